@@ -0,0 +1,145 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+func auditCmd() {
+	asJSON := false
+	filter := audit.Filter{Limit: 50}
+	workspace := ""
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		value := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--tool":
+			filter.Tool = value()
+		case "--session":
+			filter.Session = value()
+		case "--status":
+			filter.Status = value()
+		case "--errors":
+			filter.Status = audit.StatusError
+		case "--since":
+			since, err := parseSince(value(), time.Now())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			filter.Since = since
+		case "-n", "--limit":
+			n, err := strconv.Atoi(value())
+			if err != nil || n < 0 {
+				fmt.Println("Error: --limit needs a number, 0 for all")
+				return
+			}
+			filter.Limit = n
+		case "-w", "--workspace":
+			workspace = value()
+		case "-h", "--help":
+			auditHelp()
+			return
+		default:
+			fmt.Printf("Unknown option: %s\n", args[i])
+			auditHelp()
+			return
+		}
+	}
+
+	if workspace == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		workspace = cfg.WorkspacePath()
+	}
+
+	entries, err := audit.Read(audit.LogPath(workspace), filter)
+	if err != nil {
+		fmt.Printf("Error reading audit log: %v\n", err)
+		return
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			enc.Encode(e)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No tool calls recorded.")
+		return
+	}
+	for _, e := range entries {
+		who := e.Session
+		if e.Sender != "" {
+			who += " (" + e.Sender + ")"
+		}
+		fmt.Printf("%s  %-12s %-9s %6dms  %s\n",
+			e.Time.Format("2006-01-02 15:04:05"), e.Tool, e.Status, e.DurationMS, who)
+		if len(e.Args) > 0 {
+			data, _ := json.Marshal(e.Args)
+			fmt.Printf("    args: %s\n", utils.Truncate(string(data), 200))
+		}
+		if e.Preview != "" {
+			fmt.Printf("    -> %s\n", oneLine(e.Preview))
+		}
+	}
+	fmt.Printf("\n%d tool calls\n", len(entries))
+}
+
+// parseSince reads a duration back from now, such as 24h or 7d, or a date
+// in YYYY-MM-DD form.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, want a duration like 24h or 7d, or a date like 2006-01-02", s)
+}
+
+func auditHelp() {
+	fmt.Println("\nUsage: picoclaw audit [options]")
+	fmt.Println()
+	fmt.Println("Lists the tool calls the agent made, most recent last.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --tool <name>       Only calls of this tool")
+	fmt.Println("  --session <key>     Only calls in sessions starting with key")
+	fmt.Println("  --status <status>   Only calls that ended ok, error, async, denied or not_found")
+	fmt.Println("  --errors            Same as --status error")
+	fmt.Println("  --since <when>      Only calls since a duration ago (24h, 7d) or a date (2006-01-02)")
+	fmt.Println("  -n, --limit <n>     Show the last n calls (default 50, 0 for all)")
+	fmt.Println("  --json              One JSON object per line, for export")
+	fmt.Println("  -w, --workspace     Workspace to read (default: the default agent's)")
+}
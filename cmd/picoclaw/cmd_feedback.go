@@ -0,0 +1,193 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+func feedbackCmd() {
+	asJSON := false
+	report := false
+	limit := 10
+	rating := ""
+	workspace := ""
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "report":
+			report = true
+		case "-n":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fmt.Printf("Invalid -n: %s\n", args[i+1])
+					return
+				}
+				limit = n
+				i++
+			}
+		case "--json":
+			asJSON = true
+		case "--up":
+			rating = bus.FeedbackUp
+		case "--down":
+			rating = bus.FeedbackDown
+		case "-w", "--workspace":
+			if i+1 < len(args) {
+				workspace = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			feedbackHelp()
+			return
+		default:
+			fmt.Printf("Unknown option: %s\n", args[i])
+			feedbackHelp()
+			return
+		}
+	}
+
+	if workspace == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		workspace = cfg.WorkspacePath()
+	}
+
+	sm := session.NewSessionManager(filepath.Join(workspace, "sessions"))
+	defer sm.Close()
+	if report {
+		feedbackReport(sm.ListFeedback(), limit, asJSON)
+		return
+	}
+	var entries []session.SessionFeedback
+	for _, fb := range sm.ListFeedback() {
+		if rating == "" || fb.Rating == rating {
+			entries = append(entries, fb)
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, fb := range entries {
+			enc.Encode(fb)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No feedback yet.")
+		return
+	}
+	up := 0
+	for _, fb := range entries {
+		mark := "👎"
+		if fb.Rating == bus.FeedbackUp {
+			mark = "👍"
+			up++
+		}
+		fmt.Printf("%s %s  %s (%s)\n", mark, fb.Time.Format("2006-01-02 15:04"), fb.SessionKey, fb.SenderID)
+		if fb.Prompt != "" {
+			fmt.Printf("    Q: %s\n", oneLine(fb.Prompt))
+		}
+		fmt.Printf("    A: %s\n", oneLine(fb.Reply))
+		if fb.Comment != "" {
+			fmt.Printf("    Comment: %s\n", oneLine(fb.Comment))
+		}
+	}
+	fmt.Printf("\n%d ratings: %d 👍, %d 👎\n", len(entries), up, len(entries)-up)
+}
+
+// feedbackReport prints the limit worst-rated replies, and how often each
+// tool was used in a reply rated 👎, to show which prompts or skills need work.
+func feedbackReport(all []session.SessionFeedback, limit int, asJSON bool) {
+	worst := session.WorstRated(all, limit)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range worst {
+			enc.Encode(r)
+		}
+		return
+	}
+	if len(worst) == 0 {
+		fmt.Println("No 👎 ratings yet.")
+		return
+	}
+
+	for i, r := range worst {
+		turn := ""
+		if r.Turn > 0 {
+			turn = fmt.Sprintf(", message %d", r.Turn)
+		}
+		fmt.Printf("%d. score %+d (%d 👍, %d 👎)  %s%s, last rated %s\n",
+			i+1, r.Score(), r.Up, r.Down, r.SessionKey, turn, r.Last.Format("2006-01-02 15:04"))
+		if r.Prompt != "" {
+			fmt.Printf("    Q: %s\n", oneLine(r.Prompt))
+		}
+		fmt.Printf("    A: %s\n", oneLine(r.Reply))
+		if len(r.Tools) > 0 {
+			fmt.Printf("    Tools: %s\n", strings.Join(r.Tools, ", "))
+		}
+		for _, c := range r.Comments {
+			fmt.Printf("    Comment: %s\n", oneLine(c))
+		}
+	}
+
+	// Count every 👎 reply, not only the ones shown
+	downs := make(map[string]int)
+	for _, r := range session.WorstRated(all, 0) {
+		for _, tool := range r.Tools {
+			downs[tool]++
+		}
+	}
+	if len(downs) > 0 {
+		tools := make([]string, 0, len(downs))
+		for tool := range downs {
+			tools = append(tools, tool)
+		}
+		sort.Slice(tools, func(i, j int) bool {
+			if downs[tools[i]] != downs[tools[j]] {
+				return downs[tools[i]] > downs[tools[j]]
+			}
+			return tools[i] < tools[j]
+		})
+		fmt.Println("\nTools used in 👎 replies:")
+		for _, tool := range tools {
+			fmt.Printf("  %-20s %d\n", tool, downs[tool])
+		}
+	}
+}
+
+func oneLine(s string) string {
+	return utils.Truncate(strings.Join(strings.Fields(s), " "), 100)
+}
+
+func feedbackHelp() {
+	fmt.Println("\nUsage: picoclaw feedback [report] [options]")
+	fmt.Println()
+	fmt.Println("Lists 👍/👎 ratings of the agent's replies, from reactions and /good or /bad,")
+	fmt.Println("with the message each reply answered. report lists the worst-rated replies")
+	fmt.Println("first, with their comments and the tools they used.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -n <count>        Replies in the report (default: 10)")
+	fmt.Println("  --up              Only positive feedback")
+	fmt.Println("  --down            Only negative feedback")
+	fmt.Println("  --json            One JSON object per line, for export")
+	fmt.Println("  -w, --workspace   Workspace to read (default: the default agent's)")
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/agent"
@@ -24,6 +26,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
@@ -92,6 +95,17 @@ func gatewayCmd() {
 		cfg.Heartbeat.Enabled,
 	)
 	heartbeatService.SetBus(msgBus)
+	heartbeatService.SetProactive(cfg.Heartbeat.Proactive, func() []string {
+		return upcomingJobs(cronService, upcomingWindow)
+	})
+	quiet := cfg.Heartbeat.QuietHours
+	if quiet.TZ == "" {
+		quiet.TZ = cfg.Agents.Defaults.Timezone
+	}
+	if err := heartbeatService.SetQuietHours(quiet.Start, quiet.End, quiet.TZ); err != nil {
+		fmt.Printf("Error setting heartbeat quiet hours: %v\n", err)
+		os.Exit(1)
+	}
 	heartbeatService.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
 		// Use cli:direct as fallback if no valid channel
 		if channel == "" || chatID == "" {
@@ -103,9 +117,13 @@ func gatewayCmd() {
 		if err != nil {
 			return tools.ErrorResult(fmt.Sprintf("Heartbeat error: %v", err))
 		}
-		if response == "HEARTBEAT_OK" {
+		if strings.TrimSpace(response) == "HEARTBEAT_OK" {
 			return tools.SilentResult("Heartbeat OK")
 		}
+		// A proactive heartbeat replies only when the user should hear it
+		if cfg.Heartbeat.Proactive {
+			return tools.UserResult(response)
+		}
 		// For heartbeat, always return silent - the subagent result will be
 		// sent to user via processSystemMessage when the async task completes
 		return tools.SilentResult(response)
@@ -120,31 +138,16 @@ func gatewayCmd() {
 	// Inject channel manager into agent loop for command handling
 	agentLoop.SetChannelManager(channelManager)
 
-	var transcriber *voice.GroqTranscriber
-	if cfg.Providers.Groq.APIKey != "" {
-		transcriber = voice.NewGroqTranscriber(cfg.Providers.Groq.APIKey)
-		logger.InfoC("voice", "Groq voice transcription enabled")
+	transcriber, err := voice.NewTranscriber(cfg)
+	if err != nil {
+		logger.ErrorCF("voice", "Voice transcription disabled", map[string]any{"error": err.Error()})
 	}
-
 	if transcriber != nil {
-		if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
-			if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
-				tc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Telegram channel")
-			}
-		}
-		if discordChannel, ok := channelManager.GetChannel("discord"); ok {
-			if dc, ok := discordChannel.(*channels.DiscordChannel); ok {
-				dc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Discord channel")
-			}
-		}
-		if slackChannel, ok := channelManager.GetChannel("slack"); ok {
-			if sc, ok := slackChannel.(*channels.SlackChannel); ok {
-				sc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Slack channel")
-			}
-		}
+		channelManager.SetTranscriber(transcriber)
+		logger.InfoCF("voice", "Voice transcription enabled", map[string]any{
+			"provider":  cfg.Voice.Provider,
+			"available": transcriber.IsAvailable(),
+		})
 	}
 
 	enabledChannels := channelManager.GetEnabledChannels()
@@ -227,6 +230,8 @@ func setupCronTool(
 	// Create and register CronTool
 	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
 	agentLoop.RegisterTool(cronTool)
+	agentLoop.RegisterTool(tools.NewSendLaterTool(cronService))
+	agentLoop.RegisterTool(tools.NewRemindersTool(cronService))
 
 	// Set the onJob handler
 	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
@@ -234,5 +239,51 @@ func setupCronTool(
 		return result, nil
 	})
 
+	// Jobs without a time zone run on the agents' default one
+	jobs := append([]config.CronJobConfig(nil), cfg.Tools.Cron.Jobs...)
+	for i := range jobs {
+		if jobs[i].TZ == "" {
+			jobs[i].TZ = cfg.Agents.Defaults.Timezone
+		}
+	}
+	if err := cronService.SetConfigJobs(jobs); err != nil {
+		fmt.Printf("Error applying cron jobs from config: %v\n", err)
+	}
+
 	return cronService
 }
+
+// upcomingWindow is how far ahead a proactive heartbeat looks at scheduled
+// tasks.
+const upcomingWindow = 24 * time.Hour
+
+// upcomingJobs lists the enabled scheduled tasks due within the window,
+// soonest first.
+func upcomingJobs(cronService *cron.CronService, within time.Duration) []string {
+	now := time.Now()
+	jobs := cronService.ListJobs(false)
+	sort.Slice(jobs, func(i, j int) bool {
+		return nextRun(jobs[i]) < nextRun(jobs[j])
+	})
+	var items []string
+	for _, job := range jobs {
+		next := nextRun(job)
+		if next == 0 || time.UnixMilli(next).After(now.Add(within)) {
+			continue
+		}
+		what := job.Payload.Message
+		if job.Payload.Tool != "" {
+			what = "tool " + job.Payload.Tool
+		}
+		items = append(items, fmt.Sprintf("%s: %s (%s)",
+			time.UnixMilli(next).Format("2006-01-02 15:04"), job.Name, utils.Truncate(what, 120)))
+	}
+	return items
+}
+
+func nextRun(job cron.CronJob) int64 {
+	if job.State.NextRunAtMS == nil {
+		return 0
+	}
+	return *job.State.NextRunAtMS
+}
@@ -0,0 +1,88 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// mcpCmd serves the workspace tools and memory to an MCP client, which
+// starts picoclaw as a subprocess and talks to it over stdin and stdout.
+func mcpCmd() {
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--debug", "-d":
+			logger.SetLevel(logger.DEBUG)
+		case "-h", "--help":
+			mcpHelp()
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option: %s\n", args[i])
+			mcpHelp()
+			os.Exit(1)
+		}
+	}
+
+	// Stdout carries the protocol; anything else printed goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	workspace := cfg.WorkspacePath()
+	os.MkdirAll(workspace, 0o755)
+	restrict := cfg.Agents.Defaults.RestrictToWorkspace
+	allowed := cfg.Agents.Defaults.AllowedPaths
+
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadFileTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewWriteFileTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewListDirTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewGlobTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewEditFileTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewAppendFileTool(workspace, restrict, allowed...))
+	registry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
+	registry.Register(tools.NewFetchURLTool(cfg.Tools.Fetch))
+	if cfg.Tools.RunCode.Enabled {
+		registry.Register(tools.NewRunCodeTool(workspace, cfg.Tools.RunCode))
+	}
+	if cfg.Tools.Python.Enabled {
+		registry.Register(tools.NewPythonTool(workspace, cfg.Tools.Python))
+	}
+	registry.SetAccessPolicy(tools.NewAccessPolicy(cfg.Tools.Access))
+	registry.SetRetryPolicy(tools.NewRetryPolicy(cfg.Tools.Retry))
+	registry.SetResultCache(tools.NewResultCache(cfg.Tools.Cache))
+	if cfg.Tools.Audit.Enabled {
+		registry.SetAuditLog(audit.New(audit.LogPath(workspace), cfg.Tools.Audit.MaxArgChars))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	server := mcp.NewServer("picoclaw", version, registry, mcp.NewMemoryResources(workspace))
+	if err := server.Serve(ctx, os.Stdin, out); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mcpHelp() {
+	fmt.Fprintln(os.Stderr, "Usage: picoclaw mcp [--debug]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Serves the workspace tools and memory over MCP on stdin/stdout.")
+	fmt.Fprintln(os.Stderr, "Add it to a desktop client as a stdio server running `picoclaw mcp`.")
+}
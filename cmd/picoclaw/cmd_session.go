@@ -0,0 +1,172 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func sessionCmd() {
+	if len(os.Args) < 3 {
+		sessionHelp()
+		return
+	}
+	subcommand := os.Args[2]
+
+	format, output, key, workspace := "", "", "", ""
+	var positional []string
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		value := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch args[i] {
+		case "-f", "--format":
+			format = value()
+		case "-o", "--output":
+			output = value()
+		case "--key":
+			key = value()
+		case "-w", "--workspace":
+			workspace = value()
+		case "-h", "--help":
+			sessionHelp()
+			return
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+
+	if workspace == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		workspace = cfg.WorkspacePath()
+	}
+	sm := session.NewSessionManager(filepath.Join(workspace, "sessions"))
+	defer sm.Close()
+
+	switch subcommand {
+	case "list":
+		sessionListCmd(sm)
+	case "export":
+		if len(positional) != 1 {
+			fmt.Println("Usage: picoclaw session export <key> [-f md|json] [-o file]")
+			return
+		}
+		sessionExportCmd(sm, positional[0], format, output)
+	case "import":
+		if len(positional) != 1 {
+			fmt.Println("Usage: picoclaw session import <file> [--key key]")
+			return
+		}
+		sessionImportCmd(sm, positional[0], key)
+	default:
+		fmt.Printf("Unknown session command: %s\n", subcommand)
+		sessionHelp()
+	}
+}
+
+func sessionListCmd(sm *session.SessionManager) {
+	sessions := sm.List()
+	if len(sessions) == 0 {
+		fmt.Println("No sessions yet.")
+		return
+	}
+	for _, s := range sessions {
+		fmt.Printf("%s  %4d messages  %s\n", s.Updated.Format("2006-01-02 15:04"), s.Messages, s.Key)
+	}
+}
+
+func sessionExportCmd(sm *session.SessionManager, key, format, output string) {
+	transcript, err := sm.Export(key)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if format == "" {
+		format = "json"
+		if filepath.Ext(output) == ".md" {
+			format = "md"
+		}
+	}
+
+	var data []byte
+	switch format {
+	case "json":
+		if data, err = json.MarshalIndent(transcript, "", "  "); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		data = append(data, '\n')
+	case "md", "markdown":
+		data = []byte(transcript.Markdown())
+	default:
+		fmt.Printf("Unknown format %q; use json or md\n", format)
+		return
+	}
+
+	if output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", output, err)
+		return
+	}
+	fmt.Printf("Exported %d messages to %s\n", len(transcript.Messages), output)
+}
+
+func sessionImportCmd(sm *session.SessionManager, path, key string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	transcript, err := session.ParseTranscript(data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if key == "" {
+		key = transcript.Key
+	}
+	if key == "" {
+		fmt.Println("Error: the transcript names no session; pass --key")
+		return
+	}
+	if err := sm.Import(key, transcript); err != nil {
+		fmt.Printf("Error importing into %s: %v; pass --key to import into another session\n", key, err)
+		return
+	}
+	fmt.Printf("Imported %d messages into %s\n", len(transcript.Messages), key)
+}
+
+func sessionHelp() {
+	fmt.Println("\nUsage: picoclaw session <command> [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  list              List sessions, most recent first")
+	fmt.Println("  export <key>      Export a session with its tool calls and usage")
+	fmt.Println("  import <file>     Import an exported JSON session into a new session")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -f, --format      json (default) or md, readable Markdown (export)")
+	fmt.Println("  -o, --output      File to write instead of stdout (export)")
+	fmt.Println("  --key             Session to import into (default: the exported session's key)")
+	fmt.Println("  -w, --workspace   Workspace to use (default: the default agent's)")
+	fmt.Println()
+	fmt.Println("Stop the gateway before importing; it only loads sessions when it starts.")
+}
@@ -115,6 +115,14 @@ func main() {
 		authCmd()
 	case "cron":
 		cronCmd()
+	case "feedback":
+		feedbackCmd()
+	case "audit":
+		auditCmd()
+	case "session":
+		sessionCmd()
+	case "mcp":
+		mcpCmd()
 	case "skills":
 		if len(os.Args) < 3 {
 			skillsHelp()
@@ -184,6 +192,10 @@ func printHelp() {
 	fmt.Println("  gateway     Start picoclaw gateway")
 	fmt.Println("  status      Show picoclaw status")
 	fmt.Println("  cron        Manage scheduled tasks")
+	fmt.Println("  feedback    List and report ratings of the agent's replies")
+	fmt.Println("  audit       List the tool calls the agent made")
+	fmt.Println("  session     List, export and import conversations")
+	fmt.Println("  mcp         Serve workspace tools and memory to MCP clients")
 	fmt.Println("  migrate     Migrate from OpenClaw to PicoClaw")
 	fmt.Println("  skills      Manage skills (install, list, remove)")
 	fmt.Println("  version     Show version information")
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// defaultApprovalTimeout applies when tools.approval.timeout_seconds is unset.
+const defaultApprovalTimeout = 5 * time.Minute
+
+// approvals tracks tool calls waiting for /approve or /deny.
+type approvals struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval // by approval ID
+}
+
+type pendingApproval struct {
+	channel, chatID string
+	senderID        string // who sent the message the run answers
+	decision        chan bool
+}
+
+func newApprovals() *approvals {
+	return &approvals{pending: make(map[string]*pendingApproval)}
+}
+
+func (a *approvals) add(id string, p *pendingApproval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending[id] = p
+}
+
+func (a *approvals) remove(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, id)
+}
+
+// resolve delivers a decision for id. Only the chat that was asked can
+// answer, so an ID seen elsewhere can't approve a tool call, and in it only
+// the sender whose message asked for the tool, or a channel admin.
+func (a *approvals) resolve(id, channel, chatID, senderID string, admin, approved bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.pending[id]
+	if !ok || p.channel != channel || p.chatID != chatID {
+		return false
+	}
+	if p.senderID != "" && p.senderID != senderID && !admin {
+		return false
+	}
+	delete(a.pending, id)
+	p.decision <- approved
+	return true
+}
+
+// needsApproval reports whether tools.approval marks the tool: by name, or
+// by its risk level reaching tools.approval.risk.
+func (al *AgentLoop) needsApproval(agent *AgentInstance, toolName string) bool {
+	if slices.Contains(al.cfg.Tools.Approval.Tools, toolName) {
+		return true
+	}
+	tool, found := agent.Tools.Get(toolName)
+	return found && requiresApproval(al.cfg.Tools.Approval, tool)
+}
+
+// requiresApproval reports whether cfg makes tool wait for a go-ahead.
+func requiresApproval(cfg config.ApprovalConfig, tool tools.Tool) bool {
+	if slices.Contains(cfg.Tools, tool.Name()) {
+		return true
+	}
+	risk, ok := tools.ParseRiskLevel(cfg.Risk)
+	return ok && tools.ToolRisk(tool) >= risk
+}
+
+// requestApproval asks the chat the run is replying to whether the tool may
+// run, and waits for the answer. Only runs for a user message can ask;
+// heartbeats, background results and internal channels are denied.
+func (al *AgentLoop) requestApproval(ctx context.Context, opts processOptions, toolName, argsPreview string) error {
+	if !opts.SendProgress || constants.IsInternalChannel(opts.Channel) {
+		return fmt.Errorf("%s needs approval, which can't be requested from this conversation", toolName)
+	}
+
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to create approval request: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	p := &pendingApproval{
+		channel:  opts.Channel,
+		chatID:   opts.ChatID,
+		senderID: opts.SenderID,
+		decision: make(chan bool, 1),
+	}
+	al.approvals.add(id, p)
+	defer al.approvals.remove(id)
+
+	lang := al.language(opts.Channel)
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: opts.Channel,
+		ChatID:  opts.ChatID,
+		Content: i18n.T(lang, i18n.ApprovalPrompt, toolName, argsPreview),
+		Buttons: []bus.Button{
+			{Label: i18n.T(lang, i18n.ApprovalApprove), Value: "/approve " + id, Style: "primary"},
+			{Label: i18n.T(lang, i18n.ApprovalDeny), Value: "/deny " + id, Style: "danger"},
+		},
+	})
+
+	timeout := time.Duration(al.cfg.Tools.Approval.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultApprovalTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	logger.InfoCF("agent", "Waiting for tool approval", map[string]any{
+		"tool":        toolName,
+		"approval_id": id,
+		"channel":     opts.Channel,
+		"chat_id":     opts.ChatID,
+	})
+
+	select {
+	case approved := <-p.decision:
+		if !approved {
+			return fmt.Errorf("the user denied running %s", toolName)
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("approval to run %s timed out", toolName)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// approvalCommand answers a pending approval request with /approve or /deny.
+func (al *AgentLoop) approvalCommand(ctx context.Context, req commands.Request) string {
+	if len(req.Args) < 1 {
+		return commands.UsageError(req, "<id>")
+	}
+	msg := req.Message
+	approved := req.Name == "approve"
+	lang := al.language(msg.Channel)
+	admin := al.channelManager != nil && al.channelManager.Access() != nil &&
+		al.channelManager.Access().IsAdmin(msg.Channel, msg.SenderID)
+	if !al.approvals.resolve(req.Args[0], msg.Channel, msg.ChatID, msg.SenderID, admin, approved) {
+		return i18n.T(lang, i18n.ApprovalUnknown)
+	}
+	if approved {
+		return i18n.T(lang, i18n.ApprovalApproved)
+	}
+	return i18n.T(lang, i18n.ApprovalDenied)
+}
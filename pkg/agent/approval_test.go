@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// toolCallProvider calls approval_tool once, then replies with the result
+// it got back.
+type toolCallProvider struct{}
+
+func (p *toolCallProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "tool" {
+		return &providers.LLMResponse{Content: last.Content}, nil
+	}
+	return &providers.LLMResponse{
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "approval_tool", Arguments: map[string]any{}}},
+	}, nil
+}
+
+func (p *toolCallProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+type approvalTool struct {
+	runs atomic.Int32
+}
+
+func (t *approvalTool) Name() string {
+	return "approval_tool"
+}
+
+func (t *approvalTool) Description() string {
+	return "Tool that needs approval"
+}
+
+func (t *approvalTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (t *approvalTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	t.runs.Add(1)
+	return tools.SilentResult("tool ran")
+}
+
+func TestToolApproval(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		answer         string
+		continueOnDeny bool
+		wantRuns       int32
+		want           string
+	}{
+		{name: "approved", answer: "/approve", wantRuns: 1, want: "tool ran"},
+		{name: "denied", answer: "/deny", wantRuns: 0, want: "Stopped: approval_tool was not approved"},
+		{
+			name: "denied and continued", answer: "/deny", continueOnDeny: true,
+			wantRuns: 0, want: "the user denied running approval_tool",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			al := newCommandTestLoop(t, &toolCallProvider{})
+			al.cfg.Tools.Approval.Tools = []string{"approval_tool"}
+			al.cfg.Tools.Approval.ContinueOnDeny = tt.continueOnDeny
+			tool := &approvalTool{}
+			al.RegisterTool(tool)
+
+			done := make(chan string, 1)
+			go func() {
+				response, _ := al.processMessage(context.Background(), commandMessage("run it"))
+				done <- response
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			var prompt bus.OutboundMessage
+			for prompt.Content == "" || prompt.Progress {
+				var ok bool
+				if prompt, ok = al.bus.SubscribeOutbound(ctx); !ok {
+					t.Fatal("no approval prompt sent")
+				}
+			}
+			if len(prompt.Buttons) != 2 || !strings.HasPrefix(prompt.Content, "Allow approval_tool to run?") {
+				t.Fatalf("prompt = %+v", prompt)
+			}
+			id := strings.TrimPrefix(prompt.Buttons[0].Value, "/approve ")
+
+			other := commandMessage("/approve " + id)
+			other.ChatID = "another-chat"
+			if got, _ := al.processMessage(context.Background(), other); got != "No pending approval with that ID" {
+				t.Errorf("approval from another chat = %q", got)
+			}
+			// Someone else in the chat can't answer for the sender
+			bystander := commandMessage("/approve " + id)
+			bystander.SenderID = "user2"
+			if got, _ := al.processMessage(context.Background(), bystander); got != "No pending approval with that ID" {
+				t.Errorf("approval from another sender = %q", got)
+			}
+
+			al.processMessage(context.Background(), commandMessage(tt.answer+" "+id))
+
+			select {
+			case response := <-done:
+				if !strings.Contains(response, tt.want) {
+					t.Errorf("response = %q, want %q", response, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("run did not finish after the answer")
+			}
+			if runs := tool.runs.Load(); runs != tt.wantRuns {
+				t.Errorf("tool ran %d times, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestToolApprovalPromptInChannelLanguage(t *testing.T) {
+	al := newCommandTestLoop(t, &toolCallProvider{})
+	al.cfg.Tools.Approval.Tools = []string{"approval_tool"}
+	al.cfg.Channels.Language.Channels = map[string]string{"test": "zh"}
+	al.RegisterTool(&approvalTool{})
+
+	done := make(chan struct{})
+	go func() {
+		al.processMessage(context.Background(), commandMessage("run it"))
+		close(done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var prompt bus.OutboundMessage
+	for prompt.Content == "" || prompt.Progress {
+		var ok bool
+		if prompt, ok = al.bus.SubscribeOutbound(ctx); !ok {
+			t.Fatal("no approval prompt sent")
+		}
+	}
+	if !strings.HasPrefix(prompt.Content, "允许运行 approval_tool 吗？") ||
+		len(prompt.Buttons) != 2 || prompt.Buttons[0].Label != "批准" || prompt.Buttons[1].Label != "拒绝" {
+		t.Fatalf("prompt = %+v", prompt)
+	}
+
+	id := strings.TrimPrefix(prompt.Buttons[1].Value, "/deny ")
+	if got, _ := al.processMessage(context.Background(), commandMessage("/deny "+id)); got != "已拒绝" {
+		t.Errorf("/deny reply = %q", got)
+	}
+	<-done
+}
+
+func TestNeedsApprovalByRisk(t *testing.T) {
+	al := newCommandTestLoop(t, &toolCallProvider{})
+	al.RegisterTool(&approvalTool{})
+	agent := al.registry.GetDefaultAgent()
+
+	if al.needsApproval(agent, "exec") || al.needsApproval(agent, "approval_tool") {
+		t.Fatal("tools need approval with nothing marked")
+	}
+
+	al.cfg.Tools.Approval.Risk = "high"
+	for name, want := range map[string]bool{
+		"exec":          true,
+		"write_file":    true,
+		"approval_tool": true, // declares no risk, so counts as high
+		"read_file":     false,
+	} {
+		if got := al.needsApproval(agent, name); got != want {
+			t.Errorf("needsApproval(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	al.cfg.Tools.Approval.Risk = "medium"
+	if !al.needsApproval(agent, "read_file") {
+		t.Error("read_file should need approval at medium risk")
+	}
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// turnBudget tracks what a turn has spent on model calls against
+// agents.defaults.budget. A nil budget is uncapped.
+type turnBudget struct {
+	maxTokens int
+	maxCost   float64
+	// Prices of the agent's model in US dollars per million tokens. Calls
+	// to other models, such as the reviewer's, are priced the same.
+	inputPrice  float64
+	outputPrice float64
+
+	tokens int
+	cost   float64
+}
+
+// newTurnBudget returns the budget for a turn of agent, or nil when no
+// cap is configured.
+func (al *AgentLoop) newTurnBudget(agent *AgentInstance) *turnBudget {
+	cfg := al.cfg.Agents.Defaults.Budget
+	if cfg.MaxTokens <= 0 && cfg.MaxCost <= 0 {
+		return nil
+	}
+	b := &turnBudget{maxTokens: cfg.MaxTokens, maxCost: cfg.MaxCost}
+	b.inputPrice, b.outputPrice = al.cfg.ModelPrice(agent.Model)
+	return b
+}
+
+// spend counts a model call; usage may be nil when the provider doesn't
+// report it.
+func (b *turnBudget) spend(usage *providers.UsageInfo) {
+	if b == nil || usage == nil {
+		return
+	}
+	tokens := usage.TotalTokens
+	if tokens == 0 {
+		tokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	b.tokens += tokens
+	b.cost += (float64(usage.PromptTokens)*b.inputPrice + float64(usage.CompletionTokens)*b.outputPrice) / 1e6
+}
+
+// exceeded reports whether the turn has spent all of either cap.
+func (b *turnBudget) exceeded() bool {
+	if b == nil {
+		return false
+	}
+	return (b.maxTokens > 0 && b.tokens >= b.maxTokens) || (b.maxCost > 0 && b.cost >= b.maxCost)
+}
+
+// String describes the caps, such as "50000 tokens and $0.10".
+func (b *turnBudget) String() string {
+	var caps []string
+	if b.maxTokens > 0 {
+		caps = append(caps, fmt.Sprintf("%d tokens", b.maxTokens))
+	}
+	if b.maxCost > 0 {
+		caps = append(caps, fmt.Sprintf("$%.2f", b.maxCost))
+	}
+	return strings.Join(caps, " and ")
+}
+
+// remaining describes what is left of each cap.
+func (b *turnBudget) remaining() string {
+	var left []string
+	if b.maxTokens > 0 {
+		left = append(left, fmt.Sprintf("%d of %d tokens", max(b.maxTokens-b.tokens, 0), b.maxTokens))
+	}
+	if b.maxCost > 0 {
+		left = append(left, fmt.Sprintf("$%.4f of $%.2f", max(b.maxCost-b.cost, 0), b.maxCost))
+	}
+	return strings.Join(left, " and ")
+}
+
+// annotate returns messages with the remaining budget noted at the end of
+// the system prompt, so the model can spend what is left wisely. messages
+// itself is left as it was.
+func (b *turnBudget) annotate(messages []providers.Message) []providers.Message {
+	if b == nil || len(messages) == 0 {
+		return messages
+	}
+	messages = slices.Clone(messages)
+	messages[0].Content += fmt.Sprintf("\n\n## Budget\n\nThis turn has %s left for model calls. "+
+		"Every tool round costs another call with the whole conversation, so prefer fewer, "+
+		"well-chosen tool calls. When the budget runs out the turn is stopped.", b.remaining())
+	return messages
+}
+
+// recordTurnUsage counts a model call made during a turn against both the
+// session and the turn's budget.
+func (al *AgentLoop) recordTurnUsage(agent *AgentInstance, opts processOptions, usage *providers.UsageInfo) {
+	al.recordUsage(agent, opts.SessionKey, usage)
+	opts.Budget.spend(usage)
+}
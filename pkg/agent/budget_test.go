@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// spendingProvider polls forever, reporting 100 tokens per call
+type spendingProvider struct {
+	calls   int
+	systems []string
+}
+
+func (p *spendingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	usage := &providers.UsageInfo{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}
+	p.calls++
+	p.systems = append(p.systems, messages[0].Content)
+	return &providers.LLMResponse{
+		ToolCalls: []providers.ToolCall{
+			{ID: "call_1", Name: "poll_tool", Arguments: map[string]any{"attempt": p.calls}},
+		},
+		Usage: usage,
+	}, nil
+}
+
+func (p *spendingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRunLLMIteration_StopsWhenBudgetIsUsedUp(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Budget:            config.TurnBudgetConfig{MaxTokens: 250},
+			},
+		},
+	}
+	provider := &spendingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	tool := &pollTool{}
+	al.RegisterTool(tool)
+
+	response := testHelper{al: al}.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:    "test",
+		SenderID:   "u1",
+		ChatID:     "c1",
+		Content:    "wait for the build to finish",
+		SessionKey: "agent:main:test-budget",
+	})
+
+	if tool.runs != 2 {
+		t.Errorf("tool ran %d times, want 2", tool.runs)
+	}
+	// The third call goes over; nothing more is asked of the model
+	if provider.calls != 3 {
+		t.Errorf("model called %d times, want 3", provider.calls)
+	}
+	// The reply says what was done before the budget ran out
+	want := i18n.T("en", i18n.BudgetExceeded, "250 tokens") + "\n\nDone so far:\n- poll_tool\n- poll_tool"
+	if response != want {
+		t.Errorf("response = %q", response)
+	}
+	if !strings.Contains(provider.systems[0], "250 of 250 tokens") ||
+		!strings.Contains(provider.systems[2], "50 of 250 tokens") {
+		t.Errorf("system prompts don't show the remaining budget: %q", provider.systems)
+	}
+}
+
+func TestTurnBudget_Cost(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Budget: config.TurnBudgetConfig{MaxCost: 0.01}},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "gpt4", Model: "openai/gpt-4o", InputPrice: 2.5, OutputPrice: 10},
+		},
+	}
+	al := &AgentLoop{cfg: cfg}
+	b := al.newTurnBudget(&AgentInstance{Model: "gpt4"})
+
+	// $0.0025 + $0.005
+	b.spend(&providers.UsageInfo{PromptTokens: 1000, CompletionTokens: 500})
+	if b.exceeded() {
+		t.Fatalf("exceeded after $%.4f of $0.01", b.cost)
+	}
+	b.spend(&providers.UsageInfo{PromptTokens: 1000, CompletionTokens: 500})
+	if !b.exceeded() {
+		t.Errorf("not exceeded after $%.4f of $0.01", b.cost)
+	}
+	if got := b.String(); got != "$0.01" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestTurnBudget_Uncapped(t *testing.T) {
+	al := &AgentLoop{cfg: &config.Config{}}
+	b := al.newTurnBudget(&AgentInstance{Model: "gpt4"})
+	if b != nil {
+		t.Fatalf("budget = %+v, want nil", b)
+	}
+	b.spend(&providers.UsageInfo{TotalTokens: 1 << 20})
+	if b.exceeded() {
+		t.Error("a nil budget is never exceeded")
+	}
+	messages := []providers.Message{{Role: "system", Content: "prompt"}}
+	if got := b.annotate(messages); got[0].Content != "prompt" {
+		t.Errorf("annotate changed the prompt: %q", got[0].Content)
+	}
+}
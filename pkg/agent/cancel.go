@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// cancelledNote is saved as the reply of a cancelled turn, so the model
+// knows next time that it didn't finish.
+const cancelledNote = "[The user cancelled this turn before it finished.]"
+
+// activeRun lets /cancel stop the agent run of a session, and keeps track of
+// the tools it ran so the user learns what was done before it stopped.
+type activeRun struct {
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	done    []string
+	running string
+}
+
+// toolStarted records that the run is calling a tool. Safe on a nil run.
+func (r *activeRun) toolStarted(name string, args map[string]any) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = toolSummary(name, args)
+}
+
+// toolFinished records that the tool the run was calling returned.
+func (r *activeRun) toolFinished(failed bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == "" {
+		return
+	}
+	if failed {
+		r.running += " (failed)"
+	}
+	r.done = append(r.done, r.running)
+	r.running = ""
+}
+
+// finished returns the tool calls the run has completed. Safe on a nil run.
+func (r *activeRun) finished() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.done)
+}
+
+// report describes what the run had done when it was cancelled.
+func (r *activeRun) report() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString("Cancelled")
+	if r.running != "" {
+		sb.WriteString(" while running " + r.running)
+	}
+	if len(r.done) > 0 {
+		sb.WriteString(". Done before that:\n- " + strings.Join(r.done, "\n- "))
+	}
+	return sb.String()
+}
+
+// toolSummary names a tool call by the tool and the argument that tells
+// calls apart: the file, command, URL or query.
+func toolSummary(name string, args map[string]any) string {
+	for _, key := range []string{"path", "command", "url", "query", "action"} {
+		if value, ok := args[key].(string); ok && value != "" {
+			return fmt.Sprintf("%s (%s)", name, utils.Truncate(value, 60))
+		}
+	}
+	return name
+}
@@ -0,0 +1,650 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// registerCommands adds the built-in slash commands.
+func (al *AgentLoop) registerCommands() {
+	for _, cmd := range []commands.Command{
+		{
+			Name:        "help",
+			Description: "Show available commands",
+			Handler: func(ctx context.Context, req commands.Request) string {
+				return al.commands.Help()
+			},
+		},
+		{
+			Name:        "reset",
+			Aliases:     []string{"new"},
+			Description: "Start a fresh conversation",
+			Handler:     al.resetCommand,
+		},
+		{
+			Name:        "model",
+			Usage:       "[name]",
+			Description: "Show or switch the model of this conversation's agent",
+			Handler:     al.modelCommand,
+		},
+		{
+			Name:        "usage",
+			Description: "Show token usage of this conversation",
+			Handler:     al.usageCommand,
+		},
+		{
+			Name:        "good",
+			Usage:       "[comment]",
+			Description: "Rate the last reply as good, optionally saying why",
+			Handler:     al.rateCommand,
+		},
+		{
+			Name:        "bad",
+			Usage:       "[comment]",
+			Description: "Rate the last reply as bad, optionally saying what went wrong",
+			Handler:     al.rateCommand,
+		},
+		{
+			Name:        "cancel",
+			Aliases:     []string{"stop"},
+			Description: "Stop the reply in progress",
+			Immediate:   true,
+			Handler:     al.cancelCommand,
+		},
+		{
+			Name:        "tasks",
+			Usage:       "[id|cancel <id>]",
+			Description: "List this chat's background tasks, show one with its result, or cancel one",
+			Immediate:   true,
+			Handler:     al.tasksCommand,
+		},
+		{
+			Name:        "approve",
+			Usage:       "<id>",
+			Description: "Allow a tool call waiting for approval",
+			Immediate:   true,
+			Handler:     al.approvalCommand,
+		},
+		{
+			Name:        "deny",
+			Usage:       "<id>",
+			Description: "Refuse a tool call waiting for approval",
+			Immediate:   true,
+			Handler:     al.approvalCommand,
+		},
+		{
+			Name:        "show",
+			Usage:       "[model|channel|agents]",
+			Description: "Show current configuration",
+			Handler:     al.showCommand,
+		},
+		{
+			Name:        "list",
+			Usage:       "[models|channels|agents]",
+			Description: "List available options",
+			Handler:     al.listCommand,
+		},
+		{
+			Name:        "switch",
+			Usage:       "[<branch> | model to <name> | channel to <name>]",
+			Description: "List or switch conversation branches, or switch the default model or target channel",
+			Handler:     al.switchCommand,
+		},
+		{
+			Name:        "export",
+			Usage:       "[md|json]",
+			Description: "Save this conversation as Markdown (default) or JSON and send it",
+			Handler:     al.exportCommand,
+		},
+		{
+			Name:        "import",
+			Usage:       "<file>",
+			Description: "Continue an exported JSON conversation from the workspace in a new branch",
+			Handler:     al.importCommand,
+		},
+		{
+			Name:        "fork",
+			Usage:       "[name]",
+			Description: "Copy this conversation into a new branch and continue there",
+			Handler:     al.forkCommand,
+		},
+		{
+			Name:        "persona",
+			Usage:       "[name|off]",
+			Description: "List personas, or switch this conversation to one, keeping its history",
+			Handler:     al.personaCommand,
+		},
+		{
+			Name:        "dryrun",
+			Usage:       "[on|off]",
+			Description: "Show or switch dry-run mode, where tools that change things only say what they would do",
+			Handler:     al.dryRunCommand,
+		},
+		{
+			Name:        "undo",
+			Description: "Revert the files the agent changed in its last reply",
+			Handler:     al.undoCommand,
+		},
+		{
+			Name:        "status",
+			Description: "Show the health of each channel (admins)",
+			Handler:     al.statusCommand,
+		},
+		{
+			Name:        "undelivered",
+			Description: "List replies that failed to send (admins)",
+			Handler:     al.undeliveredCommand,
+		},
+		{
+			Name:        "announce",
+			Usage:       "<message>",
+			Description: "Send a message to all broadcast targets (admins)",
+			Handler:     al.announceCommand,
+		},
+		{
+			Name:        "allow",
+			Usage:       "<sender_id> [channel]",
+			Description: "Grant a sender access (admins)",
+			Handler:     al.accessCommand,
+		},
+		{
+			Name:        "revoke",
+			Usage:       "<sender_id> [channel]",
+			Description: "Revoke a runtime access grant (admins)",
+			Handler:     al.accessCommand,
+		},
+		{
+			Name:        "allowed",
+			Usage:       "[channel]",
+			Description: "List runtime access grants (admins)",
+			Handler:     al.accessCommand,
+		},
+	} {
+		al.commands.Register(cmd)
+	}
+}
+
+// commandText returns what follows the command name in content, with line
+// breaks kept, unlike the split up commands.Request.Args.
+func commandText(content string) string {
+	content = strings.TrimSpace(content)
+	i := strings.IndexFunc(content, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(content[i:])
+}
+
+func (al *AgentLoop) resetCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	if err := agent.Sessions.Archive(req.SessionKey); err != nil {
+		return fmt.Sprintf("Could not archive the conversation: %v", err)
+	}
+	return "Conversation archived. Let's start fresh."
+}
+
+func (al *AgentLoop) modelCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	if len(req.Args) == 0 {
+		return fmt.Sprintf("Current model: %s", agent.Model)
+	}
+	oldModel := agent.Model
+	agent.Model = req.Args[0]
+	return fmt.Sprintf("Switched model from %s to %s", oldModel, agent.Model)
+}
+
+func (al *AgentLoop) usageCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	conversation, total := agent.Sessions.GetUsage(req.SessionKey), al.usage.total()
+	return fmt.Sprintf("This conversation: %s\nSince start: %s", conversation, total)
+}
+
+func (al *AgentLoop) cancelCommand(ctx context.Context, req commands.Request) string {
+	run, ok := al.activeRuns.Load(req.SessionKey)
+	if !ok {
+		return "Nothing to cancel"
+	}
+	run.(*activeRun).cancel()
+	return run.(*activeRun).report()
+}
+
+func (al *AgentLoop) showCommand(ctx context.Context, req commands.Request) string {
+	if len(req.Args) < 1 {
+		return commands.UsageError(req, "[model|channel|agents]")
+	}
+	switch req.Args[0] {
+	case "model":
+		defaultAgent := al.registry.GetDefaultAgent()
+		if defaultAgent == nil {
+			return "No default agent configured"
+		}
+		return fmt.Sprintf("Current model: %s", defaultAgent.Model)
+	case "channel":
+		return fmt.Sprintf("Current channel: %s", req.Message.Channel)
+	case "agents":
+		agentIDs := al.registry.ListAgentIDs()
+		return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", "))
+	default:
+		return fmt.Sprintf("Unknown show target: %s", req.Args[0])
+	}
+}
+
+func (al *AgentLoop) listCommand(ctx context.Context, req commands.Request) string {
+	if len(req.Args) < 1 {
+		return commands.UsageError(req, "[models|channels|agents]")
+	}
+	switch req.Args[0] {
+	case "models":
+		return "Available models: configured in config.json per agent"
+	case "channels":
+		if al.channelManager == nil {
+			return "Channel manager not initialized"
+		}
+		channels := al.channelManager.GetEnabledChannels()
+		if len(channels) == 0 {
+			return "No channels enabled"
+		}
+		return fmt.Sprintf("Enabled channels: %s", strings.Join(channels, ", "))
+	case "agents":
+		agentIDs := al.registry.ListAgentIDs()
+		return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", "))
+	default:
+		return fmt.Sprintf("Unknown list target: %s", req.Args[0])
+	}
+}
+
+func (al *AgentLoop) switchCommand(ctx context.Context, req commands.Request) string {
+	args := req.Args
+	if len(args) == 0 || (len(args) == 1 && args[0] != "model" && args[0] != "channel") {
+		return al.switchBranch(req)
+	}
+	if len(args) < 3 || args[1] != "to" {
+		return commands.UsageError(req, "[<branch> | model to <name> | channel to <name>]")
+	}
+	target := args[0]
+	value := args[2]
+
+	switch target {
+	case "model":
+		defaultAgent := al.registry.GetDefaultAgent()
+		if defaultAgent == nil {
+			return "No default agent configured"
+		}
+		oldModel := defaultAgent.Model
+		defaultAgent.Model = value
+		return fmt.Sprintf("Switched model from %s to %s", oldModel, value)
+	case "channel":
+		if al.channelManager == nil {
+			return "Channel manager not initialized"
+		}
+		if _, exists := al.channelManager.GetChannel(value); !exists && value != "cli" {
+			return fmt.Sprintf("Channel '%s' not found or not enabled", value)
+		}
+		return fmt.Sprintf("Switched target channel to %s", value)
+	default:
+		return fmt.Sprintf("Unknown switch target: %s", target)
+	}
+}
+
+// forkCommand copies the conversation into a new branch, so the user can
+// try another direction and still go back to where they were.
+func (al *AgentLoop) forkCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	var name string
+	if len(req.Args) > 0 {
+		name = req.Args[0]
+	}
+	_, from := session.BaseKey(req.SessionKey)
+	name, err := agent.Sessions.Fork(req.SessionKey, name)
+	if err != nil {
+		return fmt.Sprintf("Could not fork: %v", err)
+	}
+	return fmt.Sprintf("Forked %s into branch %s, which you are on now. /switch %s goes back.", from, name, from)
+}
+
+// dryRunCommand shows or sets dry-run mode for the conversation.
+func (al *AgentLoop) dryRunCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	if len(req.Args) == 0 {
+		if al.dryRunEnabled(agent, req.SessionKey) {
+			return "Dry-run mode is on: tools that change things only say what they would do. /dryrun off ends it."
+		}
+		return "Dry-run mode is off. /dryrun on makes tools that change things only say what they would do."
+	}
+
+	var on bool
+	switch strings.ToLower(req.Args[0]) {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return commands.UsageError(req, "[on|off]")
+	}
+	agent.Sessions.SetDryRun(req.SessionKey, on)
+	if err := agent.Sessions.Save(req.SessionKey); err != nil {
+		return fmt.Sprintf("Could not save the setting: %v", err)
+	}
+	if on {
+		return "Dry-run mode is on. Files, commands and other changes are described instead of made."
+	}
+	return "Dry-run mode is off. Tools run for real again."
+}
+
+// undoCommand reverts the file changes of the conversation's latest turn
+// that changed any.
+func (al *AgentLoop) undoCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	reverted, failed, err := agent.FileHistory.Undo(req.SessionKey)
+	if errors.Is(err, tools.ErrNothingToUndo) {
+		return "Nothing to undo: no files were changed in this conversation since the agent started."
+	}
+
+	var sb strings.Builder
+	if len(reverted) > 0 {
+		sb.WriteString("Reverted:\n- " + strings.Join(reverted, "\n- "))
+	}
+	if len(failed) > 0 {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("Could not revert:\n- " + strings.Join(failed, "\n- "))
+	}
+	return sb.String()
+}
+
+// switchBranch lists the conversation's branches, or moves it to the one
+// named in req.
+func (al *AgentLoop) switchBranch(req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	base, current := session.BaseKey(req.SessionKey)
+	if len(req.Args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("Branches of this conversation:")
+		for _, name := range agent.Sessions.Branches(base) {
+			sb.WriteString("\n- " + name)
+			if name == current {
+				sb.WriteString(" (current)")
+			}
+		}
+		sb.WriteString("\n/switch <branch> moves to one, /fork [name] starts a new one.")
+		return sb.String()
+	}
+
+	name := strings.ToLower(req.Args[0])
+	if name == current {
+		return fmt.Sprintf("Already on branch %s", name)
+	}
+	if err := agent.Sessions.Switch(base, name); err != nil {
+		if errors.Is(err, session.ErrNoBranch) {
+			return fmt.Sprintf("No branch %s; /switch lists them", name)
+		}
+		return fmt.Sprintf("Could not switch branches: %v", err)
+	}
+	return fmt.Sprintf("Switched to branch %s", name)
+}
+
+// exportCommand writes the conversation to exports/ in the workspace and
+// sends the file to chats that can take attachments.
+func (al *AgentLoop) exportCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	format := "md"
+	if len(req.Args) > 0 {
+		format = strings.ToLower(req.Args[0])
+	}
+	if format != "md" && format != "json" {
+		return commands.UsageError(req, "[md|json]")
+	}
+
+	transcript, err := agent.Sessions.Export(req.SessionKey)
+	if err != nil {
+		return fmt.Sprintf("Nothing to export: %v", err)
+	}
+	var data []byte
+	if format == "json" {
+		data, err = json.MarshalIndent(transcript, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Could not export: %v", err)
+		}
+	} else {
+		data = []byte(transcript.Markdown())
+	}
+
+	dir := filepath.Join(agent.Workspace, "exports")
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(req.SessionKey) +
+		"-" + time.Now().Format("20060102-150405") + "." + format
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Sprintf("Could not export: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Sprintf("Could not export: %v", err)
+	}
+
+	reply := fmt.Sprintf("Exported %d messages to exports/%s", len(transcript.Messages), name)
+	if constants.IsInternalChannel(req.Message.Channel) {
+		return reply
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: req.Message.Channel,
+		ChatID:  req.Message.ChatID,
+		Content: reply,
+		Media:   []string{path},
+	})
+	return ""
+}
+
+// importCommand loads a JSON transcript from the workspace, such as an
+// attachment the user sent, into a new branch of the conversation.
+func (al *AgentLoop) importCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	if len(req.Args) != 1 {
+		return commands.UsageError(req, "<file>")
+	}
+	path, err := tools.WorkspacePath(req.Args[0], agent.Workspace)
+	if err != nil {
+		return "Only files in the workspace can be imported"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("Could not read %s: %v", req.Args[0], err)
+	}
+	transcript, err := session.ParseTranscript(data)
+	if err != nil {
+		return fmt.Sprintf("Could not import: %v", err)
+	}
+
+	base, _ := session.BaseKey(req.SessionKey)
+	name, err := agent.Sessions.ImportBranch(base, transcript)
+	if err != nil {
+		return fmt.Sprintf("Could not import: %v", err)
+	}
+	return fmt.Sprintf("Imported %d messages into branch %s, which you are on now. /switch main goes back.",
+		len(transcript.Messages), name)
+}
+
+// accessCommand lets admins (channels.access.admins) manage runtime
+// access grants. The channel defaults to the one the command came from.
+func (al *AgentLoop) accessCommand(ctx context.Context, req commands.Request) string {
+	msg, args := req.Message, req.Args
+	if al.channelManager == nil || al.channelManager.Access() == nil {
+		return "Channel manager not initialized"
+	}
+	access := al.channelManager.Access()
+	if !access.IsAdmin(msg.Channel, msg.SenderID) {
+		return "Only admins can manage access"
+	}
+
+	channel := msg.Channel
+	if req.Name == "allowed" {
+		if len(args) > 0 {
+			channel = args[0]
+		}
+		granted := access.Granted(channel)
+		if len(granted) == 0 {
+			return fmt.Sprintf("No runtime grants for %s", channel)
+		}
+		return fmt.Sprintf("Granted on %s: %s", channel, strings.Join(granted, ", "))
+	}
+
+	if len(args) < 1 {
+		return commands.UsageError(req, "<sender_id> [channel]")
+	}
+	senderID := args[0]
+	if len(args) > 1 {
+		channel = args[1]
+	}
+
+	if req.Name == "allow" {
+		if err := access.Grant(channel, senderID); err != nil {
+			return fmt.Sprintf("Failed to save access grant: %v", err)
+		}
+		return fmt.Sprintf("Granted %s access on %s", senderID, channel)
+	}
+
+	removed, err := access.Revoke(channel, senderID)
+	if err != nil {
+		return fmt.Sprintf("Failed to save access grant: %v", err)
+	}
+	if !removed {
+		return fmt.Sprintf("%s has no runtime grant on %s", senderID, channel)
+	}
+	return fmt.Sprintf("Revoked %s access on %s", senderID, channel)
+}
+
+// statusCommand lists each channel's connection health for admins.
+func (al *AgentLoop) statusCommand(ctx context.Context, req commands.Request) string {
+	msg := req.Message
+	if al.channelManager == nil || al.channelManager.Access() == nil {
+		return "Channel manager not initialized"
+	}
+	if !al.channelManager.Access().IsAdmin(msg.Channel, msg.SenderID) {
+		return "Only admins can view channel status"
+	}
+
+	health := al.channelManager.Health()
+	if len(health) == 0 {
+		return "No channels enabled"
+	}
+	return "Channels:\n" + channels.FormatHealth(health, time.Now())
+}
+
+// undeliveredCommand lists replies still waiting for a retry or given up
+// on, for admins.
+func (al *AgentLoop) undeliveredCommand(ctx context.Context, req commands.Request) string {
+	msg := req.Message
+	if al.channelManager == nil || al.channelManager.Access() == nil {
+		return "Channel manager not initialized"
+	}
+	if !al.channelManager.Access().IsAdmin(msg.Channel, msg.SenderID) {
+		return "Only admins can view undelivered replies"
+	}
+
+	log := al.channelManager.Deliveries()
+	stats := log.Stats()
+	summary := fmt.Sprintf("Recent replies: %d accepted, %d retried, %d pending, %d failed",
+		stats.Accepted, stats.Retried, stats.Pending, stats.Failed)
+	undelivered := log.Undelivered()
+	if len(undelivered) == 0 {
+		return summary
+	}
+	return summary + "\n" + channels.FormatDeliveries(undelivered, time.Now())
+}
+
+// announceCommand lets admins send a message to channels.broadcast.targets.
+// The text after the command is sent as written, line breaks included.
+func (al *AgentLoop) announceCommand(ctx context.Context, req commands.Request) string {
+	msg := req.Message
+	if al.channelManager == nil || al.channelManager.Access() == nil {
+		return "Channel manager not initialized"
+	}
+	if !al.channelManager.Access().IsAdmin(msg.Channel, msg.SenderID) {
+		return "Only admins can announce"
+	}
+
+	targets := al.cfg.Channels.Broadcast.Targets
+	if len(targets) == 0 {
+		return "No broadcast targets configured (channels.broadcast.targets)"
+	}
+
+	content := commandText(msg.Content)
+	if content == "" {
+		return commands.UsageError(req, "<message>")
+	}
+
+	if err := al.bus.Broadcast(ctx, targets, content); err != nil {
+		return fmt.Sprintf("Announcement not delivered everywhere:\n%v", err)
+	}
+	return fmt.Sprintf("Announced to %d recipients", len(targets))
+}
+
+// usageTracker sums the token usage reported by the provider over all
+// sessions since the agent started; sessions keep their own totals.
+type usageTracker struct {
+	mu  sync.Mutex
+	all session.Usage
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{}
+}
+
+func (t *usageTracker) record(usage *providers.UsageInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.all.Add(usage)
+}
+
+func (t *usageTracker) total() session.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.all
+}
+
+// recordUsage counts a model call made for a session.
+func (al *AgentLoop) recordUsage(agent *AgentInstance, sessionKey string, usage *providers.UsageInfo) {
+	al.usage.record(usage)
+	agent.Sessions.AddUsage(sessionKey, usage)
+}
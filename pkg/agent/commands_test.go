@@ -0,0 +1,346 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// usageProvider reports fixed token usage, or blocks until cancelled when
+// block is set.
+type usageProvider struct {
+	block   bool
+	started chan struct{}
+	once    sync.Once
+}
+
+func (p *usageProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.block {
+		p.once.Do(func() { close(p.started) })
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	}, nil
+}
+
+func (p *usageProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newCommandTestLoop(t *testing.T, provider providers.LLMProvider) *AgentLoop {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func commandMessage(content string) bus.InboundMessage {
+	return bus.InboundMessage{
+		Channel:    "test",
+		SenderID:   "user1",
+		ChatID:     "chat1",
+		Content:    content,
+		SessionKey: "agent:main:commands",
+	}
+}
+
+func TestCommands_UsageAndReset(t *testing.T) {
+	al := newCommandTestLoop(t, &usageProvider{})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	helper.executeAndGetResponse(t, ctx, commandMessage("hello"))
+
+	usage := helper.executeAndGetResponse(t, ctx, commandMessage("/usage"))
+	if !strings.Contains(usage, "120 tokens (100 prompt, 20 completion) over 1 model calls") {
+		t.Errorf("/usage = %q", usage)
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	if len(agent.Sessions.GetHistory("agent:main:commands")) == 0 {
+		t.Fatal("expected history before /reset")
+	}
+	// Usage is kept with the session, so it survives a restart
+	reloaded := session.NewSessionManager(filepath.Join(agent.Workspace, "sessions"))
+	if got := reloaded.GetUsage("agent:main:commands"); got.TotalTokens != 120 {
+		t.Errorf("saved usage = %+v", got)
+	}
+
+	helper.executeAndGetResponse(t, ctx, commandMessage("/reset"))
+	if history := agent.Sessions.GetHistory("agent:main:commands"); len(history) != 0 {
+		t.Errorf("history after /reset has %d messages", len(history))
+	}
+	if archives, _ := agent.Sessions.Archived("agent:main:commands"); len(archives) != 1 {
+		t.Errorf("archives after /reset = %v, want one", archives)
+	}
+	usage = helper.executeAndGetResponse(t, ctx, commandMessage("/usage"))
+	if !strings.Contains(usage, "This conversation: no model calls yet") {
+		t.Errorf("/usage after /reset = %q", usage)
+	}
+
+	model := helper.executeAndGetResponse(t, ctx, commandMessage("/model other-model"))
+	if agent.Model != "other-model" {
+		t.Errorf("/model = %q, agent model %q", model, agent.Model)
+	}
+	if help := helper.executeAndGetResponse(t, ctx, commandMessage("/help")); !strings.Contains(help, "/cancel") {
+		t.Errorf("/help = %q", help)
+	}
+}
+
+func TestCommands_CancelStopsActiveRun(t *testing.T) {
+	provider := &usageProvider{block: true, started: make(chan struct{})}
+	al := newCommandTestLoop(t, provider)
+
+	type result struct {
+		response string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := al.processMessage(context.Background(), commandMessage("long task"))
+		done <- result{response, err}
+	}()
+	<-provider.started
+
+	if got, _ := al.processMessage(context.Background(), commandMessage("/cancel")); got != "Cancelled" {
+		t.Errorf("/cancel = %q", got)
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil || r.response != "" {
+			t.Errorf("cancelled run = (%q, %v), want no reply", r.response, r.err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("run did not stop after /cancel")
+	}
+
+	if got, _ := al.processMessage(context.Background(), commandMessage("/cancel")); got != "Nothing to cancel" {
+		t.Errorf("second /cancel = %q", got)
+	}
+}
+
+func TestCommands_ForkAndSwitch(t *testing.T) {
+	al := newCommandTestLoop(t, &usageProvider{})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+	agent := al.registry.GetDefaultAgent()
+	base := "agent:main:commands"
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/fork")); !strings.Contains(got, "no conversation") {
+		t.Errorf("/fork of an empty conversation = %q", got)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("plan a trip to Rome"))
+
+	got := helper.executeAndGetResponse(t, ctx, commandMessage("/fork by-train"))
+	if !strings.Contains(got, "branch by-train") {
+		t.Fatalf("/fork = %q", got)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("let's go by train"))
+	branch := agent.Sessions.GetHistory(session.BranchKey(base, "by-train"))
+	if len(branch) != 4 || branch[2].Content != "let's go by train" {
+		t.Errorf("branch history = %+v", branch)
+	}
+	if main := agent.Sessions.GetHistory(base); len(main) != 2 {
+		t.Errorf("main history has %d messages, want the 2 from before the fork", len(main))
+	}
+
+	list := helper.executeAndGetResponse(t, ctx, commandMessage("/switch"))
+	if !strings.Contains(list, "- main\n- by-train (current)") {
+		t.Errorf("/switch = %q", list)
+	}
+	missing := helper.executeAndGetResponse(t, ctx, commandMessage("/switch by-plane"))
+	if !strings.HasPrefix(missing, "No branch") {
+		t.Errorf("/switch to a missing branch = %q", missing)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("/switch main"))
+	helper.executeAndGetResponse(t, ctx, commandMessage("what about flying?"))
+	if main := agent.Sessions.GetHistory(base); len(main) != 4 || main[2].Content != "what about flying?" {
+		t.Errorf("main history after switching back = %+v", main)
+	}
+
+	// The active branch survives a restart
+	helper.executeAndGetResponse(t, ctx, commandMessage("/switch by-train"))
+	reloaded := session.NewSessionManager(filepath.Join(agent.Workspace, "sessions"))
+	if got := reloaded.ActiveKey(base); got != session.BranchKey(base, "by-train") {
+		t.Errorf("reloaded active key = %q", got)
+	}
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/switch model")); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("/switch model = %q", got)
+	}
+}
+
+func TestCommands_ExportAndImport(t *testing.T) {
+	al := newCommandTestLoop(t, &usageProvider{})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+	agent := al.registry.GetDefaultAgent()
+	base := "agent:main:commands"
+
+	helper.executeAndGetResponse(t, ctx, commandMessage("plan a trip to Rome"))
+
+	// Chat channels get the file as an attachment and no text reply
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/export json")); got != "" {
+		t.Errorf("/export on a chat channel = %q", got)
+	}
+	outCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	out, ok := al.bus.SubscribeOutbound(outCtx)
+	if !ok || len(out.Media) != 1 || !strings.HasSuffix(out.Media[0], ".json") {
+		t.Fatalf("outbound after /export = %+v", out)
+	}
+
+	cli := commandMessage("/export")
+	cli.Channel = "cli"
+	if got := helper.executeAndGetResponse(t, ctx, cli); !strings.Contains(got, "Exported 2 messages to exports/") {
+		t.Errorf("/export on the CLI = %q", got)
+	}
+
+	rel, _ := filepath.Rel(agent.Workspace, out.Media[0])
+	got := helper.executeAndGetResponse(t, ctx, commandMessage("/import "+rel))
+	if !strings.Contains(got, "branch import-1") {
+		t.Fatalf("/import = %q", got)
+	}
+	if history := agent.Sessions.GetHistory(session.BranchKey(base, "import-1")); len(history) != 2 {
+		t.Errorf("imported branch has %d messages", len(history))
+	}
+	outside := helper.executeAndGetResponse(t, ctx, commandMessage("/import ../../etc/passwd"))
+	if !strings.Contains(outside, "Only files") {
+		t.Errorf("/import outside the workspace = %q", outside)
+	}
+	// A link in the workspace doesn't lead out of it
+	secret := filepath.Join(t.TempDir(), "secret.json")
+	os.WriteFile(secret, []byte(`{"messages": []}`), 0o600)
+	if err := os.Symlink(secret, filepath.Join(agent.Workspace, "link.json")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/import link.json")); !strings.Contains(got,
+		"Only files") {
+		t.Errorf("/import through a symlink = %q", got)
+	}
+}
+
+func TestCommands_Undo(t *testing.T) {
+	al := newCommandTestLoop(t, &usageProvider{})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+	agent := al.registry.GetDefaultAgent()
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/undo")); !strings.HasPrefix(got,
+		"Nothing to undo") {
+		t.Errorf("/undo with no changes = %q", got)
+	}
+
+	// A turn starts a batch; the write below stands in for the model's tool call
+	helper.executeAndGetResponse(t, ctx, commandMessage("write a plan"))
+	write, _ := agent.Tools.Get("write_file")
+	turn := audit.WithActor(ctx, audit.Actor{Agent: agent.ID, Session: "agent:main:commands"})
+	write.Execute(turn, map[string]any{"path": "plan.md", "content": "day 1"})
+
+	got := helper.executeAndGetResponse(t, ctx, commandMessage("/undo"))
+	if got != "Reverted:\n- plan.md" {
+		t.Errorf("/undo = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(agent.Workspace, "plan.md")); !os.IsNotExist(err) {
+		t.Errorf("plan.md still exists after /undo: %v", err)
+	}
+}
+
+// blockingTool runs until its context is cancelled.
+type blockingTool struct{ started chan struct{} }
+
+func (t *blockingTool) Name() string               { return "slow_tool" }
+func (t *blockingTool) Description() string        { return "Runs until cancelled" }
+func (t *blockingTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *blockingTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	close(t.started)
+	<-ctx.Done()
+	return tools.ErrorResult(ctx.Err().Error())
+}
+
+// slowTurnProvider calls approval_tool, slow_tool and approval_tool again.
+type slowTurnProvider struct{}
+
+func (p *slowTurnProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	defs []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{
+		{ID: "call_1", Name: "approval_tool", Arguments: map[string]any{"path": "notes.md"}},
+		{ID: "call_2", Name: "slow_tool", Arguments: map[string]any{}},
+		{ID: "call_3", Name: "approval_tool", Arguments: map[string]any{}},
+	}}, nil
+}
+
+func (p *slowTurnProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestCommands_CancelReportsPartialWork(t *testing.T) {
+	al := newCommandTestLoop(t, &slowTurnProvider{})
+	quick := &approvalTool{}
+	slow := &blockingTool{started: make(chan struct{})}
+	al.RegisterTool(quick)
+	al.RegisterTool(slow)
+
+	done := make(chan string, 1)
+	go func() {
+		response, _ := al.processMessage(context.Background(), commandMessage("do the work"))
+		done <- response
+	}()
+	<-slow.started
+
+	got, _ := al.processMessage(context.Background(), commandMessage("/cancel"))
+	if got != "Cancelled while running slow_tool. Done before that:\n- approval_tool (notes.md)" {
+		t.Errorf("/cancel = %q", got)
+	}
+	select {
+	case response := <-done:
+		if response != "" {
+			t.Errorf("cancelled run replied %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("run did not stop after /cancel")
+	}
+
+	// The call after the cancelled one doesn't run, and the session records
+	// that the turn was cut short
+	if runs := quick.runs.Load(); runs != 1 {
+		t.Errorf("approval_tool ran %d times", runs)
+	}
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:commands")
+	if last := history[len(history)-1]; last.Content != cancelledNote {
+		t.Errorf("last message = %+v", last)
+	}
+}
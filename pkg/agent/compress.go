@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// compressPercent is how full the context window may get before the
+	// oldest turns are summarized ahead of the next model call.
+	compressPercent = 85
+	// keepRecentMessages stay verbatim when older turns are summarized.
+	keepRecentMessages = 6
+	// compressMessageChars caps each message sent to be summarized, so tool
+	// output doesn't crowd out the conversation.
+	compressMessageChars = 2000
+	compressTimeout      = 120 * time.Second
+)
+
+// overContextBudget reports whether messages come close enough to the
+// agent's context window that older turns should be compressed first.
+func (al *AgentLoop) overContextBudget(agent *AgentInstance, messages []providers.Message) bool {
+	return agent.ContextWindow > 0 && al.estimateTokens(messages) > agent.ContextWindow*compressPercent/100
+}
+
+// compressHistory folds the oldest turns of a session into its summary,
+// which the system prompt carries as a synopsis, and keeps the recent turns
+// verbatim. It returns false when there was nothing to compress, another
+// summarization is running, or summarizing failed.
+func (al *AgentLoop) compressHistory(ctx context.Context, agent *AgentInstance, sessionKey string) bool {
+	summarizeKey := agent.ID + ":" + sessionKey
+	if _, running := al.summarizing.LoadOrStore(summarizeKey, true); running {
+		return false
+	}
+	defer al.summarizing.Delete(summarizeKey)
+
+	history := agent.Sessions.GetHistory(sessionKey)
+	cut := compressionCut(history, keepRecentMessages)
+	if cut == 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, compressTimeout)
+	defer cancel()
+
+	summary := agent.Sessions.GetSummary(sessionKey)
+	// Summarize in chunks that fit half the window, carrying the summary
+	// forward, so even a history far over the limit can be folded in.
+	budget := max(agent.ContextWindow/2, 1)
+	var chunk []providers.Message
+	flush := func() bool {
+		if len(chunk) == 0 {
+			return true
+		}
+		next, err := al.summarizeBatch(ctx, agent, chunk, summary)
+		if err != nil || next == "" {
+			logger.WarnCF("agent", "Could not compress history", map[string]any{
+				"session_key": sessionKey,
+				"error":       fmt.Sprint(err),
+			})
+			return false
+		}
+		summary, chunk = next, nil
+		return true
+	}
+	for _, m := range history[:cut] {
+		m.Content = truncateMiddle(m.Content, compressMessageChars)
+		if len(chunk) > 0 && al.estimateTokens(append(chunk, m)) > budget {
+			if !flush() {
+				return false
+			}
+		}
+		chunk = append(chunk, m)
+	}
+	if !flush() {
+		return false
+	}
+
+	agent.Sessions.SetSummary(sessionKey, summary)
+	agent.Sessions.SetHistory(sessionKey, history[cut:])
+	agent.Sessions.Save(sessionKey)
+
+	logger.InfoCF("agent", "Compressed history", map[string]any{
+		"session_key":    sessionKey,
+		"summarized":     cut,
+		"kept":           len(history) - cut,
+		"summary_length": len(summary),
+	})
+	return true
+}
+
+// compressionCut returns how many of the oldest messages to summarize so at
+// least keep remain. The kept part starts at a user message, so no tool
+// result is separated from the call that asked for it. It returns 0 when no
+// such point exists.
+func compressionCut(history []providers.Message, keep int) int {
+	for cut := len(history) - keep; cut > 0; cut-- {
+		if history[cut].Role == "user" {
+			return cut
+		}
+	}
+	return 0
+}
+
+// rebuildMessages builds the request again from the session, after its
+// history was compressed.
+func (al *AgentLoop) rebuildMessages(agent *AgentInstance, opts processOptions) []providers.Message {
+	messages := agent.ContextBuilder.BuildMessages(
+		agent.Sessions.GetHistory(opts.SessionKey),
+		agent.Sessions.GetSummary(opts.SessionKey),
+		"", nil, opts.Channel, opts.ChatID,
+	)
+	messages[0].Content += opts.Persona.section() + opts.Memories
+	return messages
+}
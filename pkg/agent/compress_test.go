@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestCompressionCut(t *testing.T) {
+	history := []providers.Message{
+		{Role: "user"}, {Role: "assistant"},
+		{Role: "user"}, {Role: "assistant"}, {Role: "tool"}, {Role: "assistant"},
+		{Role: "user"}, {Role: "assistant"},
+	}
+	// Keeping 4 would start at a tool result, so the whole turn is kept
+	if got := compressionCut(history, 4); got != 2 {
+		t.Errorf("compressionCut(4) = %d, want 2", got)
+	}
+	if got := compressionCut(history, 2); got != 6 {
+		t.Errorf("compressionCut(2) = %d, want 6", got)
+	}
+	if got := compressionCut(history, 8); got != 0 {
+		t.Errorf("compressionCut(8) = %d, want 0", got)
+	}
+}
+
+func TestCompressHistory(t *testing.T) {
+	provider := &summaryProvider{summary: "They planned a trip to Oslo."}
+	al := newCommandTestLoop(t, provider)
+	agent := al.registry.GetDefaultAgent()
+	agent.ContextWindow = 1000
+	key := "s"
+	for i := range 10 {
+		agent.Sessions.AddMessage(key, "user", strings.Repeat("question ", 50)+string(rune('a'+i)))
+		agent.Sessions.AddMessage(key, "assistant", strings.Repeat("answer ", 50))
+	}
+
+	messages := al.rebuildMessages(agent, processOptions{SessionKey: key})
+	if !al.overContextBudget(agent, messages) {
+		t.Fatal("test history should be over budget")
+	}
+	if !al.compressHistory(t.Context(), agent, key) {
+		t.Fatal("compressHistory() = false")
+	}
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) != keepRecentMessages || history[0].Role != "user" || !strings.HasSuffix(history[0].Content, "h") {
+		t.Errorf("kept %d messages starting with %q", len(history), history[0].Content)
+	}
+	if agent.Sessions.GetSummary(key) != provider.summary {
+		t.Errorf("summary = %q", agent.Sessions.GetSummary(key))
+	}
+	messages = al.rebuildMessages(agent, processOptions{SessionKey: key})
+	if !strings.Contains(messages[0].Content, "They planned a trip to Oslo.") {
+		t.Error("synopsis missing from the system prompt")
+	}
+
+	// A failed summary leaves the history alone
+	provider.summary = ""
+	agent.Sessions.AddMessage(key, "user", "more")
+	agent.Sessions.AddMessage(key, "assistant", "sure")
+	if al.compressHistory(t.Context(), agent, key) {
+		t.Error("compressHistory() = true with a failing summarizer")
+	}
+	if got := len(agent.Sessions.GetHistory(key)); got != keepRecentMessages+2 {
+		t.Errorf("history has %d messages after a failed compression", got)
+	}
+}
@@ -19,6 +19,7 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 	tools        *tools.ToolRegistry // Direct reference to tool registry
+	location     *time.Location      // Time zone of the user; nil for the server's
 }
 
 func getGlobalConfigDir() string {
@@ -43,26 +44,32 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 	}
 }
 
+// SkillsLoader returns the loader for the skills available to the agent.
+func (cb *ContextBuilder) SkillsLoader() *skills.SkillsLoader {
+	return cb.skillsLoader
+}
+
 // SetToolsRegistry sets the tools registry for dynamic tool summary generation.
 func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
-func (cb *ContextBuilder) getIdentity() string {
-	now := time.Now().Format("2006-01-02 15:04 (Monday)")
+// SetLocation sets the time zone the system prompt tells the time in.
+func (cb *ContextBuilder) SetLocation(loc *time.Location) {
+	cb.location = loc
+}
+
+func (cb *ContextBuilder) getIdentity(channel, chatID string) string {
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 	runtime := fmt.Sprintf("%s %s, Go %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
 	// Build tools section dynamically
-	toolsSection := cb.buildToolsSection()
+	toolsSection := cb.buildToolsSection(channel, chatID)
 
 	return fmt.Sprintf(`# picoclaw 🦞
 
 You are picoclaw, a helpful AI assistant.
 
-## Current Time
-%s
-
 ## Runtime
 %s
 
@@ -81,15 +88,16 @@ Your workspace is at: %s
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
 3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
+		runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
 }
 
-func (cb *ContextBuilder) buildToolsSection() string {
+// buildToolsSection lists the tools the conversation may use.
+func (cb *ContextBuilder) buildToolsSection(channel, chatID string) string {
 	if cb.tools == nil {
 		return ""
 	}
 
-	summaries := cb.tools.GetSummaries()
+	summaries := cb.tools.GetSummariesFor(channel, chatID)
 	if len(summaries) == 0 {
 		return ""
 	}
@@ -109,10 +117,18 @@ func (cb *ContextBuilder) buildToolsSection() string {
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
+	return cb.buildSystemPrompt("", "")
+}
+
+func (cb *ContextBuilder) buildSystemPrompt(channel, chatID string) string {
 	parts := []string{}
 
 	// Core identity section
-	parts = append(parts, cb.getIdentity())
+	parts = append(parts, cb.getIdentity(channel, chatID))
+
+	// Date and time, workspace files, pending reminders and the user's
+	// profile, fresh on every turn
+	parts = append(parts, cb.buildTurnContext(channel, chatID))
 
 	// Bootstrap files
 	bootstrapContent := cb.LoadBootstrapFiles()
@@ -120,14 +136,15 @@ func (cb *ContextBuilder) BuildSystemPrompt() string {
 		parts = append(parts, bootstrapContent)
 	}
 
-	// Skills - show summary, AI can read full content with read_file tool
-	skillsSummary := cb.skillsLoader.BuildSkillsSummary()
-	if skillsSummary != "" {
-		parts = append(parts, fmt.Sprintf(`# Skills
-
-The following skills extend your capabilities. To use a skill, read its SKILL.md file using the read_file tool.
-
-%s`, skillsSummary))
+	// Skills - only their names, the model pulls in the ones it needs with
+	// list_skills and load_skill
+	skillsIndex := cb.skillsLoader.BuildSkillsIndex()
+	if skillsIndex != "" {
+		parts = append(parts, fmt.Sprintf("# Skills\n\n"+
+			"Skills extend your capabilities with instructions for specific tasks. Installed skills: %s\n\n"+
+			"Before a task one of them may help with, call list_skills to see what they do, "+
+			"and load_skill to read the instructions of the relevant ones. Only load skills you need.",
+			skillsIndex))
 	}
 
 	// Memory context
@@ -144,7 +161,6 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 	bootstrapFiles := []string{
 		"AGENTS.md",
 		"SOUL.md",
-		"USER.md",
 		"IDENTITY.md",
 	}
 
@@ -163,12 +179,12 @@ func (cb *ContextBuilder) BuildMessages(
 	history []providers.Message,
 	summary string,
 	currentMessage string,
-	media []string,
+	images []string,
 	channel, chatID string,
 ) []providers.Message {
 	messages := []providers.Message{}
 
-	systemPrompt := cb.BuildSystemPrompt()
+	systemPrompt := cb.buildSystemPrompt(channel, chatID)
 
 	// Add Current Session info if provided
 	if channel != "" && chatID != "" {
@@ -206,10 +222,11 @@ func (cb *ContextBuilder) BuildMessages(
 
 	messages = append(messages, history...)
 
-	if strings.TrimSpace(currentMessage) != "" {
+	if strings.TrimSpace(currentMessage) != "" || len(images) > 0 {
 		messages = append(messages, providers.Message{
 			Role:    "user",
 			Content: currentMessage,
+			Images:  images,
 		})
 	}
 
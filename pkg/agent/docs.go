@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/docindex"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+// docsEmbedder embeds document chunks with tools.docs.embedding_model,
+// creating the provider on first use.
+type docsEmbedder struct {
+	cfg  *config.Config
+	name string
+
+	once     sync.Once
+	embedder providers.EmbeddingProvider
+	model    string
+	err      error
+}
+
+func (e *docsEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.once.Do(func() {
+		modelCfg, err := e.cfg.GetModelConfig(e.name)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.embedder, e.model, e.err = providers.CreateEmbedderFromConfig(modelCfg)
+	})
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.embedder.Embed(ctx, texts, e.model)
+}
+
+// registerDocsTool gives the agent a document index of its workspace and the
+// search_docs tool over it.
+func registerDocsTool(cfg *config.Config, agent *AgentInstance, embed docindex.EmbedFunc) {
+	docsCfg := cfg.Tools.Docs
+	store, err := vectorstore.Open(filepath.Join(agent.Workspace, "state", "docs.jsonl"))
+	if err != nil {
+		logger.WarnCF("agent", "Could not open document index, search_docs disabled", map[string]any{
+			"agent_id": agent.ID,
+			"error":    err.Error(),
+		})
+		return
+	}
+	agent.Docs = docindex.New(agent.Workspace, store, embed, docindex.Options{
+		Paths:        docsCfg.Paths,
+		Extensions:   docsCfg.Extensions,
+		ChunkChars:   docsCfg.ChunkChars,
+		MaxFileBytes: int64(docsCfg.MaxFileKB) * 1024,
+	})
+	agent.Tools.Register(tools.NewSearchDocsTool(agent.Docs, docsCfg.TopK, docsCfg.MinScore))
+}
+
+// indexDocs keeps the document index of every agent up to date, at start
+// and then every tools.docs.reindex_minutes.
+func (al *AgentLoop) indexDocs(ctx context.Context) {
+	interval := time.Duration(al.cfg.Tools.Docs.ReindexMinutes) * time.Minute
+	if !al.cfg.Tools.Docs.Enabled || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, agentID := range al.registry.ListAgentIDs() {
+			agent, ok := al.registry.GetAgent(agentID)
+			if !ok || agent.Docs == nil {
+				continue
+			}
+			stats, err := agent.Docs.Update(ctx)
+			if err != nil {
+				logger.WarnCF("agent", "Could not index all documents", map[string]any{
+					"agent_id": agentID,
+					"error":    err.Error(),
+				})
+			}
+			if stats.Indexed > 0 || stats.Removed > 0 {
+				logger.InfoCF("agent", "Updated document index", map[string]any{
+					"agent_id": agentID,
+					"indexed":  stats.Indexed,
+					"removed":  stats.Removed,
+					"chunks":   stats.Chunks,
+				})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"context"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// dryRunEnabled reports whether the session is in dry-run mode: its own
+// /dryrun setting, or tools.dry_run.enabled when it has none.
+func (al *AgentLoop) dryRunEnabled(agent *AgentInstance, sessionKey string) bool {
+	if on, set := agent.Sessions.DryRun(sessionKey); set {
+		return on
+	}
+	return al.cfg.Tools.DryRun.Enabled
+}
+
+// dryRunCall describes the call instead of making it when the session is in
+// dry-run mode and the tool changes things. It returns nil when the call
+// should run.
+func (al *AgentLoop) dryRunCall(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	name string,
+	args map[string]any,
+) *tools.ToolResult {
+	if !al.dryRunEnabled(agent, opts.SessionKey) || !agent.Tools.Allowed(name, opts.Channel, opts.ChatID) {
+		return nil
+	}
+	tool, ok := agent.Tools.Get(name)
+	if !ok || (tools.ToolRisk(tool) < tools.RiskHigh && !slices.Contains(al.cfg.Tools.DryRun.Tools, name)) {
+		return nil
+	}
+	return tools.DryRun(ctx, tool, args)
+}
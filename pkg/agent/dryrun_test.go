@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestDryRun(t *testing.T) {
+	al := newCommandTestLoop(t, &toolCallProvider{})
+	// A dry run needs no approval, so this must not wait for one
+	al.cfg.Tools.Approval.Tools = []string{"approval_tool"}
+	tool := &approvalTool{}
+	al.RegisterTool(tool)
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/dryrun on")); !strings.HasPrefix(got, "Dry-run") {
+		t.Fatalf("/dryrun on = %q", got)
+	}
+	got := helper.executeAndGetResponse(t, ctx, commandMessage("run the tool"))
+	if tool.runs.Load() != 0 || !strings.Contains(got, "[dry run, nothing was done] approval_tool would be called") {
+		t.Errorf("dry run: %d runs, reply %q", tool.runs.Load(), got)
+	}
+
+	// The setting is saved with the session
+	agent := al.registry.GetDefaultAgent()
+	reloaded := session.NewSessionManager(filepath.Join(agent.Workspace, "sessions"))
+	if on, set := reloaded.DryRun("agent:main:commands"); !on || !set {
+		t.Errorf("saved dry-run setting = %v, %v", on, set)
+	}
+
+	// A session's own setting wins over the config
+	al.cfg.Tools.DryRun.Enabled = true
+	helper.executeAndGetResponse(t, ctx, commandMessage("/dryrun off"))
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/dryrun")); !strings.Contains(got, "is off") {
+		t.Errorf("/dryrun = %q", got)
+	}
+	other := commandMessage("/dryrun")
+	other.SessionKey = "agent:main:other"
+	if got := helper.executeAndGetResponse(t, ctx, other); !strings.HasPrefix(got, "Dry-run mode is on") {
+		t.Errorf("/dryrun in a session without a setting = %q", got)
+	}
+}
+
+func TestDryRun_Webhook(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// toolCallProvider calls approval_tool, here a webhook that posts
+	al := newCommandTestLoop(t, &toolCallProvider{})
+	webhook, err := tools.NewWebhookTool("approval_tool", config.WebhookToolConfig{
+		Description: "Turns on the lights",
+		URL:         server.URL + "/lights",
+		Body:        `{"on": true}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.RegisterTool(webhook)
+	al.cfg.Tools.DryRun.Enabled = true
+	helper := testHelper{al: al}
+
+	got := helper.executeAndGetResponse(t, context.Background(), commandMessage("turn on the lights"))
+	if requests.Load() != 0 || !strings.Contains(got, "[dry run, nothing was done]") {
+		t.Errorf("dry run: %d requests, reply %q", requests.Load(), got)
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// recordFeedback stores a reaction to one of the agent's replies in the
+// session the reply belongs to. Feedback is not answered.
+func (al *AgentLoop) recordFeedback(msg bus.InboundMessage) {
+	rating := msg.Metadata["feedback"]
+	if rating != bus.FeedbackUp && rating != bus.FeedbackDown {
+		return
+	}
+	agent, sessionKey := al.routeMessage(msg)
+
+	reply := msg.Metadata["feedback_reply"]
+	ex := ratedExchange(agent.Sessions.GetHistory(sessionKey), reply)
+	if ex.reply != "" {
+		reply = ex.reply
+	}
+
+	al.saveFeedback(agent, sessionKey, session.Feedback{
+		Rating:    rating,
+		SenderID:  msg.SenderID,
+		MessageID: msg.Metadata["feedback_message_id"],
+		Turn:      ex.turn,
+		Reply:     reply,
+		Prompt:    ex.prompt,
+		Tools:     ex.tools,
+		Time:      time.Now(),
+	})
+}
+
+// saveFeedback adds fb to the session and writes the session to disk.
+func (al *AgentLoop) saveFeedback(agent *AgentInstance, sessionKey string, fb session.Feedback) error {
+	agent.Sessions.AddFeedback(sessionKey, fb)
+	if err := agent.Sessions.Save(sessionKey); err != nil {
+		logger.WarnCF("agent", "Failed to save feedback", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+		return err
+	}
+	logger.InfoCF("agent", "Recorded feedback", map[string]any{
+		"session_key": sessionKey,
+		"rating":      fb.Rating,
+		"sender_id":   fb.SenderID,
+		"turn":        fb.Turn,
+	})
+	return nil
+}
+
+// exchange is a rated reply with the user message it answered.
+type exchange struct {
+	prompt, reply string
+	turn          int      // position of the reply in the history, from 1
+	tools         []string // tools called between the prompt and the reply
+}
+
+// ratedExchange finds the rated reply in history, and the user message it
+// answered. reply may be just the part of a split reply the user reacted
+// to; when it is empty, the latest reply is taken.
+func ratedExchange(history []providers.Message, reply string) exchange {
+	at := -1
+	for i := len(history) - 1; i >= 0; i-- {
+		m := history[i]
+		if m.Role != "assistant" || m.Content == "" {
+			continue
+		}
+		if reply == "" || strings.Contains(m.Content, strings.TrimSpace(reply)) {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return exchange{}
+	}
+
+	ex := exchange{reply: history[at].Content, turn: at + 1}
+	seen := make(map[string]bool)
+	for i := at - 1; i >= 0; i-- {
+		m := history[i]
+		if m.Role == "user" {
+			ex.prompt = m.Content
+			break
+		}
+		for _, tc := range m.ToolCalls {
+			name := tc.Name
+			if name == "" && tc.Function != nil {
+				name = tc.Function.Name
+			}
+			if name != "" && !seen[name] {
+				seen[name] = true
+				ex.tools = append(ex.tools, name)
+			}
+		}
+	}
+	slices.Reverse(ex.tools)
+	return ex
+}
+
+// rateCommand handles /good and /bad: the sender rates the latest reply,
+// optionally with a comment on what was good or wrong about it.
+func (al *AgentLoop) rateCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	ex := ratedExchange(agent.Sessions.GetHistory(req.SessionKey), "")
+	if ex.reply == "" {
+		return "There is no reply to rate yet."
+	}
+
+	rating := bus.FeedbackUp
+	if req.Name == "bad" {
+		rating = bus.FeedbackDown
+	}
+	err := al.saveFeedback(agent, req.SessionKey, session.Feedback{
+		Rating:   rating,
+		SenderID: req.Message.SenderID,
+		Turn:     ex.turn,
+		Reply:    ex.reply,
+		Prompt:   ex.prompt,
+		Tools:    ex.tools,
+		Comment:  commandText(req.Message.Content),
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return fmt.Sprintf("Could not save your feedback: %v", err)
+	}
+	if rating == bus.FeedbackDown {
+		return "Thanks, noted that the last reply wasn't good. A comment after /bad says what went wrong."
+	}
+	return "Thanks, noted that the last reply was good."
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestRecordFeedback(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "Paris is the capital."})
+
+	metadata := map[string]string{"peer_kind": "direct", "peer_id": "7"}
+	msg := bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "7",
+		ChatID:   "7",
+		Content:  "What is the capital of France?",
+		Metadata: metadata,
+	}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage() error = %v", err)
+	}
+
+	feedback := msg
+	feedback.Content = "[feedback: down]"
+	feedback.Metadata = map[string]string{
+		"peer_kind":           "direct",
+		"peer_id":             "7",
+		"feedback":            bus.FeedbackDown,
+		"feedback_message_id": "100",
+		"feedback_reply":      "Paris",
+	}
+	al.recordFeedback(feedback)
+
+	// Changing the reaction replaces the rating
+	feedback.Metadata["feedback"] = bus.FeedbackUp
+	al.recordFeedback(feedback)
+
+	agent, sessionKey := al.routeMessage(msg)
+	got := agent.Sessions.GetFeedback(sessionKey)
+	if len(got) != 1 {
+		t.Fatalf("feedback = %+v, want one entry", got)
+	}
+	fb := got[0]
+	if fb.Rating != bus.FeedbackUp || fb.SenderID != "7" || fb.MessageID != "100" {
+		t.Errorf("feedback = %+v", fb)
+	}
+	if fb.Prompt != "What is the capital of France?" || fb.Reply != "Paris is the capital." {
+		t.Errorf("prompt/reply = %q/%q", fb.Prompt, fb.Reply)
+	}
+}
+
+func TestRatedExchange(t *testing.T) {
+	history := []providers.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer, in two parts"},
+		{Role: "user", Content: "second question"},
+		{Role: "assistant", Content: "", ToolCalls: []providers.ToolCall{{ID: "1", Name: "web_search"}}},
+		{Role: "tool", Content: "tool output"},
+		{Role: "assistant", Content: "", ToolCalls: []providers.ToolCall{
+			{ID: "2", Function: &providers.FunctionCall{Name: "fetch_url"}},
+			{ID: "3", Name: "web_search"},
+		}},
+		{Role: "tool", Content: "page"},
+		{Role: "tool", Content: "more results"},
+		{Role: "assistant", Content: "second answer"},
+	}
+	tests := []struct {
+		reply, prompt, full string
+		turn                int
+		tools               string
+	}{
+		{"", "second question", "second answer", 9, "web_search,fetch_url"},
+		{"in two parts\n", "first question", "first answer, in two parts", 2, ""},
+		{"never said", "", "", 0, ""},
+	}
+	for _, tt := range tests {
+		ex := ratedExchange(history, tt.reply)
+		if ex.prompt != tt.prompt || ex.reply != tt.full || ex.turn != tt.turn {
+			t.Errorf("ratedExchange(%q) = %q, %q, turn %d, want %q, %q, turn %d",
+				tt.reply, ex.prompt, ex.reply, ex.turn, tt.prompt, tt.full, tt.turn)
+		}
+		if tools := strings.Join(ex.tools, ","); tools != tt.tools {
+			t.Errorf("ratedExchange(%q) tools = %q, want %q", tt.reply, tools, tt.tools)
+		}
+	}
+}
+
+func TestRateCommand(t *testing.T) {
+	al := newCommandTestLoop(t, &simpleMockProvider{response: "It is 25 degrees."})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/bad")); !strings.Contains(got, "no reply") {
+		t.Errorf("/bad before any reply = %q", got)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("How warm is it?"))
+	helper.executeAndGetResponse(t, ctx, commandMessage("/bad wrong unit,\nI asked for Fahrenheit"))
+	// A reaction to the same reply keeps the comment
+	al.recordFeedback(bus.InboundMessage{
+		Channel:    "test",
+		SenderID:   "user1",
+		ChatID:     "chat1",
+		SessionKey: "agent:main:commands",
+		Metadata:   map[string]string{"feedback": bus.FeedbackDown, "feedback_message_id": "42"},
+	})
+
+	agent := al.registry.GetDefaultAgent()
+	got := agent.Sessions.GetFeedback("agent:main:commands")
+	if len(got) != 1 {
+		t.Fatalf("feedback = %+v, want one entry", got)
+	}
+	fb := got[0]
+	if fb.Rating != bus.FeedbackDown || fb.Turn != 2 || fb.MessageID != "42" {
+		t.Errorf("feedback = %+v", fb)
+	}
+	if fb.Comment != "wrong unit,\nI asked for Fahrenheit" {
+		t.Errorf("comment = %q", fb.Comment)
+	}
+	if fb.Prompt != "How warm is it?" || fb.Reply != "It is 25 degrees." {
+		t.Errorf("prompt/reply = %q/%q", fb.Prompt, fb.Reply)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/docindex"
+	"github.com/sipeed/picoclaw/pkg/notes"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/userprofile"
+	"github.com/sipeed/picoclaw/pkg/usertime"
 )
 
 // AgentInstance represents a fully configured agent with its own workspace,
@@ -18,9 +22,11 @@ type AgentInstance struct {
 	ID             string
 	Name           string
 	Model          string
+	Vision         bool // Model accepts images, see config.ModelConfig.Vision
 	Fallbacks      []string
 	Workspace      string
 	MaxIterations  int
+	MaxRepeats     int // Identical tool calls allowed per turn
 	MaxTokens      int
 	Temperature    float64
 	ContextWindow  int
@@ -31,6 +37,10 @@ type AgentInstance struct {
 	Subagents      *config.SubagentsConfig
 	SkillsFilter   []string
 	Candidates     []providers.FallbackCandidate
+	Docs           *docindex.Indexer  // nil unless tools.docs is enabled
+	Notes          *notes.Store       // nil unless tools.notes is enabled
+	UserProfiles   *userprofile.Store // nil unless tools.user_profiles is enabled
+	FileHistory    *tools.FileHistory // What the file tools changed, for /undo
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -48,18 +58,37 @@ func NewAgentInstance(
 
 	restrict := defaults.RestrictToWorkspace
 	toolsRegistry := tools.NewToolRegistry()
-	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
+	allowed := defaults.AllowedPaths
+	fileHistory := tools.NewFileHistory()
+	writeFile := tools.NewWriteFileTool(workspace, restrict, allowed...)
+	editFile := tools.NewEditFileTool(workspace, restrict, allowed...)
+	appendFile := tools.NewAppendFileTool(workspace, restrict, allowed...)
+	writeFile.SetHistory(fileHistory)
+	editFile.SetHistory(fileHistory)
+	appendFile.SetHistory(fileHistory)
+	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict, allowed...))
+	toolsRegistry.Register(tools.NewReadPDFTool(workspace, restrict, allowed...))
+	toolsRegistry.Register(writeFile)
+	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict, allowed...))
+	toolsRegistry.Register(tools.NewGlobTool(workspace, restrict, allowed...))
 	toolsRegistry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
-	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
+	toolsRegistry.Register(editFile)
+	toolsRegistry.Register(appendFile)
+	if cfg.Tools.Data.Enabled {
+		toolsRegistry.Register(tools.NewDataTool(workspace, restrict, cfg.Tools.Data, allowed...))
+	}
 
 	sessionsDir := filepath.Join(workspace, "sessions")
 	sessionsManager := session.NewSessionManager(sessionsDir)
 
 	contextBuilder := NewContextBuilder(workspace)
 	contextBuilder.SetToolsRegistry(toolsRegistry)
+	if defaults.Timezone != "" {
+		// Validated when the config was loaded
+		if loc, err := usertime.Load(defaults.Timezone); err == nil {
+			contextBuilder.SetLocation(loc)
+		}
+	}
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
@@ -72,17 +101,29 @@ func NewAgentInstance(
 		subagents = agentCfg.Subagents
 		skillsFilter = agentCfg.Skills
 	}
+	contextBuilder.SkillsLoader().Allow(skillsFilter)
 
 	maxIter := defaults.MaxToolIterations
 	if maxIter == 0 {
 		maxIter = 20
 	}
 
+	maxRepeated := defaults.MaxRepeatedToolCalls
+	if maxRepeated <= 0 {
+		maxRepeated = 3
+	}
+
 	maxTokens := defaults.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 8192
 	}
 
+	// The model's context limit in tokens; older configs only set max_tokens
+	contextWindow := defaults.ContextWindow
+	if contextWindow <= 0 {
+		contextWindow = maxTokens
+	}
+
 	temperature := 0.7
 	if defaults.Temperature != nil {
 		temperature = *defaults.Temperature
@@ -99,12 +140,14 @@ func NewAgentInstance(
 		ID:             agentID,
 		Name:           agentName,
 		Model:          model,
+		Vision:         cfg != nil && cfg.IsVisionModel(model),
 		Fallbacks:      fallbacks,
 		Workspace:      workspace,
 		MaxIterations:  maxIter,
+		MaxRepeats:     maxRepeated,
 		MaxTokens:      maxTokens,
 		Temperature:    temperature,
-		ContextWindow:  maxTokens,
+		ContextWindow:  contextWindow,
 		Provider:       provider,
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
@@ -112,6 +155,7 @@ func NewAgentInstance(
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
+		FileHistory:    fileHistory,
 	}
 }
 
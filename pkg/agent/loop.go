@@ -10,22 +10,30 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/commands"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/docindex"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/imagegen"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usertime"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -38,18 +46,40 @@ type AgentLoop struct {
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
+	commands       *commands.Registry
+	activeRuns     sync.Map // session key -> *activeRun
+	usage          *usageTracker
+	approvals      *approvals
+	memory         *longTermMemory // nil unless memory is enabled
+	reviewer       *reviewer       // nil unless agents.review is enabled
+	tasks          *taskQueue      // nil unless tools.tasks is enabled
+
+	resultSummarizer    resultSummarizer
+	injectionClassifier injectionClassifier
+	personaModels       sync.Map // model_list name -> *personaModel
 }
 
+// inboundQueueSize bounds how many messages wait while the agent is busy.
+const inboundQueueSize = 100
+
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string      // Session identifier for history/context
+	SenderID        string      // Who sent the message, for the audit log
+	Channel         string      // Target channel for tool execution
+	ChatID          string      // Target chat ID for tool execution
+	UserMessage     string      // User message content (may include prefix)
+	Images          []string    // Data URLs of images sent with the user message
+	DefaultResponse string      // Response when LLM returns empty
+	EnableSummary   bool        // Whether to trigger summarization
+	SendResponse    bool        // Whether to send response via bus
+	SendProgress    bool        // Whether to publish interim progress (partial output, tool activity) via bus
+	NoHistory       bool        // If true, don't load session history (for heartbeat)
+	Memories        string      // Recalled memories and the user's profile, appended to the system prompt
+	UserID          string      // Whose profile the turn shows and updates; "" for none
+	Run             *activeRun  // Tracks the tools of a run /cancel can stop; nil for others
+	Budget          *turnBudget // What the turn may still spend on model calls; nil when uncapped
+	Persona         *persona    // The persona the conversation switched to; nil for the agent's own
 }
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
@@ -69,14 +99,31 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		commands:    commands.NewRegistry(),
+		usage:       newUsageTracker(),
+		approvals:   newApprovals(),
+	}
+	if cfg.Memory.Enabled {
+		al.memory = newLongTermMemory()
+		al.recallNotes()
+		al.registerRecallTool()
+	}
+	if cfg.Agents.Review.Enabled {
+		al.reviewer = &reviewer{}
+	}
+	if cfg.Tools.Tasks.Enabled {
+		al.tasks = newTaskQueue(al)
+		al.registerTaskTool()
 	}
+	al.registerCommands()
+	return al
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
@@ -86,6 +133,36 @@ func registerSharedTools(
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
 ) {
+	var docsEmbed docindex.EmbedFunc
+	if cfg.Tools.Docs.Enabled {
+		name := cfg.Tools.Docs.EmbeddingModel
+		if name == "" {
+			name = cfg.Memory.EmbeddingModel
+		}
+		docsEmbed = (&docsEmbedder{cfg: cfg, name: name}).embed
+	}
+
+	var imageGenerator imagegen.Generator
+	if cfg.Tools.Image.Enabled {
+		var err error
+		if imageGenerator, err = imagegen.NewGenerator(cfg); err != nil {
+			logger.WarnCF("agent", "Image generation disabled", map[string]any{"error": err.Error()})
+		}
+	}
+
+	// One cache for all agents, so they share searches and fetched pages
+	resultCache := tools.NewResultCache(cfg.Tools.Cache)
+
+	var webhookTools []tools.Tool
+	for _, name := range slices.Sorted(maps.Keys(cfg.Tools.Webhooks)) {
+		tool, err := tools.NewWebhookTool(name, cfg.Tools.Webhooks[name])
+		if err != nil {
+			logger.WarnCF("agent", "Webhook tool disabled", map[string]any{"tool": name, "error": err.Error()})
+			continue
+		}
+		webhookTools = append(webhookTools, tool)
+	}
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -102,27 +179,72 @@ func registerSharedTools(
 			PerplexityAPIKey:     cfg.Tools.Web.Perplexity.APIKey,
 			PerplexityMaxResults: cfg.Tools.Web.Perplexity.MaxResults,
 			PerplexityEnabled:    cfg.Tools.Web.Perplexity.Enabled,
+			SearxNGBaseURL:       cfg.Tools.Web.SearxNG.BaseURL,
+			SearxNGMaxResults:    cfg.Tools.Web.SearxNG.MaxResults,
+			SearxNGEnabled:       cfg.Tools.Web.SearxNG.Enabled,
+			TavilyAPIKey:         cfg.Tools.Web.Tavily.APIKey,
+			TavilyMaxResults:     cfg.Tools.Web.Tavily.MaxResults,
+			TavilyEnabled:        cfg.Tools.Web.Tavily.Enabled,
+			SafeSearch:           cfg.Tools.Web.SafeSearch,
 		}); searchTool != nil {
 			agent.Tools.Register(searchTool)
 		}
-		agent.Tools.Register(tools.NewWebFetchTool(50000))
+		agent.Tools.Register(tools.NewFetchURLTool(cfg.Tools.Fetch))
+		if cfg.Tools.Weather.Enabled {
+			agent.Tools.Register(tools.NewWeatherTool(cfg.Tools.Weather))
+		}
+		if cfg.Tools.SSH.Enabled && len(cfg.Tools.SSH.Hosts) > 0 {
+			agent.Tools.Register(tools.NewSSHTool(cfg.Tools.SSH))
+		}
+		if cfg.Tools.SQL.Enabled && len(cfg.Tools.SQL.Databases) > 0 {
+			agent.Tools.Register(tools.NewSQLTool(cfg.Tools.SQL))
+		}
+		if cfg.Tools.Clipboard.Enabled {
+			agent.Tools.Register(tools.NewClipboardTool(cfg.Tools.Clipboard))
+		}
+		if cfg.Tools.Screenshot.Enabled && agent.Vision {
+			agent.Tools.Register(tools.NewScreenshotTool(cfg.Tools.Screenshot))
+		}
 
 		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
 		agent.Tools.Register(tools.NewI2CTool())
 		agent.Tools.Register(tools.NewSPITool())
 
+		if cfg.Tools.RunCode.Enabled {
+			agent.Tools.Register(tools.NewRunCodeTool(agent.Workspace, cfg.Tools.RunCode))
+		}
+		if cfg.Tools.Python.Enabled {
+			agent.Tools.Register(tools.NewPythonTool(agent.Workspace, cfg.Tools.Python))
+		}
+
 		// Message tool
-		messageTool := tools.NewMessageTool()
+		defaults := cfg.Agents.Defaults
+		messageTool := tools.NewMessageTool(agent.Workspace, defaults.RestrictToWorkspace, defaults.AllowedPaths...)
 		messageTool.SetSendCallback(func(channel, chatID, content string) error {
+			return msgBus.Notify(context.Background(), channel, chatID, content)
+		})
+		sendMedia := func(channel, chatID, content string, media []string) error {
 			msgBus.PublishOutbound(bus.OutboundMessage{
 				Channel: channel,
 				ChatID:  chatID,
 				Content: content,
+				Media:   media,
 			})
 			return nil
-		})
+		}
+		messageTool.SetMediaSendCallback(sendMedia)
 		agent.Tools.Register(messageTool)
 
+		if imageGenerator != nil {
+			imageTool := tools.NewGenerateImageTool(imageGenerator, agent.Workspace, cfg.Tools.Image)
+			imageTool.SetMediaSendCallback(sendMedia)
+			agent.Tools.Register(imageTool)
+		}
+
+		if targets := cfg.Channels.Broadcast.Targets; len(targets) > 0 {
+			agent.Tools.Register(tools.NewBroadcastTool(targets, msgBus.Broadcast))
+		}
+
 		// Skill discovery and installation tools
 		registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
 			MaxConcurrentSearches: cfg.Tools.Skills.MaxConcurrentSearches,
@@ -134,6 +256,38 @@ func registerSharedTools(
 		)
 		agent.Tools.Register(tools.NewFindSkillsTool(registryMgr, searchCache))
 		agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
+		agent.Tools.Register(tools.NewListSkillsTool(agent.ContextBuilder.SkillsLoader()))
+		agent.Tools.Register(tools.NewLoadSkillTool(agent.ContextBuilder.SkillsLoader()))
+
+		if docsEmbed != nil {
+			registerDocsTool(cfg, agent, docsEmbed)
+		}
+		if cfg.Tools.Notes.Enabled {
+			registerNotesTool(cfg, agent)
+		}
+		if cfg.Tools.Profiles.Enabled {
+			registerUserProfileTool(cfg, agent)
+		}
+		if cfg.Tools.Todo.Enabled {
+			if tasks, err := state.NewTaskList(agent.Workspace); err != nil {
+				logger.WarnCF("agent", "Could not load the todo list, todo disabled", map[string]any{
+					"agent_id": agentID,
+					"error":    err.Error(),
+				})
+			} else {
+				agent.Tools.Register(tools.NewTodoTool(tasks))
+			}
+		}
+
+		// Webhook tools from config don't replace built-in ones
+		for _, tool := range webhookTools {
+			if _, exists := agent.Tools.Get(tool.Name()); exists {
+				logger.WarnCF("agent", "Webhook tool has the name of a built-in tool, skipped",
+					map[string]any{"tool": tool.Name()})
+				continue
+			}
+			agent.Tools.Register(tool)
+		}
 
 		// Spawn tool with allowlist checker
 		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
@@ -145,6 +299,20 @@ func registerSharedTools(
 		})
 		agent.Tools.Register(spawnTool)
 
+		agent.Tools.SetAccessPolicy(tools.NewAccessPolicy(cfg.Tools.Access))
+		agent.Tools.SetRetryPolicy(tools.NewRetryPolicy(cfg.Tools.Retry))
+		agent.Tools.SetResultCache(resultCache)
+		var auditLog *audit.Log
+		if cfg.Tools.Audit.Enabled {
+			auditLog = audit.New(audit.LogPath(agent.Workspace), cfg.Tools.Audit.MaxArgChars)
+			agent.Tools.SetAuditLog(auditLog)
+		}
+
+		// Profiles take their tools from the finished registry
+		if profiles := delegateProfiles(cfg, agent, auditLog, resultCache); len(profiles) > 0 {
+			agent.Tools.Register(tools.NewDelegateTool(profiles...))
+		}
+
 		// Update context builder with the complete tools registry
 		agent.ContextBuilder.SetToolsRegistry(agent.Tools)
 	}
@@ -152,6 +320,26 @@ func registerSharedTools(
 
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
+	go al.pruneSessions(ctx)
+	go al.indexDocs(ctx)
+	go al.syncNotes(ctx)
+	if al.tasks != nil {
+		context.AfterFunc(ctx, al.tasks.stop)
+	}
+
+	// Messages are processed one at a time by a worker, so that immediate
+	// commands like /cancel can run while the agent is busy
+	queue := make(chan bus.InboundMessage, inboundQueueSize)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-queue:
+				al.handleInbound(ctx, msg)
+			}
+		}
+	}()
 
 	for al.running.Load() {
 		select {
@@ -163,32 +351,20 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			response, err := al.processMessage(ctx, msg)
-			if err != nil {
-				response = fmt.Sprintf("Error processing message: %v", err)
+			if msg.Metadata["feedback"] != "" && msg.Channel != "system" {
+				al.recordFeedback(msg)
+				continue
 			}
 
-			if response != "" {
-				// Check if the message tool already sent a response during this round.
-				// If so, skip publishing to avoid duplicate messages to the user.
-				// Use default agent's tools to check (message tool is shared).
-				alreadySent := false
-				defaultAgent := al.registry.GetDefaultAgent()
-				if defaultAgent != nil {
-					if tool, ok := defaultAgent.Tools.Get("message"); ok {
-						if mt, ok := tool.(*tools.MessageTool); ok {
-							alreadySent = mt.HasSentInRound()
-						}
-					}
-				}
+			if cmd, _, ok := al.commands.Lookup(msg.Content); ok && cmd.Immediate && msg.Channel != "system" {
+				al.runImmediateCommand(ctx, msg)
+				continue
+			}
 
-				if !alreadySent {
-					al.bus.PublishOutbound(bus.OutboundMessage{
-						Channel: msg.Channel,
-						ChatID:  msg.ChatID,
-						Content: response,
-					})
-				}
+			select {
+			case queue <- msg:
+			case <-ctx.Done():
+				return nil
 			}
 		}
 	}
@@ -196,6 +372,92 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	return nil
 }
 
+// sessionPruneInterval is how often idle sessions and old archives are
+// cleaned up.
+const sessionPruneInterval = time.Hour
+
+// pruneSessions applies session.archive_idle_days and retention_days to the
+// sessions of every agent, at start and then periodically.
+func (al *AgentLoop) pruneSessions(ctx context.Context) {
+	day := 24 * time.Hour
+	idle := time.Duration(al.cfg.Session.ArchiveIdleDays) * day
+	retention := time.Duration(al.cfg.Session.RetentionDays) * day
+	if idle <= 0 && retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(sessionPruneInterval)
+	defer ticker.Stop()
+	for {
+		for _, agentID := range al.registry.ListAgentIDs() {
+			agent, ok := al.registry.GetAgent(agentID)
+			if !ok {
+				continue
+			}
+			archived, deleted, err := agent.Sessions.Prune(idle, retention)
+			if err != nil {
+				logger.WarnCF("agent", "Failed to prune sessions", map[string]any{
+					"agent_id": agentID,
+					"error":    err.Error(),
+				})
+			}
+			if archived > 0 || deleted > 0 {
+				logger.InfoCF("agent", "Pruned sessions", map[string]any{
+					"agent_id": agentID,
+					"archived": archived,
+					"deleted":  deleted,
+				})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleInbound processes one message and publishes the reply.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	round := &tools.MessageRound{}
+	response, err := al.processMessage(tools.WithMessageRound(ctx, round), msg)
+	if err != nil {
+		response = i18n.T(al.language(msg.Channel), i18n.ProcessingError, err)
+	}
+	if response == "" {
+		return
+	}
+
+	// If the message tool already sent a response during this round, skip
+	// publishing to avoid duplicate messages to the user.
+	if !round.Sent() {
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Content: response,
+		})
+	}
+}
+
+// runImmediateCommand runs a command that must not wait for the message
+// being processed, and publishes its reply.
+func (al *AgentLoop) runImmediateCommand(ctx context.Context, msg bus.InboundMessage) {
+	agent, sessionKey := al.routeMessage(msg)
+	response, _ := al.commands.Execute(ctx, commands.Request{
+		Message:    msg,
+		AgentID:    agent.ID,
+		SessionKey: sessionKey,
+	})
+	if response != "" {
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Content: response,
+		})
+	}
+}
+
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
 }
@@ -208,6 +470,11 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	}
 }
 
+// RegisterCommand adds a slash command available from every channel.
+func (al *AgentLoop) RegisterCommand(cmd commands.Command) {
+	al.commands.Register(cmd)
+}
+
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
 }
@@ -249,6 +516,25 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	return al.processMessage(ctx, msg)
 }
 
+// ExecuteTool calls one of the default agent's tools for a scheduled job,
+// subject to the tool access rules of the chat it reports to.
+func (al *AgentLoop) ExecuteTool(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID string,
+) *tools.ToolResult {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil {
+		return tools.ErrorResult("no agent configured")
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	ctx = audit.WithActor(ctx, audit.Actor{Agent: agent.ID, Session: "cron", Sender: "cron"})
+	return agent.Tools.ExecuteWithContext(ctx, name, args, channel, chatID, nil)
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -286,12 +572,65 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return al.processSystemMessage(ctx, msg)
 	}
 
+	agent, sessionKey := al.routeMessage(msg)
+
+	var images []string
+	if agent.Vision {
+		images = msg.Images
+	}
+
 	// Check for commands
-	if response, handled := al.handleCommand(ctx, msg); handled {
+	if response, handled := al.commands.Execute(ctx, commands.Request{
+		Message:    msg,
+		AgentID:    agent.ID,
+		SessionKey: sessionKey,
+	}); handled {
 		return response, nil
 	}
 
-	// Route to determine agent and session key
+	// Let /cancel stop this run
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &activeRun{cancel: cancel}
+	al.activeRuns.Store(sessionKey, run)
+	defer func() {
+		al.activeRuns.CompareAndDelete(sessionKey, run)
+		cancel()
+	}()
+
+	response, err := al.runAgentLoop(runCtx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
+		UserID:          al.userID(msg),
+		UserMessage:     msg.Content,
+		Images:          images,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   true,
+		SendResponse:    false,
+		SendProgress:    true,
+		Run:             run,
+	})
+	if err != nil && runCtx.Err() != nil && ctx.Err() == nil {
+		// Cancelled by /cancel, which already replied
+		agent.Sessions.AddMessage(sessionKey, "assistant", cancelledNote)
+		agent.Sessions.Save(sessionKey)
+		return "", nil
+	}
+	return response, err
+}
+
+// userID names the sender of msg for their profile. Scheduled jobs and
+// the CLI, which send as "cron", aren't anyone in particular.
+func (al *AgentLoop) userID(msg bus.InboundMessage) string {
+	if msg.SenderID == "cron" {
+		return ""
+	}
+	return routing.ResolveUserID(al.cfg.Session.IdentityLinks, msg.Channel, msg.SenderID)
+}
+
+// routeMessage resolves the agent and session an inbound message belongs to.
+func (al *AgentLoop) routeMessage(msg bus.InboundMessage) (*AgentInstance, string) {
 	route := al.registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
 		AccountID:  msg.Metadata["account_id"],
@@ -299,6 +638,8 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		ParentPeer: extractParentPeer(msg),
 		GuildID:    msg.Metadata["guild_id"],
 		TeamID:     msg.Metadata["team_id"],
+		ThreadID:   msg.Metadata["thread_id"],
+		SenderID:   msg.SenderID,
 	})
 
 	agent, ok := al.registry.GetAgent(route.AgentID)
@@ -311,6 +652,8 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
 		sessionKey = msg.SessionKey
 	}
+	// Continue on the branch /fork or /switch moved the conversation to
+	sessionKey = agent.Sessions.ActiveKey(sessionKey)
 
 	logger.InfoCF("agent", "Routed message",
 		map[string]any{
@@ -318,16 +661,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			"session_key": sessionKey,
 			"matched_by":  route.MatchedBy,
 		})
-
-	return al.runAgentLoop(ctx, agent, processOptions{
-		SessionKey:      sessionKey,
-		Channel:         msg.Channel,
-		ChatID:          msg.ChatID,
-		UserMessage:     msg.Content,
-		DefaultResponse: "I've completed processing but have no response to give.",
-		EnableSummary:   true,
-		SendResponse:    false,
-	})
+	return agent, sessionKey
 }
 
 func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
@@ -358,8 +692,16 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		content = content[idx+8:] // Extract just the result part
 	}
 
-	// Skip internal channels - only log, don't send to user
+	// Internal channels have no chat to answer in; in the gateway the
+	// manager can still push the result to a phone, otherwise only log it
 	if constants.IsInternalChannel(originChannel) {
+		if al.channelManager != nil {
+			if _, ok := al.channelManager.GetChannel("push"); ok {
+				if err := al.bus.Notify(ctx, originChannel, originChatID, content); err == nil {
+					return "", nil
+				}
+			}
+		}
 		logger.InfoCF("agent", "Subagent completed (internal channel)",
 			map[string]any{
 				"sender_id":   msg.SenderID,
@@ -379,6 +721,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		SessionKey:      sessionKey,
 		Channel:         originChannel,
 		ChatID:          originChatID,
+		SenderID:        msg.SenderID,
 		UserMessage:     fmt.Sprintf("[System: %s] %s", msg.SenderID, msg.Content),
 		DefaultResponse: "Background task completed.",
 		EnableSummary:   false,
@@ -388,6 +731,8 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 
 // runAgentLoop is the core message processing logic.
 func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opts processOptions) (string, error) {
+	ctx = audit.WithActor(ctx, audit.Actor{Agent: agent.ID, Session: opts.SessionKey, Sender: opts.SenderID})
+
 	// 0. Record last channel for heartbeat notifications (skip internal channels)
 	if opts.Channel != "" && opts.ChatID != "" {
 		// Don't record internal channels (cli, system, subagent)
@@ -400,7 +745,10 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
+	ctx = tools.WithChat(ctx, opts.Channel, opts.ChatID)
+	ctx = tools.WithUser(ctx, opts.UserID)
+	ctx = usertime.WithLocation(ctx, userLocation(agent, opts.UserID))
+	agent.FileHistory.Begin(opts.SessionKey)
 
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
@@ -408,20 +756,36 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	if !opts.NoHistory {
 		history = agent.Sessions.GetHistory(opts.SessionKey)
 		summary = agent.Sessions.GetSummary(opts.SessionKey)
+		opts.Memories = userProfileContext(agent, opts.UserID) + al.recallMemories(ctx, agent, opts.UserMessage)
+	}
+	opts.Persona = al.activePersona(agent, opts.SessionKey)
+	buildMessages := func() []providers.Message {
+		messages := agent.ContextBuilder.BuildMessages(
+			history,
+			summary,
+			opts.UserMessage,
+			opts.Images,
+			opts.Channel,
+			opts.ChatID,
+		)
+		messages[0].Content += opts.Persona.section() + opts.Memories
+		return messages
+	}
+	messages := buildMessages()
+
+	// Summarize the oldest turns first if this request would come close to
+	// the context limit
+	if !opts.NoHistory && al.overContextBudget(agent, messages) && al.compressHistory(ctx, agent, opts.SessionKey) {
+		history = agent.Sessions.GetHistory(opts.SessionKey)
+		summary = agent.Sessions.GetSummary(opts.SessionKey)
+		messages = buildMessages()
 	}
-	messages := agent.ContextBuilder.BuildMessages(
-		history,
-		summary,
-		opts.UserMessage,
-		nil,
-		opts.Channel,
-		opts.ChatID,
-	)
 
 	// 3. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
 	// 4. Run LLM iteration loop
+	opts.Budget = al.newTurnBudget(agent)
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
 		return "", err
@@ -438,6 +802,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// 6. Save final assistant message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
 	agent.Sessions.Save(opts.SessionKey)
+	al.rememberTurn(agent, opts, finalContent)
 
 	// 7. Optional: summarization
 	if opts.EnableSummary {
@@ -475,6 +840,13 @@ func (al *AgentLoop) runLLMIteration(
 ) (string, int, error) {
 	iteration := 0
 	var finalContent string
+	calls := make(map[string]int) // identical tool calls made this turn
+	exhausted := true
+	reviewed := false
+	if opts.Run == nil {
+		// Keeps track of the tools run, for what a stopped turn reports
+		opts.Run = &activeRun{}
+	}
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -486,8 +858,14 @@ func (al *AgentLoop) runLLMIteration(
 				"max":       agent.MaxIterations,
 			})
 
+		// Tool results can fill the window within a turn
+		if iteration > 1 && !opts.NoHistory && al.overContextBudget(agent, messages) &&
+			al.compressHistory(ctx, agent, opts.SessionKey) {
+			messages = al.rebuildMessages(agent, opts)
+		}
+
 		// Build tool definitions
-		providerToolDefs := agent.Tools.ToProviderDefs()
+		providerToolDefs := opts.Persona.filterTools(agent.Tools.ToProviderDefsFor(opts.Channel, opts.ChatID))
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
@@ -514,37 +892,11 @@ func (al *AgentLoop) runLLMIteration(
 		var response *providers.LLMResponse
 		var err error
 
-		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
-				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
-					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":  agent.MaxTokens,
-							"temperature": agent.Temperature,
-						})
-					},
-				)
-				if fbErr != nil {
-					return nil, fbErr
-				}
-				if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
-					logger.InfoCF("agent", fmt.Sprintf("Fallback: succeeded with %s/%s after %d attempts",
-						fbResult.Provider, fbResult.Model, len(fbResult.Attempts)+1),
-						map[string]any{"agent_id": agent.ID, "iteration": iteration})
-				}
-				return fbResult.Response, nil
-			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":  agent.MaxTokens,
-				"temperature": agent.Temperature,
-			})
-		}
-
 		// Retry loop for context/token errors
 		maxRetries := 2
 		for retry := 0; retry <= maxRetries; retry++ {
-			response, err = callLLM()
-			if err == nil {
+			response, err = al.callLLM(ctx, agent, opts, opts.Budget.annotate(messages), providerToolDefs)
+			if err == nil || ctx.Err() != nil {
 				break
 			}
 
@@ -564,17 +916,15 @@ func (al *AgentLoop) runLLMIteration(
 					al.bus.PublishOutbound(bus.OutboundMessage{
 						Channel: opts.Channel,
 						ChatID:  opts.ChatID,
-						Content: "Context window exceeded. Compressing history and retrying...",
+						Content: i18n.T(al.language(opts.Channel), i18n.ContextCompressed),
 					})
 				}
 
-				al.forceCompression(agent, opts.SessionKey)
-				newHistory := agent.Sessions.GetHistory(opts.SessionKey)
-				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
-				messages = agent.ContextBuilder.BuildMessages(
-					newHistory, newSummary, "",
-					nil, opts.Channel, opts.ChatID,
-				)
+				// Drop history only if it can't be summarized
+				if !al.compressHistory(ctx, agent, opts.SessionKey) {
+					al.forceCompression(agent, opts.SessionKey)
+				}
+				messages = al.rebuildMessages(agent, opts)
 				continue
 			}
 			break
@@ -589,10 +939,40 @@ func (al *AgentLoop) runLLMIteration(
 				})
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
+		al.recordTurnUsage(agent, opts, response.Usage)
+
+		// Over budget, the tools asked for aren't run, and no more is spent
+		// on a wrap-up; the reply lists what was done instead
+		if len(response.ToolCalls) > 0 && opts.Budget.exceeded() {
+			logger.WarnCF("agent", "Turn budget used up, stopping the turn",
+				map[string]any{"agent_id": agent.ID, "budget": opts.Budget.String(), "iteration": iteration})
+			lang := al.language(opts.Channel)
+			finalContent = i18n.T(lang, i18n.BudgetExceeded, opts.Budget)
+			if done := opts.Run.finished(); len(done) > 0 {
+				finalContent += "\n\n" + i18n.T(lang, i18n.DoneSoFar) + "\n- " + strings.Join(done, "\n- ")
+			}
+			exhausted = false
+			break
+		}
 
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
+			// When the reviewer faults the draft, the agent gets one more pass
+			// to fix it; the revision isn't reviewed again
+			if !reviewed && al.reviewer != nil && iteration < agent.MaxIterations && response.Content != "" &&
+				!opts.Budget.exceeded() &&
+				al.cfg.Agents.Review.Applies(opts.Channel, opts.ChatID) {
+				reviewed = true
+				if critique := al.critique(ctx, agent, opts, messages, response.Content); critique != "" {
+					messages = append(messages,
+						providers.Message{Role: "assistant", Content: response.Content},
+						providers.Message{Role: "user", Content: revisionRequest(critique)},
+					)
+					continue
+				}
+			}
 			finalContent = response.Content
+			exhausted = false
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
@@ -620,6 +1000,8 @@ func (al *AgentLoop) runLLMIteration(
 				"iteration": iteration,
 			})
 
+		al.publishProgress(opts, response.Content, toolNames)
+
 		// Build assistant message with tool calls
 		assistantMsg := providers.Message{
 			Role:    "assistant",
@@ -652,9 +1034,30 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
-		// Execute tool calls
+		// Execute tool calls. A denied approval stops the run; the calls after
+		// it are answered without running so the history stays well formed.
+		// A call repeated more often than MaxRepeats stops it the same way.
+		var stopped, stoppedTool, repeatedTool string
+		var toolImages []string
 		for _, tc := range normalizedToolCalls {
 			argsJSON, _ := json.Marshal(tc.Arguments)
+			callKey := tc.Name + string(argsJSON)
+			if stopped == "" && ctx.Err() != nil {
+				stopped = "the turn was cancelled"
+			}
+			if stopped == "" && calls[callKey] >= agent.MaxRepeats {
+				stopped = fmt.Sprintf("%s was already called %d times with the same arguments",
+					tc.Name, agent.MaxRepeats)
+				repeatedTool = tc.Name
+			}
+			if stopped != "" {
+				skipped := providers.Message{Role: "tool", Content: "Not run: " + stopped, ToolCallID: tc.ID}
+				messages = append(messages, skipped)
+				agent.Sessions.AddFullMessage(opts.SessionKey, skipped)
+				continue
+			}
+			calls[callKey]++
+
 			argsPreview := utils.Truncate(string(argsJSON), 200)
 			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
 				map[string]any{
@@ -679,14 +1082,35 @@ func (al *AgentLoop) runLLMIteration(
 				}
 			}
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
+			var toolResult *tools.ToolResult
+			if !opts.Persona.allows(tc.Name) {
+				toolResult = opts.Persona.refusedTool(tc.Name)
+			} else {
+				toolResult = al.dryRunCall(ctx, agent, opts, tc.Name, tc.Arguments)
+			}
+			if toolResult == nil && al.needsApproval(agent, tc.Name) &&
+				agent.Tools.Allowed(tc.Name, opts.Channel, opts.ChatID) {
+				if err := al.requestApproval(ctx, opts, tc.Name, argsPreview); err != nil {
+					toolResult = tools.ErrorResult(err.Error())
+					if !al.cfg.Tools.Approval.ContinueOnDeny {
+						stopped, stoppedTool = err.Error(), tc.Name
+					}
+				}
+			}
+			if toolResult == nil {
+				toolCtx, stopProgress := al.withToolProgress(ctx, opts, tc.Name)
+				opts.Run.toolStarted(tc.Name, tc.Arguments)
+				toolResult = agent.Tools.ExecuteWithContext(
+					toolCtx,
+					tc.Name,
+					tc.Arguments,
+					opts.Channel,
+					opts.ChatID,
+					asyncCallback,
+				)
+				stopProgress()
+				opts.Run.toolFinished(toolResult.IsError)
+			}
 
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
@@ -707,6 +1131,8 @@ func (al *AgentLoop) runLLMIteration(
 			if contentForLLM == "" && toolResult.Err != nil {
 				contentForLLM = toolResult.Err.Error()
 			}
+			contentForLLM = al.limitToolResult(ctx, agent, opts, tc.Name, contentForLLM)
+			contentForLLM = al.guardToolResult(ctx, agent, opts, tc.Name, contentForLLM)
 
 			toolResultMsg := providers.Message{
 				Role:       "tool",
@@ -717,30 +1143,109 @@ func (al *AgentLoop) runLLMIteration(
 
 			// Save tool result message to session
 			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			toolImages = append(toolImages, toolResult.Images...)
+		}
+
+		// Tool messages can't carry images for every provider, so they follow
+		// in a user message. Like a user's photos, they stay out of the session.
+		if len(toolImages) > 0 && agent.Vision {
+			messages = append(messages, providers.Message{
+				Role:    "user",
+				Content: "[images from the tool results above]",
+				Images:  toolImages,
+			})
+		}
+
+		if err := ctx.Err(); err != nil {
+			return "", iteration, err
+		}
+		if repeatedTool != "" {
+			logger.WarnCF("agent", "Repeated tool call, stopping the turn",
+				map[string]any{"agent_id": agent.ID, "tool": repeatedTool, "iteration": iteration})
+			finalContent = al.wrapUp(ctx, agent, opts, messages,
+				fmt.Sprintf("you called %s with the same arguments %d times", repeatedTool, agent.MaxRepeats),
+				i18n.T(al.language(opts.Channel), i18n.LoopRepeated, repeatedTool, agent.MaxRepeats))
+			exhausted = false
+			break
+		}
+		if stopped != "" {
+			finalContent = i18n.T(al.language(opts.Channel), i18n.ApprovalStopped, stoppedTool)
+			exhausted = false
+			break
 		}
 	}
 
+	if exhausted {
+		logger.WarnCF("agent", "Tool iteration limit reached, stopping the turn",
+			map[string]any{"agent_id": agent.ID, "max": agent.MaxIterations})
+		finalContent = al.wrapUp(ctx, agent, opts, messages,
+			fmt.Sprintf("you used all %d rounds of tool calls allowed in one turn", agent.MaxIterations),
+			i18n.T(al.language(opts.Channel), i18n.LoopLimit, agent.MaxIterations))
+	}
+
 	return finalContent, iteration, nil
 }
 
-// updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
-	// Use ContextualTool interface instead of type assertions
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if mt, ok := tool.(tools.ContextualTool); ok {
-			mt.SetContext(channel, chatID)
-		}
+// callLLM sends one request of the turn to its model: the persona's when it
+// has one, else the agent's, going down its fallback candidates when it has
+// several.
+func (al *AgentLoop) callLLM(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	messages []providers.Message,
+	toolDefs []providers.ToolDefinition,
+) (*providers.LLMResponse, error) {
+	llmOpts := map[string]any{
+		"max_tokens":  agent.MaxTokens,
+		"temperature": agent.Temperature,
 	}
-	if tool, ok := agent.Tools.Get("spawn"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
+	if p := opts.Persona; p != nil && p.provider != nil {
+		return p.provider.Chat(ctx, messages, toolDefs, p.model, llmOpts)
 	}
-	if tool, ok := agent.Tools.Get("subagent"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
+	if len(agent.Candidates) > 1 && al.fallback != nil {
+		fbResult, err := al.fallback.Execute(ctx, agent.Candidates,
+			func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
+				return agent.Provider.Chat(ctx, messages, toolDefs, model, llmOpts)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
+			logger.InfoCF("agent", fmt.Sprintf("Fallback: succeeded with %s/%s after %d attempts",
+				fbResult.Provider, fbResult.Model, len(fbResult.Attempts)+1),
+				map[string]any{"agent_id": agent.ID})
 		}
+		return fbResult.Response, nil
 	}
+	return agent.Provider.Chat(ctx, messages, toolDefs, agent.Model, llmOpts)
+}
+
+// publishProgress sends an interim update before tools run: the model's partial
+// output if it wrote any, otherwise the tools it is calling. The channel manager
+// drops it for channels that don't support progressive replies.
+func (al *AgentLoop) publishProgress(opts processOptions, content string, toolNames []string) {
+	if !opts.SendProgress || constants.IsInternalChannel(opts.Channel) {
+		return
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		content = "Working: " + strings.Join(toolNames, ", ")
+	}
+
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel:  opts.Channel,
+		ChatID:   opts.ChatID,
+		Content:  content,
+		Progress: true,
+	})
+}
+
+// language returns the language of the agent's own messages on channel.
+func (al *AgentLoop) language(channel string) string {
+	return al.cfg.Channels.Language.For(channel)
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
@@ -758,7 +1263,7 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 					al.bus.PublishOutbound(bus.OutboundMessage{
 						Channel: channel,
 						ChatID:  chatID,
-						Content: "Memory threshold reached. Optimizing conversation history...",
+						Content: i18n.T(al.language(channel), i18n.MemoryOptimized),
 					})
 				}
 				al.summarizeSession(agent, sessionKey)
@@ -977,6 +1482,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		agent.Sessions.SetSummary(sessionKey, finalSummary)
 		agent.Sessions.TruncateHistory(sessionKey, 4)
 		agent.Sessions.Save(sessionKey)
+		al.rememberSummary(ctx, agent, sessionKey, finalSummary)
 	}
 }
 
@@ -1028,96 +1534,6 @@ func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
 	return totalChars * 2 / 5
 }
 
-func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
-	content := strings.TrimSpace(msg.Content)
-	if !strings.HasPrefix(content, "/") {
-		return "", false
-	}
-
-	parts := strings.Fields(content)
-	if len(parts) == 0 {
-		return "", false
-	}
-
-	cmd := parts[0]
-	args := parts[1:]
-
-	switch cmd {
-	case "/show":
-		if len(args) < 1 {
-			return "Usage: /show [model|channel|agents]", true
-		}
-		switch args[0] {
-		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
-				return "No default agent configured", true
-			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
-		case "channel":
-			return fmt.Sprintf("Current channel: %s", msg.Channel), true
-		case "agents":
-			agentIDs := al.registry.ListAgentIDs()
-			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
-		default:
-			return fmt.Sprintf("Unknown show target: %s", args[0]), true
-		}
-
-	case "/list":
-		if len(args) < 1 {
-			return "Usage: /list [models|channels|agents]", true
-		}
-		switch args[0] {
-		case "models":
-			return "Available models: configured in config.json per agent", true
-		case "channels":
-			if al.channelManager == nil {
-				return "Channel manager not initialized", true
-			}
-			channels := al.channelManager.GetEnabledChannels()
-			if len(channels) == 0 {
-				return "No channels enabled", true
-			}
-			return fmt.Sprintf("Enabled channels: %s", strings.Join(channels, ", ")), true
-		case "agents":
-			agentIDs := al.registry.ListAgentIDs()
-			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
-		default:
-			return fmt.Sprintf("Unknown list target: %s", args[0]), true
-		}
-
-	case "/switch":
-		if len(args) < 3 || args[1] != "to" {
-			return "Usage: /switch [model|channel] to <name>", true
-		}
-		target := args[0]
-		value := args[2]
-
-		switch target {
-		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
-				return "No default agent configured", true
-			}
-			oldModel := defaultAgent.Model
-			defaultAgent.Model = value
-			return fmt.Sprintf("Switched model from %s to %s", oldModel, value), true
-		case "channel":
-			if al.channelManager == nil {
-				return "Channel manager not initialized", true
-			}
-			if _, exists := al.channelManager.GetChannel(value); !exists && value != "cli" {
-				return fmt.Sprintf("Channel '%s' not found or not enabled", value), true
-			}
-			return fmt.Sprintf("Switched target channel to %s", value), true
-		default:
-			return fmt.Sprintf("Unknown switch target: %s", target), true
-		}
-	}
-
-	return "", false
-}
-
 // extractPeer extracts the routing peer from inbound message metadata.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	peerKind := msg.Metadata["peer_kind"]
@@ -631,3 +631,163 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+// imageMockProvider records the images on the last user message it saw
+type imageMockProvider struct {
+	images []string
+}
+
+func (m *imageMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.images = messages[len(messages)-1].Images
+	return &providers.LLMResponse{Content: "a cat"}, nil
+}
+
+func (m *imageMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessMessage_ForwardsImagesToVisionModel(t *testing.T) {
+	for _, vision := range []bool{true, false} {
+		t.Run(fmt.Sprintf("vision=%v", vision), func(t *testing.T) {
+			cfg := &config.Config{
+				Agents: config.AgentsConfig{
+					Defaults: config.AgentDefaults{
+						Workspace:         t.TempDir(),
+						Model:             "test-model",
+						MaxTokens:         4096,
+						MaxToolIterations: 10,
+					},
+				},
+				ModelList: []config.ModelConfig{
+					{ModelName: "test-model", Model: "openai/gpt-4o", Vision: vision},
+				},
+			}
+			provider := &imageMockProvider{}
+			al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+			images := []string{"data:image/png;base64,AAAA"}
+			_, err := al.processMessage(context.Background(), bus.InboundMessage{
+				Channel:    "telegram",
+				SenderID:   "u1",
+				ChatID:     "c1",
+				Content:    "what's in this photo?\n[image: photo]",
+				Images:     images,
+				SessionKey: "agent:main:test-images",
+			})
+			if err != nil {
+				t.Fatalf("processMessage() error = %v", err)
+			}
+
+			if vision && len(provider.images) != 1 {
+				t.Errorf("provider got %d images, want 1", len(provider.images))
+			}
+			if !vision && provider.images != nil {
+				t.Errorf("provider got images %v for a text-only model", provider.images)
+			}
+
+			history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:test-images")
+			if len(history) != 2 {
+				t.Fatalf("len(history) = %d, want 2", len(history))
+			}
+			for _, msg := range history {
+				if msg.Images != nil {
+					t.Errorf("session history kept images: %+v", msg)
+				}
+			}
+		})
+	}
+}
+
+// pictureTool returns a text result with an image attached
+type pictureTool struct{}
+
+func (pictureTool) Name() string               { return "picture_tool" }
+func (pictureTool) Description() string        { return "Returns a picture" }
+func (pictureTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (pictureTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	return tools.NewToolResult("Screenshot of the screen").WithImages("data:image/jpeg;base64,AAAA")
+}
+
+// pictureProvider calls picture_tool once, then records the images on the
+// last message it saw
+type pictureProvider struct {
+	images []string
+}
+
+func (p *pictureProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if messages[len(messages)-1].Role == "user" && messages[len(messages)-1].Images == nil {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "picture_tool", Arguments: map[string]any{}}},
+		}, nil
+	}
+	p.images = messages[len(messages)-1].Images
+	return &providers.LLMResponse{Content: "an error dialog"}, nil
+}
+
+func (p *pictureProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRunLLMIteration_SendsToolImagesToVisionModel(t *testing.T) {
+	for _, vision := range []bool{true, false} {
+		t.Run(fmt.Sprintf("vision=%v", vision), func(t *testing.T) {
+			cfg := &config.Config{
+				Agents: config.AgentsConfig{
+					Defaults: config.AgentDefaults{
+						Workspace:         t.TempDir(),
+						Model:             "test-model",
+						MaxTokens:         4096,
+						MaxToolIterations: 10,
+					},
+				},
+				ModelList: []config.ModelConfig{
+					{ModelName: "test-model", Model: "openai/gpt-4o", Vision: vision},
+				},
+			}
+			provider := &pictureProvider{}
+			al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+			al.RegisterTool(pictureTool{})
+
+			_, err := al.processMessage(context.Background(), bus.InboundMessage{
+				Channel:    "test",
+				SenderID:   "u1",
+				ChatID:     "c1",
+				Content:    "what does this error dialog mean?",
+				SessionKey: "agent:main:test-tool-images",
+			})
+			if err != nil {
+				t.Fatalf("processMessage() error = %v", err)
+			}
+
+			if vision && len(provider.images) != 1 {
+				t.Errorf("provider got %d images, want 1", len(provider.images))
+			}
+			if !vision && provider.images != nil {
+				t.Errorf("provider got images %v for a text-only model", provider.images)
+			}
+
+			history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:test-tool-images")
+			if len(history) != 4 {
+				t.Fatalf("len(history) = %d, want 4", len(history))
+			}
+			for _, msg := range history {
+				if msg.Images != nil {
+					t.Errorf("session history kept images: %+v", msg)
+				}
+			}
+		})
+	}
+}
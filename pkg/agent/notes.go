@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notes"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+const (
+	memoryNote = "note"
+
+	// noteSourcePrefix starts the store source of a note's record; the
+	// note ID follows.
+	noteSourcePrefix = "note:"
+	// noteRecallChars caps the part of a note shown with a recalled memory.
+	noteRecallChars = 600
+)
+
+// registerNotesTool gives the agent a notebook in its workspace.
+func registerNotesTool(cfg *config.Config, agent *AgentInstance) {
+	dir := cfg.Tools.Notes.Dir
+	if dir == "" {
+		dir = "notes"
+	}
+	agent.Notes = notes.NewStore(filepath.Join(agent.Workspace, dir))
+	agent.Tools.Register(tools.NewNotesTool(agent.Notes))
+}
+
+// recallNotes makes saved notes part of each agent's long-term memory.
+func (al *AgentLoop) recallNotes() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || agent.Notes == nil {
+			continue
+		}
+		tool, ok := agent.Tools.Get("notes")
+		if !ok {
+			continue
+		}
+		if notesTool, ok := tool.(*tools.NotesTool); ok {
+			notesTool.SetNoteSavedCallback(func(_ context.Context, note *notes.Note) {
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), recallTimeout)
+					defer cancel()
+					if err := al.rememberNote(ctx, agent, note); err != nil {
+						logger.WarnCF("agent", "Could not store note in memory", map[string]any{
+							"note":  note.ID,
+							"error": err.Error(),
+						})
+					}
+				}()
+			})
+		}
+	}
+}
+
+// rememberNote embeds a note and replaces what memory held for it.
+func (al *AgentLoop) rememberNote(ctx context.Context, agent *AgentInstance, note *notes.Note) error {
+	store, err := al.memoryStore(agent)
+	if err != nil {
+		return err
+	}
+	vectors, err := al.embed(ctx, []string{truncateMiddle(note.Text(), maxRecallInput)})
+	if err != nil {
+		return err
+	}
+
+	source := noteSourcePrefix + note.ID
+	if _, err := store.Delete(func(r vectorstore.Record) bool { return r.Source == source }); err != nil {
+		return err
+	}
+	text := note.Title + " (notes id " + note.ID + "): " + strings.Join(strings.Fields(note.Body), " ")
+	return store.Add(vectorstore.Record{
+		Kind:   memoryNote,
+		Source: source,
+		Text:   utils.Truncate(text, noteRecallChars),
+		Vector: vectors[0],
+	})
+}
+
+// syncNotes brings memory up to date with notes edited or removed outside
+// the notes tool, such as by hand or while memory was off. It runs once at
+// start.
+func (al *AgentLoop) syncNotes(ctx context.Context) {
+	if al.memory == nil {
+		return
+	}
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || agent.Notes == nil {
+			continue
+		}
+		if err := al.syncAgentNotes(ctx, agent); err != nil {
+			logger.WarnCF("agent", "Could not update notes in memory", map[string]any{
+				"agent_id": agentID,
+				"error":    err.Error(),
+			})
+		}
+	}
+}
+
+func (al *AgentLoop) syncAgentNotes(ctx context.Context, agent *AgentInstance) error {
+	list, err := agent.Notes.List()
+	if err != nil {
+		return err
+	}
+	store, err := al.memoryStore(agent)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]time.Time) // note ID -> when it was embedded
+	store.Each(func(r vectorstore.Record) {
+		if id, ok := strings.CutPrefix(r.Source, noteSourcePrefix); ok && r.Kind == memoryNote {
+			stored[id] = r.Created
+		}
+	})
+
+	current := make(map[string]bool)
+	for _, note := range list {
+		current[note.ID] = true
+		info, err := os.Stat(agent.Notes.Path(note.ID))
+		if err != nil {
+			continue
+		}
+		if embedded, ok := stored[note.ID]; ok && !info.ModTime().After(embedded) {
+			continue
+		}
+		if err := al.rememberNote(ctx, agent, note); err != nil {
+			return err
+		}
+	}
+
+	_, err = store.Delete(func(r vectorstore.Record) bool {
+		id, ok := strings.CutPrefix(r.Source, noteSourcePrefix)
+		return ok && r.Kind == memoryNote && !current[id]
+	})
+	return err
+}
@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// personaDir is where a workspace keeps its personas, one markdown file each.
+const personaDir = "personas"
+
+var rePersonaName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// persona is a character a conversation can switch the agent to. Its
+// provider and model are nil and "" when it keeps the agent's.
+type persona struct {
+	name        string
+	description string
+	prompt      string
+	modelName   string // model_list entry
+	tools       []string
+	provider    providers.LLMProvider
+	model       string
+}
+
+// personaModel holds the provider of a model_list entry a persona uses,
+// created on first use.
+type personaModel struct {
+	once     sync.Once
+	provider providers.LLMProvider
+	model    string
+	err      error
+}
+
+// personas returns the personas agent can switch to: those in config, and
+// the markdown files in the workspace's personas directory, which override
+// config ones of the same name.
+func (al *AgentLoop) personas(agent *AgentInstance) map[string]persona {
+	all := make(map[string]persona)
+	for name, pc := range al.cfg.Agents.Personas {
+		name = strings.ToLower(name)
+		all[name] = persona{
+			name:        name,
+			description: pc.Description,
+			prompt:      pc.Prompt,
+			modelName:   pc.Model,
+			tools:       pc.Tools,
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(agent.Workspace, personaDir, "*.md"))
+	for _, path := range files {
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".md"))
+		if !rePersonaName.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to read persona", map[string]any{"path": path, "error": err.Error()})
+			continue
+		}
+		all[name] = parsePersona(name, string(data))
+	}
+	for name, p := range all {
+		if strings.TrimSpace(p.prompt) == "" {
+			delete(all, name)
+		}
+	}
+	return all
+}
+
+// parsePersona reads a persona file: optional frontmatter with description,
+// model and tools (comma-separated), then the prompt.
+//
+//	---
+//	description: A patient tutor
+//	model: gpt-4o-mini
+//	tools: web_search, read_file
+//	---
+//	You are a patient tutor...
+func parsePersona(name, text string) persona {
+	p := persona{name: name}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if front, body, ok := strings.Cut(rest, "\n---"); ok {
+			text = strings.TrimPrefix(body, "\n")
+			for _, line := range strings.Split(front, "\n") {
+				key, value, ok := strings.Cut(line, ":")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(strings.TrimSpace(value), `"'`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "description":
+					p.description = value
+				case "model":
+					p.modelName = value
+				case "tools":
+					for _, tool := range strings.Split(strings.Trim(value, "[]"), ",") {
+						if tool = strings.Trim(strings.TrimSpace(tool), `"'`); tool != "" {
+							p.tools = append(p.tools, tool)
+						}
+					}
+				}
+			}
+		}
+	}
+	p.prompt = strings.TrimSpace(text)
+	return p
+}
+
+// activePersona returns the persona the session is switched to, with its
+// model's provider, or nil when it has none. A persona whose model can't be
+// created keeps the agent's.
+func (al *AgentLoop) activePersona(agent *AgentInstance, sessionKey string) *persona {
+	name := agent.Sessions.Persona(sessionKey)
+	if name == "" {
+		return nil
+	}
+	p, ok := al.personas(agent)[name]
+	if !ok {
+		logger.WarnCF("agent", "Persona no longer exists", map[string]any{"persona": name, "session_key": sessionKey})
+		return nil
+	}
+	if p.modelName != "" {
+		value, _ := al.personaModels.LoadOrStore(p.modelName, &personaModel{})
+		m := value.(*personaModel)
+		m.once.Do(func() {
+			modelCfg, err := al.cfg.GetModelConfig(p.modelName)
+			if err != nil {
+				m.err = err
+				return
+			}
+			m.provider, m.model, m.err = providers.CreateProviderFromConfig(modelCfg)
+		})
+		if m.err != nil {
+			logger.WarnCF("agent", "Persona model unavailable, using the agent's",
+				map[string]any{"persona": name, "model": p.modelName, "error": m.err.Error()})
+		} else {
+			p.provider, p.model = m.provider, m.model
+		}
+	}
+	return &p
+}
+
+// section is the system prompt section of the persona.
+func (p *persona) section() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\n## Persona: %s\n\nIn this conversation you act as the persona below. Where it differs "+
+		"from your identity or personality above, the persona wins; your rules on safety and tools still "+
+		"apply.\n\n%s", p.name, p.prompt)
+}
+
+// allows reports whether the persona may use the tool.
+func (p *persona) allows(tool string) bool {
+	return p == nil || len(p.tools) == 0 || slices.Contains(p.tools, tool)
+}
+
+// filterTools drops the definitions of tools the persona may not use.
+func (p *persona) filterTools(defs []providers.ToolDefinition) []providers.ToolDefinition {
+	if p == nil || len(p.tools) == 0 {
+		return defs
+	}
+	kept := make([]providers.ToolDefinition, 0, len(defs))
+	for _, def := range defs {
+		if p.allows(def.Function.Name) {
+			kept = append(kept, def)
+		}
+	}
+	return kept
+}
+
+// refusedTool is the result of a call to a tool the persona may not use.
+func (p *persona) refusedTool(name string) *tools.ToolResult {
+	return tools.ErrorResult(fmt.Sprintf("tool %q is not available to the %s persona", name, p.name))
+}
+
+// personaCommand lists the personas, or switches the conversation to one.
+// History is kept, so the new persona picks up where the last one left off.
+func (al *AgentLoop) personaCommand(ctx context.Context, req commands.Request) string {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "No agent for this conversation"
+	}
+	all := al.personas(agent)
+	current := agent.Sessions.Persona(req.SessionKey)
+
+	if len(req.Args) == 0 {
+		if len(all) == 0 {
+			return fmt.Sprintf("No personas. Add them under agents.personas in the config, or as markdown files "+
+				"in %s/ in the workspace.", personaDir)
+		}
+		var sb strings.Builder
+		sb.WriteString("Personas:")
+		for _, name := range slices.Sorted(maps.Keys(all)) {
+			p := all[name]
+			mark := ""
+			if name == current {
+				mark = " (current)"
+			}
+			fmt.Fprintf(&sb, "\n- %s%s", name, mark)
+			if p.description != "" {
+				sb.WriteString(": " + p.description)
+			}
+		}
+		sb.WriteString("\n\n/persona <name> switches to one, /persona off back to the agent's own.")
+		return sb.String()
+	}
+
+	name := strings.ToLower(req.Args[0])
+	if name == "off" || name == "default" || name == "none" {
+		if current == "" {
+			return "No persona is active."
+		}
+		agent.Sessions.SetPersona(req.SessionKey, "")
+		if err := agent.Sessions.Save(req.SessionKey); err != nil {
+			return fmt.Sprintf("Could not save the conversation: %v", err)
+		}
+		return fmt.Sprintf("Left the %s persona.", current)
+	}
+	p, ok := all[name]
+	if !ok {
+		return fmt.Sprintf("No persona %q. /persona lists them.", name)
+	}
+	agent.Sessions.SetPersona(req.SessionKey, name)
+	if err := agent.Sessions.Save(req.SessionKey); err != nil {
+		return fmt.Sprintf("Could not save the conversation: %v", err)
+	}
+
+	reply := fmt.Sprintf("Switched to the %s persona", name)
+	var details []string
+	if p.modelName != "" {
+		details = append(details, "model "+p.modelName)
+	}
+	if len(p.tools) > 0 {
+		details = append(details, "tools "+strings.Join(p.tools, ", "))
+	}
+	if len(details) > 0 {
+		reply += " (" + strings.Join(details, "; ") + ")"
+	}
+	return reply + ". The conversation so far is kept."
+}
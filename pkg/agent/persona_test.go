@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// toolListProvider records the system prompt and tools of the last request.
+type toolListProvider struct {
+	prompt string
+	tools  []string
+}
+
+func (p *toolListProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.prompt = messages[0].Content
+	p.tools = p.tools[:0]
+	for _, tool := range tools {
+		p.tools = append(p.tools, tool.Function.Name)
+	}
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *toolListProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestParsePersona(t *testing.T) {
+	p := parsePersona("tutor", "---\r\ndescription: A patient tutor\r\nmodel: small\r\n"+
+		"tools: [web_search, \"read_file\"]\r\n---\r\nYou explain step by step.\r\n")
+	if p.description != "A patient tutor" || p.modelName != "small" || p.prompt != "You explain step by step." {
+		t.Errorf("parsePersona() = %+v", p)
+	}
+	if !slices.Equal(p.tools, []string{"web_search", "read_file"}) {
+		t.Errorf("tools = %q", p.tools)
+	}
+	if p := parsePersona("plain", "Just a prompt.\n"); p.prompt != "Just a prompt." || p.description != "" {
+		t.Errorf("parsePersona() without frontmatter = %+v", p)
+	}
+}
+
+func TestPersonaCommand(t *testing.T) {
+	provider := &toolListProvider{}
+	al := newCommandTestLoop(t, provider)
+	al.cfg.Agents.Personas = map[string]config.PersonaConfig{
+		"pirate": {Description: "Talks like a pirate", Prompt: "Speak like a pirate."},
+		"critic": {Prompt: "Old critic prompt."},
+	}
+	agent := al.registry.GetDefaultAgent()
+	dir := filepath.Join(agent.Workspace, personaDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	critic := "---\ndescription: Finds the flaws\ntools: read_file\n---\nPoint out every flaw."
+	if err := os.WriteFile(filepath.Join(dir, "critic.md"), []byte(critic), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	list := helper.executeAndGetResponse(t, ctx, commandMessage("/persona"))
+	if !strings.Contains(list, "critic: Finds the flaws") || !strings.Contains(list, "pirate: Talks like a pirate") {
+		t.Errorf("/persona = %q", list)
+	}
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/persona wizard")); !strings.Contains(got, "No") {
+		t.Errorf("/persona wizard = %q", got)
+	}
+
+	helper.executeAndGetResponse(t, ctx, commandMessage("hello"))
+	if strings.Contains(provider.prompt, "## Persona") || !slices.Contains(provider.tools, "write_file") {
+		t.Fatalf("persona applied before switching: tools %q", provider.tools)
+	}
+
+	got := helper.executeAndGetResponse(t, ctx, commandMessage("/persona Critic"))
+	if !strings.Contains(got, "tools read_file") {
+		t.Errorf("/persona critic = %q", got)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("review my plan"))
+	if !strings.Contains(provider.prompt, "## Persona: critic") ||
+		!strings.Contains(provider.prompt, "Point out every flaw.") {
+		t.Errorf("system prompt has no persona:\n%s", provider.prompt)
+	}
+	if !slices.Equal(provider.tools, []string{"read_file"}) {
+		t.Errorf("tools = %q, want only read_file", provider.tools)
+	}
+	if history := agent.Sessions.GetHistory("agent:main:commands"); len(history) != 4 {
+		t.Errorf("history has %d messages, want both turns kept", len(history))
+	}
+	if refused := (&persona{name: "critic", tools: []string{"read_file"}}).refusedTool("exec"); !refused.IsError {
+		t.Error("call outside the persona's tools was not refused")
+	}
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/persona off")); !strings.Contains(got, "critic") {
+		t.Errorf("/persona off = %q", got)
+	}
+	helper.executeAndGetResponse(t, ctx, commandMessage("thanks"))
+	if strings.Contains(provider.prompt, "## Persona") {
+		t.Error("persona still applied after /persona off")
+	}
+}
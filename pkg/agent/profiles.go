@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// defaultProfileIterations bounds a profile's tool loop when its config
+// doesn't.
+const defaultProfileIterations = 10
+
+// builtinProfiles fill in the parts of the researcher, coder and summarizer
+// profiles that config leaves empty.
+var builtinProfiles = map[string]config.AgentProfileConfig{
+	"researcher": {
+		Description: "finds and checks information on the web and in workspace documents, citing sources",
+		Prompt: "You are a careful researcher. Search, read the most relevant sources and cross-check what " +
+			"they say. Report the findings with the URL or file each comes from, and say what you couldn't " +
+			"confirm.",
+		Tools: config.FlexibleStringSlice{"web_search", "fetch_url", "search_docs", "read_file", "read_pdf"},
+	},
+	"coder": {
+		Description: "writes, edits, runs and debugs code and scripts in the workspace",
+		Prompt: "You are an experienced software engineer. Read the relevant code before changing it, keep " +
+			"changes small, and run or test what you write. Report the files you changed and how you " +
+			"checked them.",
+		Tools: config.FlexibleStringSlice{
+			"read_file", "write_file", "edit_file", "append_file", "list_dir", "glob", "exec", "run_code", "python",
+		},
+	},
+	"summarizer": {
+		Description: "condenses long text, documents or results into a short summary",
+		Prompt: "You write clear, faithful summaries. Keep the key facts, numbers, decisions and open " +
+			"questions, drop repetition, and never add anything the source doesn't say.",
+		Tools: config.FlexibleStringSlice{"read_file", "read_pdf"},
+	},
+}
+
+// delegateProfiles builds the profiles in config for agent. Their tools are
+// taken from the agent's own registry, under the same access policy,
+// retries, result cache and audit log; tools that need approval are left out, since a profile has no
+// chat to ask.
+func delegateProfiles(
+	cfg *config.Config,
+	agent *AgentInstance,
+	auditLog *audit.Log,
+	cache *tools.ResultCache,
+) []*tools.DelegateProfile {
+	var profiles []*tools.DelegateProfile
+	for _, name := range slices.Sorted(maps.Keys(cfg.Agents.Profiles)) {
+		pc := cfg.Agents.Profiles[name]
+		if builtin, ok := builtinProfiles[name]; ok {
+			pc = withBuiltinProfile(pc, builtin)
+		}
+		if pc.Prompt == "" {
+			logger.WarnCF("agent", "Profile has no prompt, skipping", map[string]any{"profile": name})
+			continue
+		}
+
+		provider, model := agent.Provider, agent.Model
+		if pc.Model != "" {
+			modelCfg, err := cfg.GetModelConfig(pc.Model)
+			if err == nil {
+				provider, model, err = providers.CreateProviderFromConfig(modelCfg)
+			}
+			if err != nil {
+				logger.WarnCF("agent", "Profile model unavailable, skipping",
+					map[string]any{"profile": name, "model": pc.Model, "error": err.Error()})
+				continue
+			}
+		}
+
+		var registry *tools.ToolRegistry
+		for _, toolName := range pc.Tools {
+			tool, ok := agent.Tools.Get(toolName)
+			if !ok || requiresApproval(cfg.Tools.Approval, tool) {
+				continue
+			}
+			if registry == nil {
+				registry = tools.NewToolRegistry()
+				registry.SetAccessPolicy(tools.NewAccessPolicy(cfg.Tools.Access))
+				registry.SetRetryPolicy(tools.NewRetryPolicy(cfg.Tools.Retry))
+				registry.SetResultCache(cache)
+				registry.SetAuditLog(auditLog)
+			}
+			registry.Register(tool)
+		}
+
+		maxIter := pc.MaxIterations
+		if maxIter <= 0 {
+			maxIter = defaultProfileIterations
+		}
+		profiles = append(profiles, &tools.DelegateProfile{
+			Name:          name,
+			Description:   pc.Description,
+			Prompt:        pc.Prompt,
+			Provider:      provider,
+			Model:         model,
+			Tools:         registry,
+			MaxIterations: maxIter,
+			LLMOptions: map[string]any{
+				"max_tokens":  agent.MaxTokens,
+				"temperature": agent.Temperature,
+			},
+		})
+	}
+	return profiles
+}
+
+// withBuiltinProfile fills the empty fields of pc from builtin.
+func withBuiltinProfile(pc, builtin config.AgentProfileConfig) config.AgentProfileConfig {
+	if pc.Description == "" {
+		pc.Description = builtin.Description
+	}
+	if pc.Prompt == "" {
+		pc.Prompt = builtin.Prompt
+	}
+	if len(pc.Tools) == 0 {
+		pc.Tools = builtin.Tools
+	}
+	return pc
+}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestDelegateProfiles(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			Profiles: map[string]config.AgentProfileConfig{
+				"coder":    {MaxIterations: 4},
+				"reviewer": {Prompt: "You review code.", Tools: []string{"read_file", "no_such_tool"}},
+				"nameless": {},
+			},
+		},
+		Tools: config.ToolsConfig{Approval: config.ApprovalConfig{Risk: "high"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	agent := al.registry.GetDefaultAgent()
+	if _, ok := agent.Tools.Get("delegate"); !ok {
+		t.Fatal("delegate tool not registered")
+	}
+
+	profiles := delegateProfiles(cfg, agent, nil, nil)
+	if len(profiles) != 2 || profiles[0].Name != "coder" || profiles[1].Name != "reviewer" {
+		t.Fatalf("profiles = %+v, want coder and reviewer", profiles)
+	}
+	coder := profiles[0]
+	if coder.Prompt != builtinProfiles["coder"].Prompt || coder.MaxIterations != 4 {
+		t.Errorf("coder = %+v, want the built-in prompt and 4 iterations", coder)
+	}
+	// Writing files and running commands need approval, which a profile can't ask for
+	if got := coder.Tools.List(); len(got) != 3 {
+		t.Errorf("coder tools = %v, want read_file, list_dir and glob", got)
+	}
+	for _, name := range []string{"exec", "write_file", "edit_file"} {
+		if _, ok := coder.Tools.Get(name); ok {
+			t.Errorf("coder got %s, which needs approval", name)
+		}
+	}
+	if got := profiles[1].Tools.List(); len(got) != 1 || got[0] != "read_file" {
+		t.Errorf("reviewer tools = %v, want read_file", got)
+	}
+}
@@ -0,0 +1,331 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+const (
+	recallTimeout  = 15 * time.Second
+	extractTimeout = 60 * time.Second
+
+	// duplicateScore is how similar a new fact must be to a stored one to
+	// count as already known.
+	duplicateScore = 0.92
+	// maxRecallInput caps the text embedded or sent for extraction.
+	maxRecallInput = 8000
+)
+
+// Kinds of stored memories.
+const (
+	memoryFact    = "fact"
+	memorySummary = "summary"
+)
+
+// memoryUserKey is the Meta key of the user a fact was learned from.
+const memoryUserKey = "user"
+
+// longTermMemory stores facts and summaries from conversations as vectors
+// and recalls the ones relevant to a new message (config memory). Each
+// agent has its own store in its workspace.
+type longTermMemory struct {
+	embedOnce  sync.Once
+	embedder   providers.EmbeddingProvider
+	embedModel string
+	embedErr   error
+
+	extractOnce     sync.Once
+	extractProvider providers.LLMProvider
+	extractModel    string
+	extractErr      error
+
+	mu     sync.Mutex
+	stores map[string]*vectorstore.Store // agent ID -> store
+}
+
+func newLongTermMemory() *longTermMemory {
+	return &longTermMemory{stores: make(map[string]*vectorstore.Store)}
+}
+
+func (al *AgentLoop) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	m := al.memory
+	m.embedOnce.Do(func() {
+		modelCfg, err := al.cfg.GetModelConfig(al.cfg.Memory.EmbeddingModel)
+		if err != nil {
+			m.embedErr = err
+			return
+		}
+		m.embedder, m.embedModel, m.embedErr = providers.CreateEmbedderFromConfig(modelCfg)
+	})
+	if m.embedErr != nil {
+		return nil, m.embedErr
+	}
+	return m.embedder.Embed(ctx, texts, m.embedModel)
+}
+
+// memoryStore opens the agent's store on first use.
+func (al *AgentLoop) memoryStore(agent *AgentInstance) (*vectorstore.Store, error) {
+	m := al.memory
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if store, ok := m.stores[agent.ID]; ok {
+		return store, nil
+	}
+	store, err := vectorstore.Open(filepath.Join(agent.Workspace, "memory", "recall.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	m.stores[agent.ID] = store
+	return store, nil
+}
+
+// recallMemories returns a system prompt section with the stored memories
+// closest to the message, or "" when there are none or recall fails.
+func (al *AgentLoop) recallMemories(ctx context.Context, agent *AgentInstance, message string) string {
+	if al.memory == nil || strings.TrimSpace(message) == "" {
+		return ""
+	}
+	scope := memoryScope(tools.UserFrom(ctx), audit.ActorFrom(ctx).Session)
+	matches, err := al.searchMemories(ctx, agent, message, al.cfg.Memory.TopK, scope)
+	if err != nil {
+		logger.WarnCF("agent", "Could not recall memories", map[string]any{"error": err.Error()})
+		return ""
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	logger.DebugCF("agent", "Recalled memories", map[string]any{
+		"agent_id": agent.ID,
+		"count":    len(matches),
+		"best":     matches[0].Score,
+	})
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## Relevant Memories\n\n")
+	sb.WriteString("Remembered from earlier conversations; use them if they help with this message.\n")
+	for _, match := range matches {
+		fmt.Fprintf(&sb, "\n- (%s, %s) %s", match.Kind, match.Created.Format("2006-01-02"), match.Text)
+	}
+	return sb.String()
+}
+
+// memoryScope keeps the memories a turn of userID in sessionKey may see:
+// notes, which the whole agent shares, facts learned from the same user,
+// and the rest (summaries, facts of turns without a user) from the same
+// session.
+func memoryScope(userID, sessionKey string) func(vectorstore.Record) bool {
+	return func(r vectorstore.Record) bool {
+		if r.Kind == memoryNote {
+			return true
+		}
+		if owner := r.Meta[memoryUserKey]; owner != "" {
+			return owner == userID
+		}
+		return sessionKey != "" && r.Source == sessionKey
+	}
+}
+
+// searchMemories returns up to k of the agent's stored memories closest to
+// query that filter keeps (all when nil), best first.
+func (al *AgentLoop) searchMemories(
+	ctx context.Context,
+	agent *AgentInstance,
+	query string,
+	k int,
+	filter func(vectorstore.Record) bool,
+) ([]vectorstore.Match, error) {
+	store, err := al.memoryStore(agent)
+	if err != nil {
+		return nil, fmt.Errorf("could not open memory store: %w", err)
+	}
+	if store.Len() == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, recallTimeout)
+	defer cancel()
+	vectors, err := al.embed(ctx, []string{truncateMiddle(query, maxRecallInput)})
+	if err != nil {
+		return nil, err
+	}
+	return store.Search(vectors[0], k, al.cfg.Memory.MinScore, filter), nil
+}
+
+// registerRecallTool gives each agent recall_memory, to search its memory
+// mid-turn beyond what was recalled for the user's message.
+func (al *AgentLoop) registerRecallTool() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		search := func(ctx context.Context, query, kind string, limit int) ([]vectorstore.Match, error) {
+			scope := memoryScope(tools.UserFrom(ctx), audit.ActorFrom(ctx).Session)
+			filter := func(r vectorstore.Record) bool {
+				return scope(r) && (kind == "" || r.Kind == kind)
+			}
+			return al.searchMemories(ctx, agent, query, limit, filter)
+		}
+		agent.Tools.Register(tools.NewRecallMemoryTool(search, al.cfg.Memory.TopK))
+	}
+}
+
+// rememberTurn extracts lasting facts from a finished turn and stores the
+// new ones. It runs in the background so replies aren't held up.
+func (al *AgentLoop) rememberTurn(agent *AgentInstance, opts processOptions, reply string) {
+	if al.memory == nil || opts.NoHistory {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), extractTimeout)
+		defer cancel()
+
+		facts, err := al.extractFacts(ctx, agent, opts.SessionKey, opts.UserMessage, reply)
+		if err != nil {
+			logger.WarnCF("agent", "Could not extract memories", map[string]any{"error": err.Error()})
+			return
+		}
+		if err := al.storeMemories(ctx, agent, opts.SessionKey, opts.UserID, memoryFact, facts...); err != nil {
+			logger.WarnCF("agent", "Could not store memories", map[string]any{"error": err.Error()})
+		}
+	}()
+}
+
+// rememberSummary stores a session summary so later conversations can
+// recall it.
+func (al *AgentLoop) rememberSummary(ctx context.Context, agent *AgentInstance, sessionKey, summary string) {
+	if al.memory == nil || summary == "" {
+		return
+	}
+	// A session may have several users, so its summary stays with it
+	if err := al.storeMemories(ctx, agent, sessionKey, "", memorySummary, summary); err != nil {
+		logger.WarnCF("agent", "Could not store session summary", map[string]any{"error": err.Error()})
+	}
+}
+
+func (al *AgentLoop) extractFacts(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, message, reply string,
+) ([]string, error) {
+	provider, model := agent.Provider, agent.Model
+	if name := al.cfg.Memory.ExtractModel; name != "" {
+		m := al.memory
+		m.extractOnce.Do(func() {
+			modelCfg, err := al.cfg.GetModelConfig(name)
+			if err != nil {
+				m.extractErr = err
+				return
+			}
+			m.extractProvider, m.extractModel, m.extractErr = providers.CreateProviderFromConfig(modelCfg)
+		})
+		if m.extractErr != nil {
+			return nil, m.extractErr
+		}
+		provider, model = m.extractProvider, m.extractModel
+	}
+
+	prompt := "Below is one exchange between a user and their assistant. List the facts worth remembering " +
+		"for future conversations: who the user is, their preferences, people and things in their life, " +
+		"plans, decisions and commitments. Write each fact as a short standalone sentence. " +
+		"Skip small talk, questions, and anything only useful right now.\n" +
+		"Reply with only a JSON array of strings, [] if there is nothing to remember.\n\n" +
+		"USER: " + truncateMiddle(message, maxRecallInput) + "\n\n" +
+		"ASSISTANT: " + truncateMiddle(reply, maxRecallInput)
+
+	response, err := provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, model,
+		map[string]any{
+			"max_tokens":  512,
+			"temperature": 0.1,
+		})
+	if err != nil {
+		return nil, err
+	}
+	al.recordUsage(agent, sessionKey, response.Usage)
+	return parseFacts(response.Content)
+}
+
+// parseFacts reads the JSON array of facts from a model reply, which may
+// wrap it in prose or a code fence.
+func parseFacts(content string) ([]string, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in %q", content)
+	}
+	var facts []string
+	if err := json.Unmarshal([]byte(content[start:end+1]), &facts); err != nil {
+		return nil, err
+	}
+	kept := facts[:0]
+	for _, f := range facts {
+		if f = strings.TrimSpace(f); f != "" {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
+
+// storeMemories embeds texts and adds the ones not already stored for
+// userID, or for the session when there's no user.
+func (al *AgentLoop) storeMemories(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, userID, kind string,
+	texts ...string,
+) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	store, err := al.memoryStore(agent)
+	if err != nil {
+		return err
+	}
+	inputs := make([]string, len(texts))
+	for i, text := range texts {
+		inputs[i] = truncateMiddle(text, maxRecallInput)
+	}
+	vectors, err := al.embed(ctx, inputs)
+	if err != nil {
+		return err
+	}
+
+	var meta map[string]string
+	if userID != "" {
+		meta = map[string]string{memoryUserKey: userID}
+	}
+	scope := memoryScope(userID, sessionKey)
+	var records []vectorstore.Record
+	for i, text := range texts {
+		if dup := store.Search(vectors[i], 1, duplicateScore, scope); len(dup) > 0 {
+			continue
+		}
+		records = append(records, vectorstore.Record{
+			Kind:   kind,
+			Source: sessionKey,
+			Text:   text,
+			Meta:   meta,
+			Vector: vectors[i],
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	logger.InfoCF("agent", "Stored memories", map[string]any{
+		"agent_id": agent.ID,
+		"kind":     kind,
+		"count":    len(records),
+	})
+	return store.Add(records...)
+}
@@ -0,0 +1,227 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// memoryProvider extracts a fixed list of facts and embeds texts by the
+// topics they mention.
+type memoryProvider struct {
+	facts string
+
+	mu     sync.Mutex
+	system string // system prompt of the last turn
+}
+
+func (p *memoryProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if strings.Contains(messages[0].Content, "facts worth remembering") {
+		return &providers.LLMResponse{Content: p.facts}, nil
+	}
+	p.mu.Lock()
+	p.system = messages[0].Content
+	p.mu.Unlock()
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *memoryProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func (p *memoryProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		v := []float32{0, 0, 0.1}
+		if strings.Contains(text, "tea") {
+			v[0] = 1
+		}
+		if strings.Contains(text, "oslo") {
+			v[1] = 1
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func newMemoryTestLoop(t *testing.T, provider *memoryProvider) *AgentLoop {
+	t.Helper()
+	al := newCommandTestLoop(t, provider)
+	al.cfg.Memory.Enabled = true
+	al.cfg.Memory.TopK = 5
+	al.cfg.Memory.MinScore = 0.5
+	al.memory = newLongTermMemory()
+	al.memory.embedOnce.Do(func() {})
+	al.memory.embedder = provider
+	return al
+}
+
+func TestLongTermMemory_ExtractAndRecall(t *testing.T) {
+	provider := &memoryProvider{
+		facts: "Here you go:\n```json\n[\"The user likes green tea\", \"The user lives in Oslo\", \" \"]\n```",
+	}
+	al := newMemoryTestLoop(t, provider)
+	agent := al.registry.GetDefaultAgent()
+	// The user commandMessage turns run as
+	ctx := tools.WithUser(context.Background(), "test:user1")
+
+	facts, err := al.extractFacts(ctx, agent, "s", "I'm in Oslo, want tea", "Enjoy!")
+	if err != nil {
+		t.Fatalf("extractFacts() error: %v", err)
+	}
+	if len(facts) != 2 {
+		t.Fatalf("facts = %q, want 2 without the blank one", facts)
+	}
+	if err := al.storeMemories(ctx, agent, "s", "test:user1", memoryFact, facts...); err != nil {
+		t.Fatalf("storeMemories() error: %v", err)
+	}
+	// Known facts are not stored twice
+	al.storeMemories(ctx, agent, "s", "test:user1", memoryFact, "User likes tea a lot")
+	store, _ := al.memoryStore(agent)
+	if store.Len() != 2 {
+		t.Errorf("store has %d memories, want 2", store.Len())
+	}
+
+	got := al.recallMemories(ctx, agent, "Which tea should I buy?")
+	if !strings.Contains(got, "## Relevant Memories") || !strings.Contains(got, "likes green tea") {
+		t.Errorf("recallMemories() = %q", got)
+	}
+	if strings.Contains(got, "Oslo") {
+		t.Errorf("unrelated memory recalled: %q", got)
+	}
+	if got := al.recallMemories(ctx, agent, "What's the time?"); got != "" {
+		t.Errorf("recallMemories() for an unrelated message = %q", got)
+	}
+	if got := al.recallMemories(tools.WithUser(context.Background(), "test:user2"), agent, "tea"); got != "" {
+		t.Errorf("recallMemories() for another user = %q", got)
+	}
+
+	helper := testHelper{al: al}
+	helper.executeAndGetResponse(t, ctx, commandMessage("Anything to do in Oslo?"))
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if !strings.Contains(provider.system, "The user lives in Oslo") {
+		t.Error("recalled memory missing from the system prompt")
+	}
+}
+
+func TestRecallMemoryTool(t *testing.T) {
+	provider := &memoryProvider{}
+	al := newMemoryTestLoop(t, provider)
+	al.registerRecallTool()
+	agent := al.registry.GetDefaultAgent()
+	ctx := audit.WithActor(tools.WithUser(context.Background(), "test:user1"), audit.Actor{Session: "s"})
+
+	al.storeMemories(ctx, agent, "s", "test:user1", memoryFact, "The user is flying to Oslo in May")
+	al.storeMemories(ctx, agent, "s", "", memorySummary, "Planned the Oslo trip over tea: hotel booked")
+	al.storeMemories(ctx, agent, "other", "test:user2", memoryFact, "The user is moving to Oslo")
+
+	result := agent.Tools.Execute(ctx, "recall_memory", map[string]any{"query": "travel plans for Oslo"})
+	if result.IsError || !strings.Contains(result.ForLLM, "Found 2 memories") {
+		t.Fatalf("recall_memory = %q", result.ForLLM)
+	}
+	result = agent.Tools.Execute(ctx, "recall_memory", map[string]any{"query": "Oslo", "kind": "summary"})
+	if !strings.Contains(result.ForLLM, "hotel booked") || strings.Contains(result.ForLLM, "flying") {
+		t.Errorf("recall_memory of summaries = %q", result.ForLLM)
+	}
+	// Another user's turn in another session finds only their own fact
+	other := audit.WithActor(tools.WithUser(context.Background(), "test:user2"), audit.Actor{Session: "other"})
+	result = agent.Tools.Execute(other, "recall_memory", map[string]any{"query": "Oslo"})
+	if !strings.Contains(result.ForLLM, "moving to Oslo") || strings.Contains(result.ForLLM, "flying") ||
+		strings.Contains(result.ForLLM, "hotel booked") {
+		t.Errorf("recall_memory for another user = %q", result.ForLLM)
+	}
+	result = agent.Tools.Execute(ctx, "recall_memory", map[string]any{"query": "What is the weather like?"})
+	if !strings.Contains(result.ForLLM, "Nothing in memory") {
+		t.Errorf("recall_memory without a match = %q", result.ForLLM)
+	}
+}
+
+func TestLongTermMemory_SessionSummaries(t *testing.T) {
+	al := newMemoryTestLoop(t, &memoryProvider{})
+	agent := al.registry.GetDefaultAgent()
+	al.rememberSummary(context.Background(), agent, "agent:main:telegram:group:1", "Chose a tea shop for the team")
+
+	inSession := audit.WithActor(context.Background(), audit.Actor{Session: "agent:main:telegram:group:1"})
+	if got := al.recallMemories(inSession, agent, "tea"); !strings.Contains(got, "Chose a tea shop") {
+		t.Errorf("recallMemories() in the session = %q", got)
+	}
+	elsewhere := audit.WithActor(tools.WithUser(context.Background(), "telegram:2"),
+		audit.Actor{Session: "agent:main:telegram:direct:2"})
+	if got := al.recallMemories(elsewhere, agent, "tea"); got != "" {
+		t.Errorf("recallMemories() in another session = %q", got)
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	if facts, err := parseFacts("[]"); err != nil || len(facts) != 0 {
+		t.Errorf("parseFacts([]) = %q, %v", facts, err)
+	}
+	if _, err := parseFacts("Nothing to remember."); err == nil {
+		t.Error("expected an error without a JSON array")
+	}
+}
+
+func TestLongTermMemory_Disabled(t *testing.T) {
+	al := newCommandTestLoop(t, &memoryProvider{})
+	if al.memory != nil {
+		t.Fatal("memory enabled by default")
+	}
+	if got := al.recallMemories(context.Background(), al.registry.GetDefaultAgent(), "tea"); got != "" {
+		t.Errorf("recallMemories() = %q", got)
+	}
+}
+
+func TestLongTermMemory_Notes(t *testing.T) {
+	al := newMemoryTestLoop(t, &memoryProvider{})
+	agent := al.registry.GetDefaultAgent()
+	registerNotesTool(al.cfg, agent)
+	ctx := context.Background()
+
+	note, err := agent.Notes.Create("Tea shops", "Try the oolong at Tea Corner.", []string{"shopping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := al.syncAgentNotes(ctx, agent); err != nil {
+		t.Fatalf("syncAgentNotes() error: %v", err)
+	}
+	got := al.recallMemories(ctx, agent, "Where can I buy tea?")
+	if !strings.Contains(got, "(note, ") || !strings.Contains(got, "Tea shops (notes id tea-shops): Try the oolong") {
+		t.Errorf("recallMemories() = %q", got)
+	}
+
+	// Saving again replaces the note's memory
+	note.Body = "Tea Corner closed; try Leaf & Cup."
+	agent.Notes.Update(note)
+	if err := al.rememberNote(ctx, agent, note); err != nil {
+		t.Fatalf("rememberNote() error: %v", err)
+	}
+	store, _ := al.memoryStore(agent)
+	if store.Len() != 1 || !strings.Contains(al.recallMemories(ctx, agent, "tea"), "Leaf & Cup") {
+		t.Errorf("store has %d memories after the update", store.Len())
+	}
+
+	// Unchanged notes are not embedded again, and removed ones are forgotten
+	al.syncAgentNotes(ctx, agent)
+	if store.Len() != 1 {
+		t.Errorf("store has %d memories after a sync, want 1", store.Len())
+	}
+	os.Remove(agent.Notes.Path(note.ID))
+	al.syncAgentNotes(ctx, agent)
+	if store.Len() != 0 {
+		t.Errorf("store has %d memories after the note was removed", store.Len())
+	}
+}
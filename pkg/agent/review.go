@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// maxReviewEvidence bounds each tool result shown to the reviewer.
+	maxReviewEvidence = 2000
+	// reviewApproved is the reviewer's reply to a draft it finds no fault with.
+	reviewApproved = "APPROVED"
+)
+
+// reviewer checks draft replies before they are sent (config
+// agents.review), with its own model when one is configured.
+type reviewer struct {
+	once     sync.Once
+	provider providers.LLMProvider
+	model    string
+	err      error
+}
+
+// critique asks the reviewer about draft, the agent's answer to the user
+// message in messages, and returns what is wrong with it, or "" when it
+// passes. A failed review lets the draft through.
+func (al *AgentLoop) critique(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	messages []providers.Message,
+	draft string,
+) string {
+	provider, model := agent.Provider, agent.Model
+	if name := al.cfg.Agents.Review.Model; name != "" {
+		r := al.reviewer
+		r.once.Do(func() {
+			modelCfg, err := al.cfg.GetModelConfig(name)
+			if err != nil {
+				r.err = err
+				return
+			}
+			r.provider, r.model, r.err = providers.CreateProviderFromConfig(modelCfg)
+		})
+		if r.err != nil {
+			logger.WarnCF("agent", "Review model unavailable", map[string]any{"model": name, "error": r.err.Error()})
+			return ""
+		}
+		provider, model = r.provider, r.model
+	}
+
+	prompt := "You review an assistant's draft reply before it is sent. Check that it answers what the user " +
+		"asked, that what it states is backed by the tool results or is common knowledge, and that it " +
+		"doesn't claim actions the tools didn't take. Ignore style.\n" +
+		"If the draft is fine, reply with only " + reviewApproved + ". Otherwise list the problems briefly.\n\n" +
+		"USER: " + truncateMiddle(opts.UserMessage, maxRecallInput) + "\n\n" +
+		"TOOL RESULTS:\n" + turnEvidence(messages, opts.UserMessage) + "\n\n" +
+		"DRAFT: " + truncateMiddle(draft, maxRecallInput)
+
+	response, err := provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, model,
+		map[string]any{
+			"max_tokens":  512,
+			"temperature": 0.1,
+		})
+	if err != nil {
+		logger.WarnCF("agent", "Review failed, sending the draft", map[string]any{"error": err.Error()})
+		return ""
+	}
+	al.recordTurnUsage(agent, opts, response.Usage)
+
+	verdict := strings.TrimSpace(response.Content)
+	if verdict == "" || strings.HasPrefix(strings.ToUpper(verdict), reviewApproved) {
+		return ""
+	}
+	logger.InfoCF("agent", "Reviewer asked for a revision",
+		map[string]any{"agent_id": agent.ID, "critique": truncateMiddle(verdict, 200)})
+	return verdict
+}
+
+// turnEvidence lists the tool calls and results that follow the user's
+// message in messages, or "(none)" when the turn used no tools.
+func turnEvidence(messages []providers.Message, userMessage string) string {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" && messages[i].ToolCallID == "" && messages[i].Content == userMessage {
+			start = i + 1
+			break
+		}
+	}
+
+	var sb strings.Builder
+	for _, msg := range messages[start:] {
+		switch {
+		case msg.Role == "assistant":
+			for _, tc := range msg.ToolCalls {
+				args, _ := json.Marshal(tc.Arguments)
+				if tc.Function != nil && tc.Function.Arguments != "" {
+					args = []byte(tc.Function.Arguments)
+				}
+				fmt.Fprintf(&sb, "CALL %s %s\n", tc.Name, args)
+			}
+		case msg.Role == "tool":
+			fmt.Fprintf(&sb, "RESULT %s\n", truncateMiddle(msg.Content, maxReviewEvidence))
+		}
+	}
+	if sb.Len() == 0 {
+		return "(none)"
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// revisionRequest tells the agent what the reviewer found in its draft.
+func revisionRequest(critique string) string {
+	return "[A reviewer checked your reply against my message and the tool results and found these " +
+		"problems:\n" + critique + "\nWrite a corrected reply, using tools if something needs checking. " +
+		"Don't mention the review.]"
+}
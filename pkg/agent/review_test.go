@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// reviewedProvider drafts a wrong answer, plays the reviewer when asked,
+// and fixes the answer once it sees the critique
+type reviewedProvider struct {
+	verdict  string
+	reviews  int
+	reviewed string // the reviewer's prompt
+}
+
+func (p *reviewedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	switch {
+	case strings.HasPrefix(last.Content, "You review an assistant's draft"):
+		p.reviews++
+		p.reviewed = last.Content
+		return &providers.LLMResponse{Content: p.verdict}, nil
+	case strings.HasPrefix(last.Content, "[A reviewer checked your reply"):
+		return &providers.LLMResponse{Content: "The flight leaves at 9:40."}, nil
+	}
+	return &providers.LLMResponse{Content: "The flight leaves at 7:15."}, nil
+}
+
+func (p *reviewedProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRunLLMIteration_Review(t *testing.T) {
+	tests := []struct {
+		name        string
+		channels    []string
+		verdict     string
+		wantReviews int
+		want        string
+	}{
+		{"faulted draft is revised", nil, "The draft gives the wrong time.", 1, "The flight leaves at 9:40."},
+		{"approved draft is sent", []string{"test:c1"}, "APPROVED", 1, "The flight leaves at 7:15."},
+		{"other channels are not reviewed", []string{"telegram"}, "Wrong time.", 0, "The flight leaves at 7:15."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Agents: config.AgentsConfig{
+					Defaults: config.AgentDefaults{
+						Workspace:         t.TempDir(),
+						Model:             "test-model",
+						MaxTokens:         4096,
+						MaxToolIterations: 10,
+					},
+					Review: config.ReviewConfig{Enabled: true, Channels: tt.channels},
+				},
+			}
+			provider := &reviewedProvider{verdict: tt.verdict}
+			al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+			response := testHelper{al: al}.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+				Channel:    "test",
+				SenderID:   "u1",
+				ChatID:     "c1",
+				Content:    "when does my flight leave?",
+				SessionKey: "agent:main:test-review",
+			})
+			if response != tt.want {
+				t.Errorf("response = %q, want %q", response, tt.want)
+			}
+			if provider.reviews != tt.wantReviews {
+				t.Errorf("reviews = %d, want %d", provider.reviews, tt.wantReviews)
+			}
+			if tt.wantReviews > 0 && !strings.Contains(provider.reviewed, "USER: when does my flight leave?") {
+				t.Errorf("review prompt lacks the question:\n%s", provider.reviewed)
+			}
+		})
+	}
+}
+
+func TestTurnEvidence(t *testing.T) {
+	messages := []providers.Message{
+		{Role: "system", Content: "You are picoclaw"},
+		{Role: "user", Content: "when does my flight leave?"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{
+			{Name: "read_file", Arguments: map[string]any{"path": "trips.md"}},
+		}},
+		{Role: "tool", Content: "LH 123 departs 9:40", ToolCallID: "call_1"},
+	}
+	want := "CALL read_file {\"path\":\"trips.md\"}\nRESULT LH 123 departs 9:40"
+	if got := turnEvidence(messages, "when does my flight leave?"); got != want {
+		t.Errorf("turnEvidence = %q, want %q", got, want)
+	}
+	if got := turnEvidence(messages[:2], "when does my flight leave?"); got != "(none)" {
+		t.Errorf("turnEvidence without tools = %q", got)
+	}
+}
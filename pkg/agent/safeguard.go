@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// wrapUpPrompt asks the model to hand a stopped turn back to the user. The
+// reason is why the turn was stopped.
+const wrapUpPrompt = "[The turn was stopped because %s. Don't call any more tools. " +
+	"In the user's language, briefly tell the user what you were trying to do, what you tried " +
+	"and what you found so far, then ask how they would like to proceed.]"
+
+// wrapUp ends a turn that a safeguard stopped with one more request to the
+// turn's model, so the user hears what the agent attempted instead of
+// nothing. The tools are still offered since some providers reject tool
+// calls in a history without them, but any the model asks for are ignored.
+// fallback is the reply when the request fails.
+func (al *AgentLoop) wrapUp(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	messages []providers.Message,
+	reason, fallback string,
+) string {
+	messages = append(messages, providers.Message{Role: "user", Content: fmt.Sprintf(wrapUpPrompt, reason)})
+	toolDefs := opts.Persona.filterTools(agent.Tools.ToProviderDefsFor(opts.Channel, opts.ChatID))
+	response, err := al.callLLM(ctx, agent, opts, messages, toolDefs)
+	if err != nil {
+		logger.WarnCF("agent", "Wrap-up after a stopped turn failed",
+			map[string]any{"agent_id": agent.ID, "error": err.Error()})
+		return fallback
+	}
+	al.recordTurnUsage(agent, opts, response.Usage)
+	if strings.TrimSpace(response.Content) == "" {
+		return fallback
+	}
+	return response.Content
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// loopingProvider keeps calling poll_tool, with new arguments each time
+// when vary is set, until it is asked to wrap up
+type loopingProvider struct {
+	vary   bool
+	rounds int
+	prompt string
+}
+
+func (p *loopingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if last := messages[len(messages)-1]; strings.HasPrefix(last.Content, "[The turn was stopped") {
+		p.prompt = last.Content
+		return &providers.LLMResponse{Content: "I kept polling the build. Should I keep waiting?"}, nil
+	}
+	p.rounds++
+	args := map[string]any{"job": "build"}
+	if p.vary {
+		args["attempt"] = p.rounds
+	}
+	return &providers.LLMResponse{
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "poll_tool", Arguments: args}},
+	}, nil
+}
+
+func (p *loopingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+type pollTool struct {
+	runs int
+}
+
+func (t *pollTool) Name() string               { return "poll_tool" }
+func (t *pollTool) Description() string        { return "Polls a job" }
+func (t *pollTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (t *pollTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	t.runs++
+	return tools.NewToolResult("still running")
+}
+
+func TestRunLLMIteration_Safeguards(t *testing.T) {
+	tests := []struct {
+		name       string
+		vary       bool
+		wantRuns   int
+		wantReason string
+	}{
+		{"repeated call", false, 3, "you called poll_tool with the same arguments 3 times"},
+		{"iteration limit", true, 5, "you used all 5 rounds of tool calls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Agents: config.AgentsConfig{
+					Defaults: config.AgentDefaults{
+						Workspace:         t.TempDir(),
+						Model:             "test-model",
+						MaxTokens:         4096,
+						MaxToolIterations: 5,
+					},
+				},
+			}
+			provider := &loopingProvider{vary: tt.vary}
+			al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+			tool := &pollTool{}
+			al.RegisterTool(tool)
+
+			response := testHelper{al: al}.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+				Channel:    "test",
+				SenderID:   "u1",
+				ChatID:     "c1",
+				Content:    "wait for the build to finish",
+				SessionKey: "agent:main:test-safeguards",
+			})
+
+			if tool.runs != tt.wantRuns {
+				t.Errorf("tool ran %d times, want %d", tool.runs, tt.wantRuns)
+			}
+			if !strings.Contains(provider.prompt, tt.wantReason) {
+				t.Errorf("wrap-up prompt = %q, want it to mention %q", provider.prompt, tt.wantReason)
+			}
+			if response != "I kept polling the build. Should I keep waiting?" {
+				t.Errorf("response = %q", response)
+			}
+		})
+	}
+}
+
+func TestWrapUp_FallsBackWhenTheModelFails(t *testing.T) {
+	al := newCommandTestLoop(t, &failFirstMockProvider{failures: 1, failError: context.DeadlineExceeded})
+	agent := al.registry.GetDefaultAgent()
+	got := al.wrapUp(context.Background(), agent, processOptions{Channel: "test"}, nil, "testing", "fallback")
+	if got != "fallback" {
+		t.Errorf("wrapUp = %q, want the fallback", got)
+	}
+}
+
+func TestWrapUp_UsesThePersonaModel(t *testing.T) {
+	al := newCommandTestLoop(t, &failFirstMockProvider{failures: 1, failError: context.DeadlineExceeded})
+	agent := al.registry.GetDefaultAgent()
+	opts := processOptions{
+		Channel: "test",
+		Persona: &persona{name: "tutor", provider: &simpleMockProvider{response: "Here is where I got to."}},
+	}
+	got := al.wrapUp(context.Background(), agent, opts, nil, "testing", "fallback")
+	if got != "Here is where I got to." {
+		t.Errorf("wrapUp = %q, want the persona's model to answer", got)
+	}
+}
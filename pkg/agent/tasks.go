@@ -0,0 +1,422 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// States of a background task.
+const (
+	taskQueued    = "queued"
+	taskRunning   = "running"
+	taskDone      = "done"
+	taskFailed    = "failed"
+	taskCancelled = "cancelled"
+)
+
+// maxFinishedTasks is how many ended tasks are kept for /tasks.
+const maxFinishedTasks = 50
+
+// taskSessionMarker is in the session key of every task's own session.
+const taskSessionMarker = ":task:"
+
+// backgroundTask is a job the agent runs outside the conversation that
+// started it, in a session of its own, reporting to the chat.
+type backgroundTask struct {
+	id       int
+	label    string
+	task     string
+	agentID  string
+	origin   string // session key of the conversation that started it
+	senderID string
+	userID   string
+	channel  string
+	chatID   string
+	created  time.Time
+	run      *activeRun // tracks its tools, and cancels it
+
+	// Guarded by taskQueue.mu
+	status   string
+	started  time.Time
+	finished time.Time
+	result   string
+}
+
+// taskQueue runs background tasks (config tools.tasks), MaxConcurrent at a
+// time; the others wait their turn. It implements tools.TaskQueue.
+type taskQueue struct {
+	al    *AgentLoop
+	cfg   config.BackgroundTasksConfig
+	slots chan struct{}
+	ctx   context.Context // ends when the agent stops
+	stop  context.CancelFunc
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tasks  []*backgroundTask // oldest first
+	nextID int
+}
+
+func newTaskQueue(al *AgentLoop) *taskQueue {
+	cfg := al.cfg.Tools.Tasks
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.MaxQueued <= 0 {
+		cfg.MaxQueued = 10
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &taskQueue{
+		al:     al,
+		cfg:    cfg,
+		slots:  make(chan struct{}, cfg.MaxConcurrent),
+		ctx:    ctx,
+		stop:   stop,
+		now:    time.Now,
+		nextID: 1,
+	}
+}
+
+// registerTaskTool gives each agent background_task, backed by the queue.
+func (al *AgentLoop) registerTaskTool() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Tools.Register(tools.NewBackgroundTaskTool(al.tasks))
+		}
+	}
+}
+
+// Submit queues task for the chat, to run as the agent and user of the turn
+// in ctx. It starts right away when a slot is free.
+func (q *taskQueue) Submit(ctx context.Context, task, label, channel, chatID string) (string, error) {
+	actor := audit.ActorFrom(ctx)
+	if strings.Contains(actor.Session, taskSessionMarker) {
+		return "", errors.New("a background task can't start another one; do the work here")
+	}
+	if channel == "" || chatID == "" {
+		return "", errors.New("no chat to report to; use this tool in an active conversation")
+	}
+	agent, ok := q.al.registry.GetAgent(actor.Agent)
+	if !ok {
+		agent = q.al.registry.GetDefaultAgent()
+	}
+	if label == "" {
+		label = utils.Truncate(task, 40)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiting := 0
+	for _, t := range q.tasks {
+		if t.status == taskQueued {
+			waiting++
+		}
+	}
+	if waiting >= q.cfg.MaxQueued {
+		return "", fmt.Errorf("%d tasks are already waiting; let one finish or cancel one first", waiting)
+	}
+
+	taskCtx, cancel := context.WithCancel(q.ctx)
+	t := &backgroundTask{
+		id:       q.nextID,
+		label:    label,
+		task:     task,
+		agentID:  agent.ID,
+		origin:   actor.Session,
+		senderID: actor.Sender,
+		userID:   q.al.userID(bus.InboundMessage{Channel: channel, SenderID: actor.Sender}),
+		channel:  channel,
+		chatID:   chatID,
+		created:  q.now(),
+		run:      &activeRun{cancel: cancel},
+		status:   taskQueued,
+	}
+	q.nextID++
+	select {
+	case q.slots <- struct{}{}:
+		t.status, t.started = taskRunning, t.created
+	default:
+	}
+	q.tasks = append(q.tasks, t)
+	q.pruneLocked()
+	go q.execute(taskCtx, agent, t)
+
+	if t.status == taskRunning {
+		return fmt.Sprintf("Started task %d (%s). The user will get progress updates and the result in this "+
+			"chat; /tasks lists tasks and /tasks cancel %d stops it.", t.id, t.label, t.id), nil
+	}
+	return fmt.Sprintf("Queued task %d (%s) behind %d other task(s); it starts when one finishes. The user "+
+		"will get progress updates and the result in this chat.", t.id, t.label, waiting), nil
+}
+
+// execute runs t once it has a slot, and tells the chat how it ended.
+func (q *taskQueue) execute(ctx context.Context, agent *AgentInstance, t *backgroundTask) {
+	defer t.run.cancel()
+
+	q.mu.Lock()
+	queued := t.status == taskQueued
+	q.mu.Unlock()
+	if queued {
+		select {
+		case q.slots <- struct{}{}:
+		case <-ctx.Done():
+			q.finish(t, taskCancelled, "")
+			q.notify(t, fmt.Sprintf("Task %d (%s) was cancelled before it started.", t.id, t.label))
+			return
+		}
+		q.mu.Lock()
+		t.status, t.started = taskRunning, q.now()
+		q.mu.Unlock()
+		q.notify(t, fmt.Sprintf("Started task %d (%s).", t.id, t.label))
+	}
+	defer func() { <-q.slots }()
+
+	// The task works under the settings of the conversation it came from
+	sessionKey := fmt.Sprintf("%s%s%d", t.origin, taskSessionMarker, t.created.UnixMilli())
+	if on, set := agent.Sessions.DryRun(t.origin); set {
+		agent.Sessions.SetDryRun(sessionKey, on)
+	}
+	agent.Sessions.SetPersona(sessionKey, agent.Sessions.Persona(t.origin))
+
+	stopProgress := q.reportProgress(t)
+	response, err := q.al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         t.channel,
+		ChatID:          t.chatID,
+		SenderID:        t.senderID,
+		UserID:          t.userID,
+		UserMessage:     taskPrompt(t),
+		DefaultResponse: "The task finished without a result.",
+		Run:             t.run,
+	})
+	stopProgress()
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		q.finish(t, taskCancelled, "")
+		if q.ctx.Err() == nil {
+			q.notify(t, fmt.Sprintf("Task %d (%s): %s", t.id, t.label, t.run.report()))
+		}
+	case err != nil:
+		q.finish(t, taskFailed, err.Error())
+		q.notify(t, fmt.Sprintf("Task %d (%s) failed: %v", t.id, t.label, err))
+	default:
+		q.finish(t, taskDone, response)
+		q.notify(t, fmt.Sprintf("Task %d (%s) is done.\n\n%s", t.id, t.label, response))
+	}
+}
+
+// taskPrompt is the message a task's run starts from. Nobody answers in a
+// task's session, so the model is told to finish on its own.
+func taskPrompt(t *backgroundTask) string {
+	return fmt.Sprintf("[Background task %d] Work on the task below on your own, in as many steps as it takes. "+
+		"Nobody can answer questions until it is done, so make reasonable assumptions. Your final reply is "+
+		"sent to the user as the result, so make it complete.\n\nTask: %s", t.id, t.task)
+}
+
+// reportProgress tells the chat what t is doing every ProgressSeconds, when
+// it has done something since the last update. It returns a function that
+// stops the updates.
+func (q *taskQueue) reportProgress(t *backgroundTask) func() {
+	if q.cfg.ProgressSeconds <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(q.cfg.ProgressSeconds) * time.Second)
+		defer ticker.Stop()
+		last := ""
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if update, state := q.progress(t); state != last {
+				last = state
+				q.notify(t, update)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// progress describes what running task t has done so far, and returns a
+// state that changes only when it gets further.
+func (q *taskQueue) progress(t *backgroundTask) (update, state string) {
+	q.mu.Lock()
+	elapsed := q.now().Sub(t.started).Round(time.Second)
+	q.mu.Unlock()
+
+	r := t.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Task %d (%s) is still running after %s", t.id, t.label, elapsed)
+	if r.running != "" {
+		sb.WriteString(", now " + r.running)
+	}
+	if len(r.done) > 0 {
+		fmt.Fprintf(&sb, ". Done so far: %d step(s), last %s", len(r.done), r.done[len(r.done)-1])
+	}
+	return sb.String() + ".", fmt.Sprintf("%d %s", len(r.done), r.running)
+}
+
+// finish records how t ended.
+func (q *taskQueue) finish(t *backgroundTask, status, result string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t.status, t.result, t.finished = status, result, q.now()
+}
+
+// notify sends content to t's chat.
+func (q *taskQueue) notify(t *backgroundTask, content string) {
+	if err := q.al.bus.Notify(q.ctx, t.channel, t.chatID, content); err != nil {
+		logger.WarnCF("agent", "Failed to send a background task update", map[string]any{
+			"task":    t.id,
+			"channel": t.channel,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// pruneLocked drops the oldest ended tasks beyond maxFinishedTasks. The
+// caller holds q.mu.
+func (q *taskQueue) pruneLocked() {
+	finished := 0
+	for _, t := range q.tasks {
+		if !t.finished.IsZero() {
+			finished++
+		}
+	}
+	q.tasks = slices.DeleteFunc(q.tasks, func(t *backgroundTask) bool {
+		if finished > maxFinishedTasks && !t.finished.IsZero() {
+			finished--
+			return true
+		}
+		return false
+	})
+}
+
+// chatTasksLocked returns the tasks of a chat, newest first. The caller holds q.mu.
+func (q *taskQueue) chatTasksLocked(channel, chatID string) []*backgroundTask {
+	var found []*backgroundTask
+	for i := len(q.tasks) - 1; i >= 0; i-- {
+		if t := q.tasks[i]; t.channel == channel && t.chatID == chatID {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// List describes the chat's tasks, newest first.
+func (q *taskQueue) List(channel, chatID string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	found := q.chatTasksLocked(channel, chatID)
+	if len(found) == 0 {
+		return "No background tasks in this chat."
+	}
+	var sb strings.Builder
+	sb.WriteString("Background tasks:")
+	now := q.now()
+	for _, t := range found {
+		fmt.Fprintf(&sb, "\n- %d %s: %s", t.id, t.label, t.status)
+		switch {
+		case t.status == taskRunning:
+			fmt.Fprintf(&sb, " for %s", now.Sub(t.started).Round(time.Second))
+		case !t.finished.IsZero():
+			fmt.Fprintf(&sb, " at %s", t.finished.Format("15:04"))
+		}
+	}
+	return sb.String()
+}
+
+// Cancel stops one of the chat's tasks.
+func (q *taskQueue) Cancel(channel, chatID, id string) (string, error) {
+	t, err := q.find(channel, chatID, id)
+	if err != nil {
+		return "", err
+	}
+	q.mu.Lock()
+	status := t.status
+	q.mu.Unlock()
+	if status != taskQueued && status != taskRunning {
+		return "", fmt.Errorf("task %d has already ended (%s)", t.id, status)
+	}
+	t.run.cancel()
+	return fmt.Sprintf("Cancelling task %d (%s).", t.id, t.label), nil
+}
+
+// find returns the chat's task with the given ID.
+func (q *taskQueue) find(channel, chatID, id string) (*backgroundTask, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a task ID", id)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, t := range q.chatTasksLocked(channel, chatID) {
+		if t.id == n {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no task %d in this chat", n)
+}
+
+// describe shows one task in full, with its result once it has one.
+func (q *taskQueue) describe(t *backgroundTask) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Task %d (%s): %s\nSubmitted %s", t.id, t.label, t.status, t.created.Format("2006-01-02 15:04"))
+	if !t.finished.IsZero() {
+		fmt.Fprintf(&sb, ", ended %s", t.finished.Format("15:04"))
+	}
+	sb.WriteString("\n\n" + t.task)
+	if t.result != "" {
+		sb.WriteString("\n\nResult:\n" + t.result)
+	}
+	return sb.String()
+}
+
+// tasksCommand lists the chat's background tasks, shows one, or cancels one.
+func (al *AgentLoop) tasksCommand(ctx context.Context, req commands.Request) string {
+	if al.tasks == nil {
+		return "Background tasks are disabled (tools.tasks.enabled in the config)."
+	}
+	channel, chatID := req.Message.Channel, req.Message.ChatID
+	switch {
+	case len(req.Args) == 0:
+		return al.tasks.List(channel, chatID)
+	case req.Args[0] == "cancel" || req.Args[0] == "stop":
+		if len(req.Args) < 2 {
+			return "Usage: /tasks cancel <id>"
+		}
+		reply, err := al.tasks.Cancel(channel, chatID, strings.TrimPrefix(req.Args[1], "#"))
+		if err != nil {
+			return err.Error()
+		}
+		return reply
+	default:
+		t, err := al.tasks.find(channel, chatID, strings.TrimPrefix(req.Args[0], "#"))
+		if err != nil {
+			return err.Error()
+		}
+		return al.tasks.describe(t)
+	}
+}
@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// taskProvider answers at once, except for tasks that ask it to wait, which
+// it holds until they are cancelled.
+type taskProvider struct{}
+
+func (p *taskProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if strings.Contains(messages[len(messages)-1].Content, "wait forever") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &providers.LLMResponse{Content: "Here is the report."}, nil
+}
+
+func (p *taskProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+// nextOutbound returns the next message for the chat, failing after a while.
+func nextOutbound(t *testing.T, msgBus *bus.MessageBus) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no message sent to the chat")
+	}
+	return msg.Content
+}
+
+func TestBackgroundTasks(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{
+			Tasks: config.BackgroundTasksConfig{Enabled: true, MaxConcurrent: 1, MaxQueued: 1},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &taskProvider{})
+	agent := al.registry.GetDefaultAgent()
+	helper := testHelper{al: al}
+	ctx := audit.WithActor(context.Background(),
+		audit.Actor{Agent: agent.ID, Session: "agent:main:commands", Sender: "user1"})
+
+	start := func(task string) string {
+		result := agent.Tools.ExecuteWithContext(ctx, "background_task",
+			map[string]any{"task": task}, "test", "chat1", nil)
+		if result.IsError {
+			t.Fatalf("start %q: %s", task, result.ForLLM)
+		}
+		return result.ForLLM
+	}
+	if got := start("Research it, wait forever"); !strings.Contains(got, "Started task 1") {
+		t.Errorf("first task = %q", got)
+	}
+	if got := start("Write the report"); !strings.Contains(got, "Queued task 2") {
+		t.Errorf("second task = %q", got)
+	}
+	if result := agent.Tools.ExecuteWithContext(ctx, "background_task",
+		map[string]any{"task": "one too many"}, "test", "chat1", nil); !result.IsError {
+		t.Error("task beyond max_queued was accepted")
+	}
+
+	list := helper.executeAndGetResponse(t, ctx, commandMessage("/tasks"))
+	if !strings.Contains(list, "1 Research it, wait forever: running") ||
+		!strings.Contains(list, "2 Write the report: queued") {
+		t.Errorf("/tasks = %q", list)
+	}
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/tasks cancel 1")); !strings.Contains(got,
+		"Cancelling") {
+		t.Errorf("/tasks cancel 1 = %q", got)
+	}
+
+	// The first task reports it stopped, then the second runs to the end
+	if got := nextOutbound(t, msgBus); !strings.Contains(got, "Task 1") || !strings.Contains(got, "Cancelled") {
+		t.Errorf("cancel notice = %q", got)
+	}
+	if got := nextOutbound(t, msgBus); got != "Started task 2 (Write the report)." {
+		t.Errorf("start notice = %q", got)
+	}
+	if got := nextOutbound(t, msgBus); !strings.Contains(got, "is done.\n\nHere is the report.") {
+		t.Errorf("result = %q", got)
+	}
+
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/tasks 2")); !strings.Contains(got, "Result:\n"+
+		"Here is the report.") {
+		t.Errorf("/tasks 2 = %q", got)
+	}
+	if got := helper.executeAndGetResponse(t, ctx, commandMessage("/tasks cancel 2")); !strings.Contains(got, "ended") {
+		t.Errorf("/tasks cancel on a finished task = %q", got)
+	}
+	other := commandMessage("/tasks 1")
+	other.ChatID = "chat2"
+	if got := helper.executeAndGetResponse(t, ctx, other); !strings.Contains(got, "no task 1") {
+		t.Errorf("/tasks from another chat = %q", got)
+	}
+}
+
+func TestBackgroundTasks_InheritDryRun(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{
+			Tasks: config.BackgroundTasksConfig{Enabled: true, MaxConcurrent: 1, MaxQueued: 1},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &toolCallProvider{})
+	agent := al.registry.GetDefaultAgent()
+	tool := &approvalTool{}
+	al.RegisterTool(tool)
+	helper := testHelper{al: al}
+	ctx := audit.WithActor(context.Background(),
+		audit.Actor{Agent: agent.ID, Session: "agent:main:commands", Sender: "user1"})
+
+	helper.executeAndGetResponse(t, ctx, commandMessage("/dryrun on"))
+	result := agent.Tools.ExecuteWithContext(ctx, "background_task",
+		map[string]any{"task": "Run the tool"}, "test", "chat1", nil)
+	if result.IsError {
+		t.Fatalf("background_task: %s", result.ForLLM)
+	}
+
+	got := nextOutbound(t, msgBus)
+	if tool.runs.Load() != 0 || !strings.Contains(got, "[dry run, nothing was done]") {
+		t.Errorf("task in a dry-run session: %d runs, result %q", tool.runs.Load(), got)
+	}
+}
+
+// chatterProvider has a background task message its chat while a turn from
+// another chat is under way, and lets that turn answer once it has.
+type chatterProvider struct {
+	turnStarted chan struct{}
+	taskSent    chan struct{}
+	once        sync.Once
+}
+
+func (p *chatterProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	switch {
+	case last.Role == "tool":
+		close(p.taskSent)
+		return &providers.LLMResponse{Content: "Posted."}, nil
+	case strings.Contains(last.Content, "post an update"):
+		select {
+		case <-p.turnStarted:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{
+			{ID: "call_1", Name: "message", Arguments: map[string]any{"content": "Halfway there."}},
+		}}, nil
+	default:
+		p.once.Do(func() { close(p.turnStarted) })
+		select {
+		case <-p.taskSent:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &providers.LLMResponse{Content: "Hello, chat 2."}, nil
+	}
+}
+
+func (p *chatterProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestBackgroundTasks_ConcurrentTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{
+			Tasks: config.BackgroundTasksConfig{Enabled: true, MaxConcurrent: 1, MaxQueued: 1},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	provider := &chatterProvider{turnStarted: make(chan struct{}), taskSent: make(chan struct{})}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	agent := al.registry.GetDefaultAgent()
+	ctx := audit.WithActor(context.Background(),
+		audit.Actor{Agent: agent.ID, Session: "agent:main:commands", Sender: "user1"})
+
+	// The task from chat1 messages its chat while chat2's turn is running
+	if result := agent.Tools.ExecuteWithContext(ctx, "background_task",
+		map[string]any{"task": "post an update"}, "test", "chat1", nil); result.IsError {
+		t.Fatalf("start: %s", result.ForLLM)
+	}
+	msg := commandMessage("hello")
+	msg.ChatID, msg.SessionKey = "chat2", "agent:main:chat2"
+	turnCtx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	go al.handleInbound(turnCtx, msg)
+
+	chats := make(map[string]string)
+	for range 3 {
+		waitCtx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+		out, ok := msgBus.SubscribeOutbound(waitCtx)
+		cancel()
+		if !ok {
+			t.Fatalf("only got %v", chats)
+		}
+		chats[out.Content] = out.ChatID
+	}
+	if chats["Halfway there."] != "chat1" {
+		t.Errorf("task's message went to %q, want chat1", chats["Halfway there."])
+	}
+	if chats["Hello, chat 2."] != "chat2" {
+		t.Errorf("chat2's reply went to %q, want chat2 (all: %v)", chats["Hello, chat 2."], chats)
+	}
+}
+
+func TestTaskProgress(t *testing.T) {
+	q := &taskQueue{now: time.Now}
+	task := &backgroundTask{id: 3, label: "research", run: &activeRun{}, started: time.Now().Add(-90 * time.Second)}
+	_, before := q.progress(task)
+	task.run.toolStarted("web_search", map[string]any{"query": "solar panels"})
+	update, after := q.progress(task)
+	if update != "Task 3 (research) is still running after 1m30s, now web_search (solar panels)." {
+		t.Errorf("progress() = %q", update)
+	}
+	if before == after {
+		t.Error("progress state did not change when a tool started")
+	}
+	task.run.toolFinished(false)
+	if update, _ := q.progress(task); !strings.Contains(update, "Done so far: 1 step(s), last web_search") {
+		t.Errorf("progress() = %q", update)
+	}
+}
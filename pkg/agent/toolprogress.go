@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// toolProgressInterval spaces out the updates of a running tool; channels
+	// throttle edits further on their own.
+	toolProgressInterval = time.Second
+	// toolProgressLines is how many of the latest lines an update shows.
+	toolProgressLines = 8
+)
+
+// toolProgress relays what a running tool reports, such as the output of a
+// long command, to the chat as progress updates showing its latest lines.
+type toolProgress struct {
+	publish func(content string)
+	tool    string
+	now     func() time.Time
+
+	mu      sync.Mutex
+	lines   []string
+	sent    time.Time
+	timer   *time.Timer
+	stopped bool
+}
+
+// withToolProgress returns the context to run tool in, which relays its
+// progress to the chat, and a function to call when it returns. Runs that
+// don't send progress get ctx back.
+func (al *AgentLoop) withToolProgress(ctx context.Context, opts processOptions, tool string) (context.Context, func()) {
+	if !opts.SendProgress || constants.IsInternalChannel(opts.Channel) {
+		return ctx, func() {}
+	}
+	p := &toolProgress{
+		tool: tool,
+		now:  time.Now,
+		publish: func(content string) {
+			al.bus.PublishOutbound(bus.OutboundMessage{
+				Channel:  opts.Channel,
+				ChatID:   opts.ChatID,
+				Content:  content,
+				Progress: true,
+			})
+		},
+	}
+	return tools.WithProgress(ctx, p.report), p.stop
+}
+
+func (p *toolProgress) report(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.lines = append(p.lines, utils.Truncate(line, 200))
+	if len(p.lines) > toolProgressLines {
+		p.lines = p.lines[len(p.lines)-toolProgressLines:]
+	}
+
+	if wait := toolProgressInterval - p.now().Sub(p.sent); wait > 0 {
+		// Show the latest lines once the interval is up, even if the tool
+		// goes quiet until then
+		if p.timer == nil {
+			p.timer = time.AfterFunc(wait, p.flush)
+		}
+		return
+	}
+	p.sendLocked()
+}
+
+func (p *toolProgress) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil && !p.stopped {
+		p.sendLocked()
+	}
+}
+
+// sendLocked publishes the latest lines. The caller holds p.mu.
+func (p *toolProgress) sendLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.sent = p.now()
+	p.publish(p.tool + ":\n" + strings.Join(p.lines, "\n"))
+}
+
+// stop ends the updates once the tool has returned.
+func (p *toolProgress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestToolProgress_Throttles(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	now := time.Unix(0, 0)
+	p := &toolProgress{
+		tool: "exec",
+		now:  func() time.Time { return now },
+		publish: func(content string) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, content)
+		},
+	}
+
+	p.report("compiling")
+	p.report("linking") // within the interval: waits for the timer
+	for i := 0; i < 10; i++ {
+		now = now.Add(2 * time.Second)
+		p.report(strings.Repeat("x", i+1))
+	}
+	p.stop()
+	p.report("after the tool returned")
+	time.Sleep(toolProgressInterval + 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 11 || sent[0] != "exec:\ncompiling" || sent[1] != "exec:\ncompiling\nlinking\nx" {
+		t.Fatalf("sent = %q", sent)
+	}
+	// Updates show the latest lines only
+	if lines := strings.Split(sent[10], "\n"); len(lines) != toolProgressLines+1 || lines[1] != "xxx" {
+		t.Errorf("last update = %q", sent[10])
+	}
+}
+
+func TestToolProgress_RelaysCommandOutput(t *testing.T) {
+	al := newCommandTestLoop(t, &simpleMockProvider{response: "ok"})
+	opts := processOptions{Channel: "telegram", ChatID: "42", SendProgress: true}
+	ctx, stop := al.withToolProgress(context.Background(), opts, "exec")
+
+	exec := tools.NewExecTool(t.TempDir(), true)
+	if result := exec.Execute(ctx, map[string]any{"command": "echo step one"}); result.IsError {
+		t.Fatalf("exec failed: %s", result.ForLLM)
+	}
+	stop()
+
+	outCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := al.bus.SubscribeOutbound(outCtx)
+	want := bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "exec:\nstep one", Progress: true}
+	if !ok || msg.Channel != want.Channel || msg.Content != want.Content || !msg.Progress {
+		t.Errorf("progress = %+v, want %+v", msg, want)
+	}
+
+	// Internal channels get no progress
+	cli := processOptions{Channel: "cli", SendProgress: true}
+	if got, _ := al.withToolProgress(context.Background(), cli, "exec"); got != context.Background() {
+		t.Error("progress context for the CLI")
+	}
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// summaryInputChars caps how much of a result is sent to be summarized;
+	// the rest is cut like any oversized result.
+	summaryInputChars = 100000
+	summaryTimeout    = 60 * time.Second
+)
+
+// resultSummarizer holds the provider for tools.results.summary_model,
+// created on first use.
+type resultSummarizer struct {
+	once     sync.Once
+	provider providers.LLMProvider
+	model    string
+	err      error
+}
+
+// toolResultLimit returns the most characters a result of the tool may add
+// to the conversation, or 0 for no limit.
+func (al *AgentLoop) toolResultLimit(toolName string) int {
+	cfg := al.cfg.Tools.Results
+	if limit, ok := cfg.PerTool[toolName]; ok {
+		return limit
+	}
+	return cfg.MaxChars
+}
+
+// limitToolResult shrinks an oversized tool result before it is added to the
+// conversation: it is summarized when tools.results.summarize is on, and
+// cut to its head and tail otherwise or when summarizing fails.
+func (al *AgentLoop) limitToolResult(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	toolName, content string,
+) string {
+	limit := al.toolResultLimit(toolName)
+	size := utf8.RuneCountInString(content)
+	if limit <= 0 || size <= limit {
+		return content
+	}
+
+	if al.cfg.Tools.Results.Summarize {
+		summary, err := al.summarizeToolResult(ctx, agent, opts, toolName, content, limit)
+		if err == nil && summary != "" && utf8.RuneCountInString(summary) <= limit {
+			logger.InfoCF("agent", "Summarized oversized tool result", map[string]any{
+				"tool":          toolName,
+				"chars":         size,
+				"summary_chars": utf8.RuneCountInString(summary),
+			})
+			return fmt.Sprintf("[Output of %d chars, summarized]\n%s", size, summary)
+		}
+		logger.WarnCF("agent", "Could not summarize tool result, truncating it", map[string]any{
+			"tool":  toolName,
+			"error": fmt.Sprint(err),
+		})
+	}
+
+	logger.InfoCF("agent", "Truncated oversized tool result", map[string]any{
+		"tool":  toolName,
+		"chars": size,
+		"limit": limit,
+	})
+	return truncateMiddle(content, limit)
+}
+
+func (al *AgentLoop) summarizeToolResult(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	toolName, content string,
+	limit int,
+) (string, error) {
+	provider, model := agent.Provider, agent.Model
+	if name := al.cfg.Tools.Results.SummaryModel; name != "" {
+		s := &al.resultSummarizer
+		s.once.Do(func() {
+			modelCfg, err := al.cfg.GetModelConfig(name)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.provider, s.model, s.err = providers.CreateProviderFromConfig(modelCfg)
+		})
+		if s.err != nil {
+			return "", s.err
+		}
+		provider, model = s.provider, s.model
+	}
+
+	prompt := fmt.Sprintf("The %s tool returned the output below, which is too long to keep. "+
+		"Summarize it in under %d characters for an assistant that is using it for a task. "+
+		"Keep exact names, numbers, paths, error messages and anything that looks like the answer; "+
+		"say what was left out.\n\nOUTPUT:\n%s",
+		toolName, limit*3/4, truncateMiddle(content, summaryInputChars))
+
+	ctx, cancel := context.WithTimeout(ctx, summaryTimeout)
+	defer cancel()
+	response, err := provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, model,
+		map[string]any{
+			"max_tokens":  min(limit/3+1, 4096),
+			"temperature": 0.2,
+		})
+	if err != nil {
+		return "", err
+	}
+	al.recordTurnUsage(agent, opts, response.Usage)
+	return response.Content, nil
+}
+
+// truncateMiddle keeps the start and end of s within limit characters, as
+// both tend to matter in command output: what ran and how it ended.
+func truncateMiddle(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	marker := func(omitted int) string {
+		return fmt.Sprintf("\n\n... [%d characters omitted] ...\n\n", omitted)
+	}
+	// Room for the marker, sized for the longest count it may show
+	keep := max(limit-utf8.RuneCountInString(marker(len(runes))), 0)
+	head := keep * 2 / 3
+	tail := keep - head
+	return string(runes[:head]) + marker(len(runes)-keep) + string(runes[len(runes)-tail:])
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// summaryProvider answers summary requests with a fixed text, or fails.
+type summaryProvider struct {
+	summary string
+	prompt  string
+}
+
+func (p *summaryProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.prompt = messages[len(messages)-1].Content
+	if p.summary == "" {
+		return nil, errors.New("model unavailable")
+	}
+	return &providers.LLMResponse{Content: p.summary}, nil
+}
+
+func (p *summaryProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	s := strings.Repeat("a", 500) + strings.Repeat("é", 500)
+	got := truncateMiddle(s, 200)
+	if n := utf8.RuneCountInString(got); n > 200 {
+		t.Errorf("truncated to %d chars, want at most 200", n)
+	}
+	if !strings.HasPrefix(got, "aaa") || !strings.HasSuffix(got, "ééé") || !utf8.ValidString(got) {
+		t.Errorf("truncateMiddle() = %q", got)
+	}
+	if !strings.Contains(got, "characters omitted") {
+		t.Errorf("no omission marker in %q", got)
+	}
+	if truncateMiddle("short", 200) != "short" {
+		t.Error("short input changed")
+	}
+}
+
+func TestLimitToolResult(t *testing.T) {
+	provider := &summaryProvider{}
+	al := newCommandTestLoop(t, provider)
+	agent := al.registry.GetDefaultAgent()
+	opts := processOptions{SessionKey: "s"}
+	big := strings.Repeat("line of output\n", 1000)
+
+	al.cfg.Tools.Results.MaxChars = 1000
+	al.cfg.Tools.Results.PerTool = map[string]int{"read_file": 0}
+	if got := al.limitToolResult(context.Background(), agent, opts, "exec", big); utf8.RuneCountInString(got) > 1000 {
+		t.Errorf("exec result kept %d chars", len(got))
+	}
+	if got := al.limitToolResult(context.Background(), agent, opts, "read_file", big); got != big {
+		t.Error("read_file result cut despite its limit of 0")
+	}
+
+	al.cfg.Tools.Results.Summarize = true
+	provider.summary = "1000 identical lines"
+	got := al.limitToolResult(context.Background(), agent, opts, "exec", big)
+	if got != "[Output of 15000 chars, summarized]\n1000 identical lines" {
+		t.Errorf("summarized result = %q", got)
+	}
+	if !strings.Contains(provider.prompt, "The exec tool returned") {
+		t.Errorf("summary prompt = %q", provider.prompt[:100])
+	}
+
+	provider.summary = ""
+	got = al.limitToolResult(context.Background(), agent, opts, "exec", big)
+	if !strings.Contains(got, "characters omitted") {
+		t.Errorf("failed summary should fall back to truncation, got %q", got)
+	}
+}
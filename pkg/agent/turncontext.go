@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxWorkspaceEntries bounds the workspace listing in the system prompt.
+const maxWorkspaceEntries = 30
+
+// workspaceBookkeeping are the workspace entries the agent manages itself,
+// which the listing leaves out.
+var workspaceBookkeeping = map[string]bool{
+	"sessions":     true,
+	"state":        true,
+	"cron":         true,
+	"runs":         true,
+	"memory":       true,
+	"skills":       true,
+	"AGENTS.md":    true,
+	"SOUL.md":      true,
+	"USER.md":      true,
+	"IDENTITY.md":  true,
+	"HEARTBEAT.md": true,
+	"users":        true,
+}
+
+// turnContextTemplate renders what the model can't know on its own: when
+// the turn happens, what the workspace holds, what the chat is waiting for
+// and who the user is.
+var turnContextTemplate = template.Must(template.New("context").Parse(`# Context
+
+## Current Time
+{{.Now.Format "Monday, 2 January 2006, 15:04"}} {{.Zone}} (UTC{{.Now.Format "-07:00"}})
+This is the real date and time. Use it for anything relative to today and never say you don't know it.
+{{- if .Files}}
+
+## Workspace Files
+{{- range .Files}}
+- {{.}}
+{{- end}}
+{{- if .MoreFiles}}
+- and {{.MoreFiles}} more
+{{- end}}
+{{- end}}
+{{- if .Reminders}}
+
+## Pending Reminders in This Chat
+{{- range .Reminders}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- if .Profile}}
+
+## User Profile
+{{.Profile}}
+{{- end}}`))
+
+// turnContext is the data of turnContextTemplate.
+type turnContext struct {
+	Now       time.Time
+	Zone      string
+	Files     []string
+	MoreFiles int
+	Reminders []string
+	Profile   string
+}
+
+// reminderLister is implemented by the reminders tool.
+type reminderLister interface {
+	Pending(channel, chatID string, loc *time.Location) []string
+}
+
+// buildTurnContext renders the context section of the system prompt for a
+// turn in the given chat.
+func (cb *ContextBuilder) buildTurnContext(channel, chatID string) string {
+	loc := cb.location
+	if loc == nil {
+		loc = time.Local
+	}
+	data := turnContext{Now: time.Now().In(loc)}
+	data.Zone = data.Now.Format("MST")
+	if cb.location != nil {
+		data.Zone = cb.location.String()
+	}
+	data.Files, data.MoreFiles = workspaceEntries(cb.workspace)
+	if cb.tools != nil && channel != "" && chatID != "" {
+		if tool, ok := cb.tools.Get("reminders"); ok {
+			if lister, ok := tool.(reminderLister); ok {
+				data.Reminders = lister.Pending(channel, chatID, loc)
+			}
+		}
+	}
+	if profile, err := os.ReadFile(filepath.Join(cb.workspace, "USER.md")); err == nil {
+		data.Profile = strings.TrimSpace(string(profile))
+	}
+
+	var sb strings.Builder
+	if err := turnContextTemplate.Execute(&sb, data); err != nil {
+		logger.WarnCF("agent", "Failed to render turn context", map[string]any{"error": err.Error()})
+		return "# Context\n\n## Current Time\n" + data.Now.Format(time.RFC1123)
+	}
+	return sb.String()
+}
+
+// workspaceEntries lists the top level of the workspace, directories
+// first, and how many entries didn't fit.
+func workspaceEntries(workspace string) ([]string, int) {
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		return nil, 0
+	}
+	var dirs, files []string
+	for _, e := range entries {
+		name := e.Name()
+		if workspaceBookkeeping[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if e.IsDir() {
+			dirs = append(dirs, name+"/")
+		} else {
+			files = append(files, name)
+		}
+	}
+	listed := append(dirs, files...)
+	if len(listed) > maxWorkspaceEntries {
+		return listed[:maxWorkspaceEntries], len(listed) - maxWorkspaceEntries
+	}
+	return listed, 0
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestBuildSystemPrompt_TurnContext(t *testing.T) {
+	workspace := t.TempDir()
+	for _, dir := range []string{"projects", "sessions", ".git"} {
+		os.Mkdir(filepath.Join(workspace, dir), 0o755)
+	}
+	os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("x"), 0o644)
+	os.WriteFile(filepath.Join(workspace, "USER.md"), []byte("Name: Sam\nPrefers metric units\n"), 0o644)
+
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	reminders := tools.NewRemindersTool(cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil))
+	reminders.SetContext("telegram", "42")
+	reminders.Execute(context.Background(), map[string]any{
+		"action": "create", "text": "call mum", "when": "2099-01-02 18:00", "tz": "Asia/Tokyo",
+	})
+	registry := tools.NewToolRegistry()
+	registry.Register(reminders)
+
+	cb := NewContextBuilder(workspace)
+	cb.SetToolsRegistry(registry)
+	cb.SetLocation(loc)
+
+	prompt := cb.buildSystemPrompt("telegram", "42")
+	for _, want := range []string{
+		time.Now().In(loc).Format("Monday, 2 January 2006") + ", ",
+		" Asia/Tokyo (UTC+09:00)",
+		"## Workspace Files\n- projects/\n- notes.txt\n",
+		"## Pending Reminders in This Chat\n- Fri 2099-01-02 18:00: call mum (id: ",
+		"## User Profile\nName: Sam\nPrefers metric units",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "sessions/") || strings.Contains(prompt, ".git") {
+		t.Errorf("prompt lists bookkeeping entries:\n%s", prompt)
+	}
+	if strings.Count(prompt, "Prefers metric units") != 1 {
+		t.Errorf("USER.md included more than once:\n%s", prompt)
+	}
+
+	// Without a chat there are no reminders to show
+	if prompt := cb.buildSystemPrompt("", ""); strings.Contains(prompt, "Pending Reminders") {
+		t.Errorf("prompt without a chat lists reminders:\n%s", prompt)
+	}
+}
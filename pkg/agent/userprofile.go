@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/userprofile"
+	"github.com/sipeed/picoclaw/pkg/usertime"
+)
+
+// registerUserProfileTool gives the agent a profile of each user it talks
+// to, kept in its workspace.
+func registerUserProfileTool(cfg *config.Config, agent *AgentInstance) {
+	dir := cfg.Tools.Profiles.Dir
+	if dir == "" {
+		dir = "users"
+	}
+	agent.UserProfiles = userprofile.NewStore(filepath.Join(agent.Workspace, dir))
+	agent.Tools.Register(tools.NewUserProfileTool(agent.UserProfiles))
+}
+
+// userLocation returns the time zone of userID: the one in their profile,
+// else the agent's default (agents.defaults.timezone), else the server's.
+func userLocation(agent *AgentInstance, userID string) *time.Location {
+	if agent.UserProfiles != nil && userID != "" {
+		if p, err := agent.UserProfiles.Get(userID); err == nil && p.Timezone != "" {
+			if loc, err := usertime.Load(p.Timezone); err == nil {
+				return loc
+			}
+		}
+	}
+	if agent.ContextBuilder.location != nil {
+		return agent.ContextBuilder.location
+	}
+	return time.Local
+}
+
+// userProfileContext returns a system prompt section with what is known
+// about the user, or "" when nothing is.
+func userProfileContext(agent *AgentInstance, userID string) string {
+	if agent.UserProfiles == nil || userID == "" {
+		return ""
+	}
+	p, err := agent.UserProfiles.Get(userID)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to read user profile", map[string]any{"user": userID, "error": err.Error()})
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## The User You Are Talking To\n\n")
+	if p.Empty() {
+		sb.WriteString("Nothing is known about them yet. Save their name, time zone, preferences and standing " +
+			"instructions with user_profile when they come up.")
+		return sb.String()
+	}
+	sb.WriteString(strings.TrimSpace(p.Markdown()))
+	if loc, err := usertime.Load(p.Timezone); err == nil && p.Timezone != "" {
+		sb.WriteString("\nTheir local time: " + time.Now().In(loc).Format("Monday 2006-01-02 15:04"))
+	}
+	sb.WriteString("\n\nFollow their preferences and standing instructions, and keep the profile up to date " +
+		"with user_profile.")
+	return sb.String()
+}
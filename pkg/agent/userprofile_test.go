@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/userprofile"
+)
+
+// systemPromptProvider records the system prompt of the last request.
+type systemPromptProvider struct {
+	prompt string
+}
+
+func (p *systemPromptProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.prompt = messages[0].Content
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *systemPromptProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessMessage_ShowsOnlyTheSendersProfile(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Session: config.SessionConfig{IdentityLinks: map[string][]string{"sam": {"telegram:123"}}},
+		Tools:   config.ToolsConfig{Profiles: config.UserProfilesConfig{Enabled: true}},
+	}
+	provider := &systemPromptProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+	agent.UserProfiles.Update("sam", func(p *userprofile.Profile) error {
+		p.Name = "Sam"
+		p.Instructions = []string{"answer in German"}
+		return nil
+	})
+
+	send := func(senderID string) string {
+		_, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: senderID,
+			ChatID:   "-100group",
+			Content:  "hello",
+			Metadata: map[string]string{"peer_kind": "group"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return provider.prompt
+	}
+
+	if prompt := send("123|sam_tg"); !strings.Contains(prompt, "Name: Sam") ||
+		!strings.Contains(prompt, "- answer in German") {
+		t.Errorf("Sam's prompt lacks their profile:\n%s", prompt)
+	}
+	prompt := send("456")
+	if strings.Contains(prompt, "Name: Sam") || strings.Contains(prompt, "- answer in German") {
+		t.Errorf("another member's prompt shows Sam's profile:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "Nothing is known about them yet") {
+		t.Errorf("prompt of a new user lacks the empty profile note:\n%s", prompt)
+	}
+}
+
+func TestUserLocation(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Timezone:          "Europe/Berlin",
+			},
+		},
+		Tools: config.ToolsConfig{Profiles: config.UserProfilesConfig{Enabled: true}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &systemPromptProvider{})
+	agent := al.registry.GetDefaultAgent()
+	agent.UserProfiles.Update("sam", func(p *userprofile.Profile) error {
+		p.Timezone = "UTC-5"
+		return nil
+	})
+
+	if loc := userLocation(agent, "sam"); loc.String() != "UTC-05:00" {
+		t.Errorf("userLocation(sam) = %v, want their profile's UTC-05:00", loc)
+	}
+	if loc := userLocation(agent, "alex"); loc.String() != "Europe/Berlin" {
+		t.Errorf("userLocation(alex) = %v, want the default Europe/Berlin", loc)
+	}
+}
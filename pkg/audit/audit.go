@@ -0,0 +1,228 @@
+// Package audit keeps an append-only record of the tools the agent runs, so
+// the owner can review what it did on their machine.
+//
+// The log is a JSON Lines file with one entry per tool call. Entries are
+// only ever appended; the log is never rewritten.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// previewChars is how much of a result an entry keeps verbatim.
+const previewChars = 200
+
+// Outcomes of a tool call.
+const (
+	StatusOK       = "ok"
+	StatusError    = "error"
+	StatusAsync    = "async"     // started, finishes in the background
+	StatusDenied   = "denied"    // not allowed in the conversation
+	StatusNotFound = "not_found" // no such tool
+	StatusInvalid  = "invalid"   // arguments didn't match the tool's schema
+	StatusCached   = "cached"    // answered from the tool result cache
+)
+
+// Entry records one tool call.
+type Entry struct {
+	Time       time.Time      `json:"time"`
+	Agent      string         `json:"agent,omitempty"`
+	Session    string         `json:"session,omitempty"`
+	Sender     string         `json:"sender,omitempty"`
+	Channel    string         `json:"channel,omitempty"`
+	ChatID     string         `json:"chat_id,omitempty"`
+	Tool       string         `json:"tool"`
+	Args       map[string]any `json:"args,omitempty"`
+	Status     string         `json:"status"`
+	DurationMS int64          `json:"duration_ms"`
+	ResultSHA  string         `json:"result_sha256,omitempty"` // of the full result sent to the model
+	ResultLen  int            `json:"result_chars"`
+	Preview    string         `json:"preview,omitempty"`
+}
+
+// SetResult fills in the digest, length and preview of a result.
+func (e *Entry) SetResult(content string) {
+	if content == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(content))
+	e.ResultSHA = hex.EncodeToString(sum[:])
+	e.ResultLen = utf8.RuneCountInString(content)
+	e.Preview = truncate(strings.Join(strings.Fields(content), " "), previewChars)
+}
+
+// Actor is who a tool call was made for.
+type Actor struct {
+	Agent   string
+	Session string
+	Sender  string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose tool calls are recorded as made for
+// actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or the zero Actor.
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Log appends entries to a file.
+type Log struct {
+	path        string
+	maxArgChars int
+	mu          sync.Mutex
+}
+
+// LogPath returns where a workspace keeps its audit log.
+func LogPath(workspace string) string {
+	return filepath.Join(workspace, "state", "audit.jsonl")
+}
+
+// New returns a log that appends to path, creating it on the first entry.
+// String arguments longer than maxArgChars are shortened; 0 keeps them
+// whole.
+func New(path string, maxArgChars int) *Log {
+	return &Log{path: path, maxArgChars: maxArgChars}
+}
+
+// Path returns the file the log appends to.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry, stamping it with the current time if it has
+// none.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if l.maxArgChars > 0 && e.Args != nil {
+		e.Args = shortenValue(e.Args, l.maxArgChars).(map[string]any)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter selects entries to read. Zero fields match everything.
+type Filter struct {
+	Tool    string
+	Session string // matches a prefix of the session key
+	Status  string
+	Since   time.Time
+	Limit   int // keep only the most recent entries
+}
+
+func (f Filter) match(e Entry) bool {
+	return (f.Tool == "" || e.Tool == f.Tool) &&
+		(f.Session == "" || strings.HasPrefix(e.Session, f.Session)) &&
+		(f.Status == "" || e.Status == f.Status) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since))
+}
+
+// Read returns the entries of the log at path that match the filter,
+// oldest first. A missing log has no entries.
+func Read(path string, filter Filter) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if !filter.match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if filter.Limit > 0 && len(entries) > 2*filter.Limit {
+			entries = append(entries[:0], entries[len(entries)-filter.Limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// shortenValue copies v with long strings cut to limit characters, so a
+// file written by the agent doesn't end up in the log whole.
+func shortenValue(v any, limit int) any {
+	switch v := v.(type) {
+	case string:
+		return truncate(v, limit)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = shortenValue(item, limit)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = shortenValue(item, limit)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func truncate(s string, limit int) string {
+	n := utf8.RuneCountInString(s)
+	if n <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit]) + fmt.Sprintf("... [%d chars]", n)
+}
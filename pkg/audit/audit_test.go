@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_RecordAndRead(t *testing.T) {
+	path := LogPath(t.TempDir())
+	log := New(path, 10)
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Session: "telegram:1", Tool: "exec", Status: StatusOK},
+		{Time: start.Add(time.Hour), Session: "telegram:1", Tool: "read_file", Status: StatusError},
+		{Time: start.Add(2 * time.Hour), Session: "cron", Tool: "exec", Status: StatusOK},
+		{
+			Time:    start.Add(3 * time.Hour),
+			Session: "telegram:2",
+			Tool:    "write_file",
+			Status:  StatusOK,
+			Args:    map[string]any{"path": "a.txt", "content": strings.Repeat("x", 50)},
+		},
+	}
+	for _, e := range entries {
+		if err := log.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("log permissions = %o, want 600", perm)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // tools, oldest first
+	}{
+		{"all", Filter{}, []string{"exec", "read_file", "exec", "write_file"}},
+		{"tool", Filter{Tool: "exec"}, []string{"exec", "exec"}},
+		{"session prefix", Filter{Session: "telegram:"}, []string{"exec", "read_file", "write_file"}},
+		{"status", Filter{Status: StatusError}, []string{"read_file"}},
+		{"since", Filter{Since: start.Add(90 * time.Minute)}, []string{"exec", "write_file"}},
+		{"limit keeps the latest", Filter{Limit: 1}, []string{"write_file"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(path, tt.filter)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			var tools []string
+			for _, e := range got {
+				tools = append(tools, e.Tool)
+			}
+			if strings.Join(tools, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("tools = %v, want %v", tools, tt.want)
+			}
+		})
+	}
+
+	got, _ := Read(path, Filter{Tool: "write_file"})
+	if content := got[0].Args["content"].(string); content != "xxxxxxxxxx... [50 chars]" {
+		t.Errorf("long argument = %q, want it shortened", content)
+	}
+	if got[0].Args["path"] != "a.txt" {
+		t.Errorf("short argument = %v, want it kept", got[0].Args["path"])
+	}
+	if len(entries[3].Args["content"].(string)) != 50 {
+		t.Error("Record changed the caller's arguments")
+	}
+}
+
+func TestRead_MissingLog(t *testing.T) {
+	entries, err := Read(filepath.Join(t.TempDir(), "audit.jsonl"), Filter{})
+	if err != nil || entries != nil {
+		t.Errorf("Read = %v, %v; want no entries", entries, err)
+	}
+}
+
+func TestEntry_SetResult(t *testing.T) {
+	var e Entry
+	e.SetResult("line one\nline two")
+	if e.ResultSHA != "b6858b03a6cae635deeaeab09a74e598979b72c917cbfff0bb3fe2cd05111dbc" {
+		t.Errorf("digest = %q", e.ResultSHA)
+	}
+	if e.ResultLen != 17 || e.Preview != "line one line two" {
+		t.Errorf("length, preview = %d, %q", e.ResultLen, e.Preview)
+	}
+}
+
+func TestActor(t *testing.T) {
+	if actor := ActorFrom(context.Background()); actor != (Actor{}) {
+		t.Errorf("ActorFrom(empty) = %+v", actor)
+	}
+	want := Actor{Agent: "main", Session: "telegram:1", Sender: "42"}
+	if actor := ActorFrom(WithActor(context.Background(), want)); actor != want {
+		t.Errorf("ActorFrom = %+v, want %+v", actor, want)
+	}
+}
@@ -2,6 +2,9 @@ package bus
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -9,6 +12,7 @@ type MessageBus struct {
 	inbound  chan InboundMessage
 	outbound chan OutboundMessage
 	handlers map[string]MessageHandler
+	notifier NotifyFunc
 	closed   bool
 	mu       sync.RWMutex
 }
@@ -57,6 +61,29 @@ func (mb *MessageBus) SubscribeOutbound(ctx context.Context) (OutboundMessage, b
 	}
 }
 
+// SetNotifier registers the function that delivers proactive messages,
+// normally the channel manager's Notify.
+func (mb *MessageBus) SetNotifier(notifier NotifyFunc) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.notifier = notifier
+}
+
+// Notify sends a message the agent initiates (a scheduled task, heartbeat or
+// tool result) to a user on a channel and reports whether it was delivered.
+// Without a notifier the message is queued as a regular outbound message.
+func (mb *MessageBus) Notify(ctx context.Context, channel, recipient, content string) error {
+	mb.mu.RLock()
+	notifier := mb.notifier
+	mb.mu.RUnlock()
+
+	if notifier == nil {
+		mb.PublishOutbound(OutboundMessage{Channel: channel, ChatID: recipient, Content: content})
+		return nil
+	}
+	return notifier(ctx, channel, recipient, content)
+}
+
 func (mb *MessageBus) RegisterHandler(channel string, handler MessageHandler) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
@@ -80,3 +107,21 @@ func (mb *MessageBus) Close() {
 	close(mb.inbound)
 	close(mb.outbound)
 }
+
+// Broadcast sends content with Notify to each target, written
+// "channel:recipient". Every target is attempted; failures are returned
+// joined.
+func (mb *MessageBus) Broadcast(ctx context.Context, targets []string, content string) error {
+	var errs []error
+	for _, target := range targets {
+		channel, recipient, ok := strings.Cut(strings.TrimSpace(target), ":")
+		if !ok || channel == "" || recipient == "" {
+			errs = append(errs, fmt.Errorf("invalid broadcast target %q, want channel:recipient", target))
+			continue
+		}
+		if err := mb.Notify(ctx, channel, recipient, content); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyFallsBackToOutboundQueue(t *testing.T) {
+	mb := NewMessageBus()
+	if err := mb.Notify(context.Background(), "telegram", "42", "hello"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	msg, ok := mb.SubscribeOutbound(ctx)
+	if !ok || msg.Channel != "telegram" || msg.ChatID != "42" || msg.Content != "hello" {
+		t.Errorf("outbound = %+v (ok=%v)", msg, ok)
+	}
+}
+
+func TestNotifyUsesNotifier(t *testing.T) {
+	mb := NewMessageBus()
+	wantErr := errors.New("not running")
+	var got []string
+	mb.SetNotifier(func(_ context.Context, channel, recipient, content string) error {
+		got = []string{channel, recipient, content}
+		return wantErr
+	})
+
+	if err := mb.Notify(context.Background(), "slack", "U1", "hi"); !errors.Is(err, wantErr) {
+		t.Errorf("Notify() error = %v, want %v", err, wantErr)
+	}
+	if len(got) != 3 || got[1] != "U1" {
+		t.Errorf("notifier called with %v", got)
+	}
+}
+
+func TestBroadcastNotifiesEveryTarget(t *testing.T) {
+	mb := NewMessageBus()
+	failed := errors.New("channel slack is not running")
+	var sent []string
+	mb.SetNotifier(func(_ context.Context, channel, recipient, content string) error {
+		if channel == "slack" {
+			return failed
+		}
+		sent = append(sent, channel+"/"+recipient+"/"+content)
+		return nil
+	})
+
+	err := mb.Broadcast(context.Background(), []string{"telegram:42", "slack:C1", "bogus", "onebot:group:7"}, "digest")
+	if !errors.Is(err, failed) {
+		t.Errorf("Broadcast() error = %v, want it to include %v", err, failed)
+	}
+	if err == nil || !strings.Contains(err.Error(), `"bogus"`) {
+		t.Errorf("Broadcast() error = %v, want invalid target reported", err)
+	}
+	if len(sent) != 2 || sent[0] != "telegram/42/digest" || sent[1] != "onebot/group:7/digest" {
+		t.Errorf("sent = %v", sent)
+	}
+}
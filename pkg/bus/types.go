@@ -1,19 +1,49 @@
 package bus
 
+import "context"
+
 type InboundMessage struct {
 	Channel    string            `json:"channel"`
 	SenderID   string            `json:"sender_id"`
 	ChatID     string            `json:"chat_id"`
 	Content    string            `json:"content"`
 	Media      []string          `json:"media,omitempty"`
+	Images     []string          `json:"images,omitempty"` // data URLs of image media, for multimodal models
 	SessionKey string            `json:"session_key"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string   `json:"channel"`
+	ChatID  string   `json:"chat_id"`
+	Content string   `json:"content"`
+	Media   []string `json:"media,omitempty"` // Local file paths to attach, for channels that support it
+	// Progress marks an interim update (partial output or tool activity) sent
+	// while the agent is still working. Channels without progressive replies drop it.
+	Progress bool `json:"progress,omitempty"`
+	// Buttons are choices shown under the message, e.g. approve/deny.
+	Buttons []Button `json:"buttons,omitempty"`
+}
+
+// Button is a choice offered with an outbound message. Channels that support
+// buttons render them natively and deliver a press as an inbound message
+// whose content is Value; other channels list the values to reply with.
+type Button struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+	Style string `json:"style,omitempty"` // "primary" or "danger"
 }
 
+// Ratings a channel puts in the "feedback" metadata of an inbound message
+// when a user reacts to one of the bot's replies. Such messages are recorded
+// with the session, not answered.
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+)
+
 type MessageHandler func(InboundMessage) error
+
+// NotifyFunc delivers a proactive message to recipient, a user or chat ID on
+// channel.
+type NotifyFunc func(ctx context.Context, channel, recipient, content string) error
@@ -0,0 +1,174 @@
+package channels
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// rejectReplyInterval limits how often one sender is told they have no access.
+const rejectReplyInterval = 10 * time.Minute
+
+// AccessList holds access granted at runtime by admins, on top of each
+// channel's allow_from. Grants are persisted so they survive restarts.
+type AccessList struct {
+	admins []string // "channel:sender_id" entries
+	path   string
+	mu     sync.RWMutex
+	grants map[string][]string // channel -> sender IDs
+}
+
+type accessFile struct {
+	Grants map[string][]string `json:"grants"`
+}
+
+// NewAccessList loads runtime grants from path. A missing file is not an error.
+func NewAccessList(path string, admins []string) (*AccessList, error) {
+	a := &AccessList{
+		admins: admins,
+		path:   path,
+		grants: make(map[string][]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return a, fmt.Errorf("failed to read access list: %w", err)
+	}
+
+	var file accessFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return a, fmt.Errorf("failed to parse access list: %w", err)
+	}
+	if file.Grants != nil {
+		a.grants = file.Grants
+	}
+	return a, nil
+}
+
+// IsAdmin reports whether senderID on channel may manage access.
+func (a *AccessList) IsAdmin(channel, senderID string) bool {
+	var ids []string
+	for _, admin := range a.admins {
+		if ch, id, ok := strings.Cut(admin, ":"); ok && ch == channel {
+			ids = append(ids, id)
+		}
+	}
+	return len(ids) > 0 && matchAllowList(ids, senderID)
+}
+
+// IsGranted reports whether senderID was granted access to channel at runtime.
+func (a *AccessList) IsGranted(channel, senderID string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return matchAllowList(a.grants[channel], senderID)
+}
+
+// Grant gives senderID access to channel and saves the list.
+func (a *AccessList) Grant(channel, senderID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if slices.Contains(a.grants[channel], senderID) {
+		return nil
+	}
+	a.grants[channel] = append(a.grants[channel], senderID)
+	return a.save()
+}
+
+// Revoke removes a runtime grant. Access from allow_from is not affected.
+func (a *AccessList) Revoke(channel, senderID string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	idx := slices.Index(a.grants[channel], senderID)
+	if idx < 0 {
+		return false, nil
+	}
+	a.grants[channel] = slices.Delete(a.grants[channel], idx, idx+1)
+	if len(a.grants[channel]) == 0 {
+		delete(a.grants, channel)
+	}
+	return true, a.save()
+}
+
+// Granted returns the runtime grants for channel.
+func (a *AccessList) Granted(channel string) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.grants[channel])
+}
+
+// save writes the grants atomically; callers hold mu.
+func (a *AccessList) save() error {
+	if a.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(accessFile{Grants: a.grants}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// AccessControlled is implemented by channels embedding BaseChannel.
+type AccessControlled interface {
+	SetAccessControl(access *AccessList, rejectMessage string)
+}
+
+// SetAccessControl attaches runtime grants and the reply sent to rejected
+// senders (empty to ignore them silently).
+func (c *BaseChannel) SetAccessControl(access *AccessList, rejectMessage string) {
+	c.access = access
+	c.rejectMessage = rejectMessage
+}
+
+// RejectSender replies to a sender outside the allowlist with the configured
+// rejection message, at most once per interval per sender.
+func (c *BaseChannel) RejectSender(senderID, chatID string) {
+	logger.DebugCF("channels", "Message rejected by allowlist", map[string]any{
+		"channel":   c.name,
+		"sender_id": senderID,
+	})
+
+	if c.rejectMessage == "" || chatID == "" || c.bus == nil {
+		return
+	}
+
+	c.rejectMu.Lock()
+	if c.rejectedAt == nil {
+		c.rejectedAt = make(map[string]time.Time)
+	}
+	last, seen := c.rejectedAt[senderID]
+	if seen && time.Since(last) < rejectReplyInterval {
+		c.rejectMu.Unlock()
+		return
+	}
+	c.rejectedAt[senderID] = time.Now()
+	c.rejectMu.Unlock()
+
+	c.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: c.name,
+		ChatID:  chatID,
+		Content: c.rejectMessage,
+	})
+}
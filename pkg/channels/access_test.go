@@ -0,0 +1,70 @@
+package channels
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestAccessListGrantsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "access.json")
+	access, err := NewAccessList(path, []string{"telegram:42"})
+	if err != nil {
+		t.Fatalf("NewAccessList() error = %v", err)
+	}
+
+	if !access.IsAdmin("telegram", "42|alice") || access.IsAdmin("discord", "42") {
+		t.Error("admin entries should be scoped to their channel")
+	}
+
+	if err := access.Grant("telegram", "99"); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+
+	reloaded, err := NewAccessList(path, nil)
+	if err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if !reloaded.IsGranted("telegram", "99|bob") {
+		t.Error("grant should survive a reload")
+	}
+
+	removed, err := reloaded.Revoke("telegram", "99")
+	if err != nil || !removed {
+		t.Fatalf("Revoke() = %v, %v", removed, err)
+	}
+	if reloaded.IsGranted("telegram", "99") {
+		t.Error("revoked sender should no longer be granted")
+	}
+}
+
+func TestBaseChannelRejectsUnknownSenders(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	access, _ := NewAccessList("", nil)
+	ch := NewBaseChannel("telegram", nil, msgBus, []string{"1"})
+	ch.SetAccessControl(access, "Sorry, you don't have access.")
+
+	ch.HandleMessage("2", "chat2", "hello", nil, nil)
+	ch.HandleMessage("2", "chat2", "hello again", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "chat2" || out.Content != "Sorry, you don't have access." {
+		t.Fatalf("expected rejection reply, got %+v (ok=%v)", out, ok)
+	}
+	if _, ok := msgBus.SubscribeOutbound(ctx); ok {
+		t.Error("repeated rejections should be throttled")
+	}
+
+	access.Grant("telegram", "2")
+	ch.HandleMessage("2", "chat2", "let me in", nil, nil)
+	inCtx, inCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer inCancel()
+	if msg, ok := msgBus.ConsumeInbound(inCtx); !ok || msg.Content != "let me in" {
+		t.Errorf("granted sender should reach the agent, got %+v (ok=%v)", msg, ok)
+	}
+}
@@ -0,0 +1,332 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// HTTP API channel implementation
+// Lets other services chat with the agent: POST /v1/chat starts or continues
+// a session and answers with the reply, or streams progress as server-sent
+// events
+
+package channels
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	apiMaxBodySize     = 1 << 20
+	apiMaxSessionIDLen = 128
+)
+
+// APIChannel serves the HTTP API. A session ID is the chat ID, so each
+// session keeps its own history. One request per session runs at a time;
+// it is answered by the first reply the agent sends to the session.
+type APIChannel struct {
+	*BaseChannel
+	config     config.APIConfig
+	httpServer *http.Server
+
+	mu      sync.Mutex
+	pending map[string]*apiRequest // session ID -> request waiting on a reply
+}
+
+// apiRequest is a request waiting for the agent. Progress updates are only
+// queued for streaming requests.
+type apiRequest struct {
+	stream  bool
+	updates chan bus.OutboundMessage
+	done    chan struct{}
+}
+
+type apiChatRequest struct {
+	Message   string `json:"message"`
+	SessionID string `json:"session_id"`
+	User      string `json:"user"`
+	Stream    bool   `json:"stream"`
+}
+
+type apiChatResponse struct {
+	SessionID string       `json:"session_id"`
+	Reply     string       `json:"reply"`
+	Buttons   []bus.Button `json:"buttons,omitempty"`
+}
+
+var _ ProgressChannel = (*APIChannel)(nil)
+
+func NewAPIChannel(cfg config.APIConfig, messageBus *bus.MessageBus) (*APIChannel, error) {
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("api tokens is required")
+	}
+	for _, token := range cfg.Tokens {
+		if strings.TrimSpace(token) == "" {
+			return nil, fmt.Errorf("api tokens must not be empty")
+		}
+	}
+	if cfg.Host == "" {
+		cfg.Host = "127.0.0.1"
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 18795
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 300
+	}
+
+	// Callers are authenticated by token, not by the user they name
+	base := NewBaseChannel("api", cfg, messageBus, nil)
+
+	return &APIChannel{
+		BaseChannel: base,
+		config:      cfg,
+		pending:     make(map[string]*apiRequest),
+	}, nil
+}
+
+func (c *APIChannel) Start(ctx context.Context) error {
+	logger.InfoC("api", "Starting HTTP API channel")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat", c.chatHandler)
+
+	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+	c.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	c.setRunning(true)
+
+	go func() {
+		logger.InfoCF("api", "HTTP API listening", map[string]any{
+			"addr": addr,
+		})
+		if err := c.listenAndServe(c.httpServer); err != nil && err != http.ErrServerClosed {
+			logger.ErrorCF("api", "HTTP API server error", map[string]any{
+				"error": err.Error(),
+			})
+			c.setRunning(false) // Let the manager restart the channel
+		}
+	}()
+
+	return nil
+}
+
+func (c *APIChannel) Stop(ctx context.Context) error {
+	logger.InfoC("api", "Stopping HTTP API channel")
+
+	if c.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := c.httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.ErrorCF("api", "HTTP API server shutdown error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	c.setRunning(false)
+	return nil
+}
+
+// SupportsProgress reports true; only streaming requests receive the updates.
+func (c *APIChannel) SupportsProgress() bool {
+	return true
+}
+
+// Send hands a message to the request waiting on its session.
+func (c *APIChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("api channel not running")
+	}
+
+	c.mu.Lock()
+	req := c.pending[msg.ChatID]
+	c.mu.Unlock()
+
+	if req == nil {
+		if msg.Progress {
+			return nil
+		}
+		return fmt.Errorf("no request waiting on session %s", msg.ChatID)
+	}
+
+	if msg.Progress {
+		if !req.stream {
+			return nil
+		}
+		select {
+		case req.updates <- msg:
+		default: // the client is slow; updates can be skipped
+		}
+		return nil
+	}
+
+	select {
+	case req.updates <- msg:
+		return nil
+	case <-req.done:
+		return fmt.Errorf("request on session %s ended before the reply", msg.ChatID)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *APIChannel) chatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !c.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="picoclaw"`)
+		apiError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+
+	var body apiChatRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, apiMaxBodySize)).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(body.Message) == "" {
+		apiError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if body.SessionID == "" {
+		body.SessionID = uuid.NewString()
+	}
+	if len(body.SessionID) > apiMaxSessionIDLen {
+		apiError(w, http.StatusBadRequest, "session_id is too long")
+		return
+	}
+	if body.User == "" {
+		body.User = "api"
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		body.Stream = true
+	}
+
+	req := &apiRequest{
+		stream:  body.Stream,
+		updates: make(chan bus.OutboundMessage, 16),
+		done:    make(chan struct{}),
+	}
+	c.mu.Lock()
+	if _, busy := c.pending[body.SessionID]; busy {
+		c.mu.Unlock()
+		apiError(w, http.StatusConflict, "session is busy with another request")
+		return
+	}
+	c.pending[body.SessionID] = req
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, body.SessionID)
+		c.mu.Unlock()
+		close(req.done)
+	}()
+
+	logger.InfoCF("api", "Received API request", map[string]any{
+		"session_id": body.SessionID,
+		"user":       body.User,
+		"stream":     body.Stream,
+	})
+
+	c.HandleMessage(body.User, body.SessionID, body.Message, nil, map[string]string{
+		"peer_kind": "direct",
+		"peer_id":   body.SessionID,
+		"username":  body.User,
+	})
+
+	if body.Stream {
+		c.stream(w, r, body.SessionID, req)
+	} else {
+		c.reply(w, r, body.SessionID, req)
+	}
+}
+
+// reply waits for the agent and answers with a JSON body.
+func (c *APIChannel) reply(w http.ResponseWriter, r *http.Request, sessionID string, req *apiRequest) {
+	timeout := time.NewTimer(time.Duration(c.config.Timeout) * time.Second)
+	defer timeout.Stop()
+
+	select {
+	case msg := <-req.updates:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apiChatResponse{SessionID: sessionID, Reply: msg.Content, Buttons: msg.Buttons})
+	case <-timeout.C:
+		apiError(w, http.StatusGatewayTimeout, "timed out waiting for a reply")
+	case <-r.Context().Done():
+	}
+}
+
+// stream sends a "session" event, a "progress" event per interim update and
+// a final "reply" event.
+func (c *APIChannel) stream(w http.ResponseWriter, r *http.Request, sessionID string, req *apiRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	event := func(name string, data any) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+		flusher.Flush()
+	}
+	event("session", map[string]string{"session_id": sessionID})
+
+	timeout := time.NewTimer(time.Duration(c.config.Timeout) * time.Second)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case msg := <-req.updates:
+			if msg.Progress {
+				event("progress", map[string]string{"content": msg.Content})
+				continue
+			}
+			event("reply", apiChatResponse{SessionID: sessionID, Reply: msg.Content, Buttons: msg.Buttons})
+			return
+		case <-timeout.C:
+			event("error", map[string]string{"error": "timed out waiting for a reply"})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (c *APIChannel) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, want := range c.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func apiError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,184 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestAPIChannel(t *testing.T, timeout int) (*APIChannel, *bus.MessageBus, *httptest.Server) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewAPIChannel(config.APIConfig{
+		Tokens:  config.FlexibleStringSlice{"secret"},
+		Timeout: timeout,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewAPIChannel() error = %v", err)
+	}
+	ch.setRunning(true)
+	server := httptest.NewServer(http.HandlerFunc(ch.chatHandler))
+	t.Cleanup(server.Close)
+	return ch, msgBus, server
+}
+
+// answerAPI plays the agent: it answers the next inbound message with one
+// progress update and a reply echoing the content.
+func answerAPI(t *testing.T, ch *APIChannel, msgBus *bus.MessageBus) <-chan bus.InboundMessage {
+	t.Helper()
+	got := make(chan bus.InboundMessage, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			return
+		}
+		got <- msg
+		ch.Send(ctx, bus.OutboundMessage{ChatID: msg.ChatID, Content: "Thinking...", Progress: true})
+		ch.Send(ctx, bus.OutboundMessage{ChatID: msg.ChatID, Content: "echo: " + msg.Content})
+	}()
+	return got
+}
+
+func postAPI(t *testing.T, url, token, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAPIChannelRejectsBadRequests(t *testing.T) {
+	_, _, server := newTestAPIChannel(t, 1)
+	longSession := `{"message":"hi","session_id":"` + strings.Repeat("x", 200) + `"}`
+
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"no token", "", `{"message":"hi"}`, http.StatusUnauthorized},
+		{"wrong token", "nope", `{"message":"hi"}`, http.StatusUnauthorized},
+		{"bad json", "secret", `{"message":`, http.StatusBadRequest},
+		{"no message", "secret", `{"message":"  "}`, http.StatusBadRequest},
+		{"long session", "secret", longSession, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp := postAPI(t, server.URL, tt.token, tt.body, nil); resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestAPIChannelReplies(t *testing.T) {
+	ch, msgBus, server := newTestAPIChannel(t, 5)
+
+	inbound := answerAPI(t, ch, msgBus)
+	resp := postAPI(t, server.URL, "secret", `{"message":"hello","user":"billing-service"}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var got apiChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	if got.SessionID == "" || got.Reply != "echo: hello" {
+		t.Errorf("response = %+v", got)
+	}
+	msg := <-inbound
+	if msg.Channel != "api" || msg.ChatID != got.SessionID || msg.SenderID != "billing-service" {
+		t.Errorf("inbound = %+v", msg)
+	}
+
+	// The session carries on under the same chat
+	inbound = answerAPI(t, ch, msgBus)
+	resp = postAPI(t, server.URL, "secret", `{"message":"again","session_id":"`+got.SessionID+`"}`, nil)
+	var next apiChatResponse
+	json.NewDecoder(resp.Body).Decode(&next)
+	if next.SessionID != got.SessionID || next.Reply != "echo: again" {
+		t.Errorf("response = %+v", next)
+	}
+	if msg := <-inbound; msg.ChatID != got.SessionID || msg.SenderID != "api" {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
+
+func TestAPIChannelStreams(t *testing.T) {
+	ch, msgBus, server := newTestAPIChannel(t, 5)
+
+	answerAPI(t, ch, msgBus)
+	resp := postAPI(t, server.URL, "secret", `{"message":"hello","session_id":"s1"}`,
+		map[string]string{"Accept": "text/event-stream"})
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			scanner.Scan()
+			events = append(events, name+" "+strings.TrimPrefix(scanner.Text(), "data: "))
+		}
+	}
+	want := []string{
+		`session {"session_id":"s1"}`,
+		`progress {"content":"Thinking..."}`,
+		`reply {"session_id":"s1","reply":"echo: hello"}`,
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events =\n%s\nwant\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAPIChannelBusySessionAndTimeout(t *testing.T) {
+	ch, _, server := newTestAPIChannel(t, 1)
+
+	ch.mu.Lock()
+	ch.pending["busy"] = &apiRequest{}
+	ch.mu.Unlock()
+	resp := postAPI(t, server.URL, "secret", `{"message":"hi","session_id":"busy"}`, nil)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("busy session status = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+
+	// Nobody answers this one
+	resp = postAPI(t, server.URL, "secret", `{"message":"hi","session_id":"quiet"}`, nil)
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusGatewayTimeout)
+	}
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "quiet", Content: "too late"})
+	if err == nil {
+		t.Error("Send() after the request ended succeeded, want an error")
+	}
+}
+
+func TestNewAPIChannelRequiresTokens(t *testing.T) {
+	for _, tokens := range []config.FlexibleStringSlice{nil, {""}, {"ok", " "}} {
+		if _, err := NewAPIChannel(config.APIConfig{Tokens: tokens}, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewAPIChannel(%q) succeeded, want an error", tokens)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package channels
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const attachmentManifest = "manifest.jsonl"
+
+var reUnsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Attachment is one inbound file as recorded in the manifest.
+type Attachment struct {
+	Path       string    `json:"path,omitempty"` // relative to the workspace
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	MIMEType   string    `json:"mime_type,omitempty"`
+	Channel    string    `json:"channel"`
+	SenderID   string    `json:"sender_id"`
+	ChatID     string    `json:"chat_id"`
+	ReceivedAt time.Time `json:"received_at"`
+	Skipped    string    `json:"skipped,omitempty"` // why the file was not saved
+
+	localPath string // absolute path of the saved copy
+}
+
+// AttachmentStore copies inbound files into the workspace, so the agent's
+// file tools can open them, and logs each one to a manifest.
+type AttachmentStore struct {
+	workspace string
+	dir       string
+	maxSize   int64
+
+	mu  sync.Mutex // serializes manifest writes
+	now func() time.Time
+}
+
+// NewAttachmentStore returns a store for cfg, or nil when attachments are
+// not saved.
+func NewAttachmentStore(workspace string, cfg config.AttachmentsConfig) *AttachmentStore {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(workspace, "attachments")
+	}
+	return &AttachmentStore{
+		workspace: workspace,
+		dir:       dir,
+		maxSize:   int64(cfg.MaxSizeMB) << 20,
+		now:       time.Now,
+	}
+}
+
+// AttachmentChannel is implemented by channels embedding BaseChannel.
+type AttachmentChannel interface {
+	SetAttachmentStore(store *AttachmentStore)
+}
+
+func (c *BaseChannel) SetAttachmentStore(store *AttachmentStore) {
+	c.attachments = store
+}
+
+// Save copies the given local files into the store. Files over the size
+// limit or that can't be read are recorded with the reason they were skipped.
+func (s *AttachmentStore) Save(channel, senderID, chatID string, paths []string) []Attachment {
+	now := s.now()
+	dayDir := filepath.Join(s.dir, utils.SanitizeFilename(channel), now.Format("2006-01-02"))
+
+	saved := make([]Attachment, 0, len(paths))
+	for _, path := range paths {
+		a := Attachment{
+			Name:       filepath.Base(path),
+			Channel:    channel,
+			SenderID:   senderID,
+			ChatID:     chatID,
+			ReceivedAt: now,
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case err != nil || !info.Mode().IsRegular():
+			a.Skipped = "not a readable file"
+		case s.maxSize > 0 && info.Size() > s.maxSize:
+			a.Size = info.Size()
+			a.Skipped = fmt.Sprintf("larger than %s", formatSize(s.maxSize))
+		case s.inWorkspace(path):
+			// Already saved in the workspace by the channel (e.g. WeCom media)
+			a.Size = info.Size()
+			a.MIMEType = detectMIME(path)
+			a.Path = s.relative(path)
+			a.localPath = path
+		default:
+			a.Size = info.Size()
+			dest := filepath.Join(dayDir, attachmentFilename(a.Name, now))
+			if a.MIMEType, err = copyAttachment(path, dest); err != nil {
+				logger.WarnCF("channels", "Failed to save attachment", map[string]any{
+					"channel": channel,
+					"path":    path,
+					"error":   err.Error(),
+				})
+				a.Skipped = "could not be saved"
+			} else {
+				a.Path = s.relative(dest)
+				a.localPath = dest
+			}
+		}
+		saved = append(saved, a)
+	}
+
+	s.appendManifest(saved)
+	return saved
+}
+
+func (s *AttachmentStore) inWorkspace(path string) bool {
+	rel, err := filepath.Rel(s.workspace, path)
+	return err == nil && filepath.IsAbs(path) && !strings.HasPrefix(rel, "..")
+}
+
+// relative returns path relative to the workspace when it is inside it.
+func (s *AttachmentStore) relative(path string) string {
+	if rel, err := filepath.Rel(s.workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func (s *AttachmentStore) appendManifest(attachments []Attachment) {
+	if len(attachments) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, attachmentManifest), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logger.WarnCF("channels", "Failed to open attachment manifest", map[string]any{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, a := range attachments {
+		enc.Encode(a)
+	}
+}
+
+// attachmentFilename makes a unique, filesystem-safe name that keeps the
+// original name and extension readable.
+func attachmentFilename(name string, now time.Time) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	stem := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	stem = strings.Trim(reUnsafeFilename.ReplaceAllString(stem, "_"), "._")
+	if len(stem) > 64 {
+		stem = stem[:64]
+	}
+	ext = reUnsafeFilename.ReplaceAllString(ext, "")
+
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	unique := now.Format("150405") + "-" + hex.EncodeToString(suffix[:])
+	if stem == "" {
+		return unique + ext
+	}
+	return unique + "-" + stem + ext
+}
+
+func detectMIME(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// copyAttachment copies src to dest and sniffs its MIME type.
+func copyAttachment(src, dest string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(in, head)
+	mimeType := http.DetectContentType(head[:n])
+
+	if _, err := out.Write(head[:n]); err == nil {
+		_, err = io.Copy(out, in)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	return mimeType, nil
+}
+
+// storeAttachments saves inbound media to the workspace and lists the
+// results in the message, replacing media paths with the saved copies.
+func (c *BaseChannel) storeAttachments(
+	senderID, chatID, content string,
+	media []string,
+) (string, []string) {
+	if c.attachments == nil || len(media) == 0 {
+		return content, media
+	}
+
+	saved := c.attachments.Save(c.name, senderID, chatID, media)
+
+	var b strings.Builder
+	b.WriteString("[attachments saved to workspace]")
+	stored := make([]string, 0, len(media))
+	for i, a := range saved {
+		if a.Skipped != "" {
+			fmt.Fprintf(&b, "\n- %s (not saved: %s)", a.Name, a.Skipped)
+			stored = append(stored, media[i])
+			continue
+		}
+		fmt.Fprintf(&b, "\n- %s (%s, %s)", a.Path, a.MIMEType, formatSize(a.Size))
+		stored = append(stored, a.localPath)
+	}
+
+	if content != "" {
+		content += "\n"
+	}
+	return content + b.String(), stored
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
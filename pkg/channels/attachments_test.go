@@ -0,0 +1,113 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestAttachmentStoreSavesFilesWithManifest(t *testing.T) {
+	workspace := t.TempDir()
+	store := NewAttachmentStore(workspace, config.AttachmentsConfig{Enabled: true, MaxSizeMB: 1})
+	store.now = func() time.Time { return time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	src := t.TempDir()
+	small := filepath.Join(src, "Quarterly report (final).txt")
+	os.WriteFile(small, []byte("hello"), 0o600)
+	big := filepath.Join(src, "big.bin")
+	os.WriteFile(big, make([]byte, 2<<20), 0o600)
+
+	saved := store.Save("telegram", "u1", "c1", []string{small, big, filepath.Join(src, "missing")})
+	if len(saved) != 3 {
+		t.Fatalf("got %d attachments", len(saved))
+	}
+
+	a := saved[0]
+	if !strings.HasPrefix(a.Path, "attachments/telegram/2026-03-04/050607-") ||
+		!strings.HasSuffix(a.Path, "-Quarterly_report_final.txt") {
+		t.Errorf("path = %q", a.Path)
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, a.Path)); err != nil || string(data) != "hello" {
+		t.Errorf("saved copy = %q, %v", data, err)
+	}
+	if !strings.HasPrefix(a.MIMEType, "text/plain") || a.Size != 5 {
+		t.Errorf("attachment = %+v", a)
+	}
+	if saved[1].Skipped != "larger than 1.0 MB" || saved[1].Path != "" {
+		t.Errorf("big file = %+v", saved[1])
+	}
+	if saved[2].Skipped == "" {
+		t.Errorf("missing file should be skipped: %+v", saved[2])
+	}
+
+	f, err := os.Open(filepath.Join(workspace, "attachments", "manifest.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Attachment
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Attachment
+		json.Unmarshal(scanner.Bytes(), &entry)
+		lines = append(lines, entry)
+	}
+	if len(lines) != 3 || lines[0].Path != a.Path || lines[0].ChatID != "c1" {
+		t.Errorf("manifest = %+v", lines)
+	}
+}
+
+func TestAttachmentStoreKeepsWorkspaceFilesInPlace(t *testing.T) {
+	workspace := t.TempDir()
+	store := NewAttachmentStore(workspace, config.AttachmentsConfig{Enabled: true})
+
+	path := filepath.Join(workspace, "media", "wecom_app", "voice.amr")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("#!AMR"), 0o600)
+
+	saved := store.Save("wecom_app", "u1", "u1", []string{path})
+	if saved[0].Path != "media/wecom_app/voice.amr" {
+		t.Errorf("path = %q", saved[0].Path)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "attachments", "wecom_app")); !os.IsNotExist(err) {
+		t.Error("workspace files should not be copied")
+	}
+}
+
+func TestHandleMessageListsAttachments(t *testing.T) {
+	workspace := t.TempDir()
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("discord", nil, msgBus, nil)
+	ch.SetAttachmentStore(NewAttachmentStore(workspace, config.AttachmentsConfig{Enabled: true, MaxSizeMB: 20}))
+
+	src := filepath.Join(t.TempDir(), "notes.md")
+	os.WriteFile(src, []byte("# notes"), 0o600)
+	ch.HandleMessage("u1", "c1", "please summarize", []string{src}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+
+	lines := strings.Split(msg.Content, "\n")
+	if len(lines) != 3 || lines[0] != "please summarize" || lines[1] != "[attachments saved to workspace]" {
+		t.Fatalf("content = %q", msg.Content)
+	}
+	if !strings.HasPrefix(lines[2], "- attachments/discord/") ||
+		!strings.HasSuffix(lines[2], "-notes.md (text/plain; charset=utf-8, 7 B)") {
+		t.Errorf("manifest line = %q", lines[2])
+	}
+	if len(msg.Media) != 1 || !strings.HasPrefix(msg.Media[0], workspace) {
+		t.Errorf("media should point at the saved copy, got %v", msg.Media)
+	}
+}
@@ -2,26 +2,81 @@ package channels
 
 import (
 	"context"
+	"crypto/tls"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
+// Channel is a chat platform the agent talks through. The manager builds
+// each enabled channel from config, starts it with the gateway and routes
+// outbound bus messages to it by name.
 type Channel interface {
+	// Name returns the routing key used in bus messages, e.g. "telegram"
 	Name() string
+	// Start connects to the platform and begins publishing inbound
+	// messages. It must not block; long-running work belongs in goroutines
+	// tied to ctx.
 	Start(ctx context.Context) error
+	// Stop disconnects and releases resources. It is called once at shutdown,
+	// even if Start failed.
 	Stop(ctx context.Context) error
+	// Send delivers an outbound message. It returns an error when the
+	// channel is not running.
 	Send(ctx context.Context, msg bus.OutboundMessage) error
 	IsRunning() bool
 	IsAllowed(senderID string) bool
 }
 
+// ProgressChannel is implemented by channels that can show interim progress
+// messages before the final reply. Progress messages for other channels are
+// dropped by the manager.
+type ProgressChannel interface {
+	SupportsProgress() bool
+}
+
+// MediaChannel is implemented by channels that download inbound media. The
+// manager points them at a per-channel directory in the workspace.
+type MediaChannel interface {
+	SetMediaDir(dir string)
+}
+
+// TranscribingChannel is implemented by channels that can transcribe voice
+// messages.
+type TranscribingChannel interface {
+	SetTranscriber(transcriber voice.Transcriber)
+}
+
 type BaseChannel struct {
 	config    any
 	bus       *bus.MessageBus
-	running   bool
+	running   atomic.Bool
 	name      string
 	allowList []string
+
+	access        *AccessList
+	rejectMessage string
+	rejectedAt    map[string]time.Time // sender -> last rejection reply
+	rejectMu      sync.Mutex
+
+	limiter         *RateLimiter
+	cooldownMessage string
+	language        string // built-in messages, see SetLanguage
+
+	tlsConfig *tls.Config  // webhook server HTTPS, nil for plain HTTP
+	publicURL atomic.Value // string, webhook URL when exposed through a tunnel
+
+	transcriber voice.Transcriber // audio attachments, see SetTranscriber
+	voiceChats  sync.Map          // chatID -> true when the last message was voice
+	attachments *AttachmentStore  // inbound files, nil when not saved
+	images      bool              // inline inbound images, see SetInlineImages
+	middleware  *Pipeline         // inbound steps, nil when none are configured
+	groups      *GroupPolicy      // when to answer in group chats, see SetGroupPolicy
+	health      healthState       // see Health
 }
 
 func NewBaseChannel(name string, config any, bus *bus.MessageBus, allowList []string) *BaseChannel {
@@ -30,7 +85,6 @@ func NewBaseChannel(name string, config any, bus *bus.MessageBus, allowList []st
 		bus:       bus,
 		name:      name,
 		allowList: allowList,
-		running:   false,
 	}
 }
 
@@ -39,7 +93,7 @@ func (c *BaseChannel) Name() string {
 }
 
 func (c *BaseChannel) IsRunning() bool {
-	return c.running
+	return c.running.Load()
 }
 
 func (c *BaseChannel) IsAllowed(senderID string) bool {
@@ -47,6 +101,16 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		return true
 	}
 
+	if matchAllowList(c.allowList, senderID) {
+		return true
+	}
+
+	// Runtime grants made by admins with /allow
+	return c.access != nil && c.access.IsGranted(c.name, senderID)
+}
+
+// matchAllowList reports whether senderID matches any entry of an allowlist.
+func matchAllowList(allowList []string, senderID string) bool {
 	// Extract parts from compound senderID like "123456|username"
 	idPart := senderID
 	userPart := ""
@@ -55,7 +119,7 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		userPart = senderID[idx+1:]
 	}
 
-	for _, allowed := range c.allowList {
+	for _, allowed := range allowList {
 		// Strip leading "@" from allowed value for username matching
 		trimmed := strings.TrimPrefix(allowed, "@")
 		allowedID := trimmed
@@ -82,22 +146,34 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 }
 
 func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []string, metadata map[string]string) {
+	c.recordEvent()
 	if !c.IsAllowed(senderID) {
+		c.RejectSender(senderID, chatID)
 		return
 	}
+	if !c.allowRate(senderID, chatID) {
+		return
+	}
+	c.noteVoiceMessage(chatID, media, metadata)
+	content, metadata = c.transcribeMedia(content, media, metadata)
+	content, media = c.storeAttachments(senderID, chatID, content, media)
 
-	msg := bus.InboundMessage{
+	msg, ok := c.middleware.Inbound(bus.InboundMessage{
 		Channel:  c.name,
 		SenderID: senderID,
 		ChatID:   chatID,
 		Content:  content,
 		Media:    media,
+		Images:   c.inlineImages(media),
 		Metadata: metadata,
+	})
+	if !ok {
+		return
 	}
 
 	c.bus.PublishInbound(msg)
 }
 
 func (c *BaseChannel) setRunning(running bool) {
-	c.running = running
+	c.running.Store(running)
 }
@@ -0,0 +1,857 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Bluesky channel implementation
+// Polls the AT Protocol notification and chat APIs with an app password,
+// replying in post threads or direct message conversations
+
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	blueskyAPITimeout     = 30 * time.Second
+	blueskyChatProxy      = "did:web:api.bsky.chat#bsky_chat"
+	blueskyCDN            = "https://cdn.bsky.app"
+	blueskyPostLimit      = 300 // graphemes
+	blueskyDMLimit        = 1000
+	blueskyMaxImages      = 4
+	blueskyMaxImageSize   = 1000000
+	blueskyMinLoginDelay  = 5 * time.Second
+	blueskyMaxLoginDelay  = 5 * time.Minute
+	blueskyLogCreateMsg   = "chat.bsky.convo.defs#logCreateMessage"
+	blueskyEmbedImages    = "app.bsky.embed.images"
+	blueskyEmbedWithMedia = "app.bsky.embed.recordWithMedia"
+)
+
+var reBlueskyLink = regexp.MustCompile(`https?://[^\s<>"]+[^\s<>".,;:!?)\]]`)
+
+// BlueskyChannel answers mentions, replies and chat messages on Bluesky.
+// Chats are keyed by the sender's DID; a reply goes back to wherever that
+// person last wrote, a post thread or a DM conversation.
+type BlueskyChannel struct {
+	*BaseChannel
+	config config.BlueskyConfig
+	pds    string
+	client *http.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	session    blueskySession
+	targets    map[string]blueskyTarget // chatID -> where to reply
+	handles    map[string]string        // DID -> handle of chat senders
+	chatCursor string
+
+	refreshMu sync.Mutex // serializes session refreshes
+	dms       bool       // polling chat; only touched by the polling goroutine
+}
+
+type blueskySession struct {
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+	Handle     string `json:"handle"`
+	DID        string `json:"did"`
+}
+
+type blueskyRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+type blueskyReplyRef struct {
+	Root   blueskyRef `json:"root"`
+	Parent blueskyRef `json:"parent"`
+}
+
+// blueskyTarget is where the next reply in a chat goes: a post thread or,
+// when convoID is set, a DM conversation.
+type blueskyTarget struct {
+	reply   *blueskyReplyRef
+	convoID string
+}
+
+type blueskyAuthor struct {
+	DID         string `json:"did"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"displayName"`
+}
+
+type blueskyBlob struct {
+	Type     string `json:"$type"`
+	Ref      any    `json:"ref"`
+	MimeType string `json:"mimeType"`
+	Size     int    `json:"size"`
+}
+
+type blueskyImage struct {
+	Alt   string      `json:"alt"`
+	Image blueskyBlob `json:"image"`
+}
+
+type blueskyEmbed struct {
+	Type   string         `json:"$type"`
+	Images []blueskyImage `json:"images,omitempty"`
+	Media  *blueskyEmbed  `json:"media,omitempty"`
+}
+
+type blueskyPost struct {
+	Text  string           `json:"text"`
+	Reply *blueskyReplyRef `json:"reply,omitempty"`
+	Embed *blueskyEmbed    `json:"embed,omitempty"`
+}
+
+type blueskyNotification struct {
+	URI       string          `json:"uri"`
+	CID       string          `json:"cid"`
+	Author    blueskyAuthor   `json:"author"`
+	Reason    string          `json:"reason"`
+	Record    json.RawMessage `json:"record"`
+	IsRead    bool            `json:"isRead"`
+	IndexedAt string          `json:"indexedAt"`
+}
+
+type blueskyChatLog struct {
+	Type    string `json:"$type"`
+	ConvoID string `json:"convoId"`
+	Message struct {
+		ID     string `json:"id"`
+		Text   string `json:"text"`
+		Sender struct {
+			DID string `json:"did"`
+		} `json:"sender"`
+	} `json:"message"`
+}
+
+// blueskyError is an XRPC error response.
+type blueskyError struct {
+	Status  int
+	Name    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *blueskyError) Error() string {
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Name, e.Message, e.Status)
+}
+
+func (e *blueskyError) expired() bool {
+	return e.Name == "ExpiredToken" || e.Name == "InvalidToken"
+}
+
+// xrpcCall is one request to the PDS.
+type xrpcCall struct {
+	method      string
+	nsid        string
+	query       url.Values
+	body        []byte
+	contentType string
+	chat        bool // proxied to the chat service
+}
+
+func xrpcJSON(nsid string, v any) xrpcCall {
+	body, _ := json.Marshal(v)
+	return xrpcCall{method: http.MethodPost, nsid: nsid, body: body, contentType: "application/json"}
+}
+
+func NewBlueskyChannel(cfg config.BlueskyConfig, messageBus *bus.MessageBus) (*BlueskyChannel, error) {
+	if cfg.Handle == "" || cfg.AppPassword == "" {
+		return nil, fmt.Errorf("bluesky handle and app_password are required")
+	}
+	pds := strings.TrimRight(cfg.PDS, "/")
+	if pds == "" {
+		pds = "https://bsky.social"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 15
+	}
+
+	base := NewBaseChannel("bluesky", cfg, messageBus, cfg.AllowFrom)
+
+	return &BlueskyChannel{
+		BaseChannel: base,
+		config:      cfg,
+		pds:         pds,
+		client:      &http.Client{Timeout: blueskyAPITimeout},
+		targets:     make(map[string]blueskyTarget),
+		handles:     make(map[string]string),
+		dms:         cfg.DMs,
+	}, nil
+}
+
+func (c *BlueskyChannel) Start(ctx context.Context) error {
+	logger.InfoCF("bluesky", "Starting Bluesky channel", map[string]any{
+		"handle": c.config.Handle,
+		"pds":    c.pds,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.run()
+
+	c.setRunning(true)
+	logger.InfoC("bluesky", "Bluesky channel started")
+	return nil
+}
+
+func (c *BlueskyChannel) Stop(ctx context.Context) error {
+	logger.InfoC("bluesky", "Stopping Bluesky channel")
+	c.setRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Send replies where the chat last wrote from. Post replies are split into
+// a thread and carry any image media on the last post; chats with nowhere to
+// reply get a direct message.
+func (c *BlueskyChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("bluesky channel not running")
+	}
+
+	c.mu.Lock()
+	target, ok := c.targets[msg.ChatID]
+	c.mu.Unlock()
+
+	if !ok || target.convoID != "" {
+		return c.sendDM(ctx, msg, target.convoID)
+	}
+	return c.sendPost(ctx, msg, target)
+}
+
+func (c *BlueskyChannel) sendPost(ctx context.Context, msg bus.OutboundMessage, target blueskyTarget) error {
+	embed, err := c.uploadImages(ctx, msg.Media)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(msg.Content) == "" && embed == nil {
+		return nil
+	}
+
+	chunks := []string{msg.Content}
+	if len(msg.Content) > blueskyPostLimit {
+		chunks = utils.SplitMessage(msg.Content, blueskyPostLimit)
+	}
+
+	reply := *target.reply
+	for i, chunk := range chunks {
+		record := map[string]any{
+			"$type":     "app.bsky.feed.post",
+			"text":      chunk,
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+			"reply":     reply,
+		}
+		if facets := blueskyLinkFacets(chunk); len(facets) > 0 {
+			record["facets"] = facets
+		}
+		if i == len(chunks)-1 && embed != nil {
+			record["embed"] = embed
+		}
+
+		c.mu.Lock()
+		did := c.session.DID
+		c.mu.Unlock()
+
+		var created blueskyRef
+		err := c.xrpc(ctx, xrpcJSON("com.atproto.repo.createRecord", map[string]any{
+			"repo":       did,
+			"collection": "app.bsky.feed.post",
+			"record":     record,
+		}), &created)
+		if err != nil {
+			logger.ErrorCF("bluesky", "Failed to post reply", map[string]any{
+				"chat_id": msg.ChatID,
+				"error":   err.Error(),
+			})
+			return err
+		}
+		reply.Parent = created
+	}
+
+	c.mu.Lock()
+	c.targets[msg.ChatID] = blueskyTarget{reply: &reply}
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *BlueskyChannel) sendDM(ctx context.Context, msg bus.OutboundMessage, convoID string) error {
+	if len(msg.Media) > 0 {
+		logger.WarnCF("bluesky", "Bluesky chat does not support images; sending text only", map[string]any{
+			"chat_id": msg.ChatID,
+		})
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	if convoID == "" {
+		var resp struct {
+			Convo struct {
+				ID string `json:"id"`
+			} `json:"convo"`
+		}
+		err := c.xrpc(ctx, xrpcCall{
+			method: http.MethodGet,
+			nsid:   "chat.bsky.convo.getConvoForMembers",
+			query:  url.Values{"members": {msg.ChatID}},
+			chat:   true,
+		}, &resp)
+		if err != nil {
+			return fmt.Errorf("open conversation with %s: %w", msg.ChatID, err)
+		}
+		convoID = resp.Convo.ID
+	}
+
+	chunks := []string{msg.Content}
+	if len(msg.Content) > blueskyDMLimit {
+		chunks = utils.SplitMessage(msg.Content, blueskyDMLimit)
+	}
+	for _, chunk := range chunks {
+		message := map[string]any{"text": chunk}
+		if facets := blueskyLinkFacets(chunk); len(facets) > 0 {
+			message["facets"] = facets
+		}
+		call := xrpcJSON("chat.bsky.convo.sendMessage", map[string]any{
+			"convoId": convoID,
+			"message": message,
+		})
+		call.chat = true
+		if err := c.xrpc(ctx, call, nil); err != nil {
+			logger.ErrorCF("bluesky", "Failed to send message", map[string]any{
+				"chat_id": msg.ChatID,
+				"error":   err.Error(),
+			})
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.targets[msg.ChatID] = blueskyTarget{convoID: convoID}
+	c.mu.Unlock()
+	return nil
+}
+
+// uploadImages uploads image media as blobs and returns the embed for them.
+// Other files, and images over Bluesky's size limit, are skipped.
+func (c *BlueskyChannel) uploadImages(ctx context.Context, media []string) (*blueskyEmbed, error) {
+	var images []blueskyImage
+	for _, path := range media {
+		if len(images) == blueskyMaxImages {
+			logger.WarnCF("bluesky", "Too many images, extra ones skipped", map[string]any{"path": path})
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read media: %w", err)
+		}
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") || len(data) > blueskyMaxImageSize {
+			logger.WarnCF("bluesky", "Skipping media that is not an image under 1 MB", map[string]any{
+				"path":      path,
+				"mime_type": mimeType,
+				"size":      len(data),
+			})
+			continue
+		}
+
+		var resp struct {
+			Blob blueskyBlob `json:"blob"`
+		}
+		err = c.xrpc(ctx, xrpcCall{
+			method:      http.MethodPost,
+			nsid:        "com.atproto.repo.uploadBlob",
+			body:        data,
+			contentType: mimeType,
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("upload image: %w", err)
+		}
+		images = append(images, blueskyImage{Image: resp.Blob})
+	}
+
+	if len(images) == 0 {
+		return nil, nil
+	}
+	return &blueskyEmbed{Type: blueskyEmbedImages, Images: images}, nil
+}
+
+func (c *BlueskyChannel) run() {
+	delay := blueskyMinLoginDelay
+	for {
+		err := c.login(c.ctx)
+		if err == nil {
+			break
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.ErrorCF("bluesky", "Login failed", map[string]any{
+			"error":       err.Error(),
+			"retry_after": delay.String(),
+		})
+		c.recordError(err)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > blueskyMaxLoginDelay {
+			delay = blueskyMaxLoginDelay
+		}
+	}
+
+	if c.dms {
+		// Start from the newest chat message; older ones were already seen
+		if err := c.pollChat(c.ctx, false); err != nil {
+			logger.WarnCF("bluesky", "Failed to read chat log", map[string]any{"error": err.Error()})
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(c.config.PollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		c.poll()
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *BlueskyChannel) poll() {
+	err := c.pollNotifications(c.ctx)
+	if err == nil && c.dms {
+		err = c.pollChat(c.ctx, true)
+	}
+	if c.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.WarnCF("bluesky", "Polling failed", map[string]any{"error": err.Error()})
+		c.recordError(err)
+		return
+	}
+	c.recordSuccess()
+}
+
+// login creates a new session with the app password.
+func (c *BlueskyChannel) login(ctx context.Context) error {
+	var session blueskySession
+	err := c.do(ctx, xrpcJSON("com.atproto.server.createSession", map[string]string{
+		"identifier": c.config.Handle,
+		"password":   c.config.AppPassword,
+	}), "", &session)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.mu.Unlock()
+
+	logger.InfoCF("bluesky", "Logged in", map[string]any{"handle": session.Handle, "did": session.DID})
+	return nil
+}
+
+// refresh renews the session after stale was rejected as expired, logging in
+// again when the refresh token has expired too. Concurrent callers wait for
+// one refresh.
+func (c *BlueskyChannel) refresh(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.Lock()
+	current := c.session
+	c.mu.Unlock()
+	if current.AccessJwt != stale {
+		return nil
+	}
+
+	var session blueskySession
+	err := c.do(ctx, xrpcCall{method: http.MethodPost, nsid: "com.atproto.server.refreshSession"},
+		current.RefreshJwt, &session)
+	if err != nil {
+		logger.InfoCF("bluesky", "Session refresh failed, logging in again", map[string]any{"error": err.Error()})
+		return c.login(ctx)
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.mu.Unlock()
+	logger.DebugC("bluesky", "Session refreshed")
+	return nil
+}
+
+// xrpc makes an authenticated call, refreshing the session and retrying once
+// when the access token has expired.
+func (c *BlueskyChannel) xrpc(ctx context.Context, call xrpcCall, out any) error {
+	c.mu.Lock()
+	token := c.session.AccessJwt
+	c.mu.Unlock()
+
+	err := c.do(ctx, call, token, out)
+	var xerr *blueskyError
+	if !errors.As(err, &xerr) || !xerr.expired() {
+		return err
+	}
+
+	if err := c.refresh(ctx, token); err != nil {
+		return fmt.Errorf("refresh session: %w", err)
+	}
+	c.mu.Lock()
+	token = c.session.AccessJwt
+	c.mu.Unlock()
+	return c.do(ctx, call, token, out)
+}
+
+func (c *BlueskyChannel) do(ctx context.Context, call xrpcCall, token string, out any) error {
+	endpoint := c.pds + "/xrpc/" + call.nsid
+	if len(call.query) > 0 {
+		endpoint += "?" + call.query.Encode()
+	}
+
+	var body io.Reader
+	if call.body != nil {
+		body = bytes.NewReader(call.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, call.method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if call.contentType != "" {
+		req.Header.Set("Content-Type", call.contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if call.chat {
+		req.Header.Set("atproto-proxy", blueskyChatProxy)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		xerr := &blueskyError{Status: resp.StatusCode}
+		if json.Unmarshal(data, xerr) != nil || xerr.Name == "" {
+			xerr.Name = http.StatusText(resp.StatusCode)
+			xerr.Message = utils.Truncate(string(data), 200)
+		}
+		return xerr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// pollNotifications handles unread mentions and replies, oldest first, then
+// marks them seen.
+func (c *BlueskyChannel) pollNotifications(ctx context.Context) error {
+	var resp struct {
+		Notifications []blueskyNotification `json:"notifications"`
+	}
+	err := c.xrpc(ctx, xrpcCall{
+		method: http.MethodGet,
+		nsid:   "app.bsky.notification.listNotifications",
+		query:  url.Values{"limit": {"50"}},
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	seenAt := ""
+	for i := len(resp.Notifications) - 1; i >= 0; i-- {
+		n := &resp.Notifications[i]
+		if n.IsRead {
+			continue
+		}
+		if n.IndexedAt > seenAt {
+			seenAt = n.IndexedAt
+		}
+		if n.Reason == "mention" || n.Reason == "reply" {
+			c.handlePost(n)
+		}
+	}
+
+	if seenAt == "" {
+		return nil
+	}
+	return c.xrpc(ctx, xrpcJSON("app.bsky.notification.updateSeen", map[string]string{"seenAt": seenAt}), nil)
+}
+
+func (c *BlueskyChannel) handlePost(n *blueskyNotification) {
+	c.mu.Lock()
+	self := c.session
+	c.mu.Unlock()
+	if n.Author.DID == self.DID {
+		return
+	}
+
+	var post blueskyPost
+	if err := json.Unmarshal(n.Record, &post); err != nil {
+		logger.WarnCF("bluesky", "Invalid post record", map[string]any{"uri": n.URI, "error": err.Error()})
+		return
+	}
+
+	content := stripBlueskyMention(post.Text, self.Handle)
+
+	mediaPaths := []string{}
+	defer func() {
+		for _, file := range mediaPaths {
+			if err := os.Remove(file); err != nil {
+				logger.DebugCF("bluesky", "Failed to cleanup temp file", map[string]any{
+					"file":  file,
+					"error": err.Error(),
+				})
+			}
+		}
+	}()
+	for _, img := range blueskyPostImages(post.Embed) {
+		if cid := blueskyBlobCID(img.Image); cid != "" {
+			imageURL := fmt.Sprintf("%s/img/feed_fullsize/plain/%s/%s@jpeg", blueskyCDN, n.Author.DID, cid)
+			if localPath := utils.DownloadFile(imageURL, cid+".jpg", utils.DownloadOptions{
+				LoggerPrefix: "bluesky",
+			}); localPath != "" {
+				mediaPaths = append(mediaPaths, localPath)
+			}
+		}
+		label := "[image]"
+		if img.Alt != "" {
+			label = "[image: " + img.Alt + "]"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		content += label
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	ref := blueskyRef{URI: n.URI, CID: n.CID}
+	reply := &blueskyReplyRef{Root: ref, Parent: ref}
+	if post.Reply != nil && post.Reply.Root.URI != "" {
+		reply.Root = post.Reply.Root
+	}
+
+	chatID := n.Author.DID
+	c.mu.Lock()
+	c.targets[chatID] = blueskyTarget{reply: reply}
+	c.mu.Unlock()
+
+	metadata := map[string]string{
+		"message_id":  n.URI,
+		"reason":      n.Reason,
+		"username":    n.Author.Handle,
+		"sender_name": n.Author.DisplayName,
+		"peer_kind":   "direct",
+		"peer_id":     chatID,
+	}
+
+	logger.DebugCF("bluesky", "Received post", map[string]any{
+		"sender":  n.Author.Handle,
+		"reason":  n.Reason,
+		"preview": utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(chatID+"|"+n.Author.Handle, chatID, content, mediaPaths, metadata)
+}
+
+// pollChat reads new direct messages from the chat log. With deliver unset it
+// only moves the cursor to the end of the log.
+func (c *BlueskyChannel) pollChat(ctx context.Context, deliver bool) error {
+	c.mu.Lock()
+	cursor := c.chatCursor
+	self := c.session.DID
+	c.mu.Unlock()
+
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var resp struct {
+		Cursor string           `json:"cursor"`
+		Logs   []blueskyChatLog `json:"logs"`
+	}
+	err := c.xrpc(ctx, xrpcCall{
+		method: http.MethodGet,
+		nsid:   "chat.bsky.convo.getLog",
+		query:  query,
+		chat:   true,
+	}, &resp)
+	if err != nil {
+		var xerr *blueskyError
+		if errors.As(err, &xerr) && (xerr.Status == http.StatusUnauthorized || xerr.Status == http.StatusForbidden) {
+			logger.WarnCF("bluesky", "Direct messages disabled: the app password needs direct message access",
+				map[string]any{"error": err.Error()})
+			c.dms = false
+			return nil
+		}
+		return err
+	}
+
+	if resp.Cursor != "" {
+		c.mu.Lock()
+		c.chatCursor = resp.Cursor
+		c.mu.Unlock()
+	}
+	if !deliver {
+		return nil
+	}
+
+	for _, entry := range resp.Logs {
+		if entry.Type != blueskyLogCreateMsg || entry.Message.Sender.DID == self {
+			continue
+		}
+		c.handleChatMessage(ctx, &entry)
+	}
+	return nil
+}
+
+func (c *BlueskyChannel) handleChatMessage(ctx context.Context, entry *blueskyChatLog) {
+	content := strings.TrimSpace(entry.Message.Text)
+	if content == "" {
+		return
+	}
+
+	chatID := entry.Message.Sender.DID
+	c.mu.Lock()
+	c.targets[chatID] = blueskyTarget{convoID: entry.ConvoID}
+	c.mu.Unlock()
+
+	call := xrpcJSON("chat.bsky.convo.updateRead", map[string]string{"convoId": entry.ConvoID})
+	call.chat = true
+	if err := c.xrpc(ctx, call, nil); err != nil {
+		logger.DebugCF("bluesky", "Failed to mark conversation read", map[string]any{"error": err.Error()})
+	}
+
+	handle := c.resolveHandle(ctx, chatID)
+	metadata := map[string]string{
+		"message_id": entry.Message.ID,
+		"convo_id":   entry.ConvoID,
+		"username":   handle,
+		"peer_kind":  "direct",
+		"peer_id":    chatID,
+	}
+
+	logger.DebugCF("bluesky", "Received direct message", map[string]any{
+		"sender":  handle,
+		"preview": utils.Truncate(content, 50),
+	})
+
+	senderID := chatID
+	if handle != "" {
+		senderID += "|" + handle
+	}
+	c.HandleMessage(senderID, chatID, content, nil, metadata)
+}
+
+// resolveHandle looks up the handle of a chat sender, which chat logs leave
+// out, so allow_from can list handles for DMs too.
+func (c *BlueskyChannel) resolveHandle(ctx context.Context, did string) string {
+	c.mu.Lock()
+	handle, ok := c.handles[did]
+	c.mu.Unlock()
+	if ok {
+		return handle
+	}
+
+	var profile blueskyAuthor
+	err := c.xrpc(ctx, xrpcCall{
+		method: http.MethodGet,
+		nsid:   "app.bsky.actor.getProfile",
+		query:  url.Values{"actor": {did}},
+	}, &profile)
+	if err != nil {
+		logger.DebugCF("bluesky", "Failed to resolve handle", map[string]any{"did": did, "error": err.Error()})
+		return ""
+	}
+
+	c.mu.Lock()
+	c.handles[did] = profile.Handle
+	c.mu.Unlock()
+	return profile.Handle
+}
+
+// blueskyPostImages returns the images embedded in a post, directly or
+// alongside a quoted record.
+func blueskyPostImages(embed *blueskyEmbed) []blueskyImage {
+	if embed == nil {
+		return nil
+	}
+	switch embed.Type {
+	case blueskyEmbedImages:
+		return embed.Images
+	case blueskyEmbedWithMedia:
+		return blueskyPostImages(embed.Media)
+	}
+	return nil
+}
+
+// blueskyBlobCID returns the CID of a blob reference ({"$link": cid}).
+func blueskyBlobCID(blob blueskyBlob) string {
+	if ref, ok := blob.Ref.(map[string]any); ok {
+		if link, ok := ref["$link"].(string); ok {
+			return link
+		}
+	}
+	return ""
+}
+
+// blueskyLinkFacets marks the URLs in text as links. Bluesky only renders
+// links that have a facet, indexed by UTF-8 byte offsets.
+func blueskyLinkFacets(text string) []map[string]any {
+	var facets []map[string]any
+	for _, loc := range reBlueskyLink.FindAllStringIndex(text, -1) {
+		facets = append(facets, map[string]any{
+			"index": map[string]int{"byteStart": loc[0], "byteEnd": loc[1]},
+			"features": []map[string]string{{
+				"$type": "app.bsky.richtext.facet#link",
+				"uri":   text[loc[0]:loc[1]],
+			}},
+		})
+	}
+	return facets
+}
+
+// stripBlueskyMention removes mentions of the bot's handle from text.
+func stripBlueskyMention(text, handle string) string {
+	if handle == "" {
+		return strings.TrimSpace(text)
+	}
+	re := regexp.MustCompile(`(?i)(^|\s)@` + regexp.QuoteMeta(handle) + `\b`)
+	return strings.TrimSpace(re.ReplaceAllString(text, "$1"))
+}
@@ -0,0 +1,285 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakePDS serves the XRPC methods the channel uses. The first access token
+// it hands out is treated as expired once a post is made with it.
+type fakePDS struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	notifications string
+	chatLog       string
+	calls         map[string][]map[string]any // nsid -> JSON bodies
+	refreshes     int
+	blobs         int
+}
+
+func newFakePDS(t *testing.T) *fakePDS {
+	t.Helper()
+	f := &fakePDS{calls: make(map[string][]map[string]any)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakePDS) serve(w http.ResponseWriter, r *http.Request) {
+	nsid := strings.TrimPrefix(r.URL.Path, "/xrpc/")
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]any
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		json.NewDecoder(r.Body).Decode(&body)
+	} else {
+		io.Copy(io.Discard, r.Body)
+	}
+	f.calls[nsid] = append(f.calls[nsid], body)
+
+	if strings.HasPrefix(nsid, "chat.") && r.Header.Get("atproto-proxy") != blueskyChatProxy {
+		http.Error(w, `{"error":"MethodNotImplemented","message":"no proxy"}`, http.StatusNotImplemented)
+		return
+	}
+
+	switch nsid {
+	case "com.atproto.server.createSession":
+		fmt.Fprint(w, `{"accessJwt":"access-1","refreshJwt":"refresh-1",`+
+			`"handle":"bot.bsky.social","did":"did:plc:bot"}`)
+		return
+	case "com.atproto.server.refreshSession":
+		if auth != "refresh-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"ExpiredToken","message":"refresh token expired"}`)
+			return
+		}
+		f.refreshes++
+		fmt.Fprint(w, `{"accessJwt":"access-2","refreshJwt":"refresh-2",`+
+			`"handle":"bot.bsky.social","did":"did:plc:bot"}`)
+		return
+	}
+
+	if nsid == "com.atproto.repo.createRecord" && auth == "access-1" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"ExpiredToken","message":"Token has expired"}`)
+		return
+	}
+	if auth != "access-1" && auth != "access-2" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"AuthMissing","message":"Authentication Required"}`)
+		return
+	}
+
+	switch nsid {
+	case "app.bsky.notification.listNotifications":
+		fmt.Fprint(w, f.notifications)
+	case "chat.bsky.convo.getLog":
+		fmt.Fprint(w, f.chatLog)
+	case "app.bsky.actor.getProfile":
+		fmt.Fprintf(w, `{"did":%q,"handle":"carol.bsky.social"}`, r.URL.Query().Get("actor"))
+	case "com.atproto.repo.uploadBlob":
+		f.blobs++
+		fmt.Fprintf(w, `{"blob":{"$type":"blob","ref":{"$link":"bafyblob%d"},"mimeType":%q,"size":16}}`,
+			f.blobs, r.Header.Get("Content-Type"))
+	case "com.atproto.repo.createRecord":
+		n := len(f.calls[nsid])
+		fmt.Fprintf(w, `{"uri":"at://did:plc:bot/app.bsky.feed.post/%d","cid":"cid-%d"}`, n, n)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+// posts returns the records created with a valid token.
+func (f *fakePDS) posts() []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var records []map[string]any
+	for i, call := range f.calls["com.atproto.repo.createRecord"] {
+		if i == 0 {
+			continue // rejected with the expired token
+		}
+		records = append(records, call["record"].(map[string]any))
+	}
+	return records
+}
+
+func newTestBlueskyChannel(t *testing.T, pds string) (*BlueskyChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewBlueskyChannel(config.BlueskyConfig{
+		Handle:      "bot.bsky.social",
+		AppPassword: "app-password",
+		PDS:         pds,
+		DMs:         true,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewBlueskyChannel() error = %v", err)
+	}
+	if err := ch.login(context.Background()); err != nil {
+		t.Fatalf("login() error = %v", err)
+	}
+	ch.setRunning(true)
+	return ch, msgBus
+}
+
+func TestBlueskyChannelRepliesToMentionsInThread(t *testing.T) {
+	pds := newFakePDS(t)
+	pds.notifications = `{"notifications":[
+		{"uri":"at://did:plc:alice/app.bsky.feed.post/2","cid":"cid-b","reason":"mention","isRead":false,
+		 "indexedAt":"2026-01-02T00:00:00Z","author":{"did":"did:plc:alice","handle":"alice.bsky.social"},
+		 "record":{"text":"@bot.bsky.social what is the weather?",
+		  "reply":{"root":{"uri":"at://did:plc:alice/app.bsky.feed.post/1","cid":"cid-a"},
+		           "parent":{"uri":"at://did:plc:alice/app.bsky.feed.post/1","cid":"cid-a"}}}},
+		{"uri":"at://did:plc:bob/app.bsky.feed.post/9","cid":"cid-x","reason":"mention","isRead":true,
+		 "indexedAt":"2026-01-01T00:00:00Z","author":{"did":"did:plc:bob","handle":"bob.bsky.social"},
+		 "record":{"text":"@bot.bsky.social old news"}}
+	]}`
+	ch, msgBus := newTestBlueskyChannel(t, pds.URL)
+
+	if err := ch.pollNotifications(context.Background()); err != nil {
+		t.Fatalf("pollNotifications() error = %v", err)
+	}
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.SenderID != "did:plc:alice|alice.bsky.social" || msg.ChatID != "did:plc:alice" {
+		t.Errorf("sender/chat = %q/%q", msg.SenderID, msg.ChatID)
+	}
+	if msg.Content != "what is the weather?" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if extra, ok := expectInbound(t, msgBus); ok {
+		t.Errorf("read notification was delivered: %+v", extra)
+	}
+	seen := pds.calls["app.bsky.notification.updateSeen"]
+	if len(seen) != 1 || seen[0]["seenAt"] != "2026-01-02T00:00:00Z" {
+		t.Errorf("updateSeen calls = %v", seen)
+	}
+
+	image := filepath.Join(t.TempDir(), "chart.png")
+	os.WriteFile(image, pngHeader, 0o600)
+	reply := strings.Repeat("sunny and warm ", 30) + "https://example.com/forecast"
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  msg.ChatID,
+		Content: reply,
+		Media:   []string{image},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if pds.refreshes != 1 {
+		t.Errorf("refreshes = %d, want the expired token refreshed once", pds.refreshes)
+	}
+	posts := pds.posts()
+	if len(posts) < 2 {
+		t.Fatalf("got %d posts, want the long reply split into a thread", len(posts))
+	}
+	for i, post := range posts {
+		if n := len([]rune(post["text"].(string))); n > blueskyPostLimit {
+			t.Errorf("post %d has %d characters", i, n)
+		}
+		ref := post["reply"].(map[string]any)
+		root := ref["root"].(map[string]any)
+		parent := ref["parent"].(map[string]any)
+		if root["uri"] != "at://did:plc:alice/app.bsky.feed.post/1" {
+			t.Errorf("post %d root = %v, want the thread root", i, root)
+		}
+		wantParent := "at://did:plc:alice/app.bsky.feed.post/2"
+		if i > 0 {
+			wantParent = fmt.Sprintf("at://did:plc:bot/app.bsky.feed.post/%d", i+1)
+		}
+		if parent["uri"] != wantParent {
+			t.Errorf("post %d parent = %v, want %s", i, parent["uri"], wantParent)
+		}
+		_, hasEmbed := post["embed"]
+		if hasEmbed != (i == len(posts)-1) {
+			t.Errorf("post %d embed = %v, want images on the last post only", i, post["embed"])
+		}
+	}
+
+	last := posts[len(posts)-1]
+	images := last["embed"].(map[string]any)["images"].([]any)
+	if len(images) != 1 || images[0].(map[string]any)["image"].(map[string]any)["mimeType"] != "image/png" {
+		t.Errorf("embed images = %v", images)
+	}
+	facets, _ := last["facets"].([]any)
+	if len(facets) != 1 {
+		t.Fatalf("facets = %v, want the link marked", last["facets"])
+	}
+}
+
+func TestBlueskyChannelAnswersDirectMessages(t *testing.T) {
+	pds := newFakePDS(t)
+	pds.chatLog = `{"cursor":"c1","logs":[]}`
+	ch, msgBus := newTestBlueskyChannel(t, pds.URL)
+
+	if err := ch.pollChat(context.Background(), false); err != nil {
+		t.Fatalf("pollChat() error = %v", err)
+	}
+	pds.mu.Lock()
+	pds.chatLog = `{"cursor":"c2","logs":[
+		{"$type":"chat.bsky.convo.defs#logCreateMessage","convoId":"convo-1",
+		 "message":{"id":"m1","text":"hi there","sender":{"did":"did:plc:carol"}}},
+		{"$type":"chat.bsky.convo.defs#logCreateMessage","convoId":"convo-1",
+		 "message":{"id":"m2","text":"my own message","sender":{"did":"did:plc:bot"}}}
+	]}`
+	pds.mu.Unlock()
+
+	if err := ch.pollChat(context.Background(), true); err != nil {
+		t.Fatalf("pollChat() error = %v", err)
+	}
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.SenderID != "did:plc:carol|carol.bsky.social" || msg.Content != "hi there" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if extra, ok := expectInbound(t, msgBus); ok {
+		t.Errorf("own message was delivered: %+v", extra)
+	}
+	if ch.chatCursor != "c2" {
+		t.Errorf("cursor = %q, want c2", ch.chatCursor)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: msg.ChatID, Content: "hello!"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	sent := pds.calls["chat.bsky.convo.sendMessage"]
+	if len(sent) != 1 || sent[0]["convoId"] != "convo-1" {
+		t.Fatalf("sendMessage calls = %v", sent)
+	}
+	if text := sent[0]["message"].(map[string]any)["text"]; text != "hello!" {
+		t.Errorf("message text = %v", text)
+	}
+}
+
+func TestBlueskyLinkFacetsUseByteOffsets(t *testing.T) {
+	text := "voir café → https://example.com/a?b=c."
+	facets := blueskyLinkFacets(text)
+	if len(facets) != 1 {
+		t.Fatalf("facets = %v", facets)
+	}
+	index := facets[0]["index"].(map[string]int)
+	if got := text[index["byteStart"]:index["byteEnd"]]; got != "https://example.com/a?b=c" {
+		t.Errorf("facet covers %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+)
+
+// ButtonChannel is implemented by channels that render
+// OutboundMessage.Buttons natively. For other channels the manager lists the
+// choices in the message text.
+type ButtonChannel interface {
+	SupportsButtons() bool
+}
+
+func supportsButtons(channel Channel) bool {
+	bc, ok := channel.(ButtonChannel)
+	return ok && bc.SupportsButtons()
+}
+
+// buttonsText lists the replies that stand in for buttons, introduced in
+// lang.
+func buttonsText(lang string, buttons []bus.Button) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, i18n.ReplyWith))
+	for _, b := range buttons {
+		fmt.Fprintf(&sb, "\n• %s (%s)", b.Value, b.Label)
+	}
+	return sb.String()
+}
+
+// withButtonsAsText moves the buttons of msg into its text for channels
+// that can't show them.
+func withButtonsAsText(channel Channel, msg bus.OutboundMessage) bus.OutboundMessage {
+	if len(msg.Buttons) == 0 || supportsButtons(channel) {
+		return msg
+	}
+	if msg.Content != "" {
+		msg.Content += "\n\n"
+	}
+	msg.Content += buttonsText(channelLanguage(channel), msg.Buttons)
+	msg.Buttons = nil
+	return msg
+}
@@ -0,0 +1,335 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// deliveryHistory is how many deliveries the log keeps. Accepted ones
+	// are dropped first, so failures stay visible to /undelivered.
+	deliveryHistory = 200
+
+	deliveryRetryBase = 2 * time.Second
+	deliveryRetryMax  = time.Minute
+)
+
+// DeliveryStatus is where an outbound message stands.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is a message being sent or waiting for a retry.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryAccepted is a message the platform took in full.
+	DeliveryAccepted DeliveryStatus = "accepted"
+	// DeliveryFailed is a message given up on.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery records the sending of one outbound message. Attempts above one
+// mean it was retried.
+type Delivery struct {
+	ID        int
+	Channel   string
+	ChatID    string
+	Preview   string // start of the content
+	Status    DeliveryStatus
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	NextRetry time.Time // zero unless a retry is scheduled
+}
+
+// DeliveryStats counts the deliveries in the log by outcome.
+type DeliveryStats struct {
+	Accepted, Pending, Failed, Retried int
+}
+
+// DeliveryLog keeps the most recent outbound deliveries. A nil log records
+// nothing.
+type DeliveryLog struct {
+	mu      sync.Mutex
+	seq     int
+	entries []*Delivery // oldest first
+	limit   int
+	now     func() time.Time
+}
+
+func NewDeliveryLog(limit int) *DeliveryLog {
+	return &DeliveryLog{limit: limit, now: time.Now}
+}
+
+// start records a new pending delivery and returns its ID.
+func (l *DeliveryLog) start(msg bus.OutboundMessage) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	now := l.now()
+	l.entries = append(l.entries, &Delivery{
+		ID:        l.seq,
+		Channel:   msg.Channel,
+		ChatID:    msg.ChatID,
+		Preview:   utils.Truncate(strings.Join(strings.Fields(msg.Content), " "), 60),
+		Status:    DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if len(l.entries) > l.limit {
+		l.evict()
+	}
+	return l.seq
+}
+
+// evict drops the oldest accepted delivery, or the oldest of all when none
+// was accepted; callers hold mu.
+func (l *DeliveryLog) evict() {
+	drop := 0
+	for i, d := range l.entries {
+		if d.Status == DeliveryAccepted {
+			drop = i
+			break
+		}
+	}
+	l.entries = append(l.entries[:drop], l.entries[drop+1:]...)
+}
+
+// attempted records the outcome of one attempt. A nil err marks the
+// delivery accepted; otherwise it stays pending if retryAt is set, or fails.
+func (l *DeliveryLog) attempted(id int, err error, retryAt time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, d := range l.entries {
+		if d.ID != id {
+			continue
+		}
+		d.Attempts++
+		d.UpdatedAt = l.now()
+		d.NextRetry = retryAt
+		switch {
+		case err == nil:
+			d.Status = DeliveryAccepted
+		case retryAt.IsZero():
+			d.Status = DeliveryFailed
+			d.LastError = err.Error()
+		default:
+			d.LastError = err.Error()
+		}
+		return
+	}
+}
+
+// Undelivered returns the pending and failed deliveries, oldest first.
+func (l *DeliveryLog) Undelivered() []Delivery {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Delivery
+	for _, d := range l.entries {
+		if d.Status != DeliveryAccepted {
+			out = append(out, *d)
+		}
+	}
+	return out
+}
+
+// Stats counts the logged deliveries by outcome.
+func (l *DeliveryLog) Stats() DeliveryStats {
+	var stats DeliveryStats
+	if l == nil {
+		return stats
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, d := range l.entries {
+		switch d.Status {
+		case DeliveryAccepted:
+			stats.Accepted++
+		case DeliveryPending:
+			stats.Pending++
+		case DeliveryFailed:
+			stats.Failed++
+		}
+		if d.Attempts > 1 {
+			stats.Retried++
+		}
+	}
+	return stats
+}
+
+// FormatDeliveries renders deliveries as one line each, relative to now.
+func FormatDeliveries(deliveries []Delivery, now time.Time) string {
+	var sb strings.Builder
+	for i, d := range deliveries {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "#%d %s:%s %s, %s", d.ID, d.Channel, d.ChatID, d.Status, formatAgo(now.Sub(d.CreatedAt)))
+		if d.Attempts > 0 {
+			fmt.Fprintf(&sb, ", %d attempts", d.Attempts)
+		}
+		if d.LastError != "" {
+			fmt.Fprintf(&sb, ", last error: %s", d.LastError)
+		}
+		if !d.NextRetry.IsZero() {
+			sb.WriteString(", retrying in " + d.NextRetry.Sub(now).Round(time.Second).String())
+		}
+		if d.Preview != "" {
+			fmt.Fprintf(&sb, "\n  %q", d.Preview)
+		}
+	}
+	return sb.String()
+}
+
+// Deliveries returns the log of outbound deliveries.
+func (m *Manager) Deliveries() *DeliveryLog {
+	return m.deliveries
+}
+
+// deliver sends a reply and records the outcome. Transient failures are
+// retried in the background, resuming at the first part not yet sent;
+// cleanup runs once the delivery is accepted or given up on.
+func (m *Manager) deliver(ctx context.Context, channel Channel, msg bus.OutboundMessage, cleanup func()) {
+	parts := splitMessage(channel, msg)
+	id := m.deliveries.start(msg)
+	m.sendParts(ctx, channel, id, parts, 1, cleanup)
+}
+
+func (m *Manager) sendParts(
+	ctx context.Context,
+	channel Channel,
+	id int,
+	parts []bus.OutboundMessage,
+	attempt int,
+	cleanup func(),
+) {
+	var err error
+	for len(parts) > 0 {
+		if err = sendSafely(ctx, channel, parts[0]); err != nil {
+			break
+		}
+		parts = parts[1:]
+	}
+	recordSendResult(channel, err)
+
+	if err == nil {
+		m.deliveries.attempted(id, nil, time.Time{})
+		if attempt > 1 {
+			logger.InfoCF("channels", "Message delivered after retrying", map[string]any{
+				"channel":  channel.Name(),
+				"attempts": attempt,
+			})
+		}
+		cleanup()
+		return
+	}
+
+	if attempt <= m.config.Channels.Delivery.MaxRetries && ctx.Err() == nil && isTransientSendError(channel, err) {
+		delay := m.retryDelay(attempt)
+		m.deliveries.attempted(id, err, time.Now().Add(delay))
+		logger.WarnCF("channels", "Error sending message to channel, retrying", map[string]any{
+			"channel": channel.Name(),
+			"attempt": attempt,
+			"delay":   delay.String(),
+			"error":   err.Error(),
+		})
+		time.AfterFunc(delay, func() {
+			m.sendParts(ctx, channel, id, parts, attempt+1, cleanup)
+		})
+		return
+	}
+
+	m.deliveries.attempted(id, err, time.Time{})
+	logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
+		"channel":  channel.Name(),
+		"attempts": attempt,
+		"error":    err.Error(),
+	})
+	cleanup()
+}
+
+// recordSendResult counts a send against the channel's health.
+func recordSendResult(channel Channel, err error) {
+	if hr, ok := channel.(healthRecorder); ok {
+		if err != nil {
+			hr.recordError(err)
+		} else {
+			hr.recordSuccess()
+		}
+	}
+}
+
+// retryDelay doubles from retryBase for each attempt, up to a minute.
+func (m *Manager) retryDelay(attempt int) time.Duration {
+	delay := m.retryBase
+	if delay <= 0 {
+		delay = deliveryRetryBase
+	}
+	for i := 1; i < attempt && delay < deliveryRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, deliveryRetryMax)
+}
+
+// transientStatus matches HTTP statuses worth retrying as channels and SDKs
+// put them in error messages, e.g. "status 503" or "HTTP 429".
+var transientStatus = regexp.MustCompile(`(?i)(status|http|code)\D{0,12}\b(429|5\d\d)\b`)
+
+// isTransientSendError reports whether a failed send may work if tried
+// again: the channel is down and will be restarted, the network failed, or
+// the platform is overloaded or rate limiting.
+func isTransientSendError(channel Channel, err error) bool {
+	if !channel.IsRunning() {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	text := strings.ToLower(err.Error())
+	for _, hint := range []string{
+		"timeout", "timed out", "connection reset", "connection refused", "broken pipe",
+		"unexpected eof", "not connected", "too many requests", "rate limit", "temporarily",
+	} {
+		if strings.Contains(text, hint) {
+			return true
+		}
+	}
+	return transientStatus.MatchString(text)
+}
@@ -0,0 +1,201 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// failingChannel answers its sends with failures in order, a nil entry
+// accepting the message, then accepts the rest.
+type failingChannel struct {
+	*BaseChannel
+	mu       sync.Mutex
+	failures []error
+	sent     []string
+}
+
+func (c *failingChannel) Start(context.Context) error { c.setRunning(true); return nil }
+func (c *failingChannel) Stop(context.Context) error  { c.setRunning(false); return nil }
+
+func (c *failingChannel) Send(_ context.Context, msg bus.OutboundMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.failures) > 0 {
+		err := c.failures[0]
+		c.failures = c.failures[1:]
+		if err != nil {
+			return err
+		}
+	}
+	c.sent = append(c.sent, msg.Content)
+	return nil
+}
+
+func waitForDelivery(t *testing.T, log *DeliveryLog, done func(DeliveryStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done(log.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery did not finish, stats = %+v", log.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverRetriesTransientFailures(t *testing.T) {
+	ch := &failingChannel{
+		BaseChannel: NewBaseChannel("discord", nil, nil, nil),
+		failures: []error{
+			nil,
+			fmt.Errorf("discord API returned status 503"),
+			fmt.Errorf("dial tcp: connection reset by peer"),
+		},
+	}
+	ch.setRunning(true)
+	m := newTestManager(map[string]Channel{"discord": ch})
+	m.deliveries = NewDeliveryLog(10)
+	m.retryBase = time.Millisecond
+
+	// The reply is split in two; the second part fails twice before it goes
+	// through, and the first must not be sent again
+	first, second := strings.Repeat("a", 1500), strings.Repeat("b", 1500)
+	cleaned := make(chan struct{})
+	m.deliver(context.Background(), ch, bus.OutboundMessage{
+		Channel: "discord",
+		ChatID:  "c1",
+		Content: first + "\n\n" + second,
+	}, func() { close(cleaned) })
+
+	waitForDelivery(t, m.deliveries, func(s DeliveryStats) bool { return s.Accepted == 1 })
+	<-cleaned
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(ch.sent) != 2 || ch.sent[0] != first || ch.sent[1] != second {
+		t.Errorf("sent %d parts, want the two halves once each", len(ch.sent))
+	}
+	if d := m.deliveries.entries[0]; d.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", d.Attempts)
+	}
+	if stats := m.deliveries.Stats(); stats.Retried != 1 || stats.Failed != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(m.deliveries.Undelivered()) != 0 {
+		t.Errorf("undelivered = %+v", m.deliveries.Undelivered())
+	}
+}
+
+func TestDeliverGivesUpOnPermanentFailures(t *testing.T) {
+	ch := &failingChannel{
+		BaseChannel: NewBaseChannel("telegram", nil, nil, nil),
+		failures:    []error{errors.New("Bad Request: chat not found")},
+	}
+	ch.setRunning(true)
+	m := newTestManager(map[string]Channel{"telegram": ch})
+	m.deliveries = NewDeliveryLog(10)
+	m.retryBase = time.Millisecond
+
+	cleaned := false
+	m.deliver(context.Background(), ch, bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "Hello there"},
+		func() { cleaned = true })
+
+	undelivered := m.deliveries.Undelivered()
+	if len(undelivered) != 1 || !cleaned {
+		t.Fatalf("undelivered = %+v, cleaned = %v", undelivered, cleaned)
+	}
+	d := undelivered[0]
+	if d.Status != DeliveryFailed || d.Attempts != 1 || d.LastError != "Bad Request: chat not found" ||
+		d.Channel != "telegram" || d.ChatID != "42" || d.Preview != "Hello there" {
+		t.Errorf("delivery = %+v", d)
+	}
+	if h := ch.Health(); h.ConsecutiveErrors != 1 {
+		t.Errorf("health errors = %d, want 1", h.ConsecutiveErrors)
+	}
+}
+
+func TestDeliverStopsAfterMaxRetries(t *testing.T) {
+	timeout := errors.New("request timed out")
+	ch := &failingChannel{
+		BaseChannel: NewBaseChannel("slack", nil, nil, nil),
+		failures:    []error{timeout, timeout, timeout, timeout, timeout},
+	}
+	ch.setRunning(true)
+	m := newTestManager(map[string]Channel{"slack": ch})
+	m.config.Channels.Delivery.MaxRetries = 2
+	m.deliveries = NewDeliveryLog(10)
+	m.retryBase = time.Millisecond
+
+	m.deliver(context.Background(), ch, bus.OutboundMessage{Channel: "slack", ChatID: "C1", Content: "hi"}, func() {})
+
+	waitForDelivery(t, m.deliveries, func(s DeliveryStats) bool { return s.Failed == 1 })
+	if d := m.deliveries.Undelivered()[0]; d.Attempts != 3 || !d.NextRetry.IsZero() {
+		t.Errorf("delivery = %+v, want 3 attempts and no retry scheduled", d)
+	}
+}
+
+func TestIsTransientSendError(t *testing.T) {
+	running := &recordingChannel{BaseChannel: NewBaseChannel("telegram", nil, nil, nil)}
+	running.setRunning(true)
+	stopped := &recordingChannel{BaseChannel: NewBaseChannel("telegram", nil, nil, nil)}
+
+	tests := []struct {
+		channel Channel
+		err     error
+		want    bool
+	}{
+		{running, fmt.Errorf("send: %w", context.DeadlineExceeded), true},
+		{running, errors.New("LINE API error (status 502): bad gateway"), true},
+		{running, errors.New("telego: sendMessage: api: 429 \"Too Many Requests: retry after 3\""), true},
+		{running, errors.New("HTTP 503 Service Unavailable"), true},
+		{running, errors.New("mqtt broker not connected"), true},
+		{running, errors.New("LINE API error (status 400): invalid reply token"), false},
+		{running, errors.New("Bad Request: chat not found"), false},
+		{running, errors.New("no stream attached to session 500"), false},
+		{stopped, errors.New("telegram channel not running"), true},
+	}
+	for _, tt := range tests {
+		if got := isTransientSendError(tt.channel, tt.err); got != tt.want {
+			t.Errorf("isTransientSendError(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryLogKeepsFailuresWhenFull(t *testing.T) {
+	log := NewDeliveryLog(3)
+	failed := log.start(bus.OutboundMessage{Channel: "telegram", ChatID: "1", Content: "lost"})
+	log.attempted(failed, errors.New("chat not found"), time.Time{})
+	for i := range 5 {
+		id := log.start(bus.OutboundMessage{Channel: "telegram", ChatID: "1", Content: fmt.Sprint(i)})
+		log.attempted(id, nil, time.Time{})
+	}
+
+	if stats := log.Stats(); stats.Accepted != 2 || stats.Failed != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	undelivered := log.Undelivered()
+	if len(undelivered) != 1 || undelivered[0].Preview != "lost" {
+		t.Errorf("undelivered = %+v", undelivered)
+	}
+}
+
+func TestFormatDeliveries(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	got := FormatDeliveries([]Delivery{
+		{ID: 3, Channel: "telegram", ChatID: "42", Preview: "Your report is ready", Status: DeliveryFailed,
+			Attempts: 4, LastError: "status 503", CreatedAt: now.Add(-5 * time.Minute)},
+		{ID: 7, Channel: "slack", ChatID: "C1", Status: DeliveryPending,
+			Attempts: 1, LastError: "timeout", CreatedAt: now, NextRetry: now.Add(4 * time.Second)},
+	}, now)
+	want := "#3 telegram:42 failed, 5m ago, 4 attempts, last error: status 503\n" +
+		"  \"Your report is ready\"\n" +
+		"#7 slack:C1 pending, just now, 1 attempts, last error: timeout, retrying in 4s"
+	if got != want {
+		t.Errorf("FormatDeliveries() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -163,6 +163,13 @@ func (c *DingTalkChannel) onChatBotMessageReceived(
 		metadata["peer_kind"] = "direct"
 		metadata["peer_id"] = senderID
 	} else {
+		if !c.acceptGroupMessage(chatID, senderID, content, data.IsInAtList) {
+			logger.DebugCF("dingtalk", "Group message ignored (no mention)", map[string]any{
+				"sender_id":       senderID,
+				"conversation_id": data.ConversationId,
+			})
+			return nil, nil
+		}
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = data.ConversationId
 	}
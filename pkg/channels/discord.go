@@ -24,15 +24,19 @@ const (
 
 type DiscordChannel struct {
 	*BaseChannel
-	session     *discordgo.Session
-	config      config.DiscordConfig
-	transcriber *voice.GroqTranscriber
-	ctx         context.Context
-	typingMu    sync.Mutex
-	typingStop  map[string]chan struct{} // chatID → stop signal
-	botUserID   string                   // stored for mention checking
+	session      *discordgo.Session
+	config       config.DiscordConfig
+	transcriber  voice.Transcriber
+	ctx          context.Context
+	typingMu     sync.Mutex
+	typingStop   map[string]chan struct{} // chatID → stop signal
+	botUserID    string                   // stored for mention checking
+	placeholders *progressPlaceholders
+	replies      *replyTracker
 }
 
+var _ ProgressChannel = (*DiscordChannel)(nil)
+
 func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordChannel, error) {
 	session, err := discordgo.New("Bot " + cfg.Token)
 	if err != nil {
@@ -42,16 +46,18 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 	base := NewBaseChannel("discord", cfg, bus, cfg.AllowFrom)
 
 	return &DiscordChannel{
-		BaseChannel: base,
-		session:     session,
-		config:      cfg,
-		transcriber: nil,
-		ctx:         context.Background(),
-		typingStop:  make(map[string]chan struct{}),
+		BaseChannel:  base,
+		session:      session,
+		config:       cfg,
+		transcriber:  nil,
+		ctx:          context.Background(),
+		typingStop:   make(map[string]chan struct{}),
+		placeholders: newProgressPlaceholders(),
+		replies:      newReplyTracker(),
 	}, nil
 }
 
-func (c *DiscordChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *DiscordChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
@@ -75,6 +81,14 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	c.botUserID = botUser.ID
 
 	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(c.handleReaction)
+	// discordgo reconnects the gateway by itself; track it for /status
+	c.session.AddHandler(func(s *discordgo.Session, d *discordgo.Disconnect) {
+		c.recordError(fmt.Errorf("gateway disconnected"))
+	})
+	c.session.AddHandler(func(s *discordgo.Session, r *discordgo.Connect) {
+		c.recordSuccess()
+	})
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -109,7 +123,22 @@ func (c *DiscordChannel) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Notify messages a user in a DM. Discord user and channel IDs look alike,
+// so an ID that isn't a user is sent to as a channel.
+func (c *DiscordChannel) Notify(ctx context.Context, userID, content string) error {
+	chatID := userID
+	if dm, err := c.session.UserChannelCreate(userID, discordgo.WithContext(ctx)); err == nil {
+		chatID = dm.ID
+	}
+	return c.Send(ctx, bus.OutboundMessage{Channel: c.Name(), ChatID: chatID, Content: content})
+}
+
 func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if msg.Progress {
+		// Keep typing: the answer is still on its way
+		return c.sendProgress(ctx, msg.ChatID, msg.Content)
+	}
+
 	c.stopTyping(msg.ChatID)
 
 	if !c.IsRunning() {
@@ -128,6 +157,15 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 
 	chunks := utils.SplitMessage(msg.Content, 2000) // Split messages into chunks, Discord length limit: 2000 chars
 
+	// The first chunk replaces the progress placeholder, if there is one
+	if messageID, ok := c.placeholders.take(channelID); ok {
+		if _, err := c.session.ChannelMessageEdit(channelID, messageID, chunks[0],
+			discordgo.WithContext(ctx)); err == nil {
+			c.replies.Remember(channelID, messageID, sentReply{channelID, chunks[0]})
+			chunks = chunks[1:]
+		}
+	}
+
 	for _, chunk := range chunks {
 		if err := c.sendChunk(ctx, channelID, chunk); err != nil {
 			return err
@@ -144,7 +182,10 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content strin
 
 	done := make(chan error, 1)
 	go func() {
-		_, err := c.session.ChannelMessageSend(channelID, content)
+		sent, err := c.session.ChannelMessageSend(channelID, content)
+		if err == nil {
+			c.replies.Remember(channelID, sent.ID, sentReply{channelID, content})
+		}
 		done <- err
 	}()
 
@@ -159,6 +200,37 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content strin
 	}
 }
 
+// SupportsProgress reports whether interim updates are shown in a
+// placeholder message
+func (c *DiscordChannel) SupportsProgress() bool {
+	return c.config.ProgressiveReply
+}
+
+// sendProgress posts the chat's placeholder on the first update and edits
+// it on later ones.
+func (c *DiscordChannel) sendProgress(ctx context.Context, channelID, content string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("discord bot not running")
+	}
+
+	text := progressText(content)
+	messageID, ok := c.placeholders.update(channelID, text)
+	if !ok {
+		return nil
+	}
+
+	if messageID != "" {
+		_, err := c.session.ChannelMessageEdit(channelID, messageID, text, discordgo.WithContext(ctx))
+		return err
+	}
+	sent, err := c.session.ChannelMessageSend(channelID, text, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send discord progress: %w", err)
+	}
+	c.placeholders.set(channelID, sent.ID, text)
+	return nil
+}
+
 // appendContent safely appends content to existing text
 func appendContent(content, suffix string) string {
 	if content == "" {
@@ -176,25 +248,11 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		return
 	}
 
-	// Check allowlist first to avoid downloading attachments and transcribing for rejected users
-	if !c.IsAllowed(m.Author.ID) {
-		logger.DebugCF("discord", "Message rejected by allowlist", map[string]any{
-			"user_id": m.Author.ID,
-		})
-		return
-	}
-
-	// If configured to only respond to mentions, check if bot is mentioned
-	// Skip this check for DMs (GuildID is empty) - DMs should always be responded to
-	if c.config.MentionOnly && m.GuildID != "" {
-		isMentioned := false
-		for _, mention := range m.Mentions {
-			if mention.ID == c.botUserID {
-				isMentioned = true
-				break
-			}
-		}
-		if !isMentioned {
+	// In servers, only answer when addressed. mention_only on the Discord
+	// config also disables trigger words; DMs are always answered.
+	if m.GuildID != "" {
+		mentioned := c.mentionsBot(m)
+		if (c.config.MentionOnly && !mentioned) || !c.acceptGroupMessage(m.ChannelID, m.Author.ID, m.Content, mentioned) {
 			logger.DebugCF("discord", "Message ignored - bot not mentioned", map[string]any{
 				"user_id": m.Author.ID,
 			})
@@ -202,6 +260,12 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		}
 	}
 
+	// Check allowlist first to avoid downloading attachments and transcribing for rejected users
+	if !c.IsAllowed(m.Author.ID) {
+		c.RejectSender(m.Author.ID, m.ChannelID)
+		return
+	}
+
 	senderID := m.Author.ID
 	senderName := m.Author.Username
 	if m.Author.Discriminator != "" && m.Author.Discriminator != "0" {
@@ -276,6 +340,15 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 	if content == "" {
 		content = "[media only]"
 	}
+	if ref := m.ReferencedMessage; ref != nil {
+		author := "someone"
+		if ref.Author != nil && ref.Author.ID == c.botUserID {
+			author = ""
+		} else if ref.Author != nil {
+			author = ref.Author.Username
+		}
+		content = withQuote(content, author, ref.Content)
+	}
 
 	// Start typing after all early returns — guaranteed to have a matching Send()
 	c.startTyping(m.ChannelID)
@@ -304,10 +377,49 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"peer_kind":    peerKind,
 		"peer_id":      peerID,
 	}
+	if m.ReferencedMessage != nil {
+		metadata["reply_to_message_id"] = m.ReferencedMessage.ID
+	}
 
 	c.HandleMessage(senderID, m.ChannelID, content, mediaPaths, metadata)
 }
 
+// handleReaction takes a 👍 or 👎 on one of the bot's replies as feedback.
+func (c *DiscordChannel) handleReaction(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.UserID == c.botUserID {
+		return
+	}
+	reply, ok := c.replies.Reply(r.ChannelID, r.MessageID)
+	if !ok {
+		return
+	}
+
+	peerKind, peerID := "channel", r.ChannelID
+	if r.GuildID == "" {
+		peerKind, peerID = "direct", r.UserID
+	}
+	c.HandleFeedback(r.UserID, r.MessageID, reactionRating(r.Emoji.Name), reply, map[string]string{
+		"user_id":    r.UserID,
+		"guild_id":   r.GuildID,
+		"channel_id": r.ChannelID,
+		"is_dm":      fmt.Sprintf("%t", r.GuildID == ""),
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+	})
+}
+
+// mentionsBot reports whether a server message mentions the bot or replies
+// to one of its messages.
+func (c *DiscordChannel) mentionsBot(m *discordgo.MessageCreate) bool {
+	for _, mention := range m.Mentions {
+		if mention.ID == c.botUserID {
+			return true
+		}
+	}
+	ref := m.ReferencedMessage
+	return ref != nil && ref.Author != nil && ref.Author.ID == c.botUserID
+}
+
 // startTyping starts a continuous typing indicator loop for the given chatID.
 // It stops any existing typing loop for that chatID before starting a new one.
 func (c *DiscordChannel) startTyping(chatID string) {
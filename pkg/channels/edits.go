@@ -0,0 +1,71 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Edited user messages
+// Platforms that report edits can have the turn run again with the new
+// text, answering it in a new message or rewriting the earlier answer
+
+package channels
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Values of a channel's on_edit setting.
+const (
+	EditIgnore  = "ignore"  // edits are dropped (the default)
+	EditAppend  = "append"  // the edited message is answered anew
+	EditReplace = "replace" // the new answer overwrites the old one
+)
+
+// maxTrackedAnswers bounds how many answers an answerTracker remembers
+// before forgetting the oldest.
+const maxTrackedAnswers = 1024
+
+func validateEditMode(mode string) error {
+	switch mode {
+	case "", EditIgnore, EditAppend, EditReplace:
+		return nil
+	}
+	return fmt.Errorf("on_edit must be %q, %q or %q, got %q", EditIgnore, EditAppend, EditReplace, mode)
+}
+
+// editedContent marks the new text of an edited message, so the model
+// treats it as a correction of the earlier message rather than a new one.
+func editedContent(content string) string {
+	return "[edited message] " + content
+}
+
+// answerTracker maps user messages to the bot message that answered them,
+// so an edit can overwrite the answer in place.
+type answerTracker struct {
+	mu      sync.Mutex
+	answers map[string]string
+	order   []string
+}
+
+func newAnswerTracker() *answerTracker {
+	return &answerTracker{answers: make(map[string]string)}
+}
+
+// Remember records answerID as the answer to messageID in chatID.
+func (t *answerTracker) Remember(chatID, messageID, answerID string) {
+	key := chatID + "/" + messageID
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.answers[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.answers[key] = answerID
+	for len(t.order) > maxTrackedAnswers {
+		delete(t.answers, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// Answer returns the answer to messageID in chatID, if one was remembered.
+func (t *answerTracker) Answer(chatID, messageID string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	answerID, ok := t.answers[chatID+"/"+messageID]
+	return answerID, ok
+}
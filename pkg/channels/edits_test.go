@@ -0,0 +1,35 @@
+package channels
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAnswerTracker(t *testing.T) {
+	tr := newAnswerTracker()
+	tr.Remember("chat", "10", "11")
+	if got, ok := tr.Answer("chat", "10"); !ok || got != "11" {
+		t.Errorf("Answer(10) = %q, %v", got, ok)
+	}
+	if _, ok := tr.Answer("other", "10"); ok {
+		t.Error("answer found in another chat")
+	}
+
+	for i := 0; i < maxTrackedAnswers; i++ {
+		tr.Remember("chat", fmt.Sprint(100+i), "x")
+	}
+	if _, ok := tr.Answer("chat", "10"); ok {
+		t.Error("oldest answer still tracked")
+	}
+}
+
+func TestValidateEditMode(t *testing.T) {
+	for _, mode := range []string{"", EditIgnore, EditAppend, EditReplace} {
+		if err := validateEditMode(mode); err != nil {
+			t.Errorf("validateEditMode(%q) = %v", mode, err)
+		}
+	}
+	if err := validateEditMode("rerun"); err == nil {
+		t.Error("validateEditMode(rerun) succeeded, want an error")
+	}
+}
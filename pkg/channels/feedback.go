@@ -0,0 +1,89 @@
+package channels
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// reactionRating maps a reaction to a feedback rating, or "" for reactions
+// that aren't feedback. It accepts emoji as Telegram and Discord send them
+// and Slack's reaction names, with or without a skin tone.
+func reactionRating(reaction string) string {
+	reaction, _, _ = strings.Cut(reaction, "::") // Slack's "+1::skin-tone-2"
+	reaction = strings.TrimRight(reaction, "\U0001F3FB\U0001F3FC\U0001F3FD\U0001F3FE\U0001F3FF\uFE0F")
+	switch reaction {
+	case "👍", "+1", "thumbsup":
+		return bus.FeedbackUp
+	case "👎", "-1", "thumbsdown":
+		return bus.FeedbackDown
+	}
+	return ""
+}
+
+// sentReply is a reply the bot sent, remembered so a reaction to it can be
+// taken as feedback.
+type sentReply struct {
+	chatID  string // chat ID the reply was sent to, including any thread
+	content string
+}
+
+// replyTracker remembers the bot's recent replies by platform message ID.
+type replyTracker struct {
+	mu      sync.Mutex
+	replies map[string]sentReply
+	order   []string
+}
+
+func newReplyTracker() *replyTracker {
+	return &replyTracker{replies: make(map[string]sentReply)}
+}
+
+// Remember records reply as bot message messageID in chatID.
+func (t *replyTracker) Remember(chatID, messageID string, reply sentReply) {
+	key := chatID + "/" + messageID
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.replies[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.replies[key] = reply
+	for len(t.order) > maxTrackedAnswers {
+		delete(t.replies, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// Reply returns the bot message messageID in chatID, if it was remembered.
+func (t *replyTracker) Reply(chatID, messageID string) (sentReply, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reply, ok := t.replies[chatID+"/"+messageID]
+	return reply, ok
+}
+
+// HandleFeedback publishes a user's rating of the bot reply messageID. The
+// agent records it with the session instead of answering, so senders that
+// aren't allowed are dropped without the usual notice.
+func (c *BaseChannel) HandleFeedback(senderID, messageID, rating string, reply sentReply, metadata map[string]string) {
+	c.recordEvent()
+	if rating == "" || !c.IsAllowed(senderID) {
+		return
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["feedback"] = rating
+	metadata["feedback_message_id"] = messageID
+	metadata["feedback_reply"] = reply.content
+
+	c.bus.PublishInbound(bus.InboundMessage{
+		Channel:  c.name,
+		SenderID: senderID,
+		ChatID:   reply.chatID,
+		Content:  "[feedback: " + rating + "]",
+		Metadata: metadata,
+	})
+}
@@ -0,0 +1,108 @@
+package channels
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestReactionRating(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"👍", bus.FeedbackUp},
+		{"👍🏽", bus.FeedbackUp},
+		{"+1", bus.FeedbackUp},
+		{"thumbsup::skin-tone-3", bus.FeedbackUp},
+		{"👎", bus.FeedbackDown},
+		{"-1", bus.FeedbackDown},
+		{"thumbsdown", bus.FeedbackDown},
+		{"❤", ""},
+		{"eyes", ""},
+	}
+	for _, tt := range tests {
+		if got := reactionRating(tt.in); got != tt.want {
+			t.Errorf("reactionRating(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSlackChannelReactionFeedback(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewSlackChannel(config.SlackConfig{
+		BotToken:  "xoxb-test",
+		AppToken:  "xapp-test",
+		AllowFrom: config.FlexibleStringSlice{"U1"},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewSlackChannel() error = %v", err)
+	}
+	ch.botUserID = "UBOT"
+	ch.replies.Remember("C1", "1700.01", sentReply{chatID: "C1/1699.00", content: "Try restarting it."})
+
+	react := func(user, reaction, itemUser, ts string) {
+		ch.handleReaction(&slackevents.ReactionAddedEvent{
+			User:     user,
+			Reaction: reaction,
+			ItemUser: itemUser,
+			Item:     slackevents.Item{Type: "message", Channel: "C1", Timestamp: ts},
+		})
+	}
+
+	react("U1", "-1", "UBOT", "1700.01")
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected a feedback message")
+	}
+	if msg.ChatID != "C1/1699.00" || msg.SenderID != "U1" {
+		t.Errorf("chat/sender = %q/%q", msg.ChatID, msg.SenderID)
+	}
+	want := map[string]string{
+		"feedback":            bus.FeedbackDown,
+		"feedback_message_id": "1700.01",
+		"feedback_reply":      "Try restarting it.",
+		"thread_id":           "1699.00",
+		"peer_kind":           "channel",
+	}
+	for k, v := range want {
+		if msg.Metadata[k] != v {
+			t.Errorf("metadata[%q] = %q, want %q", k, msg.Metadata[k], v)
+		}
+	}
+
+	// Other reactions, unknown messages, other users' messages and senders
+	// outside the allow list are not feedback
+	react("U1", "tada", "UBOT", "1700.01")
+	react("U1", "+1", "UBOT", "1800.00")
+	react("U1", "+1", "U2", "1700.01")
+	react("U3", "+1", "UBOT", "1700.01")
+	if extra, ok := expectInbound(t, msgBus); ok {
+		t.Errorf("unexpected message: %+v", extra)
+	}
+}
+
+func TestDiscordChannelReactionFeedback(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewDiscordChannel(config.DiscordConfig{Token: "test"}, msgBus)
+	if err != nil {
+		t.Fatalf("NewDiscordChannel() error = %v", err)
+	}
+	ch.botUserID = "bot"
+	ch.replies.Remember("dm1", "m1", sentReply{chatID: "dm1", content: "Done."})
+
+	ch.handleReaction(nil, &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    "u1",
+		MessageID: "m1",
+		ChannelID: "dm1",
+		Emoji:     discordgo.Emoji{Name: "👍"},
+	}})
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected a feedback message")
+	}
+	if msg.ChatID != "dm1" || msg.Metadata["feedback"] != bus.FeedbackUp || msg.Metadata["peer_id"] != "u1" {
+		t.Errorf("message = %+v", msg)
+	}
+}
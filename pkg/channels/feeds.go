@@ -0,0 +1,482 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Feed watcher channel implementation
+// Polls RSS and Atom feeds and turns each new item into an agent turn,
+// forwarding the agent's reply to a chat channel or the push channel
+
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	feedsChannelName = "feeds"
+
+	// feedSkipReply is the reply that tells the channel an item isn't worth
+	// passing on.
+	feedSkipReply = "SKIP"
+
+	feedFetchTimeout = 30 * time.Second
+	feedMaxBodySize  = 5 << 20
+	feedMaxSummary   = 4000
+	feedSeenLimit    = 500 // item IDs remembered per feed
+
+	defaultFeedPrompt = "A new item was published on the %s feed. Summarize it in a few sentences " +
+		"and include the link. If it isn't worth my attention, reply only with " + feedSkipReply + "."
+)
+
+var (
+	reFeedBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	reFeedTag   = regexp.MustCompile(`<[^>]*>`)
+	reFeedBlank = regexp.MustCompile(`\n{3,}`)
+)
+
+// FeedsChannel watches RSS and Atom feeds. Each feed is a chat named after
+// it, so the agent keeps per-feed history. IDs of items already handled are
+// saved to the workspace so restarts don't repeat them.
+type FeedsChannel struct {
+	*BaseChannel
+	config    config.FeedsConfig
+	statePath string
+	client    *http.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu   sync.Mutex
+	seen map[string][]string // feed URL -> item IDs, oldest first
+}
+
+type feedItem struct {
+	ID        string
+	Title     string
+	Link      string
+	Summary   string
+	Published string
+}
+
+// feedDocument decodes RSS 2.0, RSS 1.0 (RDF) and Atom documents.
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"` // RSS 1.0 keeps items outside the channel
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type feedsState struct {
+	Seen map[string][]string `json:"seen"`
+}
+
+func NewFeedsChannel(cfg config.FeedsConfig, statePath string, messageBus *bus.MessageBus) (*FeedsChannel, error) {
+	if len(cfg.Feeds) == 0 {
+		return nil, fmt.Errorf("no feeds configured")
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 5
+	}
+
+	// Copy before filling in names, the slice belongs to the loaded config
+	cfg.Feeds = slices.Clone(cfg.Feeds)
+	names := make(map[string]bool, len(cfg.Feeds))
+	for i := range cfg.Feeds {
+		feed := &cfg.Feeds[i]
+		if feed.URL == "" {
+			return nil, fmt.Errorf("feed %q has no url", feed.Name)
+		}
+		if feed.Name == "" {
+			feed.Name = feed.URL
+		}
+		if names[feed.Name] {
+			return nil, fmt.Errorf("duplicate feed name %q", feed.Name)
+		}
+		names[feed.Name] = true
+	}
+
+	// Items come from the configured feeds only, so there is nobody to allow
+	base := NewBaseChannel(feedsChannelName, cfg, messageBus, nil)
+
+	c := &FeedsChannel{
+		BaseChannel: base,
+		config:      cfg,
+		statePath:   statePath,
+		client:      &http.Client{Timeout: feedFetchTimeout},
+		seen:        make(map[string][]string),
+	}
+	if err := c.loadState(); err != nil {
+		logger.WarnCF("feeds", "Failed to load feed state", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return c, nil
+}
+
+func (c *FeedsChannel) Start(ctx context.Context) error {
+	logger.InfoCF("feeds", "Starting feed watcher", map[string]any{
+		"feeds":         len(c.config.Feeds),
+		"poll_interval": c.config.PollInterval,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.run()
+
+	c.setRunning(true)
+	logger.InfoC("feeds", "Feed watcher started")
+	return nil
+}
+
+func (c *FeedsChannel) Stop(ctx context.Context) error {
+	logger.InfoC("feeds", "Stopping feed watcher")
+	c.setRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Send forwards the agent's reply about an item to the delivery channel.
+// Replies of just SKIP are dropped.
+func (c *FeedsChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("feeds channel not running")
+	}
+	content := strings.TrimSpace(msg.Content)
+	if content == "" || strings.EqualFold(strings.Trim(content, ".*` "), feedSkipReply) {
+		logger.DebugCF("feeds", "Agent skipped feed item", map[string]any{
+			"feed": msg.ChatID,
+		})
+		return nil
+	}
+
+	// An empty channel makes the manager fall back to the push channel
+	return c.bus.Notify(ctx, c.config.DeliverChannel, c.config.DeliverTo, content)
+}
+
+func (c *FeedsChannel) run() {
+	interval := time.Duration(c.config.PollInterval) * time.Minute
+	for {
+		c.pollAll(c.ctx)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (c *FeedsChannel) pollAll(ctx context.Context) {
+	failed := 0
+	for _, feed := range c.config.Feeds {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := c.poll(ctx, feed); err != nil {
+			failed++
+			logger.WarnCF("feeds", "Failed to poll feed", map[string]any{
+				"feed":  feed.Name,
+				"error": err.Error(),
+			})
+		}
+	}
+	if failed == len(c.config.Feeds) {
+		c.recordError(fmt.Errorf("all %d feeds failed", failed))
+	} else {
+		c.recordSuccess()
+	}
+}
+
+// poll fetches a feed and hands up to MaxItems unseen items to the agent,
+// oldest first. The rest wait for the next poll. The first time a feed is
+// seen its current items are only recorded, so adding a feed doesn't replay
+// its whole history.
+func (c *FeedsChannel) poll(ctx context.Context, feed config.FeedSource) error {
+	items, err := c.fetch(ctx, feed.URL)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	seen, known := c.seen[feed.URL]
+	c.mu.Unlock()
+
+	if !known {
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		c.markSeen(feed.URL, ids...)
+		logger.InfoCF("feeds", "Watching new feed", map[string]any{
+			"feed":  feed.Name,
+			"items": len(items),
+		})
+		return nil
+	}
+
+	// Feeds list the newest item first
+	var fresh []feedItem
+	for i := len(items) - 1; i >= 0; i-- {
+		if !slices.Contains(seen, items[i].ID) {
+			fresh = append(fresh, items[i])
+		}
+	}
+	if len(fresh) > c.config.MaxItems {
+		fresh = fresh[:c.config.MaxItems]
+	}
+
+	for _, item := range fresh {
+		c.markSeen(feed.URL, item.ID)
+		c.handleItem(feed, item)
+	}
+	return nil
+}
+
+func (c *FeedsChannel) handleItem(feed config.FeedSource, item feedItem) {
+	prompt := feed.Prompt
+	if prompt == "" {
+		prompt = c.config.Prompt
+	}
+	if prompt == "" {
+		prompt = fmt.Sprintf(defaultFeedPrompt, feed.Name)
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Feed: %s\n", feed.Name)
+	if item.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", item.Title)
+	}
+	if item.Link != "" {
+		fmt.Fprintf(&b, "Link: %s\n", item.Link)
+	}
+	if item.Published != "" {
+		fmt.Fprintf(&b, "Published: %s\n", item.Published)
+	}
+	if item.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", item.Summary)
+	}
+
+	metadata := map[string]string{
+		"peer_kind":  "direct",
+		"peer_id":    feed.Name,
+		"message_id": item.ID,
+		"feed_url":   feed.URL,
+	}
+
+	logger.InfoCF("feeds", "New feed item", map[string]any{
+		"feed":  feed.Name,
+		"title": item.Title,
+	})
+
+	c.HandleMessage("feed:"+feed.Name, feed.Name, b.String(), nil, metadata)
+}
+
+func (c *FeedsChannel) fetch(ctx context.Context, url string) ([]feedItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	req.Header.Set("User-Agent", "picoclaw-feeds/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, feedMaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return parseFeed(data)
+}
+
+// parseFeed extracts the items of an RSS or Atom document.
+func parseFeed(data []byte) ([]feedItem, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+
+	var doc feedDocument
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+
+	var items []feedItem
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, it := range append(doc.Channel.Items, doc.Items...) {
+			id := firstNonEmpty(it.GUID, it.Link, it.Title+it.PubDate)
+			summary := firstNonEmpty(it.Encoded, it.Description)
+			items = append(items, feedItem{
+				ID:        strings.TrimSpace(id),
+				Title:     feedText(it.Title),
+				Link:      strings.TrimSpace(it.Link),
+				Summary:   utils.Truncate(feedText(summary), feedMaxSummary),
+				Published: strings.TrimSpace(firstNonEmpty(it.PubDate, it.Date)),
+			})
+		}
+	case "feed":
+		for _, entry := range doc.Entries {
+			link := ""
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			summary := firstNonEmpty(entry.Content, entry.Summary)
+			items = append(items, feedItem{
+				ID:        strings.TrimSpace(firstNonEmpty(entry.ID, link, entry.Title+entry.Updated)),
+				Title:     feedText(entry.Title),
+				Link:      strings.TrimSpace(link),
+				Summary:   utils.Truncate(feedText(summary), feedMaxSummary),
+				Published: strings.TrimSpace(firstNonEmpty(entry.Published, entry.Updated)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("not an RSS or Atom feed: <%s>", doc.XMLName.Local)
+	}
+	return items, nil
+}
+
+// feedText converts an item's HTML title or summary to plain text.
+func feedText(s string) string {
+	s = reFeedBreak.ReplaceAllString(s, "\n")
+	s = reFeedTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(reFeedBlank.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// markSeen records item IDs for a feed and saves the state.
+func (c *FeedsChannel) markSeen(feedURL string, ids ...string) {
+	c.mu.Lock()
+	seen := append(c.seen[feedURL], ids...)
+	if len(seen) > feedSeenLimit {
+		seen = seen[len(seen)-feedSeenLimit:]
+	}
+	if seen == nil {
+		seen = []string{}
+	}
+	c.seen[feedURL] = seen
+	err := c.saveState()
+	c.mu.Unlock()
+
+	if err != nil {
+		logger.WarnCF("feeds", "Failed to save feed state", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+func (c *FeedsChannel) loadState() error {
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state feedsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Seen != nil {
+		c.seen = state.Seen
+	}
+	return nil
+}
+
+// saveState writes the seen item IDs. Callers hold c.mu.
+func (c *FeedsChannel) saveState() error {
+	if c.statePath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(feedsState{Seen: c.seen}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.statePath), 0o755); err != nil {
+		return err
+	}
+
+	tmp := c.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.statePath)
+}
@@ -0,0 +1,181 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func rssFeed(ids ...int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>`)
+	for _, id := range ids { // newest first, as feeds list them
+		fmt.Fprintf(&b, `<item><title>Post %d</title><link>https://blog.example/%d</link>`+
+			`<guid>post-%d</guid><description>&lt;p&gt;Body of post %d&lt;/p&gt;</description></item>`, id, id, id, id)
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
+
+func TestParseFeed(t *testing.T) {
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <title type="html">Caf&amp;eacute; news</title>
+    <id>tag:example.org,2026:1</id>
+    <link rel="replies" href="https://example.org/1#comments"/>
+    <link href="https://example.org/1"/>
+    <updated>2026-10-01T10:00:00Z</updated>
+    <summary type="html">&lt;p&gt;First&lt;br/&gt;second&lt;/p&gt;</summary>
+  </entry>
+</feed>`
+	items, err := parseFeed([]byte(atom))
+	if err != nil {
+		t.Fatalf("parseFeed(atom) error = %v", err)
+	}
+	want := feedItem{
+		ID:        "tag:example.org,2026:1",
+		Title:     "Café news",
+		Link:      "https://example.org/1",
+		Summary:   "First\nsecond",
+		Published: "2026-10-01T10:00:00Z",
+	}
+	if len(items) != 1 || items[0] != want {
+		t.Errorf("atom items = %+v, want %+v", items, want)
+	}
+
+	latin1 := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>" +
+		"<rss><channel><item><title>Cr\xe8me br\xfbl\xe9e</title><link>https://x/1</link></item></channel></rss>")
+	items, err = parseFeed(latin1)
+	if err != nil {
+		t.Fatalf("parseFeed(latin1) error = %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "Crème brûlée" || items[0].ID != "https://x/1" {
+		t.Errorf("latin1 items = %+v", items)
+	}
+
+	if _, err := parseFeed([]byte(`<html><body>not a feed</body></html>`)); err == nil {
+		t.Error("expected an error for an HTML page")
+	}
+}
+
+func TestFeedsChannelTriggersOnNewItems(t *testing.T) {
+	var mu sync.Mutex
+	body := rssFeed(2, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	setFeed := func(s string) {
+		mu.Lock()
+		body = s
+		mu.Unlock()
+	}
+
+	statePath := filepath.Join(t.TempDir(), "state", "feeds.json")
+	cfg := config.FeedsConfig{
+		MaxItems: 2,
+		Feeds:    []config.FeedSource{{Name: "blog", URL: server.URL, Prompt: "Is this about Go?"}},
+	}
+	msgBus := bus.NewMessageBus()
+	ch, err := NewFeedsChannel(cfg, statePath, msgBus)
+	if err != nil {
+		t.Fatalf("NewFeedsChannel() error = %v", err)
+	}
+	feed := ch.config.Feeds[0]
+
+	// The first poll only records what is already there
+	if err := ch.poll(context.Background(), feed); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("existing item was delivered: %+v", msg)
+	}
+
+	setFeed(rssFeed(5, 4, 3, 2, 1))
+	if err := ch.poll(context.Background(), feed); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	for _, want := range []string{"post-3", "post-4"} {
+		msg, ok := expectInbound(t, msgBus)
+		if !ok {
+			t.Fatalf("expected inbound message for %s", want)
+		}
+		if msg.Channel != "feeds" || msg.ChatID != "blog" || msg.Metadata["message_id"] != want {
+			t.Errorf("inbound = %+v, want %s on blog", msg, want)
+		}
+		if !strings.HasPrefix(msg.Content, "Is this about Go?") ||
+			!strings.Contains(msg.Content, "Link: https://blog.example/") ||
+			!strings.Contains(msg.Content, "Body of post") {
+			t.Errorf("content = %q", msg.Content)
+		}
+	}
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("more than max_items delivered: %+v", msg)
+	}
+
+	// A restarted channel picks up where the last one stopped
+	restarted, err := NewFeedsChannel(cfg, statePath, msgBus)
+	if err != nil {
+		t.Fatalf("NewFeedsChannel() error = %v", err)
+	}
+	if err := restarted.poll(context.Background(), feed); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	msg, ok := expectInbound(t, msgBus)
+	if !ok || msg.Metadata["message_id"] != "post-5" {
+		t.Fatalf("inbound = %+v, want only post-5", msg)
+	}
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Errorf("seen item delivered again: %+v", msg)
+	}
+}
+
+func TestFeedsChannelSendDeliversReplies(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	var delivered []string
+	msgBus.SetNotifier(func(_ context.Context, channel, recipient, content string) error {
+		delivered = append(delivered, channel+"/"+recipient+": "+content)
+		return nil
+	})
+	ch, err := NewFeedsChannel(config.FeedsConfig{
+		DeliverChannel: "telegram",
+		DeliverTo:      "42",
+		Feeds:          []config.FeedSource{{URL: "https://blog.example/feed"}},
+	}, "", msgBus)
+	if err != nil {
+		t.Fatalf("NewFeedsChannel() error = %v", err)
+	}
+	ch.setRunning(true)
+
+	for _, content := range []string{"SKIP", " skip. ", "**SKIP**", "Worth a read: https://blog.example/5"} {
+		if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "blog", Content: content}); err != nil {
+			t.Fatalf("Send(%q) error = %v", content, err)
+		}
+	}
+	if len(delivered) != 1 || delivered[0] != "telegram/42: Worth a read: https://blog.example/5" {
+		t.Errorf("delivered = %q", delivered)
+	}
+}
+
+func TestNewFeedsChannelValidatesFeeds(t *testing.T) {
+	for _, feeds := range [][]config.FeedSource{
+		nil,
+		{{Name: "blog"}},
+		{{Name: "blog", URL: "https://a/feed"}, {Name: "blog", URL: "https://b/feed"}},
+	} {
+		if _, err := NewFeedsChannel(config.FeedsConfig{Feeds: feeds}, "", bus.NewMessageBus()); err == nil {
+			t.Errorf("NewFeedsChannel(%+v) succeeded, want an error", feeds)
+		}
+	}
+}
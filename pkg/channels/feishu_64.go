@@ -6,10 +6,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkdispatcher "github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
@@ -25,6 +27,7 @@ type FeishuChannel struct {
 	config   config.FeishuConfig
 	client   *lark.Client
 	wsClient *larkws.Client
+	botID    string // bot open_id, for recognising mentions in groups
 
 	mu     sync.Mutex
 	cancel context.CancelFunc
@@ -45,6 +48,8 @@ func (c *FeishuChannel) Start(ctx context.Context) error {
 		return fmt.Errorf("feishu app_id or app_secret is empty")
 	}
 
+	c.botID = c.fetchBotID(ctx)
+
 	dispatcher := larkdispatcher.NewEventDispatcher(c.config.VerificationToken, c.config.EncryptKey).
 		OnP2MessageReceiveV1(c.handleMessageReceive)
 
@@ -170,6 +175,15 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 		metadata["peer_kind"] = "direct"
 		metadata["peer_id"] = senderID
 	} else {
+		mentioned, stripped := c.checkBotMention(message, content)
+		if !c.acceptGroupMessage(chatID, senderID, content, mentioned) {
+			logger.DebugCF("feishu", "Group message ignored (no mention)", map[string]any{
+				"sender_id": senderID,
+				"chat_id":   chatID,
+			})
+			return nil
+		}
+		content = stripped
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = chatID
 	}
@@ -184,6 +198,53 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 	return nil
 }
 
+// fetchBotID looks up the bot's open_id. Without it every group message that
+// reaches the bot is treated as a mention, which matches Feishu's default of
+// only delivering messages that @ the bot.
+func (c *FeishuChannel) fetchBotID(ctx context.Context) string {
+	resp, err := c.client.Get(ctx, "/open-apis/bot/v3/info", nil, larkcore.AccessTokenTypeTenant)
+	if err != nil {
+		logger.WarnCF("feishu", "Failed to fetch bot info", map[string]any{"error": err.Error()})
+		return ""
+	}
+
+	var info struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Bot  struct {
+			OpenID string `json:"open_id"`
+		} `json:"bot"`
+	}
+	if err := json.Unmarshal(resp.RawBody, &info); err != nil || info.Code != 0 {
+		logger.WarnCF("feishu", "Failed to fetch bot info", map[string]any{
+			"code": info.Code,
+			"msg":  info.Msg,
+		})
+		return ""
+	}
+	return info.Bot.OpenID
+}
+
+// checkBotMention reports whether a group message @s the bot and returns the
+// content with the bot's mention placeholder removed.
+func (c *FeishuChannel) checkBotMention(message *larkim.EventMessage, content string) (bool, string) {
+	if c.botID == "" {
+		return true, content
+	}
+
+	mentioned := false
+	for _, mention := range message.Mentions {
+		if mention == nil || mention.Id == nil || stringValue(mention.Id.OpenId) != c.botID {
+			continue
+		}
+		mentioned = true
+		if key := stringValue(mention.Key); key != "" {
+			content = strings.ReplaceAll(content, key, "")
+		}
+	}
+	return mentioned, strings.TrimSpace(content)
+}
+
 func extractFeishuSenderID(sender *larkim.EventSender) string {
 	if sender == nil || sender.SenderId == nil {
 		return ""
@@ -0,0 +1,434 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// GitHub channel implementation
+// Polls issue and pull request comments in the listed repositories and
+// answers the ones that mention the bot with a comment of its own
+
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	githubAPITimeout    = 30 * time.Second
+	githubMinLoginDelay = 5 * time.Second
+	githubMaxLoginDelay = 5 * time.Minute
+)
+
+var reGitHubRepo = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// GitHubChannel answers comments on issues and pull requests. Each issue or
+// pull request is a chat, "owner/name#number", shared by everyone in it.
+// Only the configured repositories are read or written, so a token scoped to
+// them is enough.
+type GitHubChannel struct {
+	*BaseChannel
+	config  config.GitHubConfig
+	apiBase string
+	client  *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	username string
+	mention  *regexp.Regexp
+	cursors  map[string]*githubCursor // repo -> position in its comments
+}
+
+// githubCursor tracks the newest comment handled in a repository. Comment
+// IDs only grow, so anything at or below lastID was already seen. Comments
+// created before from, when the channel started, are edits of old ones.
+type githubCursor struct {
+	from   time.Time
+	since  time.Time
+	lastID int64
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+}
+
+type githubComment struct {
+	ID        int64      `json:"id"`
+	Body      string     `json:"body"`
+	User      githubUser `json:"user"`
+	IssueURL  string     `json:"issue_url"`
+	HTMLURL   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type githubIssue struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	PullRequest json.RawMessage `json:"pull_request"`
+}
+
+func NewGitHubChannel(cfg config.GitHubConfig, messageBus *bus.MessageBus) (*GitHubChannel, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("github token is required")
+	}
+	if len(cfg.Repos) == 0 {
+		return nil, fmt.Errorf("github repos is required")
+	}
+	for _, repo := range cfg.Repos {
+		if !reGitHubRepo.MatchString(repo) {
+			return nil, fmt.Errorf("invalid github repo %q: use owner/name", repo)
+		}
+	}
+	if cfg.APIBase == "" {
+		cfg.APIBase = "https://api.github.com"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 60
+	}
+
+	base := NewBaseChannel("github", cfg, messageBus, cfg.AllowFrom)
+
+	c := &GitHubChannel{
+		BaseChannel: base,
+		config:      cfg,
+		apiBase:     strings.TrimRight(cfg.APIBase, "/"),
+		client:      &http.Client{Timeout: githubAPITimeout},
+		cursors:     make(map[string]*githubCursor),
+	}
+	if cfg.Username != "" {
+		c.setUsername(cfg.Username)
+	}
+	return c, nil
+}
+
+func (c *GitHubChannel) Start(ctx context.Context) error {
+	logger.InfoCF("github", "Starting GitHub channel", map[string]any{
+		"repos": len(c.config.Repos),
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	// Only comments written from now on are answered. GitHub timestamps
+	// have whole seconds.
+	now := time.Now().UTC().Truncate(time.Second)
+	c.mu.Lock()
+	for _, repo := range c.config.Repos {
+		c.cursors[strings.ToLower(repo)] = &githubCursor{from: now, since: now}
+	}
+	c.mu.Unlock()
+
+	go c.run()
+
+	c.setRunning(true)
+	logger.InfoC("github", "GitHub channel started")
+	return nil
+}
+
+func (c *GitHubChannel) Stop(ctx context.Context) error {
+	logger.InfoC("github", "Stopping GitHub channel")
+	c.setRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Send posts the reply as a comment on the issue or pull request.
+func (c *GitHubChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("github channel not running")
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	repo, number, err := c.parseChatID(msg.ChatID)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+	if err := c.api(ctx, http.MethodPost, endpoint, map[string]string{"body": msg.Content}, nil); err != nil {
+		logger.ErrorCF("github", "Failed to post comment", map[string]any{
+			"chat_id": msg.ChatID,
+			"error":   err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *GitHubChannel) run() {
+	delay := githubMinLoginDelay
+	for c.currentUsername() == "" {
+		var user githubUser
+		err := c.api(c.ctx, http.MethodGet, "/user", nil, &user)
+		if err == nil {
+			c.setUsername(user.Login)
+			break
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.ErrorCF("github", "Failed to look up the token's user", map[string]any{
+			"error":       err.Error(),
+			"retry_after": delay.String(),
+		})
+		c.recordError(err)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > githubMaxLoginDelay {
+			delay = githubMaxLoginDelay
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(c.config.PollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		c.poll(c.ctx)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *GitHubChannel) poll(ctx context.Context) {
+	var lastErr error
+	for _, repo := range c.config.Repos {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := c.pollRepo(ctx, repo); err != nil {
+			lastErr = err
+			logger.WarnCF("github", "Failed to read comments", map[string]any{
+				"repo":  repo,
+				"error": err.Error(),
+			})
+		}
+	}
+	if lastErr != nil {
+		c.recordError(lastErr)
+	} else {
+		c.recordSuccess()
+	}
+}
+
+// pollRepo reads comments created or edited since the cursor, oldest first,
+// and handles the new ones.
+func (c *GitHubChannel) pollRepo(ctx context.Context, repo string) error {
+	c.mu.Lock()
+	cursor, ok := c.cursors[strings.ToLower(repo)]
+	if !ok {
+		now := time.Now().UTC().Truncate(time.Second)
+		cursor = &githubCursor{from: now, since: now}
+		c.cursors[strings.ToLower(repo)] = cursor
+	}
+	since := cursor.since
+	c.mu.Unlock()
+
+	query := url.Values{
+		"since":     {since.Format(time.RFC3339)},
+		"sort":      {"created"},
+		"direction": {"asc"},
+		"per_page":  {"100"},
+	}
+	var comments []githubComment
+	endpoint := fmt.Sprintf("/repos/%s/issues/comments?%s", repo, query.Encode())
+	if err := c.api(ctx, http.MethodGet, endpoint, nil, &comments); err != nil {
+		return err
+	}
+
+	for _, comment := range comments {
+		c.mu.Lock()
+		fresh := comment.ID > cursor.lastID && !comment.CreatedAt.Before(cursor.from)
+		if fresh {
+			cursor.lastID = comment.ID
+			if comment.CreatedAt.After(cursor.since) {
+				cursor.since = comment.CreatedAt
+			}
+		}
+		c.mu.Unlock()
+
+		if fresh {
+			c.handleComment(ctx, repo, comment)
+		}
+	}
+	return nil
+}
+
+func (c *GitHubChannel) handleComment(ctx context.Context, repo string, comment githubComment) {
+	username := c.currentUsername()
+	if strings.EqualFold(comment.User.Login, username) || !c.mentions(comment.Body) {
+		return
+	}
+
+	number, err := strconv.Atoi(comment.IssueURL[strings.LastIndex(comment.IssueURL, "/")+1:])
+	if err != nil {
+		logger.WarnCF("github", "Comment without an issue number", map[string]any{
+			"comment": comment.HTMLURL,
+		})
+		return
+	}
+
+	senderID := fmt.Sprintf("%d|%s", comment.User.ID, comment.User.Login)
+	chatID := fmt.Sprintf("%s#%d", repo, number)
+
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("github", "Ignoring comment from a user not in allow_from", map[string]any{
+			"user": comment.User.Login,
+		})
+		return
+	}
+
+	text := stripGitHubMention(comment.Body, username)
+	kind := "issue"
+	var issue githubIssue
+	if err := c.api(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &issue); err != nil {
+		logger.WarnCF("github", "Failed to read issue", map[string]any{
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+	} else {
+		if len(issue.PullRequest) > 0 && string(issue.PullRequest) != "null" {
+			kind = "pull request"
+		}
+		text = fmt.Sprintf("[On %s %s: %s]\n\n%s", kind, chatID, issue.Title, text)
+	}
+
+	metadata := map[string]string{
+		"peer_kind":   "group",
+		"peer_id":     chatID,
+		"message_id":  strconv.FormatInt(comment.ID, 10),
+		"username":    comment.User.Login,
+		"sender_name": comment.User.Login,
+		"url":         comment.HTMLURL,
+	}
+
+	logger.InfoCF("github", "Received mention", map[string]any{
+		"chat_id": chatID,
+		"user":    comment.User.Login,
+	})
+
+	c.HandleMessage(senderID, chatID, text, nil, metadata)
+}
+
+// mentions reports whether a comment mentions the bot outside quoted text, so
+// quoting an earlier request doesn't trigger another answer.
+func (c *GitHubChannel) mentions(body string) bool {
+	c.mu.Lock()
+	re := c.mention
+	c.mu.Unlock()
+	if re == nil {
+		return false
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *GitHubChannel) setUsername(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.username = username
+	c.mention = regexp.MustCompile(`(?i)(^|[^\w-])@` + regexp.QuoteMeta(username) + `($|[^\w-])`)
+}
+
+func (c *GitHubChannel) currentUsername() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username
+}
+
+// parseChatID splits "owner/name#number" and checks the repository is one
+// the channel may write to.
+func (c *GitHubChannel) parseChatID(chatID string) (string, int, error) {
+	repo, num, ok := strings.Cut(chatID, "#")
+	number, err := strconv.Atoi(num)
+	if !ok || err != nil || number <= 0 {
+		return "", 0, fmt.Errorf("invalid github chat ID %q: want owner/name#number", chatID)
+	}
+	if !slices.ContainsFunc(c.config.Repos, func(r string) bool { return strings.EqualFold(r, repo) }) {
+		return "", 0, fmt.Errorf("github repo %s is not in repos", repo)
+	}
+	return repo, number, nil
+}
+
+func (c *GitHubChannel) api(ctx context.Context, method, endpoint string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiBase+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		path, _, _ := strings.Cut(endpoint, "?")
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, utils.Truncate(string(data), 200))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// stripGitHubMention removes @username mentions of the bot from text.
+func stripGitHubMention(text, username string) string {
+	if username == "" {
+		return text
+	}
+	re := regexp.MustCompile(`(?i)(^|[^\w-])@` + regexp.QuoteMeta(username) + `[,:]?($|[^\w-])`)
+	return strings.TrimSpace(re.ReplaceAllString(text, "$1$2"))
+}
@@ -0,0 +1,175 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeGitHub serves the REST endpoints the channel uses.
+type fakeGitHub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	comments string
+	since    []string
+	posted   map[string][]string // path -> comment bodies
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	t.Helper()
+	f := &fakeGitHub{comments: "[]", posted: make(map[string][]string)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp_token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/user":
+			fmt.Fprint(w, `{"login":"claw-bot","id":1}`)
+		case r.URL.Path == "/repos/acme/widgets/issues/comments":
+			f.since = append(f.since, r.URL.Query().Get("since"))
+			fmt.Fprint(w, f.comments)
+		case r.URL.Path == "/repos/acme/widgets/issues/7":
+			fmt.Fprint(w, `{"number":7,"title":"Crash on startup","pull_request":{"url":"x"}}`)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/comments"):
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			f.posted[r.URL.Path] = append(f.posted[r.URL.Path], body["body"])
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":999}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func githubCommentJSON(id int64, login, body string, created time.Time) string {
+	data, _ := json.Marshal(map[string]any{
+		"id":         id,
+		"body":       body,
+		"user":       map[string]any{"login": login, "id": id * 10},
+		"issue_url":  "https://api.github.com/repos/acme/widgets/issues/7",
+		"html_url":   fmt.Sprintf("https://github.com/acme/widgets/pull/7#issuecomment-%d", id),
+		"created_at": created.Format(time.RFC3339),
+	})
+	return string(data)
+}
+
+func TestGitHubChannelAnswersMentions(t *testing.T) {
+	server := newFakeGitHub(t)
+	msgBus := bus.NewMessageBus()
+	ch, err := NewGitHubChannel(config.GitHubConfig{
+		Token:     "ghp_token",
+		APIBase:   server.URL,
+		Repos:     config.FlexibleStringSlice{"acme/widgets"},
+		AllowFrom: config.FlexibleStringSlice{"alice", "mallory-not-here"},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewGitHubChannel() error = %v", err)
+	}
+	var user githubUser
+	if err := ch.api(context.Background(), http.MethodGet, "/user", nil, &user); err != nil {
+		t.Fatalf("api(/user) error = %v", err)
+	}
+	ch.setUsername(user.Login)
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	ch.cursors["acme/widgets"] = &githubCursor{from: start, since: start}
+	ch.setRunning(true)
+
+	server.comments = "[" + strings.Join([]string{
+		githubCommentJSON(1, "alice", "@claw-bot old comment, edited later", start.Add(-time.Hour)),
+		githubCommentJSON(2, "alice", "@claw-bot why does this crash?", start.Add(time.Minute)),
+		githubCommentJSON(3, "bob", "@claw-bot me too", start.Add(2*time.Minute)),
+		githubCommentJSON(4, "alice", "> @claw-bot why does this crash?\n\nThanks!", start.Add(3*time.Minute)),
+		githubCommentJSON(5, "claw-bot", "Answering @claw-bot", start.Add(4*time.Minute)),
+		githubCommentJSON(6, "alice", "cc @claw-bot-two", start.Add(5*time.Minute)),
+	}, ",") + "]"
+
+	if err := ch.pollRepo(context.Background(), "acme/widgets"); err != nil {
+		t.Fatalf("pollRepo() error = %v", err)
+	}
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.ChatID != "acme/widgets#7" || msg.SenderID != "20|alice" {
+		t.Errorf("chat/sender = %q/%q", msg.ChatID, msg.SenderID)
+	}
+	if msg.Content != "[On pull request acme/widgets#7: Crash on startup]\n\nwhy does this crash?" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.Metadata["peer_kind"] != "group" || msg.Metadata["message_id"] != "2" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+	if extra, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("unexpected inbound message: %+v", extra)
+	}
+
+	// The next poll asks from the newest comment on and skips what it has seen
+	if err := ch.pollRepo(context.Background(), "acme/widgets"); err != nil {
+		t.Fatalf("pollRepo() error = %v", err)
+	}
+	if extra, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("comment delivered twice: %+v", extra)
+	}
+	if got := server.since[1]; got != start.Add(5*time.Minute).Format(time.RFC3339) {
+		t.Errorf("second poll since = %s", got)
+	}
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: msg.ChatID, Content: "Fixed in main."})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := server.posted["/repos/acme/widgets/issues/7/comments"]; len(got) != 1 || got[0] != "Fixed in main." {
+		t.Errorf("posted = %v", server.posted)
+	}
+
+	for _, chatID := range []string{"other/repo#1", "acme/widgets", "acme/widgets#x"} {
+		if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: chatID, Content: "hi"}); err == nil {
+			t.Errorf("Send(%q) succeeded, want an error", chatID)
+		}
+	}
+}
+
+func TestNewGitHubChannelValidatesRepos(t *testing.T) {
+	for _, cfg := range []config.GitHubConfig{
+		{Repos: config.FlexibleStringSlice{"acme/widgets"}},
+		{Token: "t"},
+		{Token: "t", Repos: config.FlexibleStringSlice{"acme"}},
+		{Token: "t", Repos: config.FlexibleStringSlice{"https://github.com/acme/widgets"}},
+	} {
+		if _, err := NewGitHubChannel(cfg, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewGitHubChannel(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestStripGitHubMention(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"@claw-bot please review", "please review"},
+		{"@Claw-Bot: what do you think?", "what do you think?"},
+		{"thoughts, @claw-bot?", "thoughts, ?"},
+		{"ask @claw-bot-two instead", "ask @claw-bot-two instead"},
+	}
+	for _, tt := range tests {
+		if got := stripGitHubMention(tt.in, "claw-bot"); got != tt.want {
+			t.Errorf("stripGitHubMention(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
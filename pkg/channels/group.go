@@ -0,0 +1,141 @@
+package channels
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxAttending bounds the open attention windows kept before expired ones
+// are swept.
+const maxAttending = 1000
+
+// GroupPolicy decides which group chat messages the bot answers. A nil
+// policy answers mentions only.
+type GroupPolicy struct {
+	mentionOnly bool
+	triggers    []*regexp.Regexp
+	attention   time.Duration
+	now         func() time.Time
+
+	mu        sync.Mutex
+	attending map[string]time.Time // chat and sender -> end of their attention window
+}
+
+// NewGroupPolicy compiles the trigger words and patterns of cfg. Patterns
+// that don't compile are skipped; LoadConfig has rejected them already.
+func NewGroupPolicy(cfg config.GroupsConfig) *GroupPolicy {
+	p := &GroupPolicy{
+		mentionOnly: cfg.MentionOnly,
+		attention:   time.Duration(cfg.AttentionSeconds) * time.Second,
+		now:         time.Now,
+		attending:   make(map[string]time.Time),
+	}
+	for _, word := range cfg.TriggerWords {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		p.triggers = append(p.triggers, regexp.MustCompile(`(?i)(^|\W)`+regexp.QuoteMeta(word)+`($|\W)`))
+	}
+	for _, pattern := range cfg.TriggerPatterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			logger.WarnCF("channels", "Invalid group trigger pattern", map[string]any{
+				"pattern": pattern,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		p.triggers = append(p.triggers, re)
+	}
+	return p
+}
+
+// Admit reports whether a group message should reach the agent. mentioned
+// is the channel's own verdict on whether the bot was addressed. Addressing
+// the bot, by mention or trigger, opens an attention window for the sender
+// in that chat; their messages within it are admitted without a trigger and
+// keep it open.
+func (p *GroupPolicy) Admit(chatKey, senderID, content string, mentioned bool) bool {
+	if p == nil {
+		return mentioned
+	}
+	if !mentioned && !p.mentionOnly {
+		return true
+	}
+	key := chatKey + "\x00" + senderID
+	if mentioned || p.triggered(content) {
+		p.attend(key)
+		return true
+	}
+	if p.attentive(key) {
+		p.attend(key)
+		return true
+	}
+	return false
+}
+
+func (p *GroupPolicy) triggered(content string) bool {
+	for _, re := range p.triggers {
+		if re.MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// attend opens or extends the attention window of key.
+func (p *GroupPolicy) attend(key string) {
+	if p.attention <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if len(p.attending) >= maxAttending {
+		for k, until := range p.attending {
+			if !now.Before(until) {
+				delete(p.attending, k)
+			}
+		}
+	}
+	p.attending[key] = now.Add(p.attention)
+}
+
+// attentive reports whether key's attention window is open.
+func (p *GroupPolicy) attentive(key string) bool {
+	if p.attention <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until, ok := p.attending[key]
+	if ok && !p.now().Before(until) {
+		delete(p.attending, key)
+		return false
+	}
+	return ok
+}
+
+// GroupChannel is implemented by channels embedding BaseChannel.
+type GroupChannel interface {
+	SetGroupPolicy(policy *GroupPolicy)
+}
+
+// SetGroupPolicy sets the policy consulted for group chat messages.
+func (c *BaseChannel) SetGroupPolicy(policy *GroupPolicy) {
+	c.groups = policy
+}
+
+// acceptGroupMessage applies the group policy to a message from senderID
+// in a group chat. Channels call it before any side effects such as typing
+// indicators, downloads or rejection notices, so unaddressed chatter is
+// ignored quietly.
+func (c *BaseChannel) acceptGroupMessage(chatID, senderID, content string, mentioned bool) bool {
+	return c.groups.Admit(c.name+":"+chatID, senderID, content, mentioned)
+}
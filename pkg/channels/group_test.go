@@ -0,0 +1,112 @@
+package channels
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestGroupPolicyAdmit(t *testing.T) {
+	triggers := NewGroupPolicy(config.GroupsConfig{
+		MentionOnly:     true,
+		TriggerWords:    config.FlexibleStringSlice{"claw", " ", "小龙"},
+		TriggerPatterns: config.FlexibleStringSlice{`^(hey|ok)\s+bot\b`},
+	})
+	open := NewGroupPolicy(config.GroupsConfig{MentionOnly: false})
+
+	tests := []struct {
+		name      string
+		policy    *GroupPolicy
+		content   string
+		mentioned bool
+		want      bool
+	}{
+		{"nil policy needs mention", nil, "hello everyone", false, false},
+		{"nil policy with mention", nil, "hello", true, true},
+		{"trigger word", triggers, "Hey Claw, what's the weather?", false, true},
+		{"trigger inside word", triggers, "the clawback clause", false, false},
+		{"cjk trigger", triggers, "小龙，今天天气怎么样", false, true},
+		{"trigger pattern", triggers, "OK bot, set a timer", false, true},
+		{"pattern not at start", triggers, "that bot is ok", false, false},
+		{"no trigger", triggers, "lunch anyone?", false, false},
+		{"mention without trigger", triggers, "lunch anyone?", true, true},
+		{"mention_only off", open, "lunch anyone?", false, true},
+	}
+	for _, tc := range tests {
+		if got := tc.policy.Admit("test:1", "7", tc.content, tc.mentioned); got != tc.want {
+			t.Errorf("%s: Admit(%q, %v) = %v, want %v", tc.name, tc.content, tc.mentioned, got, tc.want)
+		}
+	}
+}
+
+func TestGroupPolicyAttentionWindow(t *testing.T) {
+	policy := NewGroupPolicy(config.GroupsConfig{
+		MentionOnly:      true,
+		TriggerWords:     config.FlexibleStringSlice{"claw"},
+		AttentionSeconds: 60,
+	})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	policy.now = func() time.Time { return now }
+
+	if policy.Admit("test:1", "7", "what do you think?", false) {
+		t.Fatal("follow-up admitted before the bot was addressed")
+	}
+	if !policy.Admit("test:1", "7", "claw, what's the weather?", false) {
+		t.Fatal("trigger word not admitted")
+	}
+	now = now.Add(50 * time.Second)
+	if !policy.Admit("test:1", "7", "and tomorrow?", false) {
+		t.Error("follow-up within the window ignored")
+	}
+	if policy.Admit("test:1", "8", "lunch anyone?", false) {
+		t.Error("another sender got through the window")
+	}
+	if policy.Admit("test:2", "7", "and tomorrow?", false) {
+		t.Error("the window leaked into another chat")
+	}
+	// The follow-up kept the window open
+	now = now.Add(50 * time.Second)
+	if !policy.Admit("test:1", "7", "thanks", false) {
+		t.Error("window closed despite follow-ups")
+	}
+	now = now.Add(61 * time.Second)
+	if policy.Admit("test:1", "7", "unrelated chatter", false) {
+		t.Error("window still open after it expired")
+	}
+
+	// A mention opens it too; 0 seconds turns windows off
+	policy.Admit("test:1", "8", "hi", true)
+	if !policy.Admit("test:1", "8", "still there?", false) {
+		t.Error("mention did not open the window")
+	}
+	off := NewGroupPolicy(config.GroupsConfig{MentionOnly: true, TriggerWords: config.FlexibleStringSlice{"claw"}})
+	off.Admit("test:1", "7", "claw?", false)
+	if off.Admit("test:1", "7", "hello", false) {
+		t.Error("window opened with attention_seconds 0")
+	}
+}
+
+func TestGroupPolicyAppliesToRooms(t *testing.T) {
+	ch, msgBus := newTestXMPPChannel(t)
+	ch.SetGroupPolicy(NewGroupPolicy(config.GroupsConfig{
+		MentionOnly:  true,
+		TriggerWords: config.FlexibleStringSlice{"picoclaw"},
+	}))
+
+	ch.handleMessage(decodeXMPPMessage(t,
+		`<message from="lounge@conference.example.org/bob" type="groupchat"><body>just chatting</body></message>`))
+	if _, ok := expectInbound(t, msgBus); ok {
+		t.Fatal("unaddressed room message should be ignored")
+	}
+
+	ch.handleMessage(decodeXMPPMessage(t, `<message from="lounge@conference.example.org/bob" type="groupchat">
+		<body>is picoclaw around?</body></message>`))
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected room message with trigger word")
+	}
+	if msg.Content != "is picoclaw around?" || msg.Metadata["peer_kind"] != "group" {
+		t.Errorf("unexpected message: content=%q metadata=%v", msg.Content, msg.Metadata)
+	}
+}
@@ -0,0 +1,314 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// gRPC channel implementation
+// Serves the Agent service from pkg/channels/agentpb: each bidirectional Chat
+// stream is attached to a session, takes user messages in and streams
+// progress and replies out
+
+package channels
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels/agentpb"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// GRPCChannel serves the gRPC Agent service. Like the HTTP API, a session ID
+// is the chat ID; a session is attached to at most one stream at a time.
+type GRPCChannel struct {
+	*BaseChannel
+	agentpb.UnimplementedAgentServer
+	config config.GRPCConfig
+	server *grpc.Server
+
+	mu      sync.Mutex
+	streams map[string]*grpcStream // session ID -> attached stream
+}
+
+// grpcStream is a Chat stream's queue of events. Progress events are
+// dropped when the client falls behind.
+type grpcStream struct {
+	events chan bus.OutboundMessage
+	done   chan struct{}
+}
+
+var _ ProgressChannel = (*GRPCChannel)(nil)
+
+func NewGRPCChannel(cfg config.GRPCConfig, messageBus *bus.MessageBus) (*GRPCChannel, error) {
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("grpc tokens is required")
+	}
+	for _, token := range cfg.Tokens {
+		if strings.TrimSpace(token) == "" {
+			return nil, fmt.Errorf("grpc tokens must not be empty")
+		}
+	}
+	if cfg.Host == "" {
+		cfg.Host = "127.0.0.1"
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 18796
+	}
+
+	// Callers are authenticated by token, not by the user they name
+	base := NewBaseChannel("grpc", cfg, messageBus, nil)
+
+	return &GRPCChannel{
+		BaseChannel: base,
+		config:      cfg,
+		streams:     make(map[string]*grpcStream),
+	}, nil
+}
+
+func (c *GRPCChannel) Start(ctx context.Context) error {
+	logger.InfoC("grpc", "Starting gRPC channel")
+
+	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	var opts []grpc.ServerOption
+	if c.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(c.tlsConfig)))
+	}
+	c.server = grpc.NewServer(opts...)
+	agentpb.RegisterAgentServer(c.server, c)
+
+	c.setRunning(true)
+
+	go func() {
+		logger.InfoCF("grpc", "gRPC server listening", map[string]any{
+			"addr": addr,
+		})
+		if err := c.server.Serve(listener); err != nil {
+			logger.ErrorCF("grpc", "gRPC server error", map[string]any{
+				"error": err.Error(),
+			})
+			c.setRunning(false) // Let the manager restart the channel
+		}
+	}()
+
+	return nil
+}
+
+func (c *GRPCChannel) Stop(ctx context.Context) error {
+	logger.InfoC("grpc", "Stopping gRPC channel")
+
+	c.setRunning(false)
+	if c.server != nil {
+		// Chat streams only end when their clients hang up, so don't wait for them
+		c.server.Stop()
+	}
+	return nil
+}
+
+// SupportsProgress reports true; progress goes out as Progress events.
+func (c *GRPCChannel) SupportsProgress() bool {
+	return true
+}
+
+// Send queues a message on the stream attached to its session.
+func (c *GRPCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("grpc channel not running")
+	}
+
+	c.mu.Lock()
+	stream := c.streams[msg.ChatID]
+	c.mu.Unlock()
+
+	if stream == nil {
+		if msg.Progress {
+			return nil
+		}
+		return fmt.Errorf("no stream attached to session %s", msg.ChatID)
+	}
+
+	if msg.Progress {
+		select {
+		case stream.events <- msg:
+		default: // the client is slow; updates can be skipped
+		}
+		return nil
+	}
+
+	select {
+	case stream.events <- msg:
+		return nil
+	case <-stream.done:
+		return fmt.Errorf("stream on session %s ended before the reply", msg.ChatID)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Chat implements agentpb.AgentServer.
+func (c *GRPCChannel) Chat(srv agentpb.Agent_ChatServer) error {
+	ctx := srv.Context()
+	if !c.authorized(ctx) {
+		return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+
+	first, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+	sessionID := first.GetSessionId()
+	if sessionID == "" {
+		sessionID = uuid.NewString()
+	}
+	if len(sessionID) > apiMaxSessionIDLen {
+		return status.Error(codes.InvalidArgument, "session_id is too long")
+	}
+
+	stream := &grpcStream{
+		events: make(chan bus.OutboundMessage, 16),
+		done:   make(chan struct{}),
+	}
+	c.mu.Lock()
+	if _, busy := c.streams[sessionID]; busy {
+		c.mu.Unlock()
+		return status.Error(codes.AlreadyExists, "session is attached to another stream")
+	}
+	c.streams[sessionID] = stream
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.streams, sessionID)
+		c.mu.Unlock()
+		close(stream.done)
+	}()
+
+	logger.InfoCF("grpc", "Chat stream attached", map[string]any{
+		"session_id": sessionID,
+	})
+
+	if err := srv.Send(&agentpb.ChatEvent{Event: &agentpb.ChatEvent_Session{
+		Session: &agentpb.Session{SessionId: sessionID},
+	}}); err != nil {
+		return err
+	}
+
+	requests := make(chan *agentpb.ChatRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := srv.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Messages not yet replied to; once the client closes its side, the
+	// stream ends when this reaches zero
+	unanswered := 0
+	closed := false
+
+	handle := func(req *agentpb.ChatRequest) error {
+		if id := req.GetSessionId(); id != "" && id != sessionID {
+			return status.Error(codes.InvalidArgument, "session_id cannot change on a stream")
+		}
+		if strings.TrimSpace(req.GetMessage()) == "" {
+			return nil
+		}
+		user := req.GetUser()
+		if user == "" {
+			user = "grpc"
+		}
+		unanswered++
+		c.HandleMessage(user, sessionID, req.GetMessage(), nil, map[string]string{
+			"peer_kind": "direct",
+			"peer_id":   sessionID,
+			"username":  user,
+		})
+		return nil
+	}
+
+	if err := handle(first); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case req := <-requests:
+			if err := handle(req); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			closed = true
+			if unanswered == 0 {
+				return nil
+			}
+		case msg := <-stream.events:
+			if err := srv.Send(grpcEvent(msg)); err != nil {
+				return err
+			}
+			if !msg.Progress && unanswered > 0 {
+				unanswered--
+			}
+			if closed && unanswered == 0 {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *GRPCChannel) authorized(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if !ok || token == "" {
+			continue
+		}
+		for _, want := range c.config.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func grpcEvent(msg bus.OutboundMessage) *agentpb.ChatEvent {
+	if msg.Progress {
+		return &agentpb.ChatEvent{Event: &agentpb.ChatEvent_Progress{
+			Progress: &agentpb.Progress{Content: msg.Content},
+		}}
+	}
+	reply := &agentpb.Reply{Content: msg.Content}
+	for _, b := range msg.Buttons {
+		reply.Buttons = append(reply.Buttons, &agentpb.Button{Label: b.Label, Value: b.Value, Style: b.Style})
+	}
+	return &agentpb.ChatEvent{Event: &agentpb.ChatEvent_Reply{Reply: reply}}
+}
@@ -0,0 +1,194 @@
+package channels
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels/agentpb"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestGRPCChannel(t *testing.T) (*GRPCChannel, *bus.MessageBus, agentpb.AgentClient) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewGRPCChannel(config.GRPCConfig{Tokens: config.FlexibleStringSlice{"secret"}}, msgBus)
+	if err != nil {
+		t.Fatalf("NewGRPCChannel() error = %v", err)
+	}
+	ch.setRunning(true)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	agentpb.RegisterAgentServer(server, ch)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ch, msgBus, agentpb.NewAgentClient(conn)
+}
+
+func grpcContext(t *testing.T, token string) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	return ctx
+}
+
+// answerGRPC plays the agent for n messages, answering each with a progress
+// update and a reply echoing the content.
+func answerGRPC(t *testing.T, ch *GRPCChannel, msgBus *bus.MessageBus, n int) <-chan bus.InboundMessage {
+	t.Helper()
+	got := make(chan bus.InboundMessage, n)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for range n {
+			msg, ok := msgBus.ConsumeInbound(ctx)
+			if !ok {
+				return
+			}
+			got <- msg
+			ch.Send(ctx, bus.OutboundMessage{ChatID: msg.ChatID, Content: "Working: web_search", Progress: true})
+			ch.Send(ctx, bus.OutboundMessage{
+				ChatID:  msg.ChatID,
+				Content: "echo: " + msg.Content,
+				Buttons: []bus.Button{{Label: "More", Value: "more"}},
+			})
+		}
+	}()
+	return got
+}
+
+func TestGRPCChannelChat(t *testing.T) {
+	ch, msgBus, client := newTestGRPCChannel(t)
+	inbound := answerGRPC(t, ch, msgBus, 2)
+
+	stream, err := client.Chat(grpcContext(t, "secret"))
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	for _, text := range []string{"hello", "again"} {
+		if err := stream.Send(&agentpb.ChatRequest{Message: text, User: "billing-service"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	stream.CloseSend()
+
+	var sessionID string
+	var events []string
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		switch e := ev.Event.(type) {
+		case *agentpb.ChatEvent_Session:
+			sessionID = e.Session.SessionId
+			events = append(events, "session")
+		case *agentpb.ChatEvent_Progress:
+			events = append(events, "progress "+e.Progress.Content)
+		case *agentpb.ChatEvent_Reply:
+			if len(e.Reply.Buttons) != 1 || e.Reply.Buttons[0].Value != "more" {
+				t.Errorf("buttons = %v", e.Reply.Buttons)
+			}
+			events = append(events, "reply "+e.Reply.Content)
+		}
+	}
+	want := []string{
+		"session",
+		"progress Working: web_search", "reply echo: hello",
+		"progress Working: web_search", "reply echo: again",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events[%d] = %q, want %q", i, events[i], want[i])
+		}
+	}
+
+	msg := <-inbound
+	if sessionID == "" || msg.Channel != "grpc" || msg.ChatID != sessionID || msg.SenderID != "billing-service" {
+		t.Errorf("session %q, inbound = %+v", sessionID, msg)
+	}
+}
+
+func TestGRPCChannelRejectsBadStreams(t *testing.T) {
+	ch, _, client := newTestGRPCChannel(t)
+
+	recvCode := func(token string, reqs ...*agentpb.ChatRequest) codes.Code {
+		t.Helper()
+		stream, err := client.Chat(grpcContext(t, token))
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		for _, req := range reqs {
+			stream.Send(req)
+		}
+		for {
+			if _, err := stream.Recv(); err != nil {
+				return status.Code(err)
+			}
+		}
+	}
+
+	if got := recvCode("", &agentpb.ChatRequest{Message: "hi"}); got != codes.Unauthenticated {
+		t.Errorf("no token: code = %v", got)
+	}
+	if got := recvCode("nope", &agentpb.ChatRequest{Message: "hi"}); got != codes.Unauthenticated {
+		t.Errorf("wrong token: code = %v", got)
+	}
+
+	ch.mu.Lock()
+	ch.streams["busy"] = &grpcStream{}
+	ch.mu.Unlock()
+	if got := recvCode("secret", &agentpb.ChatRequest{SessionId: "busy"}); got != codes.AlreadyExists {
+		t.Errorf("busy session: code = %v", got)
+	}
+
+	got := recvCode("secret",
+		&agentpb.ChatRequest{SessionId: "s1"},
+		&agentpb.ChatRequest{SessionId: "s2", Message: "hi"})
+	if got != codes.InvalidArgument {
+		t.Errorf("changed session: code = %v", got)
+	}
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "s1", Content: "too late"})
+	if err == nil {
+		t.Error("Send() to a detached session succeeded, want an error")
+	}
+}
+
+func TestNewGRPCChannelRequiresTokens(t *testing.T) {
+	for _, tokens := range []config.FlexibleStringSlice{nil, {""}, {"ok", " "}} {
+		if _, err := NewGRPCChannel(config.GRPCConfig{Tokens: tokens}, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewGRPCChannel(%q) succeeded, want an error", tokens)
+		}
+	}
+}
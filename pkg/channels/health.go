@@ -0,0 +1,166 @@
+package channels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChannelHealth is a snapshot of how a channel is doing, shown by /status.
+type ChannelHealth struct {
+	Running bool
+	// LastEvent is when the platform last delivered anything, zero if
+	// nothing arrived since start.
+	LastEvent time.Time
+	// ConsecutiveErrors counts connection and send failures since the last
+	// success.
+	ConsecutiveErrors int
+	LastError         string
+	LastErrorAt       time.Time
+	// Restarts is how often the supervisor restarted the channel, and
+	// NextRestart when it tries again if the channel is down.
+	Restarts    int
+	NextRestart time.Time
+}
+
+// HealthReporter is implemented by channels that track their health. Every
+// channel built on BaseChannel does.
+type HealthReporter interface {
+	Health() ChannelHealth
+}
+
+// healthRecorder lets the manager count send results against a channel.
+type healthRecorder interface {
+	recordError(err error)
+	recordSuccess()
+}
+
+type healthState struct {
+	mu          sync.Mutex
+	lastEvent   time.Time
+	errors      int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Health reports the channel's current health.
+func (c *BaseChannel) Health() ChannelHealth {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	return ChannelHealth{
+		Running:           c.IsRunning(),
+		LastEvent:         c.health.lastEvent,
+		ConsecutiveErrors: c.health.errors,
+		LastError:         c.health.lastError,
+		LastErrorAt:       c.health.lastErrorAt,
+	}
+}
+
+// recordEvent notes that the platform delivered something, which also
+// shows the connection works.
+func (c *BaseChannel) recordEvent() {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	c.health.lastEvent = time.Now()
+	c.health.errors = 0
+}
+
+// recordSuccess resets the error count after a successful send or
+// reconnect.
+func (c *BaseChannel) recordSuccess() {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	c.health.errors = 0
+}
+
+// recordError counts a failure talking to the platform.
+func (c *BaseChannel) recordError(err error) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	c.health.errors++
+	c.health.lastError = err.Error()
+	c.health.lastErrorAt = time.Now()
+}
+
+// Health reports every channel's health, including supervisor restarts.
+func (m *Manager) Health() map[string]ChannelHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+
+	health := make(map[string]ChannelHealth, len(m.channels))
+	for name, channel := range m.channels {
+		h := ChannelHealth{Running: channel.IsRunning()}
+		if hr, ok := channel.(HealthReporter); ok {
+			h = hr.Health()
+		}
+		if state := m.restarts[name]; state != nil {
+			h.Restarts = state.restarts
+			if !h.Running && state.failures > 0 {
+				h.NextRestart = state.nextAttempt
+			}
+		}
+		health[name] = h
+	}
+	return health
+}
+
+// FormatHealth renders channel health as one line per channel, sorted by
+// name, relative to now.
+func FormatHealth(health map[string]ChannelHealth, now time.Time) string {
+	names := make([]string, 0, len(health))
+	for name := range health {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for i, name := range names {
+		h := health[name]
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		state := "up"
+		if !h.Running {
+			state = "down"
+		}
+		parts := []string{state}
+		if h.LastEvent.IsZero() {
+			parts = append(parts, "no events yet")
+		} else {
+			parts = append(parts, "last event "+formatAgo(now.Sub(h.LastEvent)))
+		}
+		if h.ConsecutiveErrors > 0 {
+			parts = append(parts, fmt.Sprintf("%d errors in a row, last %s: %s",
+				h.ConsecutiveErrors, formatAgo(now.Sub(h.LastErrorAt)), h.LastError))
+		}
+		switch {
+		case h.Restarts == 1:
+			parts = append(parts, "restarted once")
+		case h.Restarts > 1:
+			parts = append(parts, fmt.Sprintf("restarted %d times", h.Restarts))
+		}
+		if !h.NextRestart.IsZero() {
+			parts = append(parts, "retrying in "+h.NextRestart.Sub(now).Round(time.Second).String())
+		}
+		fmt.Fprintf(&sb, "%s: %s", name, strings.Join(parts, ", "))
+	}
+	return sb.String()
+}
+
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
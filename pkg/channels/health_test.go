@@ -0,0 +1,54 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManagerHealth(t *testing.T) {
+	flaky := &flakyChannel{BaseChannel: NewBaseChannel("flaky", nil, nil, nil), failStarts: 2}
+	healthy := &flakyChannel{BaseChannel: NewBaseChannel("healthy", nil, nil, nil)}
+	m := newTestManager(map[string]Channel{"flaky": flaky, "healthy": healthy})
+
+	if err := m.StartAll(context.Background()); err != nil {
+		t.Fatalf("StartAll() error = %v", err)
+	}
+	defer m.StopAll(context.Background())
+	m.checkChannels(context.Background())
+
+	health := m.Health()
+	if h := health["flaky"]; h.Running || h.ConsecutiveErrors != 2 || h.NextRestart.IsZero() {
+		t.Errorf("flaky health = %+v, want down with 2 errors and a pending restart", h)
+	}
+	if h := health["healthy"]; !h.Running || h.ConsecutiveErrors != 0 {
+		t.Errorf("healthy health = %+v", h)
+	}
+
+	healthy.recordError(errors.New("send failed"))
+	healthy.recordEvent()
+	if h := healthy.Health(); h.ConsecutiveErrors != 0 || h.LastEvent.IsZero() || h.LastError != "send failed" {
+		t.Errorf("health after an event = %+v, want errors reset and last error kept", h)
+	}
+}
+
+func TestFormatHealth(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	got := FormatHealth(map[string]ChannelHealth{
+		"telegram": {Running: true, LastEvent: now.Add(-5 * time.Minute)},
+		"slack": {
+			ConsecutiveErrors: 3,
+			LastError:         "invalid_auth",
+			LastErrorAt:       now.Add(-2 * time.Hour),
+			Restarts:          1,
+			NextRestart:       now.Add(40 * time.Second),
+		},
+	}, now)
+
+	want := "slack: down, no events yet, 3 errors in a row, last 2h ago: invalid_auth, " +
+		"restarted once, retrying in 40s\ntelegram: up, last event 5m ago"
+	if got != want {
+		t.Errorf("FormatHealth() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package channels
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxInlineImageSize is the largest image passed to the model; providers
+// reject bigger ones (Anthropic caps images at 5 MB).
+const maxInlineImageSize = 5 << 20
+
+// inlineImageTypes are the image formats multimodal providers accept.
+var inlineImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ImageChannel is implemented by channels embedding BaseChannel. The manager
+// enables it when a model_list entry is marked as accepting images.
+type ImageChannel interface {
+	SetInlineImages(enabled bool)
+}
+
+func (c *BaseChannel) SetInlineImages(enabled bool) {
+	c.images = enabled
+}
+
+// inlineImages reads the image files among media into data URLs. Channels
+// delete their downloads once the message is published, so the agent could
+// not read them later.
+func (c *BaseChannel) inlineImages(media []string) []string {
+	if !c.images {
+		return nil
+	}
+
+	var images []string
+	for _, path := range media {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxInlineImageSize {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.WarnCF("channels", "Failed to read inbound image", map[string]any{
+				"channel": c.name,
+				"path":    path,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		mimeType := http.DetectContentType(data)
+		if !inlineImageTypes[mimeType] {
+			continue
+		}
+		images = append(images, "data:"+mimeType+";base64,"+base64.StdEncoding.EncodeToString(data))
+	}
+	return images
+}
@@ -0,0 +1,62 @@
+package channels
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestHandleMessageInlinesImages(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.jpg") // extension doesn't match; content decides
+	os.WriteFile(photo, pngHeader, 0o600)
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("hello"), 0o600)
+	big := filepath.Join(dir, "big.png")
+	os.WriteFile(big, append(pngHeader, make([]byte, maxInlineImageSize)...), 0o600)
+
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, msgBus, nil)
+	ch.SetInlineImages(true)
+	ch.HandleMessage("u1", "c1", "what's in this photo?", []string{photo, notes, big}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if len(msg.Images) != 1 || !strings.HasPrefix(msg.Images[0], "data:image/png;base64,iVBORw0KGgo") {
+		t.Fatalf("images = %q", msg.Images)
+	}
+	if len(msg.Media) != 3 {
+		t.Errorf("media = %v, want all paths kept", msg.Media)
+	}
+}
+
+func TestHandleMessageSkipsImagesWhenDisabled(t *testing.T) {
+	photo := filepath.Join(t.TempDir(), "photo.png")
+	os.WriteFile(photo, pngHeader, 0o600)
+
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, msgBus, nil)
+	ch.HandleMessage("u1", "c1", "[image: photo]", []string{photo}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.Images != nil {
+		t.Errorf("images = %q, want none without a vision model", msg.Images)
+	}
+}
@@ -0,0 +1,37 @@
+package channels
+
+import "github.com/sipeed/picoclaw/pkg/i18n"
+
+// LocalizedChannel is implemented by channels embedding BaseChannel. The
+// manager sets the language of the messages a channel sends on its own.
+type LocalizedChannel interface {
+	SetLanguage(lang string)
+	Language() string
+}
+
+// SetLanguage sets the language of built-in messages such as placeholders.
+func (c *BaseChannel) SetLanguage(lang string) {
+	c.language = i18n.Normalize(lang)
+}
+
+// Language returns the language set with SetLanguage, English by default.
+func (c *BaseChannel) Language() string {
+	if c.language == "" {
+		return i18n.DefaultLanguage
+	}
+	return c.language
+}
+
+// text returns a built-in message in the channel's language.
+func (c *BaseChannel) text(msg i18n.Message, args ...any) string {
+	return i18n.T(c.Language(), msg, args...)
+}
+
+// channelLanguage returns the language of channel, English for channels
+// that don't embed BaseChannel.
+func channelLanguage(channel Channel) string {
+	if lc, ok := channel.(LocalizedChannel); ok {
+		return lc.Language()
+	}
+	return i18n.DefaultLanguage
+}
@@ -29,6 +29,7 @@ const (
 	lineContentEndpoint  = lineDataAPIBase + "/message/%s/content"
 	lineBotInfoEndpoint  = lineAPIBase + "/info"
 	lineLoadingEndpoint  = lineAPIBase + "/chat/loading/start"
+	lineWebhookEndpoint  = lineAPIBase + "/channel/webhook/endpoint"
 	lineReplyTokenMaxAge = 25 * time.Second
 )
 
@@ -53,6 +54,11 @@ type LINEChannel struct {
 	cancel         context.CancelFunc
 }
 
+var (
+	_ WebhookReceiver  = (*LINEChannel)(nil)
+	_ WebhookRegistrar = (*LINEChannel)(nil)
+)
+
 // NewLINEChannel creates a new LINE channel instance.
 func NewLINEChannel(cfg config.LINEConfig, messageBus *bus.MessageBus) (*LINEChannel, error) {
 	if cfg.ChannelSecret == "" || cfg.ChannelAccessToken == "" {
@@ -67,6 +73,20 @@ func NewLINEChannel(cfg config.LINEConfig, messageBus *bus.MessageBus) (*LINECha
 	}, nil
 }
 
+// WebhookAddr returns the webhook server's listen address and path.
+func (c *LINEChannel) WebhookAddr() (string, string) {
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/line"
+	}
+	return fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort), path
+}
+
+// RegisterWebhook sets the bot's webhook endpoint URL in LINE.
+func (c *LINEChannel) RegisterWebhook(ctx context.Context, url string) error {
+	return c.doAPI(ctx, http.MethodPut, lineWebhookEndpoint, map[string]string{"endpoint": url})
+}
+
 // Start launches the HTTP webhook server.
 func (c *LINEChannel) Start(ctx context.Context) error {
 	logger.InfoC("line", "Starting LINE channel (Webhook Mode)")
@@ -87,13 +107,9 @@ func (c *LINEChannel) Start(ctx context.Context) error {
 	}
 
 	mux := http.NewServeMux()
-	path := c.config.WebhookPath
-	if path == "" {
-		path = "/webhook/line"
-	}
+	addr, path := c.WebhookAddr()
 	mux.HandleFunc(path, c.webhookHandler)
 
-	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
 	c.httpServer = &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -104,7 +120,7 @@ func (c *LINEChannel) Start(ctx context.Context) error {
 			"addr": addr,
 			"path": path,
 		})
-		if err := c.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := c.listenAndServe(c.httpServer); err != nil && err != http.ErrServerClosed {
 			logger.ErrorCF("line", "Webhook server error", map[string]any{
 				"error": err.Error(),
 			})
@@ -284,8 +300,8 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 		return
 	}
 
-	// In group chats, only respond when the bot is mentioned
-	if isGroup && !c.isBotMentioned(msg) {
+	// In group chats, only respond when the bot is addressed
+	if isGroup && !c.acceptGroupMessage(chatID, senderID, msg.Text, c.isBotMentioned(msg)) {
 		logger.DebugCF("line", "Ignoring group message without mention", map[string]any{
 			"chat_id": chatID,
 		})
@@ -566,12 +582,17 @@ func (c *LINEChannel) sendLoading(chatID string) {
 
 // callAPI makes an authenticated POST request to the LINE API.
 func (c *LINEChannel) callAPI(ctx context.Context, endpoint string, payload any) error {
+	return c.doAPI(ctx, http.MethodPost, endpoint, payload)
+}
+
+// doAPI makes an authenticated request with a JSON body to the LINE API.
+func (c *LINEChannel) doAPI(ctx context.Context, method, endpoint string, payload any) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -9,19 +9,34 @@ package channels
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 type Manager struct {
 	channels     map[string]Channel
+	access       *AccessList
+	tls          *webhookTLS
+	tts          voice.Synthesizer
+	attachments  *AttachmentStore
+	groups       *GroupPolicy
+	pipelines    map[string]*Pipeline // channel name -> middleware, nil entries when none
+	deliveries   *DeliveryLog
+	retryBase    time.Duration // first delivery retry delay, deliveryRetryBase when 0
 	bus          *bus.MessageBus
 	config       *config.Config
 	dispatchTask *asyncTask
+	restarts     map[string]*restartState // Supervisor state, guarded by restartMu
+	restartMu    sync.Mutex
 	mu           sync.RWMutex
 }
 
@@ -31,11 +46,42 @@ type asyncTask struct {
 
 func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error) {
 	m := &Manager{
-		channels: make(map[string]Channel),
-		bus:      messageBus,
-		config:   cfg,
+		channels:   make(map[string]Channel),
+		pipelines:  make(map[string]*Pipeline),
+		deliveries: NewDeliveryLog(deliveryHistory),
+		restarts:   make(map[string]*restartState),
+		bus:        messageBus,
+		config:     cfg,
 	}
 
+	access, err := NewAccessList(
+		filepath.Join(cfg.WorkspacePath(), "state", "access.json"),
+		cfg.Channels.Access.Admins,
+	)
+	if err != nil {
+		logger.WarnCF("channels", "Failed to load runtime access grants", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	m.access = access
+
+	webhookTLS, err := newWebhookTLS(cfg.Channels.TLS, defaultCertCacheDir(cfg.WorkspacePath()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid channels.tls config: %w", err)
+	}
+	m.tls = webhookTLS
+	m.groups = NewGroupPolicy(cfg.Channels.Groups)
+
+	tts, err := voice.NewSynthesizer(cfg)
+	if err != nil {
+		logger.ErrorCF("channels", "Voice replies disabled", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	m.tts = tts
+	m.attachments = NewAttachmentStore(cfg.WorkspacePath(), cfg.Channels.Attachments)
+	messageBus.SetNotifier(m.Notify)
+
 	if err := m.initChannels(); err != nil {
 		return nil, err
 	}
@@ -46,169 +92,101 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error
 func (m *Manager) initChannels() error {
 	logger.InfoC("channels", "Initializing channel manager")
 
-	if m.config.Channels.Telegram.Enabled && m.config.Channels.Telegram.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize Telegram channel")
-		telegram, err := NewTelegramChannel(m.config, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Telegram channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["telegram"] = telegram
-			logger.InfoC("channels", "Telegram channel enabled successfully")
+	for _, spec := range channelSpecs {
+		if !spec.enabled(&m.config.Channels) {
+			continue
 		}
-	}
 
-	if m.config.Channels.WhatsApp.Enabled && m.config.Channels.WhatsApp.BridgeURL != "" {
-		logger.DebugC("channels", "Attempting to initialize WhatsApp channel")
-		whatsapp, err := NewWhatsAppChannel(m.config.Channels.WhatsApp, m.bus)
+		pipeline, err := NewPipeline(spec.name, m.config.Channels.Middleware)
 		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WhatsApp channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["whatsapp"] = whatsapp
-			logger.InfoC("channels", "WhatsApp channel enabled successfully")
+			return fmt.Errorf("invalid channels.middleware: %w", err)
 		}
-	}
 
-	if m.config.Channels.Feishu.Enabled {
-		logger.DebugC("channels", "Attempting to initialize Feishu channel")
-		feishu, err := NewFeishuChannel(m.config.Channels.Feishu, m.bus)
+		logger.DebugC("channels", fmt.Sprintf("Attempting to initialize %s channel", spec.display))
+		channel, err := spec.create(m.config, m.bus)
 		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Feishu channel", map[string]any{
+			logger.ErrorCF("channels", fmt.Sprintf("Failed to initialize %s channel", spec.display), map[string]any{
 				"error": err.Error(),
 			})
-		} else {
-			m.channels["feishu"] = feishu
-			logger.InfoC("channels", "Feishu channel enabled successfully")
+			continue
 		}
-	}
 
-	if m.config.Channels.Discord.Enabled && m.config.Channels.Discord.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize Discord channel")
-		discord, err := NewDiscordChannel(m.config.Channels.Discord, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Discord channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["discord"] = discord
-			logger.InfoC("channels", "Discord channel enabled successfully")
+		if lc, ok := channel.(LocalizedChannel); ok {
+			lang := m.config.Channels.Language.For(spec.name)
+			if lang != "" && !i18n.Supported(lang) {
+				logger.WarnCF("channels", "Unsupported language, using English", map[string]any{
+					"channel":  spec.name,
+					"language": lang,
+				})
+			}
+			lc.SetLanguage(lang)
 		}
-	}
-
-	if m.config.Channels.MaixCam.Enabled {
-		logger.DebugC("channels", "Attempting to initialize MaixCam channel")
-		maixcam, err := NewMaixCamChannel(m.config.Channels.MaixCam, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize MaixCam channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["maixcam"] = maixcam
-			logger.InfoC("channels", "MaixCam channel enabled successfully")
+		if ac, ok := channel.(AccessControlled); ok {
+			ac.SetAccessControl(m.access, m.config.Channels.Access.RejectMessage)
 		}
-	}
-
-	if m.config.Channels.QQ.Enabled {
-		logger.DebugC("channels", "Attempting to initialize QQ channel")
-		qq, err := NewQQChannel(m.config.Channels.QQ, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize QQ channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["qq"] = qq
-			logger.InfoC("channels", "QQ channel enabled successfully")
+		if rl, ok := channel.(RateLimited); ok {
+			rateLimit := m.config.Channels.RateLimit
+			cooldown := rateLimit.CooldownMessage
+			if cooldown == "" {
+				cooldown = i18n.T(channelLanguage(channel), i18n.RateLimited)
+			}
+			rl.SetRateLimit(NewRateLimiter(rateLimit), cooldown)
 		}
-	}
-
-	if m.config.Channels.DingTalk.Enabled && m.config.Channels.DingTalk.ClientID != "" {
-		logger.DebugC("channels", "Attempting to initialize DingTalk channel")
-		dingtalk, err := NewDingTalkChannel(m.config.Channels.DingTalk, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize DingTalk channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["dingtalk"] = dingtalk
-			logger.InfoC("channels", "DingTalk channel enabled successfully")
+		if ac, ok := channel.(AttachmentChannel); ok && m.attachments != nil {
+			ac.SetAttachmentStore(m.attachments)
 		}
-	}
-
-	if m.config.Channels.Slack.Enabled && m.config.Channels.Slack.BotToken != "" {
-		logger.DebugC("channels", "Attempting to initialize Slack channel")
-		slackCh, err := NewSlackChannel(m.config.Channels.Slack, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Slack channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["slack"] = slackCh
-			logger.InfoC("channels", "Slack channel enabled successfully")
+		if ic, ok := channel.(ImageChannel); ok && m.config.HasVisionModel() {
+			ic.SetInlineImages(true)
 		}
-	}
-
-	if m.config.Channels.LINE.Enabled && m.config.Channels.LINE.ChannelAccessToken != "" {
-		logger.DebugC("channels", "Attempting to initialize LINE channel")
-		line, err := NewLINEChannel(m.config.Channels.LINE, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize LINE channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["line"] = line
-			logger.InfoC("channels", "LINE channel enabled successfully")
+		if tc, ok := channel.(TLSChannel); ok && m.tls != nil {
+			tc.SetTLSConfig(m.tls.config)
 		}
-	}
-
-	if m.config.Channels.OneBot.Enabled && m.config.Channels.OneBot.WSUrl != "" {
-		logger.DebugC("channels", "Attempting to initialize OneBot channel")
-		onebot, err := NewOneBotChannel(m.config.Channels.OneBot, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize OneBot channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["onebot"] = onebot
-			logger.InfoC("channels", "OneBot channel enabled successfully")
+		if mc, ok := channel.(MediaChannel); ok {
+			mc.SetMediaDir(filepath.Join(m.config.WorkspacePath(), "media", spec.name))
 		}
-	}
-
-	if m.config.Channels.WeCom.Enabled && m.config.Channels.WeCom.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize WeCom channel")
-		wecom, err := NewWeComBotChannel(m.config.Channels.WeCom, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WeCom channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["wecom"] = wecom
-			logger.InfoC("channels", "WeCom channel enabled successfully")
+		if gc, ok := channel.(GroupChannel); ok {
+			gc.SetGroupPolicy(m.groups)
 		}
-	}
-
-	if m.config.Channels.WeComApp.Enabled && m.config.Channels.WeComApp.CorpID != "" {
-		logger.DebugC("channels", "Attempting to initialize WeCom App channel")
-		wecomApp, err := NewWeComAppChannel(m.config.Channels.WeComApp, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WeCom App channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["wecom_app"] = wecomApp
-			logger.InfoC("channels", "WeCom App channel enabled successfully")
+		if mc, ok := channel.(MiddlewareChannel); ok && pipeline != nil {
+			mc.SetMiddleware(pipeline)
 		}
+		m.pipelines[spec.name] = pipeline
+
+		m.channels[spec.name] = channel
+		logger.InfoC("channels", fmt.Sprintf("%s channel enabled successfully", spec.display))
 	}
 
 	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
 		"enabled_channels": len(m.channels),
 	})
 
+	// All channels feed one agent; with the default "main" DM scope every
+	// direct chat, whichever channel it arrives on, continues the same session
+	if scope := m.config.Session.DMScope; len(m.channels) > 1 && (scope == "" || scope == "main") {
+		logger.InfoCF("channels", "Direct messages from all channels share one session", map[string]any{
+			"dm_scope": "main",
+			"hint":     `set session.dm_scope to "per-channel-peer" to key sessions per channel`,
+		})
+	}
+
 	return nil
 }
 
+// SetTranscriber attaches a voice transcriber to every channel that accepts one
+func (m *Manager) SetTranscriber(transcriber voice.Transcriber) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for name, channel := range m.channels {
+		if tc, ok := channel.(TranscribingChannel); ok {
+			tc.SetTranscriber(transcriber)
+			logger.InfoCF("voice", "Groq transcription attached to channel", map[string]any{
+				"channel": name,
+			})
+		}
+	}
+}
+
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -225,18 +203,30 @@ func (m *Manager) StartAll(ctx context.Context) error {
 
 	go m.dispatchOutbound(dispatchCtx)
 
+	if m.tls != nil {
+		m.tls.start()
+	}
+
+	// Channels are independent: one failing to start doesn't stop the others,
+	// and the supervisor keeps retrying it in the background
 	for name, channel := range m.channels {
 		logger.InfoCF("channels", "Starting channel", map[string]any{
 			"channel": name,
 		})
-		if err := channel.Start(ctx); err != nil {
+		if err := safeChannelCall(func() error { return channel.Start(ctx) }); err != nil {
 			logger.ErrorCF("channels", "Failed to start channel", map[string]any{
 				"channel": name,
 				"error":   err.Error(),
 			})
+			if hr, ok := channel.(healthRecorder); ok {
+				hr.recordError(err)
+			}
 		}
 	}
 
+	go m.supervise(dispatchCtx)
+	m.startTunnels(dispatchCtx)
+
 	logger.InfoC("channels", "All channels started")
 	return nil
 }
@@ -252,6 +242,10 @@ func (m *Manager) StopAll(ctx context.Context) error {
 		m.dispatchTask = nil
 	}
 
+	if m.tls != nil {
+		m.tls.stop(ctx)
+	}
+
 	for name, channel := range m.channels {
 		logger.InfoCF("channels", "Stopping channel", map[string]any{
 			"channel": name,
@@ -289,6 +283,7 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 
 			m.mu.RLock()
 			channel, exists := m.channels[msg.Channel]
+			pipeline := m.pipelines[msg.Channel]
 			m.mu.RUnlock()
 
 			if !exists {
@@ -298,16 +293,45 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
-			if err := channel.Send(ctx, msg); err != nil {
-				logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
-					"channel": msg.Channel,
-					"error":   err.Error(),
-				})
+			if msg.Progress {
+				if pc, ok := channel.(ProgressChannel); !ok || !pc.SupportsProgress() {
+					continue
+				}
+			}
+
+			msg, allowed := pipeline.Outbound(msg)
+			if !allowed {
+				continue
+			}
+
+			// Progress updates are stale by the time a retry would run
+			if msg.Progress {
+				err := sendSafely(ctx, channel, msg)
+				if err != nil {
+					logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
+						"channel": msg.Channel,
+						"error":   err.Error(),
+					})
+				}
+				recordSendResult(channel, err)
+				continue
 			}
+
+			msg, audioPath := m.speakReply(ctx, channel, msg)
+			m.deliver(ctx, channel, msg, func() {
+				if audioPath != "" {
+					os.Remove(audioPath)
+				}
+			})
 		}
 	}
 }
 
+// Access returns the runtime access list shared by all channels
+func (m *Manager) Access() *AccessList {
+	return m.access
+}
+
 func (m *Manager) GetChannel(name string) (Channel, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -316,15 +340,30 @@ func (m *Manager) GetChannel(name string) (Channel, bool) {
 }
 
 func (m *Manager) GetStatus() map[string]any {
+	health := m.Health()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	status := make(map[string]any)
 	for name, channel := range m.channels {
-		status[name] = map[string]any{
-			"enabled": true,
-			"running": channel.IsRunning(),
+		h := health[name]
+		channelStatus := map[string]any{
+			"enabled":            true,
+			"running":            h.Running,
+			"restarts":           h.Restarts,
+			"consecutive_errors": h.ConsecutiveErrors,
+		}
+		if !h.LastEvent.IsZero() {
+			channelStatus["last_event"] = h.LastEvent.Format(time.RFC3339)
+		}
+		if h.LastError != "" {
+			channelStatus["last_error"] = h.LastError
+		}
+		if pc, ok := channel.(interface{ PublicURL() string }); ok && pc.PublicURL() != "" {
+			channelStatus["public_url"] = pc.PublicURL()
 		}
+		status[name] = channelStatus
 	}
 	return status
 }
@@ -0,0 +1,149 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewManagerBuildsEnabledChannels(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Channels.WeComApp.Enabled = true
+	cfg.Channels.WeComApp.CorpID = "corp"
+	cfg.Channels.WeComApp.CorpSecret = "secret"
+	cfg.Channels.WeComApp.AgentID = 1000002
+	cfg.Channels.Webhook.Enabled = true
+	cfg.Channels.Webhook.OutboundURL = "http://127.0.0.1:0/unused"
+	// Enabled but missing required settings: skipped by its spec
+	cfg.Channels.WeCom.Enabled = true
+
+	m, err := NewManager(cfg, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	names := m.GetEnabledChannels()
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "webhook" || names[1] != "wecom_app" {
+		t.Fatalf("enabled channels = %v, want [webhook wecom_app]", names)
+	}
+
+	ch, _ := m.GetChannel("wecom_app")
+	wc := ch.(*WeComAppChannel)
+	if want := filepath.Join(cfg.WorkspacePath(), "media", "wecom_app"); wc.mediaDir != want {
+		t.Errorf("media dir = %q, want %q", wc.mediaDir, want)
+	}
+}
+
+func TestChannelSpecsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, spec := range channelSpecs {
+		if seen[spec.name] {
+			t.Errorf("duplicate channel spec %q", spec.name)
+		}
+		seen[spec.name] = true
+	}
+}
+
+// flakyChannel fails or panics on its first starts, then runs normally.
+type flakyChannel struct {
+	*BaseChannel
+	failStarts int
+	panicStart bool
+	starts     int
+	sendPanics bool
+}
+
+func (c *flakyChannel) Start(ctx context.Context) error {
+	c.starts++
+	if c.panicStart {
+		c.panicStart = false
+		panic("boom")
+	}
+	if c.starts <= c.failStarts {
+		return errors.New("connection refused")
+	}
+	c.setRunning(true)
+	return nil
+}
+
+func (c *flakyChannel) Stop(ctx context.Context) error {
+	c.setRunning(false)
+	return nil
+}
+
+func (c *flakyChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if c.sendPanics {
+		panic("send failed")
+	}
+	return nil
+}
+
+func newTestManager(channels map[string]Channel) *Manager {
+	m := &Manager{
+		channels: channels,
+		restarts: make(map[string]*restartState),
+		bus:      bus.NewMessageBus(),
+		config:   config.DefaultConfig(),
+	}
+	return m
+}
+
+func TestSupervisorRestartsStoppedChannels(t *testing.T) {
+	flaky := &flakyChannel{BaseChannel: NewBaseChannel("flaky", nil, nil, nil), failStarts: 1}
+	crashy := &flakyChannel{BaseChannel: NewBaseChannel("crashy", nil, nil, nil), panicStart: true}
+	healthy := &flakyChannel{BaseChannel: NewBaseChannel("healthy", nil, nil, nil)}
+	m := newTestManager(map[string]Channel{"flaky": flaky, "crashy": crashy, "healthy": healthy})
+
+	if err := m.StartAll(context.Background()); err != nil {
+		t.Fatalf("StartAll() error = %v", err)
+	}
+	defer m.StopAll(context.Background())
+
+	if !healthy.IsRunning() || flaky.IsRunning() || crashy.IsRunning() {
+		t.Fatalf("unexpected state after start: healthy=%v flaky=%v crashy=%v",
+			healthy.IsRunning(), flaky.IsRunning(), crashy.IsRunning())
+	}
+
+	m.checkChannels(context.Background())
+
+	if !flaky.IsRunning() || !crashy.IsRunning() {
+		t.Fatalf("channels were not restarted: flaky=%v crashy=%v", flaky.IsRunning(), crashy.IsRunning())
+	}
+	if healthy.starts != 1 {
+		t.Errorf("healthy channel started %d times, want 1", healthy.starts)
+	}
+
+	status := m.GetStatus()["flaky"].(map[string]any)
+	if status["restarts"] != 1 {
+		t.Errorf("restarts = %v, want 1", status["restarts"])
+	}
+}
+
+func TestSupervisorBacksOff(t *testing.T) {
+	flaky := &flakyChannel{BaseChannel: NewBaseChannel("flaky", nil, nil, nil), failStarts: 10}
+	m := newTestManager(map[string]Channel{"flaky": flaky})
+
+	m.checkChannels(context.Background())
+	m.checkChannels(context.Background())
+
+	if flaky.starts != 1 {
+		t.Errorf("starts = %d, want 1 while backing off", flaky.starts)
+	}
+	if got := restartBackoff(20); got != restartBackoffMax {
+		t.Errorf("restartBackoff(20) = %v, want %v", got, restartBackoffMax)
+	}
+}
+
+func TestSendSafelyRecoversPanics(t *testing.T) {
+	ch := &flakyChannel{BaseChannel: NewBaseChannel("bad", nil, nil, nil), sendPanics: true}
+	if err := sendSafely(context.Background(), ch, bus.OutboundMessage{}); err == nil {
+		t.Fatal("expected error from panicking Send")
+	}
+}
@@ -0,0 +1,514 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Mastodon channel implementation
+// Listens for mentions on the user streaming API and answers them as
+// threaded replies through the REST API
+
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	mastodonAPITimeout        = 30 * time.Second
+	mastodonMinReconnectDelay = 5 * time.Second
+	mastodonMaxReconnectDelay = 5 * time.Minute
+)
+
+// mastodonVisibility ranks post visibilities from most to least public.
+var mastodonVisibility = map[string]int{
+	"public":   0,
+	"unlisted": 1,
+	"private":  2,
+	"direct":   3,
+}
+
+var (
+	reMastodonBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+	reMastodonPara  = regexp.MustCompile(`(?i)</p>\s*<p[^>]*>`)
+	reMastodonTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// MastodonChannel answers mentions and direct messages on a Mastodon (or
+// compatible) server. Chats are keyed by the sender's acct, so each person
+// keeps one conversation across threads.
+type MastodonChannel struct {
+	*BaseChannel
+	config config.MastodonConfig
+	server string
+	client *http.Client // REST calls
+	stream *http.Client // the long-lived streaming connection
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	self    mastodonAccount
+	threads map[string]mastodonThread // chatID -> post to reply to
+}
+
+type mastodonAccount struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Acct        string `json:"acct"`
+	DisplayName string `json:"display_name"`
+}
+
+type mastodonAttachment struct {
+	Type        string `json:"type"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type mastodonStatus struct {
+	ID               string               `json:"id"`
+	Content          string               `json:"content"`
+	Visibility       string               `json:"visibility"`
+	SpoilerText      string               `json:"spoiler_text"`
+	Account          mastodonAccount      `json:"account"`
+	MediaAttachments []mastodonAttachment `json:"media_attachments"`
+}
+
+type mastodonNotification struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Account mastodonAccount `json:"account"`
+	Status  *mastodonStatus `json:"status"`
+}
+
+// mastodonThread is where the next reply in a chat goes.
+type mastodonThread struct {
+	statusID   string
+	visibility string
+	spoiler    string // content warning of the post being answered
+}
+
+func NewMastodonChannel(cfg config.MastodonConfig, messageBus *bus.MessageBus) (*MastodonChannel, error) {
+	if cfg.Server == "" || cfg.AccessToken == "" {
+		return nil, fmt.Errorf("mastodon server and access_token are required")
+	}
+	if cfg.Visibility == "" {
+		cfg.Visibility = "unlisted"
+	}
+	if _, ok := mastodonVisibility[cfg.Visibility]; !ok {
+		return nil, fmt.Errorf("invalid mastodon visibility %q: use public, unlisted, private or direct",
+			cfg.Visibility)
+	}
+	if cfg.MaxChars <= 0 {
+		cfg.MaxChars = 500
+	}
+	if cfg.SpoilerText == "" {
+		cfg.SpoilerText = "Long reply"
+	}
+
+	server := strings.TrimRight(cfg.Server, "/")
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+
+	base := NewBaseChannel("mastodon", cfg, messageBus, cfg.AllowFrom)
+
+	return &MastodonChannel{
+		BaseChannel: base,
+		config:      cfg,
+		server:      server,
+		client:      &http.Client{Timeout: mastodonAPITimeout},
+		stream:      &http.Client{},
+		threads:     make(map[string]mastodonThread),
+	}, nil
+}
+
+func (c *MastodonChannel) Start(ctx context.Context) error {
+	logger.InfoCF("mastodon", "Starting Mastodon channel", map[string]any{
+		"server": c.server,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.run()
+
+	c.setRunning(true)
+	logger.InfoC("mastodon", "Mastodon channel started")
+	return nil
+}
+
+func (c *MastodonChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mastodon", "Stopping Mastodon channel")
+	c.setRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Send posts the reply as one or more statuses, each answering the previous
+// one so long answers read as a thread. Chats with no post to answer get a
+// new direct message.
+func (c *MastodonChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("mastodon channel not running")
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	thread, ok := c.threads[msg.ChatID]
+	c.mu.Unlock()
+	if !ok {
+		thread = mastodonThread{visibility: "direct"}
+	}
+
+	spoiler := thread.spoiler
+	if spoiler == "" && c.config.SpoilerThreshold > 0 &&
+		utf8.RuneCountInString(msg.Content) > c.config.SpoilerThreshold {
+		spoiler = c.config.SpoilerText
+	}
+
+	// Mastodon counts the mention and content warning against the limit
+	prefix := "@" + msg.ChatID + " "
+	budget := c.config.MaxChars - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(spoiler)
+	if budget < 100 {
+		budget = 100
+	}
+	chunks := []string{msg.Content}
+	if len(msg.Content) > budget {
+		chunks = utils.SplitMessage(msg.Content, budget)
+	}
+
+	for _, chunk := range chunks {
+		form := url.Values{}
+		form.Set("status", prefix+chunk)
+		form.Set("visibility", thread.visibility)
+		if thread.statusID != "" {
+			form.Set("in_reply_to_id", thread.statusID)
+		}
+		if spoiler != "" {
+			form.Set("spoiler_text", spoiler)
+		}
+
+		var posted mastodonStatus
+		if err := c.api(ctx, http.MethodPost, "/api/v1/statuses", form, &posted); err != nil {
+			logger.ErrorCF("mastodon", "Failed to post reply", map[string]any{
+				"chat_id": msg.ChatID,
+				"error":   err.Error(),
+			})
+			return err
+		}
+		thread.statusID = posted.ID
+	}
+
+	c.mu.Lock()
+	c.threads[msg.ChatID] = thread
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MastodonChannel) run() {
+	delay := mastodonMinReconnectDelay
+
+	for {
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		body, err := c.connect()
+		if err != nil {
+			logger.ErrorCF("mastodon", "Connection failed", map[string]any{
+				"error":       err.Error(),
+				"retry_after": delay.String(),
+			})
+			c.recordError(err)
+		} else {
+			delay = mastodonMinReconnectDelay
+			c.recordSuccess()
+
+			err = c.listen(body)
+			body.Close()
+
+			if c.ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("mastodon", "Stream closed, will reconnect", map[string]any{
+				"error": fmt.Sprint(err),
+			})
+			c.recordError(fmt.Errorf("stream closed: %v", err))
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > mastodonMaxReconnectDelay {
+			delay = mastodonMaxReconnectDelay
+		}
+	}
+}
+
+// connect looks up the bot's own account and opens the notification stream.
+func (c *MastodonChannel) connect() (io.ReadCloser, error) {
+	var self mastodonAccount
+	if err := c.api(c.ctx, http.MethodGet, "/api/v1/accounts/verify_credentials", nil, &self); err != nil {
+		return nil, fmt.Errorf("verify credentials: %w", err)
+	}
+	c.mu.Lock()
+	c.self = self
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet,
+		c.streamingBase()+"/api/v1/streaming/user/notification", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("streaming API returned %s", resp.Status)
+	}
+
+	logger.InfoCF("mastodon", "Connected to streaming API", map[string]any{
+		"account": self.Acct,
+	})
+	return resp.Body, nil
+}
+
+// streamingBase returns the streaming server's base URL, which large
+// instances host separately from the REST API.
+func (c *MastodonChannel) streamingBase() string {
+	var instance struct {
+		URLs struct {
+			StreamingAPI string `json:"streaming_api"`
+		} `json:"urls"`
+	}
+	if err := c.api(c.ctx, http.MethodGet, "/api/v1/instance", nil, &instance); err != nil {
+		return c.server
+	}
+
+	base := strings.TrimRight(instance.URLs.StreamingAPI, "/")
+	switch {
+	case strings.HasPrefix(base, "wss://"):
+		return "https://" + strings.TrimPrefix(base, "wss://")
+	case strings.HasPrefix(base, "ws://"):
+		return "http://" + strings.TrimPrefix(base, "ws://")
+	case strings.HasPrefix(base, "http"):
+		return base
+	default:
+		return c.server
+	}
+}
+
+// listen reads server-sent events until the stream ends.
+func (c *MastodonChannel) listen(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var event string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "notification" && data.Len() > 0 {
+				var n mastodonNotification
+				if err := json.Unmarshal([]byte(data.String()), &n); err != nil {
+					logger.WarnCF("mastodon", "Invalid notification", map[string]any{"error": err.Error()})
+				} else {
+					c.handleNotification(&n)
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// Heartbeat comment
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (c *MastodonChannel) handleNotification(n *mastodonNotification) {
+	if n.Type != "mention" || n.Status == nil {
+		return
+	}
+
+	c.mu.Lock()
+	self := c.self
+	c.mu.Unlock()
+	if n.Account.ID == self.ID {
+		return
+	}
+
+	status := n.Status
+	senderID := n.Account.Acct
+	content := stripMastodonMention(mastodonText(status.Content), self.Username)
+	if status.SpoilerText != "" {
+		content = fmt.Sprintf("[content warning: %s]\n%s", status.SpoilerText, content)
+	}
+
+	mediaPaths := []string{}
+	defer func() {
+		for _, file := range mediaPaths {
+			if err := os.Remove(file); err != nil {
+				logger.DebugCF("mastodon", "Failed to cleanup temp file", map[string]any{
+					"file":  file,
+					"error": err.Error(),
+				})
+			}
+		}
+	}()
+	for _, a := range status.MediaAttachments {
+		label := a.Type
+		if a.Description != "" {
+			label += ": " + a.Description
+		}
+		if localPath := c.downloadAttachment(a); localPath != "" {
+			mediaPaths = append(mediaPaths, localPath)
+		}
+		if content != "" {
+			content += "\n"
+		}
+		content += "[" + label + "]"
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	c.mu.Lock()
+	c.threads[senderID] = mastodonThread{
+		statusID:   status.ID,
+		visibility: c.replyVisibility(status.Visibility),
+		spoiler:    status.SpoilerText,
+	}
+	c.mu.Unlock()
+
+	metadata := map[string]string{
+		"message_id":  status.ID,
+		"visibility":  status.Visibility,
+		"username":    n.Account.Username,
+		"sender_name": n.Account.DisplayName,
+		"peer_kind":   "direct",
+		"peer_id":     senderID,
+	}
+
+	logger.DebugCF("mastodon", "Received mention", map[string]any{
+		"sender":     senderID,
+		"visibility": status.Visibility,
+		"preview":    utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(senderID, senderID, content, mediaPaths, metadata)
+}
+
+// replyVisibility is the configured visibility, narrowed so a reply is never
+// more public than the post it answers.
+func (c *MastodonChannel) replyVisibility(incoming string) string {
+	rank, ok := mastodonVisibility[incoming]
+	if !ok || rank <= mastodonVisibility[c.config.Visibility] {
+		return c.config.Visibility
+	}
+	return incoming
+}
+
+func (c *MastodonChannel) downloadAttachment(a mastodonAttachment) string {
+	if a.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return ""
+	}
+	return utils.DownloadFile(a.URL, path.Base(u.Path), utils.DownloadOptions{
+		LoggerPrefix: "mastodon",
+	})
+}
+
+// api calls the REST API with form parameters and decodes the JSON reply
+// into out.
+func (c *MastodonChannel) api(ctx context.Context, method, endpoint string, form url.Values, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, endpoint, resp.Status, utils.Truncate(string(data), 200))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// mastodonText converts a status' HTML content to plain text.
+func mastodonText(content string) string {
+	text := reMastodonPara.ReplaceAllString(content, "\n\n")
+	text = reMastodonBreak.ReplaceAllString(text, "\n")
+	text = reMastodonTag.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// stripMastodonMention removes mentions of the bot, local (@bot) or remote
+// (@bot@example.social), from text.
+func stripMastodonMention(text, username string) string {
+	if username == "" {
+		return text
+	}
+	re := regexp.MustCompile(`(?i)(^|\s)@` + regexp.QuoteMeta(username) + `(@[\w.-]+)?\b`)
+	return strings.TrimSpace(re.ReplaceAllString(text, "$1"))
+}
@@ -0,0 +1,209 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeMastodon serves the endpoints the channel uses and records posts.
+type fakeMastodon struct {
+	*httptest.Server
+	events chan string
+
+	mu    sync.Mutex
+	posts []url.Values
+}
+
+func newFakeMastodon(t *testing.T) *fakeMastodon {
+	t.Helper()
+	f := &fakeMastodon{events: make(chan string, 8)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/accounts/verify_credentials", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1","username":"claw","acct":"claw"}`)
+	})
+	mux.HandleFunc("/api/v1/instance", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"urls":{"streaming_api":"ws://%s"}}`, r.Host)
+	})
+	mux.HandleFunc("/api/v1/streaming/user/notification", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ":)\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data := <-f.events:
+				fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+	mux.HandleFunc("/api/v1/statuses", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		f.posts = append(f.posts, r.PostForm)
+		id := len(f.posts)
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"id":"reply-%d"}`, id)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeMastodon) Posts() []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]url.Values(nil), f.posts...)
+}
+
+func newTestMastodonChannel(t *testing.T, server string, visibility string) (*MastodonChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewMastodonChannel(config.MastodonConfig{
+		Server:           server,
+		AccessToken:      "token",
+		Visibility:       visibility,
+		MaxChars:         200,
+		SpoilerThreshold: 150,
+		SpoilerText:      "Long reply",
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewMastodonChannel() error = %v", err)
+	}
+	return ch, msgBus
+}
+
+func TestMastodonChannelAnswersMentionsInThread(t *testing.T) {
+	server := newFakeMastodon(t)
+	ch, msgBus := newTestMastodonChannel(t, server.URL, "unlisted")
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(context.Background())
+
+	server.events <- `{"id":"n1","type":"mention",` +
+		`"account":{"id":"2","username":"alice","acct":"alice@example.social"},` +
+		`"status":{"id":"100","visibility":"direct","spoiler_text":"",` +
+		`"content":"<p><span class=\"h-card\"><a href=\"https://x/@claw\" class=\"u-url mention\">` +
+		`@<span>claw</span></a></span> what&#39;s up?</p><p>second<br>line</p>"}}`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.SenderID != "alice@example.social" || msg.ChatID != "alice@example.social" {
+		t.Errorf("sender/chat = %q/%q", msg.SenderID, msg.ChatID)
+	}
+	if msg.Content != "what's up?\n\nsecond\nline" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.Metadata["message_id"] != "100" || msg.Metadata["visibility"] != "direct" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+
+	reply := strings.Repeat("word ", 60)
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: msg.ChatID, Content: reply}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	posts := server.Posts()
+	if len(posts) < 2 {
+		t.Fatalf("got %d posts, want the long reply split", len(posts))
+	}
+	for i, post := range posts {
+		if !strings.HasPrefix(post.Get("status"), "@alice@example.social ") {
+			t.Errorf("post %d status = %q", i, post.Get("status"))
+		}
+		if len([]rune(post.Get("status")))+len([]rune(post.Get("spoiler_text"))) > 200 {
+			t.Errorf("post %d is over max_chars", i)
+		}
+		if post.Get("visibility") != "direct" {
+			t.Errorf("post %d visibility = %q, a DM must stay direct", i, post.Get("visibility"))
+		}
+		if post.Get("spoiler_text") != "Long reply" {
+			t.Errorf("post %d spoiler_text = %q", i, post.Get("spoiler_text"))
+		}
+		wantReplyTo := "100"
+		if i > 0 {
+			wantReplyTo = fmt.Sprintf("reply-%d", i)
+		}
+		if post.Get("in_reply_to_id") != wantReplyTo {
+			t.Errorf("post %d in_reply_to_id = %q, want %q", i, post.Get("in_reply_to_id"), wantReplyTo)
+		}
+	}
+}
+
+func TestMastodonChannelSkipsOwnAndNonMentionNotifications(t *testing.T) {
+	ch, msgBus := newTestMastodonChannel(t, "https://example.social", "")
+	ch.self = mastodonAccount{ID: "1", Username: "claw"}
+
+	ch.handleNotification(&mastodonNotification{Type: "favourite", Status: &mastodonStatus{Content: "hi"}})
+	ch.handleNotification(&mastodonNotification{
+		Type:    "mention",
+		Account: mastodonAccount{ID: "1", Acct: "claw"},
+		Status:  &mastodonStatus{Content: "<p>talking to myself</p>"},
+	})
+
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("unexpected inbound message: %+v", msg)
+	}
+}
+
+func TestMastodonReplyVisibility(t *testing.T) {
+	ch, _ := newTestMastodonChannel(t, "https://example.social", "unlisted")
+
+	tests := map[string]string{
+		"public":   "unlisted",
+		"unlisted": "unlisted",
+		"private":  "private",
+		"direct":   "direct",
+		"":         "unlisted",
+	}
+	for incoming, want := range tests {
+		if got := ch.replyVisibility(incoming); got != want {
+			t.Errorf("replyVisibility(%q) = %q, want %q", incoming, got, want)
+		}
+	}
+
+	if _, err := NewMastodonChannel(config.MastodonConfig{
+		Server:      "example.social",
+		AccessToken: "token",
+		Visibility:  "followers",
+	}, bus.NewMessageBus()); err == nil {
+		t.Error("expected an error for an unknown visibility")
+	}
+}
+
+func TestStripMastodonMention(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"@claw hello", "hello"},
+		{"@claw@example.social hello", "hello"},
+		{"hey @Claw, are you there?", "hey , are you there?"},
+		{"@clawdia hello", "@clawdia hello"},
+	}
+	for _, tt := range tests {
+		if got := stripMastodonMention(tt.in, "claw"); got != tt.want {
+			t.Errorf("stripMastodonMention(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
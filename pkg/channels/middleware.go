@@ -0,0 +1,244 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Channel middleware
+// Stackable filters and transforms applied to messages between the channels
+// and the agent, configured per channel without touching the channel code
+
+package channels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// InboundMiddleware rewrites a message on its way to the agent, or returns
+// false to drop it.
+type InboundMiddleware func(msg bus.InboundMessage) (bus.InboundMessage, bool)
+
+// OutboundMiddleware rewrites a reply on its way to the channel, or returns
+// false to drop it.
+type OutboundMiddleware func(msg bus.OutboundMessage) (bus.OutboundMessage, bool)
+
+// MiddlewareChannel is implemented by channels that run inbound messages
+// through a middleware pipeline before publishing them.
+type MiddlewareChannel interface {
+	SetMiddleware(pipeline *Pipeline)
+}
+
+func (c *BaseChannel) SetMiddleware(pipeline *Pipeline) {
+	c.middleware = pipeline
+}
+
+// Pipeline runs middleware in the order it was added.
+type Pipeline struct {
+	inbound  []InboundMiddleware
+	outbound []OutboundMiddleware
+}
+
+// UseInbound appends middleware for messages from users.
+func (p *Pipeline) UseInbound(mw InboundMiddleware) {
+	p.inbound = append(p.inbound, mw)
+}
+
+// UseOutbound appends middleware for replies to users.
+func (p *Pipeline) UseOutbound(mw OutboundMiddleware) {
+	p.outbound = append(p.outbound, mw)
+}
+
+// Inbound passes msg through the inbound middleware. A nil pipeline passes
+// every message unchanged.
+func (p *Pipeline) Inbound(msg bus.InboundMessage) (bus.InboundMessage, bool) {
+	if p == nil {
+		return msg, true
+	}
+	for _, mw := range p.inbound {
+		var ok bool
+		if msg, ok = mw(msg); !ok {
+			return msg, false
+		}
+	}
+	return msg, true
+}
+
+// Outbound passes msg through the outbound middleware. Progress updates go
+// through the same steps as final replies.
+func (p *Pipeline) Outbound(msg bus.OutboundMessage) (bus.OutboundMessage, bool) {
+	if p == nil {
+		return msg, true
+	}
+	for _, mw := range p.outbound {
+		var ok bool
+		if msg, ok = mw(msg); !ok {
+			return msg, false
+		}
+	}
+	return msg, true
+}
+
+// contentFilter is the shape of the built-in middleware: it sees a message's
+// text and returns the text to pass on, or false to drop the message.
+type contentFilter func(channel, chatID, content string) (string, bool)
+
+// middlewareSpec describes a built-in middleware type.
+type middlewareSpec struct {
+	direction string // used when the config leaves direction empty
+	build     func(cfg config.MiddlewareConfig) (contentFilter, error)
+}
+
+var middlewareSpecs = map[string]middlewareSpec{
+	"log":         {direction: "both", build: buildLogMiddleware},
+	"normalize":   {direction: "inbound", build: buildNormalizeMiddleware},
+	"word_filter": {direction: "outbound", build: buildWordFilterMiddleware},
+	"block":       {direction: "inbound", build: buildBlockMiddleware},
+	"replace":     {direction: "inbound", build: buildReplaceMiddleware},
+}
+
+// NewPipeline builds the pipeline for channelName from config: the steps
+// listed under "*" apply to every channel and run before the channel's own.
+// It returns nil when no middleware is configured.
+func NewPipeline(channelName string, cfg map[string][]config.MiddlewareConfig) (*Pipeline, error) {
+	steps := append(append([]config.MiddlewareConfig{}, cfg["*"]...), cfg[channelName]...)
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	p := &Pipeline{}
+	for i, step := range steps {
+		spec, ok := middlewareSpecs[step.Type]
+		if !ok {
+			return nil, fmt.Errorf("middleware %d for %s: unknown type %q", i, channelName, step.Type)
+		}
+		filter, err := spec.build(step)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %d (%s) for %s: %w", i, step.Type, channelName, err)
+		}
+
+		direction := step.Direction
+		if direction == "" {
+			direction = spec.direction
+		}
+		switch direction {
+		case "inbound":
+			p.UseInbound(inboundFilter(filter))
+		case "outbound":
+			p.UseOutbound(outboundFilter(filter))
+		case "both":
+			p.UseInbound(inboundFilter(filter))
+			p.UseOutbound(outboundFilter(filter))
+		default:
+			return nil, fmt.Errorf("middleware %d (%s) for %s: invalid direction %q",
+				i, step.Type, channelName, direction)
+		}
+	}
+	return p, nil
+}
+
+func inboundFilter(filter contentFilter) InboundMiddleware {
+	return func(msg bus.InboundMessage) (bus.InboundMessage, bool) {
+		var ok bool
+		msg.Content, ok = filter(msg.Channel, msg.ChatID, msg.Content)
+		return msg, ok
+	}
+}
+
+func outboundFilter(filter contentFilter) OutboundMiddleware {
+	return func(msg bus.OutboundMessage) (bus.OutboundMessage, bool) {
+		var ok bool
+		msg.Content, ok = filter(msg.Channel, msg.ChatID, msg.Content)
+		return msg, ok
+	}
+}
+
+// buildLogMiddleware logs a preview of every message.
+func buildLogMiddleware(cfg config.MiddlewareConfig) (contentFilter, error) {
+	return func(channel, chatID, content string) (string, bool) {
+		logger.InfoCF("middleware", "Message", map[string]any{
+			"channel": channel,
+			"chat_id": chatID,
+			"preview": utils.Truncate(content, 100),
+		})
+		return content, true
+	}, nil
+}
+
+// buildNormalizeMiddleware applies Unicode NFKC normalization, which folds
+// full-width letters and digits and compatibility characters into their
+// plain forms, and trims surrounding whitespace.
+func buildNormalizeMiddleware(cfg config.MiddlewareConfig) (contentFilter, error) {
+	return func(channel, chatID, content string) (string, bool) {
+		return strings.TrimSpace(norm.NFKC.String(content)), true
+	}, nil
+}
+
+// buildWordFilterMiddleware masks the configured words, matched as whole
+// words regardless of case, with the replacement (default "***").
+func buildWordFilterMiddleware(cfg config.MiddlewareConfig) (contentFilter, error) {
+	quoted := make([]string, 0, len(cfg.Words))
+	for _, word := range cfg.Words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil, fmt.Errorf("words is required")
+	}
+	re, err := regexp.Compile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	if err != nil {
+		return nil, err
+	}
+	replacement := cfg.Replacement
+	if replacement == "" {
+		replacement = "***"
+	}
+	return func(channel, chatID, content string) (string, bool) {
+		return re.ReplaceAllLiteralString(content, replacement), true
+	}, nil
+}
+
+// buildBlockMiddleware drops messages matching the pattern.
+func buildBlockMiddleware(cfg config.MiddlewareConfig) (contentFilter, error) {
+	re, err := compileMiddlewarePattern(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(channel, chatID, content string) (string, bool) {
+		if re.MatchString(content) {
+			logger.DebugCF("middleware", "Message blocked", map[string]any{
+				"channel": channel,
+				"chat_id": chatID,
+			})
+			return content, false
+		}
+		return content, true
+	}, nil
+}
+
+// buildReplaceMiddleware rewrites matches of the pattern; the replacement may
+// refer to capture groups as $1.
+func buildReplaceMiddleware(cfg config.MiddlewareConfig) (contentFilter, error) {
+	re, err := compileMiddlewarePattern(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(channel, chatID, content string) (string, bool) {
+		return re.ReplaceAllString(content, cfg.Replacement), true
+	}, nil
+}
+
+func compileMiddlewarePattern(cfg config.MiddlewareConfig) (*regexp.Regexp, error) {
+	if cfg.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	re, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
@@ -0,0 +1,87 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestPipelineFromConfig(t *testing.T) {
+	cfg := map[string][]config.MiddlewareConfig{
+		"*": {{Type: "normalize"}},
+		"telegram": {
+			{Type: "block", Pattern: `(?i)^spam`},
+			{Type: "word_filter", Words: []string{"darn"}},
+			{Type: "replace", Pattern: `colour`, Replacement: "color", Direction: "both"},
+		},
+	}
+	p, err := NewPipeline("telegram", cfg)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	in, ok := p.Inbound(bus.InboundMessage{Content: "  ＡＢＣ１２３ colour darn "})
+	if !ok || in.Content != "ABC123 color darn" {
+		t.Errorf("Inbound = (%q, %v), want normalized text with only inbound steps applied", in.Content, ok)
+	}
+	if _, ok := p.Inbound(bus.InboundMessage{Content: "SPAM offer"}); ok {
+		t.Error("blocked message passed the pipeline")
+	}
+
+	out, ok := p.Outbound(bus.OutboundMessage{Content: "Darn, that colour! darning"})
+	if !ok || out.Content != "***, that color! darning" {
+		t.Errorf("Outbound = (%q, %v)", out.Content, ok)
+	}
+
+	other := map[string][]config.MiddlewareConfig{"telegram": {{Type: "log"}}}
+	if p, err := NewPipeline("discord", other); err != nil || p != nil {
+		t.Errorf("NewPipeline without steps = (%v, %v), want (nil, nil)", p, err)
+	}
+}
+
+func TestPipelineRejectsBadConfig(t *testing.T) {
+	for _, step := range []config.MiddlewareConfig{
+		{Type: "translate"},
+		{Type: "block"},
+		{Type: "replace", Pattern: "("},
+		{Type: "word_filter", Words: []string{" "}},
+		{Type: "log", Direction: "sideways"},
+	} {
+		if _, err := NewPipeline("slack", map[string][]config.MiddlewareConfig{"slack": {step}}); err == nil {
+			t.Errorf("NewPipeline(%+v) succeeded, want error", step)
+		}
+	}
+}
+
+func TestMiddlewareAppliesToChannelsAndManager(t *testing.T) {
+	pipeline, err := NewPipeline("telegram", map[string][]config.MiddlewareConfig{
+		"telegram": {{Type: "replace", Pattern: "secret", Replacement: "[redacted]", Direction: "both"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messageBus := bus.NewMessageBus()
+	ch := &recordingChannel{BaseChannel: NewBaseChannel("telegram", nil, messageBus, nil)}
+	ch.SetMiddleware(pipeline)
+	ch.setRunning(true)
+
+	ch.HandleMessage("u1", "c1", "my secret", nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg, ok := messageBus.ConsumeInbound(ctx); !ok || msg.Content != "my [redacted]" {
+		t.Errorf("inbound = %q (ok=%v)", msg.Content, ok)
+	}
+
+	m := newTestManager(map[string]Channel{"telegram": ch})
+	m.pipelines = map[string]*Pipeline{"telegram": pipeline}
+	if err := m.Notify(ctx, "telegram", "c1", "the secret"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(ch.sent) != 1 || ch.sent[0].Content != "the [redacted]" {
+		t.Errorf("sent = %+v", ch.sent)
+	}
+}
@@ -0,0 +1,217 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// MQTT channel implementation
+// Subscribes to a command topic and publishes replies, so Home Assistant
+// automations and ESP devices can talk to the agent through their broker
+
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	mqttPublishTimeout    = 10 * time.Second
+	mqttMinReconnectDelay = 5 * time.Second
+	mqttMaxReconnectDelay = 5 * time.Minute
+)
+
+// MQTTChannel implements the Channel interface for an MQTT broker. The chat
+// ID is the topic replies go to, so devices with their own reply_to topic
+// keep their own conversation.
+type MQTTChannel struct {
+	*BaseChannel
+	config config.MQTTConfig
+	client mqtt.Client
+}
+
+// mqttCommand is the JSON form of a command. Plain-text payloads are taken
+// as the message itself.
+type mqttCommand struct {
+	Message string `json:"message"`
+	From    string `json:"from"`
+	ReplyTo string `json:"reply_to"`
+}
+
+func NewMQTTChannel(cfg config.MQTTConfig, messageBus *bus.MessageBus) (*MQTTChannel, error) {
+	if cfg.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker is required")
+	}
+	if cfg.CommandTopic == "" {
+		return nil, fmt.Errorf("mqtt command_topic is required")
+	}
+	if cfg.ResponseTopic == "" {
+		return nil, fmt.Errorf("mqtt response_topic is required")
+	}
+	if !validMQTTReplyTopic(cfg.ResponseTopic) {
+		return nil, fmt.Errorf("mqtt response_topic %q must not contain wildcards", cfg.ResponseTopic)
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt qos must be 0, 1 or 2")
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "picoclaw"
+	}
+
+	base := NewBaseChannel("mqtt", cfg, messageBus, cfg.AllowFrom)
+
+	return &MQTTChannel{
+		BaseChannel: base,
+		config:      cfg,
+	}, nil
+}
+
+func (c *MQTTChannel) Start(ctx context.Context) error {
+	logger.InfoCF("mqtt", "Starting MQTT channel", map[string]any{
+		"broker": c.config.Broker,
+	})
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(c.config.Broker).
+		SetClientID(c.config.ClientID).
+		SetUsername(c.config.Username).
+		SetPassword(c.config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(mqttMinReconnectDelay).
+		SetMaxReconnectInterval(mqttMaxReconnectDelay).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.WarnCF("mqtt", "Connection lost, reconnecting", map[string]any{
+				"error": err.Error(),
+			})
+			c.recordError(fmt.Errorf("connection lost: %w", err))
+		})
+
+	// With retries on, Connect keeps trying in the background; don't wait
+	c.client = mqtt.NewClient(opts)
+	c.client.Connect()
+
+	c.setRunning(true)
+	return nil
+}
+
+func (c *MQTTChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mqtt", "Stopping MQTT channel")
+
+	if c.client != nil {
+		c.client.Disconnect(250)
+	}
+	c.setRunning(false)
+	return nil
+}
+
+// onConnect subscribes on every connection; the broker forgets
+// subscriptions of clean sessions when they drop.
+func (c *MQTTChannel) onConnect(client mqtt.Client) {
+	token := client.Subscribe(c.config.CommandTopic, byte(c.config.QoS), func(_ mqtt.Client, msg mqtt.Message) {
+		c.handleCommand(msg.Topic(), msg.Payload(), msg.Retained())
+	})
+	if !token.WaitTimeout(mqttPublishTimeout) || token.Error() != nil {
+		err := token.Error()
+		if err == nil {
+			err = fmt.Errorf("timed out")
+		}
+		logger.ErrorCF("mqtt", "Failed to subscribe", map[string]any{
+			"topic": c.config.CommandTopic,
+			"error": err.Error(),
+		})
+		c.recordError(fmt.Errorf("subscribe %s: %w", c.config.CommandTopic, err))
+		return
+	}
+
+	logger.InfoCF("mqtt", "Connected and subscribed", map[string]any{
+		"topic": c.config.CommandTopic,
+	})
+	c.recordSuccess()
+}
+
+func (c *MQTTChannel) handleCommand(topic string, payload []byte, retained bool) {
+	// A retained command would run again on every reconnect
+	if retained {
+		logger.DebugCF("mqtt", "Ignoring retained command", map[string]any{
+			"topic": topic,
+		})
+		return
+	}
+
+	var cmd mqttCommand
+	content := strings.TrimSpace(string(payload))
+	if !strings.HasPrefix(content, "{") {
+		cmd.Message = content
+	} else if err := json.Unmarshal(payload, &cmd); err != nil {
+		logger.WarnCF("mqtt", "Invalid JSON command", map[string]any{
+			"topic": topic,
+			"error": err.Error(),
+		})
+		return
+	}
+	cmd.Message = strings.TrimSpace(cmd.Message)
+	if cmd.Message == "" {
+		return
+	}
+
+	senderID := cmd.From
+	if senderID == "" {
+		senderID = topic
+	}
+	chatID := c.config.ResponseTopic
+	if cmd.ReplyTo != "" {
+		if !validMQTTReplyTopic(cmd.ReplyTo) {
+			logger.WarnCF("mqtt", "Invalid reply_to topic", map[string]any{
+				"topic":    topic,
+				"reply_to": cmd.ReplyTo,
+			})
+			return
+		}
+		chatID = cmd.ReplyTo
+	}
+
+	c.HandleMessage(senderID, chatID, cmd.Message, nil, map[string]string{
+		"peer_kind": "direct",
+		"peer_id":   chatID,
+		"username":  senderID,
+		"topic":     topic,
+	})
+}
+
+// Send publishes the reply as plain text to the chat's topic.
+func (c *MQTTChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("mqtt channel not running")
+	}
+	if !validMQTTReplyTopic(msg.ChatID) {
+		return fmt.Errorf("invalid mqtt topic %q", msg.ChatID)
+	}
+	if !c.client.IsConnectionOpen() {
+		return fmt.Errorf("mqtt broker not connected")
+	}
+
+	token := c.client.Publish(msg.ChatID, byte(c.config.QoS), false, msg.Content)
+	select {
+	case <-token.Done():
+	case <-time.After(mqttPublishTimeout):
+		return fmt.Errorf("publish to %s timed out", msg.ChatID)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("publish to %s: %w", msg.ChatID, err)
+	}
+	return nil
+}
+
+// validMQTTReplyTopic reports whether topic can be published to: not empty
+// and free of wildcards.
+func validMQTTReplyTopic(topic string) bool {
+	return topic != "" && !strings.ContainsAny(topic, "+#\x00")
+}
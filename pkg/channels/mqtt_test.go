@@ -0,0 +1,128 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeMQTTClient records publishes; other client methods are not used.
+type fakeMQTTClient struct {
+	mqtt.Client
+	published map[string][]string
+}
+
+func (f *fakeMQTTClient) IsConnectionOpen() bool { return true }
+
+func (f *fakeMQTTClient) Publish(topic string, _ byte, _ bool, payload any) mqtt.Token {
+	f.published[topic] = append(f.published[topic], payload.(string))
+	return &doneToken{}
+}
+
+type doneToken struct{}
+
+func (*doneToken) Wait() bool                     { return true }
+func (*doneToken) WaitTimeout(time.Duration) bool { return true }
+func (*doneToken) Error() error                   { return nil }
+func (*doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func TestMQTTChannelHandlesCommands(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewMQTTChannel(config.MQTTConfig{
+		Broker:        "tcp://127.0.0.1:1883",
+		CommandTopic:  "picoclaw/command/+",
+		ResponseTopic: "picoclaw/response",
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewMQTTChannel() error = %v", err)
+	}
+
+	ch.handleCommand("picoclaw/command/kitchen", []byte(" Turn on the lights \n"), false)
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.Content != "Turn on the lights" || msg.SenderID != "picoclaw/command/kitchen" ||
+		msg.ChatID != "picoclaw/response" {
+		t.Errorf("plain command = %+v", msg)
+	}
+
+	payload := `{"message":"Is the door locked?","from":"esp-hall","reply_to":"home/hall/answer"}`
+	ch.handleCommand("picoclaw/command/hall", []byte(payload), false)
+	msg, ok = expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.Content != "Is the door locked?" || msg.SenderID != "esp-hall" || msg.ChatID != "home/hall/answer" ||
+		msg.Metadata["topic"] != "picoclaw/command/hall" {
+		t.Errorf("JSON command = %+v", msg)
+	}
+
+	for _, bad := range []struct {
+		payload  string
+		retained bool
+	}{
+		{"old command", true},
+		{"   ", false},
+		{`{"message":`, false},
+		{`{"from":"esp-hall"}`, false},
+		{`{"message":"hi","reply_to":"home/+/answer"}`, false},
+	} {
+		ch.handleCommand("picoclaw/command/hall", []byte(bad.payload), bad.retained)
+		if msg, ok := expectInbound(t, msgBus); ok {
+			t.Errorf("command %q was delivered: %+v", bad.payload, msg)
+		}
+	}
+}
+
+func TestMQTTChannelSendPublishesReplies(t *testing.T) {
+	ch, err := NewMQTTChannel(config.MQTTConfig{
+		Broker:        "tcp://127.0.0.1:1883",
+		CommandTopic:  "picoclaw/command",
+		ResponseTopic: "picoclaw/response",
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewMQTTChannel() error = %v", err)
+	}
+	client := &fakeMQTTClient{published: make(map[string][]string)}
+	ch.client = client
+	ch.setRunning(true)
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "home/hall/answer", Content: "Yes, it is locked."})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := client.published["home/hall/answer"]; len(got) != 1 || got[0] != "Yes, it is locked." {
+		t.Errorf("published = %v", client.published)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "home/#", Content: "hi"}); err == nil {
+		t.Error("Send() to a wildcard topic succeeded, want an error")
+	}
+}
+
+func TestNewMQTTChannelValidatesConfig(t *testing.T) {
+	valid := config.MQTTConfig{Broker: "tcp://b:1883", CommandTopic: "c", ResponseTopic: "r"}
+	for _, mutate := range []func(*config.MQTTConfig){
+		func(c *config.MQTTConfig) { c.Broker = "" },
+		func(c *config.MQTTConfig) { c.CommandTopic = "" },
+		func(c *config.MQTTConfig) { c.ResponseTopic = "" },
+		func(c *config.MQTTConfig) { c.ResponseTopic = "picoclaw/+" },
+		func(c *config.MQTTConfig) { c.QoS = 3 },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := NewMQTTChannel(cfg, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewMQTTChannel(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
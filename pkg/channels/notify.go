@@ -0,0 +1,77 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Notifier is implemented by channels where a user ID is not a chat ID
+// messages can be sent to, such as Slack and Discord, which need a direct
+// message channel opened first. Other channels send to the user ID directly.
+type Notifier interface {
+	Notify(ctx context.Context, userID, content string) error
+}
+
+// Notify sends a message the agent initiates to a user on a channel and
+// returns once it was delivered. It is registered on the bus, so scheduled
+// tasks, heartbeats and tools reach it with MessageBus.Notify.
+//
+// Messages with no chat channel to go to, such as a heartbeat before anyone
+// has talked to the agent, are sent to the push channel when it is enabled.
+func (m *Manager) Notify(ctx context.Context, channelName, recipient, content string) error {
+	if channelName == "" || constants.IsInternalChannel(channelName) {
+		if _, ok := m.GetChannel(pushChannelName); !ok {
+			if channelName == "" {
+				return fmt.Errorf("no channel to deliver to")
+			}
+			return fmt.Errorf("channel %s can't receive messages", channelName)
+		}
+		channelName, recipient = pushChannelName, pushDefaultRecipient
+	}
+	if recipient == "" {
+		return fmt.Errorf("no recipient for channel %s", channelName)
+	}
+
+	m.mu.RLock()
+	channel, ok := m.channels[channelName]
+	pipeline := m.pipelines[channelName]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("channel %s is not enabled", channelName)
+	}
+	if !channel.IsRunning() {
+		return fmt.Errorf("channel %s is not running", channelName)
+	}
+
+	msg, allowed := pipeline.Outbound(bus.OutboundMessage{Channel: channelName, ChatID: recipient, Content: content})
+	if !allowed {
+		return fmt.Errorf("message to %s on %s was dropped by middleware", recipient, channelName)
+	}
+	content = msg.Content
+
+	for _, chunk := range splitContent(channelName, content) {
+		err := safeChannelCall(func() error {
+			if n, ok := channel.(Notifier); ok {
+				return n.Notify(ctx, recipient, chunk)
+			}
+			return channel.Send(ctx, bus.OutboundMessage{
+				Channel: channelName,
+				ChatID:  recipient,
+				Content: chunk,
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to notify %s on %s: %w", recipient, channelName, err)
+		}
+	}
+
+	logger.DebugCF("channels", "Sent proactive message", map[string]any{
+		"channel":   channelName,
+		"recipient": recipient,
+	})
+	return nil
+}
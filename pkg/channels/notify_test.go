@@ -0,0 +1,67 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// recordingChannel records sent messages.
+type recordingChannel struct {
+	*BaseChannel
+	sent []bus.OutboundMessage
+}
+
+func (c *recordingChannel) Start(context.Context) error { c.setRunning(true); return nil }
+func (c *recordingChannel) Stop(context.Context) error  { c.setRunning(false); return nil }
+
+func (c *recordingChannel) Send(_ context.Context, msg bus.OutboundMessage) error {
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+// dmChannel opens a DM chat for the user, like Slack and Discord.
+type dmChannel struct{ recordingChannel }
+
+func (c *dmChannel) Notify(ctx context.Context, userID, content string) error {
+	return c.Send(ctx, bus.OutboundMessage{Channel: c.Name(), ChatID: "dm-" + userID, Content: content})
+}
+
+func TestManagerNotify(t *testing.T) {
+	plain := &recordingChannel{BaseChannel: NewBaseChannel("telegram", nil, nil, nil)}
+	dm := &dmChannel{recordingChannel{BaseChannel: NewBaseChannel("slack", nil, nil, nil)}}
+	stopped := &recordingChannel{BaseChannel: NewBaseChannel("line", nil, nil, nil)}
+	m := newTestManager(map[string]Channel{"telegram": plain, "slack": dm, "line": stopped})
+	m.bus.SetNotifier(m.Notify)
+	plain.setRunning(true)
+	dm.setRunning(true)
+
+	ctx := context.Background()
+	if err := m.bus.Notify(ctx, "telegram", "42", "reminder"); err != nil {
+		t.Fatalf("Notify(telegram) error = %v", err)
+	}
+	if len(plain.sent) != 1 || plain.sent[0].ChatID != "42" || plain.sent[0].Content != "reminder" {
+		t.Errorf("telegram sent %+v", plain.sent)
+	}
+
+	if err := m.bus.Notify(ctx, "slack", "U123", "hi"); err != nil {
+		t.Fatalf("Notify(slack) error = %v", err)
+	}
+	if len(dm.sent) != 1 || dm.sent[0].ChatID != "dm-U123" {
+		t.Errorf("slack sent %+v", dm.sent)
+	}
+
+	for _, tc := range []struct{ channel, recipient, want string }{
+		{"line", "u1", "not running"},
+		{"discord", "u1", "not enabled"},
+		{"cli", "direct", "can't receive"},
+		{"telegram", "", "no recipient"},
+	} {
+		err := m.Notify(ctx, tc.channel, tc.recipient, "x")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Notify(%s, %q) error = %v, want %q", tc.channel, tc.recipient, err, tc.want)
+		}
+	}
+}
@@ -35,7 +35,7 @@ type OneBotChannel struct {
 	selfID          int64
 	pending         map[string]chan json.RawMessage
 	pendingMu       sync.Mutex
-	transcriber     *voice.GroqTranscriber
+	transcriber     voice.Transcriber
 	lastMessageID   sync.Map
 	pendingEmojiMsg sync.Map
 }
@@ -111,7 +111,7 @@ func NewOneBotChannel(cfg config.OneBotConfig, messageBus *bus.MessageBus) (*One
 	}, nil
 }
 
-func (c *OneBotChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *OneBotChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
@@ -314,32 +314,43 @@ func (c *OneBotChannel) sendAPIRequest(action string, params any, timeout time.D
 	}
 }
 
+// reconnectLoop redials a dropped connection, starting at reconnect_interval
+// and doubling the wait after each failed attempt up to restartBackoffMax.
 func (c *OneBotChannel) reconnectLoop() {
 	interval := time.Duration(c.config.ReconnectInterval) * time.Second
 	if interval < 5*time.Second {
 		interval = 5 * time.Second
 	}
+	delay := interval
 
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
-		case <-time.After(interval):
+		case <-time.After(delay):
 			c.mu.Lock()
 			conn := c.conn
 			c.mu.Unlock()
 
-			if conn == nil {
-				logger.InfoC("onebot", "Attempting to reconnect...")
-				if err := c.connect(); err != nil {
-					logger.ErrorCF("onebot", "Reconnect failed", map[string]any{
-						"error": err.Error(),
-					})
-				} else {
-					go c.listen()
-					c.fetchSelfID()
-				}
+			if conn != nil {
+				delay = interval
+				continue
 			}
+
+			logger.InfoC("onebot", "Attempting to reconnect...")
+			if err := c.connect(); err != nil {
+				delay = min(delay*2, restartBackoffMax)
+				logger.ErrorCF("onebot", "Reconnect failed", map[string]any{
+					"error":       err.Error(),
+					"retry_after": delay.String(),
+				})
+				c.recordError(err)
+				continue
+			}
+			delay = interval
+			c.recordSuccess()
+			go c.listen()
+			c.fetchSelfID()
 		}
 	}
 }
@@ -482,11 +493,19 @@ func (c *OneBotChannel) listen() {
 					"error": err.Error(),
 				})
 				c.mu.Lock()
-				if c.conn == conn {
+				dropped := c.conn == conn
+				if dropped {
 					c.conn.Close()
 					c.conn = nil
 				}
 				c.mu.Unlock()
+				if dropped && c.ctx.Err() == nil {
+					c.recordError(fmt.Errorf("connection lost: %w", err))
+					if c.config.ReconnectInterval <= 0 {
+						// No reconnect loop of our own; let the manager restart us
+						c.setRunning(false)
+					}
+				}
 				return
 			}
 
@@ -888,7 +907,7 @@ func (c *OneBotChannel) handleMessage(raw *oneBotRawEvent) {
 		}
 
 		triggered, strippedContent := c.checkGroupTrigger(content, isBotMentioned)
-		if !triggered {
+		if !c.acceptGroupMessage(chatID, senderID, content, triggered) {
 			logger.DebugCF("onebot", "Group message ignored (no trigger)", map[string]any{
 				"sender":       senderID,
 				"group":        groupIDStr,
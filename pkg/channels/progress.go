@@ -0,0 +1,96 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Editable progress placeholders
+// Channels that can edit sent messages show a single "working on it" message
+// while the agent runs, update it with the tools being executed, and replace
+// it with the final answer
+
+package channels
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	progressPlaceholderText = "Working on it ⏳"
+	// Platforms rate limit message edits; one edit per interval per chat
+	// keeps long tool runs well under their limits.
+	progressEditInterval = 2 * time.Second
+	// Partial model output can be long; the placeholder only needs a glimpse.
+	maxProgressTextLen = 1000
+)
+
+// progressPlaceholders tracks the placeholder message of each chat waiting
+// on a reply.
+type progressPlaceholders struct {
+	mu       sync.Mutex
+	interval time.Duration
+	chats    map[string]*progressPlaceholder
+	now      func() time.Time
+}
+
+type progressPlaceholder struct {
+	messageID string
+	content   string
+	edited    time.Time
+}
+
+func newProgressPlaceholders() *progressPlaceholders {
+	return &progressPlaceholders{
+		interval: progressEditInterval,
+		chats:    make(map[string]*progressPlaceholder),
+		now:      time.Now,
+	}
+}
+
+// set records messageID, showing content, as chatID's placeholder.
+func (p *progressPlaceholders) set(chatID, messageID, content string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chats[chatID] = &progressPlaceholder{messageID: messageID, content: content, edited: p.now()}
+}
+
+// update reports whether chatID's placeholder should now show content. An
+// empty messageID means the chat has no placeholder yet and the caller should
+// send one and set it. Repeated content and edits within the interval are
+// skipped.
+func (p *progressPlaceholders) update(chatID, content string) (messageID string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, exists := p.chats[chatID]
+	if !exists {
+		p.chats[chatID] = &progressPlaceholder{content: content, edited: p.now()}
+		return "", true
+	}
+	if state.messageID == "" || content == state.content || p.now().Sub(state.edited) < p.interval {
+		return "", false
+	}
+	state.content = content
+	state.edited = p.now()
+	return state.messageID, true
+}
+
+// take removes chatID's placeholder so the final answer can replace it.
+func (p *progressPlaceholders) take(chatID string) (messageID string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, exists := p.chats[chatID]
+	delete(p.chats, chatID)
+	if !exists || state.messageID == "" {
+		return "", false
+	}
+	return state.messageID, true
+}
+
+// progressText formats an interim update for a placeholder.
+func progressText(content string) string {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return progressPlaceholderText
+	}
+	return "⏳ " + utils.Truncate(content, maxProgressTextLen)
+}
@@ -0,0 +1,49 @@
+package channels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressPlaceholders(t *testing.T) {
+	p := newProgressPlaceholders()
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+
+	// The first update asks the caller to post the placeholder
+	if id, ok := p.update("chat", "⏳ Working: exec"); !ok || id != "" {
+		t.Fatalf("first update = (%q, %v), want (\"\", true)", id, ok)
+	}
+	// Updates are skipped until the placeholder exists
+	if _, ok := p.update("chat", "⏳ Working: read_file"); ok {
+		t.Error("update before set should be skipped")
+	}
+	p.set("chat", "m1", "⏳ Working: exec")
+
+	if _, ok := p.update("chat", "⏳ Working: read_file"); ok {
+		t.Error("update within the edit interval should be skipped")
+	}
+	now = now.Add(progressEditInterval)
+	if _, ok := p.update("chat", "⏳ Working: exec"); ok {
+		t.Error("unchanged content should be skipped")
+	}
+	if id, ok := p.update("chat", "⏳ Working: read_file"); !ok || id != "m1" {
+		t.Errorf("update = (%q, %v), want (m1, true)", id, ok)
+	}
+
+	if id, ok := p.take("chat"); !ok || id != "m1" {
+		t.Errorf("take = (%q, %v), want (m1, true)", id, ok)
+	}
+	if _, ok := p.take("chat"); ok {
+		t.Error("placeholder should be gone after take")
+	}
+}
+
+func TestProgressText(t *testing.T) {
+	if got := progressText("  "); got != progressPlaceholderText {
+		t.Errorf("progressText(blank) = %q", got)
+	}
+	if got := progressText("Working: exec"); got != "⏳ Working: exec" {
+		t.Errorf("progressText = %q", got)
+	}
+}
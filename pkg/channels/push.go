@@ -0,0 +1,180 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Push notification channel implementation
+// Sends heartbeat and scheduled task results to a phone through ntfy or
+// Pushover. It is send-only: nobody can talk to the agent through it
+
+package channels
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	pushChannelName = "push"
+
+	// pushDefaultRecipient addresses the topic or user key from the config.
+	pushDefaultRecipient = "default"
+
+	pushAPITimeout = 30 * time.Second
+
+	ntfyMessageLimit     = 4096
+	pushoverMessageLimit = 1024
+)
+
+// pushoverMessagesURL is a variable so tests can point it at a local server.
+var pushoverMessagesURL = "https://api.pushover.net/1/messages.json"
+
+// PushChannel delivers agent-initiated messages as phone notifications. The
+// channel manager falls back to it when a heartbeat or scheduled task has no
+// chat channel to answer in.
+type PushChannel struct {
+	*BaseChannel
+	config config.PushConfig
+	client *http.Client
+}
+
+func NewPushChannel(cfg config.PushConfig, messageBus *bus.MessageBus) (*PushChannel, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = "ntfy"
+	}
+	switch cfg.Provider {
+	case "ntfy":
+		if cfg.Topic == "" {
+			return nil, fmt.Errorf("push topic is required for ntfy")
+		}
+		if cfg.Server == "" {
+			cfg.Server = "https://ntfy.sh"
+		}
+		cfg.Server = strings.TrimRight(cfg.Server, "/")
+	case "pushover":
+		if cfg.Token == "" || cfg.User == "" {
+			return nil, fmt.Errorf("push token and user are required for pushover")
+		}
+	default:
+		return nil, fmt.Errorf("invalid push provider %q: use ntfy or pushover", cfg.Provider)
+	}
+	if cfg.Title == "" {
+		cfg.Title = "PicoClaw"
+	}
+
+	// Nobody sends to this channel, so there is nothing to allow
+	base := NewBaseChannel(pushChannelName, cfg, messageBus, nil)
+
+	return &PushChannel{
+		BaseChannel: base,
+		config:      cfg,
+		client:      &http.Client{Timeout: pushAPITimeout},
+	}, nil
+}
+
+func (c *PushChannel) Start(ctx context.Context) error {
+	c.setRunning(true)
+	logger.InfoCF("push", "Push channel started", map[string]any{
+		"provider": c.config.Provider,
+	})
+	return nil
+}
+
+func (c *PushChannel) Stop(ctx context.Context) error {
+	c.setRunning(false)
+	logger.InfoC("push", "Push channel stopped")
+	return nil
+}
+
+// Send pushes the message as one or more notifications. The chat ID is the
+// ntfy topic or Pushover user key; empty or "default" uses the configured one.
+func (c *PushChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("push channel not running")
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	limit := ntfyMessageLimit
+	if c.config.Provider == "pushover" {
+		limit = pushoverMessageLimit
+	}
+	chunks := []string{msg.Content}
+	if len(msg.Content) > limit {
+		chunks = utils.SplitMessage(msg.Content, limit)
+	}
+
+	for _, chunk := range chunks {
+		var err error
+		if c.config.Provider == "pushover" {
+			err = c.sendPushover(ctx, msg.ChatID, chunk)
+		} else {
+			err = c.sendNtfy(ctx, msg.ChatID, chunk)
+		}
+		if err != nil {
+			logger.ErrorCF("push", "Failed to send notification", map[string]any{
+				"provider": c.config.Provider,
+				"error":    err.Error(),
+			})
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PushChannel) sendNtfy(ctx context.Context, topic, content string) error {
+	if topic == "" || topic == pushDefaultRecipient {
+		topic = c.config.Topic
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.config.Server+"/"+url.PathEscape(topic), strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", c.config.Title)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	return c.do(req)
+}
+
+func (c *PushChannel) sendPushover(ctx context.Context, user, content string) error {
+	if user == "" || user == pushDefaultRecipient {
+		user = c.config.User
+	}
+	form := url.Values{
+		"token":   {c.config.Token},
+		"user":    {user},
+		"title":   {c.config.Title},
+		"message": {content},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushoverMessagesURL,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req)
+}
+
+func (c *PushChannel) do(req *http.Request) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s: %s", c.config.Provider, resp.Status, utils.Truncate(string(data), 200))
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package channels
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// pushRequest is what a fake push server received.
+type pushRequest struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func newFakePushServer(t *testing.T) (*httptest.Server, func() []pushRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []pushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, pushRequest{path: r.URL.Path, header: r.Header, body: string(body)})
+		mu.Unlock()
+		w.Write([]byte(`{"status":1}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []pushRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]pushRequest(nil), requests...)
+	}
+}
+
+func TestPushChannelSendsToNtfy(t *testing.T) {
+	server, requests := newFakePushServer(t)
+	ch, err := NewPushChannel(config.PushConfig{
+		Server: server.URL + "/",
+		Topic:  "claw-alerts",
+		Token:  "tk_secret",
+		Title:  "Heartbeat",
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewPushChannel() error = %v", err)
+	}
+	ch.Start(context.Background())
+
+	long := strings.Repeat("all systems normal ", 300)
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: pushDefaultRecipient, Content: long})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := requests()
+	if len(got) < 2 {
+		t.Fatalf("got %d requests, want the long message split", len(got))
+	}
+	for i, req := range got {
+		if req.path != "/claw-alerts" {
+			t.Errorf("request %d path = %q", i, req.path)
+		}
+		if req.header.Get("Title") != "Heartbeat" || req.header.Get("Authorization") != "Bearer tk_secret" {
+			t.Errorf("request %d headers = %v", i, req.header)
+		}
+		if len(req.body) > ntfyMessageLimit {
+			t.Errorf("request %d body has %d bytes", i, len(req.body))
+		}
+	}
+}
+
+func TestPushChannelSendsToPushover(t *testing.T) {
+	server, requests := newFakePushServer(t)
+	old := pushoverMessagesURL
+	pushoverMessagesURL = server.URL + "/1/messages.json"
+	defer func() { pushoverMessagesURL = old }()
+
+	ch, err := NewPushChannel(config.PushConfig{
+		Provider: "pushover",
+		Token:    "app-token",
+		User:     "user-key",
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewPushChannel() error = %v", err)
+	}
+	ch.Start(context.Background())
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{Content: "backup finished"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("got %d requests", len(got))
+	}
+	form, _ := url.ParseQuery(got[0].body)
+	if form.Get("token") != "app-token" || form.Get("user") != "user-key" ||
+		form.Get("message") != "backup finished" || form.Get("title") != "PicoClaw" {
+		t.Errorf("form = %v", form)
+	}
+}
+
+func TestNewPushChannelValidatesConfig(t *testing.T) {
+	for _, cfg := range []config.PushConfig{
+		{Provider: "ntfy"},
+		{Provider: "pushover", Token: "app-token"},
+		{Provider: "gotify", Topic: "x"},
+	} {
+		if _, err := NewPushChannel(cfg, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewPushChannel(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestManagerNotifyFallsBackToPush(t *testing.T) {
+	push := &recordingChannel{BaseChannel: NewBaseChannel(pushChannelName, nil, nil, nil)}
+	m := newTestManager(map[string]Channel{pushChannelName: push})
+	push.setRunning(true)
+
+	ctx := context.Background()
+	if err := m.Notify(ctx, "cli", "direct", "disk is 90% full"); err != nil {
+		t.Fatalf("Notify(cli) error = %v", err)
+	}
+	if err := m.Notify(ctx, "", "", "backup done"); err != nil {
+		t.Fatalf("Notify(\"\") error = %v", err)
+	}
+	if len(push.sent) != 2 {
+		t.Fatalf("push sent %+v", push.sent)
+	}
+	for _, msg := range push.sent {
+		if msg.Channel != pushChannelName || msg.ChatID != pushDefaultRecipient {
+			t.Errorf("sent %+v, want the default push recipient", msg)
+		}
+	}
+}
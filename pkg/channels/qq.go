@@ -90,6 +90,7 @@ func (c *QQChannel) Start(ctx context.Context) error {
 			logger.ErrorCF("qq", "WebSocket session error", map[string]any{
 				"error": err.Error(),
 			})
+			c.recordError(err)
 			c.setRunning(false)
 		}
 	}()
@@ -0,0 +1,32 @@
+package channels
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// maxQuoteLen bounds how much of a replied-to message goes into the prompt.
+const maxQuoteLen = 1000
+
+// withQuote puts the message a user replied to above their own, so requests
+// like "translate this" come with their subject. author names who wrote the
+// quoted message; empty means the bot itself.
+func withQuote(content, author, quoted string) string {
+	quoted = strings.TrimSpace(quoted)
+	if quoted == "" {
+		return content
+	}
+
+	var sb strings.Builder
+	if author == "" {
+		sb.WriteString("[Replying to your earlier message]\n")
+	} else {
+		sb.WriteString("[Replying to a message from " + author + "]\n")
+	}
+	for _, line := range strings.Split(utils.Truncate(quoted, maxQuoteLen), "\n") {
+		sb.WriteString("> " + line + "\n")
+	}
+	sb.WriteString("\n" + content)
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/telego"
+)
+
+func TestWithQuote(t *testing.T) {
+	tests := []struct {
+		name, author, quoted, want string
+	}{
+		{
+			name:   "user message",
+			author: "alice",
+			quoted: "Bonjour à tous\nÇa va ?",
+			want:   "[Replying to a message from alice]\n> Bonjour à tous\n> Ça va ?\n\ntranslate this",
+		},
+		{
+			name:   "bot message",
+			quoted: "The build passed.",
+			want:   "[Replying to your earlier message]\n> The build passed.\n\ntranslate this",
+		},
+		{
+			name:   "nothing quoted",
+			author: "alice",
+			quoted: "  ",
+			want:   "translate this",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withQuote("translate this", tt.author, tt.quoted); got != tt.want {
+				t.Errorf("withQuote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	long := withQuote("summarize", "bob", strings.Repeat("x", 5000))
+	if len(long) > maxQuoteLen+100 {
+		t.Errorf("long quote not truncated: %d bytes", len(long))
+	}
+}
+
+func TestTelegramQuote(t *testing.T) {
+	const botID = 99
+	alice := &telego.User{ID: 1, FirstName: "Alice"}
+	bot := &telego.User{ID: botID, IsBot: true, Username: "claw_bot"}
+
+	tests := []struct {
+		name       string
+		message    telego.Message
+		wantAuthor string
+		wantText   string
+	}{
+		{
+			name:    "no reply",
+			message: telego.Message{Text: "hi"},
+		},
+		{
+			name: "reply to a forwarded caption",
+			message: telego.Message{ReplyToMessage: &telego.Message{
+				From: alice, Caption: "Hola, ¿qué tal?", Photo: []telego.PhotoSize{{FileID: "p"}},
+			}},
+			wantAuthor: "Alice",
+			wantText:   "Hola, ¿qué tal?",
+		},
+		{
+			name: "highlighted part of the bot's answer",
+			message: telego.Message{
+				ReplyToMessage: &telego.Message{From: bot, Text: "Step 1: install. Step 2: run."},
+				Quote:          &telego.TextQuote{Text: "Step 2: run."},
+			},
+			wantText: "Step 2: run.",
+		},
+		{
+			name: "forum topic message",
+			message: telego.Message{IsTopicMessage: true, ReplyToMessage: &telego.Message{
+				From: alice, Text: "General", ForumTopicCreated: &telego.ForumTopicCreated{Name: "General"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			author, text := telegramQuote(&tt.message, botID)
+			if text != tt.wantText || (text != "" && author != tt.wantAuthor) {
+				t.Errorf("telegramQuote() = %q, %q; want %q, %q", author, text, tt.wantAuthor, tt.wantText)
+			}
+		})
+	}
+}
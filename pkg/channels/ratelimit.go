@@ -0,0 +1,166 @@
+package channels
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// rateLimitPruneInterval controls how often idle buckets are dropped.
+const rateLimitPruneInterval = 10 * time.Minute
+
+// tokenBucket refills at rate tokens per second up to burst.
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	notified bool // cooldown notice already sent while limited
+}
+
+// RateLimiter limits inbound messages per sender and per chat with token
+// buckets, so a single user or a busy group chat can't flood the agent.
+type RateLimiter struct {
+	userRate  float64 // tokens per second, 0 = unlimited
+	chatRate  float64
+	userBurst float64
+	chatBurst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter returns a limiter for cfg, or nil when no limit is set.
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	if cfg.UserPerMinute <= 0 && cfg.ChatPerMinute <= 0 {
+		return nil
+	}
+
+	burst := func(perMinute int) float64 {
+		if cfg.Burst > 0 {
+			return float64(cfg.Burst)
+		}
+		return float64(perMinute)
+	}
+
+	return &RateLimiter{
+		userRate:  float64(cfg.UserPerMinute) / 60,
+		chatRate:  float64(cfg.ChatPerMinute) / 60,
+		userBurst: burst(cfg.UserPerMinute),
+		chatBurst: burst(cfg.ChatPerMinute),
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the sender's and the chat's bucket. When the
+// message is refused, notify is true the first time a bucket runs dry, so the
+// caller sends one cooldown notice rather than one per dropped message.
+func (r *RateLimiter) Allow(senderID, chatID string) (allowed, notify bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Sub(r.lastPrune) > rateLimitPruneInterval {
+		r.prune(now)
+	}
+
+	var limited []*tokenBucket
+	var user, chat *tokenBucket
+	if r.userRate > 0 {
+		user = r.bucket("user:"+senderID, r.userRate, r.userBurst, now)
+		if user.tokens < 1 {
+			limited = append(limited, user)
+		}
+	}
+	if r.chatRate > 0 && chatID != "" {
+		chat = r.bucket("chat:"+chatID, r.chatRate, r.chatBurst, now)
+		if chat.tokens < 1 {
+			limited = append(limited, chat)
+		}
+	}
+
+	if len(limited) > 0 {
+		for _, b := range limited {
+			if !b.notified {
+				b.notified = true
+				notify = true
+			}
+		}
+		return false, notify
+	}
+
+	for _, b := range []*tokenBucket{user, chat} {
+		if b != nil {
+			b.tokens--
+			b.notified = false
+		}
+	}
+	return true, false
+}
+
+// bucket returns the refilled bucket for key; callers hold mu.
+func (r *RateLimiter) bucket(key string, rate, burst float64, now time.Time) *tokenBucket {
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		r.buckets[key] = b
+		return b
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	return b
+}
+
+// prune drops buckets that have been idle long enough to be full again.
+func (r *RateLimiter) prune(now time.Time) {
+	for key, b := range r.buckets {
+		if now.Sub(b.last) > rateLimitPruneInterval {
+			delete(r.buckets, key)
+		}
+	}
+	r.lastPrune = now
+}
+
+// RateLimited is implemented by channels embedding BaseChannel.
+type RateLimited interface {
+	SetRateLimit(limiter *RateLimiter, cooldownMessage string)
+}
+
+// SetRateLimit attaches an inbound rate limiter and the notice sent when a
+// sender or chat hits it (empty to drop messages silently).
+func (c *BaseChannel) SetRateLimit(limiter *RateLimiter, cooldownMessage string) {
+	c.limiter = limiter
+	c.cooldownMessage = cooldownMessage
+}
+
+// allowRate applies the rate limit to an inbound message, sending the
+// cooldown notice when a limit is first hit.
+func (c *BaseChannel) allowRate(senderID, chatID string) bool {
+	if c.limiter == nil {
+		return true
+	}
+
+	allowed, notify := c.limiter.Allow(senderID, chatID)
+	if allowed {
+		return true
+	}
+
+	logger.DebugCF("channels", "Message dropped by rate limit", map[string]any{
+		"channel":   c.name,
+		"sender_id": senderID,
+		"chat_id":   chatID,
+	})
+
+	if notify && c.cooldownMessage != "" && chatID != "" && c.bus != nil {
+		c.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: c.name,
+			ChatID:  chatID,
+			Content: c.cooldownMessage,
+		})
+	}
+	return false
+}
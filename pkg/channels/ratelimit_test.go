@@ -0,0 +1,78 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRateLimiterDisabledByDefault(t *testing.T) {
+	if NewRateLimiter(config.RateLimitConfig{}) != nil {
+		t.Error("expected no limiter when no limit is configured")
+	}
+}
+
+func TestRateLimiterUserAndChatLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewRateLimiter(config.RateLimitConfig{UserPerMinute: 2, ChatPerMinute: 3})
+	r.now = func() time.Time { return now }
+
+	for i := range 2 {
+		if ok, _ := r.Allow("alice", "group"); !ok {
+			t.Fatalf("message %d should be allowed", i+1)
+		}
+	}
+
+	ok, notify := r.Allow("alice", "group")
+	if ok || !notify {
+		t.Fatalf("third message from alice: allowed=%v notify=%v, want false true", ok, notify)
+	}
+	if ok, notify := r.Allow("alice", "group"); ok || notify {
+		t.Errorf("cooldown notice should only be sent once, got allowed=%v notify=%v", ok, notify)
+	}
+
+	// The chat still has one token left for another member
+	if ok, _ := r.Allow("bob", "group"); !ok {
+		t.Error("bob should be allowed")
+	}
+	if ok, _ := r.Allow("carol", "group"); ok {
+		t.Error("chat limit should stop carol")
+	}
+
+	// Tokens refill over time
+	now = now.Add(30 * time.Second)
+	if ok, _ := r.Allow("alice", "group"); !ok {
+		t.Error("alice should be allowed after refill")
+	}
+}
+
+func TestBaseChannelRateLimitNotice(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, msgBus, nil)
+	ch.SetRateLimit(NewRateLimiter(config.RateLimitConfig{UserPerMinute: 1}), "Slow down")
+
+	ch.HandleMessage("1", "chat1", "first", nil, nil)
+	ch.HandleMessage("1", "chat1", "second", nil, nil)
+	ch.HandleMessage("1", "chat1", "third", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if msg, ok := msgBus.ConsumeInbound(ctx); !ok || msg.Content != "first" {
+		t.Fatalf("expected first message to reach the agent, got %+v", msg)
+	}
+	if msg, ok := msgBus.ConsumeInbound(ctx); ok {
+		t.Errorf("rate limited message reached the agent: %+v", msg)
+	}
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer outCancel()
+	if out, ok := msgBus.SubscribeOutbound(outCtx); !ok || out.Content != "Slow down" {
+		t.Fatalf("expected cooldown notice, got %+v", out)
+	}
+	if out, ok := msgBus.SubscribeOutbound(outCtx); ok {
+		t.Errorf("expected a single cooldown notice, got %+v", out)
+	}
+}
@@ -0,0 +1,245 @@
+package channels
+
+import (
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// channelSpec describes how the manager builds one channel from config.
+type channelSpec struct {
+	name    string // routing key, matches OutboundMessage.Channel
+	display string // name used in logs
+	enabled func(cfg *config.ChannelsConfig) bool
+	create  func(cfg *config.Config, messageBus *bus.MessageBus) (Channel, error)
+	// maxMessageLen is the longest reply, in bytes, the platform accepts in
+	// one message; longer replies are split. 0 means no limit.
+	maxMessageLen int
+}
+
+// channelSpecs lists every built-in channel. Adding a channel means adding
+// an entry here; the manager handles construction, startup and shutdown.
+var channelSpecs = []channelSpec{
+	{
+		name:          "telegram",
+		display:       "Telegram",
+		maxMessageLen: 4096,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.Telegram.Enabled && c.Telegram.Token != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewTelegramChannel(cfg, b)
+		},
+	},
+	{
+		name:          "whatsapp",
+		display:       "WhatsApp",
+		maxMessageLen: 65536,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.WhatsApp.Enabled && c.WhatsApp.BridgeURL != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWhatsAppChannel(cfg.Channels.WhatsApp, b)
+		},
+	},
+	{
+		name:          "feishu",
+		display:       "Feishu",
+		maxMessageLen: 30000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.Feishu.Enabled },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewFeishuChannel(cfg.Channels.Feishu, b)
+		},
+	},
+	{
+		name:          "discord",
+		display:       "Discord",
+		maxMessageLen: 2000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.Discord.Enabled && c.Discord.Token != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewDiscordChannel(cfg.Channels.Discord, b)
+		},
+	},
+	{
+		name:    "maixcam",
+		display: "MaixCam",
+		enabled: func(c *config.ChannelsConfig) bool { return c.MaixCam.Enabled },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewMaixCamChannel(cfg.Channels.MaixCam, b)
+		},
+	},
+	{
+		name:          "qq",
+		display:       "QQ",
+		maxMessageLen: 2000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.QQ.Enabled },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewQQChannel(cfg.Channels.QQ, b)
+		},
+	},
+	{
+		name:          "dingtalk",
+		display:       "DingTalk",
+		maxMessageLen: 20000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.DingTalk.Enabled && c.DingTalk.ClientID != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewDingTalkChannel(cfg.Channels.DingTalk, b)
+		},
+	},
+	{
+		name:          "slack",
+		display:       "Slack",
+		maxMessageLen: 4000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.Slack.Enabled && c.Slack.BotToken != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewSlackChannel(cfg.Channels.Slack, b)
+		},
+	},
+	{
+		name:          "line",
+		display:       "LINE",
+		maxMessageLen: 5000,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.LINE.Enabled && c.LINE.ChannelAccessToken != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewLINEChannel(cfg.Channels.LINE, b)
+		},
+	},
+	{
+		name:          "onebot",
+		display:       "OneBot",
+		maxMessageLen: 4500,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.OneBot.Enabled && c.OneBot.WSUrl != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewOneBotChannel(cfg.Channels.OneBot, b)
+		},
+	},
+	{
+		name:          "wecom",
+		display:       "WeCom",
+		maxMessageLen: 4096,
+		enabled: func(c *config.ChannelsConfig) bool {
+			// An invalid block lands here too, so its error is reported
+			mode, _ := c.WeCom.ResolveMode()
+			return c.WeCom.Enabled && mode != config.WeComModeApp
+		},
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			if _, err := cfg.Channels.WeCom.ResolveMode(); err != nil {
+				return nil, err
+			}
+			return NewWeComBotChannel(cfg.Channels.WeCom, b)
+		},
+	},
+	{
+		name:          "wecom_app",
+		display:       "WeCom App",
+		maxMessageLen: 2048,
+		enabled: func(c *config.ChannelsConfig) bool {
+			mode, _ := c.WeCom.ResolveMode()
+			return (c.WeCom.Enabled && mode == config.WeComModeApp) || (c.WeComApp.Enabled && c.WeComApp.CorpID != "")
+		},
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			appCfg, err := weComAppSettings(&cfg.Channels)
+			if err != nil {
+				return nil, err
+			}
+			return NewWeComAppChannel(appCfg, b)
+		},
+	},
+	{
+		name:    "xmpp",
+		display: "XMPP",
+		enabled: func(c *config.ChannelsConfig) bool { return c.XMPP.Enabled && c.XMPP.JID != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewXMPPChannel(cfg.Channels.XMPP, b)
+		},
+	},
+	{
+		// Splits and threads long replies itself, see MastodonChannel.Send
+		name:    "mastodon",
+		display: "Mastodon",
+		enabled: func(c *config.ChannelsConfig) bool { return c.Mastodon.Enabled && c.Mastodon.AccessToken != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewMastodonChannel(cfg.Channels.Mastodon, b)
+		},
+	},
+	{
+		// Splits and threads long replies itself, see BlueskyChannel.Send
+		name:    "bluesky",
+		display: "Bluesky",
+		enabled: func(c *config.ChannelsConfig) bool { return c.Bluesky.Enabled && c.Bluesky.AppPassword != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewBlueskyChannel(cfg.Channels.Bluesky, b)
+		},
+	},
+	{
+		name:          "github",
+		display:       "GitHub",
+		maxMessageLen: 65536,
+		enabled:       func(c *config.ChannelsConfig) bool { return c.GitHub.Enabled && c.GitHub.Token != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewGitHubChannel(cfg.Channels.GitHub, b)
+		},
+	},
+	{
+		// Replies go back whole in the HTTP response
+		name:    "api",
+		display: "HTTP API",
+		enabled: func(c *config.ChannelsConfig) bool { return c.API.Enabled && len(c.API.Tokens) > 0 },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewAPIChannel(cfg.Channels.API, b)
+		},
+	},
+	{
+		// Replies go back whole on the stream
+		name:    "grpc",
+		display: "gRPC",
+		enabled: func(c *config.ChannelsConfig) bool { return c.GRPC.Enabled && len(c.GRPC.Tokens) > 0 },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewGRPCChannel(cfg.Channels.GRPC, b)
+		},
+	},
+	{
+		// Replies are published whole; brokers accept payloads up to 256 MB
+		name:    "mqtt",
+		display: "MQTT",
+		enabled: func(c *config.ChannelsConfig) bool { return c.MQTT.Enabled && c.MQTT.Broker != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewMQTTChannel(cfg.Channels.MQTT, b)
+		},
+	},
+	{
+		// Splits at the provider's limit itself, see PushChannel.Send
+		name:    "push",
+		display: "Push notifications",
+		enabled: func(c *config.ChannelsConfig) bool {
+			return c.Push.Enabled && (c.Push.Topic != "" || c.Push.User != "")
+		},
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewPushChannel(cfg.Channels.Push, b)
+		},
+	},
+	{
+		name:    "feeds",
+		display: "Feed watcher",
+		enabled: func(c *config.ChannelsConfig) bool { return c.Feeds.Enabled && len(c.Feeds.Feeds) > 0 },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			statePath := filepath.Join(cfg.WorkspacePath(), "state", "feeds.json")
+			return NewFeedsChannel(cfg.Channels.Feeds, statePath, b)
+		},
+	},
+	{
+		name:    "webhook",
+		display: "Generic webhook",
+		enabled: func(c *config.ChannelsConfig) bool { return c.Webhook.Enabled && c.Webhook.OutboundURL != "" },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWebhookChannel(cfg.Channels.Webhook, b)
+		},
+	},
+}
+
+// messageLimit returns the message length limit of the named channel.
+func messageLimit(name string) int {
+	for _, spec := range channelSpecs {
+		if spec.name == name {
+			return spec.maxMessageLen
+		}
+	}
+	return 0
+}
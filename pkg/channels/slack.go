@@ -26,12 +26,17 @@ type SlackChannel struct {
 	socketClient *socketmode.Client
 	botUserID    string
 	teamID       string
-	transcriber  *voice.GroqTranscriber
+	transcriber  voice.Transcriber
 	ctx          context.Context
 	cancel       context.CancelFunc
 	pendingAcks  sync.Map
+	placeholders *progressPlaceholders
+	answers      *answerTracker
+	replies      *replyTracker
 }
 
+var _ ProgressChannel = (*SlackChannel)(nil)
+
 type slackMessageRef struct {
 	ChannelID string
 	Timestamp string
@@ -41,6 +46,9 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 	if cfg.BotToken == "" || cfg.AppToken == "" {
 		return nil, fmt.Errorf("slack bot_token and app_token are required")
 	}
+	if err := validateEditMode(cfg.OnEdit); err != nil {
+		return nil, fmt.Errorf("slack %w", err)
+	}
 
 	api := slack.New(
 		cfg.BotToken,
@@ -56,10 +64,13 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 		config:       cfg,
 		api:          api,
 		socketClient: socketClient,
+		placeholders: newProgressPlaceholders(),
+		answers:      newAnswerTracker(),
+		replies:      newReplyTracker(),
 	}, nil
 }
 
-func (c *SlackChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *SlackChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
@@ -83,11 +94,15 @@ func (c *SlackChannel) Start(ctx context.Context) error {
 	go c.eventLoop()
 
 	go func() {
+		// RunContext reconnects by itself and only returns on fatal errors
+		// such as revoked tokens, so hand the channel back to the manager
 		if err := c.socketClient.RunContext(c.ctx); err != nil {
 			if c.ctx.Err() == nil {
 				logger.ErrorCF("slack", "Socket Mode connection error", map[string]any{
 					"error": err.Error(),
 				})
+				c.recordError(err)
+				c.setRunning(false)
 			}
 		}
 	}()
@@ -109,6 +124,22 @@ func (c *SlackChannel) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Notify messages a user directly. Channel IDs are sent to as they are;
+// user IDs (U.../W...) get a direct message conversation opened first.
+func (c *SlackChannel) Notify(ctx context.Context, userID, content string) error {
+	chatID := userID
+	if strings.HasPrefix(userID, "U") || strings.HasPrefix(userID, "W") {
+		dm, _, _, err := c.api.OpenConversationContext(ctx, &slack.OpenConversationParameters{
+			Users: []string{userID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open slack DM: %w", err)
+		}
+		chatID = dm.ID
+	}
+	return c.Send(ctx, bus.OutboundMessage{Channel: c.Name(), ChatID: chatID, Content: content})
+}
+
 func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 	if !c.IsRunning() {
 		return fmt.Errorf("slack channel not running")
@@ -119,18 +150,39 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
 	}
 
-	opts := []slack.MsgOption{
-		slack.MsgOptionText(msg.Content, false),
+	if msg.Progress {
+		c.sendProgress(ctx, msg.ChatID, channelID, threadTS, msg.Content)
+		return nil
 	}
 
-	if threadTS != "" {
-		opts = append(opts, slack.MsgOptionTS(threadTS))
+	// Replace the progress placeholder with the answer, posting a new
+	// message if the edit fails
+	content := []slack.MsgOption{slack.MsgOptionText(msg.Content, false)}
+	if len(msg.Buttons) > 0 {
+		content = append(content, slack.MsgOptionBlocks(slackButtonBlocks(msg.Content, msg.Buttons)...))
 	}
 
-	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
-	if err != nil {
-		return fmt.Errorf("failed to send slack message: %w", err)
+	answerTS := ""
+	if ts, ok := c.placeholders.take(msg.ChatID); ok {
+		if _, _, _, err := c.api.UpdateMessageContext(ctx, channelID, ts, content...); err == nil {
+			answerTS = ts
+		}
+	}
+
+	if answerTS == "" {
+		opts := content
+
+		if threadTS != "" {
+			opts = append(opts, slack.MsgOptionTS(threadTS))
+		}
+
+		_, ts, err := c.api.PostMessageContext(ctx, channelID, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to send slack message: %w", err)
+		}
+		answerTS = ts
 	}
+	c.replies.Remember(channelID, answerTS, sentReply{msg.ChatID, msg.Content})
 
 	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
 		msgRef := ref.(slackMessageRef)
@@ -138,6 +190,7 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 			Channel:   msgRef.ChannelID,
 			Timestamp: msgRef.Timestamp,
 		})
+		c.answers.Remember(msgRef.ChannelID, msgRef.Timestamp, answerTS)
 	}
 
 	logger.DebugCF("slack", "Message sent", map[string]any{
@@ -148,6 +201,127 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return nil
 }
 
+// SupportsButtons reports that buttons are shown as Block Kit actions.
+func (c *SlackChannel) SupportsButtons() bool {
+	return true
+}
+
+// slackButtonBlocks renders content as a section followed by the buttons.
+func slackButtonBlocks(content string, buttons []bus.Button) []slack.Block {
+	elements := make([]slack.BlockElement, 0, len(buttons))
+	for i, b := range buttons {
+		label := slack.NewTextBlockObject(slack.PlainTextType, b.Label, false, false)
+		button := slack.NewButtonBlockElement(fmt.Sprintf("button_%d", i), b.Value, label)
+		switch b.Style {
+		case "primary":
+			button.WithStyle(slack.StylePrimary)
+		case "danger":
+			button.WithStyle(slack.StyleDanger)
+		}
+		elements = append(elements, button)
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, content, false, false), nil, nil),
+		slack.NewActionBlock("buttons", elements...),
+	}
+}
+
+// handleInteractive delivers a button press as an inbound message with the
+// button's value, and replaces the buttons with a note of the choice.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	if event.Request != nil {
+		c.socketClient.Ack(*event.Request)
+	}
+
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions || len(callback.ActionCallback.BlockActions) == 0 {
+		return
+	}
+	action := callback.ActionCallback.BlockActions[0]
+	senderID := callback.User.ID
+	channelID := callback.Container.ChannelID
+	if channelID == "" {
+		channelID = callback.Channel.ID
+	}
+
+	if !c.IsAllowed(senderID) {
+		c.RejectSender(senderID, channelID)
+		return
+	}
+
+	chatID := channelID
+	threadTS := callback.Container.ThreadTs
+	if threadTS != "" {
+		chatID = channelID + "/" + threadTS
+	}
+
+	text := callback.Message.Text
+	choice := fmt.Sprintf("%s by <@%s>", action.Text.Text, senderID)
+	_, _, _, err := c.api.UpdateMessageContext(c.ctx, channelID, callback.Container.MessageTs,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, choice, false, false)),
+		))
+	if err != nil {
+		logger.DebugCF("slack", "Failed to remove buttons", map[string]any{"error": err.Error()})
+	}
+
+	peerKind := "channel"
+	peerID := channelID
+	if strings.HasPrefix(channelID, "D") {
+		peerKind = "direct"
+		peerID = senderID
+	}
+
+	c.HandleMessage(senderID, chatID, action.Value, nil, map[string]string{
+		"message_ts": callback.Container.MessageTs,
+		"channel_id": channelID,
+		"thread_ts":  threadTS,
+		"thread_id":  threadTS,
+		"platform":   "slack",
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+		"team_id":    c.teamID,
+		"button":     "true",
+	})
+}
+
+// SupportsProgress reports whether interim updates are shown in a
+// placeholder message
+func (c *SlackChannel) SupportsProgress() bool {
+	return c.config.ProgressiveReply
+}
+
+// sendProgress posts the chat's placeholder on the first update and edits
+// it on later ones.
+func (c *SlackChannel) sendProgress(ctx context.Context, chatID, channelID, threadTS, content string) {
+	text := progressText(content)
+	ts, ok := c.placeholders.update(chatID, text)
+	if !ok {
+		return
+	}
+
+	var err error
+	if ts != "" {
+		_, _, _, err = c.api.UpdateMessageContext(ctx, channelID, ts, slack.MsgOptionText(text, false))
+	} else {
+		opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+		if threadTS != "" {
+			opts = append(opts, slack.MsgOptionTS(threadTS))
+		}
+		if _, ts, err = c.api.PostMessageContext(ctx, channelID, opts...); err == nil {
+			c.placeholders.set(chatID, ts, text)
+		}
+	}
+	if err != nil {
+		logger.DebugCF("slack", "Failed to update progress placeholder", map[string]any{
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+	}
+}
+
 func (c *SlackChannel) eventLoop() {
 	for {
 		select {
@@ -158,14 +332,16 @@ func (c *SlackChannel) eventLoop() {
 				return
 			}
 			switch event.Type {
+			case socketmode.EventTypeConnected:
+				c.recordSuccess()
+			case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+				c.recordError(fmt.Errorf("socket mode %s: %v", event.Type, event.Data))
 			case socketmode.EventTypeEventsAPI:
 				c.handleEventsAPI(event)
 			case socketmode.EventTypeSlashCommand:
 				c.handleSlashCommand(event)
 			case socketmode.EventTypeInteractive:
-				if event.Request != nil {
-					c.socketClient.Ack(*event.Request)
-				}
+				c.handleInteractive(event)
 			}
 		}
 	}
@@ -183,13 +359,97 @@ func (c *SlackChannel) handleEventsAPI(event socketmode.Event) {
 
 	switch ev := eventsAPIEvent.InnerEvent.Data.(type) {
 	case *slackevents.MessageEvent:
-		c.handleMessageEvent(ev)
+		if ev.SubType == "message_changed" {
+			c.handleMessageChanged(ev)
+		} else {
+			c.handleMessageEvent(ev, false)
+		}
 	case *slackevents.AppMentionEvent:
-		c.handleAppMention(ev)
+		c.handleAppMention(ev, false)
+	case *slackevents.ReactionAddedEvent:
+		c.handleReaction(ev)
+	}
+}
+
+// handleReaction takes a 👍 or 👎 on one of the bot's replies as feedback.
+func (c *SlackChannel) handleReaction(ev *slackevents.ReactionAddedEvent) {
+	if ev.User == "" || ev.User == c.botUserID || ev.ItemUser != c.botUserID {
+		return
+	}
+	reply, ok := c.replies.Reply(ev.Item.Channel, ev.Item.Timestamp)
+	if !ok {
+		return
+	}
+
+	peerKind, peerID := "channel", ev.Item.Channel
+	if strings.HasPrefix(ev.Item.Channel, "D") {
+		peerKind, peerID = "direct", ev.User
+	}
+	_, threadTS := parseSlackChatID(reply.chatID)
+	c.HandleFeedback(ev.User, ev.Item.Timestamp, reactionRating(ev.Reaction), reply, map[string]string{
+		"channel_id": ev.Item.Channel,
+		"thread_ts":  threadTS,
+		"thread_id":  threadTS,
+		"platform":   "slack",
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+		"team_id":    c.teamID,
+	})
+}
+
+// handleMessageChanged answers an edited message again when on_edit asks
+// for it. The edit goes through the same checks as a new message.
+func (c *SlackChannel) handleMessageChanged(ev *slackevents.MessageEvent) {
+	if c.config.OnEdit != EditAppend && c.config.OnEdit != EditReplace {
+		return
+	}
+	msg := ev.Message
+	if msg == nil || msg.User == "" || msg.User == c.botUserID || msg.BotID != "" {
+		return
+	}
+	// Link unfurls change messages too; only text edits count
+	if ev.PreviousMessage != nil && ev.PreviousMessage.Text == msg.Text {
+		return
+	}
+
+	if !strings.HasPrefix(ev.Channel, "D") && strings.Contains(msg.Text, fmt.Sprintf("<@%s>", c.botUserID)) {
+		c.handleAppMention(&slackevents.AppMentionEvent{
+			User:            msg.User,
+			Text:            msg.Text,
+			TimeStamp:       msg.Timestamp,
+			ThreadTimeStamp: msg.ThreadTimestamp,
+			Channel:         ev.Channel,
+		}, true)
+		return
+	}
+	// A message that started a thread since carries its own ts as thread_ts,
+	// but it was answered outside the thread
+	threadTS := msg.ThreadTimestamp
+	if threadTS == msg.Timestamp {
+		threadTS = ""
+	}
+	c.handleMessageEvent(&slackevents.MessageEvent{
+		User:            msg.User,
+		Text:            msg.Text,
+		TimeStamp:       msg.Timestamp,
+		ThreadTimeStamp: threadTS,
+		Channel:         ev.Channel,
+		Message:         msg,
+	}, true)
+}
+
+// reuseAnswer makes the answer to an edited message the chat's placeholder
+// when on_edit is "replace", so the new answer overwrites it.
+func (c *SlackChannel) reuseAnswer(chatID, channelID, messageTS string) {
+	if c.config.OnEdit != EditReplace {
+		return
+	}
+	if ts, ok := c.answers.Answer(channelID, messageTS); ok {
+		c.placeholders.set(chatID, ts, "")
 	}
 }
 
-func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
+func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent, edited bool) {
 	if ev.User == c.botUserID || ev.User == "" {
 		return
 	}
@@ -200,13 +460,27 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		return
 	}
 
+	if !strings.HasPrefix(ev.Channel, "D") {
+		// Mentions also arrive as app_mention events, which answer them
+		if strings.Contains(ev.Text, fmt.Sprintf("<@%s>", c.botUserID)) {
+			return
+		}
+		if !c.acceptGroupMessage(ev.Channel, ev.User, ev.Text, false) {
+			logger.DebugCF("slack", "Channel message ignored (no mention)", map[string]any{
+				"channel_id": ev.Channel,
+				"sender_id":  ev.User,
+			})
+			return
+		}
+	}
+
 	// 检查白名单，避免为被拒绝的用户下载附件
 	if !c.IsAllowed(ev.User) {
-		logger.DebugCF("slack", "Message rejected by allowlist", map[string]any{
-			"user_id": ev.User,
-		})
+		c.RejectSender(ev.User, ev.Channel)
 		return
 	}
+	// A mention opens the sender's attention window for follow-ups
+	c.acceptGroupMessage(ev.Channel, ev.User, ev.Text, true)
 
 	senderID := ev.User
 	channelID := ev.Channel
@@ -287,11 +561,17 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		"message_ts": messageTS,
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
+		"thread_id":  threadTS,
 		"platform":   "slack",
 		"peer_kind":  peerKind,
 		"peer_id":    peerID,
 		"team_id":    c.teamID,
 	}
+	if edited {
+		content = editedContent(content)
+		metadata["edited"] = "true"
+		c.reuseAnswer(chatID, channelID, messageTS)
+	}
 
 	logger.DebugCF("slack", "Received message", map[string]any{
 		"sender_id":  senderID,
@@ -303,29 +583,29 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
 }
 
-func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
+func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent, edited bool) {
 	if ev.User == c.botUserID {
 		return
 	}
 
 	if !c.IsAllowed(ev.User) {
-		logger.DebugCF("slack", "Mention rejected by allowlist", map[string]any{
-			"user_id": ev.User,
-		})
+		c.RejectSender(ev.User, ev.Channel)
 		return
 	}
+	// A mention opens the sender's attention window for follow-ups
+	c.acceptGroupMessage(ev.Channel, ev.User, ev.Text, true)
 
 	senderID := ev.User
 	channelID := ev.Channel
 	threadTS := ev.ThreadTimeStamp
 	messageTS := ev.TimeStamp
 
-	var chatID string
-	if threadTS != "" {
-		chatID = channelID + "/" + threadTS
-	} else {
-		chatID = channelID + "/" + messageTS
+	// Mentions outside a thread start one rooted at the mention itself.
+	threadID := threadTS
+	if threadID == "" {
+		threadID = messageTS
 	}
+	chatID := channelID + "/" + threadID
 
 	c.api.AddReaction("eyes", slack.ItemRef{
 		Channel:   channelID,
@@ -354,12 +634,18 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		"message_ts": messageTS,
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
+		"thread_id":  threadID,
 		"platform":   "slack",
 		"is_mention": "true",
 		"peer_kind":  mentionPeerKind,
 		"peer_id":    mentionPeerID,
 		"team_id":    c.teamID,
 	}
+	if edited {
+		content = editedContent(content)
+		metadata["edited"] = "true"
+		c.reuseAnswer(chatID, channelID, messageTS)
+	}
 
 	c.HandleMessage(senderID, chatID, content, nil, metadata)
 }
@@ -375,9 +661,7 @@ func (c *SlackChannel) handleSlashCommand(event socketmode.Event) {
 	}
 
 	if !c.IsAllowed(cmd.UserID) {
-		logger.DebugCF("slack", "Slash command rejected by allowlist", map[string]any{
-			"user_id": cmd.UserID,
-		})
+		c.RejectSender(cmd.UserID, cmd.ChannelID)
 		return
 	}
 
@@ -1,8 +1,14 @@
 package channels
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -123,6 +129,17 @@ func TestNewSlackChannel(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid on_edit", func(t *testing.T) {
+		cfg := config.SlackConfig{
+			BotToken: "xoxb-test",
+			AppToken: "xapp-test",
+			OnEdit:   "rerun",
+		}
+		if _, err := NewSlackChannel(cfg, msgBus); err == nil {
+			t.Error("expected error for invalid on_edit, got nil")
+		}
+	})
+
 	t.Run("valid config", func(t *testing.T) {
 		cfg := config.SlackConfig{
 			BotToken:  "xoxb-test",
@@ -172,3 +189,57 @@ func TestSlackChannelIsAllowed(t *testing.T) {
 		}
 	})
 }
+
+func TestSlackChannelAnswersEditedMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	msgBus := bus.NewMessageBus()
+	ch, err := NewSlackChannel(config.SlackConfig{
+		BotToken: "xoxb-test",
+		AppToken: "xapp-test",
+		OnEdit:   EditReplace,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewSlackChannel() error = %v", err)
+	}
+	ch.api = slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+	ch.botUserID = "UBOT"
+	ch.answers.Remember("D1", "100.1", "100.2")
+
+	edit := func(previous, text string) *slackevents.MessageEvent {
+		msg := &slack.Msg{User: "U1", Text: text, Timestamp: "100.1"}
+		return &slackevents.MessageEvent{
+			Channel:         "D1",
+			SubType:         "message_changed",
+			Message:         msg,
+			PreviousMessage: &slack.Msg{User: "U1", Text: previous, Timestamp: "100.1"},
+		}
+	}
+
+	// Unfurling a link leaves the text as it was
+	ch.handleMessageChanged(edit("see https://example.com", "see https://example.com"))
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("unfurl handled as an edit: %+v", msg)
+	}
+
+	ch.handleMessageChanged(edit("whats 2+2", "what's 2+3?"))
+	msg, ok := expectInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message for the edit")
+	}
+	if msg.ChatID != "D1" || msg.Content != "[edited message] what's 2+3?" || msg.Metadata["edited"] != "true" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if ts, ok := ch.placeholders.take("D1"); !ok || ts != "100.2" {
+		t.Errorf("placeholder = %q, %v; want the earlier answer", ts, ok)
+	}
+
+	ch.config.OnEdit = EditIgnore
+	ch.handleMessageChanged(edit("what's 2+3?", "what's 2+4?"))
+	if msg, ok := expectInbound(t, msgBus); ok {
+		t.Fatalf("edit handled with on_edit ignore: %+v", msg)
+	}
+}
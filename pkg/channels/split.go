@@ -0,0 +1,52 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// splitContent splits a reply into messages that fit the channel's length
+// limit, breaking at paragraphs, lines and code fences rather than letting the
+// platform truncate or reject it.
+func splitContent(channelName, content string) []string {
+	limit := messageLimit(channelName)
+	if limit <= 0 || len(content) <= limit {
+		return []string{content}
+	}
+	return utils.SplitMessage(content, limit)
+}
+
+// sendSplit delivers msg as one or more messages within the channel's length
+// limit. Media and buttons are attached to the last one, after the text they
+// belong to.
+func sendSplit(ctx context.Context, channel Channel, msg bus.OutboundMessage) error {
+	for _, part := range splitMessage(channel, msg) {
+		if err := sendSafely(ctx, channel, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitMessage returns the messages sendSplit sends for msg. Progress
+// updates are never split.
+func splitMessage(channel Channel, msg bus.OutboundMessage) []bus.OutboundMessage {
+	if msg.Progress {
+		return []bus.OutboundMessage{msg}
+	}
+
+	msg = withButtonsAsText(channel, msg)
+	chunks := splitContent(msg.Channel, msg.Content)
+	parts := make([]bus.OutboundMessage, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = msg
+		parts[i].Content = chunk
+		if i < len(chunks)-1 {
+			parts[i].Media = nil
+			parts[i].Buttons = nil
+		}
+	}
+	return parts
+}
@@ -0,0 +1,79 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSendSplitRespectsChannelLimit(t *testing.T) {
+	ch := &recordingChannel{BaseChannel: NewBaseChannel("discord", nil, nil, nil)}
+	paragraph := strings.Repeat("lorem ipsum ", 120) // 1440 bytes
+	content := strings.Join([]string{paragraph, paragraph, paragraph}, "\n\n")
+
+	msg := bus.OutboundMessage{Channel: "discord", ChatID: "c1", Content: content, Media: []string{"/tmp/a.png"}}
+	if err := sendSplit(context.Background(), ch, msg); err != nil {
+		t.Fatalf("sendSplit() error = %v", err)
+	}
+
+	if len(ch.sent) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(ch.sent))
+	}
+	for i, sent := range ch.sent {
+		if len(sent.Content) > 2000 {
+			t.Errorf("message %d is %d bytes, over Discord's limit", i, len(sent.Content))
+		}
+		if strings.TrimSpace(sent.Content) != strings.TrimSpace(paragraph) {
+			t.Errorf("message %d was not split at the paragraph break", i)
+		}
+		if wantMedia := i == 2; (len(sent.Media) > 0) != wantMedia {
+			t.Errorf("message %d media = %v", i, sent.Media)
+		}
+	}
+}
+
+func TestSplitContentWithoutLimit(t *testing.T) {
+	content := strings.Repeat("x", 100000)
+	if chunks := splitContent("webhook", content); len(chunks) != 1 {
+		t.Errorf("webhook reply split into %d messages, want 1", len(chunks))
+	}
+}
+
+func TestSendSplitListsButtonsAsText(t *testing.T) {
+	ch := &recordingChannel{BaseChannel: NewBaseChannel("discord", nil, nil, nil)}
+	msg := bus.OutboundMessage{
+		Channel: "discord",
+		ChatID:  "c1",
+		Content: "Allow exec to run?",
+		Buttons: []bus.Button{{Label: "Approve", Value: "/approve 1"}, {Label: "Deny", Value: "/deny 1"}},
+	}
+	if err := sendSplit(context.Background(), ch, msg); err != nil {
+		t.Fatalf("sendSplit() error = %v", err)
+	}
+
+	if len(ch.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(ch.sent))
+	}
+	want := "Allow exec to run?\n\nReply with:\n• /approve 1 (Approve)\n• /deny 1 (Deny)"
+	if sent := ch.sent[0]; sent.Content != want || len(sent.Buttons) != 0 {
+		t.Errorf("sent content = %q, buttons = %v", sent.Content, sent.Buttons)
+	}
+}
+
+func TestSendSplitListsButtonsInChannelLanguage(t *testing.T) {
+	ch := &recordingChannel{BaseChannel: NewBaseChannel("wecom", nil, nil, nil)}
+	ch.SetLanguage("zh-CN")
+	msg := bus.OutboundMessage{
+		Channel: "wecom",
+		ChatID:  "c1",
+		Buttons: []bus.Button{{Label: "批准", Value: "/approve 1"}},
+	}
+	if err := sendSplit(context.Background(), ch, msg); err != nil {
+		t.Fatalf("sendSplit() error = %v", err)
+	}
+	if want := "请回复：\n• /approve 1 (批准)"; len(ch.sent) != 1 || ch.sent[0].Content != want {
+		t.Errorf("sent = %+v, want %q", ch.sent, want)
+	}
+}
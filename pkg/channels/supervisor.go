@@ -0,0 +1,131 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	superviseInterval = 10 * time.Second
+	restartBackoffMin = 5 * time.Second
+	restartBackoffMax = 5 * time.Minute
+)
+
+// restartState tracks restart attempts for one channel.
+type restartState struct {
+	failures    int
+	restarts    int
+	nextAttempt time.Time
+}
+
+// supervise periodically restarts channels that are no longer running, so a
+// channel that failed to start or crashed recovers without affecting the
+// others. It stops when ctx is cancelled by StopAll.
+func (m *Manager) supervise(ctx context.Context) {
+	ticker := time.NewTicker(superviseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkChannels(ctx)
+		}
+	}
+}
+
+// checkChannels restarts stopped channels, backing off exponentially for
+// channels that keep failing.
+func (m *Manager) checkChannels(ctx context.Context) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+
+	now := time.Now()
+	for name, channel := range m.channels {
+		if ctx.Err() != nil {
+			return
+		}
+
+		state := m.restarts[name]
+		if state == nil {
+			state = &restartState{}
+			m.restarts[name] = state
+		}
+
+		if channel.IsRunning() {
+			state.failures = 0
+			continue
+		}
+		if now.Before(state.nextAttempt) {
+			continue
+		}
+
+		logger.WarnCF("channels", "Channel is not running, restarting", map[string]any{
+			"channel": name,
+			"attempt": state.failures + 1,
+		})
+
+		if err := safeChannelCall(func() error { return channel.Stop(ctx) }); err != nil {
+			logger.DebugCF("channels", "Error stopping channel before restart", map[string]any{
+				"channel": name,
+				"error":   err.Error(),
+			})
+		}
+
+		err := safeChannelCall(func() error { return channel.Start(ctx) })
+		if err == nil && !channel.IsRunning() {
+			err = fmt.Errorf("channel did not report running after start")
+		}
+		if err != nil {
+			if hr, ok := channel.(healthRecorder); ok {
+				hr.recordError(err)
+			}
+			state.failures++
+			state.nextAttempt = now.Add(restartBackoff(state.failures))
+			logger.ErrorCF("channels", "Channel restart failed", map[string]any{
+				"channel":    name,
+				"error":      err.Error(),
+				"next_retry": state.nextAttempt.Format(time.RFC3339),
+			})
+			continue
+		}
+
+		state.failures = 0
+		state.restarts++
+		logger.InfoCF("channels", "Channel restarted", map[string]any{
+			"channel": name,
+		})
+	}
+}
+
+func restartBackoff(failures int) time.Duration {
+	backoff := restartBackoffMin
+	for i := 1; i < failures && backoff < restartBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, restartBackoffMax)
+}
+
+// safeChannelCall runs a channel lifecycle call, turning a panic into an
+// error so one misbehaving channel can't take down the gateway.
+func safeChannelCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// sendSafely delivers an outbound message, recovering from panics in the
+// channel's Send.
+func sendSafely(ctx context.Context, channel Channel, msg bus.OutboundMessage) error {
+	return safeChannelCall(func() error { return channel.Send(ctx, msg) })
+}
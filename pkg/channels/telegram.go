@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
@@ -29,11 +31,35 @@ type TelegramChannel struct {
 	commands     TelegramCommander
 	config       *config.Config
 	chatIDs      map[string]int64
-	transcriber  *voice.GroqTranscriber
-	placeholders sync.Map // chatID -> messageID
+	transcriber  voice.Transcriber
+	placeholders *progressPlaceholders
 	stopThinking sync.Map // chatID -> thinkingCancel
+	threads      *threadTracker
+	replyTargets sync.Map // threaded chatID -> telegramReplyTarget
+	buttonChats  sync.Map // "<chat>:<message>" with buttons -> chatID the prompt was sent to
+	answers      *answerTracker
+	replies      *replyTracker
 }
 
+// telegramReplyTarget says where replies to a threaded chat go: into a forum
+// topic, or as a reply to the user's latest message in a reply chain.
+type telegramReplyTarget struct {
+	topicID   int
+	messageID int
+}
+
+func (t telegramReplyTarget) replyParameters() *telego.ReplyParameters {
+	if t.messageID == 0 {
+		return nil
+	}
+	return &telego.ReplyParameters{MessageID: t.messageID, AllowSendingWithoutReply: true}
+}
+
+var (
+	_ VoiceReplyChannel = (*TelegramChannel)(nil)
+	_ ProgressChannel   = (*TelegramChannel)(nil)
+)
+
 type thinkingCancel struct {
 	fn context.CancelFunc
 }
@@ -47,6 +73,9 @@ func (c *thinkingCancel) Cancel() {
 func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChannel, error) {
 	var opts []telego.BotOption
 	telegramCfg := cfg.Channels.Telegram
+	if err := validateEditMode(telegramCfg.OnEdit); err != nil {
+		return nil, fmt.Errorf("telegram %w", err)
+	}
 
 	if telegramCfg.Proxy != "" {
 		proxyURL, parseErr := url.Parse(telegramCfg.Proxy)
@@ -81,20 +110,25 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 		config:       cfg,
 		chatIDs:      make(map[string]int64),
 		transcriber:  nil,
-		placeholders: sync.Map{},
+		placeholders: newProgressPlaceholders(),
 		stopThinking: sync.Map{},
+		threads:      newThreadTracker(),
+		answers:      newAnswerTracker(),
+		replies:      newReplyTracker(),
 	}, nil
 }
 
-func (c *TelegramChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *TelegramChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
 func (c *TelegramChannel) Start(ctx context.Context) error {
 	logger.InfoC("telegram", "Starting Telegram bot (polling mode)...")
 
+	// Reactions are only sent when asked for by name
 	updates, err := c.bot.UpdatesViaLongPolling(ctx, &telego.GetUpdatesParams{
-		Timeout: 30,
+		Timeout:        30,
+		AllowedUpdates: []string{"message", "edited_message", "callback_query", "message_reaction"},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to start long polling: %w", err)
@@ -105,26 +139,30 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create bot handler: %w", err)
 	}
 
-	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
-		c.commands.Help(ctx, message)
-		return nil
-	}, th.CommandEqual("help"))
+	// Other commands, like /help, go to the agent's shared command registry
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		return c.commands.Start(ctx, message)
 	}, th.CommandEqual("start"))
 
-	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
-		return c.commands.Show(ctx, message)
-	}, th.CommandEqual("show"))
-
-	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
-		return c.commands.List(ctx, message)
-	}, th.CommandEqual("list"))
-
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	if mode := c.config.Channels.Telegram.OnEdit; mode == EditAppend || mode == EditReplace {
+		bh.HandleEditedMessage(func(ctx *th.Context, message telego.Message) error {
+			return c.handleMessage(ctx, &message)
+		}, th.AnyEditedMessage())
+	}
+
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, query)
+	}, th.AnyCallbackQueryWithMessage())
+
+	bh.HandleMessageReaction(func(ctx *th.Context, reaction telego.MessageReactionUpdated) error {
+		c.handleReaction(reaction)
+		return nil
+	})
+
 	c.setRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -156,6 +194,11 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return fmt.Errorf("invalid chat ID: %w", err)
 	}
 
+	if msg.Progress {
+		c.sendProgress(ctx, chatID, msg.ChatID, msg.Content)
+		return nil
+	}
+
 	// Stop thinking animation
 	if stop, ok := c.stopThinking.Load(msg.ChatID); ok {
 		if cf, ok := stop.(*thinkingCancel); ok && cf != nil {
@@ -164,35 +207,335 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		c.stopThinking.Delete(msg.ChatID)
 	}
 
-	htmlContent := markdownToTelegramHTML(msg.Content)
+	if msg.Content != "" || len(msg.Media) == 0 {
+		if err := c.sendText(ctx, chatID, msg.ChatID, msg.Content, telegramKeyboard(msg.Buttons)); err != nil {
+			return err
+		}
+	} else if pID, ok := c.takePlaceholder(msg.ChatID); ok {
+		// Media-only reply, e.g. a voice note: drop the "Thinking..." placeholder
+		c.bot.DeleteMessage(ctx, tu.Delete(tu.ID(chatID), pID))
+	}
+
+	for _, path := range msg.Media {
+		if err := c.sendMedia(ctx, chatID, msg.ChatID, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendText sends a reply, editing the "Thinking..." placeholder when there is
+// one. keyboard, if not nil, shows the message's buttons.
+func (c *TelegramChannel) sendText(
+	ctx context.Context,
+	chatID int64,
+	chatKey, content string,
+	keyboard *telego.InlineKeyboardMarkup,
+) error {
+	htmlContent := markdownToTelegramHTML(content)
 
 	// Try to edit placeholder
-	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
-		c.placeholders.Delete(msg.ChatID)
-		editMsg := tu.EditMessageText(tu.ID(chatID), pID.(int), htmlContent)
+	if pID, ok := c.takePlaceholder(chatKey); ok {
+		editMsg := tu.EditMessageText(tu.ID(chatID), pID, htmlContent)
 		editMsg.ParseMode = telego.ModeHTML
+		editMsg.ReplyMarkup = keyboard
 
-		if _, err = c.bot.EditMessageText(ctx, editMsg); err == nil {
+		if _, err := c.bot.EditMessageText(ctx, editMsg); err == nil {
+			c.rememberButtons(chatID, pID, chatKey, keyboard)
+			c.replies.Remember(strconv.FormatInt(chatID, 10), strconv.Itoa(pID), sentReply{chatKey, content})
 			return nil
 		}
 		// Fallback to new message if edit fails
 	}
 
+	target := c.replyTarget(chatKey)
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
+	tgMsg.MessageThreadID = target.topicID
+	tgMsg.ReplyParameters = target.replyParameters()
+	if keyboard != nil {
+		tgMsg.ReplyMarkup = keyboard
+	}
 
-	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
+	sent, err := c.bot.SendMessage(ctx, tgMsg)
+	if err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
 			"error": err.Error(),
 		})
 		tgMsg.ParseMode = ""
-		_, err = c.bot.SendMessage(ctx, tgMsg)
-		return err
+		if sent, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
+			return err
+		}
+	}
+
+	c.rememberSent(chatKey, sent)
+	c.rememberButtons(chatID, sent.MessageID, chatKey, keyboard)
+	c.replies.Remember(strconv.FormatInt(chatID, 10), strconv.Itoa(sent.MessageID), sentReply{chatKey, content})
+	return nil
+}
+
+// SupportsButtons reports that buttons are shown as an inline keyboard.
+func (c *TelegramChannel) SupportsButtons() bool {
+	return true
+}
+
+// telegramKeyboard lays buttons out in one row, or returns nil for none.
+// Telegram limits callback data to 64 bytes.
+func telegramKeyboard(buttons []bus.Button) *telego.InlineKeyboardMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	row := make([]telego.InlineKeyboardButton, 0, len(buttons))
+	for _, b := range buttons {
+		row = append(row, tu.InlineKeyboardButton(b.Label).WithCallbackData(b.Value))
+	}
+	return tu.InlineKeyboard(row)
+}
+
+// rememberButtons records which threaded chat a message with buttons went
+// to, so presses are answered in the same thread.
+func (c *TelegramChannel) rememberButtons(
+	chatID int64,
+	messageID int,
+	chatKey string,
+	keyboard *telego.InlineKeyboardMarkup,
+) {
+	if keyboard != nil {
+		c.buttonChats.Store(fmt.Sprintf("%d:%d", chatID, messageID), chatKey)
+	}
+}
+
+// handleCallbackQuery delivers a button press as an inbound message with the
+// button's value, and removes the keyboard so the choice is made only once.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query telego.CallbackQuery) error {
+	user := query.From
+	senderID := fmt.Sprintf("%d", user.ID)
+	if user.Username != "" {
+		senderID = fmt.Sprintf("%d|%s", user.ID, user.Username)
+	}
+
+	if !c.IsAllowed(senderID) {
+		answer := tu.CallbackQuery(query.ID).WithText("You are not allowed to use this bot")
+		return c.bot.AnswerCallbackQuery(ctx, answer)
+	}
+	if err := c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID)); err != nil {
+		logger.DebugCF("telegram", "Failed to answer callback query", map[string]any{"error": err.Error()})
+	}
+	if query.Data == "" {
+		return nil
+	}
+
+	chat := query.Message.GetChat()
+	messageID := query.Message.GetMessageID()
+	removeKeyboard := tu.EditMessageReplyMarkup(tu.ID(chat.ID), messageID, nil)
+	if _, err := c.bot.EditMessageReplyMarkup(ctx, removeKeyboard); err != nil {
+		logger.DebugCF("telegram", "Failed to remove buttons", map[string]any{"error": err.Error()})
+	}
+
+	chatKey := fmt.Sprintf("%d", chat.ID)
+	if key, ok := c.buttonChats.LoadAndDelete(fmt.Sprintf("%d:%d", chat.ID, messageID)); ok {
+		chatKey = key.(string)
+	}
+
+	peerKind := "direct"
+	peerID := fmt.Sprintf("%d", user.ID)
+	if chat.Type != "private" {
+		peerKind = "group"
+		peerID = fmt.Sprintf("%d", chat.ID)
+	}
+	metadata := map[string]string{
+		"message_id": fmt.Sprintf("%d", messageID),
+		"user_id":    fmt.Sprintf("%d", user.ID),
+		"username":   user.Username,
+		"first_name": user.FirstName,
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+		"button":     "true",
+	}
+	if _, threadID, ok := strings.Cut(chatKey, "/"); ok {
+		metadata["thread_id"] = threadID
+	}
+
+	c.HandleMessage(senderID, chatKey, query.Data, nil, metadata)
+	return nil
+}
+
+// SupportsProgress reports whether interim updates edit the "Thinking..."
+// placeholder
+func (c *TelegramChannel) SupportsProgress() bool {
+	return c.config.Channels.Telegram.ProgressiveReply
+}
+
+// sendProgress edits the chat's placeholder to show what the agent is doing.
+func (c *TelegramChannel) sendProgress(ctx context.Context, chatID int64, chatKey, content string) {
+	text := progressText(content)
+	pID, ok := c.placeholders.update(chatKey, text)
+	if !ok {
+		return
+	}
+	if pID == "" {
+		// The placeholder failed to send when the message arrived
+		target := c.replyTarget(chatKey)
+		tgMsg := tu.Message(tu.ID(chatID), text)
+		tgMsg.MessageThreadID = target.topicID
+		tgMsg.ReplyParameters = target.replyParameters()
+		if sent, err := c.bot.SendMessage(ctx, tgMsg); err == nil {
+			c.placeholders.set(chatKey, strconv.Itoa(sent.MessageID), text)
+			c.rememberSent(chatKey, sent)
+		}
+		return
 	}
 
+	id, _ := strconv.Atoi(pID)
+	if _, err := c.bot.EditMessageText(ctx, tu.EditMessageText(tu.ID(chatID), id, text)); err != nil {
+		logger.DebugCF("telegram", "Failed to update progress placeholder", map[string]any{
+			"chat_id": chatKey,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (c *TelegramChannel) takePlaceholder(chatKey string) (int, bool) {
+	pID, ok := c.placeholders.take(chatKey)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(pID)
+	return id, err == nil
+}
+
+// keepTyping repeats the typing indicator, which Telegram clears after five
+// seconds, until the reply is sent.
+func (c *TelegramChannel) keepTyping(ctx context.Context, action *telego.SendChatActionParams) {
+	ticker := time.NewTicker(4 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.bot.SendChatAction(ctx, action); err != nil && ctx.Err() == nil {
+				logger.DebugCF("telegram", "Failed to refresh chat action", map[string]any{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// sendMedia sends a local file, audio as a voice note and anything else as
+// a document.
+func (c *TelegramChannel) sendMedia(ctx context.Context, chatID int64, chatKey, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open media: %w", err)
+	}
+	defer file.Close()
+
+	target := c.replyTarget(chatKey)
+	var sent *telego.Message
+	if utils.IsAudioFile(path, "") {
+		params := tu.Voice(tu.ID(chatID), tu.File(file))
+		params.MessageThreadID = target.topicID
+		params.ReplyParameters = target.replyParameters()
+		sent, err = c.bot.SendVoice(ctx, params)
+	} else {
+		params := tu.Document(tu.ID(chatID), tu.File(file))
+		params.MessageThreadID = target.topicID
+		params.ReplyParameters = target.replyParameters()
+		sent, err = c.bot.SendDocument(ctx, params)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send media: %w", err)
+	}
+	c.rememberSent(chatKey, sent)
 	return nil
 }
 
+// trackThread resolves the thread a message belongs to: its forum topic, or
+// the root of the reply chain it continues. Threaded messages get the chat ID
+// "<chatID>/<threadID>" so the reply lands back in the same thread.
+func (c *TelegramChannel) trackThread(message *telego.Message) (chatKey, threadID string) {
+	chatID := fmt.Sprintf("%d", message.Chat.ID)
+	var target telegramReplyTarget
+	switch {
+	case message.IsTopicMessage && message.MessageThreadID != 0:
+		threadID = fmt.Sprintf("%d", message.MessageThreadID)
+		target.topicID = message.MessageThreadID
+	case message.ReplyToMessage != nil:
+		threadID = c.threads.Root(chatID, fmt.Sprintf("%d", message.ReplyToMessage.MessageID))
+		target.messageID = message.MessageID
+	default:
+		return chatID, ""
+	}
+
+	c.threads.Remember(chatID, fmt.Sprintf("%d", message.MessageID), threadID)
+	chatKey = chatID + "/" + threadID
+	c.replyTargets.Store(chatKey, target)
+	return chatKey, threadID
+}
+
+func (c *TelegramChannel) replyTarget(chatKey string) telegramReplyTarget {
+	if target, ok := c.replyTargets.Load(chatKey); ok {
+		return target.(telegramReplyTarget)
+	}
+	return telegramReplyTarget{}
+}
+
+// rememberSent adds a bot message to its chat's thread, so a user replying
+// to the bot's answer continues the same session.
+func (c *TelegramChannel) rememberSent(chatKey string, sent *telego.Message) {
+	chatID, threadID, ok := strings.Cut(chatKey, "/")
+	if !ok || sent == nil {
+		return
+	}
+	c.threads.Remember(chatID, fmt.Sprintf("%d", sent.MessageID), threadID)
+}
+
+// handleReaction takes a 👍 or 👎 on one of the bot's replies as feedback.
+// Reactions by anonymous group admins have no user and are skipped.
+func (c *TelegramChannel) handleReaction(reaction telego.MessageReactionUpdated) {
+	if reaction.User == nil {
+		return
+	}
+	messageID := strconv.Itoa(reaction.MessageID)
+	reply, ok := c.replies.Reply(strconv.FormatInt(reaction.Chat.ID, 10), messageID)
+	if !ok {
+		return
+	}
+
+	rating := ""
+	for _, r := range reaction.NewReaction {
+		if emoji, ok := r.(*telego.ReactionTypeEmoji); ok {
+			if rating = reactionRating(emoji.Emoji); rating != "" {
+				break
+			}
+		}
+	}
+
+	senderID := strconv.FormatInt(reaction.User.ID, 10)
+	metadata := map[string]string{
+		"user_id":   senderID,
+		"username":  reaction.User.Username,
+		"peer_kind": "direct",
+		"peer_id":   senderID,
+	}
+	if reaction.Chat.Type != "private" {
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = strconv.FormatInt(reaction.Chat.ID, 10)
+	}
+	if _, threadID, ok := strings.Cut(reply.chatID, "/"); ok {
+		metadata["thread_id"] = threadID
+	}
+	c.HandleFeedback(senderID, messageID, rating, reply, metadata)
+}
+
+// VoiceFormats lists the audio formats Telegram plays as voice notes
+func (c *TelegramChannel) VoiceFormats() []string {
+	return []string{"ogg", "mp3"}
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
@@ -208,11 +551,19 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		senderID = fmt.Sprintf("%d|%s", user.ID, user.Username)
 	}
 
+	isGroup := message.Chat.Type != "private"
+	if isGroup && !c.acceptGroupMessage(fmt.Sprintf("%d", message.Chat.ID), senderID,
+		message.Text+"\n"+message.Caption, c.mentionsBot(message)) {
+		logger.DebugCF("telegram", "Group message ignored (no mention)", map[string]any{
+			"chat_id":   fmt.Sprintf("%d", message.Chat.ID),
+			"sender_id": senderID,
+		})
+		return nil
+	}
+
 	// 检查白名单，避免为被拒绝的用户下载附件
 	if !c.IsAllowed(senderID) {
-		logger.DebugCF("telegram", "Message rejected by allowlist", map[string]any{
-			"user_id": senderID,
-		})
+		c.RejectSender(senderID, fmt.Sprintf("%d", message.Chat.ID))
 		return nil
 	}
 
@@ -318,9 +669,20 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		}
 	}
 
+	if isGroup {
+		content = c.stripBotMention(content)
+	}
+
 	if content == "" {
 		content = "[empty message]"
 	}
+	if author, quoted := telegramQuote(message, c.bot.ID()); quoted != "" {
+		content = withQuote(content, author, quoted)
+	}
+	edited := message.EditDate != 0
+	if edited {
+		content = editedContent(content)
+	}
 
 	logger.DebugCF("telegram", "Received message", map[string]any{
 		"sender_id": senderID,
@@ -328,8 +690,13 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		"preview":   utils.Truncate(content, 50),
 	})
 
+	chatIDStr, threadID := c.trackThread(message)
+	target := c.replyTarget(chatIDStr)
+
 	// Thinking indicator
-	err := c.bot.SendChatAction(ctx, tu.ChatAction(tu.ID(chatID), telego.ChatActionTyping))
+	action := tu.ChatAction(tu.ID(chatID), telego.ChatActionTyping)
+	action.MessageThreadID = target.topicID
+	err := c.bot.SendChatAction(ctx, action)
 	if err != nil {
 		logger.ErrorCF("telegram", "Failed to send chat action", map[string]any{
 			"error": err.Error(),
@@ -337,26 +704,36 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	}
 
 	// Stop any previous thinking animation
-	chatIDStr := fmt.Sprintf("%d", chatID)
 	if prevStop, ok := c.stopThinking.Load(chatIDStr); ok {
 		if cf, ok := prevStop.(*thinkingCancel); ok && cf != nil {
 			cf.Cancel()
 		}
 	}
 
-	// Create cancel function for thinking state
-	_, thinkCancel := context.WithTimeout(ctx, 5*time.Minute)
+	// Keep the typing indicator up until the reply is sent
+	thinkCtx, thinkCancel := context.WithTimeout(ctx, 5*time.Minute)
 	c.stopThinking.Store(chatIDStr, &thinkingCancel{fn: thinkCancel})
-
-	pMsg, err := c.bot.SendMessage(ctx, tu.Message(tu.ID(chatID), "Thinking... 💭"))
-	if err == nil {
-		pID := pMsg.MessageID
-		c.placeholders.Store(chatIDStr, pID)
+	go c.keepTyping(thinkCtx, action)
+
+	messageKey := fmt.Sprintf("%d", message.MessageID)
+	if pID, ok := c.reuseAnswer(ctx, chatID, messageKey, edited); ok {
+		c.placeholders.set(chatIDStr, pID, c.text(i18n.Thinking))
+	} else {
+		placeholder := tu.Message(tu.ID(chatID), c.text(i18n.Thinking))
+		placeholder.MessageThreadID = target.topicID
+		placeholder.ReplyParameters = target.replyParameters()
+		pMsg, err := c.bot.SendMessage(ctx, placeholder)
+		if err == nil {
+			pID = strconv.Itoa(pMsg.MessageID)
+			c.placeholders.set(chatIDStr, pID, placeholder.Text)
+			c.rememberSent(chatIDStr, pMsg)
+			c.answers.Remember(fmt.Sprintf("%d", chatID), messageKey, pID)
+		}
 	}
 
 	peerKind := "direct"
 	peerID := fmt.Sprintf("%d", user.ID)
-	if message.Chat.Type != "private" {
+	if isGroup {
 		peerKind = "group"
 		peerID = fmt.Sprintf("%d", chatID)
 	}
@@ -366,15 +743,127 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		"user_id":    fmt.Sprintf("%d", user.ID),
 		"username":   user.Username,
 		"first_name": user.FirstName,
-		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
+		"is_group":   fmt.Sprintf("%t", isGroup),
 		"peer_kind":  peerKind,
 		"peer_id":    peerID,
 	}
+	if threadID != "" {
+		metadata["thread_id"] = threadID
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.ForumTopicCreated == nil {
+		metadata["reply_to_message_id"] = fmt.Sprintf("%d", reply.MessageID)
+	}
+	if edited {
+		metadata["edited"] = "true"
+	}
 
-	c.HandleMessage(fmt.Sprintf("%d", user.ID), fmt.Sprintf("%d", chatID), content, mediaPaths, metadata)
+	c.HandleMessage(fmt.Sprintf("%d", user.ID), chatIDStr, content, mediaPaths, metadata)
 	return nil
 }
 
+// reuseAnswer turns the answer to an edited message back into a "Thinking..."
+// placeholder when on_edit is "replace", so the new answer overwrites it.
+func (c *TelegramChannel) reuseAnswer(
+	ctx context.Context,
+	chatID int64,
+	messageKey string,
+	edited bool,
+) (string, bool) {
+	if !edited || c.config.Channels.Telegram.OnEdit != EditReplace {
+		return "", false
+	}
+	pID, ok := c.answers.Answer(fmt.Sprintf("%d", chatID), messageKey)
+	if !ok {
+		return "", false
+	}
+	id, _ := strconv.Atoi(pID)
+	if _, err := c.bot.EditMessageText(ctx, tu.EditMessageText(tu.ID(chatID), id, c.text(i18n.Thinking))); err != nil {
+		logger.DebugCF("telegram", "Failed to reuse answer of edited message", map[string]any{
+			"message_id": messageKey,
+			"error":      err.Error(),
+		})
+		return "", false
+	}
+	return pID, true
+}
+
+// telegramQuote returns who wrote the message a message replies to, empty
+// for the bot, and its text: the part the user highlighted, or the whole
+// text or caption. Replies in forum topics point at the topic's service
+// message, which isn't quoted.
+func telegramQuote(message *telego.Message, botID int64) (author, text string) {
+	reply := message.ReplyToMessage
+	if reply != nil && reply.ForumTopicCreated != nil {
+		reply = nil
+	}
+	switch {
+	case message.Quote != nil:
+		text = message.Quote.Text
+	case reply != nil && reply.Text != "":
+		text = reply.Text
+	case reply != nil:
+		text = reply.Caption
+	}
+
+	author = "someone"
+	if reply != nil && reply.From != nil {
+		switch {
+		case reply.From.ID == botID:
+			author = ""
+		case reply.From.FirstName != "":
+			author = reply.From.FirstName
+		case reply.From.Username != "":
+			author = reply.From.Username
+		}
+	}
+	return author, text
+}
+
+// mentionsBot reports whether a group message is addressed to the bot: an
+// @username or text mention, a reply to one of its messages, or a command
+// that isn't meant for another bot.
+func (c *TelegramChannel) mentionsBot(message *telego.Message) bool {
+	botID := c.bot.ID()
+	// Topic messages reply to the topic's service message, which doesn't count
+	reply := message.ReplyToMessage
+	if reply != nil && reply.ForumTopicCreated == nil && reply.From != nil && reply.From.ID == botID {
+		return true
+	}
+
+	username := strings.ToLower(c.bot.Username())
+	for _, text := range []string{message.Text, message.Caption} {
+		if command, ok := strings.CutPrefix(text, "/"); ok {
+			command, _, _ = strings.Cut(command, " ")
+			_, target, addressed := strings.Cut(command, "@")
+			if !addressed || strings.EqualFold(target, username) {
+				return true
+			}
+		}
+		if username != "" && strings.Contains(strings.ToLower(text), "@"+username) {
+			return true
+		}
+	}
+
+	for _, entities := range [][]telego.MessageEntity{message.Entities, message.CaptionEntities} {
+		for _, entity := range entities {
+			if entity.Type == telego.EntityTypeTextMention && entity.User != nil && entity.User.ID == botID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripBotMention removes @username mentions of the bot from group messages.
+func (c *TelegramChannel) stripBotMention(content string) string {
+	username := c.bot.Username()
+	if username == "" {
+		return content
+	}
+	re := regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`)
+	return strings.TrimSpace(re.ReplaceAllString(content, ""))
+}
+
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
@@ -0,0 +1,47 @@
+package channels
+
+import "sync"
+
+// maxTrackedThreadMessages bounds how many message IDs a threadTracker
+// remembers before forgetting the oldest.
+const maxTrackedThreadMessages = 4096
+
+// threadTracker maps message IDs to the root of the reply chain they belong
+// to, for platforms like Telegram where threads are implicit reply chains.
+// Replies to replies, including replies to the bot's own answers, resolve to
+// the message that started the chain.
+type threadTracker struct {
+	mu    sync.Mutex
+	roots map[string]string
+	order []string
+}
+
+func newThreadTracker() *threadTracker {
+	return &threadTracker{roots: make(map[string]string)}
+}
+
+// Root returns the thread root of messageID in chatID, or messageID itself
+// when it is not part of a known thread.
+func (t *threadTracker) Root(chatID, messageID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if root, ok := t.roots[chatID+"/"+messageID]; ok {
+		return root
+	}
+	return messageID
+}
+
+// Remember records that messageID in chatID belongs to the thread rooted at root.
+func (t *threadTracker) Remember(chatID, messageID, root string) {
+	key := chatID + "/" + messageID
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.roots[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.roots[key] = root
+	for len(t.order) > maxTrackedThreadMessages {
+		delete(t.roots, t.order[0])
+		t.order = t.order[1:]
+	}
+}
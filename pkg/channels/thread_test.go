@@ -0,0 +1,37 @@
+package channels
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestThreadTrackerResolvesReplyChains(t *testing.T) {
+	tr := newThreadTracker()
+
+	// 10 <- 11 (user reply) <- 12 (bot answer) <- 13 (user reply to bot)
+	root := tr.Root("chat", "10")
+	if root != "10" {
+		t.Fatalf("Root of unknown message = %q, want 10", root)
+	}
+	tr.Remember("chat", "11", root)
+	tr.Remember("chat", "12", tr.Root("chat", "11"))
+	if got := tr.Root("chat", "12"); got != "10" {
+		t.Errorf("Root(12) = %q, want 10", got)
+	}
+	if got := tr.Root("other", "12"); got != "12" {
+		t.Errorf("Root in another chat = %q, want 12", got)
+	}
+}
+
+func TestThreadTrackerForgetsOldest(t *testing.T) {
+	tr := newThreadTracker()
+	for i := 0; i <= maxTrackedThreadMessages; i++ {
+		tr.Remember("chat", fmt.Sprint(i), "root")
+	}
+	if got := tr.Root("chat", "0"); got != "0" {
+		t.Errorf("oldest message still tracked: Root(0) = %q", got)
+	}
+	if got := tr.Root("chat", fmt.Sprint(maxTrackedThreadMessages)); got != "root" {
+		t.Errorf("newest message Root = %q, want root", got)
+	}
+}
@@ -0,0 +1,190 @@
+package channels
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// TLSChannel is implemented by channels embedding BaseChannel. Channels that
+// run a webhook server serve HTTPS once a TLS config is attached.
+type TLSChannel interface {
+	SetTLSConfig(tlsConfig *tls.Config)
+}
+
+// SetTLSConfig makes the channel's webhook server serve HTTPS.
+func (c *BaseChannel) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+}
+
+// listenAndServe runs a webhook server over HTTPS when TLS is configured and
+// plain HTTP otherwise.
+func (c *BaseChannel) listenAndServe(server *http.Server) error {
+	if c.tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = c.tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// webhookTLS builds the TLS setup shared by all webhook servers.
+type webhookTLS struct {
+	config *tls.Config
+
+	// Let's Encrypt HTTP-01 challenges are answered here, if set
+	challengeAddr    string
+	challengeHandler http.Handler
+	challengeServer  *http.Server
+}
+
+// newWebhookTLS returns the TLS setup for cfg, or nil when TLS is disabled.
+// ACME certificates are cached in cacheDir unless cfg names another one.
+func newWebhookTLS(cfg config.TLSConfig, cacheDir string) (*webhookTLS, error) {
+	hasFiles := cfg.CertFile != "" || cfg.KeyFile != ""
+	hasACME := len(cfg.ACMEDomains) > 0
+
+	switch {
+	case hasFiles && hasACME:
+		return nil, errors.New("set either cert_file/key_file or acme_domains, not both")
+	case hasFiles:
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("both cert_file and key_file are required")
+		}
+		reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &webhookTLS{config: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}}, nil
+	case hasACME:
+		if cfg.ACMECacheDir != "" {
+			cacheDir = cfg.ACMECacheDir
+		}
+		acme := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      cfg.ACMEEmail,
+		}
+		tlsConfig := acme.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return &webhookTLS{
+			config:           tlsConfig,
+			challengeAddr:    cfg.ACMEHTTPAddr,
+			challengeHandler: acme.HTTPHandler(nil),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// start runs the HTTP-01 challenge server. It also redirects plain HTTP
+// requests to HTTPS.
+func (w *webhookTLS) start() {
+	if w.challengeAddr == "" || w.challengeServer != nil {
+		return
+	}
+
+	server := &http.Server{
+		Addr:              w.challengeAddr,
+		Handler:           w.challengeHandler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	w.challengeServer = server
+
+	go func() {
+		logger.InfoCF("channels", "ACME challenge server listening", map[string]any{
+			"addr": w.challengeAddr,
+		})
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.ErrorCF("channels", "ACME challenge server error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}()
+}
+
+func (w *webhookTLS) stop(ctx context.Context) {
+	if w.challengeServer == nil {
+		return
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.challengeServer.Shutdown(shutdownCtx); err != nil {
+		logger.ErrorCF("channels", "ACME challenge server shutdown error", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	w.challengeServer = nil
+}
+
+// certReloader serves a certificate from files and reloads it when the
+// certificate file changes, so renewals by an external tool are picked up
+// without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	if r.cert != nil && !info.ModTime().After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// Keep serving the old certificate while files are being replaced
+			logger.WarnCF("channels", "Failed to reload TLS certificate", map[string]any{
+				"cert_file": r.certFile,
+				"error":     err.Error(),
+			})
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return r.cert, nil
+}
+
+// defaultCertCacheDir is where ACME certificates are kept in the workspace.
+func defaultCertCacheDir(workspace string) string {
+	return filepath.Join(workspace, "state", "certs")
+}
@@ -0,0 +1,126 @@
+package channels
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func writeTestCert(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func leafName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestNewWebhookTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+
+	tests := []struct {
+		name    string
+		cfg     config.TLSConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "disabled", cfg: config.TLSConfig{ACMEHTTPAddr: ":80"}, wantNil: true},
+		{name: "cert files", cfg: config.TLSConfig{CertFile: certFile, KeyFile: keyFile}},
+		{name: "acme", cfg: config.TLSConfig{ACMEDomains: []string{"bot.example.com"}}},
+		{name: "missing key", cfg: config.TLSConfig{CertFile: certFile}, wantErr: true},
+		{name: "missing file", cfg: config.TLSConfig{CertFile: certFile, KeyFile: "nope.pem"}, wantErr: true},
+		{
+			name:    "both",
+			cfg:     config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ACMEDomains: []string{"x"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newWebhookTLS(tt.cfg, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newWebhookTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (w == nil) != tt.wantNil {
+				t.Fatalf("newWebhookTLS() = %v, wantNil %v", w, tt.wantNil)
+			}
+			if w != nil && w.config.GetCertificate == nil {
+				t.Error("expected a certificate source")
+			}
+		})
+	}
+}
+
+func TestCertReloaderPicksUpRenewals(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if got := leafName(t, cert); got != "first" {
+		t.Fatalf("certificate = %q, want first", got)
+	}
+
+	writeTestCert(t, dir, "second")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	cert, _ = r.GetCertificate(nil)
+	if got := leafName(t, cert); got != "second" {
+		t.Errorf("certificate after renewal = %q, want second", got)
+	}
+}
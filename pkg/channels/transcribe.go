@@ -0,0 +1,65 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// SetTranscriber enables transcription of audio attachments in
+// HandleMessage. Channels that transcribe voice themselves (Telegram,
+// Discord, Slack, OneBot, WeCom App) define their own SetTranscriber, so
+// their messages are not transcribed twice.
+func (c *BaseChannel) SetTranscriber(transcriber voice.Transcriber) {
+	c.transcriber = transcriber
+}
+
+// transcribeMedia appends a transcription of each audio attachment to
+// content and records the detected language in metadata.
+func (c *BaseChannel) transcribeMedia(
+	content string,
+	media []string,
+	metadata map[string]string,
+) (string, map[string]string) {
+	if c.transcriber == nil || !c.transcriber.IsAvailable() {
+		return content, metadata
+	}
+
+	for _, path := range media {
+		if !utils.IsAudioFile(path, "") {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), transcriptionTimeout)
+		result, err := c.transcriber.Transcribe(ctx, path)
+		cancel()
+
+		text := ""
+		if err != nil {
+			logger.ErrorCF("channels", "Voice transcription failed", map[string]any{
+				"channel": c.name,
+				"path":    path,
+				"error":   err.Error(),
+			})
+			text = "[voice (transcription failed)]"
+		} else {
+			text = fmt.Sprintf("[voice transcription: %s]", result.Text)
+			if result.Language != "" {
+				if metadata == nil {
+					metadata = make(map[string]string)
+				}
+				metadata["voice_language"] = result.Language
+			}
+		}
+
+		if content != "" {
+			content += "\n"
+		}
+		content += text
+	}
+
+	return content, metadata
+}
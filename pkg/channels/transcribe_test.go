@@ -0,0 +1,75 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+type fakeTranscriber struct {
+	text string
+	err  error
+}
+
+func (f *fakeTranscriber) Transcribe(context.Context, string) (*voice.TranscriptionResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &voice.TranscriptionResponse{Text: f.text, Language: "en"}, nil
+}
+
+func (f *fakeTranscriber) IsAvailable() bool { return true }
+
+func TestBaseChannelTranscribesAudio(t *testing.T) {
+	tests := []struct {
+		name        string
+		transcriber voice.Transcriber
+		media       []string
+		want        string
+		wantLang    string
+	}{
+		{
+			name:        "audio",
+			transcriber: &fakeTranscriber{text: "call mom"},
+			media:       []string{"/tmp/photo.jpg", "/tmp/note.m4a"},
+			want:        "[audio]\n[voice transcription: call mom]",
+			wantLang:    "en",
+		},
+		{
+			name:        "failure",
+			transcriber: &fakeTranscriber{err: errors.New("boom")},
+			media:       []string{"/tmp/note.ogg"},
+			want:        "[audio]\n[voice (transcription failed)]",
+		},
+		{name: "no transcriber", media: []string{"/tmp/note.ogg"}, want: "[audio]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgBus := bus.NewMessageBus()
+			ch := NewBaseChannel("line", nil, msgBus, nil)
+			if tt.transcriber != nil {
+				ch.SetTranscriber(tt.transcriber)
+			}
+
+			ch.HandleMessage("u1", "c1", "[audio]", tt.media, nil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			msg, ok := msgBus.ConsumeInbound(ctx)
+			if !ok {
+				t.Fatal("expected inbound message")
+			}
+			if msg.Content != tt.want {
+				t.Errorf("content = %q, want %q", msg.Content, tt.want)
+			}
+			if msg.Metadata["voice_language"] != tt.wantLang {
+				t.Errorf("voice_language = %q, want %q", msg.Metadata["voice_language"], tt.wantLang)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package channels
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tunnel"
+)
+
+// tunnelRetryDelay is how long to wait before restarting a tunnel client
+// that exited.
+const tunnelRetryDelay = 10 * time.Second
+
+// WebhookReceiver is implemented by channels that receive platform callbacks
+// on a local HTTP server. When a tunnel is configured, the manager exposes
+// the server and tells the channel its public URL.
+type WebhookReceiver interface {
+	// WebhookAddr returns the local listen address and the callback path
+	WebhookAddr() (addr, path string)
+	SetPublicURL(url string)
+}
+
+// WebhookRegistrar is implemented by channels that can point the platform at
+// a new callback URL through its API.
+type WebhookRegistrar interface {
+	RegisterWebhook(ctx context.Context, url string) error
+}
+
+// SetPublicURL records the URL the platform reaches the webhook at.
+func (c *BaseChannel) SetPublicURL(url string) {
+	c.publicURL.Store(url)
+}
+
+// PublicURL returns the tunnel URL of the channel's webhook, if any.
+func (c *BaseChannel) PublicURL() string {
+	url, _ := c.publicURL.Load().(string)
+	return url
+}
+
+// startTunnels exposes the webhook channels through the configured tunnel.
+// Clients run until ctx is cancelled and are restarted if they exit; each new
+// public URL is handed to the channels behind it.
+func (m *Manager) startTunnels(ctx context.Context) {
+	cfg := m.config.Channels.Tunnel
+	if cfg.Provider == "" {
+		return
+	}
+
+	// Group receivers by local server; providers that expose everything with
+	// one process get a single group
+	groups := make(map[string][]WebhookReceiver)
+	for _, channel := range m.channels {
+		receiver, ok := channel.(WebhookReceiver)
+		if !ok {
+			continue
+		}
+		localURL := ""
+		if tunnel.PerPort(cfg.Provider) {
+			addr, _ := receiver.WebhookAddr()
+			localURL = m.localWebhookURL(addr)
+		}
+		groups[localURL] = append(groups[localURL], receiver)
+	}
+
+	for localURL, receivers := range groups {
+		t, err := tunnel.New(cfg, localURL)
+		if err != nil {
+			logger.ErrorCF("channels", "Invalid tunnel config", map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		go m.runTunnel(ctx, t, receivers)
+	}
+}
+
+func (m *Manager) runTunnel(ctx context.Context, t *tunnel.Tunnel, receivers []WebhookReceiver) {
+	for {
+		err := t.Run(ctx, func(publicURL string) {
+			m.publishWebhookURL(ctx, publicURL, receivers)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.ErrorCF("channels", "Tunnel stopped, restarting", map[string]any{
+			"error": err.Error(),
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tunnelRetryDelay):
+		}
+	}
+}
+
+// publishWebhookURL hands the public URL to each channel, registering it
+// with platforms that support it.
+func (m *Manager) publishWebhookURL(ctx context.Context, publicURL string, receivers []WebhookReceiver) {
+	for _, receiver := range receivers {
+		_, path := receiver.WebhookAddr()
+		url := publicURL + path
+		receiver.SetPublicURL(url)
+
+		name := ""
+		if ch, ok := receiver.(Channel); ok {
+			name = ch.Name()
+		}
+
+		registrar, ok := receiver.(WebhookRegistrar)
+		if !ok {
+			logger.InfoCF("channels", "Webhook reachable through tunnel; set it as the callback URL", map[string]any{
+				"channel": name,
+				"url":     url,
+			})
+			continue
+		}
+
+		if err := registrar.RegisterWebhook(ctx, url); err != nil {
+			logger.ErrorCF("channels", "Failed to register tunnel webhook URL", map[string]any{
+				"channel": name,
+				"url":     url,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		logger.InfoCF("channels", "Registered tunnel webhook URL", map[string]any{
+			"channel": name,
+			"url":     url,
+		})
+	}
+}
+
+// localWebhookURL returns the URL a tunnel client on this host uses to reach
+// a webhook server listening on addr.
+func (m *Manager) localWebhookURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if m.tls != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,65 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type fakeReceiver struct {
+	*BaseChannel
+	path       string
+	registered string
+	err        error
+}
+
+func (f *fakeReceiver) WebhookAddr() (string, string) { return "0.0.0.0:18791", f.path }
+
+func (f *fakeReceiver) RegisterWebhook(_ context.Context, url string) error {
+	f.registered = url
+	return f.err
+}
+
+func TestPublishWebhookURL(t *testing.T) {
+	m := &Manager{config: config.DefaultConfig()}
+	ok := &fakeReceiver{BaseChannel: NewBaseChannel("line", nil, nil, nil), path: "/webhook/line"}
+	failing := &fakeReceiver{
+		BaseChannel: NewBaseChannel("other", nil, nil, nil),
+		path:        "/hook",
+		err:         errors.New("denied"),
+	}
+
+	m.publishWebhookURL(context.Background(), "https://x.trycloudflare.com", []WebhookReceiver{ok, failing})
+
+	if ok.registered != "https://x.trycloudflare.com/webhook/line" {
+		t.Errorf("registered = %q", ok.registered)
+	}
+	if ok.PublicURL() != ok.registered {
+		t.Errorf("PublicURL() = %q, want %q", ok.PublicURL(), ok.registered)
+	}
+	if failing.PublicURL() != "https://x.trycloudflare.com/hook" {
+		t.Errorf("public URL should be recorded even if registration fails, got %q", failing.PublicURL())
+	}
+}
+
+func TestLocalWebhookURL(t *testing.T) {
+	m := &Manager{config: config.DefaultConfig()}
+	tests := map[string]string{
+		"0.0.0.0:18791":  "http://127.0.0.1:18791",
+		":18792":         "http://127.0.0.1:18792",
+		"192.168.1.5:80": "http://192.168.1.5:80",
+		"[::]:18793":     "http://127.0.0.1:18793",
+	}
+	for addr, want := range tests {
+		if got := m.localWebhookURL(addr); got != want {
+			t.Errorf("localWebhookURL(%q) = %q, want %q", addr, got, want)
+		}
+	}
+
+	m.tls = &webhookTLS{}
+	if got := m.localWebhookURL("0.0.0.0:443"); got != "https://127.0.0.1:443" {
+		t.Errorf("with TLS, localWebhookURL() = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package channels
+
+import (
+	"context"
+	"os"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// VoiceReplyChannel is implemented by channels that can send voice notes.
+// When text-to-speech is configured, the manager answers a voice message
+// with a voice note in one of VoiceFormats.
+type VoiceReplyChannel interface {
+	// VoiceFormats lists the audio formats (file extensions) the platform
+	// plays as voice notes, preferred first
+	VoiceFormats() []string
+	// WantsVoiceReply reports whether the last message in chatID was a
+	// voice message. It returns true once per voice message.
+	WantsVoiceReply(chatID string) bool
+}
+
+// noteVoiceMessage remembers whether the latest inbound message in a chat
+// was a voice message.
+func (c *BaseChannel) noteVoiceMessage(chatID string, media []string, metadata map[string]string) {
+	isVoice := metadata["msg_type"] == "voice"
+	for _, path := range media {
+		if utils.IsAudioFile(path, "") {
+			isVoice = true
+		}
+	}
+
+	if isVoice {
+		c.voiceChats.Store(chatID, true)
+	} else {
+		c.voiceChats.Delete(chatID)
+	}
+}
+
+func (c *BaseChannel) WantsVoiceReply(chatID string) bool {
+	_, ok := c.voiceChats.LoadAndDelete(chatID)
+	return ok
+}
+
+// speakReply turns a final reply to a voice message into a voice note. It
+// returns the message unchanged if no voice reply is wanted or synthesis
+// fails, and the path of the audio file to remove after sending.
+func (m *Manager) speakReply(
+	ctx context.Context,
+	channel Channel,
+	msg bus.OutboundMessage,
+) (bus.OutboundMessage, string) {
+	if m.tts == nil || msg.Progress || msg.Content == "" {
+		return msg, ""
+	}
+	vc, ok := channel.(VoiceReplyChannel)
+	if !ok || !vc.WantsVoiceReply(msg.ChatID) {
+		return msg, ""
+	}
+
+	text := voice.SpeechText(msg.Content)
+	ttsConfig := m.config.Voice.TTS
+	if text == "" || (ttsConfig.MaxChars > 0 && len([]rune(text)) > ttsConfig.MaxChars) {
+		return msg, ""
+	}
+
+	path, err := voice.SynthesizeFile(ctx, m.tts, text, vc.VoiceFormats())
+	if err != nil {
+		logger.ErrorCF("channels", "Speech synthesis failed, replying with text", map[string]any{
+			"channel": msg.Channel,
+			"error":   err.Error(),
+		})
+		os.Remove(path)
+		return msg, ""
+	}
+
+	spoken := msg
+	spoken.Media = append(append([]string{}, msg.Media...), path)
+	if !ttsConfig.IncludeText {
+		spoken.Content = ""
+	}
+	return spoken, path
+}
@@ -0,0 +1,75 @@
+package channels
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type fakeSynthesizer struct{ texts []string }
+
+func (f *fakeSynthesizer) Formats() []string { return []string{"mp3"} }
+
+func (f *fakeSynthesizer) Synthesize(_ context.Context, text, _, path string) error {
+	f.texts = append(f.texts, text)
+	return os.WriteFile(path, []byte("ID3"), 0o600)
+}
+
+type voiceChannel struct{ *BaseChannel }
+
+func (voiceChannel) VoiceFormats() []string                          { return []string{"mp3"} }
+func (voiceChannel) Start(context.Context) error                     { return nil }
+func (voiceChannel) Stop(context.Context) error                      { return nil }
+func (voiceChannel) Send(context.Context, bus.OutboundMessage) error { return nil }
+
+func TestSpeakReplyAnswersVoiceWithVoice(t *testing.T) {
+	cfg := config.DefaultConfig()
+	tts := &fakeSynthesizer{}
+	m := &Manager{config: cfg, tts: tts}
+	ch := voiceChannel{NewBaseChannel("telegram", nil, bus.NewMessageBus(), nil)}
+
+	ch.HandleMessage("u1", "c1", "[voice]", []string{filepath.Join(t.TempDir(), "note.ogg")}, nil)
+
+	reply := bus.OutboundMessage{Channel: "telegram", ChatID: "c1", Content: "**Sure**, done."}
+	spoken, path := m.speakReply(context.Background(), ch, reply)
+	if path == "" {
+		t.Fatal("expected a voice reply")
+	}
+	defer os.Remove(path)
+	if spoken.Content != "" || !slices.Equal(spoken.Media, []string{path}) {
+		t.Errorf("spoken = %+v", spoken)
+	}
+	if len(tts.texts) != 1 || tts.texts[0] != "Sure, done." {
+		t.Errorf("synthesized %q", tts.texts)
+	}
+
+	// Only the reply to the voice message is spoken
+	if again, path := m.speakReply(context.Background(), ch, reply); path != "" || again.Content != reply.Content {
+		t.Errorf("second reply should stay text, got %+v", again)
+	}
+
+	// Text messages get text replies
+	ch.HandleMessage("u1", "c1", "hi", nil, nil)
+	if _, path := m.speakReply(context.Background(), ch, reply); path != "" {
+		t.Error("reply to a text message should not be spoken")
+	}
+}
+
+func TestSpeakReplyRespectsMaxChars(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Voice.TTS.MaxChars = 5
+	cfg.Voice.TTS.IncludeText = true
+	m := &Manager{config: cfg, tts: &fakeSynthesizer{}}
+	ch := voiceChannel{NewBaseChannel("telegram", nil, bus.NewMessageBus(), nil)}
+
+	ch.HandleMessage("u1", "c1", "[voice]", nil, map[string]string{"msg_type": "voice"})
+	reply := bus.OutboundMessage{Channel: "telegram", ChatID: "c1", Content: "far too long"}
+	if _, path := m.speakReply(context.Background(), ch, reply); path != "" {
+		t.Error("long replies should be sent as text")
+	}
+}
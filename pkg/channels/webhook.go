@@ -0,0 +1,418 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Generic webhook channel implementation
+// Maps arbitrary inbound JSON to agent turns and POSTs templated replies,
+// so simple integrations can be wired up from config alone
+
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	webhookMaxBodySize     = 1 << 20
+	webhookDefaultTemplate = `{"chat_id": {{json .ChatID}}, "content": {{json .Content}}}`
+	// webhookPayloadMaxAge is how long the replies to an inbound payload can
+	// use it as .Inbound, so the payloads of quiet chats aren't kept forever.
+	webhookPayloadMaxAge = time.Hour
+)
+
+// WebhookChannel implements the Channel interface for generic HTTP
+// integrations.
+type WebhookChannel struct {
+	*BaseChannel
+	config     config.WebhookConfig
+	tmpl       *template.Template
+	httpServer *http.Server
+	client     *http.Client
+	payloads   sync.Map // chatID -> *webhookPayload, the last inbound one, exposed to templates as .Inbound
+}
+
+type webhookPayload struct {
+	payload  any
+	received time.Time
+}
+
+// webhookTemplateData is the value passed to the outbound template.
+type webhookTemplateData struct {
+	Channel string
+	ChatID  string
+	Content string
+	Inbound any
+}
+
+var _ WebhookReceiver = (*WebhookChannel)(nil)
+
+func NewWebhookChannel(cfg config.WebhookConfig, messageBus *bus.MessageBus) (*WebhookChannel, error) {
+	if cfg.OutboundURL == "" {
+		return nil, fmt.Errorf("webhook outbound_url is required")
+	}
+	if cfg.Secret == "" && !isLoopbackHost(cfg.WebhookHost) {
+		return nil, fmt.Errorf("webhook secret is required unless webhook_host is a loopback address")
+	}
+	if cfg.ContentField == "" {
+		cfg.ContentField = "$.content"
+	}
+	if cfg.OutboundMethod == "" {
+		cfg.OutboundMethod = http.MethodPost
+	}
+
+	text := cfg.OutboundTemplate
+	if text == "" {
+		text = webhookDefaultTemplate
+	}
+	tmpl, err := template.New("outbound").Funcs(template.FuncMap{
+		"json": webhookJSON,
+	}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook outbound_template: %w", err)
+	}
+
+	base := NewBaseChannel("webhook", cfg, messageBus, cfg.AllowFrom)
+
+	return &WebhookChannel{
+		BaseChannel: base,
+		config:      cfg,
+		tmpl:        tmpl,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// isLoopbackHost reports whether a listen host only accepts connections
+// from this machine. An empty host listens on every interface.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// WebhookAddr returns the webhook server's listen address and path.
+func (c *WebhookChannel) WebhookAddr() (string, string) {
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/generic"
+	}
+	return fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort), path
+}
+
+func (c *WebhookChannel) Start(ctx context.Context) error {
+	logger.InfoC("webhook", "Starting generic webhook channel")
+
+	mux := http.NewServeMux()
+	addr, path := c.WebhookAddr()
+	mux.HandleFunc(path, c.webhookHandler)
+
+	c.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	c.setRunning(true)
+
+	go func() {
+		logger.InfoCF("webhook", "Webhook server listening", map[string]any{
+			"addr": addr,
+			"path": path,
+		})
+		if err := c.listenAndServe(c.httpServer); err != nil && err != http.ErrServerClosed {
+			logger.ErrorCF("webhook", "Webhook server error", map[string]any{
+				"error": err.Error(),
+			})
+			c.setRunning(false) // Let the manager restart the channel
+		}
+	}()
+
+	return nil
+}
+
+func (c *WebhookChannel) Stop(ctx context.Context) error {
+	logger.InfoC("webhook", "Stopping generic webhook channel")
+
+	if c.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := c.httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.ErrorCF("webhook", "Webhook server shutdown error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	c.setRunning(false)
+	return nil
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("webhook channel not running")
+	}
+
+	body, err := c.renderOutbound(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.config.OutboundMethod, c.config.OutboundURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create outbound request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.config.OutboundHeaders {
+		req.Header.Set(k, v)
+	}
+	if c.config.Secret != "" {
+		req.Header.Set("X-Picoclaw-Signature", "sha256="+webhookSign(c.config.Secret, body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver webhook reply: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("outbound webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+func (c *WebhookChannel) renderOutbound(msg bus.OutboundMessage) ([]byte, error) {
+	data := webhookTemplateData{
+		Channel: msg.Channel,
+		ChatID:  msg.ChatID,
+		Content: msg.Content,
+	}
+	if entry, ok := c.payloads.Load(msg.ChatID); ok {
+		if p := entry.(*webhookPayload); time.Since(p.received) < webhookPayloadMaxAge {
+			data.Inbound = p.payload
+		} else {
+			c.payloads.CompareAndDelete(msg.ChatID, entry)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render outbound template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *WebhookChannel) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBodySize))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !c.authorize(r, body) {
+		logger.WarnCF("webhook", "Rejected unauthenticated webhook request", map[string]any{
+			"remote": r.RemoteAddr,
+		})
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// UseNumber keeps large numeric IDs (chat/user IDs) from losing precision
+	var payload any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	content := lookupJSONPathString(payload, c.config.ContentField)
+	senderID := lookupJSONPathString(payload, c.config.SenderField)
+	chatID := lookupJSONPathString(payload, c.config.ChatField)
+	if chatID == "" {
+		chatID = senderID
+	}
+	if senderID == "" {
+		senderID = chatID
+	}
+
+	if content == "" || chatID == "" {
+		http.Error(w, "Missing content or sender", http.StatusBadRequest)
+		return
+	}
+
+	if !c.IsAllowed(senderID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	c.storePayload(chatID, payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte(`{"ok":true}`))
+
+	metadata := map[string]string{
+		"peer_kind": "direct",
+		"peer_id":   chatID,
+	}
+
+	logger.InfoCF("webhook", "Received webhook message", map[string]any{
+		"sender":  senderID,
+		"chat_id": chatID,
+		"length":  len(content),
+	})
+
+	c.HandleMessage(senderID, chatID, content, nil, metadata)
+}
+
+// storePayload keeps payload for the replies to chatID, dropping those of
+// chats that have been quiet for longer than webhookPayloadMaxAge.
+func (c *WebhookChannel) storePayload(chatID string, payload any) {
+	now := time.Now()
+	c.payloads.Range(func(key, value any) bool {
+		if now.Sub(value.(*webhookPayload).received) >= webhookPayloadMaxAge {
+			c.payloads.CompareAndDelete(key, value)
+		}
+		return true
+	})
+	c.payloads.Store(chatID, &webhookPayload{payload: payload, received: now})
+}
+
+// authorize accepts either a shared secret in X-Webhook-Secret or an
+// HMAC-SHA256 of the body in X-Hub-Signature-256 ("sha256=<hex>"), the
+// format used by GitHub and many other webhook senders.
+func (c *WebhookChannel) authorize(r *http.Request, body []byte) bool {
+	if c.config.Secret == "" {
+		return true
+	}
+
+	if token := r.Header.Get("X-Webhook-Secret"); token != "" {
+		return hmac.Equal([]byte(token), []byte(c.config.Secret))
+	}
+
+	sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if sig == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(webhookSign(c.config.Secret, body)))
+}
+
+func webhookSign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// lookupJSONPath resolves a small JSONPath subset against decoded JSON:
+// dotted keys and array indexes, e.g. "$.message.from.id" or "$.items[0].text".
+func lookupJSONPath(data any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	cur := data
+
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = obj[key]; !ok {
+				return nil, false
+			}
+
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			token := strings.Trim(path[1:end], `'"`)
+			path = path[end+1:]
+
+			switch v := cur.(type) {
+			case []any:
+				idx, err := strconv.Atoi(token)
+				if err != nil || idx < 0 || idx >= len(v) {
+					return nil, false
+				}
+				cur = v[idx]
+			case map[string]any:
+				var ok bool
+				if cur, ok = v[token]; !ok {
+					return nil, false
+				}
+			default:
+				return nil, false
+			}
+
+		default:
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+// lookupJSONPathString is lookupJSONPath with scalar values stringified.
+func lookupJSONPathString(data any, path string) string {
+	if path == "" {
+		return ""
+	}
+	v, ok := lookupJSONPath(data, path)
+	if !ok || v == nil {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
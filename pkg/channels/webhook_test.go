@@ -0,0 +1,228 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var payload any
+	raw := `{"message": {"from": {"id": 12345678901234567}, "text": "hi"},
+		"items": [{"v": "a"}, {"v": "b"}], "ok": true}`
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "$.message.text", want: "hi"},
+		{path: "$.message.from.id", want: "12345678901234567"},
+		{path: "$.items[1].v", want: "b"},
+		{path: "$['message']['text']", want: "hi"},
+		{path: "$.ok", want: "true"},
+		{path: "$.message.missing", want: ""},
+		{path: "$.items[5].v", want: ""},
+		{path: "$.message.from", want: `{"id":12345678901234567}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := lookupJSONPathString(payload, tt.path); got != tt.want {
+				t.Errorf("lookupJSONPathString(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestWebhookChannel(t *testing.T, cfg config.WebhookConfig) (*WebhookChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	if cfg.OutboundURL == "" {
+		cfg.OutboundURL = "http://127.0.0.1:0/unused"
+	}
+	if cfg.WebhookHost == "" {
+		cfg.WebhookHost = "127.0.0.1"
+	}
+	ch, err := NewWebhookChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("NewWebhookChannel() error = %v", err)
+	}
+	return ch, msgBus
+}
+
+func TestNewWebhookChannelRequiresSecret(t *testing.T) {
+	tests := []struct {
+		host    string
+		secret  string
+		wantErr bool
+	}{
+		{host: "0.0.0.0", wantErr: true},
+		{host: "", wantErr: true},
+		{host: "0.0.0.0", secret: "s3cret"},
+		{host: "127.0.0.1"},
+		{host: "localhost"},
+		{host: "::1"},
+	}
+	for _, tt := range tests {
+		cfg := config.WebhookConfig{WebhookHost: tt.host, Secret: tt.secret, OutboundURL: "http://localhost"}
+		_, err := NewWebhookChannel(cfg, bus.NewMessageBus())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewWebhookChannel(host %q, secret %q) error = %v, wantErr %v",
+				tt.host, tt.secret, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Secret:       "s3cret",
+		SenderField:  "$.user.id",
+		ChatField:    "$.conversation",
+		ContentField: "$.text",
+	}
+	body := `{"user": {"id": "u1"}, "conversation": "c1", "text": "hello"}`
+
+	t.Run("valid hmac signature", func(t *testing.T) {
+		ch, msgBus := newTestWebhookChannel(t, cfg)
+		req := httptest.NewRequest(http.MethodPost, "/webhook/generic", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+webhookSign("s3cret", []byte(body)))
+		rec := httptest.NewRecorder()
+
+		ch.webhookHandler(rec, req)
+
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatal("expected inbound message")
+		}
+		if msg.SenderID != "u1" || msg.ChatID != "c1" || msg.Content != "hello" {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	})
+
+	t.Run("shared secret header", func(t *testing.T) {
+		ch, _ := newTestWebhookChannel(t, cfg)
+		req := httptest.NewRequest(http.MethodPost, "/webhook/generic", strings.NewReader(body))
+		req.Header.Set("X-Webhook-Secret", "s3cret")
+		rec := httptest.NewRecorder()
+
+		ch.webhookHandler(rec, req)
+
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+		}
+	})
+
+	t.Run("bad signature is rejected", func(t *testing.T) {
+		ch, _ := newTestWebhookChannel(t, cfg)
+		req := httptest.NewRequest(http.MethodPost, "/webhook/generic", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256=deadbeef")
+		rec := httptest.NewRecorder()
+
+		ch.webhookHandler(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("missing content", func(t *testing.T) {
+		ch, _ := newTestWebhookChannel(t, config.WebhookConfig{SenderField: "$.user.id"})
+		req := httptest.NewRequest(http.MethodPost, "/webhook/generic", strings.NewReader(`{"user": {"id": "u1"}}`))
+		rec := httptest.NewRecorder()
+
+		ch.webhookHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestWebhookSendRendersTemplate(t *testing.T) {
+	var gotBody, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ch, _ := newTestWebhookChannel(t, config.WebhookConfig{
+		SenderField:      "$.user",
+		ContentField:     "$.text",
+		OutboundURL:      server.URL,
+		OutboundHeaders:  map[string]string{"Authorization": "Bearer abc"},
+		OutboundTemplate: `{"room": {{json .ChatID}}, "reply": {{json .Content}}, "thread": {{json .Inbound.thread}}}`,
+	})
+	ch.setRunning(true)
+
+	// Seed the inbound payload so the template can reference .Inbound
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"user": "u1", "text": "q", "thread": "t9"}`))
+	ch.webhookHandler(httptest.NewRecorder(), req)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{Channel: "webhook", ChatID: "u1", Content: `say "hi"`})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal([]byte(gotBody), &got); err != nil {
+		t.Fatalf("outbound body is not valid JSON: %v (%s)", err, gotBody)
+	}
+	if got["room"] != "u1" || got["reply"] != `say "hi"` || got["thread"] != "t9" {
+		t.Errorf("unexpected outbound body: %v", got)
+	}
+	if gotAuth != "Bearer abc" {
+		t.Errorf("Authorization header = %q", gotAuth)
+	}
+}
+
+func TestWebhookPayloadsExpire(t *testing.T) {
+	ch, _ := newTestWebhookChannel(t, config.WebhookConfig{
+		SenderField:      "$.user",
+		ContentField:     "$.text",
+		OutboundURL:      "http://localhost",
+		OutboundTemplate: `{{json .Inbound}}`,
+	})
+	stale := &webhookPayload{payload: map[string]any{"thread": "t1"}, received: time.Now().Add(-webhookPayloadMaxAge)}
+	ch.payloads.Store("quiet", stale)
+	ch.payloads.Store("old", stale)
+
+	// A reply to a chat whose payload is too old doesn't get it
+	body, err := ch.renderOutbound(bus.OutboundMessage{ChatID: "old", Content: "hi"})
+	if err != nil || string(body) != "null" {
+		t.Errorf("renderOutbound() = %s, %v", body, err)
+	}
+	if _, ok := ch.payloads.Load("old"); ok {
+		t.Error("expired payload kept after a reply")
+	}
+
+	// A new payload drops the other expired ones
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"user": "u1", "text": "q"}`))
+	ch.webhookHandler(httptest.NewRecorder(), req)
+	if _, ok := ch.payloads.Load("quiet"); ok {
+		t.Error("expired payload kept after a new message")
+	}
+	if body, _ := ch.renderOutbound(bus.OutboundMessage{ChatID: "u1"}); !strings.Contains(string(body), `"text":"q"`) {
+		t.Errorf("renderOutbound() = %s", body)
+	}
+}
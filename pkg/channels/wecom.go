@@ -7,18 +7,10 @@ package channels
 import (
 	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/sha1"
-	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
-	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -38,8 +30,18 @@ type WeComBotChannel struct {
 	cancel        context.CancelFunc
 	processedMsgs map[string]bool // Message deduplication: msg_id -> processed
 	msgMu         sync.RWMutex
+	mediaDir      string
+	progress      *wecomProgress
+	replay        *weComReplayGuard
 }
 
+var (
+	_ Channel         = (*WeComBotChannel)(nil)
+	_ ProgressChannel = (*WeComBotChannel)(nil)
+	_ MediaChannel    = (*WeComBotChannel)(nil)
+	_ WebhookReceiver = (*WeComBotChannel)(nil)
+)
+
 // WeComBotMessage represents the JSON message structure from WeCom Bot (AIBOT)
 type WeComBotMessage struct {
 	MsgID    string `json:"msgid"`
@@ -102,6 +104,8 @@ func NewWeComBotChannel(cfg config.WeComConfig, messageBus *bus.MessageBus) (*We
 		BaseChannel:   base,
 		config:        cfg,
 		processedMsgs: make(map[string]bool),
+		progress:      newWeComProgress(),
+		replay:        newWeComReplayGuard(cfg.TimestampWindow),
 	}, nil
 }
 
@@ -110,6 +114,15 @@ func (c *WeComBotChannel) Name() string {
 	return "wecom"
 }
 
+// WebhookAddr returns the callback server's listen address and path
+func (c *WeComBotChannel) WebhookAddr() (string, string) {
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/wecom"
+	}
+	return fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort), path
+}
+
 // Start initializes the WeCom Bot channel with HTTP webhook server
 func (c *WeComBotChannel) Start(ctx context.Context) error {
 	logger.InfoC("wecom", "Starting WeCom Bot channel...")
@@ -118,16 +131,12 @@ func (c *WeComBotChannel) Start(ctx context.Context) error {
 
 	// Setup HTTP server for webhook
 	mux := http.NewServeMux()
-	webhookPath := c.config.WebhookPath
-	if webhookPath == "" {
-		webhookPath = "/webhook/wecom"
-	}
+	addr, webhookPath := c.WebhookAddr()
 	mux.HandleFunc(webhookPath, c.handleWebhook)
 
 	// Health check endpoint
 	mux.HandleFunc("/health/wecom", c.handleHealth)
 
-	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
 	c.server = &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -141,10 +150,11 @@ func (c *WeComBotChannel) Start(ctx context.Context) error {
 
 	// Start server in goroutine
 	go func() {
-		if err := c.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := c.listenAndServe(c.server); err != nil && err != http.ErrServerClosed {
 			logger.ErrorCF("wecom", "HTTP server error", map[string]any{
 				"error": err.Error(),
 			})
+			c.setRunning(false) // Let the manager restart the channel
 		}
 	}()
 
@@ -179,10 +189,19 @@ func (c *WeComBotChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	}
 
 	logger.DebugCF("wecom", "Sending message via webhook", map[string]any{
-		"chat_id": msg.ChatID,
-		"preview": utils.Truncate(msg.Content, 100),
+		"chat_id":  msg.ChatID,
+		"preview":  utils.Truncate(msg.Content, 100),
+		"progress": msg.Progress,
 	})
 
+	if msg.Progress {
+		if !c.progress.allow(msg.ChatID, msg.Content) {
+			return nil
+		}
+	} else {
+		c.progress.finish(msg.ChatID)
+	}
+
 	return c.sendWebhookReply(ctx, msg.ChatID, msg.Content)
 }
 
@@ -205,97 +224,26 @@ func (c *WeComBotChannel) handleWebhook(w http.ResponseWriter, r *http.Request)
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
-// handleVerification handles the URL verification request from WeCom
-func (c *WeComBotChannel) handleVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	msgSignature := query.Get("msg_signature")
-	timestamp := query.Get("timestamp")
-	nonce := query.Get("nonce")
-	echostr := query.Get("echostr")
-
-	if msgSignature == "" || timestamp == "" || nonce == "" || echostr == "" {
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature
-	if !WeComVerifySignature(c.config.Token, msgSignature, timestamp, nonce, echostr) {
-		logger.WarnC("wecom", "Signature verification failed")
-		http.Error(w, "Invalid signature", http.StatusForbidden)
-		return
-	}
-
-	// Decrypt echostr
-	// For AIBOT (智能机器人), receiveid should be empty string ""
-	// Reference: https://developer.work.weixin.qq.com/document/path/101033
-	decryptedEchoStr, err := WeComDecryptMessageWithVerify(echostr, c.config.EncodingAESKey, "")
-	if err != nil {
-		logger.ErrorCF("wecom", "Failed to decrypt echostr", map[string]any{
-			"error": err.Error(),
-		})
-		http.Error(w, "Decryption failed", http.StatusInternalServerError)
-		return
+// callback returns the checks for the bot's callbacks. For AIBOT
+// (智能机器人) the receiveid is empty
+func (c *WeComBotChannel) callback() weComCallback {
+	return weComCallback{
+		component: "wecom",
+		token:     c.config.Token,
+		aesKey:    c.config.EncodingAESKey,
+		replay:    c.replay,
 	}
+}
 
-	// Remove BOM and whitespace as per WeCom documentation
-	// The response must be plain text without quotes, BOM, or newlines
-	decryptedEchoStr = strings.TrimSpace(decryptedEchoStr)
-	decryptedEchoStr = strings.TrimPrefix(decryptedEchoStr, "\xef\xbb\xbf") // Remove UTF-8 BOM
-	w.Write([]byte(decryptedEchoStr))
+// handleVerification handles the URL verification request from WeCom
+func (c *WeComBotChannel) handleVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	c.callback().verifyURL(w, r)
 }
 
 // handleMessageCallback handles incoming messages from WeCom
 func (c *WeComBotChannel) handleMessageCallback(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	msgSignature := query.Get("msg_signature")
-	timestamp := query.Get("timestamp")
-	nonce := query.Get("nonce")
-
-	if msgSignature == "" || timestamp == "" || nonce == "" {
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
-
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Parse XML to get encrypted message
-	var encryptedMsg struct {
-		XMLName    xml.Name `xml:"xml"`
-		ToUserName string   `xml:"ToUserName"`
-		Encrypt    string   `xml:"Encrypt"`
-		AgentID    string   `xml:"AgentID"`
-	}
-
-	if err = xml.Unmarshal(body, &encryptedMsg); err != nil {
-		logger.ErrorCF("wecom", "Failed to parse XML", map[string]any{
-			"error": err.Error(),
-		})
-		http.Error(w, "Invalid XML", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature
-	if !WeComVerifySignature(c.config.Token, msgSignature, timestamp, nonce, encryptedMsg.Encrypt) {
-		logger.WarnC("wecom", "Message signature verification failed")
-		http.Error(w, "Invalid signature", http.StatusForbidden)
-		return
-	}
-
-	// Decrypt message
-	// For AIBOT (智能机器人), receiveid should be empty string ""
-	// Reference: https://developer.work.weixin.qq.com/document/path/101033
-	decryptedMsg, err := WeComDecryptMessageWithVerify(encryptedMsg.Encrypt, c.config.EncodingAESKey, "")
-	if err != nil {
-		logger.ErrorCF("wecom", "Failed to decrypt message", map[string]any{
-			"error": err.Error(),
-		})
-		http.Error(w, "Decryption failed", http.StatusInternalServerError)
+	decryptedMsg, ok := c.callback().open(w, r)
+	if !ok {
 		return
 	}
 
@@ -309,8 +257,9 @@ func (c *WeComBotChannel) handleMessageCallback(ctx context.Context, w http.Resp
 		return
 	}
 
-	// Process the message asynchronously with context
-	go c.processMessage(ctx, msg)
+	// Process the message asynchronously. The request context ends with this
+	// handler, so processing (which may download media) uses the channel context
+	go c.processMessage(c.getContext(), msg)
 
 	// Return success response immediately
 	// WeCom Bot requires response within configured timeout (default 5 seconds)
@@ -369,20 +318,27 @@ func (c *WeComBotChannel) processMessage(ctx context.Context, msg WeComBotMessag
 
 	// Extract content based on message type
 	var content string
+	var mediaPaths []string
 	switch msg.MsgType {
 	case "text":
 		content = msg.Text.Content
 	case "voice":
-		content = msg.Voice.Content // Voice to text content
+		// AIBOT delivers voice already converted to text
+		content = fmt.Sprintf("[voice transcription: %s]", msg.Voice.Content)
+	case "image":
+		content = c.attachImage(ctx, msg.Image.URL, msg.MsgID+".jpg", &mediaPaths)
 	case "mixed":
-		// For mixed messages, concatenate text items
-		for _, item := range msg.Mixed.MsgItem {
-			if item.MsgType == "text" {
+		// For mixed messages, concatenate text items and download images
+		for i, item := range msg.Mixed.MsgItem {
+			switch item.MsgType {
+			case "text":
 				content += item.Text.Content
+			case "image":
+				content += c.attachImage(ctx, item.Image.URL, fmt.Sprintf("%s_%d.jpg", msg.MsgID, i), &mediaPaths)
 			}
 		}
-	case "image", "file":
-		// For image and file, we don't have text content
+	case "file":
+		// Files have no text content
 		content = ""
 	}
 
@@ -408,8 +364,30 @@ func (c *WeComBotChannel) processMessage(ctx context.Context, msg WeComBotMessag
 		"preview":       utils.Truncate(content, 50),
 	})
 
+	if c.config.ProgressiveReply && c.IsAllowed(senderID) {
+		c.startProgress(ctx, chatID)
+	}
+
 	// Handle the message through the base channel
-	c.HandleMessage(senderID, chatID, content, nil, metadata)
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
+}
+
+// attachImage downloads an image and returns the content marker for the agent
+func (c *WeComBotChannel) attachImage(ctx context.Context, imageURL, filename string, mediaPaths *[]string) string {
+	if imageURL == "" {
+		return "[image]"
+	}
+
+	imagePath, err := c.downloadImage(ctx, imageURL, filename)
+	if err != nil {
+		logger.WarnCF("wecom", "Failed to download image", map[string]any{
+			"error": err.Error(),
+		})
+		return "[image: download failed]"
+	}
+
+	*mediaPaths = append(*mediaPaths, imagePath)
+	return fmt.Sprintf("[image: %s]", imagePath)
 }
 
 // sendWebhookReply sends a reply using the webhook URL
@@ -477,129 +455,3 @@ func (c *WeComBotChannel) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
-
-// WeCom common utilities for both WeCom Bot and WeCom App
-// The following functions were moved from wecom_common.go
-
-// WeComVerifySignature verifies the message signature for WeCom
-// This is a common function used by both WeCom Bot and WeCom App
-func WeComVerifySignature(token, msgSignature, timestamp, nonce, msgEncrypt string) bool {
-	if token == "" {
-		return true // Skip verification if token is not set
-	}
-
-	// Sort parameters
-	params := []string{token, timestamp, nonce, msgEncrypt}
-	sort.Strings(params)
-
-	// Concatenate
-	str := strings.Join(params, "")
-
-	// SHA1 hash
-	hash := sha1.Sum([]byte(str))
-	expectedSignature := fmt.Sprintf("%x", hash)
-
-	return expectedSignature == msgSignature
-}
-
-// WeComDecryptMessage decrypts the encrypted message using AES
-// This is a common function used by both WeCom Bot and WeCom App
-// For AIBOT, receiveid should be the aibotid; for other apps, it should be corp_id
-func WeComDecryptMessage(encryptedMsg, encodingAESKey string) (string, error) {
-	return WeComDecryptMessageWithVerify(encryptedMsg, encodingAESKey, "")
-}
-
-// WeComDecryptMessageWithVerify decrypts the encrypted message and optionally verifies receiveid
-// receiveid: for AIBOT use aibotid, for WeCom App use corp_id. If empty, skip verification.
-func WeComDecryptMessageWithVerify(encryptedMsg, encodingAESKey, receiveid string) (string, error) {
-	if encodingAESKey == "" {
-		// No encryption, return as is (base64 decode)
-		decoded, err := base64.StdEncoding.DecodeString(encryptedMsg)
-		if err != nil {
-			return "", err
-		}
-		return string(decoded), nil
-	}
-
-	// Decode AES key (base64)
-	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
-	if err != nil {
-		return "", fmt.Errorf("failed to decode AES key: %w", err)
-	}
-
-	// Decode encrypted message
-	cipherText, err := base64.StdEncoding.DecodeString(encryptedMsg)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode message: %w", err)
-	}
-
-	// AES decrypt
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	if len(cipherText) < aes.BlockSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-
-	// IV is the first 16 bytes of AESKey
-	iv := aesKey[:aes.BlockSize]
-	mode := cipher.NewCBCDecrypter(block, iv)
-	plainText := make([]byte, len(cipherText))
-	mode.CryptBlocks(plainText, cipherText)
-
-	// Remove PKCS7 padding
-	plainText, err = pkcs7UnpadWeCom(plainText)
-	if err != nil {
-		return "", fmt.Errorf("failed to unpad: %w", err)
-	}
-
-	// Parse message structure
-	// Format: random(16) + msg_len(4) + msg + receiveid
-	if len(plainText) < 20 {
-		return "", fmt.Errorf("decrypted message too short")
-	}
-
-	msgLen := binary.BigEndian.Uint32(plainText[16:20])
-	if int(msgLen) > len(plainText)-20 {
-		return "", fmt.Errorf("invalid message length")
-	}
-
-	msg := plainText[20 : 20+msgLen]
-
-	// Verify receiveid if provided
-	if receiveid != "" && len(plainText) > 20+int(msgLen) {
-		actualReceiveID := string(plainText[20+msgLen:])
-		if actualReceiveID != receiveid {
-			return "", fmt.Errorf("receiveid mismatch: expected %s, got %s", receiveid, actualReceiveID)
-		}
-	}
-
-	return string(msg), nil
-}
-
-// pkcs7UnpadWeCom removes PKCS7 padding with validation
-// WeCom uses block size of 32 (not standard AES block size of 16)
-const wecomBlockSize = 32
-
-func pkcs7UnpadWeCom(data []byte) ([]byte, error) {
-	if len(data) == 0 {
-		return data, nil
-	}
-	padding := int(data[len(data)-1])
-	// WeCom uses 32-byte block size for PKCS7 padding
-	if padding == 0 || padding > wecomBlockSize {
-		return nil, fmt.Errorf("invalid padding size: %d", padding)
-	}
-	if padding > len(data) {
-		return nil, fmt.Errorf("padding size larger than data")
-	}
-	// Verify all padding bytes
-	for i := 0; i < padding; i++ {
-		if data[len(data)-1-i] != byte(padding) {
-			return nil, fmt.Errorf("invalid padding byte at position %d", i)
-		}
-	}
-	return data[:len(data)-padding], nil
-}
@@ -13,6 +13,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -21,26 +23,55 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 const (
 	wecomAPIBase = "https://qyapi.weixin.qq.com"
 )
 
+var (
+	reWeComImage         = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	reWeComBoldUnderline = regexp.MustCompile(`__([^_\n]+)__`)
+	reWeComItalicStar    = regexp.MustCompile(`(^|[^*])\*([^*\n]+)\*`)
+	reWeComItalicUnder   = regexp.MustCompile(`(^|[^\w])_([^_\n]+)_`)
+	reWeComStrike        = regexp.MustCompile(`~~([^~\n]+)~~`)
+	reWeComListItem      = regexp.MustCompile(`(?m)^([ \t]*)[-*+][ \t]+`)
+	reWeComTableDivider  = regexp.MustCompile(`(?m)^[ \t]*\|?[ \t]*:?-{3,}:?[ \t]*(\|[ \t]*:?-{3,}:?[ \t]*)+\|?\s*\n`)
+	reWeComRule          = regexp.MustCompile(`(?m)^[ \t]*(-{3,}|\*{3,}|_{3,})[ \t]*$`)
+)
+
 // WeComAppChannel implements the Channel interface for WeCom App (企业微信自建应用)
 type WeComAppChannel struct {
 	*BaseChannel
 	config        config.WeComAppConfig
 	server        *http.Server
-	accessToken   string
-	tokenExpiry   time.Time
-	tokenMu       sync.RWMutex
+	tokens        *wecomTokenManager
 	ctx           context.Context
 	cancel        context.CancelFunc
 	processedMsgs map[string]bool // Message deduplication: msg_id -> processed
 	msgMu         sync.RWMutex
+	apiBase       string
+	mediaDir      string
+	transcriber   voice.Transcriber
+	uploads       map[string]wecomUploadedMedia // Uploaded temporary material, keyed by file identity
+	uploadMu      sync.Mutex
+	progress      *wecomProgress
+	replay        *weComReplayGuard
+
+	externalOwners map[string]string // External contact or customer group -> owning member
+	externalMu     sync.RWMutex
 }
 
+var (
+	_ Channel             = (*WeComAppChannel)(nil)
+	_ ProgressChannel     = (*WeComAppChannel)(nil)
+	_ MediaChannel        = (*WeComAppChannel)(nil)
+	_ TranscribingChannel = (*WeComAppChannel)(nil)
+	_ WebhookReceiver     = (*WeComAppChannel)(nil)
+	_ VoiceReplyChannel   = (*WeComAppChannel)(nil)
+)
+
 // WeComXMLMessage represents the XML message structure from WeCom
 type WeComXMLMessage struct {
 	XMLName      xml.Name `xml:"xml"`
@@ -54,6 +85,7 @@ type WeComXMLMessage struct {
 	PicUrl       string   `xml:"PicUrl"`
 	MediaId      string   `xml:"MediaId"`
 	Format       string   `xml:"Format"`
+	Recognition  string   `xml:"Recognition"` // Voice recognition result, if enabled for the app
 	ThumbMediaId string   `xml:"ThumbMediaId"`
 	LocationX    float64  `xml:"Location_X"`
 	LocationY    float64  `xml:"Location_Y"`
@@ -64,6 +96,18 @@ type WeComXMLMessage struct {
 	Url          string   `xml:"Url"`
 	Event        string   `xml:"Event"`
 	EventKey     string   `xml:"EventKey"`
+	Latitude     float64  `xml:"Latitude"`  // LOCATION event
+	Longitude    float64  `xml:"Longitude"` // LOCATION event
+	Precision    float64  `xml:"Precision"` // LOCATION event
+	TaskId       string   `xml:"TaskId"`    // template_card_event
+	CardType     string   `xml:"CardType"`  // template_card_event
+
+	// External contact (客户联系) fields
+	ChangeType     string `xml:"ChangeType"`
+	UserID         string `xml:"UserID"`
+	ExternalUserID string `xml:"ExternalUserID"`
+	ChatId         string `xml:"ChatId"`
+	UpdateDetail   string `xml:"UpdateDetail"`
 }
 
 // WeComTextMessage represents text message for sending
@@ -87,6 +131,36 @@ type WeComMarkdownMessage struct {
 	} `json:"markdown"`
 }
 
+// WeComTemplateCardMessage represents a button_interaction template card
+// for sending. Pressing a button triggers a template_card_event callback
+// carrying the button key.
+type WeComTemplateCardMessage struct {
+	ToUser       string            `json:"touser"`
+	MsgType      string            `json:"msgtype"`
+	AgentID      int64             `json:"agentid"`
+	TemplateCard WeComTemplateCard `json:"template_card"`
+}
+
+// WeComTemplateCard is the template_card payload of WeComTemplateCardMessage
+type WeComTemplateCard struct {
+	CardType  string `json:"card_type"`
+	MainTitle struct {
+		Title string `json:"title"`
+		Desc  string `json:"desc,omitempty"`
+	} `json:"main_title"`
+	SubTitleText string                    `json:"sub_title_text,omitempty"`
+	TaskID       string                    `json:"task_id"`
+	ButtonList   []WeComTemplateCardButton `json:"button_list"`
+}
+
+// WeComTemplateCardButton is a template card button. Style 1 is the
+// highlighted button, 2 the normal one and 3 the red one.
+type WeComTemplateCardButton struct {
+	Text  string `json:"text"`
+	Style int    `json:"style,omitempty"`
+	Key   string `json:"key"`
+}
+
 // WeComImageMessage represents image message for sending
 type WeComImageMessage struct {
 	ToUser  string `json:"touser"`
@@ -97,6 +171,32 @@ type WeComImageMessage struct {
 	} `json:"image"`
 }
 
+// WeComMediaMessage represents an image, voice, video or file message for sending
+// Exactly one of the media fields is set, matching MsgType
+type WeComMediaMessage struct {
+	ToUser  string         `json:"touser"`
+	MsgType string         `json:"msgtype"`
+	AgentID int64          `json:"agentid"`
+	Image   *WeComMediaRef `json:"image,omitempty"`
+	Voice   *WeComMediaRef `json:"voice,omitempty"`
+	Video   *WeComMediaRef `json:"video,omitempty"`
+	File    *WeComMediaRef `json:"file,omitempty"`
+}
+
+// WeComMediaRef references uploaded temporary material
+type WeComMediaRef struct {
+	MediaID string `json:"media_id"`
+}
+
+// WeComUploadMediaResponse represents the media upload API response
+type WeComUploadMediaResponse struct {
+	ErrCode   int    `json:"errcode"`
+	ErrMsg    string `json:"errmsg"`
+	Type      string `json:"type"`
+	MediaID   string `json:"media_id"`
+	CreatedAt string `json:"created_at"`
+}
+
 // WeComAccessTokenResponse represents the access token API response
 type WeComAccessTokenResponse struct {
 	ErrCode     int    `json:"errcode"`
@@ -125,11 +225,47 @@ func NewWeComAppChannel(cfg config.WeComAppConfig, messageBus *bus.MessageBus) (
 
 	base := NewBaseChannel("wecom_app", cfg, messageBus, cfg.AllowFrom)
 
-	return &WeComAppChannel{
+	c := &WeComAppChannel{
 		BaseChannel:   base,
 		config:        cfg,
 		processedMsgs: make(map[string]bool),
-	}, nil
+		apiBase:       wecomAPIBase,
+		uploads:       make(map[string]wecomUploadedMedia),
+		progress:      newWeComProgress(),
+		replay:        newWeComReplayGuard(cfg.TimestampWindow),
+
+		externalOwners: make(map[string]string),
+	}
+	c.tokens = newWeComTokenManager(c.fetchAccessToken)
+	return c, nil
+}
+
+// weComAppSettings picks the settings of the app channel: the wecom block
+// in app mode, or the older standalone wecom_app block.
+func weComAppSettings(c *config.ChannelsConfig) (config.WeComAppConfig, error) {
+	if mode, err := c.WeCom.ResolveMode(); c.WeCom.Enabled && mode == config.WeComModeApp {
+		if err != nil {
+			return config.WeComAppConfig{}, err
+		}
+		if c.WeComApp.Enabled {
+			return config.WeComAppConfig{}, fmt.Errorf(
+				"wecom is in app mode and wecom_app is enabled too; keep the settings in wecom and disable wecom_app")
+		}
+		return c.WeCom.App(), nil
+	}
+
+	logger.WarnC("wecom_app", `The wecom_app block is deprecated; move its settings into wecom with "mode": "app"`)
+	return c.WeComApp, nil
+}
+
+// VoiceFormats returns the only format WeCom sends as a voice message
+func (c *WeComAppChannel) VoiceFormats() []string {
+	return []string{"amr"}
+}
+
+// SetTranscriber sets the voice transcriber used for voice messages
+func (c *WeComAppChannel) SetTranscriber(transcriber voice.Transcriber) {
+	c.transcriber = transcriber
 }
 
 // Name returns the channel name
@@ -137,6 +273,15 @@ func (c *WeComAppChannel) Name() string {
 	return "wecom_app"
 }
 
+// WebhookAddr returns the callback server's listen address and path
+func (c *WeComAppChannel) WebhookAddr() (string, string) {
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/wecom-app"
+	}
+	return fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort), path
+}
+
 // Start initializes the WeCom App channel with HTTP webhook server
 func (c *WeComAppChannel) Start(ctx context.Context) error {
 	logger.InfoC("wecom_app", "Starting WeCom App channel...")
@@ -144,7 +289,7 @@ func (c *WeComAppChannel) Start(ctx context.Context) error {
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
 	// Get initial access token
-	if err := c.refreshAccessToken(); err != nil {
+	if _, err := c.tokens.get(c.ctx); err != nil {
 		logger.WarnCF("wecom_app", "Failed to get initial access token", map[string]any{
 			"error": err.Error(),
 		})
@@ -155,16 +300,12 @@ func (c *WeComAppChannel) Start(ctx context.Context) error {
 
 	// Setup HTTP server for webhook
 	mux := http.NewServeMux()
-	webhookPath := c.config.WebhookPath
-	if webhookPath == "" {
-		webhookPath = "/webhook/wecom-app"
-	}
+	addr, webhookPath := c.WebhookAddr()
 	mux.HandleFunc(webhookPath, c.handleWebhook)
 
 	// Health check endpoint
 	mux.HandleFunc("/health/wecom-app", c.handleHealth)
 
-	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
 	c.server = &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -178,10 +319,11 @@ func (c *WeComAppChannel) Start(ctx context.Context) error {
 
 	// Start server in goroutine
 	go func() {
-		if err := c.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := c.listenAndServe(c.server); err != nil && err != http.ErrServerClosed {
 			logger.ErrorCF("wecom_app", "HTTP server error", map[string]any{
 				"error": err.Error(),
 			})
+			c.setRunning(false) // Let the manager restart the channel
 		}
 	}()
 
@@ -213,17 +355,47 @@ func (c *WeComAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return fmt.Errorf("wecom_app channel not running")
 	}
 
-	accessToken := c.getAccessToken()
-	if accessToken == "" {
-		return fmt.Errorf("no valid access token available")
-	}
-
 	logger.DebugCF("wecom_app", "Sending message", map[string]any{
-		"chat_id": msg.ChatID,
-		"preview": utils.Truncate(msg.Content, 100),
+		"chat_id":     msg.ChatID,
+		"preview":     utils.Truncate(msg.Content, 100),
+		"media_count": len(msg.Media),
+		"progress":    msg.Progress,
 	})
 
-	return c.sendTextMessage(ctx, accessToken, msg.ChatID, msg.Content)
+	if msg.Progress {
+		if !c.progress.allow(msg.ChatID, msg.Content) {
+			return nil
+		}
+		return c.sendTextMessage(ctx, msg.ChatID, msg.Content)
+	}
+	c.progress.finish(msg.ChatID)
+
+	if isWeComExternalUser(msg.ChatID) || isWeComExternalChat(msg.ChatID) {
+		// Customer messages can't carry template cards
+		content := msg.Content
+		if len(msg.Buttons) > 0 {
+			content = strings.TrimSpace(content + "\n\n" + buttonsText(c.Language(), msg.Buttons))
+		}
+		return c.sendExternalMessage(ctx, msg.ChatID, content, msg.Media)
+	}
+
+	if len(msg.Buttons) > 0 {
+		if err := c.sendTemplateCard(ctx, msg.ChatID, msg.Content, msg.Buttons); err != nil {
+			return err
+		}
+	} else if msg.Content != "" || len(msg.Media) == 0 {
+		if err := c.sendReply(ctx, msg.ChatID, msg.Content); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range msg.Media {
+		if err := c.sendMediaFile(ctx, msg.ChatID, path); err != nil {
+			return fmt.Errorf("failed to send %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	return nil
 }
 
 // handleWebhook handles incoming webhook requests from WeCom
@@ -256,121 +428,27 @@ func (c *WeComAppChannel) handleWebhook(w http.ResponseWriter, r *http.Request)
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
-// handleVerification handles the URL verification request from WeCom
-func (c *WeComAppChannel) handleVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	msgSignature := query.Get("msg_signature")
-	timestamp := query.Get("timestamp")
-	nonce := query.Get("nonce")
-	echostr := query.Get("echostr")
-
-	logger.DebugCF("wecom_app", "Handling verification request", map[string]any{
-		"msg_signature": msgSignature,
-		"timestamp":     timestamp,
-		"nonce":         nonce,
-		"echostr":       echostr,
-		"corp_id":       c.config.CorpID,
-	})
-
-	if msgSignature == "" || timestamp == "" || nonce == "" || echostr == "" {
-		logger.ErrorC("wecom_app", "Missing parameters in verification request")
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature
-	if !WeComVerifySignature(c.config.Token, msgSignature, timestamp, nonce, echostr) {
-		logger.WarnCF("wecom_app", "Signature verification failed", map[string]any{
-			"token":         c.config.Token,
-			"msg_signature": msgSignature,
-			"timestamp":     timestamp,
-			"nonce":         nonce,
-		})
-		http.Error(w, "Invalid signature", http.StatusForbidden)
-		return
+// callback returns the checks for the app's callbacks. For WeCom App
+// (自建应用) the receiveid is the corp ID
+func (c *WeComAppChannel) callback() weComCallback {
+	return weComCallback{
+		component: "wecom_app",
+		token:     c.config.Token,
+		aesKey:    c.config.EncodingAESKey,
+		receiveID: c.config.CorpID,
+		replay:    c.replay,
 	}
+}
 
-	logger.DebugC("wecom_app", "Signature verification passed")
-
-	// Decrypt echostr with CorpID verification
-	// For WeCom App (自建应用), receiveid should be corp_id
-	logger.DebugCF("wecom_app", "Attempting to decrypt echostr", map[string]any{
-		"encoding_aes_key": c.config.EncodingAESKey,
-		"corp_id":          c.config.CorpID,
-	})
-	decryptedEchoStr, err := WeComDecryptMessageWithVerify(echostr, c.config.EncodingAESKey, c.config.CorpID)
-	if err != nil {
-		logger.ErrorCF("wecom_app", "Failed to decrypt echostr", map[string]any{
-			"error":            err.Error(),
-			"encoding_aes_key": c.config.EncodingAESKey,
-			"corp_id":          c.config.CorpID,
-		})
-		http.Error(w, "Decryption failed", http.StatusInternalServerError)
-		return
-	}
-
-	logger.DebugCF("wecom_app", "Successfully decrypted echostr", map[string]any{
-		"decrypted": decryptedEchoStr,
-	})
-
-	// Remove BOM and whitespace as per WeCom documentation
-	// The response must be plain text without quotes, BOM, or newlines
-	decryptedEchoStr = strings.TrimSpace(decryptedEchoStr)
-	decryptedEchoStr = strings.TrimPrefix(decryptedEchoStr, "\xef\xbb\xbf") // Remove UTF-8 BOM
-	w.Write([]byte(decryptedEchoStr))
+// handleVerification handles the URL verification request from WeCom
+func (c *WeComAppChannel) handleVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	c.callback().verifyURL(w, r)
 }
 
 // handleMessageCallback handles incoming messages from WeCom
 func (c *WeComAppChannel) handleMessageCallback(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	msgSignature := query.Get("msg_signature")
-	timestamp := query.Get("timestamp")
-	nonce := query.Get("nonce")
-
-	if msgSignature == "" || timestamp == "" || nonce == "" {
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
-
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Parse XML to get encrypted message
-	var encryptedMsg struct {
-		XMLName    xml.Name `xml:"xml"`
-		ToUserName string   `xml:"ToUserName"`
-		Encrypt    string   `xml:"Encrypt"`
-		AgentID    string   `xml:"AgentID"`
-	}
-
-	if err = xml.Unmarshal(body, &encryptedMsg); err != nil {
-		logger.ErrorCF("wecom_app", "Failed to parse XML", map[string]any{
-			"error": err.Error(),
-		})
-		http.Error(w, "Invalid XML", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature
-	if !WeComVerifySignature(c.config.Token, msgSignature, timestamp, nonce, encryptedMsg.Encrypt) {
-		logger.WarnC("wecom_app", "Message signature verification failed")
-		http.Error(w, "Invalid signature", http.StatusForbidden)
-		return
-	}
-
-	// Decrypt message with CorpID verification
-	// For WeCom App (自建应用), receiveid should be corp_id
-	decryptedMsg, err := WeComDecryptMessageWithVerify(encryptedMsg.Encrypt, c.config.EncodingAESKey, c.config.CorpID)
-	if err != nil {
-		logger.ErrorCF("wecom_app", "Failed to decrypt message", map[string]any{
-			"error": err.Error(),
-		})
-		http.Error(w, "Decryption failed", http.StatusInternalServerError)
+	decryptedMsg, ok := c.callback().open(w, r)
+	if !ok {
 		return
 	}
 
@@ -384,8 +462,9 @@ func (c *WeComAppChannel) handleMessageCallback(ctx context.Context, w http.Resp
 		return
 	}
 
-	// Process the message with context
-	go c.processMessage(ctx, msg)
+	// Process the message asynchronously. The request context ends with this
+	// handler, so processing (which may download media) uses the channel context
+	go c.processMessage(c.getContext(), msg)
 
 	// Return success response immediately
 	// WeCom App requires response within configured timeout (default 5 seconds)
@@ -394,7 +473,12 @@ func (c *WeComAppChannel) handleMessageCallback(ctx context.Context, w http.Resp
 
 // processMessage processes the received message
 func (c *WeComAppChannel) processMessage(ctx context.Context, msg WeComXMLMessage) {
-	// Skip non-text messages for now (can be extended)
+	if msg.MsgType == "event" {
+		c.handleEvent(ctx, msg)
+		return
+	}
+
+	// Only text, image and voice messages are forwarded to the agent
 	if msg.MsgType != "text" && msg.MsgType != "image" && msg.MsgType != "voice" {
 		logger.DebugCF("wecom_app", "Skipping non-supported message type", map[string]any{
 			"msg_type": msg.MsgType,
@@ -405,29 +489,23 @@ func (c *WeComAppChannel) processMessage(ctx context.Context, msg WeComXMLMessag
 	// Message deduplication: Use msg_id to prevent duplicate processing
 	// As per WeCom documentation, use msg_id for deduplication
 	msgID := fmt.Sprintf("%d", msg.MsgId)
-	c.msgMu.Lock()
-	if c.processedMsgs[msgID] {
-		c.msgMu.Unlock()
+	if !c.markProcessed(msgID) {
 		logger.DebugCF("wecom_app", "Skipping duplicate message", map[string]any{
 			"msg_id": msgID,
 		})
 		return
 	}
-	c.processedMsgs[msgID] = true
-	c.msgMu.Unlock()
-
-	// Clean up old messages periodically (keep last 1000)
-	if len(c.processedMsgs) > 1000 {
-		c.msgMu.Lock()
-		c.processedMsgs = make(map[string]bool)
-		c.msgMu.Unlock()
-	}
 
 	senderID := msg.FromUserName
-	chatID := senderID // WeCom App uses user ID as chat ID for direct messages
+	chatID := senderID // Direct messages use the user ID as chat ID
+	peerKind := "direct"
+	if msg.ChatId != "" {
+		// Messages from customer group chats carry the group's chat ID
+		chatID = msg.ChatId
+		peerKind = "group"
+	}
 
 	// Build metadata
-	// WeCom App only supports direct messages (private chat)
 	metadata := map[string]string{
 		"msg_type":    msg.MsgType,
 		"msg_id":      fmt.Sprintf("%d", msg.MsgId),
@@ -435,25 +513,95 @@ func (c *WeComAppChannel) processMessage(ctx context.Context, msg WeComXMLMessag
 		"platform":    "wecom_app",
 		"media_id":    msg.MediaId,
 		"create_time": fmt.Sprintf("%d", msg.CreateTime),
-		"peer_kind":   "direct",
-		"peer_id":     senderID,
+		"peer_kind":   peerKind,
+		"peer_id":     chatID,
+	}
+	if peerKind == "group" {
+		metadata["sender_id"] = senderID
+	}
+	if isWeComExternalUser(senderID) {
+		metadata["external"] = "true"
 	}
 
 	content := msg.Content
+	var mediaPaths []string
+
+	switch msg.MsgType {
+	case "image":
+		imagePath, err := c.downloadMedia(ctx, msg.MediaId, msgID+".jpg")
+		if err != nil {
+			logger.WarnCF("wecom_app", "Failed to download image", map[string]any{
+				"media_id": msg.MediaId,
+				"error":    err.Error(),
+			})
+			content = "[image: download failed]"
+		} else {
+			mediaPaths = append(mediaPaths, imagePath)
+			content = fmt.Sprintf("[image: %s]", imagePath)
+		}
+
+	case "voice":
+		// Prefer WeCom's own speech recognition when it is enabled for the app
+		if msg.Recognition != "" {
+			content = fmt.Sprintf("[voice transcription: %s]", msg.Recognition)
+			break
+		}
+
+		format := strings.ToLower(msg.Format)
+		if format == "" {
+			format = "amr"
+		}
+		voicePath, err := c.downloadMedia(ctx, msg.MediaId, msgID+"."+format)
+		if err != nil {
+			logger.WarnCF("wecom_app", "Failed to download voice", map[string]any{
+				"media_id": msg.MediaId,
+				"error":    err.Error(),
+			})
+			content = "[voice: download failed]"
+		} else {
+			mediaPaths = append(mediaPaths, voicePath)
+			content = c.transcribe(ctx, voicePath)
+		}
+	}
 
 	logger.DebugCF("wecom_app", "Received message", map[string]any{
-		"sender_id": senderID,
-		"msg_type":  msg.MsgType,
-		"preview":   utils.Truncate(content, 50),
+		"sender_id":   senderID,
+		"msg_type":    msg.MsgType,
+		"media_count": len(mediaPaths),
+		"preview":     utils.Truncate(content, 50),
 	})
 
+	// Group-send replies need the owner's confirmation, so customers get no interim updates
+	external := isWeComExternalUser(chatID) || isWeComExternalChat(chatID)
+	if c.config.ProgressiveReply && !external && c.IsAllowed(senderID) {
+		c.startProgress(ctx, chatID)
+	}
+
 	// Handle the message through the base channel
-	c.HandleMessage(senderID, chatID, content, nil, metadata)
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
 }
 
-// tokenRefreshLoop periodically refreshes the access token
+// markProcessed records a message key and reports whether it is new
+func (c *WeComAppChannel) markProcessed(key string) bool {
+	c.msgMu.Lock()
+	defer c.msgMu.Unlock()
+
+	if c.processedMsgs[key] {
+		return false
+	}
+	c.processedMsgs[key] = true
+
+	// Clean up old messages periodically (keep last 1000)
+	if len(c.processedMsgs) > 1000 {
+		c.processedMsgs = map[string]bool{key: true}
+	}
+	return true
+}
+
+// tokenRefreshLoop replaces the access token before it expires, so sends
+// rarely wait for a refresh
 func (c *WeComAppChannel) tokenRefreshLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
 	for {
@@ -461,65 +609,68 @@ func (c *WeComAppChannel) tokenRefreshLoop() {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := c.refreshAccessToken(); err != nil {
+			if _, err := c.tokens.get(c.ctx); err != nil {
 				logger.ErrorCF("wecom_app", "Failed to refresh access token", map[string]any{
 					"error": err.Error(),
 				})
 			}
+			c.pruneUploadedMedia()
 		}
 	}
 }
 
-// refreshAccessToken gets a new access token from WeCom API
-func (c *WeComAppChannel) refreshAccessToken() error {
+// fetchAccessToken gets a new access token from WeCom API
+func (c *WeComAppChannel) fetchAccessToken(ctx context.Context) (string, time.Duration, error) {
 	apiURL := fmt.Sprintf("%s/cgi-bin/gettoken?corpid=%s&corpsecret=%s",
-		wecomAPIBase, url.QueryEscape(c.config.CorpID), url.QueryEscape(c.config.CorpSecret))
+		c.apiBase, url.QueryEscape(c.config.CorpID), url.QueryEscape(c.config.CorpSecret))
+
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
 
-	resp, err := http.Get(apiURL)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to request access token: %w", err)
+		return "", 0, fmt.Errorf("failed to request access token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return "", 0, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var tokenResp WeComAccessTokenResponse
 	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+		return "", 0, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if tokenResp.ErrCode != 0 {
-		return fmt.Errorf("API error: %s (code: %d)", tokenResp.ErrMsg, tokenResp.ErrCode)
+		return "", 0, &wecomAPIError{Code: tokenResp.ErrCode, Msg: tokenResp.ErrMsg}
 	}
 
-	c.tokenMu.Lock()
-	c.accessToken = tokenResp.AccessToken
-	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-300) * time.Second) // Refresh 5 minutes early
-	c.tokenMu.Unlock()
-
 	logger.DebugC("wecom_app", "Access token refreshed successfully")
-	return nil
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
 }
 
-// getAccessToken returns the current valid access token
+// getAccessToken returns the cached access token, or "" if it needs a refresh
 func (c *WeComAppChannel) getAccessToken() string {
-	c.tokenMu.RLock()
-	defer c.tokenMu.RUnlock()
+	return c.tokens.cached()
+}
 
-	if time.Now().After(c.tokenExpiry) {
-		return ""
+// sendReply sends the agent's reply in the configured reply_format
+func (c *WeComAppChannel) sendReply(ctx context.Context, userID, content string) error {
+	if c.config.ReplyFormat == "markdown" && content != "" {
+		return c.sendMarkdownMessage(ctx, userID, markdownToWeCom(content))
 	}
-
-	return c.accessToken
+	return c.sendTextMessage(ctx, userID, content)
 }
 
 // sendTextMessage sends a text message to a user
-func (c *WeComAppChannel) sendTextMessage(ctx context.Context, accessToken, userID, content string) error {
-	apiURL := fmt.Sprintf("%s/cgi-bin/message/send?access_token=%s", wecomAPIBase, accessToken)
-
+func (c *WeComAppChannel) sendTextMessage(ctx context.Context, userID, content string) error {
 	msg := WeComTextMessage{
 		ToUser:  userID,
 		MsgType: "text",
@@ -527,62 +678,80 @@ func (c *WeComAppChannel) sendTextMessage(ctx context.Context, accessToken, user
 	}
 	msg.Text.Content = content
 
-	jsonData, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
+	return c.postMessage(ctx, msg)
+}
 
-	// Use configurable timeout (default 5 seconds)
-	timeout := c.config.ReplyTimeout
-	if timeout <= 0 {
-		timeout = 5
+// sendMarkdownMessage sends a markdown message to a user
+func (c *WeComAppChannel) sendMarkdownMessage(ctx context.Context, userID, content string) error {
+	msg := WeComMarkdownMessage{
+		ToUser:  userID,
+		MsgType: "markdown",
+		AgentID: c.config.AgentID,
 	}
+	msg.Markdown.Content = content
 
-	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
-	defer cancel()
+	return c.postMessage(ctx, msg)
+}
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+// SupportsButtons reports that buttons are sent as template cards.
+func (c *WeComAppChannel) SupportsButtons() bool {
+	return true
+}
 
-	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+// sendTemplateCard sends content with buttons as a button_interaction card.
+// The first line of content becomes the title and the rest the card text.
+func (c *WeComAppChannel) sendTemplateCard(ctx context.Context, userID, content string, buttons []bus.Button) error {
+	title, desc, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if len([]rune(title)) > 36 {
+		// Keep the full line in the card text rather than losing it
+		desc = strings.TrimSpace(title + "\n" + desc)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	msg := WeComTemplateCardMessage{
+		ToUser:  userID,
+		MsgType: "template_card",
+		AgentID: c.config.AgentID,
 	}
-
-	var sendResp WeComSendMessageResponse
-	if err := json.Unmarshal(body, &sendResp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+	card := &msg.TemplateCard
+	card.CardType = "button_interaction"
+	card.MainTitle.Title = utils.Truncate(title, 36)
+	card.SubTitleText = utils.Truncate(strings.TrimSpace(desc), 112)
+	card.TaskID = fmt.Sprintf("picoclaw_%d", time.Now().UnixNano())
+	for _, b := range buttons {
+		style := 2
+		switch b.Style {
+		case "primary":
+			style = 1
+		case "danger":
+			style = 3
+		}
+		card.ButtonList = append(card.ButtonList, WeComTemplateCardButton{
+			Text:  utils.Truncate(b.Label, 10),
+			Style: style,
+			Key:   b.Value,
+		})
 	}
 
-	if sendResp.ErrCode != 0 {
-		return fmt.Errorf("API error: %s (code: %d)", sendResp.ErrMsg, sendResp.ErrCode)
-	}
+	return c.postMessage(ctx, msg)
+}
 
-	return nil
+// postMessage calls the message/send API with any of the message payloads
+func (c *WeComAppChannel) postMessage(ctx context.Context, msg any) error {
+	return c.postAPI(ctx, "/cgi-bin/message/send", msg, nil)
 }
 
-// sendMarkdownMessage sends a markdown message to a user
-func (c *WeComAppChannel) sendMarkdownMessage(ctx context.Context, accessToken, userID, content string) error {
-	apiURL := fmt.Sprintf("%s/cgi-bin/message/send?access_token=%s", wecomAPIBase, accessToken)
+// postAPI POSTs a JSON payload to a WeCom API path and decodes the response
+// into out, if given. A non-zero errcode is returned as an error.
+func (c *WeComAppChannel) postAPI(ctx context.Context, path string, payload, out any) error {
+	return c.withToken(ctx, func(accessToken string) error {
+		return c.postAPIWithToken(ctx, accessToken, path, payload, out)
+	})
+}
 
-	msg := WeComMarkdownMessage{
-		ToUser:  userID,
-		MsgType: "markdown",
-		AgentID: c.config.AgentID,
-	}
-	msg.Markdown.Content = content
+func (c *WeComAppChannel) postAPIWithToken(ctx context.Context, accessToken, path string, payload, out any) error {
+	apiURL := fmt.Sprintf("%s%s?access_token=%s", c.apiBase, path, url.QueryEscape(accessToken))
 
-	jsonData, err := json.Marshal(msg)
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
@@ -620,7 +789,13 @@ func (c *WeComAppChannel) sendMarkdownMessage(ctx context.Context, accessToken,
 	}
 
 	if sendResp.ErrCode != 0 {
-		return fmt.Errorf("API error: %s (code: %d)", sendResp.ErrMsg, sendResp.ErrCode)
+		return &wecomAPIError{Code: sendResp.ErrCode, Msg: sendResp.ErrMsg}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
 	}
 
 	return nil
@@ -637,3 +812,42 @@ func (c *WeComAppChannel) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
+
+// markdownToWeCom rewrites agent markdown into the subset WeCom App markdown
+// messages render: headings, bold, links, inline code and quotes. Anything
+// else is reduced to plain text so it does not show up as stray syntax.
+func markdownToWeCom(text string) string {
+	codeBlocks := extractCodeBlocks(text)
+	text = codeBlocks.text
+
+	inlineCodes := extractInlineCodes(text)
+	text = inlineCodes.text
+
+	// Images can't be embedded; link to them instead
+	text = reWeComImage.ReplaceAllStringFunc(text, func(s string) string {
+		m := reWeComImage.FindStringSubmatch(s)
+		if m[1] == "" {
+			return m[2]
+		}
+		return fmt.Sprintf("[%s](%s)", m[1], m[2])
+	})
+
+	text = reWeComTableDivider.ReplaceAllString(text, "")
+	text = reWeComRule.ReplaceAllString(text, "")
+	text = reWeComListItem.ReplaceAllString(text, "$1• ")
+	text = reWeComBoldUnderline.ReplaceAllString(text, "**$1**")
+	text = reWeComItalicStar.ReplaceAllString(text, "$1$2")
+	text = reWeComItalicUnder.ReplaceAllString(text, "$1$2")
+	text = reWeComStrike.ReplaceAllString(text, "$1")
+
+	for i, code := range inlineCodes.codes {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), "`"+code+"`")
+	}
+
+	// Fenced code blocks are not supported; keep their contents as plain lines
+	for i, code := range codeBlocks.codes {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00CB%d\x00", i), strings.TrimRight(code, "\n"))
+	}
+
+	return text
+}
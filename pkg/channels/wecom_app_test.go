@@ -16,13 +16,16 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
 )
 
 // generateTestAESKeyApp generates a valid test AES key for WeCom App
@@ -148,6 +151,86 @@ func TestNewWeComAppChannel(t *testing.T) {
 	})
 }
 
+func TestWeComAppSettings(t *testing.T) {
+	unified := config.WeComConfig{
+		Enabled:     true,
+		CorpID:      "corp",
+		CorpSecret:  "secret",
+		AgentID:     1000002,
+		WebhookPath: "/webhook/wecom",
+	}
+	legacy := config.WeComAppConfig{Enabled: true, CorpID: "old_corp", CorpSecret: "s", AgentID: 1}
+
+	enabledWeCom := func(c *config.ChannelsConfig) []string {
+		var names []string
+		for _, spec := range channelSpecs {
+			if strings.HasPrefix(spec.name, "wecom") && spec.enabled(c) {
+				names = append(names, spec.name)
+			}
+		}
+		return names
+	}
+
+	t.Run("wecom block in app mode", func(t *testing.T) {
+		c := &config.ChannelsConfig{WeCom: unified}
+		if got := enabledWeCom(c); len(got) != 1 || got[0] != "wecom_app" {
+			t.Errorf("enabled channels = %v, want [wecom_app]", got)
+		}
+		got, err := weComAppSettings(c)
+		if err != nil {
+			t.Fatalf("weComAppSettings() error = %v", err)
+		}
+		if got.CorpID != "corp" || got.AgentID != 1000002 || got.WebhookPath != "/webhook/wecom" {
+			t.Errorf("settings = %+v", got)
+		}
+	})
+
+	t.Run("legacy wecom_app block", func(t *testing.T) {
+		got, err := weComAppSettings(&config.ChannelsConfig{WeComApp: legacy})
+		if err != nil || got.CorpID != "old_corp" {
+			t.Errorf("weComAppSettings() = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("both blocks", func(t *testing.T) {
+		_, err := weComAppSettings(&config.ChannelsConfig{WeCom: unified, WeComApp: legacy})
+		if err == nil || !strings.Contains(err.Error(), "disable wecom_app") {
+			t.Errorf("error = %v, want a conflict error", err)
+		}
+	})
+
+	t.Run("incomplete app mode", func(t *testing.T) {
+		cfg := unified
+		cfg.CorpSecret = ""
+		_, err := weComAppSettings(&config.ChannelsConfig{WeCom: cfg})
+		if err == nil || err.Error() != "wecom app mode is missing corp_secret" {
+			t.Errorf("error = %v", err)
+		}
+	})
+
+	t.Run("invalid block is reported by the bot channel", func(t *testing.T) {
+		c := &config.ChannelsConfig{WeCom: config.WeComConfig{Enabled: true}}
+		if got := enabledWeCom(c); len(got) != 1 || got[0] != "wecom" {
+			t.Fatalf("enabled channels = %v, want [wecom]", got)
+		}
+		_, err := channelSpecs[specIndex(t, "wecom")].create(&config.Config{Channels: *c}, bus.NewMessageBus())
+		if err == nil || err.Error() != "wecom bot mode is missing token, webhook_url" {
+			t.Errorf("create() error = %v", err)
+		}
+	})
+}
+
+func specIndex(t *testing.T, name string) int {
+	t.Helper()
+	for i, spec := range channelSpecs {
+		if spec.name == name {
+			return i
+		}
+	}
+	t.Fatalf("no channel spec named %q", name)
+	return -1
+}
+
 func TestWeComAppChannelIsAllowed(t *testing.T) {
 	msgBus := bus.NewMessageBus()
 
@@ -395,7 +478,7 @@ func TestWeComAppHandleVerification(t *testing.T) {
 	t.Run("valid verification request", func(t *testing.T) {
 		echostr := "test_echostr_123"
 		encryptedEchostr, _ := encryptTestMessageApp(echostr, aesKey)
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignatureApp("test_token", timestamp, nonce, encryptedEchostr)
 
@@ -430,7 +513,7 @@ func TestWeComAppHandleVerification(t *testing.T) {
 	t.Run("invalid signature", func(t *testing.T) {
 		echostr := "test_echostr"
 		encryptedEchostr, _ := encryptTestMessageApp(echostr, aesKey)
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 
 		req := httptest.NewRequest(
@@ -485,7 +568,7 @@ func TestWeComAppHandleMessageCallback(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignatureApp("test_token", timestamp, nonce, encrypted)
 
@@ -518,7 +601,7 @@ func TestWeComAppHandleMessageCallback(t *testing.T) {
 	})
 
 	t.Run("invalid XML", func(t *testing.T) {
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignatureApp("test_token", timestamp, nonce, "")
 
@@ -545,7 +628,7 @@ func TestWeComAppHandleMessageCallback(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 
 		req := httptest.NewRequest(
@@ -662,7 +745,7 @@ func TestWeComAppHandleWebhook(t *testing.T) {
 	t.Run("GET request calls verification", func(t *testing.T) {
 		echostr := "test_echostr"
 		encoded := base64.StdEncoding.EncodeToString([]byte(echostr))
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignatureApp("test_token", timestamp, nonce, encoded)
 
@@ -689,7 +772,7 @@ func TestWeComAppHandleWebhook(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignatureApp("test_token", timestamp, nonce, encryptedWrapper.Encrypt)
 
@@ -766,10 +849,7 @@ func TestWeComAppAccessToken(t *testing.T) {
 	})
 
 	t.Run("set and get access token", func(t *testing.T) {
-		ch.tokenMu.Lock()
-		ch.accessToken = "test_token_123"
-		ch.tokenExpiry = time.Now().Add(1 * time.Hour)
-		ch.tokenMu.Unlock()
+		ch.tokens.set("test_token_123", time.Now().Add(1*time.Hour))
 
 		token := ch.getAccessToken()
 		if token != "test_token_123" {
@@ -778,10 +858,7 @@ func TestWeComAppAccessToken(t *testing.T) {
 	})
 
 	t.Run("expired token returns empty", func(t *testing.T) {
-		ch.tokenMu.Lock()
-		ch.accessToken = "expired_token"
-		ch.tokenExpiry = time.Now().Add(-1 * time.Hour)
-		ch.tokenMu.Unlock()
+		ch.tokens.set("expired_token", time.Now().Add(-1*time.Hour))
 
 		token := ch.getAccessToken()
 		if token != "" {
@@ -1102,3 +1179,598 @@ func TestWeComAppXMLMessageEvent(t *testing.T) {
 		t.Errorf("EventKey = %q, want %q", msg.EventKey, "event_key_123")
 	}
 }
+
+func TestWeComAppInboundMedia(t *testing.T) {
+	imageData := []byte("\x89PNG fake image bytes")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cgi-bin/media/get" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("media_id") == "expired" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"errcode":40007,"errmsg":"invalid media_id"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(imageData)
+	}))
+	defer server.Close()
+
+	newChannel := func(t *testing.T) (*WeComAppChannel, *bus.MessageBus) {
+		msgBus := bus.NewMessageBus()
+		ch, err := NewWeComAppChannel(config.WeComAppConfig{
+			CorpID:     "test_corp_id",
+			CorpSecret: "test_secret",
+			AgentID:    1000002,
+		}, msgBus)
+		if err != nil {
+			t.Fatalf("NewWeComAppChannel() error = %v", err)
+		}
+		ch.apiBase = server.URL
+		ch.SetMediaDir(t.TempDir())
+		ch.tokens.set("token", time.Now().Add(time.Hour))
+		return ch, msgBus
+	}
+
+	consume := func(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatal("expected inbound message")
+		}
+		return msg
+	}
+
+	t.Run("image is downloaded and attached", func(t *testing.T) {
+		ch, msgBus := newChannel(t)
+		ch.processMessage(context.Background(), WeComXMLMessage{
+			FromUserName: "user123",
+			MsgType:      "image",
+			MediaId:      "media_1",
+			MsgId:        1001,
+		})
+
+		msg := consume(t, msgBus)
+		if len(msg.Media) != 1 {
+			t.Fatalf("Media = %v, want one file", msg.Media)
+		}
+		data, err := os.ReadFile(msg.Media[0])
+		if err != nil || !bytes.Equal(data, imageData) {
+			t.Errorf("downloaded file mismatch: %q, err=%v", data, err)
+		}
+		if !strings.Contains(msg.Content, msg.Media[0]) {
+			t.Errorf("content %q should reference the local path", msg.Content)
+		}
+	})
+
+	t.Run("media API error is reported in content", func(t *testing.T) {
+		ch, msgBus := newChannel(t)
+		ch.processMessage(context.Background(), WeComXMLMessage{
+			FromUserName: "user123",
+			MsgType:      "image",
+			MediaId:      "expired",
+			MsgId:        1002,
+		})
+
+		msg := consume(t, msgBus)
+		if len(msg.Media) != 0 || msg.Content != "[image: download failed]" {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	})
+
+	t.Run("voice uses WeCom recognition when present", func(t *testing.T) {
+		ch, msgBus := newChannel(t)
+		ch.processMessage(context.Background(), WeComXMLMessage{
+			FromUserName: "user123",
+			MsgType:      "voice",
+			MediaId:      "media_2",
+			Format:       "amr",
+			Recognition:  "明天开会",
+			MsgId:        1003,
+		})
+
+		msg := consume(t, msgBus)
+		if msg.Content != "[voice transcription: 明天开会]" {
+			t.Errorf("Content = %q", msg.Content)
+		}
+	})
+
+	t.Run("voice without transcriber keeps the file", func(t *testing.T) {
+		ch, msgBus := newChannel(t)
+		ch.processMessage(context.Background(), WeComXMLMessage{
+			FromUserName: "user123",
+			MsgType:      "voice",
+			MediaId:      "media_3",
+			Format:       "AMR",
+			MsgId:        1004,
+		})
+
+		msg := consume(t, msgBus)
+		if msg.Content != "[voice]" || len(msg.Media) != 1 || !strings.HasSuffix(msg.Media[0], ".amr") {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	})
+}
+
+func TestWeComAppSendMedia(t *testing.T) {
+	var uploads int
+	var sent []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/media/upload":
+			uploads++
+			file, header, err := r.FormFile("media")
+			if err != nil {
+				t.Errorf("upload without media field: %v", err)
+				return
+			}
+			file.Close()
+			fmt.Fprintf(w, `{"errcode":0,"errmsg":"ok","type":%q,"media_id":"mid_%s"}`,
+				r.URL.Query().Get("type"), header.Filename)
+		case "/cgi-bin/message/send":
+			var payload map[string]any
+			json.NewDecoder(r.Body).Decode(&payload)
+			sent = append(sent, payload)
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:     "test_corp_id",
+		CorpSecret: "test_secret",
+		AgentID:    1000002,
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("token", time.Now().Add(time.Hour))
+	ch.setRunning(true)
+
+	dir := t.TempDir()
+	imagePath := dir + "/chart.png"
+	docPath := dir + "/report.pdf"
+	os.WriteFile(imagePath, []byte("png"), 0o600)
+	os.WriteFile(docPath, []byte("pdf"), 0o600)
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{
+		Channel: "wecom_app",
+		ChatID:  "user123",
+		Content: "Here you go",
+		Media:   []string{imagePath, docPath},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(sent) != 3 {
+		t.Fatalf("sent %d messages, want 3 (text + 2 media)", len(sent))
+	}
+	if sent[0]["msgtype"] != "text" || sent[1]["msgtype"] != "image" || sent[2]["msgtype"] != "file" {
+		t.Errorf("unexpected message types: %v, %v, %v", sent[0]["msgtype"], sent[1]["msgtype"], sent[2]["msgtype"])
+	}
+	image, _ := sent[1]["image"].(map[string]any)
+	if image["media_id"] != "mid_chart.png" {
+		t.Errorf("image media_id = %v", image["media_id"])
+	}
+
+	// Sending the same file again reuses the uploaded media ID
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "user123", Media: []string{imagePath}})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if uploads != 2 {
+		t.Errorf("uploads = %d, want 2 (cached media reused)", uploads)
+	}
+
+	// Expired media IDs are pruned and uploaded again
+	ch.uploadMu.Lock()
+	for key, uploaded := range ch.uploads {
+		uploaded.UploadedAt = time.Now().Add(-wecomMediaTTL)
+		ch.uploads[key] = uploaded
+	}
+	ch.uploadMu.Unlock()
+	ch.pruneUploadedMedia()
+	if len(ch.uploads) != 0 {
+		t.Errorf("expected expired uploads to be pruned, %d left", len(ch.uploads))
+	}
+}
+
+func TestWeComMediaType(t *testing.T) {
+	tests := map[string]string{
+		"photo.JPG":  "image",
+		"clip.amr":   "voice",
+		"song.mp3":   "file",
+		"movie.mp4":  "video",
+		"notes.docx": "file",
+	}
+	for path, want := range tests {
+		if got := wecomMediaType(path); got != want {
+			t.Errorf("wecomMediaType(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWeComProgressThrottle(t *testing.T) {
+	p := newWeComProgress()
+	p.interval = 0
+
+	if p.allow("user123", "Working: web_search") {
+		t.Error("progress should be dropped for chats without a pending reply")
+	}
+
+	p.start("user123", "Thinking... 💭")
+	if !p.allow("user123", "Working: web_search") {
+		t.Error("first update should be sent")
+	}
+	if p.allow("user123", "Working: web_search") {
+		t.Error("repeated update should be dropped")
+	}
+
+	p.interval = time.Hour
+	if p.allow("user123", "Working: read_file") {
+		t.Error("update within the interval should be dropped")
+	}
+
+	p.finish("user123")
+	p.interval = 0
+	if p.allow("user123", "Working: read_file") {
+		t.Error("progress should be dropped after the final reply")
+	}
+}
+
+func TestWeComAppProgressiveReply(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WeComTextMessage
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = append(sent, payload.Text.Content)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:           "test_corp_id",
+		CorpSecret:       "test_secret",
+		AgentID:          1000002,
+		ProgressiveReply: true,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("token", time.Now().Add(time.Hour))
+	ch.progress.interval = 0
+	ch.setRunning(true)
+
+	if !ch.SupportsProgress() {
+		t.Fatal("SupportsProgress() = false with progressive_reply enabled")
+	}
+
+	ch.processMessage(context.Background(), WeComXMLMessage{
+		FromUserName: "user123",
+		MsgType:      "text",
+		Content:      "hello",
+		MsgId:        1,
+	})
+
+	ctx := context.Background()
+	ch.Send(ctx, bus.OutboundMessage{ChatID: "user123", Content: "Working: web_search", Progress: true})
+	ch.Send(ctx, bus.OutboundMessage{ChatID: "user123", Content: "Here is the answer"})
+	ch.Send(ctx, bus.OutboundMessage{ChatID: "user123", Content: "Working: late", Progress: true})
+
+	want := []string{i18n.T(i18n.DefaultLanguage, i18n.Thinking), "Working: web_search", "Here is the answer"}
+	if len(sent) != len(want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Errorf("sent[%d] = %q, want %q", i, sent[i], want[i])
+		}
+	}
+}
+
+func TestMarkdownToWeCom(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "supported syntax kept",
+			in:   "# Title\n**bold** and [link](https://a.b)\n> quote",
+			want: "# Title\n**bold** and [link](https://a.b)\n> quote",
+		},
+		{name: "underscore bold", in: "__bold__", want: "**bold**"},
+		{name: "italic and strike", in: "an *italic* _word_ and ~~gone~~", want: "an italic word and gone"},
+		{name: "snake case untouched", in: "call read_file_now", want: "call read_file_now"},
+		{name: "list items", in: "- one\n  * two", want: "• one\n  • two"},
+		{name: "image becomes link", in: "![chart](https://a.b/c.png)", want: "[chart](https://a.b/c.png)"},
+		{name: "inline code", in: "run `go *test*`", want: "run `go *test*`"},
+		{name: "code block", in: "```go\nx := *p\n```", want: "x := *p"},
+		{name: "table divider dropped", in: "| a | b |\n|---|---|\n| 1 | 2 |", want: "| a | b |\n| 1 | 2 |"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToWeCom(tt.in); got != tt.want {
+				t.Errorf("markdownToWeCom(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeComAppSendMarkdown(t *testing.T) {
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:      "test_corp_id",
+		CorpSecret:  "test_secret",
+		AgentID:     1000002,
+		ReplyFormat: "markdown",
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("token", time.Now().Add(time.Hour))
+	ch.setRunning(true)
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "user123", Content: "**Done** ~~maybe~~"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if sent["msgtype"] != "markdown" {
+		t.Fatalf("msgtype = %v, want markdown", sent["msgtype"])
+	}
+	markdown, _ := sent["markdown"].(map[string]any)
+	if markdown["content"] != "**Done** maybe" {
+		t.Errorf("markdown content = %q", markdown["content"])
+	}
+}
+
+func TestWeComAppSendButtonsAsTemplateCard(t *testing.T) {
+	var sent WeComTemplateCardMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:     "test_corp_id",
+		CorpSecret: "test_secret",
+		AgentID:    1000002,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("token", time.Now().Add(time.Hour))
+	ch.setRunning(true)
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "user123",
+		Content: "Allow exec to run?\n{\"command\":\"ls\"}",
+		Buttons: []bus.Button{
+			{Label: "Approve", Value: "/approve ab12", Style: "primary"},
+			{Label: "Deny", Value: "/deny ab12", Style: "danger"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	card := sent.TemplateCard
+	if sent.MsgType != "template_card" || card.CardType != "button_interaction" {
+		t.Fatalf("msgtype = %q, card_type = %q", sent.MsgType, card.CardType)
+	}
+	if card.MainTitle.Title != "Allow exec to run?" || card.SubTitleText != `{"command":"ls"}` {
+		t.Errorf("title = %q, text = %q", card.MainTitle.Title, card.SubTitleText)
+	}
+	if len(card.ButtonList) != 2 || card.ButtonList[0].Key != "/approve ab12" || card.ButtonList[1].Style != 3 {
+		t.Errorf("buttons = %+v", card.ButtonList)
+	}
+
+	ch.processMessage(context.Background(), WeComXMLMessage{
+		MsgType:      "event",
+		Event:        "template_card_event",
+		EventKey:     "/approve ab12",
+		TaskId:       card.TaskID,
+		FromUserName: "user123",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message for the button press")
+	}
+	if msg.Content != "/approve ab12" || msg.ChatID != "user123" || msg.Metadata["button"] != "true" {
+		t.Errorf("got content=%q chat=%q metadata=%v", msg.Content, msg.ChatID, msg.Metadata)
+	}
+}
+
+func TestWeComAppExternalContacts(t *testing.T) {
+	var templates []WeComMsgTemplate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/externalcontact/groupchat/get":
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok","group_chat":{"chat_id":"wrGroup1","owner":"alice"}}`))
+		case "/cgi-bin/externalcontact/add_msg_template":
+			var tmpl WeComMsgTemplate
+			json.NewDecoder(r.Body).Decode(&tmpl)
+			templates = append(templates, tmpl)
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok","fail_list":[],"msgid":"msg1"}`))
+		default:
+			t.Errorf("unexpected API call %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:     "test_corp_id",
+		CorpSecret: "test_secret",
+		AgentID:    1000002,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("token", time.Now().Add(time.Hour))
+	ch.setRunning(true)
+
+	ch.processMessage(context.Background(), WeComXMLMessage{
+		MsgType:        "event",
+		Event:          "change_external_contact",
+		ChangeType:     "add_external_contact",
+		UserID:         "bob",
+		ExternalUserID: "wmCustomer1",
+	})
+
+	t.Run("contact reply is sent as owner", func(t *testing.T) {
+		templates = nil
+		err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "wmCustomer1", Content: "hi"})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(templates) != 1 {
+			t.Fatalf("got %d group-send tasks, want 1", len(templates))
+		}
+		tmpl := templates[0]
+		if tmpl.ChatType != "single" || tmpl.Sender != "bob" || len(tmpl.ExternalUserID) != 1 ||
+			tmpl.Text == nil || tmpl.Text.Content != "hi" {
+			t.Errorf("unexpected template: %+v", tmpl)
+		}
+	})
+
+	t.Run("group owner is looked up", func(t *testing.T) {
+		templates = nil
+		err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "wrGroup1", Content: "hello all"})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(templates) != 1 || templates[0].ChatType != "group" || templates[0].Sender != "alice" ||
+			len(templates[0].ChatIDList) != 1 || templates[0].ChatIDList[0] != "wrGroup1" {
+			t.Errorf("unexpected templates: %+v", templates)
+		}
+	})
+
+	t.Run("unknown contact without external_sender fails", func(t *testing.T) {
+		err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "wmStranger", Content: "hi"})
+		if err == nil {
+			t.Fatal("expected error without an owner or external_sender")
+		}
+	})
+
+	t.Run("group message routes to group chat", func(t *testing.T) {
+		ch.processMessage(context.Background(), WeComXMLMessage{
+			FromUserName: "wmCustomer1",
+			ChatId:       "wrGroup1",
+			MsgType:      "text",
+			Content:      "question",
+			MsgId:        42,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatal("expected inbound message")
+		}
+		if msg.ChatID != "wrGroup1" || msg.Metadata["peer_kind"] != "group" || msg.Metadata["external"] != "true" {
+			t.Errorf("unexpected message: chat=%q metadata=%v", msg.ChatID, msg.Metadata)
+		}
+	})
+}
+
+func TestWeComAppEvents(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:     "test_corp_id",
+		CorpSecret: "test_secret",
+		AgentID:    1000002,
+		EventPrompts: map[string]string{
+			"click:DAILY_REPORT": "Summarize today's tasks for {user}",
+			"location":           "I'm at {latitude},{longitude}",
+		},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+
+	consume := func() (bus.InboundMessage, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		return msgBus.ConsumeInbound(ctx)
+	}
+
+	tests := []struct {
+		name    string
+		msg     WeComXMLMessage
+		want    string
+		wantNil bool
+	}{
+		{
+			name: "configured menu prompt",
+			msg:  WeComXMLMessage{Event: "click", EventKey: "DAILY_REPORT", CreateTime: 1},
+			want: "Summarize today's tasks for user123",
+		},
+		{
+			name: "unconfigured menu click",
+			msg:  WeComXMLMessage{Event: "click", EventKey: "HELP", CreateTime: 2},
+			want: "[menu click: HELP]",
+		},
+		{
+			name: "location event",
+			msg:  WeComXMLMessage{Event: "LOCATION", Latitude: 23.1, Longitude: 113.3, CreateTime: 3},
+			want: "I'm at 23.1,113.3",
+		},
+		{
+			name:    "unconfigured subscribe is ignored",
+			msg:     WeComXMLMessage{Event: "subscribe", CreateTime: 4},
+			wantNil: true,
+		},
+		{
+			name:    "retried event is deduplicated",
+			msg:     WeComXMLMessage{Event: "click", EventKey: "HELP", CreateTime: 2},
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.MsgType = "event"
+			tt.msg.FromUserName = "user123"
+			ch.processMessage(context.Background(), tt.msg)
+
+			msg, ok := consume()
+			if tt.wantNil {
+				if ok {
+					t.Fatalf("unexpected inbound message %q", msg.Content)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected inbound message")
+			}
+			if msg.Content != tt.want || msg.ChatID != "user123" || msg.Metadata["msg_type"] != "event" {
+				t.Errorf("got content=%q chat=%q metadata=%v", msg.Content, msg.ChatID, msg.Metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom callback crypto shared by the bot and app modes: signatures,
+// AES message encryption and the checks every safe mode callback goes through
+
+package channels
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// weComCallback checks and decrypts the callbacks WeCom sends to a channel.
+// receiveID is empty for bots (AIBOT) and the corp ID for apps.
+type weComCallback struct {
+	component string
+	token     string
+	aesKey    string
+	receiveID string
+	replay    *weComReplayGuard
+}
+
+// verifyURL answers the URL verification request with the decrypted echostr.
+func (cb weComCallback) verifyURL(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	msgSignature := query.Get("msg_signature")
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+	echostr := query.Get("echostr")
+
+	if msgSignature == "" || timestamp == "" || nonce == "" || echostr == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	if !WeComVerifySignature(cb.token, msgSignature, timestamp, nonce, echostr) {
+		logger.WarnC(cb.component, "Signature verification failed")
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	if _, err := cb.replay.checkTimestamp(timestamp); err != nil {
+		logger.WarnCF(cb.component, "Rejected verification request", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Invalid timestamp", http.StatusForbidden)
+		return
+	}
+
+	// Reference: https://developer.work.weixin.qq.com/document/path/101033
+	decryptedEchoStr, err := WeComDecryptMessageWithVerify(echostr, cb.aesKey, cb.receiveID)
+	if err != nil {
+		logger.ErrorCF(cb.component, "Failed to decrypt echostr", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Decryption failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Remove BOM and whitespace as per WeCom documentation
+	// The response must be plain text without quotes, BOM, or newlines
+	decryptedEchoStr = strings.TrimSpace(decryptedEchoStr)
+	decryptedEchoStr = strings.TrimPrefix(decryptedEchoStr, "\xef\xbb\xbf") // Remove UTF-8 BOM
+	w.Write([]byte(decryptedEchoStr))
+}
+
+// open checks the signature and freshness of a message callback and returns
+// its decrypted payload. On failure the request has already been answered.
+func (cb weComCallback) open(w http.ResponseWriter, r *http.Request) (string, bool) {
+	query := r.URL.Query()
+	msgSignature := query.Get("msg_signature")
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+
+	if msgSignature == "" || timestamp == "" || nonce == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return "", false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return "", false
+	}
+	defer r.Body.Close()
+
+	var encryptedMsg struct {
+		XMLName    xml.Name `xml:"xml"`
+		ToUserName string   `xml:"ToUserName"`
+		Encrypt    string   `xml:"Encrypt"`
+		AgentID    string   `xml:"AgentID"`
+	}
+	if err = xml.Unmarshal(body, &encryptedMsg); err != nil {
+		logger.ErrorCF(cb.component, "Failed to parse XML", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Invalid XML", http.StatusBadRequest)
+		return "", false
+	}
+
+	if !WeComVerifySignature(cb.token, msgSignature, timestamp, nonce, encryptedMsg.Encrypt) {
+		logger.WarnC(cb.component, "Message signature verification failed")
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return "", false
+	}
+
+	// Checked after the signature so forged requests can't fill the nonce cache
+	if err := cb.replay.check(timestamp, nonce); err != nil {
+		logger.WarnCF(cb.component, "Rejected replayed or stale callback", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Replayed or expired request", http.StatusForbidden)
+		return "", false
+	}
+
+	decrypted, err := WeComDecryptMessageWithVerify(encryptedMsg.Encrypt, cb.aesKey, cb.receiveID)
+	if err != nil {
+		logger.ErrorCF(cb.component, "Failed to decrypt message", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Decryption failed", http.StatusInternalServerError)
+		return "", false
+	}
+	return decrypted, true
+}
+
+// WeComVerifySignature verifies the message signature for WeCom
+// This is a common function used by both WeCom Bot and WeCom App
+func WeComVerifySignature(token, msgSignature, timestamp, nonce, msgEncrypt string) bool {
+	if token == "" {
+		return true // Skip verification if token is not set
+	}
+
+	expectedSignature := WeComGenerateSignature(token, timestamp, nonce, msgEncrypt)
+
+	// Constant-time, so the comparison doesn't leak how much of a forged
+	// signature matched
+	return subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(msgSignature)) == 1
+}
+
+// WeComGenerateSignature computes msg_signature: sha1 over the sorted and
+// concatenated token, timestamp, nonce and encrypted payload
+func WeComGenerateSignature(token, timestamp, nonce, msgEncrypt string) string {
+	// Sort parameters
+	params := []string{token, timestamp, nonce, msgEncrypt}
+	sort.Strings(params)
+
+	// Concatenate
+	str := strings.Join(params, "")
+
+	// SHA1 hash
+	hash := sha1.Sum([]byte(str))
+	return fmt.Sprintf("%x", hash)
+}
+
+// WeComEncryptMessage encrypts a reply for callbacks running in safe mode (安全模式)
+// Format before encryption: random(16) + msg_len(4) + msg + receiveid,
+// PKCS7 padded to 32 bytes and encrypted with AES-256-CBC (IV = first 16 bytes of key)
+// receiveid: for AIBOT it is empty, for WeCom App it is corp_id
+func WeComEncryptMessage(msg, encodingAESKey, receiveid string) (string, error) {
+	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return "", fmt.Errorf("failed to decode AES key: %w", err)
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate random prefix: %w", err)
+	}
+
+	plainText := make([]byte, 0, 20+len(msg)+len(receiveid)+wecomBlockSize)
+	plainText = append(plainText, random...)
+	plainText = binary.BigEndian.AppendUint32(plainText, uint32(len(msg)))
+	plainText = append(plainText, msg...)
+	plainText = append(plainText, receiveid...)
+	plainText = pkcs7PadWeCom(plainText)
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	cipherText := make([]byte, len(plainText))
+	mode := cipher.NewCBCEncrypter(block, aesKey[:aes.BlockSize])
+	mode.CryptBlocks(cipherText, plainText)
+
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+// WeComReplyEnvelope is the encrypted passive reply returned to a safe mode callback
+type WeComReplyEnvelope struct {
+	Encrypt      string `json:"encrypt"`
+	MsgSignature string `json:"msgsignature"`
+	TimeStamp    string `json:"timestamp"`
+	Nonce        string `json:"nonce"`
+}
+
+// WeComEncryptReply encrypts and signs a passive reply
+func WeComEncryptReply(reply, token, encodingAESKey, receiveid string) (*WeComReplyEnvelope, error) {
+	encrypted, err := WeComEncryptMessage(reply, encodingAESKey, receiveid)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceBytes := make([]byte, 8)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := fmt.Sprintf("%x", nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	return &WeComReplyEnvelope{
+		Encrypt:      encrypted,
+		MsgSignature: WeComGenerateSignature(token, timestamp, nonce, encrypted),
+		TimeStamp:    timestamp,
+		Nonce:        nonce,
+	}, nil
+}
+
+// XML renders the envelope in the format expected by WeCom App callbacks
+func (e *WeComReplyEnvelope) XML() ([]byte, error) {
+	type cdata struct {
+		Value string `xml:",cdata"`
+	}
+	return xml.Marshal(struct {
+		XMLName      xml.Name `xml:"xml"`
+		Encrypt      cdata    `xml:"Encrypt"`
+		MsgSignature cdata    `xml:"MsgSignature"`
+		TimeStamp    string   `xml:"TimeStamp"`
+		Nonce        cdata    `xml:"Nonce"`
+	}{
+		Encrypt:      cdata{e.Encrypt},
+		MsgSignature: cdata{e.MsgSignature},
+		TimeStamp:    e.TimeStamp,
+		Nonce:        cdata{e.Nonce},
+	})
+}
+
+// JSON renders the envelope in the format expected by WeCom Bot (AIBOT) callbacks
+func (e *WeComReplyEnvelope) JSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// WeComDecryptMessage decrypts the encrypted message using AES
+// This is a common function used by both WeCom Bot and WeCom App
+// For AIBOT, receiveid should be the aibotid; for other apps, it should be corp_id
+func WeComDecryptMessage(encryptedMsg, encodingAESKey string) (string, error) {
+	return WeComDecryptMessageWithVerify(encryptedMsg, encodingAESKey, "")
+}
+
+// WeComDecryptMessageWithVerify decrypts the encrypted message and optionally verifies receiveid
+// receiveid: for AIBOT use aibotid, for WeCom App use corp_id. If empty, skip verification.
+func WeComDecryptMessageWithVerify(encryptedMsg, encodingAESKey, receiveid string) (string, error) {
+	if encodingAESKey == "" {
+		// No encryption, return as is (base64 decode)
+		decoded, err := base64.StdEncoding.DecodeString(encryptedMsg)
+		if err != nil {
+			return "", err
+		}
+		return string(decoded), nil
+	}
+
+	// Decode AES key (base64)
+	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return "", fmt.Errorf("failed to decode AES key: %w", err)
+	}
+
+	// Decode encrypted message
+	cipherText, err := base64.StdEncoding.DecodeString(encryptedMsg)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	// AES decrypt
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	if len(cipherText) < aes.BlockSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	// IV is the first 16 bytes of AESKey
+	iv := aesKey[:aes.BlockSize]
+	mode := cipher.NewCBCDecrypter(block, iv)
+	plainText := make([]byte, len(cipherText))
+	mode.CryptBlocks(plainText, cipherText)
+
+	// Remove PKCS7 padding
+	plainText, err = pkcs7UnpadWeCom(plainText)
+	if err != nil {
+		return "", fmt.Errorf("failed to unpad: %w", err)
+	}
+
+	// Parse message structure
+	// Format: random(16) + msg_len(4) + msg + receiveid
+	if len(plainText) < 20 {
+		return "", fmt.Errorf("decrypted message too short")
+	}
+
+	msgLen := binary.BigEndian.Uint32(plainText[16:20])
+	if int(msgLen) > len(plainText)-20 {
+		return "", fmt.Errorf("invalid message length")
+	}
+
+	msg := plainText[20 : 20+msgLen]
+
+	// Verify receiveid if provided
+	if receiveid != "" && len(plainText) > 20+int(msgLen) {
+		actualReceiveID := string(plainText[20+msgLen:])
+		if actualReceiveID != receiveid {
+			return "", fmt.Errorf("receiveid mismatch: expected %s, got %s", receiveid, actualReceiveID)
+		}
+	}
+
+	return string(msg), nil
+}
+
+// pkcs7UnpadWeCom removes PKCS7 padding with validation
+// WeCom uses block size of 32 (not standard AES block size of 16)
+const wecomBlockSize = 32
+
+// pkcs7PadWeCom pads data to a multiple of the WeCom block size
+func pkcs7PadWeCom(data []byte) []byte {
+	padding := wecomBlockSize - len(data)%wecomBlockSize
+	return append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func pkcs7UnpadWeCom(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	padding := int(data[len(data)-1])
+	// WeCom uses 32-byte block size for PKCS7 padding
+	if padding == 0 || padding > wecomBlockSize {
+		return nil, fmt.Errorf("invalid padding size: %d", padding)
+	}
+	if padding > len(data) {
+		return nil, fmt.Errorf("padding size larger than data")
+	}
+	// Verify all padding bytes
+	for i := 0; i < padding; i++ {
+		if data[len(data)-1-i] != byte(padding) {
+			return nil, fmt.Errorf("invalid padding byte at position %d", i)
+		}
+	}
+	return data[:len(data)-padding], nil
+}
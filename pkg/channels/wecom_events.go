@@ -0,0 +1,131 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom App event callbacks
+// Routes menu clicks, subscribe/unsubscribe, enter_agent and location reports
+// into the agent, using prompts configured per event in event_prompts.
+// Template card button presses are delivered as the button's key.
+
+package channels
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// handleEvent handles event callbacks
+func (c *WeComAppChannel) handleEvent(ctx context.Context, msg WeComXMLMessage) {
+	switch msg.Event {
+	case "change_external_contact":
+		c.handleExternalContactEvent(msg)
+	case "change_external_chat":
+		c.handleExternalChatEvent(msg)
+	case "template_card_event":
+		c.handleTemplateCardEvent(msg)
+	default:
+		c.handleAgentEvent(ctx, msg)
+	}
+}
+
+// handleAgentEvent turns a user event into an agent turn. Events only reach
+// the agent when a prompt is configured for them, except menu clicks, which
+// are always forwarded since the user explicitly asked for something.
+func (c *WeComAppChannel) handleAgentEvent(ctx context.Context, msg WeComXMLMessage) {
+	event := strings.ToLower(msg.Event)
+
+	content, ok := c.eventPrompt(event, msg)
+	if !ok {
+		logger.DebugCF("wecom_app", "Skipping unhandled event", map[string]any{
+			"event":     msg.Event,
+			"event_key": msg.EventKey,
+		})
+		return
+	}
+
+	// Events carry no MsgId; WeCom retries use the same sender and timestamp
+	if !c.markProcessed(fmt.Sprintf("event:%s:%s:%d", msg.FromUserName, event, msg.CreateTime)) {
+		return
+	}
+
+	senderID := msg.FromUserName
+	metadata := map[string]string{
+		"msg_type":    "event",
+		"event":       event,
+		"event_key":   msg.EventKey,
+		"agent_id":    fmt.Sprintf("%d", msg.AgentID),
+		"platform":    "wecom_app",
+		"create_time": fmt.Sprintf("%d", msg.CreateTime),
+		"peer_kind":   "direct",
+		"peer_id":     senderID,
+	}
+
+	logger.InfoCF("wecom_app", "Routing event to agent", map[string]any{
+		"sender_id": senderID,
+		"event":     event,
+		"event_key": msg.EventKey,
+	})
+
+	// Other events fire passively (e.g. periodic location reports), so only
+	// menu clicks get a thinking message
+	if c.config.ProgressiveReply && event == "click" && c.IsAllowed(senderID) {
+		c.startProgress(ctx, senderID)
+	}
+
+	c.HandleMessage(senderID, senderID, content, nil, metadata)
+}
+
+// handleTemplateCardEvent delivers a template card button press as if the
+// user had sent the button's key.
+func (c *WeComAppChannel) handleTemplateCardEvent(msg WeComXMLMessage) {
+	if msg.EventKey == "" || !c.markProcessed("card:"+msg.FromUserName+":"+msg.TaskId+":"+msg.EventKey) {
+		return
+	}
+
+	senderID := msg.FromUserName
+	metadata := map[string]string{
+		"msg_type":    "event",
+		"event":       "template_card_event",
+		"event_key":   msg.EventKey,
+		"task_id":     msg.TaskId,
+		"button":      "true",
+		"agent_id":    fmt.Sprintf("%d", msg.AgentID),
+		"platform":    "wecom_app",
+		"create_time": fmt.Sprintf("%d", msg.CreateTime),
+		"peer_kind":   "direct",
+		"peer_id":     senderID,
+	}
+
+	logger.InfoCF("wecom_app", "Routing card button to agent", map[string]any{
+		"sender_id": senderID,
+		"task_id":   msg.TaskId,
+		"event_key": msg.EventKey,
+	})
+
+	c.HandleMessage(senderID, senderID, msg.EventKey, nil, metadata)
+}
+
+// eventPrompt returns the agent input for an event. A prompt configured for
+// "<event>:<event_key>" wins over one for "<event>". Prompts may use the
+// placeholders {user}, {event_key}, {latitude}, {longitude} and {precision}.
+func (c *WeComAppChannel) eventPrompt(event string, msg WeComXMLMessage) (string, bool) {
+	prompt, ok := c.config.EventPrompts[event+":"+msg.EventKey]
+	if !ok || msg.EventKey == "" {
+		prompt, ok = c.config.EventPrompts[event]
+	}
+
+	if !ok {
+		if event != "click" || msg.EventKey == "" {
+			return "", false
+		}
+		prompt = "[menu click: {event_key}]"
+	}
+
+	return strings.NewReplacer(
+		"{user}", msg.FromUserName,
+		"{event_key}", msg.EventKey,
+		"{latitude}", fmt.Sprintf("%g", msg.Latitude),
+		"{longitude}", fmt.Sprintf("%g", msg.Longitude),
+		"{precision}", fmt.Sprintf("%g", msg.Precision),
+	).Replace(prompt), true
+}
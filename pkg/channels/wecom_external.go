@@ -0,0 +1,210 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom external contact (客户联系) support
+// Customers and customer group chats can't be reached with message/send;
+// replies go out through the group-send API (add_msg_template) on behalf of
+// the member who owns the contact or group, who confirms them in WeCom
+
+package channels
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// WeComMsgTemplate is the payload of /cgi-bin/externalcontact/add_msg_template
+type WeComMsgTemplate struct {
+	ChatType       string                       `json:"chat_type"`
+	ExternalUserID []string                     `json:"external_userid,omitempty"`
+	ChatIDList     []string                     `json:"chat_id_list,omitempty"`
+	Sender         string                       `json:"sender,omitempty"`
+	Text           *WeComTextContent            `json:"text,omitempty"`
+	Attachments    []WeComMsgTemplateAttachment `json:"attachments,omitempty"`
+}
+
+// WeComTextContent is the text body of a group-send message
+type WeComTextContent struct {
+	Content string `json:"content"`
+}
+
+// WeComMsgTemplateAttachment is an image, video or file sent with a group-send message
+type WeComMsgTemplateAttachment struct {
+	MsgType string         `json:"msgtype"`
+	Image   *WeComMediaRef `json:"image,omitempty"`
+	Video   *WeComMediaRef `json:"video,omitempty"`
+	File    *WeComMediaRef `json:"file,omitempty"`
+}
+
+// WeComMsgTemplateResponse represents the add_msg_template API response
+type WeComMsgTemplateResponse struct {
+	ErrCode  int      `json:"errcode"`
+	ErrMsg   string   `json:"errmsg"`
+	FailList []string `json:"fail_list"`
+	MsgID    string   `json:"msgid"`
+}
+
+// WeComGroupChatResponse represents the externalcontact/groupchat/get API response
+type WeComGroupChatResponse struct {
+	ErrCode   int    `json:"errcode"`
+	ErrMsg    string `json:"errmsg"`
+	GroupChat struct {
+		ChatID string `json:"chat_id"`
+		Name   string `json:"name"`
+		Owner  string `json:"owner"`
+	} `json:"group_chat"`
+}
+
+// isWeComExternalUser reports whether id is an external contact's userid.
+// External userids start with "wm" (WeChat users) or "wo" (WeCom users of
+// other corps).
+func isWeComExternalUser(id string) bool {
+	return strings.HasPrefix(id, "wm") || strings.HasPrefix(id, "wo")
+}
+
+// isWeComExternalChat reports whether id is a customer group chat ID
+func isWeComExternalChat(id string) bool {
+	return strings.HasPrefix(id, "wr")
+}
+
+// handleExternalContactEvent tracks which member owns each external contact,
+// so replies can be group-sent on their behalf.
+func (c *WeComAppChannel) handleExternalContactEvent(msg WeComXMLMessage) {
+	logger.InfoCF("wecom_app", "External contact changed", map[string]any{
+		"change_type":      msg.ChangeType,
+		"user_id":          msg.UserID,
+		"external_user_id": msg.ExternalUserID,
+	})
+
+	switch msg.ChangeType {
+	case "add_external_contact", "edit_external_contact", "add_half_external_contact":
+		c.setExternalOwner(msg.ExternalUserID, msg.UserID)
+	case "del_external_contact", "del_follow_user":
+		c.setExternalOwner(msg.ExternalUserID, "")
+	}
+}
+
+// handleExternalChatEvent forgets dismissed customer groups; owners of other
+// groups are looked up when a reply is sent.
+func (c *WeComAppChannel) handleExternalChatEvent(msg WeComXMLMessage) {
+	logger.InfoCF("wecom_app", "External group chat changed", map[string]any{
+		"change_type":   msg.ChangeType,
+		"chat_id":       msg.ChatId,
+		"update_detail": msg.UpdateDetail,
+	})
+
+	if msg.ChangeType == "dismiss" {
+		c.setExternalOwner(msg.ChatId, "")
+	}
+}
+
+func (c *WeComAppChannel) setExternalOwner(id, owner string) {
+	if id == "" {
+		return
+	}
+
+	c.externalMu.Lock()
+	defer c.externalMu.Unlock()
+	if owner == "" {
+		delete(c.externalOwners, id)
+		return
+	}
+	c.externalOwners[id] = owner
+}
+
+// externalOwner returns the member that replies to an external contact or
+// customer group are sent as, falling back to external_sender.
+func (c *WeComAppChannel) externalOwner(ctx context.Context, chatID string) string {
+	c.externalMu.RLock()
+	owner := c.externalOwners[chatID]
+	c.externalMu.RUnlock()
+	if owner != "" {
+		return owner
+	}
+
+	if isWeComExternalChat(chatID) {
+		var resp WeComGroupChatResponse
+		err := c.postAPI(ctx, "/cgi-bin/externalcontact/groupchat/get",
+			map[string]string{"chat_id": chatID}, &resp)
+		if err == nil && resp.GroupChat.Owner != "" {
+			c.setExternalOwner(chatID, resp.GroupChat.Owner)
+			return resp.GroupChat.Owner
+		}
+		if err != nil {
+			logger.WarnCF("wecom_app", "Failed to look up customer group owner", map[string]any{
+				"chat_id": chatID,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	return c.config.ExternalSender
+}
+
+// sendExternalMessage creates a group-send task for a customer or customer
+// group. Media is attached as images, videos or files; voice is not
+// supported by the group-send API and is skipped.
+func (c *WeComAppChannel) sendExternalMessage(
+	ctx context.Context,
+	chatID, content string,
+	media []string,
+) error {
+	tmpl := WeComMsgTemplate{
+		Sender: c.externalOwner(ctx, chatID),
+	}
+	if isWeComExternalChat(chatID) {
+		tmpl.ChatType = "group"
+		tmpl.ChatIDList = []string{chatID}
+	} else {
+		tmpl.ChatType = "single"
+		tmpl.ExternalUserID = []string{chatID}
+	}
+	if tmpl.Sender == "" {
+		return fmt.Errorf("no member to send as for %s; set external_sender", chatID)
+	}
+
+	if content != "" {
+		tmpl.Text = &WeComTextContent{Content: content}
+	}
+
+	for _, path := range media {
+		if wecomMediaType(path) == "voice" {
+			logger.WarnCF("wecom_app", "Skipping voice attachment unsupported by group-send", map[string]any{
+				"path": path,
+			})
+			continue
+		}
+
+		uploaded, err := c.uploadMedia(ctx, path)
+		if err != nil {
+			return err
+		}
+		ref := &WeComMediaRef{MediaID: uploaded.MediaID}
+		attachment := WeComMsgTemplateAttachment{MsgType: uploaded.Type}
+		switch uploaded.Type {
+		case "image":
+			attachment.Image = ref
+		case "video":
+			attachment.Video = ref
+		default:
+			attachment.File = ref
+		}
+		tmpl.Attachments = append(tmpl.Attachments, attachment)
+	}
+
+	var resp WeComMsgTemplateResponse
+	if err := c.postAPI(ctx, "/cgi-bin/externalcontact/add_msg_template", tmpl, &resp); err != nil {
+		return err
+	}
+	if len(resp.FailList) > 0 {
+		return fmt.Errorf("group-send failed for %s", strings.Join(resp.FailList, ", "))
+	}
+
+	logger.DebugCF("wecom_app", "Created group-send task", map[string]any{
+		"chat_id": chatID,
+		"sender":  tmpl.Sender,
+		"msg_id":  resp.MsgID,
+	})
+	return nil
+}
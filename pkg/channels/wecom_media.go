@@ -0,0 +1,349 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom media handling
+// Downloads inbound images and voice messages for the agent, and uploads
+// temporary material so replies can carry images, voice and files
+
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	wecomMediaMaxSize         = 20 << 20 // WeCom caps media at 20MB
+	wecomMediaDownloadTimeout = 30 * time.Second
+)
+
+// saveWeComMedia writes downloaded media below dir (falling back to the
+// shared temp media directory) and returns the local path.
+func saveWeComMedia(dir, filename string, data []byte) (string, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "picoclaw_media")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	path := filepath.Join(dir, utils.SanitizeFilename(filename))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write media file: %w", err)
+	}
+	return path, nil
+}
+
+// fetchWeComMedia GETs a media URL and returns its body. The WeCom media API
+// answers errors with a JSON body instead of a non-200 status, so a JSON
+// content type is treated as failure.
+func fetchWeComMedia(ctx context.Context, mediaURL string) ([]byte, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, wecomMediaDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, wecomMediaMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	if len(data) > wecomMediaMaxSize {
+		return nil, fmt.Errorf("media exceeds %d bytes", wecomMediaMaxSize)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/plain") {
+		var apiErr struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.ErrCode != 0 {
+			return nil, fmt.Errorf("media %w", &wecomAPIError{Code: apiErr.ErrCode, Msg: apiErr.ErrMsg})
+		}
+	}
+
+	return data, nil
+}
+
+// WeComDecryptMedia decrypts files served to WeCom Bot (AIBOT) callbacks,
+// which are encrypted with the callback EncodingAESKey using AES-256-CBC
+// and 32-byte PKCS7 padding, the same scheme as callback messages.
+func WeComDecryptMedia(data []byte, encodingAESKey string) ([]byte, error) {
+	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AES key: %w", err)
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted media length: %d", len(data))
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, aesKey[:aes.BlockSize]).CryptBlocks(plain, data)
+
+	return pkcs7UnpadWeCom(plain)
+}
+
+func (c *WeComAppChannel) getContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetMediaDir sets where downloaded media is stored
+func (c *WeComAppChannel) SetMediaDir(dir string) {
+	c.mediaDir = dir
+}
+
+// downloadMedia fetches a temporary media file by media_id via
+// /cgi-bin/media/get and stores it locally.
+func (c *WeComAppChannel) downloadMedia(ctx context.Context, mediaID, filename string) (string, error) {
+	var data []byte
+	err := c.withToken(ctx, func(accessToken string) error {
+		apiURL := fmt.Sprintf("%s/cgi-bin/media/get?access_token=%s&media_id=%s",
+			c.apiBase, url.QueryEscape(accessToken), url.QueryEscape(mediaID))
+
+		var err error
+		data, err = fetchWeComMedia(ctx, apiURL)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return saveWeComMedia(c.mediaDir, filename, data)
+}
+
+// transcribe converts a downloaded voice file to text when a transcriber is
+// configured, returning the content marker passed to the agent.
+func (c *WeComAppChannel) transcribe(ctx context.Context, voicePath string) string {
+	if c.transcriber == nil || !c.transcriber.IsAvailable() {
+		return "[voice]"
+	}
+
+	transcribeCtx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+
+	result, err := c.transcriber.Transcribe(transcribeCtx, voicePath)
+	if err != nil {
+		logger.ErrorCF("wecom_app", "Voice transcription failed", map[string]any{
+			"error": err.Error(),
+			"path":  voicePath,
+		})
+		return "[voice (transcription failed)]"
+	}
+
+	return fmt.Sprintf("[voice transcription: %s]", result.Text)
+}
+
+func (c *WeComBotChannel) getContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetMediaDir sets where downloaded media is stored
+func (c *WeComBotChannel) SetMediaDir(dir string) {
+	c.mediaDir = dir
+}
+
+// downloadImage fetches and decrypts an image delivered to an AIBOT callback.
+func (c *WeComBotChannel) downloadImage(ctx context.Context, imageURL, filename string) (string, error) {
+	data, err := fetchWeComMedia(ctx, imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	if c.config.EncodingAESKey != "" {
+		if data, err = WeComDecryptMedia(data, c.config.EncodingAESKey); err != nil {
+			return "", fmt.Errorf("failed to decrypt image: %w", err)
+		}
+	}
+
+	return saveWeComMedia(c.mediaDir, filename, data)
+}
+
+// Temporary material expires three days after upload; media IDs are reused
+// until shortly before that.
+const wecomMediaTTL = 3*24*time.Hour - time.Hour
+
+type wecomUploadedMedia struct {
+	MediaID    string
+	Type       string
+	UploadedAt time.Time
+}
+
+// wecomMediaType picks the WeCom material type for a local file. Voice must
+// be AMR; other audio is sent as a file.
+func wecomMediaType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp":
+		return "image"
+	case ".amr":
+		return "voice"
+	case ".mp4":
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+// sendMediaFile uploads (or reuses) a file and sends it as the matching message type
+func (c *WeComAppChannel) sendMediaFile(ctx context.Context, userID, path string) error {
+	uploaded, err := c.uploadMedia(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	ref := &WeComMediaRef{MediaID: uploaded.MediaID}
+	msg := WeComMediaMessage{
+		ToUser:  userID,
+		MsgType: uploaded.Type,
+		AgentID: c.config.AgentID,
+	}
+	switch uploaded.Type {
+	case "image":
+		msg.Image = ref
+	case "voice":
+		msg.Voice = ref
+	case "video":
+		msg.Video = ref
+	default:
+		msg.File = ref
+	}
+
+	return c.postMessage(ctx, msg)
+}
+
+// uploadMedia uploads a file as temporary material via /cgi-bin/media/upload.
+// Files that were already uploaded and have not expired are not re-sent.
+func (c *WeComAppChannel) uploadMedia(ctx context.Context, path string) (wecomUploadedMedia, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return wecomUploadedMedia{}, err
+	}
+	key := fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+
+	c.uploadMu.Lock()
+	cached, ok := c.uploads[key]
+	c.uploadMu.Unlock()
+	if ok && time.Since(cached.UploadedAt) < wecomMediaTTL {
+		return cached, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return wecomUploadedMedia{}, err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("media", filepath.Base(path))
+	if err != nil {
+		return wecomUploadedMedia{}, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return wecomUploadedMedia{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := writer.Close(); err != nil {
+		return wecomUploadedMedia{}, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	mediaType := wecomMediaType(path)
+	var uploadResp WeComUploadMediaResponse
+	err = c.withToken(ctx, func(accessToken string) error {
+		apiURL := fmt.Sprintf("%s/cgi-bin/media/upload?access_token=%s&type=%s",
+			c.apiBase, url.QueryEscape(accessToken), mediaType)
+
+		reqCtx, cancel := context.WithTimeout(ctx, wecomMediaDownloadTimeout)
+		defer cancel()
+
+		// The body is read from a fresh reader so a retry resends it in full
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, apiURL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload media: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
+			return fmt.Errorf("failed to parse upload response: %w", err)
+		}
+		if uploadResp.ErrCode != 0 {
+			return fmt.Errorf("media upload %w", &wecomAPIError{Code: uploadResp.ErrCode, Msg: uploadResp.ErrMsg})
+		}
+		return nil
+	})
+	if err != nil {
+		return wecomUploadedMedia{}, err
+	}
+
+	uploaded := wecomUploadedMedia{
+		MediaID:    uploadResp.MediaID,
+		Type:       mediaType,
+		UploadedAt: time.Now(),
+	}
+
+	c.uploadMu.Lock()
+	c.uploads[key] = uploaded
+	c.uploadMu.Unlock()
+
+	logger.DebugCF("wecom_app", "Uploaded media", map[string]any{
+		"path": path,
+		"type": mediaType,
+	})
+
+	return uploaded, nil
+}
+
+// pruneUploadedMedia drops media IDs that WeCom has already expired
+func (c *WeComAppChannel) pruneUploadedMedia() {
+	c.uploadMu.Lock()
+	defer c.uploadMu.Unlock()
+
+	for key, uploaded := range c.uploads {
+		if time.Since(uploaded.UploadedAt) >= wecomMediaTTL {
+			delete(c.uploads, key)
+		}
+	}
+}
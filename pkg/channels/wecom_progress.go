@@ -0,0 +1,111 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom progressive replies
+// Sends a "thinking" message as soon as a message is accepted and follows
+// up with interim updates while the agent works, instead of staying silent
+// until the final reply
+
+package channels
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// WeCom cannot edit sent messages, so every update is a new message; keep
+// them spaced out to avoid flooding the chat and hitting rate limits.
+const wecomProgressInterval = 3 * time.Second
+
+// wecomProgress tracks which chats are waiting on a reply and throttles the
+// interim updates sent to them.
+type wecomProgress struct {
+	mu       sync.Mutex
+	interval time.Duration
+	chats    map[string]*wecomProgressState
+}
+
+type wecomProgressState struct {
+	lastSent    time.Time
+	lastContent string
+}
+
+func newWeComProgress() *wecomProgress {
+	return &wecomProgress{
+		interval: wecomProgressInterval,
+		chats:    make(map[string]*wecomProgressState),
+	}
+}
+
+// start marks chatID as waiting on a reply; the thinking message counts as
+// the first update.
+func (p *wecomProgress) start(chatID, thinking string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chats[chatID] = &wecomProgressState{lastSent: time.Now(), lastContent: thinking}
+}
+
+// allow reports whether a progress update should be sent now. Updates are
+// only sent for chats with a pending reply, never repeat the previous update,
+// and are rate limited to one per interval.
+func (p *wecomProgress) allow(chatID, content string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.chats[chatID]
+	if !ok || content == "" || content == state.lastContent {
+		return false
+	}
+	if time.Since(state.lastSent) < p.interval {
+		return false
+	}
+
+	state.lastSent = time.Now()
+	state.lastContent = content
+	return true
+}
+
+// finish clears the pending state once the final reply has been sent.
+func (p *wecomProgress) finish(chatID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.chats, chatID)
+}
+
+// SupportsProgress reports whether interim progress messages are delivered
+func (c *WeComAppChannel) SupportsProgress() bool {
+	return c.config.ProgressiveReply
+}
+
+// startProgress sends the thinking message for a newly accepted message
+func (c *WeComAppChannel) startProgress(ctx context.Context, chatID string) {
+	thinking := c.text(i18n.Thinking)
+	if err := c.sendTextMessage(ctx, chatID, thinking); err != nil {
+		logger.WarnCF("wecom_app", "Failed to send thinking message", map[string]any{
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.progress.start(chatID, thinking)
+}
+
+// SupportsProgress reports whether interim progress messages are delivered
+func (c *WeComBotChannel) SupportsProgress() bool {
+	return c.config.ProgressiveReply
+}
+
+// startProgress sends the thinking message for a newly accepted message
+func (c *WeComBotChannel) startProgress(ctx context.Context, chatID string) {
+	thinking := c.text(i18n.Thinking)
+	if err := c.sendWebhookReply(ctx, chatID, thinking); err != nil {
+		logger.WarnCF("wecom", "Failed to send thinking message", map[string]any{
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.progress.start(chatID, thinking)
+}
@@ -0,0 +1,76 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom callback replay protection
+// Rejects callbacks whose timestamp is too far from now, and callbacks that
+// reuse the timestamp and nonce of one already accepted
+
+package channels
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultWeComTimestampWindow applies when timestamp_window is not set
+const defaultWeComTimestampWindow = 5 * time.Minute
+
+type weComReplayGuard struct {
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+	seen   map[string]time.Time // timestamp:nonce -> when it falls out of the window
+}
+
+func newWeComReplayGuard(windowSeconds int) *weComReplayGuard {
+	window := time.Duration(windowSeconds) * time.Second
+	if window <= 0 {
+		window = defaultWeComTimestampWindow
+	}
+	return &weComReplayGuard{
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// checkTimestamp rejects a callback timestamp (unix seconds) more than the
+// window away from now, in either direction to allow for clock skew
+func (g *weComReplayGuard) checkTimestamp(timestamp string) (time.Time, error) {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	ts := time.Unix(sec, 0)
+	if age := g.now().Sub(ts); age > g.window || age < -g.window {
+		return time.Time{}, fmt.Errorf("timestamp %s is outside the %s window", timestamp, g.window)
+	}
+	return ts, nil
+}
+
+// check accepts a callback once: its timestamp must be within the window and
+// the timestamp and nonce must not have been seen before. Nonces are only
+// remembered for as long as their timestamp would pass checkTimestamp.
+func (g *weComReplayGuard) check(timestamp, nonce string) error {
+	ts, err := g.checkTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for key, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, key)
+		}
+	}
+
+	key := timestamp + ":" + nonce
+	if _, ok := g.seen[key]; ok {
+		return fmt.Errorf("nonce %q was already used", nonce)
+	}
+	g.seen[key] = ts.Add(g.window)
+	return nil
+}
@@ -0,0 +1,41 @@
+package channels
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWeComReplayGuard(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := newWeComReplayGuard(300)
+	g.now = func() time.Time { return now }
+	ts := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).Unix(), 10) }
+
+	if err := g.check(ts(-time.Minute), "n1"); err != nil {
+		t.Fatalf("fresh callback rejected: %v", err)
+	}
+	if err := g.check(ts(-time.Minute), "n1"); err == nil {
+		t.Error("replayed callback accepted")
+	}
+	if err := g.check(ts(-time.Minute), "n2"); err != nil {
+		t.Errorf("new nonce rejected: %v", err)
+	}
+	if err := g.check(ts(-10*time.Minute), "n3"); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+	if err := g.check(ts(10*time.Minute), "n4"); err == nil {
+		t.Error("future timestamp accepted")
+	}
+	if err := g.check("not-a-number", "n5"); err == nil {
+		t.Error("invalid timestamp accepted")
+	}
+
+	// Nonces are forgotten once their timestamp leaves the window
+	key := ts(-time.Minute) + ":n1"
+	now = now.Add(5 * time.Minute)
+	g.check(ts(0), "n6")
+	if _, ok := g.seen[key]; ok {
+		t.Error("nonce kept after its timestamp left the window")
+	}
+}
@@ -17,8 +17,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -339,6 +341,109 @@ func TestWeComBotPKCS7Unpad(t *testing.T) {
 	}
 }
 
+func TestWeComEncryptMessage(t *testing.T) {
+	aesKey := generateTestAESKey()
+
+	tests := []struct {
+		name      string
+		message   string
+		receiveid string
+	}{
+		{name: "AIBOT reply without receiveid", message: `{"msgtype":"text","text":{"content":"hi"}}`},
+		{name: "app reply with corp_id", message: "<xml><Content>你好</Content></xml>", receiveid: "test_corp_id"},
+		{name: "block aligned payload", message: "123456789012", receiveid: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := WeComEncryptMessage(tt.message, aesKey, tt.receiveid)
+			if err != nil {
+				t.Fatalf("WeComEncryptMessage() error = %v", err)
+			}
+
+			raw, _ := base64.StdEncoding.DecodeString(encrypted)
+			if len(raw)%wecomBlockSize != 0 {
+				t.Errorf("ciphertext length %d is not a multiple of %d", len(raw), wecomBlockSize)
+			}
+
+			decrypted, err := WeComDecryptMessageWithVerify(encrypted, aesKey, tt.receiveid)
+			if err != nil {
+				t.Fatalf("round trip decrypt error = %v", err)
+			}
+			if decrypted != tt.message {
+				t.Errorf("round trip = %q, want %q", decrypted, tt.message)
+			}
+		})
+	}
+
+	t.Run("random prefix makes ciphertexts differ", func(t *testing.T) {
+		a, _ := WeComEncryptMessage("same", aesKey, "")
+		b, _ := WeComEncryptMessage("same", aesKey, "")
+		if a == b {
+			t.Error("expected different ciphertexts for identical plaintexts")
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := WeComEncryptMessage("x", "short", ""); err == nil {
+			t.Error("expected error for invalid AES key")
+		}
+	})
+}
+
+func TestWeComEncryptReply(t *testing.T) {
+	aesKey := generateTestAESKey()
+	token := "test_token"
+
+	envelope, err := WeComEncryptReply("pong", token, aesKey, "")
+	if err != nil {
+		t.Fatalf("WeComEncryptReply() error = %v", err)
+	}
+
+	if !WeComVerifySignature(token, envelope.MsgSignature, envelope.TimeStamp, envelope.Nonce, envelope.Encrypt) {
+		t.Error("envelope signature does not verify")
+	}
+
+	xmlData, err := envelope.XML()
+	if err != nil {
+		t.Fatalf("XML() error = %v", err)
+	}
+	var parsed struct {
+		Encrypt      string `xml:"Encrypt"`
+		MsgSignature string `xml:"MsgSignature"`
+		TimeStamp    string `xml:"TimeStamp"`
+		Nonce        string `xml:"Nonce"`
+	}
+	if err := xml.Unmarshal(xmlData, &parsed); err != nil {
+		t.Fatalf("failed to parse XML envelope: %v", err)
+	}
+	if parsed.Encrypt != envelope.Encrypt || parsed.MsgSignature != envelope.MsgSignature {
+		t.Errorf("XML envelope mismatch: %s", xmlData)
+	}
+	if !strings.Contains(string(xmlData), "<![CDATA[") {
+		t.Errorf("expected CDATA sections in XML envelope: %s", xmlData)
+	}
+
+	jsonData, err := envelope.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+	var parsedJSON map[string]string
+	if err := json.Unmarshal(jsonData, &parsedJSON); err != nil {
+		t.Fatalf("failed to parse JSON envelope: %v", err)
+	}
+	for _, key := range []string{"encrypt", "msgsignature", "timestamp", "nonce"} {
+		if parsedJSON[key] == "" {
+			t.Errorf("JSON envelope missing %q: %s", key, jsonData)
+		}
+	}
+
+	decrypted, err := WeComDecryptMessage(parsedJSON["encrypt"], aesKey)
+	if err != nil || decrypted != "pong" {
+		t.Errorf("decrypted = %q, err = %v", decrypted, err)
+	}
+}
+
 func TestWeComBotHandleVerification(t *testing.T) {
 	msgBus := bus.NewMessageBus()
 	aesKey := generateTestAESKey()
@@ -352,7 +457,7 @@ func TestWeComBotHandleVerification(t *testing.T) {
 	t.Run("valid verification request", func(t *testing.T) {
 		echostr := "test_echostr_123"
 		encryptedEchostr, _ := encryptTestMessage(echostr, aesKey)
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignature("test_token", timestamp, nonce, encryptedEchostr)
 
@@ -387,7 +492,7 @@ func TestWeComBotHandleVerification(t *testing.T) {
 	t.Run("invalid signature", func(t *testing.T) {
 		echostr := "test_echostr"
 		encryptedEchostr, _ := encryptTestMessage(echostr, aesKey)
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 
 		req := httptest.NewRequest(
@@ -439,7 +544,7 @@ func TestWeComBotHandleMessageCallback(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignature("test_token", timestamp, nonce, encrypted)
 
@@ -458,6 +563,18 @@ func TestWeComBotHandleMessageCallback(t *testing.T) {
 		if w.Body.String() != "success" {
 			t.Errorf("response body = %q, want %q", w.Body.String(), "success")
 		}
+
+		// The same callback sent again is a replay
+		req = httptest.NewRequest(
+			http.MethodPost,
+			"/webhook/wecom?msg_signature="+signature+"&timestamp="+timestamp+"&nonce="+nonce,
+			bytes.NewReader(wrapperData),
+		)
+		w = httptest.NewRecorder()
+		ch.handleMessageCallback(context.Background(), w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("replayed callback status code = %d, want %d", w.Code, http.StatusForbidden)
+		}
 	})
 
 	t.Run("valid group message callback", func(t *testing.T) {
@@ -485,8 +602,8 @@ func TestWeComBotHandleMessageCallback(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
-		nonce := "test_nonce"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := "test_nonce_group"
 		signature := generateSignature("test_token", timestamp, nonce, encrypted)
 
 		req := httptest.NewRequest(
@@ -518,7 +635,7 @@ func TestWeComBotHandleMessageCallback(t *testing.T) {
 	})
 
 	t.Run("invalid XML", func(t *testing.T) {
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignature("test_token", timestamp, nonce, "")
 
@@ -545,7 +662,7 @@ func TestWeComBotHandleMessageCallback(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 
 		req := httptest.NewRequest(
@@ -643,7 +760,7 @@ func TestWeComBotHandleWebhook(t *testing.T) {
 	t.Run("GET request calls verification", func(t *testing.T) {
 		echostr := "test_echostr"
 		encoded := base64.StdEncoding.EncodeToString([]byte(echostr))
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignature("test_token", timestamp, nonce, encoded)
 
@@ -670,7 +787,7 @@ func TestWeComBotHandleWebhook(t *testing.T) {
 		}
 		wrapperData, _ := xml.Marshal(encryptedWrapper)
 
-		timestamp := "1234567890"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 		nonce := "test_nonce"
 		signature := generateSignature("test_token", timestamp, nonce, encryptedWrapper.Encrypt)
 
@@ -783,3 +900,26 @@ func TestWeComBotMessageStructure(t *testing.T) {
 		t.Errorf("Text.Content = %q, want %q", msg.Text.Content, "Hello World")
 	}
 }
+
+func TestWeComDecryptMedia(t *testing.T) {
+	aesKey := generateTestAESKey()
+	key, _ := base64.StdEncoding.DecodeString(aesKey + "=")
+	original := []byte("binary image payload \x00\x01\x02")
+
+	plain := pkcs7PadWeCom(append([]byte(nil), original...))
+	block, _ := aes.NewCipher(key)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(encrypted, plain)
+
+	decrypted, err := WeComDecryptMedia(encrypted, aesKey)
+	if err != nil {
+		t.Fatalf("WeComDecryptMedia() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, original) {
+		t.Errorf("WeComDecryptMedia() = %q, want %q", decrypted, original)
+	}
+
+	if _, err := WeComDecryptMedia([]byte("not a block multiple"), aesKey); err == nil {
+		t.Error("expected error for truncated ciphertext")
+	}
+}
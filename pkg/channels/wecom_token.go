@@ -0,0 +1,132 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// WeCom App access_token management
+// Caches the token, refreshes it ahead of expiry with one request at a time,
+// and lets API calls retry once when WeCom reports the token invalid
+
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// wecomTokenRefreshMargin is how long before its expiry a token is replaced
+const wecomTokenRefreshMargin = 5 * time.Minute
+
+// WeCom errcodes meaning the access_token must be fetched again
+const (
+	wecomErrInvalidToken = 40014
+	wecomErrExpiredToken = 42001
+)
+
+// wecomAPIError is a non-zero errcode returned by the WeCom API
+type wecomAPIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *wecomAPIError) Error() string {
+	return fmt.Sprintf("API error: %s (code: %d)", e.Msg, e.Code)
+}
+
+// isWeComTokenError reports whether err means the access_token was rejected
+func isWeComTokenError(err error) bool {
+	var apiErr *wecomAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == wecomErrInvalidToken || apiErr.Code == wecomErrExpiredToken
+}
+
+// wecomTokenFetcher requests a new access_token and its lifetime
+type wecomTokenFetcher func(ctx context.Context) (token string, expiresIn time.Duration, err error)
+
+// wecomTokenManager caches an access_token for all API calls of a channel.
+// Refreshes are serialized, so goroutines that find the token stale at the
+// same time share one gettoken request instead of each making their own.
+type wecomTokenManager struct {
+	fetch     wecomTokenFetcher
+	refreshMu sync.Mutex // held while fetching
+
+	mu     sync.RWMutex
+	token  string
+	expiry time.Time // when the token should be replaced, ahead of the real expiry
+}
+
+func newWeComTokenManager(fetch wecomTokenFetcher) *wecomTokenManager {
+	return &wecomTokenManager{fetch: fetch}
+}
+
+// cached returns the current token, or "" if there is none or it is due
+// for refresh
+func (m *wecomTokenManager) cached() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if time.Now().After(m.expiry) {
+		return ""
+	}
+	return m.token
+}
+
+func (m *wecomTokenManager) set(token string, expiry time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = token
+	m.expiry = expiry
+}
+
+// get returns a valid token, fetching one if needed
+func (m *wecomTokenManager) get(ctx context.Context) (string, error) {
+	if token := m.cached(); token != "" {
+		return token, nil
+	}
+	return m.refresh(ctx, "")
+}
+
+// refresh fetches a new token to replace stale, the token an API call just
+// had rejected ("" if there was none). If another goroutine already replaced
+// it while this one waited, that token is returned without fetching again.
+func (m *wecomTokenManager) refresh(ctx context.Context, stale string) (string, error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	if token := m.cached(); token != "" && token != stale {
+		return token, nil
+	}
+
+	token, expiresIn, err := m.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	margin := wecomTokenRefreshMargin
+	if expiresIn <= 2*margin {
+		// Short-lived tokens (never seen in practice) are used for half their life
+		margin = expiresIn / 2
+	}
+	m.set(token, time.Now().Add(expiresIn-margin))
+	return token, nil
+}
+
+// withToken runs call with the access token. If WeCom rejects the token as
+// invalid or expired, it is refreshed and call is retried once.
+func (c *WeComAppChannel) withToken(ctx context.Context, call func(accessToken string) error) error {
+	accessToken, err := c.tokens.get(ctx)
+	if err != nil {
+		return fmt.Errorf("no valid access token available: %w", err)
+	}
+
+	err = call(accessToken)
+	if !isWeComTokenError(err) {
+		return err
+	}
+
+	accessToken, refreshErr := c.tokens.refresh(ctx, accessToken)
+	if refreshErr != nil {
+		return fmt.Errorf("%w (token refresh failed: %v)", err, refreshErr)
+	}
+	return call(accessToken)
+}
@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestWeComTokenManagerSerializesRefresh(t *testing.T) {
+	var fetches atomic.Int32
+	m := newWeComTokenManager(func(ctx context.Context) (string, time.Duration, error) {
+		fetches.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "token", 2 * time.Hour, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := m.get(context.Background()); err != nil || token != "token" {
+				t.Errorf("get() = %q, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d tokens, want 1", n)
+	}
+	if _, err := m.refresh(context.Background(), "older"); err != nil || fetches.Load() != 1 {
+		t.Errorf("refresh of an already replaced token fetched again")
+	}
+	if _, err := m.refresh(context.Background(), "token"); err != nil || fetches.Load() != 2 {
+		t.Errorf("refresh of the current token did not fetch")
+	}
+}
+
+func TestWeComAppRetriesRejectedToken(t *testing.T) {
+	var fetches, sends atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			fetches.Add(1)
+			w.Write([]byte(`{"errcode":0,"access_token":"fresh","expires_in":7200}`))
+		case "/cgi-bin/message/send":
+			sends.Add(1)
+			if r.URL.Query().Get("access_token") != "fresh" {
+				w.Write([]byte(`{"errcode":42001,"errmsg":"access_token expired"}`))
+				return
+			}
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	ch, err := NewWeComAppChannel(config.WeComAppConfig{
+		CorpID:     "test_corp_id",
+		CorpSecret: "test_secret",
+		AgentID:    1000002,
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewWeComAppChannel() error = %v", err)
+	}
+	ch.apiBase = server.URL
+	ch.tokens.set("revoked", time.Now().Add(time.Hour))
+	ch.setRunning(true)
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "user123", Content: "hi"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if fetches.Load() != 1 || sends.Load() != 2 {
+		t.Errorf("fetches = %d, sends = %d, want 1 and 2", fetches.Load(), sends.Load())
+	}
+	if token := ch.getAccessToken(); token != "fresh" {
+		t.Errorf("cached token = %q, want fresh", token)
+	}
+}
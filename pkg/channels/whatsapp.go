@@ -121,9 +121,23 @@ func (c *WhatsAppChannel) listen(ctx context.Context) {
 
 			_, message, err := conn.ReadMessage()
 			if err != nil {
-				log.Printf("WhatsApp read error: %v", err)
-				time.Sleep(2 * time.Second)
-				continue
+				c.mu.Lock()
+				dropped := c.conn == conn // otherwise Stop closed it
+				if dropped {
+					conn.Close()
+					c.conn = nil
+					c.connected = false
+				}
+				c.mu.Unlock()
+				if !dropped {
+					return
+				}
+
+				// Report the channel down so the manager reconnects it with backoff
+				log.Printf("WhatsApp bridge connection lost: %v", err)
+				c.recordError(fmt.Errorf("bridge connection lost: %w", err))
+				c.setRunning(false)
+				return
 			}
 
 			var msg map[string]any
@@ -182,6 +196,11 @@ func (c *WhatsAppChannel) handleIncomingMessage(msg map[string]any) {
 		metadata["peer_kind"] = "direct"
 		metadata["peer_id"] = senderID
 	} else {
+		// The bridge sets "mentioned" when the bot's number is @-ed
+		mentioned, _ := msg["mentioned"].(bool)
+		if !c.acceptGroupMessage(chatID, senderID, content, mentioned) {
+			return
+		}
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = chatID
 	}
@@ -0,0 +1,914 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// XMPP (Jabber) channel implementation
+// Speaks the client protocol directly: STARTTLS, SASL PLAIN, resource binding,
+// message carbons (XEP-0280) and multi-user chat (XEP-0045)
+
+package channels
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	xmppNSStream  = "http://etherx.jabber.org/streams"
+	xmppNSTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	xmppNSSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	xmppNSBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	xmppNSSession = "urn:ietf:params:xml:ns:xmpp-session"
+	xmppNSCarbons = "urn:xmpp:carbons:2"
+	xmppNSMUC     = "http://jabber.org/protocol/muc"
+
+	xmppDialTimeout       = 15 * time.Second
+	xmppKeepaliveInterval = 60 * time.Second
+	xmppMinReconnectDelay = 5 * time.Second
+	xmppMaxReconnectDelay = 5 * time.Minute
+)
+
+// XMPPChannel implements the Channel interface for XMPP servers such as
+// ejabberd or Prosody.
+type XMPPChannel struct {
+	*BaseChannel
+	config  config.XMPPConfig
+	jid     xmppJID
+	rooms   map[string]struct{} // bare room JIDs
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stream  *xmppStream
+	mu      sync.Mutex
+	writeMu sync.Mutex
+	idSeq   uint64
+}
+
+// xmppJID is a parsed local@domain/resource address.
+type xmppJID struct {
+	Local    string
+	Domain   string
+	Resource string
+}
+
+func parseXMPPJID(s string) (xmppJID, error) {
+	var j xmppJID
+	rest := strings.TrimSpace(s)
+	if idx := strings.Index(rest, "/"); idx >= 0 {
+		j.Resource = rest[idx+1:]
+		rest = rest[:idx]
+	}
+	if idx := strings.Index(rest, "@"); idx >= 0 {
+		j.Local = rest[:idx]
+		rest = rest[idx+1:]
+	}
+	j.Domain = strings.ToLower(rest)
+	if j.Domain == "" {
+		return j, fmt.Errorf("invalid JID %q: missing domain", s)
+	}
+	return j, nil
+}
+
+func (j xmppJID) Bare() string {
+	if j.Local == "" {
+		return j.Domain
+	}
+	return j.Local + "@" + j.Domain
+}
+
+func (j xmppJID) String() string {
+	if j.Resource == "" {
+		return j.Bare()
+	}
+	return j.Bare() + "/" + j.Resource
+}
+
+type xmppFeatures struct {
+	XMLName  xml.Name `xml:"http://etherx.jabber.org/streams features"`
+	StartTLS *struct {
+		Required *struct{} `xml:"required"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+	Bind    *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session *struct {
+		Optional *struct{} `xml:"optional"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+type xmppStanzaError struct {
+	Type      string     `xml:"type,attr"`
+	Condition []xml.Name `xml:",any"`
+}
+
+func (e *xmppStanzaError) String() string {
+	if e == nil {
+		return ""
+	}
+	for _, c := range e.Condition {
+		if c.Local != "text" {
+			return e.Type + "/" + c.Local
+		}
+	}
+	return e.Type
+}
+
+type xmppMessage struct {
+	XMLName  xml.Name         `xml:"message"`
+	From     string           `xml:"from,attr"`
+	To       string           `xml:"to,attr"`
+	ID       string           `xml:"id,attr"`
+	Type     string           `xml:"type,attr"`
+	Body     string           `xml:"body"`
+	Delay    *struct{}        `xml:"urn:xmpp:delay delay"`
+	Received *xmppCarbon      `xml:"urn:xmpp:carbons:2 received"`
+	Sent     *xmppCarbon      `xml:"urn:xmpp:carbons:2 sent"`
+	Error    *xmppStanzaError `xml:"error"`
+}
+
+type xmppCarbon struct {
+	Forwarded struct {
+		Message *xmppMessage `xml:"message"`
+	} `xml:"urn:xmpp:forward:0 forwarded"`
+}
+
+type xmppPresence struct {
+	XMLName xml.Name         `xml:"presence"`
+	From    string           `xml:"from,attr"`
+	Type    string           `xml:"type,attr"`
+	Error   *xmppStanzaError `xml:"error"`
+}
+
+type xmppIQ struct {
+	XMLName xml.Name  `xml:"iq"`
+	From    string    `xml:"from,attr"`
+	ID      string    `xml:"id,attr"`
+	Type    string    `xml:"type,attr"`
+	Ping    *struct{} `xml:"urn:xmpp:ping ping"`
+	Bind    *struct {
+		JID string `xml:"jid"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Error *xmppStanzaError `xml:"error"`
+}
+
+// xmppStream wraps one live connection and its XML decoder. The decoder has
+// to be replaced whenever the stream is restarted (after STARTTLS and SASL).
+type xmppStream struct {
+	conn net.Conn
+	dec  *xml.Decoder
+}
+
+func NewXMPPChannel(cfg config.XMPPConfig, messageBus *bus.MessageBus) (*XMPPChannel, error) {
+	if cfg.JID == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("xmpp jid and password are required")
+	}
+
+	jid, err := parseXMPPJID(cfg.JID)
+	if err != nil {
+		return nil, err
+	}
+	if jid.Local == "" {
+		return nil, fmt.Errorf("xmpp jid must include a local part: %s", cfg.JID)
+	}
+	if jid.Resource == "" {
+		jid.Resource = cfg.Resource
+	}
+	if jid.Resource == "" {
+		jid.Resource = "picoclaw"
+	}
+	if cfg.Nickname == "" {
+		cfg.Nickname = jid.Local
+	}
+
+	rooms := make(map[string]struct{}, len(cfg.Rooms))
+	for _, room := range cfg.Rooms {
+		roomJID, err := parseXMPPJID(room)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xmpp room: %w", err)
+		}
+		rooms[roomJID.Bare()] = struct{}{}
+	}
+
+	base := NewBaseChannel("xmpp", cfg, messageBus, cfg.AllowFrom)
+
+	return &XMPPChannel{
+		BaseChannel: base,
+		config:      cfg,
+		jid:         jid,
+		rooms:       rooms,
+	}, nil
+}
+
+func (c *XMPPChannel) Start(ctx context.Context) error {
+	logger.InfoCF("xmpp", "Starting XMPP channel", map[string]any{
+		"jid":   c.jid.Bare(),
+		"rooms": len(c.rooms),
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.run()
+
+	c.setRunning(true)
+	logger.InfoC("xmpp", "XMPP channel started")
+	return nil
+}
+
+func (c *XMPPChannel) Stop(ctx context.Context) error {
+	logger.InfoC("xmpp", "Stopping XMPP channel")
+	c.setRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mu.Lock()
+	stream := c.stream
+	c.stream = nil
+	c.mu.Unlock()
+
+	if stream != nil {
+		_ = c.writeRaw(stream, "<presence type='unavailable'/></stream:stream>")
+		stream.conn.Close()
+	}
+
+	return nil
+}
+
+func (c *XMPPChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("xmpp channel not running")
+	}
+
+	c.mu.Lock()
+	stream := c.stream
+	c.mu.Unlock()
+
+	if stream == nil {
+		return fmt.Errorf("xmpp not connected")
+	}
+
+	to, err := parseXMPPJID(msg.ChatID)
+	if err != nil {
+		return err
+	}
+
+	msgType := "chat"
+	if c.isRoom(to.Bare()) {
+		msgType = "groupchat"
+		to.Resource = ""
+	}
+
+	stanza := fmt.Sprintf("<message to='%s' type='%s' id='%s'><body>%s</body></message>",
+		xmppEscape(to.String()), msgType, c.nextID(), xmppEscape(msg.Content))
+
+	if err := c.writeRaw(stream, stanza); err != nil {
+		logger.ErrorCF("xmpp", "Failed to send message", map[string]any{
+			"to":    to.String(),
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	return nil
+}
+
+// run keeps a session alive until the channel is stopped, reconnecting with
+// exponential backoff whenever the connection drops.
+func (c *XMPPChannel) run() {
+	delay := xmppMinReconnectDelay
+
+	for {
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		stream, err := c.connect()
+		if err != nil {
+			logger.ErrorCF("xmpp", "Connection failed", map[string]any{
+				"error":       err.Error(),
+				"retry_after": delay.String(),
+			})
+			c.recordError(err)
+		} else {
+			delay = xmppMinReconnectDelay
+			c.recordSuccess()
+
+			c.mu.Lock()
+			c.stream = stream
+			c.mu.Unlock()
+
+			err = c.listen(stream)
+
+			c.mu.Lock()
+			if c.stream == stream {
+				c.stream = nil
+			}
+			c.mu.Unlock()
+			stream.conn.Close()
+
+			if c.ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("xmpp", "Disconnected, will reconnect", map[string]any{
+				"error": fmt.Sprint(err),
+			})
+			c.recordError(fmt.Errorf("disconnected: %v", err))
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > xmppMaxReconnectDelay {
+			delay = xmppMaxReconnectDelay
+		}
+	}
+}
+
+// connect dials the server and runs stream negotiation up to an
+// authenticated, bound and present session.
+func (c *XMPPChannel) connect() (*xmppStream, error) {
+	addr, err := c.resolveAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: xmppDialTimeout}
+	conn, err := dialer.DialContext(c.ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	// Negotiation must not hang forever on an unresponsive server.
+	_ = conn.SetDeadline(time.Now().Add(xmppDialTimeout * 2))
+
+	stream := &xmppStream{conn: conn}
+	encrypted := false
+
+	if c.config.DirectTLS {
+		tlsConn := tls.Client(conn, c.tlsConfig())
+		if err := tlsConn.HandshakeContext(c.ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		stream.conn = tlsConn
+		encrypted = true
+	}
+
+	features, err := c.openStream(stream)
+	if err != nil {
+		stream.conn.Close()
+		return nil, err
+	}
+
+	if !encrypted {
+		if features.StartTLS == nil {
+			stream.conn.Close()
+			return nil, fmt.Errorf("server does not offer STARTTLS, refusing to authenticate in plaintext")
+		}
+		if features, err = c.startTLS(stream); err != nil {
+			stream.conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := c.authenticate(stream, features); err != nil {
+		stream.conn.Close()
+		return nil, err
+	}
+
+	if features, err = c.openStream(stream); err != nil {
+		stream.conn.Close()
+		return nil, err
+	}
+
+	if err := c.bind(stream, features); err != nil {
+		stream.conn.Close()
+		return nil, err
+	}
+
+	_ = stream.conn.SetDeadline(time.Time{})
+
+	if err := c.initSession(stream); err != nil {
+		stream.conn.Close()
+		return nil, err
+	}
+
+	logger.InfoCF("xmpp", "XMPP session established", map[string]any{
+		"jid":    c.jid.String(),
+		"server": addr,
+	})
+
+	return stream, nil
+}
+
+func (c *XMPPChannel) resolveAddr() (string, error) {
+	if c.config.Server != "" {
+		if _, _, err := net.SplitHostPort(c.config.Server); err == nil {
+			return c.config.Server, nil
+		}
+		return net.JoinHostPort(c.config.Server, c.defaultPort()), nil
+	}
+
+	service := "xmpp-client"
+	if c.config.DirectTLS {
+		service = "xmpps-client"
+	}
+	if _, records, err := net.DefaultResolver.LookupSRV(c.ctx, service, "tcp", c.jid.Domain); err == nil {
+		for _, rec := range records {
+			if rec.Target == "." {
+				continue
+			}
+			return net.JoinHostPort(strings.TrimSuffix(rec.Target, "."), strconv.Itoa(int(rec.Port))), nil
+		}
+	}
+
+	return net.JoinHostPort(c.jid.Domain, c.defaultPort()), nil
+}
+
+func (c *XMPPChannel) defaultPort() string {
+	if c.config.DirectTLS {
+		return "5223"
+	}
+	return "5222"
+}
+
+func (c *XMPPChannel) tlsConfig() *tls.Config {
+	// Certificates are issued for the JID domain, even when connecting to an
+	// explicitly configured host.
+	return &tls.Config{
+		ServerName:         c.jid.Domain,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.config.InsecureSkipVerify,
+	}
+}
+
+// openStream sends a fresh stream header and reads the server's features.
+func (c *XMPPChannel) openStream(stream *xmppStream) (*xmppFeatures, error) {
+	header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+		"xmlns:stream='%s' version='1.0'>", xmppEscape(c.jid.Domain), xmppNSStream)
+	if err := c.writeRaw(stream, header); err != nil {
+		return nil, fmt.Errorf("open stream: %w", err)
+	}
+
+	stream.dec = xml.NewDecoder(stream.conn)
+
+	for {
+		tok, err := stream.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("read stream header: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Space != xmppNSStream || se.Name.Local != "stream" {
+				return nil, fmt.Errorf("unexpected element <%s> instead of stream header", se.Name.Local)
+			}
+			break
+		}
+	}
+
+	se, err := stream.next()
+	if err != nil {
+		return nil, err
+	}
+
+	var features xmppFeatures
+	if se.Name.Space != xmppNSStream || se.Name.Local != "features" {
+		return nil, fmt.Errorf("expected stream features, got <%s>", se.Name.Local)
+	}
+	if err := stream.dec.DecodeElement(&features, &se); err != nil {
+		return nil, fmt.Errorf("decode stream features: %w", err)
+	}
+
+	return &features, nil
+}
+
+func (c *XMPPChannel) startTLS(stream *xmppStream) (*xmppFeatures, error) {
+	if err := c.writeRaw(stream, "<starttls xmlns='"+xmppNSTLS+"'/>"); err != nil {
+		return nil, err
+	}
+
+	se, err := stream.next()
+	if err != nil {
+		return nil, err
+	}
+	if se.Name.Space != xmppNSTLS || se.Name.Local != "proceed" {
+		return nil, fmt.Errorf("server refused STARTTLS")
+	}
+
+	tlsConn := tls.Client(stream.conn, c.tlsConfig())
+	if err := tlsConn.HandshakeContext(c.ctx); err != nil {
+		return nil, fmt.Errorf("tls handshake: %w", err)
+	}
+	stream.conn = tlsConn
+
+	return c.openStream(stream)
+}
+
+func (c *XMPPChannel) authenticate(stream *xmppStream, features *xmppFeatures) error {
+	supported := false
+	for _, mech := range features.Mechanisms.Mechanism {
+		if mech == "PLAIN" {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("server does not support SASL PLAIN (offered: %s)",
+			strings.Join(features.Mechanisms.Mechanism, ", "))
+	}
+
+	auth := fmt.Sprintf("<auth xmlns='%s' mechanism='PLAIN'>%s</auth>",
+		xmppNSSASL, saslPlain(c.jid.Local, c.config.Password))
+	if err := c.writeRaw(stream, auth); err != nil {
+		return err
+	}
+
+	se, err := stream.next()
+	if err != nil {
+		return err
+	}
+	if se.Name.Space != xmppNSSASL {
+		return fmt.Errorf("unexpected SASL response <%s>", se.Name.Local)
+	}
+
+	switch se.Name.Local {
+	case "success":
+		return stream.dec.Skip()
+	case "failure":
+		var failure xmppStanzaError
+		_ = stream.dec.DecodeElement(&failure, &se)
+		return fmt.Errorf("authentication failed: %s", failure.String())
+	default:
+		return fmt.Errorf("unexpected SASL response <%s>", se.Name.Local)
+	}
+}
+
+// saslPlain builds the RFC 4616 initial response with an empty authzid.
+func saslPlain(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+}
+
+func (c *XMPPChannel) bind(stream *xmppStream, features *xmppFeatures) error {
+	if features.Bind == nil {
+		return fmt.Errorf("server does not offer resource binding")
+	}
+
+	id := c.nextID()
+	req := fmt.Sprintf("<iq type='set' id='%s'><bind xmlns='%s'><resource>%s</resource></bind></iq>",
+		id, xmppNSBind, xmppEscape(c.jid.Resource))
+	iq, err := c.roundTrip(stream, id, req)
+	if err != nil {
+		return fmt.Errorf("bind resource: %w", err)
+	}
+
+	if iq.Bind != nil && iq.Bind.JID != "" {
+		if bound, err := parseXMPPJID(iq.Bind.JID); err == nil {
+			c.jid = bound
+		}
+	}
+
+	// RFC 3921 sessions are obsolete but some older servers still require them.
+	if features.Session != nil && features.Session.Optional == nil {
+		id = c.nextID()
+		req = fmt.Sprintf("<iq type='set' id='%s'><session xmlns='%s'/></iq>", id, xmppNSSession)
+		if _, err := c.roundTrip(stream, id, req); err != nil {
+			return fmt.Errorf("establish session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// roundTrip sends an IQ and waits for its response. It is only used during
+// negotiation, before the listener owns the decoder.
+func (c *XMPPChannel) roundTrip(stream *xmppStream, id, req string) (*xmppIQ, error) {
+	if err := c.writeRaw(stream, req); err != nil {
+		return nil, err
+	}
+
+	for {
+		se, err := stream.next()
+		if err != nil {
+			return nil, err
+		}
+		if se.Name.Local != "iq" {
+			if err := stream.dec.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var iq xmppIQ
+		if err := stream.dec.DecodeElement(&iq, &se); err != nil {
+			return nil, err
+		}
+		if iq.ID != id {
+			continue
+		}
+		if iq.Type == "error" {
+			return nil, fmt.Errorf("server error: %s", iq.Error.String())
+		}
+		return &iq, nil
+	}
+}
+
+// initSession announces presence, enables carbons and joins configured rooms.
+func (c *XMPPChannel) initSession(stream *xmppStream) error {
+	if err := c.writeRaw(stream, "<presence/>"); err != nil {
+		return err
+	}
+
+	carbons := fmt.Sprintf("<iq type='set' id='%s'><enable xmlns='%s'/></iq>", c.nextID(), xmppNSCarbons)
+	if err := c.writeRaw(stream, carbons); err != nil {
+		return err
+	}
+
+	for room := range c.rooms {
+		join := fmt.Sprintf("<presence to='%s/%s'><x xmlns='%s'><history maxstanzas='0'/></x></presence>",
+			xmppEscape(room), xmppEscape(c.config.Nickname), xmppNSMUC)
+		if err := c.writeRaw(stream, join); err != nil {
+			return err
+		}
+		logger.InfoCF("xmpp", "Joining room", map[string]any{
+			"room": room,
+			"nick": c.config.Nickname,
+		})
+	}
+
+	go c.keepalive(stream)
+	return nil
+}
+
+func (c *XMPPChannel) keepalive(stream *xmppStream) {
+	ticker := time.NewTicker(xmppKeepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			current := c.stream
+			c.mu.Unlock()
+			if current != stream {
+				return
+			}
+			// RFC 6120 §4.6.1 whitespace keepalive
+			if err := c.writeRaw(stream, " "); err != nil {
+				stream.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *XMPPChannel) listen(stream *xmppStream) error {
+	for {
+		se, err := stream.next()
+		if err != nil {
+			return err
+		}
+
+		switch se.Name.Local {
+		case "message":
+			var msg xmppMessage
+			if err := stream.dec.DecodeElement(&msg, &se); err != nil {
+				return err
+			}
+			c.handleMessage(&msg)
+
+		case "presence":
+			var pres xmppPresence
+			if err := stream.dec.DecodeElement(&pres, &se); err != nil {
+				return err
+			}
+			if pres.Type == "error" {
+				logger.WarnCF("xmpp", "Presence error", map[string]any{
+					"from":  pres.From,
+					"error": pres.Error.String(),
+				})
+			}
+
+		case "iq":
+			var iq xmppIQ
+			if err := stream.dec.DecodeElement(&iq, &se); err != nil {
+				return err
+			}
+			c.handleIQ(stream, &iq)
+
+		default:
+			if se.Name.Space == xmppNSStream && se.Name.Local == "error" {
+				var streamErr xmppStanzaError
+				_ = stream.dec.DecodeElement(&streamErr, &se)
+				return fmt.Errorf("stream error: %s", streamErr.String())
+			}
+			if err := stream.dec.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *XMPPChannel) handleIQ(stream *xmppStream, iq *xmppIQ) {
+	if iq.Type != "get" && iq.Type != "set" {
+		return
+	}
+
+	var reply string
+	if iq.Ping != nil {
+		reply = fmt.Sprintf("<iq type='result' to='%s' id='%s'/>", xmppEscape(iq.From), xmppEscape(iq.ID))
+	} else {
+		reply = fmt.Sprintf("<iq type='error' to='%s' id='%s'><error type='cancel'>"+
+			"<service-unavailable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>",
+			xmppEscape(iq.From), xmppEscape(iq.ID))
+	}
+
+	if err := c.writeRaw(stream, reply); err != nil {
+		logger.DebugCF("xmpp", "Failed to answer iq", map[string]any{
+			"id":    iq.ID,
+			"error": err.Error(),
+		})
+	}
+}
+
+func (c *XMPPChannel) handleMessage(msg *xmppMessage) {
+	if msg.Type == "error" {
+		logger.WarnCF("xmpp", "Message error", map[string]any{
+			"from":  msg.From,
+			"error": msg.Error.String(),
+		})
+		return
+	}
+
+	if msg.Received != nil || msg.Sent != nil {
+		inner, ok := c.unwrapCarbon(msg)
+		if !ok {
+			return
+		}
+		msg = inner
+	}
+
+	content := strings.TrimSpace(msg.Body)
+	if content == "" {
+		return
+	}
+
+	from, err := parseXMPPJID(msg.From)
+	if err != nil {
+		logger.DebugCF("xmpp", "Ignoring message with invalid sender", map[string]any{
+			"from": msg.From,
+		})
+		return
+	}
+
+	metadata := map[string]string{
+		"message_id": msg.ID,
+		"xmpp_type":  msg.Type,
+	}
+
+	var senderID, chatID string
+
+	if msg.Type == "groupchat" {
+		room := from.Bare()
+		// Skip our own echo, room subjects and history replayed on join
+		if !c.isRoom(room) || from.Resource == "" || from.Resource == c.config.Nickname || msg.Delay != nil {
+			return
+		}
+
+		triggered, stripped := checkXMPPMention(content, c.config.Nickname)
+		if !c.acceptGroupMessage(room, from.Resource, content, triggered) {
+			logger.DebugCF("xmpp", "Room message ignored (no mention)", map[string]any{
+				"room": room,
+				"nick": from.Resource,
+			})
+			return
+		}
+		content = stripped
+
+		senderID = from.String()
+		chatID = room
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = room
+		metadata["sender_name"] = from.Resource
+	} else {
+		if from.Bare() == c.jid.Bare() {
+			return
+		}
+		senderID = from.Bare()
+		chatID = from.Bare()
+		metadata["peer_kind"] = "direct"
+		metadata["peer_id"] = senderID
+	}
+
+	logger.InfoCF("xmpp", "Received message", map[string]any{
+		"sender":  senderID,
+		"chat_id": chatID,
+		"length":  len(content),
+		"content": truncate(content, 100),
+	})
+
+	c.HandleMessage(senderID, chatID, content, nil, metadata)
+}
+
+// unwrapCarbon returns the forwarded message of a received carbon. Sent
+// carbons are our own outgoing messages from other clients and are dropped,
+// as are carbons that were not delivered by our own account (spoofing).
+func (c *XMPPChannel) unwrapCarbon(msg *xmppMessage) (*xmppMessage, bool) {
+	if msg.From != "" && msg.From != c.jid.Bare() {
+		logger.WarnCF("xmpp", "Dropping carbon from foreign sender", map[string]any{
+			"from": msg.From,
+		})
+		return nil, false
+	}
+	if msg.Received == nil || msg.Received.Forwarded.Message == nil {
+		return nil, false
+	}
+	return msg.Received.Forwarded.Message, true
+}
+
+func (c *XMPPChannel) isRoom(jid string) bool {
+	_, ok := c.rooms[jid]
+	return ok
+}
+
+func (c *XMPPChannel) nextID() string {
+	return "picoclaw-" + strconv.FormatUint(atomic.AddUint64(&c.idSeq, 1), 10)
+}
+
+func (c *XMPPChannel) writeRaw(stream *xmppStream, data string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := io.WriteString(stream.conn, data)
+	return err
+}
+
+// next returns the next top-level start element, or io.EOF when the server
+// closes the stream.
+func (s *xmppStream) next() (xml.StartElement, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Space == xmppNSStream && t.Name.Local == "stream" {
+				return xml.StartElement{}, io.EOF
+			}
+		}
+	}
+}
+
+// checkXMPPMention reports whether a room message addresses the bot, either
+// with the usual "nick: ..." prefix or an inline "@nick", and returns the
+// message with the address removed.
+func checkXMPPMention(content, nick string) (bool, string) {
+	if nick == "" {
+		return false, content
+	}
+
+	lower := strings.ToLower(content)
+	lowerNick := strings.ToLower(nick)
+
+	if strings.HasPrefix(lower, lowerNick) {
+		rest := content[len(nick):]
+		if rest == "" {
+			return true, ""
+		}
+		switch rest[0] {
+		case ':', ',', ' ':
+			return true, strings.TrimSpace(strings.TrimLeft(rest, ":, "))
+		}
+	}
+
+	if idx := strings.Index(lower, "@"+lowerNick); idx >= 0 {
+		stripped := content[:idx] + content[idx+len(nick)+1:]
+		return true, strings.TrimSpace(stripped)
+	}
+
+	return false, content
+}
+
+func xmppEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,204 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestXMPPChannel(t *testing.T, allowFrom ...string) (*XMPPChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewXMPPChannel(config.XMPPConfig{
+		JID:       "bot@example.org",
+		Password:  "secret",
+		Rooms:     []string{"lounge@conference.example.org"},
+		Nickname:  "claw",
+		AllowFrom: allowFrom,
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewXMPPChannel() error = %v", err)
+	}
+	return ch, msgBus
+}
+
+func decodeXMPPMessage(t *testing.T, raw string) *xmppMessage {
+	t.Helper()
+	var msg xmppMessage
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("unmarshal stanza: %v", err)
+	}
+	return &msg
+}
+
+func expectInbound(t *testing.T, msgBus *bus.MessageBus) (bus.InboundMessage, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return msgBus.ConsumeInbound(ctx)
+}
+
+func TestParseXMPPJID(t *testing.T) {
+	tests := []struct {
+		in       string
+		bare     string
+		resource string
+		wantErr  bool
+	}{
+		{in: "alice@Example.org/phone", bare: "alice@example.org", resource: "phone"},
+		{in: "alice@example.org", bare: "alice@example.org"},
+		{in: "conference.example.org", bare: "conference.example.org"},
+		{in: "room@conf.example.org/nick/with/slash", bare: "room@conf.example.org", resource: "nick/with/slash"},
+		{in: "alice@", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			jid, err := parseXMPPJID(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if jid.Bare() != tt.bare || jid.Resource != tt.resource {
+				t.Errorf("got bare=%q resource=%q, want bare=%q resource=%q",
+					jid.Bare(), jid.Resource, tt.bare, tt.resource)
+			}
+		})
+	}
+}
+
+func TestCheckXMPPMention(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+		rest    string
+	}{
+		{content: "claw: what time is it?", want: true, rest: "what time is it?"},
+		{content: "Claw, hello", want: true, rest: "hello"},
+		{content: "hey @claw check this", want: true, rest: "hey  check this"},
+		{content: "clawing at the door", want: false},
+		{content: "nothing to see", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			got, rest := checkXMPPMention(tt.content, "claw")
+			if got != tt.want {
+				t.Fatalf("checkXMPPMention() = %v, want %v", got, tt.want)
+			}
+			if tt.want && rest != tt.rest {
+				t.Errorf("stripped = %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestSASLPlain(t *testing.T) {
+	decoded, err := base64.StdEncoding.DecodeString(saslPlain("bot", "secret"))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(decoded) != "\x00bot\x00secret" {
+		t.Errorf("unexpected PLAIN payload %q", decoded)
+	}
+}
+
+func TestXMPPHandleMessage(t *testing.T) {
+	t.Run("direct chat", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t)
+		ch.handleMessage(decodeXMPPMessage(t,
+			`<message from="alice@example.org/phone" type="chat" id="m1"><body>hi there</body></message>`))
+
+		msg, ok := expectInbound(t, msgBus)
+		if !ok {
+			t.Fatal("expected inbound message")
+		}
+		if msg.ChatID != "alice@example.org" || msg.SenderID != "alice@example.org" {
+			t.Errorf("unexpected routing: chat=%q sender=%q", msg.ChatID, msg.SenderID)
+		}
+		if msg.Metadata["peer_kind"] != "direct" {
+			t.Errorf("peer_kind = %q, want direct", msg.Metadata["peer_kind"])
+		}
+	})
+
+	t.Run("received carbon is unwrapped", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t)
+		ch.handleMessage(decodeXMPPMessage(t, `<message from="bot@example.org" type="chat">
+			<received xmlns="urn:xmpp:carbons:2"><forwarded xmlns="urn:xmpp:forward:0">
+			<message xmlns="jabber:client" from="alice@example.org/laptop" type="chat"><body>via carbon</body></message>
+			</forwarded></received></message>`))
+
+		msg, ok := expectInbound(t, msgBus)
+		if !ok {
+			t.Fatal("expected inbound message from carbon")
+		}
+		if msg.Content != "via carbon" || msg.SenderID != "alice@example.org" {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	})
+
+	t.Run("spoofed carbon is dropped", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t)
+		ch.handleMessage(decodeXMPPMessage(t, `<message from="mallory@evil.example" type="chat">
+			<received xmlns="urn:xmpp:carbons:2"><forwarded xmlns="urn:xmpp:forward:0">
+			<message xmlns="jabber:client" from="alice@example.org/laptop" type="chat"><body>forged</body></message>
+			</forwarded></received></message>`))
+
+		if _, ok := expectInbound(t, msgBus); ok {
+			t.Fatal("spoofed carbon should not be delivered")
+		}
+	})
+
+	t.Run("room message requires mention", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t)
+		ch.handleMessage(decodeXMPPMessage(t,
+			`<message from="lounge@conference.example.org/bob" type="groupchat"><body>just chatting</body></message>`))
+		if _, ok := expectInbound(t, msgBus); ok {
+			t.Fatal("unaddressed room message should be ignored")
+		}
+
+		ch.handleMessage(decodeXMPPMessage(t,
+			`<message from="lounge@conference.example.org/bob" type="groupchat"><body>claw: ping</body></message>`))
+		msg, ok := expectInbound(t, msgBus)
+		if !ok {
+			t.Fatal("expected addressed room message")
+		}
+		if msg.ChatID != "lounge@conference.example.org" || msg.Content != "ping" {
+			t.Errorf("unexpected message: chat=%q content=%q", msg.ChatID, msg.Content)
+		}
+		if msg.Metadata["peer_kind"] != "group" || msg.Metadata["sender_name"] != "bob" {
+			t.Errorf("unexpected metadata: %v", msg.Metadata)
+		}
+	})
+
+	t.Run("own room echo and history are ignored", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t)
+		ch.handleMessage(decodeXMPPMessage(t,
+			`<message from="lounge@conference.example.org/claw" type="groupchat"><body>claw: loop</body></message>`))
+		ch.handleMessage(decodeXMPPMessage(t, `<message from="lounge@conference.example.org/bob" type="groupchat">
+			<body>claw: old</body><delay xmlns="urn:xmpp:delay" stamp="2024-01-01T00:00:00Z"/></message>`))
+		if _, ok := expectInbound(t, msgBus); ok {
+			t.Fatal("echo and history messages should be ignored")
+		}
+	})
+
+	t.Run("allowlist applies to bare JID", func(t *testing.T) {
+		ch, msgBus := newTestXMPPChannel(t, "alice@example.org")
+		ch.handleMessage(decodeXMPPMessage(t,
+			`<message from="eve@example.org/x" type="chat"><body>let me in</body></message>`))
+		if _, ok := expectInbound(t, msgBus); ok {
+			t.Fatal("sender outside allowlist should be rejected")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Package commands parses and dispatches the slash commands users can send
+// from any channel, such as /help, /reset and /model.
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// Handler runs a command and returns the reply for the user.
+type Handler func(ctx context.Context, req Request) string
+
+// Command is a slash command. Name is used without the leading slash.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Usage       string // arguments shown in /help, e.g. "<name>"
+	Description string
+	// Immediate commands run as soon as they arrive instead of waiting for
+	// the message being processed, e.g. /cancel.
+	Immediate bool
+	Handler   Handler
+}
+
+// Request is a parsed command invocation.
+type Request struct {
+	Name       string   // command name as registered, without the slash
+	Args       []string // whitespace-separated arguments
+	Message    bus.InboundMessage
+	AgentID    string // agent the message was routed to
+	SessionKey string // session the message belongs to
+}
+
+// Parse splits "/name arg1 arg2" into the lowercased command name and its
+// arguments. A Telegram style "@botname" suffix on the name is dropped. It
+// reports false when text is not a command.
+func Parse(text string) (name string, args []string, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", nil, false
+	}
+	fields := strings.Fields(text[1:])
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	name, _, _ = strings.Cut(fields[0], "@")
+	if name == "" || strings.Contains(name, "/") {
+		// "/" alone, "/@bot" or a path like /usr/bin
+		return "", nil, false
+	}
+	return strings.ToLower(name), fields[1:], true
+}
+
+// Registry holds the available commands.
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command // by name and alias
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]*Command)}
+}
+
+// Register adds cmd, replacing any command with the same name or alias.
+func (r *Registry) Register(cmd Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &cmd
+	r.commands[strings.ToLower(cmd.Name)] = c
+	for _, alias := range cmd.Aliases {
+		r.commands[strings.ToLower(alias)] = c
+	}
+}
+
+// Lookup finds the command text invokes, along with its arguments.
+func (r *Registry) Lookup(text string) (*Command, []string, bool) {
+	name, args, ok := Parse(text)
+	if !ok {
+		return nil, nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, args, ok
+}
+
+// Execute runs the command in req.Message, reporting false when the message
+// is not a registered command so it can go to the agent instead.
+func (r *Registry) Execute(ctx context.Context, req Request) (string, bool) {
+	cmd, args, ok := r.Lookup(req.Message.Content)
+	if !ok {
+		return "", false
+	}
+	req.Name = cmd.Name
+	req.Args = args
+	return cmd.Handler(ctx, req), true
+}
+
+// List returns the registered commands sorted by name.
+func (r *Registry) List() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[*Command]bool)
+	list := make([]Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		if !seen[cmd] {
+			seen[cmd] = true
+			list = append(list, *cmd)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Help lists every command with its usage and description.
+func (r *Registry) Help() string {
+	var sb strings.Builder
+	sb.WriteString("Available commands:")
+	for _, cmd := range r.List() {
+		sb.WriteString("\n/" + cmd.Name)
+		if cmd.Usage != "" {
+			sb.WriteString(" " + cmd.Usage)
+		}
+		if cmd.Description != "" {
+			sb.WriteString(" - " + cmd.Description)
+		}
+	}
+	return sb.String()
+}
+
+// UsageError formats the reply for a command called with the wrong arguments.
+func UsageError(req Request, usage string) string {
+	return fmt.Sprintf("Usage: /%s %s", req.Name, usage)
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantName string
+		wantArgs []string
+		wantOK   bool
+	}{
+		{"/help", "help", nil, true},
+		{"  /Model gpt-4o  ", "model", []string{"gpt-4o"}, true},
+		{"/reset@picoclaw_bot", "reset", nil, true},
+		{"/switch model to glm", "switch", []string{"model", "to", "glm"}, true},
+		{"hello /help", "", nil, false},
+		{"/", "", nil, false},
+		{"/usr/bin/env", "", nil, false},
+	}
+	for _, tc := range tests {
+		name, args, ok := Parse(tc.text)
+		if name != tc.wantName || ok != tc.wantOK || strings.Join(args, " ") != strings.Join(tc.wantArgs, " ") {
+			t.Errorf("Parse(%q) = (%q, %v, %v), want (%q, %v, %v)",
+				tc.text, name, args, ok, tc.wantName, tc.wantArgs, tc.wantOK)
+		}
+	}
+}
+
+func TestRegistryExecute(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Command{
+		Name:        "echo",
+		Aliases:     []string{"say"},
+		Usage:       "<text>",
+		Description: "Repeat the text",
+		Handler: func(ctx context.Context, req Request) string {
+			return req.Name + ": " + strings.Join(req.Args, " ") + " in " + req.SessionKey
+		},
+	})
+
+	req := Request{Message: bus.InboundMessage{Content: "/say hi there"}, SessionKey: "s1"}
+	if got, ok := r.Execute(context.Background(), req); !ok || got != "echo: hi there in s1" {
+		t.Errorf("Execute(/say) = (%q, %v)", got, ok)
+	}
+
+	req.Message.Content = "/unknown"
+	if _, ok := r.Execute(context.Background(), req); ok {
+		t.Error("unknown command should not be handled")
+	}
+
+	if list := r.List(); len(list) != 1 {
+		t.Errorf("List() has %d commands, want 1 (aliases are not listed)", len(list))
+	}
+	if help := r.Help(); !strings.Contains(help, "/echo <text> - Repeat the text") {
+		t.Errorf("Help() = %q", help)
+	}
+}
@@ -5,9 +5,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/adhocore/gronx"
 	"github.com/caarlos0/env/v11"
+
+	"github.com/sipeed/picoclaw/pkg/usertime"
 )
 
 // rrCounter is a global counter for round-robin load balancing across models.
@@ -57,6 +64,8 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Voice     VoiceConfig     `json:"voice"`
+	Memory    MemoryConfig    `json:"memory"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -77,7 +86,8 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || c.Session.GroupScope != "" ||
+		c.Session.SharedThreads || c.Session.ArchiveIdleDays != 0 || c.Session.RetentionDays != 0 {
 		aux.Session = &c.Session
 	}
 
@@ -87,6 +97,61 @@ func (c Config) MarshalJSON() ([]byte, error) {
 type AgentsConfig struct {
 	Defaults AgentDefaults `json:"defaults"`
 	List     []AgentConfig `json:"list,omitempty"`
+	Review   ReviewConfig  `json:"review"`
+	// Profiles are specialists every agent can hand work to with the
+	// delegate tool, keyed by name.
+	Profiles map[string]AgentProfileConfig `json:"profiles,omitempty"`
+	// Personas are characters a conversation can switch the agent to with
+	// /persona, keyed by name.
+	Personas map[string]PersonaConfig `json:"personas,omitempty"`
+}
+
+// PersonaConfig describes a persona: Prompt is added to the system prompt
+// and takes precedence over the agent's own identity, Model is a model_list
+// entry (empty keeps the agent's model), and Tools limits the agent's tools
+// the persona may use (empty allows all). Description is shown in the
+// /persona list. Personas can also be markdown files in the workspace's
+// personas directory.
+type PersonaConfig struct {
+	Description string              `json:"description,omitempty"`
+	Prompt      string              `json:"prompt"`
+	Model       string              `json:"model,omitempty"`
+	Tools       FlexibleStringSlice `json:"tools,omitempty"`
+}
+
+// AgentProfileConfig describes a specialist agent: Prompt is its system
+// prompt, Model a model_list entry (empty uses the delegating agent's
+// model), and Tools the delegating agent's tools it may use. Description
+// tells the delegating agent what to hand it. The built-in researcher,
+// coder and summarizer profiles fill in whatever is left empty.
+type AgentProfileConfig struct {
+	Description   string              `json:"description,omitempty"`
+	Prompt        string              `json:"prompt,omitempty"`
+	Model         string              `json:"model,omitempty"`
+	Tools         FlexibleStringSlice `json:"tools,omitempty"`
+	MaxIterations int                 `json:"max_iterations,omitempty"`
+}
+
+// ReviewConfig adds a reviewer pass before replies are sent: Model, a
+// model_list entry (empty uses the agent's own), checks the draft against
+// the user's message and the turn's tool results, and the agent revises a
+// draft it finds fault with. Channels lists the channels ("telegram") or
+// conversations ("telegram:123456") to review; empty reviews every one.
+type ReviewConfig struct {
+	Enabled  bool                `json:"enabled"  env:"PICOCLAW_AGENTS_REVIEW_ENABLED"`
+	Model    string              `json:"model"    env:"PICOCLAW_AGENTS_REVIEW_MODEL"`
+	Channels FlexibleStringSlice `json:"channels" env:"PICOCLAW_AGENTS_REVIEW_CHANNELS"`
+}
+
+// Applies reports whether replies in chatID on channel are reviewed.
+func (c ReviewConfig) Applies(channel, chatID string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Channels) == 0 {
+		return true
+	}
+	return slices.Contains(c.Channels, channel) || slices.Contains(c.Channels, channel+":"+chatID)
 }
 
 // AgentModelConfig supports both string and structured model config.
@@ -161,37 +226,215 @@ type AgentBinding struct {
 	Match   BindingMatch `json:"match"`
 }
 
+// SessionConfig sets how finely conversations are split into sessions, which
+// scope history, summaries and usage. DMScope is main, per-peer,
+// per-channel-peer or per-account-channel-peer; IdentityLinks maps a
+// canonical name to the platform IDs ("telegram:123") of one person so
+// their DMs share a session across channels. GroupScope is per-chat (one
+// session per group) or per-member (one per member within each group).
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	GroupScope    string              `json:"group_scope,omitempty"`
+	// SharedThreads keeps threaded replies in the chat's session instead of
+	// giving each thread its own history.
+	SharedThreads bool `json:"shared_threads,omitempty"`
+	// ArchiveIdleDays archives sessions with no messages for this many days;
+	// RetentionDays deletes archived sessions (from /reset or going idle)
+	// after this many days. 0 disables either.
+	ArchiveIdleDays int `json:"archive_idle_days,omitempty"`
+	RetentionDays   int `json:"retention_days,omitempty"`
+}
+
+// TurnBudgetConfig caps the model calls of one turn: MaxTokens in total
+// tokens, MaxCost in US dollars priced from the input_price and
+// output_price of the agent's model_list entry. A turn over either stops
+// calling tools and sums up what it did. 0 leaves a cap off.
+type TurnBudgetConfig struct {
+	MaxTokens int     `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_BUDGET_MAX_TOKENS"`
+	MaxCost   float64 `json:"max_cost"   env:"PICOCLAW_AGENTS_DEFAULTS_BUDGET_MAX_COST"`
+}
+
+// AllowedPath is a file or directory outside the workspace that tools may
+// read, and write too when Write is set, while restrict_to_workspace is on.
+type AllowedPath struct {
+	Path  string `json:"path"`
+	Write bool   `json:"write,omitempty"`
 }
 
 type AgentDefaults struct {
-	Workspace           string   `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	RestrictToWorkspace bool     `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
-	Provider            string   `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
-	Model               string   `json:"model"                           env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
-	ModelFallbacks      []string `json:"model_fallbacks,omitempty"`
-	ImageModel          string   `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
-	ImageModelFallbacks []string `json:"image_model_fallbacks,omitempty"`
-	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Workspace            string   `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	RestrictToWorkspace  bool     `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
+	Provider             string   `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	Model                string   `json:"model"                           env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
+	ModelFallbacks       []string `json:"model_fallbacks,omitempty"`
+	ImageModel           string   `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
+	ImageModelFallbacks  []string `json:"image_model_fallbacks,omitempty"`
+	MaxTokens            int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	ContextWindow        int      `json:"context_window,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_CONTEXT_WINDOW"`
+	Temperature          *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations    int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxRepeatedToolCalls int      `json:"max_repeated_tool_calls"         env:"PICOCLAW_AGENTS_DEFAULTS_MAX_REPEATED_TOOL_CALLS"`
+	Timezone             string   `json:"timezone,omitempty"              env:"PICOCLAW_AGENTS_DEFAULTS_TIMEZONE"`
+
+	// Budget caps what a single turn may spend on model calls.
+	Budget TurnBudgetConfig `json:"budget"`
+
+	// AllowedPaths are the exceptions to RestrictToWorkspace.
+	AllowedPaths []AllowedPath `json:"allowed_paths,omitempty"`
 }
 
 type ChannelsConfig struct {
-	WhatsApp WhatsAppConfig `json:"whatsapp"`
-	Telegram TelegramConfig `json:"telegram"`
-	Feishu   FeishuConfig   `json:"feishu"`
-	Discord  DiscordConfig  `json:"discord"`
-	MaixCam  MaixCamConfig  `json:"maixcam"`
-	QQ       QQConfig       `json:"qq"`
-	DingTalk DingTalkConfig `json:"dingtalk"`
-	Slack    SlackConfig    `json:"slack"`
-	LINE     LINEConfig     `json:"line"`
-	OneBot   OneBotConfig   `json:"onebot"`
-	WeCom    WeComConfig    `json:"wecom"`
-	WeComApp WeComAppConfig `json:"wecom_app"`
+	WhatsApp    WhatsAppConfig    `json:"whatsapp"`
+	Telegram    TelegramConfig    `json:"telegram"`
+	Feishu      FeishuConfig      `json:"feishu"`
+	Discord     DiscordConfig     `json:"discord"`
+	MaixCam     MaixCamConfig     `json:"maixcam"`
+	QQ          QQConfig          `json:"qq"`
+	DingTalk    DingTalkConfig    `json:"dingtalk"`
+	Slack       SlackConfig       `json:"slack"`
+	LINE        LINEConfig        `json:"line"`
+	OneBot      OneBotConfig      `json:"onebot"`
+	WeCom       WeComConfig       `json:"wecom"`
+	WeComApp    WeComAppConfig    `json:"wecom_app"`
+	XMPP        XMPPConfig        `json:"xmpp"`
+	Mastodon    MastodonConfig    `json:"mastodon"`
+	Bluesky     BlueskyConfig     `json:"bluesky"`
+	Push        PushConfig        `json:"push"`
+	Feeds       FeedsConfig       `json:"feeds"`
+	GitHub      GitHubConfig      `json:"github"`
+	API         APIConfig         `json:"api"`
+	GRPC        GRPCConfig        `json:"grpc"`
+	MQTT        MQTTConfig        `json:"mqtt"`
+	Webhook     WebhookConfig     `json:"webhook"`
+	Access      AccessConfig      `json:"access"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+	TLS         TLSConfig         `json:"tls"`
+	Tunnel      TunnelConfig      `json:"tunnel"`
+	Attachments AttachmentsConfig `json:"attachments"`
+	Groups      GroupsConfig      `json:"groups"`
+	Broadcast   BroadcastConfig   `json:"broadcast"`
+	Language    LanguageConfig    `json:"language"`
+	Delivery    DeliveryConfig    `json:"delivery"`
+	// Middleware lists the pipeline steps for each channel by name; steps
+	// under "*" apply to all channels.
+	Middleware map[string][]MiddlewareConfig `json:"middleware,omitempty"`
+}
+
+// MiddlewareConfig is one step of a channel's message pipeline. Type is log,
+// normalize, word_filter, block or replace; direction is inbound, outbound or
+// both and defaults per type.
+type MiddlewareConfig struct {
+	Type        string   `json:"type"`
+	Direction   string   `json:"direction,omitempty"`
+	Words       []string `json:"words,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+}
+
+// GroupsConfig decides when the bot speaks up in group chats. With
+// mention_only, group messages are ignored unless they mention the bot,
+// contain one of trigger_words (whole words, any case) or match one of
+// trigger_patterns (regular expressions, any case). After a sender addresses
+// the bot, their messages in that chat are answered without a trigger for
+// attention_seconds; 0 turns this off. Direct messages are always answered.
+type GroupsConfig struct {
+	MentionOnly      bool                `json:"mention_only"      env:"PICOCLAW_CHANNELS_GROUPS_MENTION_ONLY"`
+	TriggerWords     FlexibleStringSlice `json:"trigger_words"     env:"PICOCLAW_CHANNELS_GROUPS_TRIGGER_WORDS"`
+	TriggerPatterns  FlexibleStringSlice `json:"trigger_patterns"  env:"PICOCLAW_CHANNELS_GROUPS_TRIGGER_PATTERNS"`
+	AttentionSeconds int                 `json:"attention_seconds" env:"PICOCLAW_CHANNELS_GROUPS_ATTENTION_SECONDS"`
+}
+
+func (c *GroupsConfig) Validate() error {
+	for _, pattern := range c.TriggerPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("channels.groups.trigger_patterns: %w", err)
+		}
+	}
+	if c.AttentionSeconds < 0 {
+		return fmt.Errorf("channels.groups.attention_seconds must not be negative")
+	}
+	return nil
+}
+
+// BroadcastConfig lists where /announce and the broadcast tool deliver, as
+// "channel:recipient" entries such as "telegram:123456" or "slack:C0123".
+type BroadcastConfig struct {
+	Targets FlexibleStringSlice `json:"targets" env:"PICOCLAW_CHANNELS_BROADCAST_TARGETS"`
+}
+
+// DeliveryConfig controls retries of replies a channel failed to send.
+// Only transient failures, such as network errors, rate limits or a channel
+// being restarted, are retried; 0 disables retries.
+type DeliveryConfig struct {
+	MaxRetries int `json:"max_retries" env:"PICOCLAW_CHANNELS_DELIVERY_MAX_RETRIES"`
+}
+
+// LanguageConfig picks the language of the messages channels and the agent
+// send on their own, such as "Thinking..." placeholders, rate-limit notices
+// and approval prompts: "en" or "zh". Channels maps a channel name to its
+// own language; the rest use Default.
+type LanguageConfig struct {
+	Default  string            `json:"default"  env:"PICOCLAW_CHANNELS_LANGUAGE_DEFAULT"`
+	Channels map[string]string `json:"channels"`
+}
+
+// For returns the language of channel.
+func (c LanguageConfig) For(channel string) string {
+	if lang, ok := c.Channels[channel]; ok && lang != "" {
+		return lang
+	}
+	return c.Default
+}
+
+// AttachmentsConfig controls saving inbound files into the workspace. Dir
+// defaults to <workspace>/attachments; files over max_size_mb are skipped.
+type AttachmentsConfig struct {
+	Enabled   bool   `json:"enabled"     env:"PICOCLAW_CHANNELS_ATTACHMENTS_ENABLED"`
+	Dir       string `json:"dir"         env:"PICOCLAW_CHANNELS_ATTACHMENTS_DIR"`
+	MaxSizeMB int    `json:"max_size_mb" env:"PICOCLAW_CHANNELS_ATTACHMENTS_MAX_SIZE_MB"`
+}
+
+// TLSConfig enables HTTPS on the webhook servers of LINE, WeCom, the
+// generic webhook channel and the HTTP API, using either certificate files or certificates
+// obtained from Let's Encrypt for acme_domains.
+type TLSConfig struct {
+	CertFile     string              `json:"cert_file"      env:"PICOCLAW_CHANNELS_TLS_CERT_FILE"`
+	KeyFile      string              `json:"key_file"       env:"PICOCLAW_CHANNELS_TLS_KEY_FILE"`
+	ACMEDomains  FlexibleStringSlice `json:"acme_domains"   env:"PICOCLAW_CHANNELS_TLS_ACME_DOMAINS"`
+	ACMEEmail    string              `json:"acme_email"     env:"PICOCLAW_CHANNELS_TLS_ACME_EMAIL"`
+	ACMECacheDir string              `json:"acme_cache_dir" env:"PICOCLAW_CHANNELS_TLS_ACME_CACHE_DIR"`
+	ACMEHTTPAddr string              `json:"acme_http_addr" env:"PICOCLAW_CHANNELS_TLS_ACME_HTTP_ADDR"`
+}
+
+// TunnelConfig exposes webhook channels through an outbound tunnel client.
+// Provider is "cloudflared", "ngrok" or "frp"; empty disables the tunnel.
+// cloudflared and ngrok report their public URL; frp uses public_url, and
+// frp_config must route each channel's webhook path to its port.
+type TunnelConfig struct {
+	Provider  string `json:"provider"   env:"PICOCLAW_CHANNELS_TUNNEL_PROVIDER"`
+	Binary    string `json:"binary"     env:"PICOCLAW_CHANNELS_TUNNEL_BINARY"`
+	AuthToken string `json:"auth_token" env:"PICOCLAW_CHANNELS_TUNNEL_AUTH_TOKEN"`
+	FRPConfig string `json:"frp_config" env:"PICOCLAW_CHANNELS_TUNNEL_FRP_CONFIG"`
+	PublicURL string `json:"public_url" env:"PICOCLAW_CHANNELS_TUNNEL_PUBLIC_URL"`
+}
+
+// AccessConfig controls what happens to senders outside a channel's
+// allow_from list and who may grant access at runtime.
+type AccessConfig struct {
+	RejectMessage string              `json:"reject_message" env:"PICOCLAW_CHANNELS_ACCESS_REJECT_MESSAGE"`
+	Admins        FlexibleStringSlice `json:"admins"         env:"PICOCLAW_CHANNELS_ACCESS_ADMINS"`
+}
+
+// RateLimitConfig limits inbound messages per sender and per chat on each
+// channel. A limit of 0 disables it; burst defaults to the per-minute limit.
+// An empty cooldown_message sends the built-in notice in the channel's
+// language.
+type RateLimitConfig struct {
+	UserPerMinute   int    `json:"user_per_minute"  env:"PICOCLAW_CHANNELS_RATE_LIMIT_USER_PER_MINUTE"`
+	ChatPerMinute   int    `json:"chat_per_minute"  env:"PICOCLAW_CHANNELS_RATE_LIMIT_CHAT_PER_MINUTE"`
+	Burst           int    `json:"burst"            env:"PICOCLAW_CHANNELS_RATE_LIMIT_BURST"`
+	CooldownMessage string `json:"cooldown_message" env:"PICOCLAW_CHANNELS_RATE_LIMIT_COOLDOWN_MESSAGE"`
 }
 
 type WhatsAppConfig struct {
@@ -201,10 +444,12 @@ type WhatsAppConfig struct {
 }
 
 type TelegramConfig struct {
-	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
-	Token     string              `json:"token"      env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
-	Proxy     string              `json:"proxy"      env:"PICOCLAW_CHANNELS_TELEGRAM_PROXY"`
-	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
+	Token            string              `json:"token"             env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
+	Proxy            string              `json:"proxy"             env:"PICOCLAW_CHANNELS_TELEGRAM_PROXY"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	ProgressiveReply bool                `json:"progressive_reply" env:"PICOCLAW_CHANNELS_TELEGRAM_PROGRESSIVE_REPLY"`
+	OnEdit           string              `json:"on_edit"           env:"PICOCLAW_CHANNELS_TELEGRAM_ON_EDIT"`
 }
 
 type FeishuConfig struct {
@@ -217,10 +462,11 @@ type FeishuConfig struct {
 }
 
 type DiscordConfig struct {
-	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
-	Token       string              `json:"token"        env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
-	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
-	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_DISCORD_MENTION_ONLY"`
+	Enabled          bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
+	Token            string              `json:"token"        env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	MentionOnly      bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_DISCORD_MENTION_ONLY"`
+	ProgressiveReply bool                `json:"progressive_reply" env:"PICOCLAW_CHANNELS_DISCORD_PROGRESSIVE_REPLY"`
 }
 
 type MaixCamConfig struct {
@@ -245,10 +491,12 @@ type DingTalkConfig struct {
 }
 
 type SlackConfig struct {
-	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_SLACK_ENABLED"`
-	BotToken  string              `json:"bot_token"  env:"PICOCLAW_CHANNELS_SLACK_BOT_TOKEN"`
-	AppToken  string              `json:"app_token"  env:"PICOCLAW_CHANNELS_SLACK_APP_TOKEN"`
-	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_FROM"`
+	Enabled          bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_SLACK_ENABLED"`
+	BotToken         string              `json:"bot_token"  env:"PICOCLAW_CHANNELS_SLACK_BOT_TOKEN"`
+	AppToken         string              `json:"app_token"  env:"PICOCLAW_CHANNELS_SLACK_APP_TOKEN"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_FROM"`
+	ProgressiveReply bool                `json:"progressive_reply" env:"PICOCLAW_CHANNELS_SLACK_PROGRESSIVE_REPLY"`
+	OnEdit           string              `json:"on_edit"           env:"PICOCLAW_CHANNELS_SLACK_ON_EDIT"`
 }
 
 type LINEConfig struct {
@@ -270,35 +518,335 @@ type OneBotConfig struct {
 	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_ONEBOT_ALLOW_FROM"`
 }
 
+// WeComConfig configures WeCom as either a bot (智能机器人), which answers
+// through webhook_url, or an app (自建应用), which sends with corp_id,
+// corp_secret and agent_id. Mode picks one; left empty, setting any of the
+// app fields selects app mode.
 type WeComConfig struct {
-	Enabled        bool                `json:"enabled"          env:"PICOCLAW_CHANNELS_WECOM_ENABLED"`
-	Token          string              `json:"token"            env:"PICOCLAW_CHANNELS_WECOM_TOKEN"`
-	EncodingAESKey string              `json:"encoding_aes_key" env:"PICOCLAW_CHANNELS_WECOM_ENCODING_AES_KEY"`
-	WebhookURL     string              `json:"webhook_url"      env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_URL"`
-	WebhookHost    string              `json:"webhook_host"     env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_HOST"`
-	WebhookPort    int                 `json:"webhook_port"     env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PORT"`
-	WebhookPath    string              `json:"webhook_path"     env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PATH"`
-	AllowFrom      FlexibleStringSlice `json:"allow_from"       env:"PICOCLAW_CHANNELS_WECOM_ALLOW_FROM"`
-	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_REPLY_TIMEOUT"`
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_WECOM_ENABLED"`
+	Mode             string              `json:"mode"              env:"PICOCLAW_CHANNELS_WECOM_MODE"`
+	Token            string              `json:"token"             env:"PICOCLAW_CHANNELS_WECOM_TOKEN"`
+	EncodingAESKey   string              `json:"encoding_aes_key"  env:"PICOCLAW_CHANNELS_WECOM_ENCODING_AES_KEY"`
+	WebhookURL       string              `json:"webhook_url"       env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_URL"`
+	CorpID           string              `json:"corp_id"           env:"PICOCLAW_CHANNELS_WECOM_CORP_ID"`
+	CorpSecret       string              `json:"corp_secret"       env:"PICOCLAW_CHANNELS_WECOM_CORP_SECRET"`
+	AgentID          int64               `json:"agent_id"          env:"PICOCLAW_CHANNELS_WECOM_AGENT_ID"`
+	WebhookHost      string              `json:"webhook_host"      env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_HOST"`
+	WebhookPort      int                 `json:"webhook_port"      env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PORT"`
+	WebhookPath      string              `json:"webhook_path"      env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PATH"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_WECOM_ALLOW_FROM"`
+	ReplyTimeout     int                 `json:"reply_timeout"     env:"PICOCLAW_CHANNELS_WECOM_REPLY_TIMEOUT"`
+	ProgressiveReply bool                `json:"progressive_reply" env:"PICOCLAW_CHANNELS_WECOM_PROGRESSIVE_REPLY"`
+	ReplyFormat      string              `json:"reply_format"      env:"PICOCLAW_CHANNELS_WECOM_REPLY_FORMAT"`
+	ExternalSender   string              `json:"external_sender"   env:"PICOCLAW_CHANNELS_WECOM_EXTERNAL_SENDER"`
+	EventPrompts     map[string]string   `json:"event_prompts"     env:"PICOCLAW_CHANNELS_WECOM_EVENT_PROMPTS"`
+	TimestampWindow  int                 `json:"timestamp_window"  env:"PICOCLAW_CHANNELS_WECOM_TIMESTAMP_WINDOW"`
 }
 
+// WeCom modes.
+const (
+	WeComModeBot = "bot"
+	WeComModeApp = "app"
+)
+
+// ResolveMode returns the mode the WeCom block runs in, and an error naming
+// what is missing when the block can't run in it. The mode is returned even
+// with an error, so callers know which channel the block was meant for.
+func (c WeComConfig) ResolveMode() (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(c.Mode))
+	switch mode {
+	case "":
+		mode = WeComModeBot
+		if c.CorpID != "" || c.CorpSecret != "" || c.AgentID != 0 {
+			mode = WeComModeApp
+		}
+	case WeComModeBot, WeComModeApp:
+	default:
+		return mode, fmt.Errorf("wecom mode %q is not valid; use %q or %q", c.Mode, WeComModeBot, WeComModeApp)
+	}
+
+	var missing []string
+	if mode == WeComModeApp {
+		if c.CorpID == "" {
+			missing = append(missing, "corp_id")
+		}
+		if c.CorpSecret == "" {
+			missing = append(missing, "corp_secret")
+		}
+		if c.AgentID == 0 {
+			missing = append(missing, "agent_id")
+		}
+	} else {
+		if c.Token == "" {
+			missing = append(missing, "token")
+		}
+		if c.WebhookURL == "" {
+			missing = append(missing, "webhook_url")
+		}
+	}
+	if len(missing) > 0 {
+		return mode, fmt.Errorf("wecom %s mode is missing %s", mode, strings.Join(missing, ", "))
+	}
+	return mode, nil
+}
+
+// App returns the block as the settings of a WeCom App channel.
+func (c WeComConfig) App() WeComAppConfig {
+	return WeComAppConfig{
+		Enabled:          c.Enabled,
+		CorpID:           c.CorpID,
+		CorpSecret:       c.CorpSecret,
+		AgentID:          c.AgentID,
+		Token:            c.Token,
+		EncodingAESKey:   c.EncodingAESKey,
+		WebhookHost:      c.WebhookHost,
+		WebhookPort:      c.WebhookPort,
+		WebhookPath:      c.WebhookPath,
+		AllowFrom:        c.AllowFrom,
+		ReplyTimeout:     c.ReplyTimeout,
+		ProgressiveReply: c.ProgressiveReply,
+		ReplyFormat:      c.ReplyFormat,
+		ExternalSender:   c.ExternalSender,
+		EventPrompts:     c.EventPrompts,
+		TimestampWindow:  c.TimestampWindow,
+	}
+}
+
+// WeComAppConfig is the former standalone WeCom App block. It still works,
+// but new setups use the wecom block in app mode.
 type WeComAppConfig struct {
-	Enabled        bool                `json:"enabled"          env:"PICOCLAW_CHANNELS_WECOM_APP_ENABLED"`
-	CorpID         string              `json:"corp_id"          env:"PICOCLAW_CHANNELS_WECOM_APP_CORP_ID"`
-	CorpSecret     string              `json:"corp_secret"      env:"PICOCLAW_CHANNELS_WECOM_APP_CORP_SECRET"`
-	AgentID        int64               `json:"agent_id"         env:"PICOCLAW_CHANNELS_WECOM_APP_AGENT_ID"`
-	Token          string              `json:"token"            env:"PICOCLAW_CHANNELS_WECOM_APP_TOKEN"`
-	EncodingAESKey string              `json:"encoding_aes_key" env:"PICOCLAW_CHANNELS_WECOM_APP_ENCODING_AES_KEY"`
-	WebhookHost    string              `json:"webhook_host"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_HOST"`
-	WebhookPort    int                 `json:"webhook_port"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_PORT"`
-	WebhookPath    string              `json:"webhook_path"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_PATH"`
-	AllowFrom      FlexibleStringSlice `json:"allow_from"       env:"PICOCLAW_CHANNELS_WECOM_APP_ALLOW_FROM"`
-	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_TIMEOUT"`
+	Enabled          bool                `json:"enabled"          env:"PICOCLAW_CHANNELS_WECOM_APP_ENABLED"`
+	CorpID           string              `json:"corp_id"          env:"PICOCLAW_CHANNELS_WECOM_APP_CORP_ID"`
+	CorpSecret       string              `json:"corp_secret"      env:"PICOCLAW_CHANNELS_WECOM_APP_CORP_SECRET"`
+	AgentID          int64               `json:"agent_id"         env:"PICOCLAW_CHANNELS_WECOM_APP_AGENT_ID"`
+	Token            string              `json:"token"            env:"PICOCLAW_CHANNELS_WECOM_APP_TOKEN"`
+	EncodingAESKey   string              `json:"encoding_aes_key" env:"PICOCLAW_CHANNELS_WECOM_APP_ENCODING_AES_KEY"`
+	WebhookHost      string              `json:"webhook_host"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_HOST"`
+	WebhookPort      int                 `json:"webhook_port"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_PORT"`
+	WebhookPath      string              `json:"webhook_path"     env:"PICOCLAW_CHANNELS_WECOM_APP_WEBHOOK_PATH"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"       env:"PICOCLAW_CHANNELS_WECOM_APP_ALLOW_FROM"`
+	ReplyTimeout     int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_TIMEOUT"`
+	ProgressiveReply bool                `json:"progressive_reply" env:"PICOCLAW_CHANNELS_WECOM_APP_PROGRESSIVE_REPLY"`
+	ReplyFormat      string              `json:"reply_format" env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_FORMAT"`
+	ExternalSender   string              `json:"external_sender" env:"PICOCLAW_CHANNELS_WECOM_APP_EXTERNAL_SENDER"`
+	EventPrompts     map[string]string   `json:"event_prompts" env:"PICOCLAW_CHANNELS_WECOM_APP_EVENT_PROMPTS"`
+	TimestampWindow  int                 `json:"timestamp_window" env:"PICOCLAW_CHANNELS_WECOM_APP_TIMESTAMP_WINDOW"`
+}
+
+type XMPPConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_XMPP_ENABLED"`
+	JID                string              `json:"jid"                  env:"PICOCLAW_CHANNELS_XMPP_JID"`
+	Password           string              `json:"password"             env:"PICOCLAW_CHANNELS_XMPP_PASSWORD"`
+	Server             string              `json:"server"               env:"PICOCLAW_CHANNELS_XMPP_SERVER"`
+	Resource           string              `json:"resource"             env:"PICOCLAW_CHANNELS_XMPP_RESOURCE"`
+	DirectTLS          bool                `json:"direct_tls"           env:"PICOCLAW_CHANNELS_XMPP_DIRECT_TLS"`
+	InsecureSkipVerify bool                `json:"insecure_skip_verify" env:"PICOCLAW_CHANNELS_XMPP_INSECURE_SKIP_VERIFY"`
+	Rooms              []string            `json:"rooms"                env:"PICOCLAW_CHANNELS_XMPP_ROOMS"`
+	Nickname           string              `json:"nickname"             env:"PICOCLAW_CHANNELS_XMPP_NICKNAME"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_XMPP_ALLOW_FROM"`
+}
+
+// MastodonConfig configures the Mastodon channel, which answers mentions and
+// direct messages received over the streaming API.
+type MastodonConfig struct {
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_MASTODON_ENABLED"`
+	Server           string              `json:"server"            env:"PICOCLAW_CHANNELS_MASTODON_SERVER"`
+	AccessToken      string              `json:"access_token"      env:"PICOCLAW_CHANNELS_MASTODON_ACCESS_TOKEN"`
+	Visibility       string              `json:"visibility"        env:"PICOCLAW_CHANNELS_MASTODON_VISIBILITY"`
+	MaxChars         int                 `json:"max_chars"         env:"PICOCLAW_CHANNELS_MASTODON_MAX_CHARS"`
+	SpoilerThreshold int                 `json:"spoiler_threshold" env:"PICOCLAW_CHANNELS_MASTODON_SPOILER_THRESHOLD"`
+	SpoilerText      string              `json:"spoiler_text"      env:"PICOCLAW_CHANNELS_MASTODON_SPOILER_TEXT"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_MASTODON_ALLOW_FROM"`
+}
+
+// BlueskyConfig configures the Bluesky channel, which polls for mentions and
+// chat messages with an app password.
+type BlueskyConfig struct {
+	Enabled      bool                `json:"enabled"       env:"PICOCLAW_CHANNELS_BLUESKY_ENABLED"`
+	Handle       string              `json:"handle"        env:"PICOCLAW_CHANNELS_BLUESKY_HANDLE"`
+	AppPassword  string              `json:"app_password"  env:"PICOCLAW_CHANNELS_BLUESKY_APP_PASSWORD"`
+	PDS          string              `json:"pds"           env:"PICOCLAW_CHANNELS_BLUESKY_PDS"`
+	PollInterval int                 `json:"poll_interval" env:"PICOCLAW_CHANNELS_BLUESKY_POLL_INTERVAL"`
+	DMs          bool                `json:"dms"           env:"PICOCLAW_CHANNELS_BLUESKY_DMS"`
+	AllowFrom    FlexibleStringSlice `json:"allow_from"    env:"PICOCLAW_CHANNELS_BLUESKY_ALLOW_FROM"`
+}
+
+// GitHubConfig configures the GitHub channel, which answers issue and pull
+// request comments that mention Username in the listed repositories.
+type GitHubConfig struct {
+	Enabled      bool                `json:"enabled"       env:"PICOCLAW_CHANNELS_GITHUB_ENABLED"`
+	Token        string              `json:"token"         env:"PICOCLAW_CHANNELS_GITHUB_TOKEN"`
+	Username     string              `json:"username"      env:"PICOCLAW_CHANNELS_GITHUB_USERNAME"` // empty: the token's user
+	APIBase      string              `json:"api_base"      env:"PICOCLAW_CHANNELS_GITHUB_API_BASE"`
+	Repos        FlexibleStringSlice `json:"repos"         env:"PICOCLAW_CHANNELS_GITHUB_REPOS"` // "owner/name"
+	PollInterval int                 `json:"poll_interval" env:"PICOCLAW_CHANNELS_GITHUB_POLL_INTERVAL"`
+	AllowFrom    FlexibleStringSlice `json:"allow_from"    env:"PICOCLAW_CHANNELS_GITHUB_ALLOW_FROM"`
+}
+
+// APIConfig configures the HTTP API channel, which lets other services chat
+// with the agent over POST /v1/chat. Requests must carry one of Tokens as a
+// bearer token. Timeout is how long, in seconds, a request waits for a reply.
+type APIConfig struct {
+	Enabled bool                `json:"enabled" env:"PICOCLAW_CHANNELS_API_ENABLED"`
+	Host    string              `json:"host"    env:"PICOCLAW_CHANNELS_API_HOST"`
+	Port    int                 `json:"port"    env:"PICOCLAW_CHANNELS_API_PORT"`
+	Tokens  FlexibleStringSlice `json:"tokens"  env:"PICOCLAW_CHANNELS_API_TOKENS"`
+	Timeout int                 `json:"timeout" env:"PICOCLAW_CHANNELS_API_TIMEOUT"`
+}
+
+// GRPCConfig configures the gRPC channel, which serves the Agent service from
+// pkg/channels/agentpb. Calls must carry one of Tokens as a bearer token.
+type GRPCConfig struct {
+	Enabled bool                `json:"enabled" env:"PICOCLAW_CHANNELS_GRPC_ENABLED"`
+	Host    string              `json:"host"    env:"PICOCLAW_CHANNELS_GRPC_HOST"`
+	Port    int                 `json:"port"    env:"PICOCLAW_CHANNELS_GRPC_PORT"`
+	Tokens  FlexibleStringSlice `json:"tokens"  env:"PICOCLAW_CHANNELS_GRPC_TOKENS"`
+}
+
+// MQTTConfig configures the MQTT channel. Commands published to CommandTopic
+// (wildcards allowed) reach the agent, and replies are published to
+// ResponseTopic unless a command names its own reply_to topic.
+type MQTTConfig struct {
+	Enabled       bool                `json:"enabled"        env:"PICOCLAW_CHANNELS_MQTT_ENABLED"`
+	Broker        string              `json:"broker"         env:"PICOCLAW_CHANNELS_MQTT_BROKER"`
+	ClientID      string              `json:"client_id"      env:"PICOCLAW_CHANNELS_MQTT_CLIENT_ID"`
+	Username      string              `json:"username"       env:"PICOCLAW_CHANNELS_MQTT_USERNAME"`
+	Password      string              `json:"password"       env:"PICOCLAW_CHANNELS_MQTT_PASSWORD"`
+	CommandTopic  string              `json:"command_topic"  env:"PICOCLAW_CHANNELS_MQTT_COMMAND_TOPIC"`
+	ResponseTopic string              `json:"response_topic" env:"PICOCLAW_CHANNELS_MQTT_RESPONSE_TOPIC"`
+	QoS           int                 `json:"qos"            env:"PICOCLAW_CHANNELS_MQTT_QOS"`
+	AllowFrom     FlexibleStringSlice `json:"allow_from"     env:"PICOCLAW_CHANNELS_MQTT_ALLOW_FROM"`
+}
+
+// PushConfig configures the one-way push channel, which sends heartbeat and
+// scheduled task results to a phone through ntfy or Pushover.
+type PushConfig struct {
+	Enabled  bool   `json:"enabled"  env:"PICOCLAW_CHANNELS_PUSH_ENABLED"`
+	Provider string `json:"provider" env:"PICOCLAW_CHANNELS_PUSH_PROVIDER"` // "ntfy" or "pushover"
+	Server   string `json:"server"   env:"PICOCLAW_CHANNELS_PUSH_SERVER"`   // ntfy server
+	Topic    string `json:"topic"    env:"PICOCLAW_CHANNELS_PUSH_TOPIC"`    // ntfy topic
+	Token    string `json:"token"    env:"PICOCLAW_CHANNELS_PUSH_TOKEN"`    // ntfy access token or Pushover app token
+	User     string `json:"user"     env:"PICOCLAW_CHANNELS_PUSH_USER"`     // Pushover user key
+	Title    string `json:"title"    env:"PICOCLAW_CHANNELS_PUSH_TITLE"`
+}
+
+// FeedsConfig configures the feed watcher, which turns new RSS/Atom items
+// into agent turns. Replies go to DeliverTo on DeliverChannel, or to the push
+// channel when no delivery channel is set.
+type FeedsConfig struct {
+	Enabled        bool         `json:"enabled"         env:"PICOCLAW_CHANNELS_FEEDS_ENABLED"`
+	PollInterval   int          `json:"poll_interval"   env:"PICOCLAW_CHANNELS_FEEDS_POLL_INTERVAL"` // minutes
+	MaxItems       int          `json:"max_items"       env:"PICOCLAW_CHANNELS_FEEDS_MAX_ITEMS"`     // per feed and poll
+	Prompt         string       `json:"prompt"          env:"PICOCLAW_CHANNELS_FEEDS_PROMPT"`
+	DeliverChannel string       `json:"deliver_channel" env:"PICOCLAW_CHANNELS_FEEDS_DELIVER_CHANNEL"`
+	DeliverTo      string       `json:"deliver_to"      env:"PICOCLAW_CHANNELS_FEEDS_DELIVER_TO"`
+	Feeds          []FeedSource `json:"feeds"`
+}
+
+// FeedSource is one watched feed. Prompt overrides FeedsConfig.Prompt.
+type FeedSource struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// WebhookConfig configures the generic webhook channel. Inbound fields are
+// selected with JSONPath expressions and replies are rendered through a Go
+// text/template before being POSTed to OutboundURL.
+type WebhookConfig struct {
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_WEBHOOK_ENABLED"`
+	WebhookHost      string              `json:"webhook_host"      env:"PICOCLAW_CHANNELS_WEBHOOK_HOST"`
+	WebhookPort      int                 `json:"webhook_port"      env:"PICOCLAW_CHANNELS_WEBHOOK_PORT"`
+	WebhookPath      string              `json:"webhook_path"      env:"PICOCLAW_CHANNELS_WEBHOOK_PATH"`
+	Secret           string              `json:"secret"            env:"PICOCLAW_CHANNELS_WEBHOOK_SECRET"`
+	SenderField      string              `json:"sender_field"      env:"PICOCLAW_CHANNELS_WEBHOOK_SENDER_FIELD"`
+	ChatField        string              `json:"chat_field"        env:"PICOCLAW_CHANNELS_WEBHOOK_CHAT_FIELD"`
+	ContentField     string              `json:"content_field"     env:"PICOCLAW_CHANNELS_WEBHOOK_CONTENT_FIELD"`
+	OutboundURL      string              `json:"outbound_url"      env:"PICOCLAW_CHANNELS_WEBHOOK_OUTBOUND_URL"`
+	OutboundMethod   string              `json:"outbound_method"   env:"PICOCLAW_CHANNELS_WEBHOOK_OUTBOUND_METHOD"`
+	OutboundHeaders  map[string]string   `json:"outbound_headers"  env:"PICOCLAW_CHANNELS_WEBHOOK_OUTBOUND_HEADERS"`
+	OutboundTemplate string              `json:"outbound_template" env:"PICOCLAW_CHANNELS_WEBHOOK_OUTBOUND_TEMPLATE"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_WEBHOOK_ALLOW_FROM"`
+}
+
+// VoiceConfig selects how inbound voice messages are transcribed. Provider is
+// "groq", "openai", "bedrock" (an OpenAI-compatible gateway at api_base) or
+// "whisper_cpp"; empty uses Groq when a Groq API key is configured. Language
+// is an ISO-639-1 code, or empty/"auto" to detect it.
+type VoiceConfig struct {
+	Provider         string    `json:"provider"           env:"PICOCLAW_VOICE_PROVIDER"`
+	APIKey           string    `json:"api_key"            env:"PICOCLAW_VOICE_API_KEY"`
+	APIBase          string    `json:"api_base"           env:"PICOCLAW_VOICE_API_BASE"`
+	Model            string    `json:"model"              env:"PICOCLAW_VOICE_MODEL"`
+	Language         string    `json:"language"           env:"PICOCLAW_VOICE_LANGUAGE"`
+	WhisperCppBinary string    `json:"whisper_cpp_binary" env:"PICOCLAW_VOICE_WHISPER_CPP_BINARY"`
+	WhisperCppModel  string    `json:"whisper_cpp_model"  env:"PICOCLAW_VOICE_WHISPER_CPP_MODEL"`
+	TTS              TTSConfig `json:"tts"`
+}
+
+// TTSConfig enables spoken replies to voice messages on channels that can
+// send voice notes. Provider is "openai", "polly" or "edge_tts"; empty
+// disables it. Model is the Polly engine for polly. Replies longer than
+// max_chars are sent as text.
+type TTSConfig struct {
+	Provider        string `json:"provider"          env:"PICOCLAW_VOICE_TTS_PROVIDER"`
+	APIKey          string `json:"api_key"           env:"PICOCLAW_VOICE_TTS_API_KEY"`
+	APIBase         string `json:"api_base"          env:"PICOCLAW_VOICE_TTS_API_BASE"`
+	Model           string `json:"model"             env:"PICOCLAW_VOICE_TTS_MODEL"`
+	Voice           string `json:"voice"             env:"PICOCLAW_VOICE_TTS_VOICE"`
+	Region          string `json:"region"            env:"PICOCLAW_VOICE_TTS_REGION"`
+	AccessKeyID     string `json:"access_key_id"     env:"PICOCLAW_VOICE_TTS_ACCESS_KEY_ID"`
+	SecretAccessKey string `json:"secret_access_key" env:"PICOCLAW_VOICE_TTS_SECRET_ACCESS_KEY"`
+	Binary          string `json:"binary"            env:"PICOCLAW_VOICE_TTS_BINARY"`
+	MaxChars        int    `json:"max_chars"         env:"PICOCLAW_VOICE_TTS_MAX_CHARS"`
+	IncludeText     bool   `json:"include_text"      env:"PICOCLAW_VOICE_TTS_INCLUDE_TEXT"`
+}
+
+// MemoryConfig enables long-term memory: facts and session summaries taken
+// from conversations are embedded with EmbeddingModel, a model_list entry
+// of an OpenAI-compatible protocol, and the closest ones are added to the
+// system prompt of each turn. ExtractModel picks the facts; empty uses the
+// agent's model.
+type MemoryConfig struct {
+	Enabled        bool    `json:"enabled"         env:"PICOCLAW_MEMORY_ENABLED"`
+	EmbeddingModel string  `json:"embedding_model" env:"PICOCLAW_MEMORY_EMBEDDING_MODEL"`
+	ExtractModel   string  `json:"extract_model"   env:"PICOCLAW_MEMORY_EXTRACT_MODEL"`
+	TopK           int     `json:"top_k"           env:"PICOCLAW_MEMORY_TOP_K"`
+	MinScore       float64 `json:"min_score"       env:"PICOCLAW_MEMORY_MIN_SCORE"`
 }
 
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
+	// Proactive heartbeats also review upcoming tasks and changed workspace
+	// files, and message the user only when something needs their attention.
+	Proactive  bool             `json:"proactive"   env:"PICOCLAW_HEARTBEAT_PROACTIVE"`
+	QuietHours QuietHoursConfig `json:"quiet_hours"`
+}
+
+// QuietHoursConfig is a daily window, in "HH:MM", in which heartbeats don't
+// run. An end before the start wraps past midnight.
+type QuietHoursConfig struct {
+	Start string `json:"start" env:"PICOCLAW_HEARTBEAT_QUIET_HOURS_START"`
+	End   string `json:"end"   env:"PICOCLAW_HEARTBEAT_QUIET_HOURS_END"`
+	TZ    string `json:"tz"    env:"PICOCLAW_HEARTBEAT_QUIET_HOURS_TZ"`
+}
+
+func (c *QuietHoursConfig) Validate() error {
+	if c.Start == "" && c.End == "" {
+		return nil
+	}
+	for _, t := range []string{c.Start, c.End} {
+		if _, err := time.Parse("15:04", t); err != nil {
+			return fmt.Errorf("heartbeat.quiet_hours: invalid time %q, want HH:MM", t)
+		}
+	}
+	if c.TZ != "" {
+		if _, err := usertime.Load(c.TZ); err != nil {
+			return fmt.Errorf("heartbeat.quiet_hours: %w", err)
+		}
+	}
+	return nil
 }
 
 type DevicesConfig struct {
@@ -394,6 +942,11 @@ type ModelConfig struct {
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
+	Vision         bool   `json:"vision,omitempty"`           // Model accepts images in user messages
+
+	// Prices in US dollars per million tokens, for agents.defaults.budget
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -429,26 +982,598 @@ type PerplexityConfig struct {
 	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_PERPLEXITY_MAX_RESULTS"`
 }
 
+type SearxNGConfig struct {
+	Enabled    bool   `json:"enabled"     env:"PICOCLAW_TOOLS_WEB_SEARXNG_ENABLED"`
+	BaseURL    string `json:"base_url"    env:"PICOCLAW_TOOLS_WEB_SEARXNG_BASE_URL"`
+	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_SEARXNG_MAX_RESULTS"`
+}
+
+type TavilyConfig struct {
+	Enabled    bool   `json:"enabled"     env:"PICOCLAW_TOOLS_WEB_TAVILY_ENABLED"`
+	APIKey     string `json:"api_key"     env:"PICOCLAW_TOOLS_WEB_TAVILY_API_KEY"`
+	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_TAVILY_MAX_RESULTS"`
+}
+
+// WebToolsConfig configures web search. The first enabled backend in the
+// order Perplexity, Tavily, Brave, SearxNG, DuckDuckGo is used. SafeSearch
+// is "off", "moderate" or "strict" for the backends that support it.
 type WebToolsConfig struct {
 	Brave      BraveConfig      `json:"brave"`
 	DuckDuckGo DuckDuckGoConfig `json:"duckduckgo"`
 	Perplexity PerplexityConfig `json:"perplexity"`
+	SearxNG    SearxNGConfig    `json:"searxng"`
+	Tavily     TavilyConfig     `json:"tavily"`
+	SafeSearch string           `json:"safe_search" env:"PICOCLAW_TOOLS_WEB_SAFE_SEARCH"`
 }
 
 type CronToolsConfig struct {
-	ExecTimeoutMinutes int `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"` // 0 means no timeout
+	// 0 means no timeout
+	ExecTimeoutMinutes int             `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"`
+	Jobs               []CronJobConfig `json:"jobs,omitempty"`
+}
+
+// CronJobConfig is a scheduled task defined in the config. At each time
+// Schedule (a cron expression, in TZ or local time) matches, the agent
+// handles Prompt, or Tool is called with Args, and the result is sent to
+// To on Channel.
+type CronJobConfig struct {
+	Name     string         `json:"name"`
+	Schedule string         `json:"schedule"`
+	TZ       string         `json:"tz,omitempty"`
+	Prompt   string         `json:"prompt,omitempty"`
+	Tool     string         `json:"tool,omitempty"`
+	Args     map[string]any `json:"args,omitempty"`
+	Channel  string         `json:"channel"`
+	To       string         `json:"to"`
+	Disabled bool           `json:"disabled,omitempty"`
+}
+
+func (c *CronToolsConfig) Validate() error {
+	names := make(map[string]bool, len(c.Jobs))
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("tools.cron.jobs[%d]: name is required", i)
+		}
+		if names[job.Name] {
+			return fmt.Errorf("tools.cron.jobs: duplicate name %q", job.Name)
+		}
+		names[job.Name] = true
+		if !gronx.IsValid(job.Schedule) {
+			return fmt.Errorf("tools.cron.jobs.%s: invalid schedule %q", job.Name, job.Schedule)
+		}
+		if job.TZ != "" {
+			if _, err := usertime.Load(job.TZ); err != nil {
+				return fmt.Errorf("tools.cron.jobs.%s: %w", job.Name, err)
+			}
+		}
+		if (job.Prompt == "") == (job.Tool == "") {
+			return fmt.Errorf("tools.cron.jobs.%s: set either prompt or tool", job.Name)
+		}
+		if job.Channel == "" || job.To == "" {
+			return fmt.Errorf("tools.cron.jobs.%s: channel and to are required", job.Name)
+		}
+	}
+	return nil
 }
 
 type ExecConfig struct {
-	EnableDenyPatterns bool     `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
-	CustomDenyPatterns []string `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	EnableDenyPatterns bool                `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
+	CustomDenyPatterns []string            `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	AllowPatterns      FlexibleStringSlice `json:"allow_patterns"       env:"PICOCLAW_TOOLS_EXEC_ALLOW_PATTERNS"`
+	TimeoutSeconds     int                 `json:"timeout_seconds"      env:"PICOCLAW_TOOLS_EXEC_TIMEOUT_SECONDS"`
+	MaxOutputChars     int                 `json:"max_output_chars"     env:"PICOCLAW_TOOLS_EXEC_MAX_OUTPUT_CHARS"`
+	Sandbox            ExecSandboxConfig   `json:"sandbox"`
+}
+
+// Sandbox types for ExecSandboxConfig.Type.
+const (
+	ExecSandboxNone   = "none"
+	ExecSandboxBwrap  = "bwrap"
+	ExecSandboxNsjail = "nsjail"
+)
+
+// ExecSandboxConfig confines exec commands with bubblewrap or nsjail. The
+// file system is mounted read-only except for the workspace and /tmp.
+type ExecSandboxConfig struct {
+	Type    string              `json:"type"    env:"PICOCLAW_TOOLS_EXEC_SANDBOX_TYPE"`
+	Path    string              `json:"path"    env:"PICOCLAW_TOOLS_EXEC_SANDBOX_PATH"` // binary; looked up on PATH if empty
+	Network bool                `json:"network" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_NETWORK"`
+	Args    FlexibleStringSlice `json:"args"    env:"PICOCLAW_TOOLS_EXEC_SANDBOX_ARGS"` // extra flags for the sandbox
+}
+
+// ApprovalConfig marks tools that need a user's go-ahead in chat before
+// they run: those listed in Tools and, when Risk is set, every tool of that
+// risk level or higher. A request that isn't answered within TimeoutSeconds
+// is denied. A denial stops the run, unless ContinueOnDeny lets the agent
+// carry on without the tool.
+type ApprovalConfig struct {
+	Tools          FlexibleStringSlice `json:"tools"            env:"PICOCLAW_TOOLS_APPROVAL_TOOLS"`
+	Risk           string              `json:"risk"             env:"PICOCLAW_TOOLS_APPROVAL_RISK"`
+	TimeoutSeconds int                 `json:"timeout_seconds"  env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"`
+	ContinueOnDeny bool                `json:"continue_on_deny" env:"PICOCLAW_TOOLS_APPROVAL_CONTINUE_ON_DENY"`
+}
+
+// DryRunConfig makes the tools that change things, every high-risk tool and
+// those listed in Tools, describe what a call would do instead of doing it.
+// Conversations can turn it on or off for themselves with /dryrun.
+type DryRunConfig struct {
+	Enabled bool                `json:"enabled" env:"PICOCLAW_TOOLS_DRY_RUN_ENABLED"`
+	Tools   FlexibleStringSlice `json:"tools"   env:"PICOCLAW_TOOLS_DRY_RUN_TOOLS"`
+}
+
+// ToolAccessConfig picks the tools each conversation may use. Rules are
+// keyed by channel ("wecom") or by channel and chat ID ("telegram:123456");
+// the most specific one applies, and Default covers the rest.
+type ToolAccessConfig struct {
+	Default ToolAccessRule            `json:"default"`
+	Rules   map[string]ToolAccessRule `json:"rules"`
+}
+
+// ToolAccessRule allows the tools whose risk is at most MaxRisk ("low",
+// "medium" or "high"; empty for any). Allow, when set, keeps only the tools
+// it lists, and Deny removes tools.
+type ToolAccessRule struct {
+	MaxRisk string              `json:"max_risk"`
+	Allow   FlexibleStringSlice `json:"allow"`
+	Deny    FlexibleStringSlice `json:"deny"`
+}
+
+// validRisk reports whether s names a tool risk level, or is empty.
+func validRisk(s string) bool {
+	switch s {
+	case "", "low", "medium", "high":
+		return true
+	}
+	return false
+}
+
+// Validate checks the risk levels of the rules.
+func (c *ToolAccessConfig) Validate() error {
+	check := func(name string, rule ToolAccessRule) error {
+		if validRisk(rule.MaxRisk) {
+			return nil
+		}
+		return fmt.Errorf("tools.access.%s: max_risk must be low, medium or high, got %q", name, rule.MaxRisk)
+	}
+	if err := check("default", c.Default); err != nil {
+		return err
+	}
+	for key, rule := range c.Rules {
+		if err := check("rules."+key, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToolResultsConfig caps what one tool call adds to the conversation.
+// Results longer than MaxChars, or the tool's entry in PerTool, are cut to
+// their start and end, or condensed by SummaryModel when Summarize is on.
+// A limit of 0 keeps results whole.
+type ToolResultsConfig struct {
+	MaxChars     int            `json:"max_chars"     env:"PICOCLAW_TOOLS_RESULTS_MAX_CHARS"`
+	PerTool      map[string]int `json:"per_tool"`
+	Summarize    bool           `json:"summarize"     env:"PICOCLAW_TOOLS_RESULTS_SUMMARIZE"`
+	SummaryModel string         `json:"summary_model" env:"PICOCLAW_TOOLS_RESULTS_SUMMARY_MODEL"` // empty: agent's model
+}
+
+// ToolRetryConfig retries tool calls that fail for a passing reason, such
+// as a network hiccup, before the model sees the failure. Default applies
+// to every tool; an entry in PerTool replaces it for that tool.
+type ToolRetryConfig struct {
+	Default ToolRetryPolicy            `json:"default"`
+	PerTool map[string]ToolRetryPolicy `json:"per_tool"`
+}
+
+// ToolRetryPolicy makes up to Attempts tries in all, waiting BackoffMS
+// before the second and twice as long before each one after, up to
+// MaxBackoffMS. Only failures of a class in RetryOn (network, timeout,
+// rate_limit or server) are retried; empty retries all of them. Attempts
+// of 0 or 1 never retry.
+type ToolRetryPolicy struct {
+	Attempts     int                 `json:"attempts"`
+	BackoffMS    int                 `json:"backoff_ms"`
+	MaxBackoffMS int                 `json:"max_backoff_ms,omitempty"`
+	RetryOn      FlexibleStringSlice `json:"retry_on,omitempty"`
+}
+
+// ToolRetryClasses are the kinds of failure a ToolRetryPolicy can retry.
+var ToolRetryClasses = []string{"network", "timeout", "rate_limit", "server"}
+
+// Validate checks the policies' numbers and error classes.
+func (c *ToolRetryConfig) Validate() error {
+	check := func(name string, p ToolRetryPolicy) error {
+		if p.Attempts < 0 || p.BackoffMS < 0 || p.MaxBackoffMS < 0 {
+			return fmt.Errorf("tools.retry.%s: attempts and backoff can't be negative", name)
+		}
+		for _, class := range p.RetryOn {
+			if !slices.Contains(ToolRetryClasses, class) {
+				return fmt.Errorf("tools.retry.%s: unknown retry_on class %q, want one of %s",
+					name, class, strings.Join(ToolRetryClasses, ", "))
+			}
+		}
+		return nil
+	}
+	if err := check("default", c.Default); err != nil {
+		return err
+	}
+	for tool, p := range c.PerTool {
+		if err := check("per_tool."+tool, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToolCacheConfig keeps the results of tools whose answers don't depend on
+// who asks, such as searches and page fetches, so the same call within a
+// conversation or from a later scheduled job is answered without the
+// network. TTLMinutes maps a tool to how long its results are kept; tools
+// not listed aren't cached. At most MaxEntries results are kept.
+type ToolCacheConfig struct {
+	Enabled    bool           `json:"enabled"     env:"PICOCLAW_TOOLS_CACHE_ENABLED"`
+	TTLMinutes map[string]int `json:"ttl_minutes"`
+	MaxEntries int            `json:"max_entries" env:"PICOCLAW_TOOLS_CACHE_MAX_ENTRIES"`
+}
+
+// UntrustedContentConfig guards against instructions planted in what tools
+// bring in from outside, such as web pages, before it enters the prompt.
+// Results of Tools (names, or patterns like "mcp_*") are put in a marked
+// block the model is told to read as data only. StripInstructions removes
+// text that addresses an AI, like "ignore previous instructions", and
+// Classifier has ClassifierModel (a model_list entry; empty for the
+// agent's) check each result and flag suspected injections.
+type UntrustedContentConfig struct {
+	Enabled           bool                `json:"enabled"            env:"PICOCLAW_TOOLS_UNTRUSTED_ENABLED"`
+	Tools             FlexibleStringSlice `json:"tools"              env:"PICOCLAW_TOOLS_UNTRUSTED_TOOLS"`
+	StripInstructions bool                `json:"strip_instructions" env:"PICOCLAW_TOOLS_UNTRUSTED_STRIP_INSTRUCTIONS"`
+	Classifier        bool                `json:"classifier"         env:"PICOCLAW_TOOLS_UNTRUSTED_CLASSIFIER"`
+	ClassifierModel   string              `json:"classifier_model"   env:"PICOCLAW_TOOLS_UNTRUSTED_CLASSIFIER_MODEL"`
+}
+
+// Applies reports whether results of the named tool are untrusted.
+func (c UntrustedContentConfig) Applies(tool string) bool {
+	if !c.Enabled {
+		return false
+	}
+	for _, pattern := range c.Tools {
+		if ok, _ := filepath.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DocsToolsConfig sets up search_docs, which searches workspace documents
+// by meaning. Files under Paths (the whole workspace when empty) with one of
+// Extensions are split into chunks of about ChunkChars and embedded with
+// EmbeddingModel, falling back to memory.embedding_model. Changed files are
+// indexed again every ReindexMinutes and before a search.
+type DocsToolsConfig struct {
+	Enabled        bool                `json:"enabled"         env:"PICOCLAW_TOOLS_DOCS_ENABLED"`
+	EmbeddingModel string              `json:"embedding_model" env:"PICOCLAW_TOOLS_DOCS_EMBEDDING_MODEL"`
+	Paths          FlexibleStringSlice `json:"paths"           env:"PICOCLAW_TOOLS_DOCS_PATHS"`
+	Extensions     FlexibleStringSlice `json:"extensions"      env:"PICOCLAW_TOOLS_DOCS_EXTENSIONS"`
+	ChunkChars     int                 `json:"chunk_chars"     env:"PICOCLAW_TOOLS_DOCS_CHUNK_CHARS"`
+	MaxFileKB      int                 `json:"max_file_kb"     env:"PICOCLAW_TOOLS_DOCS_MAX_FILE_KB"`
+	TopK           int                 `json:"top_k"           env:"PICOCLAW_TOOLS_DOCS_TOP_K"`
+	MinScore       float64             `json:"min_score"       env:"PICOCLAW_TOOLS_DOCS_MIN_SCORE"`
+	ReindexMinutes int                 `json:"reindex_minutes" env:"PICOCLAW_TOOLS_DOCS_REINDEX_MINUTES"`
+}
+
+// AuditToolsConfig controls the audit log of tool calls, kept in each
+// agent's workspace at state/audit.jsonl. String arguments longer than
+// MaxArgChars are shortened in the log; 0 keeps them whole.
+type AuditToolsConfig struct {
+	Enabled     bool `json:"enabled"       env:"PICOCLAW_TOOLS_AUDIT_ENABLED"`
+	MaxArgChars int  `json:"max_arg_chars" env:"PICOCLAW_TOOLS_AUDIT_MAX_ARG_CHARS"`
+}
+
+// ImageToolsConfig sets up generate_image. Provider is "openai", for the
+// OpenAI Images API or a compatible endpoint at api_base, or "bedrock",
+// which runs Titan Image, Nova Canvas or Stability models in Region. Images
+// are saved under Dir in the workspace.
+type ImageToolsConfig struct {
+	Enabled         bool   `json:"enabled"           env:"PICOCLAW_TOOLS_IMAGE_ENABLED"`
+	Provider        string `json:"provider"          env:"PICOCLAW_TOOLS_IMAGE_PROVIDER"`
+	APIKey          string `json:"api_key"           env:"PICOCLAW_TOOLS_IMAGE_API_KEY"`
+	APIBase         string `json:"api_base"          env:"PICOCLAW_TOOLS_IMAGE_API_BASE"`
+	Model           string `json:"model"             env:"PICOCLAW_TOOLS_IMAGE_MODEL"`
+	Size            string `json:"size"              env:"PICOCLAW_TOOLS_IMAGE_SIZE"`
+	Region          string `json:"region"            env:"PICOCLAW_TOOLS_IMAGE_REGION"`
+	AccessKeyID     string `json:"access_key_id"     env:"PICOCLAW_TOOLS_IMAGE_ACCESS_KEY_ID"`
+	SecretAccessKey string `json:"secret_access_key" env:"PICOCLAW_TOOLS_IMAGE_SECRET_ACCESS_KEY"`
+	Dir             string `json:"dir"               env:"PICOCLAW_TOOLS_IMAGE_DIR"`
+}
+
+// NotesToolsConfig sets up the notes tool. Notes are markdown files under
+// Dir in the workspace; with memory enabled they are also recalled like
+// other memories.
+type NotesToolsConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_TOOLS_NOTES_ENABLED"`
+	Dir     string `json:"dir"     env:"PICOCLAW_TOOLS_NOTES_DIR"`
+}
+
+// UserProfilesConfig sets up the user_profile tool. Each user's name, time
+// zone, preferences and standing instructions are kept in a markdown file
+// under Dir in the workspace and shown to the agent in their conversations.
+type UserProfilesConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_TOOLS_USER_PROFILES_ENABLED"`
+	Dir     string `json:"dir"     env:"PICOCLAW_TOOLS_USER_PROFILES_DIR"`
+}
+
+// TodoToolsConfig sets up the todo tool, whose list is kept in the agent's
+// workspace at state/tasks.json.
+type TodoToolsConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_TOOLS_TODO_ENABLED"`
+}
+
+// BackgroundTasksConfig sets up the background_task tool and /tasks: long
+// jobs that run outside the conversation that started them. MaxConcurrent
+// run at once and up to MaxQueued wait behind them. A running task tells
+// the chat what it is doing every ProgressSeconds (0 only when it ends).
+type BackgroundTasksConfig struct {
+	Enabled         bool `json:"enabled"          env:"PICOCLAW_TOOLS_TASKS_ENABLED"`
+	MaxConcurrent   int  `json:"max_concurrent"   env:"PICOCLAW_TOOLS_TASKS_MAX_CONCURRENT"`
+	MaxQueued       int  `json:"max_queued"       env:"PICOCLAW_TOOLS_TASKS_MAX_QUEUED"`
+	ProgressSeconds int  `json:"progress_seconds" env:"PICOCLAW_TOOLS_TASKS_PROGRESS_SECONDS"`
+}
+
+// WeatherToolsConfig sets up the weather tool, which uses Open-Meteo. When
+// the agent names no place it reports on Location, a place name, or on
+// Latitude and Longitude when they are set. Units is "metric" or
+// "imperial"; Days is how many days of forecast it gives by default.
+type WeatherToolsConfig struct {
+	Enabled   bool    `json:"enabled"   env:"PICOCLAW_TOOLS_WEATHER_ENABLED"`
+	Location  string  `json:"location"  env:"PICOCLAW_TOOLS_WEATHER_LOCATION"`
+	Latitude  float64 `json:"latitude"  env:"PICOCLAW_TOOLS_WEATHER_LATITUDE"`
+	Longitude float64 `json:"longitude" env:"PICOCLAW_TOOLS_WEATHER_LONGITUDE"`
+	Units     string  `json:"units"     env:"PICOCLAW_TOOLS_WEATHER_UNITS"`
+	Days      int     `json:"days"      env:"PICOCLAW_TOOLS_WEATHER_DAYS"`
+}
+
+// DataToolsConfig sets up the analyze_data tool, which answers questions
+// about CSV and XLSX files. Files over MaxFileMB aren't loaded, and at most
+// MaxRows rows of a result are shown.
+type DataToolsConfig struct {
+	Enabled   bool `json:"enabled"     env:"PICOCLAW_TOOLS_DATA_ENABLED"`
+	MaxFileMB int  `json:"max_file_mb" env:"PICOCLAW_TOOLS_DATA_MAX_FILE_MB"`
+	MaxRows   int  `json:"max_rows"    env:"PICOCLAW_TOOLS_DATA_MAX_ROWS"`
+}
+
+// ClipboardToolsConfig sets up the clipboard tool, for agents running on a
+// desktop. It is off by default since servers have no clipboard and the
+// clipboard often holds passwords. At most MaxChars of it are read.
+type ClipboardToolsConfig struct {
+	Enabled  bool `json:"enabled"   env:"PICOCLAW_TOOLS_CLIPBOARD_ENABLED"`
+	MaxChars int  `json:"max_chars" env:"PICOCLAW_TOOLS_CLIPBOARD_MAX_CHARS"`
+}
+
+// ScreenshotToolsConfig sets up the screenshot tool, which captures the
+// desktop picoclaw runs on for models that accept images. Captures wider
+// than MaxWidth pixels are scaled down before they are sent.
+type ScreenshotToolsConfig struct {
+	Enabled  bool `json:"enabled"   env:"PICOCLAW_TOOLS_SCREENSHOT_ENABLED"`
+	MaxWidth int  `json:"max_width" env:"PICOCLAW_TOOLS_SCREENSHOT_MAX_WIDTH"`
+}
+
+// SSHToolsConfig sets up the ssh tool, which runs commands on the Hosts
+// listed here and nowhere else. Host keys are checked against KnownHosts
+// (~/.ssh/known_hosts when empty) unless a host pins its own HostKey.
+type SSHToolsConfig struct {
+	Enabled        bool                     `json:"enabled"          env:"PICOCLAW_TOOLS_SSH_ENABLED"`
+	KnownHosts     string                   `json:"known_hosts"      env:"PICOCLAW_TOOLS_SSH_KNOWN_HOSTS"`
+	TimeoutSeconds int                      `json:"timeout_seconds"  env:"PICOCLAW_TOOLS_SSH_TIMEOUT_SECONDS"`
+	MaxOutputChars int                      `json:"max_output_chars" env:"PICOCLAW_TOOLS_SSH_MAX_OUTPUT_CHARS"`
+	Hosts          map[string]SSHHostConfig `json:"hosts"`
+}
+
+// SSHHostConfig is a remote host, logged into as User with the private key
+// in KeyFile. When AllowPatterns is set, only commands matching one of them
+// run; otherwise exec's default deny patterns apply. DenyPatterns are
+// always checked.
+type SSHHostConfig struct {
+	Address       string              `json:"address"` // host or host:port
+	User          string              `json:"user"`
+	KeyFile       string              `json:"key_file"`
+	KeyPassphrase string              `json:"key_passphrase,omitempty"`
+	HostKey       string              `json:"host_key,omitempty"` // "ssh-ed25519 AAAA...", as in known_hosts
+	Description   string              `json:"description,omitempty"`
+	AllowPatterns FlexibleStringSlice `json:"allow_patterns,omitempty"`
+	DenyPatterns  FlexibleStringSlice `json:"deny_patterns,omitempty"`
+}
+
+// KeyPath returns KeyFile with ~ expanded.
+func (c SSHHostConfig) KeyPath() string {
+	return expandHome(c.KeyFile)
+}
+
+// KnownHostsPath returns the known_hosts file host keys are checked against.
+func (c *SSHToolsConfig) KnownHostsPath() string {
+	if c.KnownHosts == "" {
+		return expandHome("~/.ssh/known_hosts")
+	}
+	return expandHome(c.KnownHosts)
+}
+
+func (c *SSHToolsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for name, host := range c.Hosts {
+		if host.Address == "" || host.User == "" || host.KeyFile == "" {
+			return fmt.Errorf("tools.ssh.hosts.%s: address, user and key_file are required", name)
+		}
+		for _, pattern := range append(append([]string{}, host.AllowPatterns...), host.DenyPatterns...) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("tools.ssh.hosts.%s: invalid pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// WebhookToolsConfig declares tools that call HTTP endpoints, keyed by tool
+// name, so the agent can use the user's own services without Go code.
+type WebhookToolsConfig map[string]WebhookToolConfig
+
+// WebhookToolConfig is one webhook tool. Parameters is the JSON schema of
+// its arguments. URL, Headers and Body are Go text/templates over the
+// arguments ({{.city}}); without Body, POST, PUT and PATCH send the
+// arguments as JSON. Method defaults to POST with a Body, GET otherwise.
+type WebhookToolConfig struct {
+	Description    string            `json:"description"`
+	Parameters     map[string]any    `json:"parameters,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	Risk           string            `json:"risk,omitempty"` // low, medium or high; default by method
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+	MaxChars       int               `json:"max_chars,omitempty"`
+}
+
+var reToolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func (c WebhookToolsConfig) Validate() error {
+	for name, tool := range c {
+		if !reToolName.MatchString(name) {
+			return fmt.Errorf("tools.webhooks.%s: names may only have letters, digits, _ and -", name)
+		}
+		if tool.Description == "" || tool.URL == "" {
+			return fmt.Errorf("tools.webhooks.%s: description and url are required", name)
+		}
+		switch strings.ToUpper(tool.Method) {
+		case "", "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return fmt.Errorf("tools.webhooks.%s: method must be GET, POST, PUT, PATCH or DELETE, got %q",
+				name, tool.Method)
+		}
+		switch tool.Risk {
+		case "", "low", "medium", "high":
+		default:
+			return fmt.Errorf("tools.webhooks.%s: risk must be low, medium or high, got %q", name, tool.Risk)
+		}
+		if t, ok := tool.Parameters["type"]; ok && t != "object" {
+			return fmt.Errorf("tools.webhooks.%s: parameters must be a JSON schema of type object", name)
+		}
+		if tool.TimeoutSeconds < 0 || tool.MaxChars < 0 {
+			return fmt.Errorf("tools.webhooks.%s: timeout_seconds and max_chars can't be negative", name)
+		}
+	}
+	return nil
+}
+
+// SQLToolsConfig sets up the sql tool, which runs read-only queries against
+// the Databases listed here. Results are cut off after MaxRows rows or
+// MaxChars characters.
+type SQLToolsConfig struct {
+	Enabled        bool                         `json:"enabled"         env:"PICOCLAW_TOOLS_SQL_ENABLED"`
+	MaxRows        int                          `json:"max_rows"        env:"PICOCLAW_TOOLS_SQL_MAX_ROWS"`
+	MaxChars       int                          `json:"max_chars"       env:"PICOCLAW_TOOLS_SQL_MAX_CHARS"`
+	TimeoutSeconds int                          `json:"timeout_seconds" env:"PICOCLAW_TOOLS_SQL_TIMEOUT_SECONDS"`
+	Databases      map[string]SQLDatabaseConfig `json:"databases"`
+}
+
+// SQLDatabaseConfig is a database the sql tool can query. Driver is
+// postgres, mysql or sqlite, and DSN is in that driver's format.
+type SQLDatabaseConfig struct {
+	Driver      string `json:"driver"`
+	DSN         string `json:"dsn"`
+	Description string `json:"description,omitempty"`
+}
+
+// DataSource returns DSN, with ~ expanded in SQLite paths.
+func (c SQLDatabaseConfig) DataSource() string {
+	if c.Driver == "sqlite" {
+		return expandHome(c.DSN)
+	}
+	return c.DSN
+}
+
+func (c *SQLToolsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for name, db := range c.Databases {
+		switch db.Driver {
+		case "postgres", "mysql", "sqlite":
+		default:
+			return fmt.Errorf("tools.sql.databases.%s: driver must be postgres, mysql or sqlite, got %q", name, db.Driver)
+		}
+		if db.DSN == "" {
+			return fmt.Errorf("tools.sql.databases.%s: dsn is required", name)
+		}
+	}
+	return nil
+}
+
+// FetchToolsConfig limits the fetch_url tool. Pages are cached per session
+// for CacheMinutes; 0 turns the cache off.
+type FetchToolsConfig struct {
+	MaxBytes      int  `json:"max_bytes"      env:"PICOCLAW_TOOLS_FETCH_MAX_BYTES"`
+	MaxChars      int  `json:"max_chars"      env:"PICOCLAW_TOOLS_FETCH_MAX_CHARS"`
+	RespectRobots bool `json:"respect_robots" env:"PICOCLAW_TOOLS_FETCH_RESPECT_ROBOTS"`
+	CacheMinutes  int  `json:"cache_minutes"  env:"PICOCLAW_TOOLS_FETCH_CACHE_MINUTES"`
+}
+
+// RunCodeConfig enables the run_code tool, which runs Python and Node
+// snippets in throwaway Docker containers. Containers have no network
+// unless Network is set.
+type RunCodeConfig struct {
+	Enabled        bool    `json:"enabled"          env:"PICOCLAW_TOOLS_RUN_CODE_ENABLED"`
+	Docker         string  `json:"docker"           env:"PICOCLAW_TOOLS_RUN_CODE_DOCKER"` // docker or podman binary
+	PythonImage    string  `json:"python_image"     env:"PICOCLAW_TOOLS_RUN_CODE_PYTHON_IMAGE"`
+	NodeImage      string  `json:"node_image"       env:"PICOCLAW_TOOLS_RUN_CODE_NODE_IMAGE"`
+	Network        bool    `json:"network"          env:"PICOCLAW_TOOLS_RUN_CODE_NETWORK"`
+	CPUs           float64 `json:"cpus"             env:"PICOCLAW_TOOLS_RUN_CODE_CPUS"`
+	MemoryMB       int     `json:"memory_mb"        env:"PICOCLAW_TOOLS_RUN_CODE_MEMORY_MB"`
+	TimeoutSeconds int     `json:"timeout_seconds"  env:"PICOCLAW_TOOLS_RUN_CODE_TIMEOUT_SECONDS"`
+	MaxOutputChars int     `json:"max_output_chars" env:"PICOCLAW_TOOLS_RUN_CODE_MAX_OUTPUT_CHARS"`
+}
+
+// PythonToolConfig enables the python tool, which runs code with a venv in
+// the workspace on the host itself, for hosts without Docker. The module
+// allowlist and limits guard against mistakes; they are not a sandbox.
+type PythonToolConfig struct {
+	Enabled        bool                `json:"enabled"          env:"PICOCLAW_TOOLS_PYTHON_ENABLED"`
+	Interpreter    string              `json:"interpreter"      env:"PICOCLAW_TOOLS_PYTHON_INTERPRETER"`
+	Venv           string              `json:"venv"             env:"PICOCLAW_TOOLS_PYTHON_VENV"` // relative to the workspace
+	AllowedModules FlexibleStringSlice `json:"allowed_modules"  env:"PICOCLAW_TOOLS_PYTHON_ALLOWED_MODULES"`
+	TimeoutSeconds int                 `json:"timeout_seconds"  env:"PICOCLAW_TOOLS_PYTHON_TIMEOUT_SECONDS"`
+	MemoryMB       int                 `json:"memory_mb"        env:"PICOCLAW_TOOLS_PYTHON_MEMORY_MB"`
+	MaxOutputChars int                 `json:"max_output_chars" env:"PICOCLAW_TOOLS_PYTHON_MAX_OUTPUT_CHARS"`
 }
 
 type ToolsConfig struct {
-	Web    WebToolsConfig    `json:"web"`
-	Cron   CronToolsConfig   `json:"cron"`
-	Exec   ExecConfig        `json:"exec"`
-	Skills SkillsToolsConfig `json:"skills"`
+	Web        WebToolsConfig         `json:"web"`
+	Fetch      FetchToolsConfig       `json:"fetch"`
+	Cron       CronToolsConfig        `json:"cron"`
+	Exec       ExecConfig             `json:"exec"`
+	RunCode    RunCodeConfig          `json:"run_code"`
+	Python     PythonToolConfig       `json:"python"`
+	Skills     SkillsToolsConfig      `json:"skills"`
+	Approval   ApprovalConfig         `json:"approval"`
+	DryRun     DryRunConfig           `json:"dry_run"`
+	Access     ToolAccessConfig       `json:"access"`
+	Results    ToolResultsConfig      `json:"results"`
+	Retry      ToolRetryConfig        `json:"retry"`
+	Cache      ToolCacheConfig        `json:"cache"`
+	Untrusted  UntrustedContentConfig `json:"untrusted"`
+	Docs       DocsToolsConfig        `json:"docs"`
+	Audit      AuditToolsConfig       `json:"audit"`
+	Image      ImageToolsConfig       `json:"image"`
+	Notes      NotesToolsConfig       `json:"notes"`
+	Todo       TodoToolsConfig        `json:"todo"`
+	Tasks      BackgroundTasksConfig  `json:"tasks"`
+	Weather    WeatherToolsConfig     `json:"weather"`
+	SSH        SSHToolsConfig         `json:"ssh"`
+	SQL        SQLToolsConfig         `json:"sql"`
+	Data       DataToolsConfig        `json:"data"`
+	Clipboard  ClipboardToolsConfig   `json:"clipboard"`
+	Screenshot ScreenshotToolsConfig  `json:"screenshot"`
+	Profiles   UserProfilesConfig     `json:"user_profiles"`
+	Webhooks   WebhookToolsConfig     `json:"webhooks,omitempty"`
 }
 
 type SkillsToolsConfig struct {
@@ -507,6 +1632,60 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Tools.Access.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.Cron.Validate(); err != nil {
+		return nil, err
+	}
+	for tool, minutes := range cfg.Tools.Cache.TTLMinutes {
+		if minutes < 0 {
+			return nil, fmt.Errorf("tools.cache.ttl_minutes.%s can't be negative", tool)
+		}
+	}
+	if err := cfg.Tools.Retry.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.SSH.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.SQL.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.Webhooks.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Heartbeat.QuietHours.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Channels.Groups.Validate(); err != nil {
+		return nil, err
+	}
+	if tz := cfg.Agents.Defaults.Timezone; tz != "" {
+		if _, err := usertime.Load(tz); err != nil {
+			return nil, fmt.Errorf("agents.defaults.timezone: %w", err)
+		}
+	}
+	if b := cfg.Agents.Defaults.Budget; b.MaxTokens < 0 || b.MaxCost < 0 {
+		return nil, fmt.Errorf("agents.defaults.budget: max_tokens and max_cost can't be negative")
+	}
+	for i, allowed := range cfg.Agents.Defaults.AllowedPaths {
+		path := expandHome(allowed.Path)
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("agents.defaults.allowed_paths: %q must be an absolute path", allowed.Path)
+		}
+		cfg.Agents.Defaults.AllowedPaths[i].Path = filepath.Clean(path)
+	}
+	if !validRisk(cfg.Tools.Approval.Risk) {
+		return nil, fmt.Errorf("tools.approval.risk must be low, medium or high, got %q", cfg.Tools.Approval.Risk)
+	}
+	if cfg.Memory.Enabled && cfg.Memory.EmbeddingModel == "" {
+		return nil, fmt.Errorf("memory.embedding_model is required when memory is enabled")
+	}
+	if cfg.Tools.Docs.Enabled && cfg.Tools.Docs.EmbeddingModel == "" && cfg.Memory.EmbeddingModel == "" {
+		return nil, fmt.Errorf("tools.docs.embedding_model is required when docs search is enabled")
+	}
+
 	return cfg, nil
 }
 
@@ -606,6 +1785,38 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// HasVisionModel reports whether any model_list entry accepts images.
+func (c *Config) HasVisionModel() bool {
+	for i := range c.ModelList {
+		if c.ModelList[i].Vision {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVisionModel reports whether the model_list entry named modelName
+// accepts images.
+func (c *Config) IsVisionModel(modelName string) bool {
+	for _, m := range c.findMatches(modelName) {
+		if m.Vision {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelPrice returns the input and output prices, in US dollars per
+// million tokens, of the model_list entry named modelName; 0 when unset.
+func (c *Config) ModelPrice(modelName string) (input, output float64) {
+	for _, m := range c.findMatches(modelName) {
+		if m.InputPrice > 0 || m.OutputPrice > 0 {
+			return m.InputPrice, m.OutputPrice
+		}
+	}
+	return 0, 0
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
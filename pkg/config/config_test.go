@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -392,3 +393,226 @@ func TestLoadConfig_OpenAIWebSearchCanBeDisabled(t *testing.T) {
 		t.Fatal("OpenAI codex web search should be false when disabled in config file")
 	}
 }
+
+func TestLoadConfig_ToolAccessRejectsUnknownRisk(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	data := `{"tools":{"access":{"rules":{"wecom":{"max_risk":"none"}}}}}`
+	if err := os.WriteFile(configPath, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "tools.access.rules.wecom") {
+		t.Fatalf("LoadConfig() error = %v, want max_risk error for the wecom rule", err)
+	}
+}
+
+func TestLoadConfig_AllowedPaths(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	write := func(data string) {
+		if err := os.WriteFile(configPath, []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	write(`{"agents":{"defaults":{"allowed_paths":[{"path":"/etc/hosts"},{"path":"~/shared/","write":true}]}}}`)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	home, _ := os.UserHomeDir()
+	got := cfg.Agents.Defaults.AllowedPaths
+	if len(got) != 2 || got[0].Path != "/etc/hosts" || got[0].Write || got[1].Path != filepath.Join(home, "shared") {
+		t.Errorf("allowed_paths = %+v", got)
+	}
+
+	write(`{"agents":{"defaults":{"allowed_paths":[{"path":"etc/hosts"}]}}}`)
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("LoadConfig() error = %v, want an error for a relative path", err)
+	}
+}
+
+func TestCronToolsConfig_Validate(t *testing.T) {
+	valid := CronJobConfig{Name: "digest", Schedule: "0 7 * * *", Prompt: "News", Channel: "telegram", To: "42"}
+	tests := []struct {
+		name    string
+		edit    func(*CronJobConfig)
+		wantErr string
+	}{
+		{name: "valid", edit: func(*CronJobConfig) {}},
+		{name: "bad schedule", edit: func(j *CronJobConfig) { j.Schedule = "every day" }, wantErr: "invalid schedule"},
+		{name: "bad zone", edit: func(j *CronJobConfig) { j.TZ = "Mars/Olympus" }, wantErr: "Mars/Olympus"},
+		{name: "prompt and tool", edit: func(j *CronJobConfig) { j.Tool = "exec" }, wantErr: "either prompt or tool"},
+		{name: "no target", edit: func(j *CronJobConfig) { j.To = "" }, wantErr: "channel and to"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := valid
+			tt.edit(&job)
+			cfg := CronToolsConfig{Jobs: []CronJobConfig{job}}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	duplicate := CronToolsConfig{Jobs: []CronJobConfig{valid, valid}}
+	if err := duplicate.Validate(); err == nil {
+		t.Error("Validate() accepted duplicate names")
+	}
+}
+
+func TestToolRetryConfig_Validate(t *testing.T) {
+	cfg := DefaultConfig().Tools.Retry
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default retry config: %v", err)
+	}
+	cfg.PerTool = map[string]ToolRetryPolicy{"ssh": {Attempts: 2, RetryOn: FlexibleStringSlice{"dns"}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `"dns"`) {
+		t.Errorf("Validate() error = %v, want an unknown class error", err)
+	}
+	cfg.PerTool = nil
+	cfg.Default.BackoffMS = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted a negative backoff")
+	}
+}
+
+func TestGroupsConfig_Validate(t *testing.T) {
+	cfg := DefaultConfig().Channels.Groups
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default groups config: %v", err)
+	}
+	cfg.TriggerPatterns = FlexibleStringSlice{`^hey (bot|claw)\b`, `(unclosed`}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "trigger_patterns") {
+		t.Errorf("Validate() error = %v, want a trigger_patterns error", err)
+	}
+	cfg.TriggerPatterns = nil
+	cfg.AttentionSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted a negative attention_seconds")
+	}
+}
+
+func TestWebhookToolsConfig_Validate(t *testing.T) {
+	valid := WebhookToolConfig{Description: "Turns on a light", URL: "http://ha.local/api/light", Method: "post"}
+	tests := []struct {
+		name    string
+		tools   WebhookToolsConfig
+		wantErr string
+	}{
+		{name: "valid", tools: WebhookToolsConfig{"light_on": valid}},
+		{name: "bad name", tools: WebhookToolsConfig{"light on": valid}, wantErr: "names"},
+		{name: "no url", tools: WebhookToolsConfig{"light_on": {Description: "x"}}, wantErr: "url"},
+		{
+			name:    "bad method",
+			tools:   WebhookToolsConfig{"light_on": {Description: "x", URL: "http://x", Method: "TRACE"}},
+			wantErr: "method",
+		},
+		{
+			name:    "bad risk",
+			tools:   WebhookToolsConfig{"light_on": {Description: "x", URL: "http://x", Risk: "extreme"}},
+			wantErr: "risk",
+		},
+		{
+			name: "schema not an object",
+			tools: WebhookToolsConfig{"light_on": {
+				Description: "x", URL: "http://x", Parameters: map[string]any{"type": "string"},
+			}},
+			wantErr: "parameters",
+		},
+	}
+	for _, tt := range tests {
+		err := tt.tools.Validate()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: Validate() error = %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: Validate() error = %v, want one about %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWeComConfig_ResolveMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     WeComConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name: "bot by default",
+			cfg:  WeComConfig{Token: "t", WebhookURL: "https://example.com/hook"},
+			want: WeComModeBot,
+		},
+		{
+			name: "app detected from corp fields",
+			cfg:  WeComConfig{CorpID: "corp", CorpSecret: "secret", AgentID: 1000002},
+			want: WeComModeApp,
+		},
+		{
+			name:    "detected app missing agent_id",
+			cfg:     WeComConfig{CorpID: "corp", CorpSecret: "secret"},
+			want:    WeComModeApp,
+			wantErr: "wecom app mode is missing agent_id",
+		},
+		{
+			name:    "explicit app missing everything",
+			cfg:     WeComConfig{Mode: "App", Token: "t"},
+			want:    WeComModeApp,
+			wantErr: "wecom app mode is missing corp_id, corp_secret, agent_id",
+		},
+		{
+			name:    "explicit bot ignores corp fields",
+			cfg:     WeComConfig{Mode: "bot", CorpID: "corp", Token: "t"},
+			want:    WeComModeBot,
+			wantErr: "wecom bot mode is missing webhook_url",
+		},
+		{
+			name:    "unknown mode",
+			cfg:     WeComConfig{Mode: "kf"},
+			want:    "kf",
+			wantErr: `wecom mode "kf" is not valid; use "bot" or "app"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.ResolveMode()
+			if got != tt.want {
+				t.Errorf("mode = %q, want %q", got, tt.want)
+			}
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("error = %q, want %q", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReviewConfig_Applies(t *testing.T) {
+	cfg := ReviewConfig{Enabled: true, Channels: []string{"slack", "telegram:42"}}
+	for _, tt := range []struct {
+		channel, chatID string
+		want            bool
+	}{
+		{"slack", "C1", true},
+		{"telegram", "42", true},
+		{"telegram", "43", false},
+	} {
+		if got := cfg.Applies(tt.channel, tt.chatID); got != tt.want {
+			t.Errorf("Applies(%q, %q) = %v, want %v", tt.channel, tt.chatID, got, tt.want)
+		}
+	}
+}
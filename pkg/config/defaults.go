@@ -10,13 +10,14 @@ func DefaultConfig() *Config {
 	return &Config{
 		Agents: AgentsConfig{
 			Defaults: AgentDefaults{
-				Workspace:           "~/.picoclaw/workspace",
-				RestrictToWorkspace: true,
-				Provider:            "",
-				Model:               "glm-4.7",
-				MaxTokens:           8192,
-				Temperature:         nil, // nil means use provider default
-				MaxToolIterations:   20,
+				Workspace:            "~/.picoclaw/workspace",
+				RestrictToWorkspace:  true,
+				Provider:             "",
+				Model:                "glm-4.7",
+				MaxTokens:            8192,
+				Temperature:          nil, // nil means use provider default
+				MaxToolIterations:    20,
+				MaxRepeatedToolCalls: 3,
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -30,9 +31,10 @@ func DefaultConfig() *Config {
 				AllowFrom: FlexibleStringSlice{},
 			},
 			Telegram: TelegramConfig{
-				Enabled:   false,
-				Token:     "",
-				AllowFrom: FlexibleStringSlice{},
+				Enabled:          false,
+				Token:            "",
+				AllowFrom:        FlexibleStringSlice{},
+				ProgressiveReply: true,
 			},
 			Feishu: FeishuConfig{
 				Enabled:           false,
@@ -43,10 +45,11 @@ func DefaultConfig() *Config {
 				AllowFrom:         FlexibleStringSlice{},
 			},
 			Discord: DiscordConfig{
-				Enabled:     false,
-				Token:       "",
-				AllowFrom:   FlexibleStringSlice{},
-				MentionOnly: false,
+				Enabled:          false,
+				Token:            "",
+				AllowFrom:        FlexibleStringSlice{},
+				MentionOnly:      false,
+				ProgressiveReply: true,
 			},
 			MaixCam: MaixCamConfig{
 				Enabled:   false,
@@ -67,10 +70,11 @@ func DefaultConfig() *Config {
 				AllowFrom:    FlexibleStringSlice{},
 			},
 			Slack: SlackConfig{
-				Enabled:   false,
-				BotToken:  "",
-				AppToken:  "",
-				AllowFrom: FlexibleStringSlice{},
+				Enabled:          false,
+				BotToken:         "",
+				AppToken:         "",
+				AllowFrom:        FlexibleStringSlice{},
+				ProgressiveReply: true,
 			},
 			LINE: LINEConfig{
 				Enabled:            false,
@@ -90,28 +94,152 @@ func DefaultConfig() *Config {
 				AllowFrom:          FlexibleStringSlice{},
 			},
 			WeCom: WeComConfig{
-				Enabled:        false,
-				Token:          "",
-				EncodingAESKey: "",
-				WebhookURL:     "",
-				WebhookHost:    "0.0.0.0",
-				WebhookPort:    18793,
-				WebhookPath:    "/webhook/wecom",
-				AllowFrom:      FlexibleStringSlice{},
-				ReplyTimeout:   5,
+				Enabled:         false,
+				Token:           "",
+				EncodingAESKey:  "",
+				WebhookURL:      "",
+				WebhookHost:     "0.0.0.0",
+				WebhookPort:     18793,
+				WebhookPath:     "/webhook/wecom",
+				AllowFrom:       FlexibleStringSlice{},
+				ReplyTimeout:    5,
+				ReplyFormat:     "text",
+				TimestampWindow: 300,
 			},
 			WeComApp: WeComAppConfig{
-				Enabled:        false,
-				CorpID:         "",
-				CorpSecret:     "",
-				AgentID:        0,
-				Token:          "",
-				EncodingAESKey: "",
-				WebhookHost:    "0.0.0.0",
-				WebhookPort:    18792,
-				WebhookPath:    "/webhook/wecom-app",
-				AllowFrom:      FlexibleStringSlice{},
-				ReplyTimeout:   5,
+				Enabled:         false,
+				CorpID:          "",
+				CorpSecret:      "",
+				AgentID:         0,
+				Token:           "",
+				EncodingAESKey:  "",
+				WebhookHost:     "0.0.0.0",
+				WebhookPort:     18792,
+				WebhookPath:     "/webhook/wecom-app",
+				AllowFrom:       FlexibleStringSlice{},
+				ReplyTimeout:    5,
+				ReplyFormat:     "text",
+				TimestampWindow: 300,
+			},
+			XMPP: XMPPConfig{
+				Enabled:   false,
+				JID:       "",
+				Password:  "",
+				Resource:  "picoclaw",
+				Rooms:     []string{},
+				Nickname:  "picoclaw",
+				AllowFrom: FlexibleStringSlice{},
+			},
+			Mastodon: MastodonConfig{
+				Enabled:          false,
+				Server:           "",
+				AccessToken:      "",
+				Visibility:       "unlisted",
+				MaxChars:         500,
+				SpoilerThreshold: 500,
+				SpoilerText:      "Long reply",
+				AllowFrom:        FlexibleStringSlice{},
+			},
+			Bluesky: BlueskyConfig{
+				Enabled:      false,
+				Handle:       "",
+				AppPassword:  "",
+				PDS:          "https://bsky.social",
+				PollInterval: 15,
+				DMs:          true,
+				AllowFrom:    FlexibleStringSlice{},
+			},
+			Push: PushConfig{
+				Enabled:  false,
+				Provider: "ntfy",
+				Server:   "https://ntfy.sh",
+				Title:    "PicoClaw",
+			},
+			Feeds: FeedsConfig{
+				Enabled:      false,
+				PollInterval: 30,
+				MaxItems:     5,
+				Feeds:        []FeedSource{},
+			},
+			GitHub: GitHubConfig{
+				Enabled:      false,
+				APIBase:      "https://api.github.com",
+				Repos:        FlexibleStringSlice{},
+				PollInterval: 60,
+				AllowFrom:    FlexibleStringSlice{},
+			},
+			API: APIConfig{
+				Enabled: false,
+				Host:    "127.0.0.1",
+				Port:    18795,
+				Tokens:  FlexibleStringSlice{},
+				Timeout: 300,
+			},
+			GRPC: GRPCConfig{
+				Enabled: false,
+				Host:    "127.0.0.1",
+				Port:    18796,
+				Tokens:  FlexibleStringSlice{},
+			},
+			MQTT: MQTTConfig{
+				Enabled:       false,
+				Broker:        "tcp://127.0.0.1:1883",
+				ClientID:      "picoclaw",
+				CommandTopic:  "picoclaw/command",
+				ResponseTopic: "picoclaw/response",
+				QoS:           1,
+				AllowFrom:     FlexibleStringSlice{},
+			},
+			Webhook: WebhookConfig{
+				Enabled:          false,
+				WebhookHost:      "127.0.0.1",
+				WebhookPort:      18794,
+				WebhookPath:      "/webhook/generic",
+				SenderField:      "$.sender",
+				ChatField:        "$.chat_id",
+				ContentField:     "$.content",
+				OutboundMethod:   "POST",
+				OutboundHeaders:  map[string]string{},
+				OutboundTemplate: "",
+				AllowFrom:        FlexibleStringSlice{},
+			},
+			Access: AccessConfig{
+				RejectMessage: "",
+				Admins:        FlexibleStringSlice{},
+			},
+			RateLimit: RateLimitConfig{
+				UserPerMinute:   0,
+				ChatPerMinute:   0,
+				Burst:           0,
+				CooldownMessage: "",
+			},
+			TLS: TLSConfig{
+				ACMEDomains:  FlexibleStringSlice{},
+				ACMEHTTPAddr: ":80",
+			},
+			Tunnel: TunnelConfig{
+				Provider: "",
+			},
+			Attachments: AttachmentsConfig{
+				Enabled:   true,
+				Dir:       "",
+				MaxSizeMB: 20,
+			},
+			Groups: GroupsConfig{
+				MentionOnly:      true,
+				TriggerWords:     FlexibleStringSlice{},
+				TriggerPatterns:  FlexibleStringSlice{},
+				AttentionSeconds: 120,
+			},
+			Broadcast: BroadcastConfig{
+				Targets: FlexibleStringSlice{},
+			},
+			Language: LanguageConfig{
+				Default:  "en",
+				Channels: map[string]string{},
+			},
+			Delivery: DeliveryConfig{
+				MaxRetries: 3,
 			},
 		},
 		Providers: ProvidersConfig{
@@ -283,12 +411,61 @@ func DefaultConfig() *Config {
 					APIKey:     "",
 					MaxResults: 5,
 				},
+				SearxNG: SearxNGConfig{
+					Enabled:    false,
+					BaseURL:    "",
+					MaxResults: 5,
+				},
+				Tavily: TavilyConfig{
+					Enabled:    false,
+					APIKey:     "",
+					MaxResults: 5,
+				},
+				SafeSearch: "moderate",
+			},
+			Fetch: FetchToolsConfig{
+				MaxBytes:      2 << 20,
+				MaxChars:      50000,
+				RespectRobots: true,
+				CacheMinutes:  15,
 			},
 			Cron: CronToolsConfig{
 				ExecTimeoutMinutes: 5,
 			},
 			Exec: ExecConfig{
 				EnableDenyPatterns: true,
+				TimeoutSeconds:     60,
+				MaxOutputChars:     10000,
+				Sandbox: ExecSandboxConfig{
+					Type:    ExecSandboxNone,
+					Network: true,
+				},
+			},
+			RunCode: RunCodeConfig{
+				Enabled:        false,
+				Docker:         "docker",
+				PythonImage:    "python:3.12-slim",
+				NodeImage:      "node:22-slim",
+				Network:        false,
+				CPUs:           1,
+				MemoryMB:       512,
+				TimeoutSeconds: 60,
+				MaxOutputChars: 10000,
+			},
+			Python: PythonToolConfig{
+				Enabled:     false,
+				Interpreter: "python3",
+				Venv:        ".venv",
+				AllowedModules: FlexibleStringSlice{
+					"math", "cmath", "statistics", "random", "decimal", "fractions",
+					"datetime", "time", "calendar", "zoneinfo",
+					"json", "csv", "re", "string", "textwrap", "unicodedata",
+					"collections", "itertools", "functools", "operator", "heapq", "bisect",
+					"dataclasses", "enum", "typing", "hashlib", "base64", "uuid",
+				},
+				TimeoutSeconds: 30,
+				MemoryMB:       1024,
+				MaxOutputChars: 10000,
 			},
 			Skills: SkillsToolsConfig{
 				Registries: SkillsRegistriesConfig{
@@ -303,6 +480,108 @@ func DefaultConfig() *Config {
 					TTLSeconds: 300,
 				},
 			},
+			Approval: ApprovalConfig{
+				Tools:          FlexibleStringSlice{},
+				TimeoutSeconds: 300,
+			},
+			DryRun: DryRunConfig{
+				Tools: FlexibleStringSlice{},
+			},
+			Access: ToolAccessConfig{
+				Rules: map[string]ToolAccessRule{},
+			},
+			Results: ToolResultsConfig{
+				MaxChars: 20000,
+				PerTool:  map[string]int{},
+			},
+			Retry: ToolRetryConfig{
+				Default: ToolRetryPolicy{Attempts: 1},
+				PerTool: map[string]ToolRetryPolicy{
+					"fetch_url":  {Attempts: 3, BackoffMS: 1000, MaxBackoffMS: 8000},
+					"web_search": {Attempts: 3, BackoffMS: 1000, MaxBackoffMS: 8000},
+				},
+			},
+			Cache: ToolCacheConfig{
+				Enabled: true,
+				TTLMinutes: map[string]int{
+					"web_search": 60,
+					"fetch_url":  15,
+				},
+				MaxEntries: 500,
+			},
+			Untrusted: UntrustedContentConfig{
+				Enabled:           true,
+				Tools:             FlexibleStringSlice{"web_search", "fetch_url"},
+				StripInstructions: true,
+			},
+			Docs: DocsToolsConfig{
+				Enabled:        false,
+				Paths:          FlexibleStringSlice{},
+				Extensions:     FlexibleStringSlice{".md", ".txt", ".pdf", ".go", ".py", ".js", ".ts", ".sh"},
+				ChunkChars:     1500,
+				MaxFileKB:      2048,
+				TopK:           5,
+				MinScore:       0.3,
+				ReindexMinutes: 10,
+			},
+			Audit: AuditToolsConfig{
+				Enabled:     true,
+				MaxArgChars: 1000,
+			},
+			Image: ImageToolsConfig{
+				Enabled:  false,
+				Provider: "openai",
+				Size:     "1024x1024",
+				Dir:      "images",
+			},
+			Notes: NotesToolsConfig{
+				Enabled: true,
+				Dir:     "notes",
+			},
+			Todo: TodoToolsConfig{
+				Enabled: true,
+			},
+			Tasks: BackgroundTasksConfig{
+				Enabled:         true,
+				MaxConcurrent:   1,
+				MaxQueued:       10,
+				ProgressSeconds: 60,
+			},
+			Weather: WeatherToolsConfig{
+				Enabled: true,
+				Units:   "metric",
+				Days:    3,
+			},
+			SSH: SSHToolsConfig{
+				Enabled:        false,
+				TimeoutSeconds: 60,
+				MaxOutputChars: 10000,
+				Hosts:          map[string]SSHHostConfig{},
+			},
+			SQL: SQLToolsConfig{
+				Enabled:        false,
+				MaxRows:        100,
+				MaxChars:       10000,
+				TimeoutSeconds: 30,
+				Databases:      map[string]SQLDatabaseConfig{},
+			},
+			Data: DataToolsConfig{
+				Enabled:   true,
+				MaxFileMB: 20,
+				MaxRows:   50,
+			},
+			Clipboard: ClipboardToolsConfig{
+				Enabled:  false,
+				MaxChars: 20000,
+			},
+			Screenshot: ScreenshotToolsConfig{
+				Enabled:  false,
+				MaxWidth: 1568,
+			},
+			Profiles: UserProfilesConfig{
+				Enabled: true,
+				Dir:     "users",
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
@@ -312,5 +591,18 @@ func DefaultConfig() *Config {
 			Enabled:    false,
 			MonitorUSB: true,
 		},
+		Voice: VoiceConfig{
+			Provider: "",
+			Language: "",
+			TTS: TTSConfig{
+				Provider: "",
+				MaxChars: 1000,
+			},
+		},
+		Memory: MemoryConfig{
+			Enabled:  false,
+			TopK:     5,
+			MinScore: 0.35,
+		},
 	}
 }
@@ -12,6 +12,9 @@ import (
 	"time"
 
 	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usertime"
 )
 
 type CronSchedule struct {
@@ -23,12 +26,14 @@ type CronSchedule struct {
 }
 
 type CronPayload struct {
-	Kind    string `json:"kind"`
-	Message string `json:"message"`
-	Command string `json:"command,omitempty"`
-	Deliver bool   `json:"deliver"`
-	Channel string `json:"channel,omitempty"`
-	To      string `json:"to,omitempty"`
+	Kind    string         `json:"kind"`
+	Message string         `json:"message"`
+	Command string         `json:"command,omitempty"`
+	Tool    string         `json:"tool,omitempty"`
+	Args    map[string]any `json:"args,omitempty"`
+	Deliver bool           `json:"deliver"`
+	Channel string         `json:"channel,omitempty"`
+	To      string         `json:"to,omitempty"`
 }
 
 type CronJobState struct {
@@ -48,8 +53,14 @@ type CronJob struct {
 	CreatedAtMS    int64        `json:"createdAtMs"`
 	UpdatedAtMS    int64        `json:"updatedAtMs"`
 	DeleteAfterRun bool         `json:"deleteAfterRun"`
+	// Source is "config" for jobs from tools.cron.jobs, which are replaced
+	// whenever the config is applied.
+	Source string `json:"source,omitempty"`
 }
 
+// SourceConfig marks jobs defined in the config.
+const SourceConfig = "config"
+
 type CronStore struct {
 	Version int       `json:"version"`
 	Jobs    []CronJob `json:"jobs"`
@@ -265,6 +276,14 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 
 		// Use gronx to calculate next run time
 		now := time.UnixMilli(nowMS)
+		if schedule.TZ != "" {
+			loc, err := usertime.Load(schedule.TZ)
+			if err != nil {
+				log.Printf("[cron] unknown time zone '%s': %v", schedule.TZ, err)
+				return nil
+			}
+			now = now.In(loc)
+		}
 		nextTime, err := gronx.NextTickAfter(schedule.Expr, now, false)
 		if err != nil {
 			log.Printf("[cron] failed to compute next run for expr '%s': %v", schedule.Expr, err)
@@ -282,9 +301,17 @@ func (cs *CronService) recomputeNextRuns() {
 	now := time.Now().UnixMilli()
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled {
-			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		if !job.Enabled {
+			continue
 		}
+		// A one-time job that came due while the service was down runs
+		// late rather than never
+		if job.Schedule.Kind == "at" && job.Schedule.AtMS != nil && job.State.LastRunAtMS == nil &&
+			*job.Schedule.AtMS <= now {
+			job.State.NextRunAtMS = job.Schedule.AtMS
+			continue
+		}
+		job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
 	}
 }
 
@@ -386,6 +413,58 @@ func (cs *CronService) AddJob(
 	return &job, nil
 }
 
+// SetConfigJobs replaces the jobs from the config with jobs. Jobs keep their
+// run state across restarts as long as their name is unchanged.
+func (cs *CronService) SetConfigJobs(jobs []config.CronJobConfig) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	previous := make(map[string]CronJob)
+	kept := cs.store.Jobs[:0]
+	for _, job := range cs.store.Jobs {
+		if job.Source == SourceConfig {
+			previous[job.ID] = job
+			continue
+		}
+		kept = append(kept, job)
+	}
+	cs.store.Jobs = kept
+
+	now := time.Now().UnixMilli()
+	for _, jc := range jobs {
+		job := CronJob{
+			ID:       "config-" + jc.Name,
+			Name:     jc.Name,
+			Enabled:  !jc.Disabled,
+			Schedule: CronSchedule{Kind: "cron", Expr: jc.Schedule, TZ: jc.TZ},
+			Payload: CronPayload{
+				Kind:    "agent_turn",
+				Message: jc.Prompt,
+				Channel: jc.Channel,
+				To:      jc.To,
+			},
+			CreatedAtMS: now,
+			UpdatedAtMS: now,
+			Source:      SourceConfig,
+		}
+		if jc.Tool != "" {
+			job.Payload.Kind = "tool"
+			job.Payload.Tool = jc.Tool
+			job.Payload.Args = jc.Args
+		}
+		if old, ok := previous[job.ID]; ok {
+			job.CreatedAtMS = old.CreatedAtMS
+			job.State = old.State
+		}
+		job.State.NextRunAtMS = nil
+		if job.Enabled {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		}
+		cs.store.Jobs = append(cs.store.Jobs, job)
+	}
+	return cs.saveStoreUnsafe()
+}
+
 func (cs *CronService) UpdateJob(job *CronJob) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 func TestSaveStore_FilePermissions(t *testing.T) {
@@ -33,6 +36,98 @@ func TestSaveStore_FilePermissions(t *testing.T) {
 	}
 }
 
+func TestStartRunsMissedOneTimeJobs(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+	cs := NewCronService(storePath, nil)
+	atMS := time.Now().Add(time.Hour).UnixMilli()
+	job, err := cs.AddJob("later", CronSchedule{Kind: "at", AtMS: &atMS}, "hello", true, "telegram", "42")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	// The gateway was down when the job came due
+	past := time.Now().Add(-time.Minute).UnixMilli()
+	job.Schedule.AtMS = &past
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatalf("UpdateJob failed: %v", err)
+	}
+
+	ran := make(chan string, 1)
+	restarted := NewCronService(storePath, func(job *CronJob) (string, error) {
+		ran <- job.Payload.Message
+		return "ok", nil
+	})
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer restarted.Stop()
+
+	select {
+	case msg := <-ran:
+		if msg != "hello" {
+			t.Errorf("ran job with message %q", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("missed job did not run after restart")
+	}
+}
+
+func TestSetConfigJobs(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "cron", "jobs.json"), nil)
+	_, err := cs.AddJob("own", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", true, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	jobs := []config.CronJobConfig{
+		{
+			Name: "digest", Schedule: "0 7 * * *", TZ: "Asia/Tokyo",
+			Prompt: "News digest", Channel: "telegram", To: "42",
+		},
+		{
+			Name: "backup", Schedule: "0 3 * * *",
+			Tool: "exec", Args: map[string]any{"command": "ls"}, Channel: "slack", To: "C1",
+		},
+	}
+	if err := cs.SetConfigJobs(jobs); err != nil {
+		t.Fatalf("SetConfigJobs failed: %v", err)
+	}
+
+	byID := make(map[string]CronJob)
+	for _, job := range cs.ListJobs(true) {
+		byID[job.ID] = job
+	}
+	if len(byID) != 3 {
+		t.Fatalf("jobs = %v, want own job and two from config", byID)
+	}
+	digest := byID["config-digest"]
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	next := time.UnixMilli(*digest.State.NextRunAtMS).In(tokyo)
+	if next.Hour() != 7 || next.Minute() != 0 {
+		t.Errorf("digest runs next at %v, want 07:00 Tokyo time", next)
+	}
+	if backup := byID["config-backup"]; backup.Payload.Kind != "tool" || backup.Payload.Tool != "exec" {
+		t.Errorf("backup payload = %+v", backup.Payload)
+	}
+
+	// Run state survives, jobs removed from the config go away
+	lastRun := int64(12345)
+	digest.State.LastRunAtMS = &lastRun
+	cs.UpdateJob(&digest)
+	if err := cs.SetConfigJobs(jobs[:1]); err != nil {
+		t.Fatalf("SetConfigJobs failed: %v", err)
+	}
+	remaining := cs.ListJobs(true)
+	if len(remaining) != 2 {
+		t.Fatalf("jobs after removing backup = %d, want 2", len(remaining))
+	}
+	for _, job := range remaining {
+		if job.ID == "config-digest" && (job.State.LastRunAtMS == nil || *job.State.LastRunAtMS != lastRun) {
+			t.Errorf("digest lost its run state: %+v", job.State)
+		}
+	}
+}
+
 func int64Ptr(v int64) *int64 {
 	return &v
 }
@@ -126,12 +126,14 @@ func (s *Service) sendNotification(ev *events.DeviceEvent) {
 		return
 	}
 
-	msg := ev.FormatMessage()
-	msgBus.PublishOutbound(bus.OutboundMessage{
-		Channel: platform,
-		ChatID:  userID,
-		Content: msg,
-	})
+	if err := msgBus.Notify(s.ctx, platform, userID, ev.FormatMessage()); err != nil {
+		logger.ErrorCF("devices", "Device notification failed", map[string]any{
+			"kind":  ev.Kind,
+			"to":    platform,
+			"error": err.Error(),
+		})
+		return
+	}
 
 	logger.InfoCF("devices", "Device notification sent", map[string]any{
 		"kind":   ev.Kind,
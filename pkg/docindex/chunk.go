@@ -0,0 +1,56 @@
+package docindex
+
+import "strings"
+
+type chunk struct {
+	line int // first line, from 1
+	page int // PDF page, from 1; 0 for other files
+	text string
+}
+
+// splitChunks splits text into chunks of whole lines of at most size bytes,
+// starting a new chunk at markdown headings once the current one is half
+// full, so sections tend to stay together. Longer lines are split on their
+// own.
+func splitChunks(text string, size int) []chunk {
+	var chunks []chunk
+	var sb strings.Builder
+	start := 1
+	flush := func(next int) {
+		if t := strings.TrimSpace(sb.String()); t != "" {
+			chunks = append(chunks, chunk{line: start, text: t})
+		}
+		sb.Reset()
+		start = next
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		n := i + 1
+		heading := strings.HasPrefix(line, "#") && sb.Len() >= size/2
+		if sb.Len() > 0 && (heading || sb.Len()+len(line)+1 > size) {
+			flush(n)
+		}
+		for len(line) > size {
+			cut := size
+			for cut > 0 && !isCharStart(line[cut]) {
+				cut--
+			}
+			sb.WriteString(line[:cut])
+			flush(n)
+			line = line[cut:]
+		}
+		if sb.Len() == 0 {
+			start = n
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	flush(0)
+	return chunks
+}
+
+// isCharStart reports whether b starts a UTF-8 character.
+func isCharStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
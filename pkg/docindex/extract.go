@@ -0,0 +1,77 @@
+package docindex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/pdftext"
+)
+
+// errNoText marks files with nothing to index: empty, binary, or PDFs
+// that are scanned or password-protected.
+var errNoText = errors.New("no text to index")
+
+// document is the text of a file. PDFs also have it split by page.
+type document struct {
+	text  string
+	pages []string
+}
+
+// extractText returns the text of a document, reading PDFs with pdftext.
+func extractText(ctx context.Context, path string) (document, error) {
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return pdfDocument(ctx, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return document{}, err
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return document{}, fmt.Errorf("%w: not a text file", errNoText)
+	}
+	text := string(data)
+	if strings.TrimSpace(text) == "" {
+		return document{}, fmt.Errorf("%w: empty", errNoText)
+	}
+	return document{text: text}, nil
+}
+
+func pdfDocument(ctx context.Context, path string) (document, error) {
+	pages, err := pdftext.Extract(ctx, path)
+	if errors.Is(err, pdftext.ErrEncrypted) {
+		return document{}, fmt.Errorf("%w: %v", errNoText, err)
+	}
+	if err != nil {
+		return document{}, err
+	}
+	for i, page := range pages {
+		pages[i] = strings.ToValidUTF8(page, "�")
+	}
+	text := strings.Join(pages, "\n")
+	if strings.TrimSpace(text) == "" {
+		return document{}, fmt.Errorf("%w: no text in the PDF", errNoText)
+	}
+	return document{text: text, pages: pages}, nil
+}
+
+// chunks splits the document for indexing. PDF chunks stay within a page
+// and remember it.
+func (d document) chunks(size int) []chunk {
+	if d.pages == nil {
+		return splitChunks(d.text, size)
+	}
+	var chunks []chunk
+	for i, page := range d.pages {
+		for _, c := range splitChunks(page, size) {
+			c.page = i + 1
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,290 @@
+// Package docindex splits documents in a workspace into chunks, embeds them
+// into a vector store and keeps the index in step with the files.
+//
+// Each chunk is a record of kind "doc" whose source is the file's path
+// relative to the root, and whose meta holds the file's modification time
+// and the line the chunk starts at, plus the page for PDFs. A file is indexed again only when its
+// modification time changes.
+package docindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+const (
+	kindDoc = "doc"
+	// embedBatch is how many chunks go to the embedding model at once.
+	embedBatch = 32
+	// minChunkChars keeps chunks long enough to mean something.
+	minChunkChars = 200
+)
+
+// skipDirs hold the agent's own bookkeeping and installed dependencies.
+var skipDirs = map[string]bool{
+	"sessions":     true,
+	"state":        true,
+	"cron":         true,
+	"runs":         true,
+	"node_modules": true,
+}
+
+// EmbedFunc returns an embedding for each text.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Options select and split the documents to index.
+type Options struct {
+	Paths        []string // relative to the root; the whole root when empty
+	Extensions   []string // e.g. ".md"; case is ignored
+	ChunkChars   int
+	MaxFileBytes int64 // larger files are skipped; 0 for no limit
+}
+
+// Stats tells what an update did.
+type Stats struct {
+	Indexed int // files added or indexed again
+	Removed int // files gone from the index
+	Chunks  int // chunks embedded
+}
+
+// Hit is a chunk found by a search.
+type Hit struct {
+	Path  string // relative to the root
+	Line  int    // first line of the chunk, within its page for PDFs
+	Page  int    // PDF page, from 1; 0 for other files
+	Text  string
+	Score float64
+}
+
+// Indexer keeps the documents under a root indexed in a store.
+type Indexer struct {
+	root  string
+	opts  Options
+	exts  map[string]bool
+	store *vectorstore.Store
+	embed EmbedFunc
+
+	mu      sync.Mutex // serializes updates
+	updated time.Time
+}
+
+// New creates an indexer for the documents under root.
+func New(root string, store *vectorstore.Store, embed EmbedFunc, opts Options) *Indexer {
+	exts := make(map[string]bool, len(opts.Extensions))
+	for _, ext := range opts.Extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts[ext] = true
+	}
+	if opts.ChunkChars <= 0 {
+		opts.ChunkChars = 1500
+	}
+	opts.ChunkChars = max(opts.ChunkChars, minChunkChars)
+	return &Indexer{root: root, opts: opts, exts: exts, store: store, embed: embed}
+}
+
+// Refresh updates the index unless it was updated within maxAge.
+func (ix *Indexer) Refresh(ctx context.Context, maxAge time.Duration) (Stats, error) {
+	ix.mu.Lock()
+	fresh := time.Since(ix.updated) < maxAge
+	ix.mu.Unlock()
+	if fresh {
+		return Stats{}, nil
+	}
+	return ix.Update(ctx)
+}
+
+// Update indexes new and changed files and drops the chunks of files that
+// are gone. A file that can't be read or embedded is left as it was and
+// tried again on the next update.
+func (ix *Indexer) Update(ctx context.Context) (Stats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	indexed := make(map[string]string) // path -> modification time
+	ix.store.Each(func(r vectorstore.Record) {
+		if r.Kind == kindDoc {
+			indexed[r.Source] = r.Meta["mtime"]
+		}
+	})
+
+	var stats Stats
+	seen := make(map[string]bool)
+	var errs error
+	for _, file := range ix.files() {
+		seen[file.path] = true
+		if indexed[file.path] == file.mtime {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		n, err := ix.indexFile(ctx, file)
+		if err != nil {
+			if errors.Is(err, errNoText) {
+				// Drop what was indexed before the file lost its text
+				delete(seen, file.path)
+				logger.DebugCF("docindex", "Skipped document", map[string]any{
+					"path":  file.path,
+					"error": err.Error(),
+				})
+				continue
+			}
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", file.path, err))
+			continue
+		}
+		stats.Indexed++
+		stats.Chunks += n
+	}
+
+	removed := make(map[string]bool)
+	for path := range indexed {
+		if !seen[path] {
+			removed[path] = true
+		}
+	}
+	if len(removed) > 0 {
+		if _, err := ix.store.Delete(func(r vectorstore.Record) bool {
+			return r.Kind == kindDoc && removed[r.Source]
+		}); err != nil {
+			return stats, err
+		}
+		stats.Removed = len(removed)
+	}
+
+	ix.updated = time.Now()
+	return stats, errs
+}
+
+// Search returns up to k chunks closest in meaning to query that score at
+// least minScore, best first.
+func (ix *Indexer) Search(ctx context.Context, query string, k int, minScore float64) ([]Hit, error) {
+	if ix.store.Len() == 0 {
+		return nil, nil
+	}
+	vectors, err := ix.embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	matches := ix.store.Search(vectors[0], k, minScore, func(r vectorstore.Record) bool {
+		return r.Kind == kindDoc
+	})
+	hits := make([]Hit, len(matches))
+	for i, m := range matches {
+		line, _ := strconv.Atoi(m.Meta["line"])
+		page, _ := strconv.Atoi(m.Meta["page"])
+		hits[i] = Hit{Path: m.Source, Line: line, Page: page, Text: m.Text, Score: m.Score}
+	}
+	return hits, nil
+}
+
+type docFile struct {
+	path  string // relative, with forward slashes
+	abs   string
+	mtime string
+}
+
+// files lists the documents to index under the configured paths.
+func (ix *Indexer) files() []docFile {
+	roots := ix.opts.Paths
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	var files []docFile
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		start := filepath.Join(ix.root, filepath.Clean("/"+root))
+		filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != start && (skipDirs[name] || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || !ix.exts[strings.ToLower(filepath.Ext(name))] || seen[path] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			if ix.opts.MaxFileBytes > 0 && info.Size() > ix.opts.MaxFileBytes {
+				return nil
+			}
+			rel, err := filepath.Rel(ix.root, path)
+			if err != nil {
+				return nil
+			}
+			seen[path] = true
+			files = append(files, docFile{
+				path:  filepath.ToSlash(rel),
+				abs:   path,
+				mtime: strconv.FormatInt(info.ModTime().UnixNano(), 10),
+			})
+			return nil
+		})
+	}
+	return files
+}
+
+// indexFile replaces the chunks of a file with freshly embedded ones.
+func (ix *Indexer) indexFile(ctx context.Context, file docFile) (int, error) {
+	doc, err := extractText(ctx, file.abs)
+	if err != nil {
+		return 0, err
+	}
+	chunks := doc.chunks(ix.opts.ChunkChars)
+
+	records := make([]vectorstore.Record, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedBatch {
+		batch := chunks[start:min(start+embedBatch, len(chunks))]
+		inputs := make([]string, len(batch))
+		for i, c := range batch {
+			// The path helps match questions that name the file
+			inputs[i] = file.path + "\n\n" + c.text
+		}
+		vectors, err := ix.embed(ctx, inputs)
+		if err != nil {
+			return 0, err
+		}
+		for i, c := range batch {
+			meta := map[string]string{
+				"mtime": file.mtime,
+				"line":  strconv.Itoa(c.line),
+			}
+			if c.page > 0 {
+				meta["page"] = strconv.Itoa(c.page)
+			}
+			records = append(records, vectorstore.Record{
+				Kind:   kindDoc,
+				Source: file.path,
+				Text:   c.text,
+				Meta:   meta,
+				Vector: vectors[i],
+			})
+		}
+	}
+
+	if _, err := ix.store.Delete(func(r vectorstore.Record) bool {
+		return r.Kind == kindDoc && r.Source == file.path
+	}); err != nil {
+		return 0, err
+	}
+	return len(records), ix.store.Add(records...)
+}
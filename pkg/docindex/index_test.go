@@ -0,0 +1,167 @@
+package docindex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+// topicEmbed embeds texts by the topics they mention and counts calls.
+type topicEmbed struct {
+	texts int
+}
+
+func (e *topicEmbed) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		v := []float32{0, 0, 0.1}
+		if strings.Contains(text, "garden") {
+			v[0] = 1
+		}
+		if strings.Contains(text, "invoice") {
+			v[1] = 1
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func newTestIndexer(t *testing.T) (*Indexer, *topicEmbed, string) {
+	t.Helper()
+	root := t.TempDir()
+	store, err := vectorstore.Open(filepath.Join(root, "state", "docs.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &topicEmbed{}
+	ix := New(root, store, e.embed, Options{Extensions: []string{".md", "txt"}})
+	return ix, e, root
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexer_UpdateAndSearch(t *testing.T) {
+	ix, e, root := newTestIndexer(t)
+	ctx := context.Background()
+	writeFile(t, filepath.Join(root, "notes", "garden.md"), "# Plans\n\nPlant tomatoes in the garden in May.\n")
+	writeFile(t, filepath.Join(root, "bills.txt"), "The invoice for March is due on the 10th.\n")
+	writeFile(t, filepath.Join(root, "script.py"), "print('garden')\n")
+	writeFile(t, filepath.Join(root, "sessions", "chat.md"), "garden talk\n")
+	writeFile(t, filepath.Join(root, "empty.md"), "\n\n")
+
+	stats, err := ix.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if stats.Indexed != 2 || stats.Chunks != 2 {
+		t.Errorf("stats = %+v, want 2 files in 2 chunks", stats)
+	}
+
+	hits, err := ix.Search(ctx, "when do I plant in the garden?", 5, 0.5)
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Path != "notes/garden.md" || hits[0].Line != 1 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if !strings.Contains(hits[0].Text, "tomatoes") {
+		t.Errorf("hit text = %q", hits[0].Text)
+	}
+
+	// Nothing changed, nothing embedded
+	embedded := e.texts
+	if stats, _ := ix.Update(ctx); stats != (Stats{}) || e.texts != embedded {
+		t.Errorf("second update = %+v, embedded %d more", stats, e.texts-embedded)
+	}
+
+	// A changed file is indexed again and a deleted one dropped
+	garden := filepath.Join(root, "notes", "garden.md")
+	writeFile(t, garden, "The garden needs water.\n")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(garden, later, later)
+	os.Remove(filepath.Join(root, "bills.txt"))
+	stats, _ = ix.Update(ctx)
+	if stats.Indexed != 1 || stats.Removed != 1 {
+		t.Errorf("stats after changes = %+v", stats)
+	}
+	if ix.store.Len() != 1 {
+		t.Errorf("store has %d chunks, want 1", ix.store.Len())
+	}
+	hits, _ = ix.Search(ctx, "invoice", 5, 0.5)
+	if len(hits) != 0 {
+		t.Errorf("deleted file still found: %+v", hits)
+	}
+
+	// The index survives a restart
+	store, _ := vectorstore.Open(filepath.Join(root, "state", "docs.jsonl"))
+	reopened := New(root, store, e.embed, Options{Extensions: []string{".md", ".txt"}})
+	embedded = e.texts
+	if stats, _ := reopened.Update(ctx); stats != (Stats{}) || e.texts != embedded {
+		t.Errorf("update after reopening = %+v", stats)
+	}
+}
+
+func TestIndexer_Paths(t *testing.T) {
+	root := t.TempDir()
+	store, _ := vectorstore.Open(filepath.Join(root, "state", "docs.jsonl"))
+	e := &topicEmbed{}
+	ix := New(root, store, e.embed, Options{Paths: []string{"docs"}, Extensions: []string{".md"}})
+	writeFile(t, filepath.Join(root, "docs", "a.md"), "garden\n")
+	writeFile(t, filepath.Join(root, "other", "b.md"), "garden\n")
+
+	if stats, _ := ix.Update(context.Background()); stats.Indexed != 1 {
+		t.Errorf("indexed %d files, want only docs/a.md", stats.Indexed)
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	text := "# One\n" + strings.Repeat("alpha beta gamma\n", 10) + "# Two\nshort\n" + strings.Repeat("é", 700)
+	chunks := splitChunks(text, 300)
+	for _, c := range chunks {
+		if len(c.text) > 300 {
+			t.Errorf("chunk at line %d has %d bytes", c.line, len(c.text))
+		}
+	}
+	var two *chunk
+	for i := range chunks {
+		if strings.HasPrefix(chunks[i].text, "# Two") {
+			two = &chunks[i]
+		}
+	}
+	if two == nil || two.line != 12 {
+		t.Errorf("heading did not start a chunk at line 12: %+v", chunks)
+	}
+	last := chunks[len(chunks)-1]
+	if last.line != 14 || strings.ContainsRune(last.text, '�') {
+		t.Errorf("long line split badly: %+v", last)
+	}
+}
+
+func TestDocumentChunks_Pages(t *testing.T) {
+	doc := document{pages: []string{"cover", "", "first line\n" + strings.Repeat("invoice line\n", 40)}}
+	chunks := doc.chunks(200)
+	if len(chunks) < 3 || chunks[0].page != 1 || chunks[0].text != "cover" {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	for _, c := range chunks[1:] {
+		if c.page != 3 {
+			t.Errorf("chunk %q is on page %d, want 3", c.text, c.page)
+		}
+	}
+	if chunks[1].line != 1 {
+		t.Errorf("page 3 starts at line %d, want 1", chunks[1].line)
+	}
+}
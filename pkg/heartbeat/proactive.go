@@ -0,0 +1,196 @@
+package heartbeat
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usertime"
+)
+
+// maxChangedFiles caps how many changed workspace files a proactive
+// heartbeat lists; the rest are only counted.
+const maxChangedFiles = 20
+
+// skipDirs hold the agent's own bookkeeping, which changes on every turn and
+// is not worth reviewing.
+var skipDirs = map[string]bool{
+	"sessions": true,
+	"state":    true,
+	"cron":     true,
+	"runs":     true,
+	".venv":    true,
+}
+
+// skipFiles are written by the heartbeat and memory themselves, or already
+// part of the prompt.
+var skipFiles = map[string]bool{
+	"heartbeat.log": true,
+	"recall.jsonl":  true,
+	"HEARTBEAT.md":  true,
+}
+
+// quietHours is a daily window in which heartbeats don't run.
+type quietHours struct {
+	start, end int // minutes after midnight
+	loc        *time.Location
+}
+
+// parseQuietHours reads a window from "HH:MM" times. It returns nil when
+// both are empty.
+func parseQuietHours(start, end, tz string) (*quietHours, error) {
+	if start == "" && end == "" {
+		return nil, nil
+	}
+	q := &quietHours{loc: time.Local}
+	var err error
+	if q.start, err = parseClock(start); err != nil {
+		return nil, fmt.Errorf("quiet hours start: %w", err)
+	}
+	if q.end, err = parseClock(end); err != nil {
+		return nil, fmt.Errorf("quiet hours end: %w", err)
+	}
+	if tz != "" {
+		if q.loc, err = usertime.Load(tz); err != nil {
+			return nil, fmt.Errorf("quiet hours time zone: %w", err)
+		}
+	}
+	return q, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls in the window. A window whose end is
+// before its start runs past midnight, e.g. 22:00 to 07:00.
+func (q *quietHours) contains(t time.Time) bool {
+	if q == nil || q.start == q.end {
+		return false
+	}
+	t = t.In(q.loc)
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+// buildProactivePrompt asks the agent to review HEARTBEAT.md, upcoming tasks
+// and workspace files changed since the last run, and to decide whether any
+// of it is worth telling the user.
+func (hs *HeartbeatService) buildProactivePrompt(now time.Time) string {
+	hs.mu.Lock()
+	since := hs.lastRun
+	if since.IsZero() {
+		since = now.Add(-hs.interval)
+	}
+	hs.lastRun = now
+	upcoming := hs.upcoming
+	hs.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `# Heartbeat Review
+
+Current time: %s
+Last review: %s
+
+You are a proactive AI assistant. This is a scheduled heartbeat: nobody asked you anything.
+Review the tasks, upcoming schedule and workspace changes below, and act on anything due using available skills.
+Then decide whether the user needs to hear from you. Message them only about something new, time-sensitive,
+or that they asked to be told about; the user should not be disturbed for routine status.
+If nothing needs their attention, respond ONLY with: HEARTBEAT_OK
+Otherwise, respond with only the message for the user; it is sent to them as is.
+`, now.Format("2006-01-02 15:04:05"), since.Format("2006-01-02 15:04:05"))
+
+	if tasks := hs.readTasks(); tasks != "" {
+		sb.WriteString("\n## Tasks (HEARTBEAT.md)\n\n")
+		sb.WriteString(tasks)
+		sb.WriteString("\n")
+	}
+
+	if upcoming != nil {
+		if items := upcoming(); len(items) > 0 {
+			sb.WriteString("\n## Upcoming Scheduled Tasks and Reminders\n\n")
+			for _, item := range items {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+	}
+
+	changed, total := hs.changedFiles(since)
+	if total > 0 {
+		sb.WriteString("\n## Workspace Files Changed Since Last Review\n\n")
+		for _, f := range changed {
+			fmt.Fprintf(&sb, "- %s\n", f)
+		}
+		if total > len(changed) {
+			fmt.Fprintf(&sb, "- ... and %d more\n", total-len(changed))
+		}
+	}
+	return sb.String()
+}
+
+// readTasks returns the content of HEARTBEAT.md, creating the template when
+// it is missing.
+func (hs *HeartbeatService) readTasks() string {
+	data, err := os.ReadFile(filepath.Join(hs.workspace, "HEARTBEAT.md"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			hs.createDefaultHeartbeatTemplate()
+		} else {
+			hs.logError("Error reading HEARTBEAT.md: %v", err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// changedFiles lists workspace files modified after since, newest first and
+// at most maxChangedFiles of them, along with how many there are in all.
+func (hs *HeartbeatService) changedFiles(since time.Time) ([]string, int) {
+	type change struct {
+		path    string
+		modTime time.Time
+	}
+	var changes []change
+	filepath.WalkDir(hs.workspace, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != hs.workspace && (skipDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if skipFiles[name] || strings.HasPrefix(name, ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().After(since) {
+			return nil
+		}
+		rel, _ := filepath.Rel(hs.workspace, path)
+		changes = append(changes, change{filepath.ToSlash(rel), info.ModTime()})
+		return nil
+	})
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].modTime.After(changes[j].modTime)
+	})
+	var list []string
+	for _, c := range changes[:min(len(changes), maxChangedFiles)] {
+		list = append(list, fmt.Sprintf("%s (modified %s)", c.path, c.modTime.Format("2006-01-02 15:04")))
+	}
+	return list, len(changes)
+}
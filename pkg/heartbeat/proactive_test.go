@@ -0,0 +1,117 @@
+package heartbeat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestQuietHours(t *testing.T) {
+	q, err := parseQuietHours("22:00", "07:30", "UTC")
+	if err != nil {
+		t.Fatalf("parseQuietHours() error: %v", err)
+	}
+	tests := []struct {
+		clock string
+		quiet bool
+	}{
+		{"21:59", false},
+		{"22:00", true},
+		{"03:00", true},
+		{"07:29", true},
+		{"07:30", false},
+		{"12:00", false},
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse("2006-01-02 15:04", "2026-03-01 "+tt.clock)
+		if got := q.contains(at); got != tt.quiet {
+			t.Errorf("contains(%s) = %v, want %v", tt.clock, got, tt.quiet)
+		}
+	}
+
+	day, _ := parseQuietHours("12:00", "13:00", "")
+	if !day.contains(time.Date(2026, 3, 1, 12, 30, 0, 0, time.Local)) {
+		t.Error("12:30 should be inside 12:00-13:00")
+	}
+
+	if q, err := parseQuietHours("", "", ""); q != nil || err != nil {
+		t.Errorf("empty quiet hours = %v, %v", q, err)
+	}
+	if _, err := parseQuietHours("25:00", "07:00", ""); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+	if _, err := parseQuietHours("22:00", "07:00", "Nowhere/City"); err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
+
+func TestExecuteHeartbeat_QuietHours(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{})
+	hs.now = func() time.Time { return time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC) }
+	if err := hs.SetQuietHours("22:00", "07:00", "UTC"); err != nil {
+		t.Fatalf("SetQuietHours() error: %v", err)
+	}
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Test task"), 0o644)
+
+	called := false
+	hs.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+		called = true
+		return tools.SilentResult("Heartbeat OK")
+	})
+
+	hs.executeHeartbeat()
+	if called {
+		t.Error("heartbeat ran during quiet hours")
+	}
+
+	hs.now = func() time.Time { return time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC) }
+	hs.executeHeartbeat()
+	if !called {
+		t.Error("heartbeat did not run outside quiet hours")
+	}
+}
+
+func TestBuildProactivePrompt(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.SetProactive(true, func() []string {
+		return []string{"2026-03-01 09:00: standup (Remind me about the standup)"}
+	})
+
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(name string, modTime time.Time) {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("x"), 0o644)
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("notes/todo.md", time.Now())
+	write("notes/old.md", old)
+	write("sessions/telegram_1.json", time.Now())
+	write("heartbeat.log", time.Now())
+	write(".git/HEAD", time.Now())
+
+	prompt := hs.buildProactivePrompt(time.Now())
+	for _, want := range []string{"HEARTBEAT_OK", "standup", "notes/todo.md"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	for _, unwanted := range []string{"old.md", "sessions/", "heartbeat.log", ".git"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Errorf("prompt lists %q:\n%s", unwanted, prompt)
+		}
+	}
+
+	// The next review only looks at what changed since this one
+	prompt = hs.buildProactivePrompt(time.Now().Add(time.Minute))
+	if strings.Contains(prompt, "notes/todo.md") {
+		t.Errorf("unchanged file listed again:\n%s", prompt)
+	}
+}
@@ -7,6 +7,7 @@
 package heartbeat
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,7 @@ import (
 const (
 	minIntervalMinutes     = 5
 	defaultIntervalMinutes = 30
+	notifyTimeout          = 30 * time.Second
 )
 
 // HeartbeatHandler is the function type for handling heartbeat.
@@ -31,6 +33,10 @@ const (
 // channel and chatID are derived from the last active user channel.
 type HeartbeatHandler func(prompt, channel, chatID string) *tools.ToolResult
 
+// UpcomingFunc lists scheduled tasks and reminders that are coming up,
+// one line each, for a proactive heartbeat to review.
+type UpcomingFunc func() []string
+
 // HeartbeatService manages periodic heartbeat checks
 type HeartbeatService struct {
 	workspace string
@@ -41,6 +47,14 @@ type HeartbeatService struct {
 	enabled   bool
 	mu        sync.RWMutex
 	stopChan  chan struct{}
+
+	// Proactive heartbeats also review workspace changes and upcoming
+	// tasks, and run even without HEARTBEAT.md.
+	proactive bool
+	upcoming  UpcomingFunc
+	quiet     *quietHours
+	lastRun   time.Time
+	now       func() time.Time
 }
 
 // NewHeartbeatService creates a new heartbeat service
@@ -59,7 +73,32 @@ func NewHeartbeatService(workspace string, intervalMinutes int, enabled bool) *H
 		interval:  time.Duration(intervalMinutes) * time.Minute,
 		enabled:   enabled,
 		state:     state.NewManager(workspace),
+		now:       time.Now,
+	}
+}
+
+// SetProactive turns on proactive heartbeats, which review what changed in
+// the workspace and what is coming up, and tell the user only what needs
+// their attention. upcoming may be nil.
+func (hs *HeartbeatService) SetProactive(proactive bool, upcoming UpcomingFunc) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.proactive = proactive
+	hs.upcoming = upcoming
+}
+
+// SetQuietHours skips heartbeats from start to end ("HH:MM", wrapping past
+// midnight) in the time zone tz, or local time when tz is empty. Empty
+// start and end disable quiet hours.
+func (hs *HeartbeatService) SetQuietHours(start, end, tz string) error {
+	quiet, err := parseQuietHours(start, end, tz)
+	if err != nil {
+		return err
 	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.quiet = quiet
+	return nil
 }
 
 // SetBus sets the message bus for delivering heartbeat results.
@@ -147,6 +186,7 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	hs.mu.RLock()
 	enabled := hs.enabled
 	handler := hs.handler
+	proactive, quiet := hs.proactive, hs.quiet
 	if !hs.enabled || hs.stopChan == nil {
 		hs.mu.RUnlock()
 		return
@@ -157,9 +197,20 @@ func (hs *HeartbeatService) executeHeartbeat() {
 		return
 	}
 
+	now := hs.now()
+	if quiet.contains(now) {
+		logger.DebugC("heartbeat", "Skipping heartbeat in quiet hours")
+		return
+	}
+
 	logger.DebugC("heartbeat", "Executing heartbeat")
 
-	prompt := hs.buildPrompt()
+	var prompt string
+	if proactive {
+		prompt = hs.buildProactivePrompt(now)
+	} else {
+		prompt = hs.buildPrompt()
+	}
 	if prompt == "" {
 		logger.InfoC("heartbeat", "No heartbeat prompt (HEARTBEAT.md empty or missing)")
 		return
@@ -293,26 +344,23 @@ func (hs *HeartbeatService) sendResponse(response string) {
 		return
 	}
 
-	// Get last channel from state
-	lastChannel := hs.state.GetLastChannel()
-	if lastChannel == "" {
-		hs.logInfo("No last channel recorded, heartbeat result not sent")
-		return
+	// With no usable last channel the platform stays empty, and the channel
+	// manager sends the result to the push channel if one is enabled
+	platform, userID := hs.parseLastChannel(hs.state.GetLastChannel())
+	if platform == "" || userID == "" {
+		platform, userID = "", ""
 	}
 
-	platform, userID := hs.parseLastChannel(lastChannel)
-
-	// Skip internal channels that can't receive messages
-	if platform == "" || userID == "" {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := msgBus.Notify(ctx, platform, userID, response); err != nil {
+		hs.logInfo("Heartbeat result not sent: %v", err)
 		return
 	}
 
-	msgBus.PublishOutbound(bus.OutboundMessage{
-		Channel: platform,
-		ChatID:  userID,
-		Content: response,
-	})
-
+	if platform == "" {
+		platform = "push"
+	}
 	hs.logInfo("Heartbeat result sent to %s", platform)
 }
 
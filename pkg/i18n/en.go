@@ -0,0 +1,22 @@
+package i18n
+
+var en = map[Message]string{
+	Thinking:          "Thinking... 💭",
+	Welcome:           "Hello! I am PicoClaw 🦞 Send /help to see what I can do.",
+	RateLimited:       "You're sending messages too quickly. Please wait a moment and try again.",
+	ReplyWith:         "Reply with:",
+	ProcessingError:   "Error processing message: %v",
+	ContextCompressed: "Context window exceeded. Compressing history and retrying...",
+	MemoryOptimized:   "Memory threshold reached. Optimizing conversation history...",
+	ApprovalPrompt:    "Allow %s to run?\n%s",
+	ApprovalApprove:   "Approve",
+	ApprovalDeny:      "Deny",
+	ApprovalApproved:  "Approved",
+	ApprovalDenied:    "Denied",
+	ApprovalUnknown:   "No pending approval with that ID",
+	ApprovalStopped:   "Stopped: %s was not approved",
+	LoopRepeated:      "I stopped after calling %s with the same arguments %d times. How would you like me to proceed?",
+	LoopLimit:         "I stopped after %d rounds of tool calls without finishing. How would you like me to proceed?",
+	BudgetExceeded:    "I stopped because this request used up its budget of %s. How would you like me to proceed?",
+	DoneSoFar:         "Done so far:",
+}
@@ -0,0 +1,83 @@
+// Package i18n translates the fixed messages picoclaw shows users on its
+// own, such as placeholders, notices and approval prompts. Replies written
+// by the model are not touched.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultLanguage is used for unknown languages and for messages a bundle
+// lacks.
+const DefaultLanguage = "en"
+
+// Message identifies a translatable message. Some take fmt arguments,
+// noted next to them.
+type Message string
+
+const (
+	Thinking          Message = "thinking"
+	Welcome           Message = "welcome"
+	RateLimited       Message = "rate_limited"
+	ReplyWith         Message = "reply_with"
+	ProcessingError   Message = "processing_error" // error
+	ContextCompressed Message = "context_compressed"
+	MemoryOptimized   Message = "memory_optimized"
+	ApprovalPrompt    Message = "approval_prompt" // tool name, arguments
+	ApprovalApprove   Message = "approval_approve"
+	ApprovalDeny      Message = "approval_deny"
+	ApprovalApproved  Message = "approval_approved"
+	ApprovalDenied    Message = "approval_denied"
+	ApprovalUnknown   Message = "approval_unknown"
+	ApprovalStopped   Message = "approval_stopped" // tool name
+	LoopRepeated      Message = "loop_repeated"    // tool name, times
+	LoopLimit         Message = "loop_limit"       // rounds
+	BudgetExceeded    Message = "budget_exceeded"  // budget
+	DoneSoFar         Message = "done_so_far"
+)
+
+var bundles = map[string]map[Message]string{
+	"en": en,
+	"zh": zh,
+}
+
+// T returns msg in lang, formatted with args when given. Unsupported
+// languages and missing translations fall back to English.
+func T(lang string, msg Message, args ...any) string {
+	text, ok := bundles[Normalize(lang)][msg]
+	if !ok {
+		text, ok = en[msg]
+	}
+	if !ok {
+		return string(msg)
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Normalize maps a language tag such as "zh-CN", "zh_Hans" or "EN-us" to
+// its bundle name, or DefaultLanguage when there is none.
+func Normalize(lang string) string {
+	if Supported(lang) {
+		return primary(lang)
+	}
+	return DefaultLanguage
+}
+
+// Supported reports whether lang has a bundle of its own.
+func Supported(lang string) bool {
+	_, ok := bundles[primary(lang)]
+	return ok
+}
+
+// primary returns the lowercased primary subtag of a language tag.
+func primary(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
@@ -0,0 +1,48 @@
+package i18n
+
+import "testing"
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		lang string
+		msg  Message
+		args []any
+		want string
+	}{
+		{"en", ReplyWith, nil, "Reply with:"},
+		{"zh", ReplyWith, nil, "请回复："},
+		{"zh-CN", ApprovalPrompt, []any{"exec", "ls -la"}, "允许运行 exec 吗？\nls -la"},
+		{"ZH_hans", ApprovalDeny, nil, "拒绝"},
+		{"", ApprovalDeny, nil, "Deny"},
+		{"fr", ProcessingError, []any{"boom"}, "Error processing message: boom"},
+		{"en", Message("no_such_message"), nil, "no_such_message"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.lang, tt.msg, tt.args...); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.lang, tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestBundlesAreComplete(t *testing.T) {
+	for lang, bundle := range bundles {
+		for msg := range en {
+			if _, ok := bundle[msg]; !ok {
+				t.Errorf("%s bundle is missing %q", lang, msg)
+			}
+		}
+		for msg := range bundle {
+			if _, ok := en[msg]; !ok {
+				t.Errorf("%s bundle has %q, which English lacks", lang, msg)
+			}
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for lang, want := range map[string]bool{"en": true, "zh-TW": true, "fr": false, "": false} {
+		if got := Supported(lang); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
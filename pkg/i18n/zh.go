@@ -0,0 +1,22 @@
+package i18n
+
+var zh = map[Message]string{
+	Thinking:          "思考中... 💭",
+	Welcome:           "你好！我是 PicoClaw 🦞 发送 /help 查看我能做什么。",
+	RateLimited:       "你发送消息太快了，请稍等片刻再试。",
+	ReplyWith:         "请回复：",
+	ProcessingError:   "处理消息时出错：%v",
+	ContextCompressed: "上下文窗口已满，正在压缩历史记录并重试...",
+	MemoryOptimized:   "对话记录较长，正在整理对话历史...",
+	ApprovalPrompt:    "允许运行 %s 吗？\n%s",
+	ApprovalApprove:   "批准",
+	ApprovalDeny:      "拒绝",
+	ApprovalApproved:  "已批准",
+	ApprovalDenied:    "已拒绝",
+	ApprovalUnknown:   "没有该 ID 的待批准请求",
+	ApprovalStopped:   "已停止：%s 未获批准",
+	LoopRepeated:      "我已用相同参数调用 %s %d 次，因此停了下来。接下来你希望我怎么做？",
+	LoopLimit:         "我已进行了 %d 轮工具调用仍未完成，因此停了下来。接下来你希望我怎么做？",
+	BudgetExceeded:    "这个请求已用完 %s 的预算，因此我停了下来。接下来你希望我怎么做？",
+	DoneSoFar:         "目前已完成：",
+}
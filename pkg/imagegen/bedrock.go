@@ -0,0 +1,134 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// stabilityAspectRatios are the shapes Stability's newer models produce.
+var stabilityAspectRatios = []string{"1:1", "16:9", "21:9", "2:3", "3:2", "4:5", "5:4", "9:16", "9:21"}
+
+// BedrockGenerator runs an image model through the Bedrock runtime
+// InvokeModel API. Titan Image and Nova Canvas share one request format,
+// Stable Diffusion XL another, and the newer Stability models a third.
+type BedrockGenerator struct {
+	region    string
+	accessKey string
+	secretKey string
+	model     string
+	endpoint  string // overrides the regional endpoint, for tests
+	now       func() time.Time
+}
+
+func (g *BedrockGenerator) Generate(ctx context.Context, prompt, size string) ([]byte, error) {
+	width, height := 1024, 1024
+	if size != "" {
+		var err error
+		if width, height, err = parseSize(size); err != nil {
+			return nil, err
+		}
+	}
+
+	var payload map[string]any
+	switch {
+	case strings.Contains(g.model, "amazon."):
+		payload = map[string]any{
+			"taskType":          "TEXT_IMAGE",
+			"textToImageParams": map[string]any{"text": prompt},
+			"imageGenerationConfig": map[string]any{
+				"numberOfImages": 1,
+				"width":          width,
+				"height":         height,
+			},
+		}
+	case strings.Contains(g.model, "stable-diffusion-xl"):
+		payload = map[string]any{
+			"text_prompts": []map[string]any{{"text": prompt}},
+			"width":        width,
+			"height":       height,
+		}
+	default:
+		payload = map[string]any{
+			"prompt":        prompt,
+			"aspect_ratio":  aspectRatio(width, height),
+			"output_format": "png",
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := g.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", g.region)
+	}
+	path := "/model/" + g.model + "/invoke"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// Model IDs carry a colon, which must be sent encoded to match the signature
+	req.URL.RawPath = "/model/" + utils.AWSURIEncode(g.model) + "/invoke"
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	now := time.Now
+	if g.now != nil {
+		now = g.now
+	}
+	utils.SignAWSRequest(req, body, g.region, "bedrock", g.accessKey, g.secretKey, now().UTC())
+
+	var result struct {
+		Images        []string  `json:"images"`
+		Error         string    `json:"error"`
+		FinishReasons []*string `json:"finish_reasons"`
+		Artifacts     []struct {
+			Base64       string `json:"base64"`
+			FinishReason string `json:"finishReason"`
+		} `json:"artifacts"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("image generation failed: %s", result.Error)
+	}
+	if len(result.FinishReasons) > 0 && result.FinishReasons[0] != nil {
+		return nil, fmt.Errorf("image generation failed: %s", *result.FinishReasons[0])
+	}
+	switch {
+	case len(result.Images) > 0:
+		return base64.StdEncoding.DecodeString(result.Images[0])
+	case len(result.Artifacts) > 0:
+		if reason := result.Artifacts[0].FinishReason; reason != "" && reason != "SUCCESS" {
+			return nil, fmt.Errorf("image generation failed: %s", reason)
+		}
+		return base64.StdEncoding.DecodeString(result.Artifacts[0].Base64)
+	default:
+		return nil, fmt.Errorf("no image in response")
+	}
+}
+
+// aspectRatio picks the supported aspect ratio closest to width:height.
+func aspectRatio(width, height int) string {
+	want := math.Log(float64(width) / float64(height))
+	best, bestDiff := stabilityAspectRatios[0], math.Inf(1)
+	for _, ratio := range stabilityAspectRatios {
+		var w, h float64
+		fmt.Sscanf(ratio, "%g:%g", &w, &h)
+		if diff := math.Abs(math.Log(w/h) - want); diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best
+}
@@ -0,0 +1,80 @@
+// Package imagegen creates images from text prompts with a hosted model:
+// the OpenAI Images API or a compatible endpoint, or Titan Image and
+// Stability models on Amazon Bedrock.
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// maxImageBytes caps an image downloaded from a URL a provider returns.
+const maxImageBytes = 20 << 20
+
+var httpClient = &http.Client{Timeout: 180 * time.Second}
+
+// Generator creates images.
+type Generator interface {
+	// Generate returns the encoded image (usually PNG) for prompt. Size is
+	// "WIDTHxHEIGHT"; empty uses the provider's default
+	Generate(ctx context.Context, prompt, size string) ([]byte, error)
+}
+
+// NewGenerator builds the generator selected in cfg.Tools.Image.
+func NewGenerator(cfg *config.Config) (Generator, error) {
+	img := cfg.Tools.Image
+	switch img.Provider {
+	case "", "openai":
+		apiKey := firstNonEmpty(img.APIKey, cfg.Providers.OpenAI.APIKey)
+		if apiKey == "" {
+			return nil, fmt.Errorf("image provider openai requires an API key")
+		}
+		return &OpenAIGenerator{
+			apiKey:  apiKey,
+			apiBase: strings.TrimRight(firstNonEmpty(img.APIBase, "https://api.openai.com/v1"), "/"),
+			model:   firstNonEmpty(img.Model, "gpt-image-1"),
+		}, nil
+	case "bedrock":
+		if img.AccessKeyID == "" || img.SecretAccessKey == "" {
+			return nil, fmt.Errorf("image provider %q requires access_key_id and secret_access_key", img.Provider)
+		}
+		return &BedrockGenerator{
+			region:    firstNonEmpty(img.Region, "us-east-1"),
+			accessKey: img.AccessKeyID,
+			secretKey: img.SecretAccessKey,
+			model:     firstNonEmpty(img.Model, "amazon.titan-image-generator-v2:0"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown image provider %q", img.Provider)
+	}
+}
+
+// parseSize reads "WIDTHxHEIGHT".
+func parseSize(size string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if ok {
+		width, err = strconv.Atoi(w)
+		if err == nil {
+			height, err = strconv.Atoi(h)
+		}
+	}
+	if !ok || err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid image size %q, want WIDTHxHEIGHT such as 1024x1024", size)
+	}
+	return width, height, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestOpenAIGenerator(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/images/generations" || r.Header.Get("Authorization") != "Bearer sk" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]string{{"b64_json": base64.StdEncoding.EncodeToString(pngHeader)}},
+		})
+	}))
+	defer server.Close()
+
+	g := &OpenAIGenerator{apiKey: "sk", apiBase: server.URL + "/v1", model: "dall-e-3"}
+	data, err := g.Generate(context.Background(), "a red fox", "1024x1024")
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if string(data) != string(pngHeader) {
+		t.Errorf("image = %q", data)
+	}
+	if payload["prompt"] != "a red fox" || payload["size"] != "1024x1024" || payload["response_format"] != "b64_json" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestOpenAIGeneratorDownloadsURL(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image.png" {
+			w.Write(pngHeader)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]string{{"url": server.URL + "/image.png"}}})
+	}))
+	defer server.Close()
+
+	g := &OpenAIGenerator{apiKey: "sk", apiBase: server.URL, model: "gpt-image-1"}
+	data, err := g.Generate(context.Background(), "a red fox", "")
+	if err != nil || string(data) != string(pngHeader) {
+		t.Errorf("Generate() = %q, %v", data, err)
+	}
+}
+
+func TestBedrockGenerator(t *testing.T) {
+	tests := []struct {
+		model   string
+		check   func(payload map[string]any) bool
+		respond map[string]any
+	}{
+		{
+			model: "amazon.titan-image-generator-v2:0",
+			check: func(p map[string]any) bool {
+				config, _ := p["imageGenerationConfig"].(map[string]any)
+				return p["taskType"] == "TEXT_IMAGE" && config["width"] == 1280.0 && config["height"] == 720.0
+			},
+			respond: map[string]any{"images": []string{base64.StdEncoding.EncodeToString(pngHeader)}},
+		},
+		{
+			model: "stability.sd3-5-large-v1:0",
+			check: func(p map[string]any) bool {
+				return p["prompt"] == "a red fox" && p["aspect_ratio"] == "16:9"
+			},
+			respond: map[string]any{
+				"images":         []string{base64.StdEncoding.EncodeToString(pngHeader)},
+				"finish_reasons": []any{nil},
+			},
+		},
+		{
+			model: "stability.stable-diffusion-xl-v1",
+			check: func(p map[string]any) bool {
+				_, ok := p["text_prompts"]
+				return ok && p["width"] == 1280.0
+			},
+			respond: map[string]any{
+				"artifacts": []map[string]string{
+					{"base64": base64.StdEncoding.EncodeToString(pngHeader), "finishReason": "SUCCESS"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var auth, rawPath string
+			var payload map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				rawPath = r.URL.EscapedPath()
+				json.NewDecoder(r.Body).Decode(&payload)
+				json.NewEncoder(w).Encode(tt.respond)
+			}))
+			defer server.Close()
+
+			g := &BedrockGenerator{
+				region:    "us-west-2",
+				accessKey: "AKID",
+				secretKey: "secret",
+				model:     tt.model,
+				endpoint:  server.URL,
+				now:       func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) },
+			}
+			data, err := g.Generate(context.Background(), "a red fox", "1280x720")
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if string(data) != string(pngHeader) {
+				t.Errorf("image = %q", data)
+			}
+			if !tt.check(payload) {
+				t.Errorf("payload = %v", payload)
+			}
+			if want := "/model/" + strings.ReplaceAll(tt.model, ":", "%3A") + "/invoke"; rawPath != want {
+				t.Errorf("path = %q, want %q", rawPath, want)
+			}
+			if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260102/us-west-2/bedrock/aws4_request") {
+				t.Errorf("Authorization = %q", auth)
+			}
+		})
+	}
+}
+
+func TestBedrockGeneratorFiltered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"images": [], "finish_reasons": ["Filter reason: prompt"]}`))
+	}))
+	defer server.Close()
+
+	g := &BedrockGenerator{
+		region:    "us-east-1",
+		accessKey: "a",
+		secretKey: "b",
+		model:     "stability.sd3",
+		endpoint:  server.URL,
+	}
+	if _, err := g.Generate(context.Background(), "x", ""); err == nil || !strings.Contains(err.Error(), "Filter") {
+		t.Errorf("Generate() error = %v, want the filter reason", err)
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	for size, want := range map[[2]int]string{
+		{1024, 1024}: "1:1",
+		{1792, 1024}: "16:9",
+		{1024, 1536}: "2:3",
+		{720, 1280}:  "9:16",
+	} {
+		if got := aspectRatio(size[0], size[1]); got != want {
+			t.Errorf("aspectRatio(%v) = %s, want %s", size, got, want)
+		}
+	}
+}
+
+func TestNewGeneratorValidatesConfig(t *testing.T) {
+	for _, img := range []config.ImageToolsConfig{
+		{Provider: "openai"},
+		{Provider: "bedrock", AccessKeyID: "AKID"},
+		{Provider: "nope"},
+	} {
+		cfg := config.DefaultConfig()
+		cfg.Providers.OpenAI.APIKey = ""
+		cfg.Tools.Image = img
+		if _, err := NewGenerator(cfg); err == nil {
+			t.Errorf("NewGenerator(%+v) should fail", img)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Tools.Image = config.ImageToolsConfig{Provider: "bedrock", AccessKeyID: "AKID", SecretAccessKey: "s"}
+	g, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	if b := g.(*BedrockGenerator); b.region != "us-east-1" || b.model != "amazon.titan-image-generator-v2:0" {
+		t.Errorf("defaults = %+v", b)
+	}
+}
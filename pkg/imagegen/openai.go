@@ -0,0 +1,107 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIGenerator uses the OpenAI /images/generations endpoint.
+type OpenAIGenerator struct {
+	apiKey  string
+	apiBase string
+	model   string
+}
+
+func (g *OpenAIGenerator) Generate(ctx context.Context, prompt, size string) ([]byte, error) {
+	payload := map[string]any{
+		"model":  g.model,
+		"prompt": prompt,
+		"n":      1,
+	}
+	if size != "" {
+		payload["size"] = size
+	}
+	if strings.HasPrefix(g.model, "dall-e") {
+		// DALL·E returns a short-lived URL unless asked for the image itself;
+		// newer models always return it
+		payload["response_format"] = "b64_json"
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.apiBase+"/images/generations", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	var result struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+			URL     string `json:"url"`
+		} `json:"data"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("no image in response")
+	}
+	if image := result.Data[0]; image.B64JSON != "" {
+		return base64.StdEncoding.DecodeString(image.B64JSON)
+	}
+	return download(ctx, result.Data[0].URL)
+}
+
+// doJSON performs req and decodes its JSON response into v.
+func doJSON(req *http.Request, v any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	if url == "" {
+		return nil, fmt.Errorf("no image in response")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image is larger than %d MB", maxImageBytes>>20)
+	}
+	return data, nil
+}
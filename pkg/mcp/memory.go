@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	memoryURIPrefix = "picoclaw://memory/"
+
+	// maxDailyNotes caps how many daily notes are listed, newest first.
+	maxDailyNotes = 60
+)
+
+// MemoryResources serves the agent's memory: MEMORY.md and the daily notes
+// under the workspace's memory directory.
+type MemoryResources struct {
+	dir string
+}
+
+func NewMemoryResources(workspace string) *MemoryResources {
+	return &MemoryResources{dir: filepath.Join(workspace, "memory")}
+}
+
+func (m *MemoryResources) ListResources() []Resource {
+	resources := []Resource{}
+	if _, err := os.Stat(filepath.Join(m.dir, "MEMORY.md")); err == nil {
+		resources = append(resources, Resource{
+			URI:         memoryURIPrefix + "MEMORY.md",
+			Name:        "Long-term memory",
+			Description: "Facts and preferences the agent keeps across conversations",
+			MimeType:    "text/markdown",
+		})
+	}
+
+	var notes []string
+	filepath.WalkDir(m.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Dir(path) == m.dir || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		if rel, err := filepath.Rel(m.dir, path); err == nil {
+			notes = append(notes, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	// Notes are named memory/YYYYMM/YYYYMMDD.md, so names sort by date
+	sort.Sort(sort.Reverse(sort.StringSlice(notes)))
+	if len(notes) > maxDailyNotes {
+		notes = notes[:maxDailyNotes]
+	}
+	for _, note := range notes {
+		day := strings.TrimSuffix(filepath.Base(note), ".md")
+		if len(day) == 8 {
+			day = day[:4] + "-" + day[4:6] + "-" + day[6:]
+		}
+		resources = append(resources, Resource{
+			URI:      memoryURIPrefix + note,
+			Name:     "Daily notes " + day,
+			MimeType: "text/markdown",
+		})
+	}
+	return resources
+}
+
+func (m *MemoryResources) ReadResource(uri string) (string, error) {
+	name, ok := strings.CutPrefix(uri, memoryURIPrefix)
+	if !ok || !fs.ValidPath(name) || !strings.HasSuffix(name, ".md") {
+		return "", fmt.Errorf("unknown resource: %s", uri)
+	}
+	data, err := os.ReadFile(filepath.Join(m.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", fmt.Errorf("unknown resource: %s", uri)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,318 @@
+// Package mcp serves picoclaw's tools and memory over the Model Context
+// Protocol, so desktop clients can use them. Messages are newline-delimited
+// JSON-RPC 2.0 on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const (
+	// protocolVersion is the newest MCP revision the server speaks.
+	protocolVersion = "2025-06-18"
+
+	// Channel and ChatID identify MCP clients to the tool access rules in
+	// tools.access, e.g. a rule for "mcp".
+	Channel = "mcp"
+	ChatID  = "local"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Resource describes a document a client can read.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceProvider lists and reads the server's resources.
+type ResourceProvider interface {
+	ListResources() []Resource
+	ReadResource(uri string) (string, error)
+}
+
+// Server answers MCP requests with the tools of a registry and the
+// documents of a resource provider.
+type Server struct {
+	name, version string
+	tools         *tools.ToolRegistry
+	resources     ResourceProvider
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc // by request ID
+}
+
+func NewServer(name, version string, registry *tools.ToolRegistry, resources ResourceProvider) *Server {
+	return &Server{
+		name:      name,
+		version:   version,
+		tools:     registry,
+		resources: resources,
+		inFlight:  make(map[string]context.CancelFunc),
+	}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads requests from r until it is closed or ctx is done. Requests
+// are handled concurrently, so a slow tool doesn't hold up the others.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+
+	for {
+		var line []byte
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok = <-lines:
+		}
+		if !ok {
+			return scanner.Err()
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{Code: codeParseError, Message: "parse error: " + err.Error()}})
+			continue
+		}
+
+		if len(req.ID) == 0 {
+			s.handleNotification(req)
+			continue
+		}
+
+		reqCtx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.inFlight[string(req.ID)] = cancel
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.inFlight, string(req.ID))
+				s.mu.Unlock()
+				cancel()
+			}()
+
+			resp := response{JSONRPC: "2.0", ID: req.ID}
+			result, err := s.handle(reqCtx, req)
+			if err != nil {
+				rpcErr, ok := err.(*rpcError)
+				if !ok {
+					rpcErr = &rpcError{Code: codeInvalidRequest, Message: err.Error()}
+				}
+				resp.Error = rpcErr
+			} else {
+				resp.Result = result
+			}
+			s.write(resp)
+		}()
+	}
+}
+
+func (s *Server) write(resp response) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.enc.Encode(resp); err != nil {
+		logger.ErrorCF("mcp", "Failed to write response", map[string]any{"error": err.Error()})
+	}
+}
+
+func (s *Server) handleNotification(req request) {
+	if req.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(req.Params, &params) != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.inFlight[string(params.RequestID)]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params), nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return s.listTools(), nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "resources/list":
+		return map[string]any{"resources": s.listResources()}, nil
+	case "resources/read":
+		return s.readResource(req.Params)
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func (s *Server) initialize(params json.RawMessage) map[string]any {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	json.Unmarshal(params, &p)
+	logger.InfoCF("mcp", "Client connected", map[string]any{
+		"client":   p.ClientInfo.Name,
+		"version":  p.ClientInfo.Version,
+		"protocol": p.ProtocolVersion,
+	})
+
+	// Agree to an older revision the client asks for; the methods served
+	// here haven't changed since the first one
+	version := protocolVersion
+	if p.ProtocolVersion != "" && p.ProtocolVersion < protocolVersion {
+		version = p.ProtocolVersion
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools":     map[string]any{},
+			"resources": map[string]any{},
+		},
+		"serverInfo": map[string]any{"name": s.name, "version": s.version},
+	}
+}
+
+func (s *Server) listTools() map[string]any {
+	defs := s.tools.ToProviderDefsFor(Channel, ChatID)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Function.Name < defs[j].Function.Name })
+
+	list := make([]map[string]any, 0, len(defs))
+	for _, def := range defs {
+		schema := def.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		list = append(list, map[string]any{
+			"name":        def.Function.Name,
+			"description": def.Function.Description,
+			"inputSchema": schema,
+		})
+	}
+	return map[string]any{"tools": list}
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "tools/call needs a tool name"}
+	}
+	if _, ok := s.tools.Get(p.Name); !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", p.Name)}
+	}
+	if p.Arguments == nil {
+		p.Arguments = map[string]any{}
+	}
+
+	result := s.tools.ExecuteWithContext(ctx, p.Name, p.Arguments, Channel, ChatID, nil)
+	text := result.ForLLM
+	if text == "" && result.Err != nil {
+		text = result.Err.Error()
+	}
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": result.IsError,
+	}, nil
+}
+
+func (s *Server) listResources() []Resource {
+	if s.resources == nil {
+		return []Resource{}
+	}
+	return s.resources.ListResources()
+}
+
+func (s *Server) readResource(params json.RawMessage) (any, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "resources/read needs a uri"}
+	}
+	if s.resources == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown resource: " + p.URI}
+	}
+	text, err := s.resources.ReadResource(p.URI)
+	if err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return map[string]any{
+		"contents": []map[string]any{{"uri": p.URI, "mimeType": "text/markdown", "text": text}},
+	}, nil
+}
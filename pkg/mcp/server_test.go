@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// serve runs the server over the given request lines and returns its
+// responses by ID.
+func serve(t *testing.T, s *Server, lines ...string) map[string]response {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out); err != nil {
+		t.Fatalf("Serve() error: %v", err)
+	}
+
+	responses := make(map[string]response)
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp struct {
+			response
+			Result json.RawMessage `json:"result"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("bad response: %v", err)
+		}
+		resp.response.Result = resp.Result
+		responses[string(resp.ID)] = resp.response
+	}
+	return responses
+}
+
+func newTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadFileTool(workspace, true))
+	registry.Register(tools.NewWriteFileTool(workspace, true))
+	registry.SetAccessPolicy(tools.NewAccessPolicy(config.ToolAccessConfig{
+		Rules: map[string]config.ToolAccessRule{Channel: {Deny: config.FlexibleStringSlice{"write_file"}}},
+	}))
+	return NewServer("picoclaw", "test", registry, NewMemoryResources(workspace)), workspace
+}
+
+func TestServer_Tools(t *testing.T) {
+	s, workspace := newTestServer(t)
+	os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("hello"), 0o644)
+
+	responses := serve(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"notes.txt"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call",`+
+			`"params":{"name":"write_file","arguments":{"path":"x","content":"y"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"sampling/createMessage"}`,
+		`not json`,
+	)
+	if len(responses) != 7 {
+		t.Fatalf("got %d responses, want 7 (none for the notification): %v", len(responses), responses)
+	}
+
+	if got := string(responses["1"].Result.(json.RawMessage)); !strings.Contains(got, `"protocolVersion":"2024-11-05"`) {
+		t.Errorf("initialize = %s, want the client's protocol version", got)
+	}
+
+	var list struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	json.Unmarshal(responses["2"].Result.(json.RawMessage), &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != "read_file" || list.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools/list = %+v, want only read_file", list.Tools)
+	}
+
+	type callResult struct {
+		Content []struct{ Text string } `json:"content"`
+		IsError bool                    `json:"isError"`
+	}
+	var read, write callResult
+	json.Unmarshal(responses["3"].Result.(json.RawMessage), &read)
+	if read.IsError || len(read.Content) != 1 || read.Content[0].Text != "hello" {
+		t.Errorf("read_file = %+v", read)
+	}
+	json.Unmarshal(responses["4"].Result.(json.RawMessage), &write)
+	if !write.IsError || !strings.Contains(write.Content[0].Text, "not available") {
+		t.Errorf("denied write_file = %+v", write)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "x")); err == nil {
+		t.Error("denied tool wrote a file")
+	}
+
+	if e := responses["5"].Error; e == nil || e.Code != codeInvalidParams {
+		t.Errorf("unknown tool error = %+v", e)
+	}
+	if e := responses["6"].Error; e == nil || e.Code != codeMethodNotFound {
+		t.Errorf("unknown method error = %+v", e)
+	}
+	if e := responses["null"].Error; e == nil || e.Code != codeParseError {
+		t.Errorf("parse error = %+v", e)
+	}
+}
+
+func TestServer_MemoryResources(t *testing.T) {
+	s, workspace := newTestServer(t)
+	memory := filepath.Join(workspace, "memory")
+	os.MkdirAll(filepath.Join(memory, "202610"), 0o755)
+	os.WriteFile(filepath.Join(memory, "MEMORY.md"), []byte("Likes tea"), 0o644)
+	os.WriteFile(filepath.Join(memory, "202610", "20261001.md"), []byte("Older"), 0o644)
+	os.WriteFile(filepath.Join(memory, "202610", "20261015.md"), []byte("Met Sam"), 0o644)
+	os.WriteFile(filepath.Join(workspace, "secret.md"), []byte("no"), 0o644)
+
+	responses := serve(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"picoclaw://memory/202610/20261015.md"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"picoclaw://memory/../secret.md"}}`,
+	)
+
+	var list struct{ Resources []Resource }
+	json.Unmarshal(responses["1"].Result.(json.RawMessage), &list)
+	var uris []string
+	for _, r := range list.Resources {
+		uris = append(uris, r.URI)
+	}
+	want := "picoclaw://memory/MEMORY.md picoclaw://memory/202610/20261015.md picoclaw://memory/202610/20261001.md"
+	if strings.Join(uris, " ") != want {
+		t.Errorf("resources = %v", uris)
+	}
+	if list.Resources[1].Name != "Daily notes 2026-10-15" {
+		t.Errorf("name = %q", list.Resources[1].Name)
+	}
+
+	if got := string(responses["2"].Result.(json.RawMessage)); !strings.Contains(got, `"text":"Met Sam"`) {
+		t.Errorf("resources/read = %s", got)
+	}
+	if responses["3"].Error == nil {
+		t.Error("resources/read escaped the memory directory")
+	}
+}
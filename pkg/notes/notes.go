@@ -0,0 +1,309 @@
+// Package notes keeps the agent's notes as markdown files in a directory of
+// the workspace. Each note starts with a short frontmatter holding its
+// title, tags and times, so the files stay readable and editable by hand.
+package notes
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for a note ID with no file.
+var ErrNotFound = errors.New("note not found")
+
+var (
+	reSlug = regexp.MustCompile(`[^a-z0-9]+`)
+	reID   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// Note is one note. ID is the file name without .md.
+type Note struct {
+	ID      string
+	Title   string
+	Tags    []string
+	Created time.Time
+	Updated time.Time
+	Body    string
+}
+
+// HasTag reports whether the note is tagged tag, ignoring case.
+func (n *Note) HasTag(tag string) bool {
+	for _, t := range n.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text is the note as one string, for searching and embedding.
+func (n *Note) Text() string {
+	text := n.Title
+	if len(n.Tags) > 0 {
+		text += " [" + strings.Join(n.Tags, ", ") + "]"
+	}
+	return text + "\n" + n.Body
+}
+
+// Store reads and writes the notes in a directory.
+type Store struct {
+	dir string
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// NewStore returns the store for dir, which is created on the first write.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, now: time.Now}
+}
+
+// Dir returns the directory notes are kept in.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path returns the file of the note with id.
+func (s *Store) Path(id string) string {
+	return filepath.Join(s.dir, id+".md")
+}
+
+// Create writes a new note, naming it after its title.
+func (s *Store) Create(title, body string, tags []string) (*Note, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("a note needs a title")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base := strings.Trim(reSlug.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(base) > 60 {
+		base = strings.TrimRight(base[:60], "-")
+	}
+	if base == "" {
+		base = "note"
+	}
+	id := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(s.Path(id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	now := s.now()
+	n := &Note{ID: id, Title: title, Tags: cleanTags(tags), Created: now, Updated: now, Body: body}
+	return n, s.write(n)
+}
+
+// Get reads the note with id.
+func (s *Store) Get(id string) (*Note, error) {
+	if !reID.MatchString(id) {
+		return nil, fmt.Errorf("invalid note id %q", id)
+	}
+	data, err := os.ReadFile(s.Path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(id, string(data)), nil
+}
+
+// Update saves n over its file and sets its update time.
+func (s *Store) Update(n *Note) error {
+	if !reID.MatchString(n.ID) {
+		return fmt.Errorf("invalid note id %q", n.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n.Tags = cleanTags(n.Tags)
+	n.Updated = s.now()
+	return s.write(n)
+}
+
+// List returns every note, most recently updated first.
+func (s *Store) List() ([]*Note, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var notes []*Note
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".md")
+		if e.IsDir() || !ok || !reID.MatchString(id) {
+			continue
+		}
+		n, err := s.Get(id)
+		if err != nil {
+			continue
+		}
+		notes = append(notes, n)
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Updated.After(notes[j].Updated) })
+	return notes, nil
+}
+
+// Search returns the notes that contain every word of query and carry every
+// tag in tags, best match first. Title and tag hits count more than words
+// in the body. An empty query matches every note with the tags.
+func (s *Store) Search(query string, tags []string, limit int) ([]*Note, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(query))
+
+	type scored struct {
+		note  *Note
+		score int
+	}
+	var hits []scored
+	for _, n := range all {
+		tagged := true
+		for _, tag := range tags {
+			if !n.HasTag(strings.TrimPrefix(strings.TrimSpace(tag), "#")) {
+				tagged = false
+				break
+			}
+		}
+		if !tagged {
+			continue
+		}
+		score, ok := matchScore(n, words)
+		if ok {
+			hits = append(hits, scored{n, score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	var notes []*Note
+	for _, h := range hits {
+		if limit > 0 && len(notes) == limit {
+			break
+		}
+		notes = append(notes, h.note)
+	}
+	return notes, nil
+}
+
+func matchScore(n *Note, words []string) (int, bool) {
+	title := strings.ToLower(n.Title)
+	body := strings.ToLower(n.Body)
+	tags := strings.ToLower(strings.Join(n.Tags, " "))
+	score := 0
+	for _, w := range words {
+		hit := strings.Count(body, w)
+		if hit > 5 {
+			hit = 5
+		}
+		if strings.Contains(title, w) {
+			hit += 5
+		}
+		if strings.Contains(tags, w) {
+			hit += 3
+		}
+		if hit == 0 {
+			return 0, false
+		}
+		score += hit
+	}
+	return score, true
+}
+
+// write saves n; callers hold mu.
+func (s *Store) write(n *Note) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "title: %s\n", oneLine(n.Title))
+	if len(n.Tags) > 0 {
+		fmt.Fprintf(&sb, "tags: %s\n", strings.Join(n.Tags, ", "))
+	}
+	fmt.Fprintf(&sb, "created: %s\n", n.Created.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "updated: %s\n", n.Updated.Format(time.RFC3339))
+	sb.WriteString("---\n\n")
+	sb.WriteString(strings.TrimSpace(n.Body))
+	sb.WriteString("\n")
+
+	tmp := s.Path(n.ID) + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path(n.ID))
+}
+
+// parse reads a note file. Notes written by hand may have no frontmatter;
+// they take their title from the first heading, or else the ID.
+func parse(id, content string) *Note {
+	n := &Note{ID: id}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		if meta, body, ok := strings.Cut(rest, "\n---\n"); ok {
+			content = body
+			scanner := bufio.NewScanner(strings.NewReader(meta))
+			for scanner.Scan() {
+				key, value, ok := strings.Cut(scanner.Text(), ":")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(strings.TrimSpace(value), `"'`)
+				switch strings.TrimSpace(key) {
+				case "title":
+					n.Title = value
+				case "tags":
+					n.Tags = cleanTags(strings.Split(strings.Trim(value, "[]"), ","))
+				case "created":
+					n.Created, _ = time.Parse(time.RFC3339, value)
+				case "updated":
+					n.Updated, _ = time.Parse(time.RFC3339, value)
+				}
+			}
+		}
+	}
+	n.Body = strings.TrimSpace(content)
+
+	if n.Title == "" {
+		n.Title = id
+		if heading, ok := strings.CutPrefix(n.Body, "# "); ok {
+			n.Title, _, _ = strings.Cut(heading, "\n")
+		}
+	}
+	if n.Updated.IsZero() {
+		n.Updated = n.Created
+	}
+	return n
+}
+
+// cleanTags trims tags and drops empty and repeated ones.
+func cleanTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.Trim(strings.TrimSpace(tag), `"'`), "#")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,115 @@
+package notes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_CreateGetUpdate(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "notes"))
+	now := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	n, err := store.Create("Trip to Lisbon!", "Flights booked.", []string{"travel", " #Family", "travel", ""})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n.ID != "trip-to-lisbon" || strings.Join(n.Tags, ",") != "travel,Family" {
+		t.Errorf("note = %+v", n)
+	}
+	again, _ := store.Create("Trip to Lisbon", "", nil)
+	if again.ID != "trip-to-lisbon-2" {
+		t.Errorf("second note id = %q", again.ID)
+	}
+	if _, err := store.Create("  ", "x", nil); err == nil {
+		t.Error("created a note without a title")
+	}
+
+	got, err := store.Get("trip-to-lisbon")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != "Trip to Lisbon!" || got.Body != "Flights booked." || !got.HasTag("family") ||
+		!got.Created.Equal(now) {
+		t.Errorf("read back %+v", got)
+	}
+
+	now = now.Add(time.Hour)
+	got.Body += "\n\nHotel: Alfama."
+	if err := store.Update(got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, _ = store.Get("trip-to-lisbon")
+	if !strings.HasSuffix(got.Body, "Hotel: Alfama.") || !got.Updated.Equal(now) || got.Created.Equal(now) {
+		t.Errorf("updated note = %+v", got)
+	}
+
+	for _, id := range []string{"missing", "../secret", ""} {
+		if _, err := store.Get(id); err == nil {
+			t.Errorf("Get(%q) succeeded", id)
+		}
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_HandWrittenNote(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "recipes.md"), []byte("# Recipes\n\nPancakes: flour, eggs, milk.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "Not A Note.md"), []byte("ignored"), 0o644)
+
+	list, err := NewStore(dir).List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "recipes" || list[0].Title != "Recipes" {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestStore_Search(t *testing.T) {
+	store := NewStore(t.TempDir())
+	store.Create("Garden", "Plant tomatoes in May. Water the basil.", []string{"home"})
+	store.Create("Basil pesto", "Blend basil, pine nuts and parmesan.", []string{"recipes"})
+	store.Create("Car", "Service due in June.", []string{"home"})
+
+	tests := []struct {
+		query string
+		tags  []string
+		want  string
+	}{
+		{"basil", nil, "basil-pesto,garden"},
+		{"basil tomatoes", nil, "garden"},
+		{"", []string{"home"}, "car,garden"},
+		{"basil", []string{"#HOME"}, "garden"},
+		{"recipes", nil, "basil-pesto"},
+		{"snow", nil, ""},
+	}
+	for _, tt := range tests {
+		found, err := store.Search(tt.query, tt.tags, 0)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		var ids []string
+		for _, n := range found {
+			ids = append(ids, n.ID)
+		}
+		// Notes with equal scores come most recently updated first
+		if tt.query == "" {
+			sort.Strings(ids)
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("Search(%q, %v) = %s, want %s", tt.query, tt.tags, got, tt.want)
+		}
+	}
+
+	if found, _ := store.Search("", nil, 2); len(found) != 2 {
+		t.Errorf("limit 2 found %d notes", len(found))
+	}
+}
@@ -0,0 +1,112 @@
+// Package pdftext extracts the text of PDF files, page by page.
+//
+// It uses pdftotext from poppler-utils when that is installed, which copes
+// with more fonts and keeps the layout of tables and columns, and a
+// built-in reader otherwise.
+package pdftext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// timeout bounds how long pdftotext may take for one file.
+const timeout = 60 * time.Second
+
+// popplerBin is the pdftotext binary looked up in PATH.
+var popplerBin = "pdftotext"
+
+// ErrEncrypted is returned for password-protected PDFs.
+var ErrEncrypted = errors.New("the PDF is password-protected")
+
+// Extract returns the text of each page of the PDF at path. Pages without
+// text, such as scans, are "".
+func Extract(ctx context.Context, path string) ([]string, error) {
+	if bin, err := exec.LookPath(popplerBin); err == nil {
+		return popplerPages(ctx, bin, path)
+	}
+	return readerPages(path)
+}
+
+// popplerPages runs pdftotext, which ends every page with a form feed.
+func popplerPages(ctx context.Context, bin, path string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-layout", "-enc", "UTF-8", path, "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "Incorrect password") {
+			return nil, ErrEncrypted
+		}
+		return nil, fmt.Errorf("pdftotext: %v: %s", err, msg)
+	}
+	pages := strings.Split(string(out), "\f")
+	if len(pages) > 1 && strings.TrimSpace(pages[len(pages)-1]) == "" {
+		pages = pages[:len(pages)-1]
+	}
+	for i, page := range pages {
+		pages[i] = strings.TrimRight(page, " \n")
+	}
+	return pages, nil
+}
+
+// readerPages reads the PDF with the built-in reader, which panics on some
+// malformed files.
+func readerPages(path string) (pages []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		if errors.Is(err, pdf.ErrInvalidPassword) {
+			return nil, ErrEncrypted
+		}
+		return nil, fmt.Errorf("reading PDF: %w", err)
+	}
+	defer f.Close()
+
+	for i := 1; i <= r.NumPage(); i++ {
+		pages = append(pages, pageText(r.Page(i).Content().Text))
+	}
+	return pages, nil
+}
+
+// pageText lays out the glyphs of a page in the order they were drawn,
+// breaking lines where the baseline moves and adding spaces at gaps wider
+// than a fraction of the font size.
+func pageText(glyphs []pdf.Text) string {
+	var sb strings.Builder
+	for i, g := range glyphs {
+		if i > 0 {
+			prev := glyphs[i-1]
+			size := math.Max(prev.FontSize, 1)
+			switch {
+			case math.Abs(g.Y-prev.Y) > size/2:
+				sb.WriteByte('\n')
+			case g.X-(prev.X+prev.W) > size/5:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(g.S)
+	}
+
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
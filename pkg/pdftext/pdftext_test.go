@@ -0,0 +1,89 @@
+package pdftext
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestPDF writes a PDF with a page for each entry of pages, drawing
+// each line of text below the one before.
+func writeTestPDF(t *testing.T, path string, pages ...[]string) {
+	t.Helper()
+	var objects []string
+	add := func(obj string) int {
+		objects = append(objects, obj)
+		return len(objects)
+	}
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	add("") // the page tree, once its kids are known
+	font := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	var kids []string
+	for _, lines := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td")
+		for i, line := range lines {
+			if i > 0 {
+				content.WriteString(" 0 -20 Td")
+			}
+			fmt.Fprintf(&content, " (%s) Tj", line)
+		}
+		content.WriteString(" ET")
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] "+
+			"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", font, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	// Use the built-in reader whether or not poppler is installed
+	popplerBin = "pdftotext-not-installed"
+	t.Cleanup(func() { popplerBin = "pdftotext" })
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	writeTestPDF(t, path, []string{"Quarterly report", "Revenue grew 12%"}, nil, []string{"Appendix"})
+
+	pages, err := Extract(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Quarterly report\nRevenue grew 12%", "", "Appendix"}
+	if len(pages) != len(want) {
+		t.Fatalf("pages = %q, want %q", pages, want)
+	}
+	for i := range want {
+		if pages[i] != want[i] {
+			t.Errorf("page %d = %q, want %q", i+1, pages[i], want[i])
+		}
+	}
+
+	broken := filepath.Join(dir, "broken.pdf")
+	os.WriteFile(broken, []byte("%PDF-1.4\nnot really a pdf"), 0o644)
+	if _, err := Extract(context.Background(), broken); err == nil {
+		t.Error("broken PDF extracted without an error")
+	}
+}
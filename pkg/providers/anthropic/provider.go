@@ -119,6 +119,8 @@ func buildParams(
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if len(msg.Images) > 0 {
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(imageBlocks(msg)...))
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -172,6 +174,24 @@ func buildParams(
 	return params, nil
 }
 
+// imageBlocks converts a user message's data URL images into base64 image
+// blocks followed by its text, the order Anthropic recommends.
+func imageBlocks(msg Message) []anthropic.ContentBlockParamUnion {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(msg.Images)+1)
+	for _, img := range msg.Images {
+		header, data, ok := strings.Cut(strings.TrimPrefix(img, "data:"), ",")
+		mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+		if !ok || !isBase64 {
+			continue
+		}
+		blocks = append(blocks, anthropic.NewImageBlockBase64(mediaType, data))
+	}
+	if msg.Content != "" || len(blocks) == 0 {
+		blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+	}
+	return blocks
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -32,6 +32,34 @@ func TestBuildParams_BasicMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ImageMessage(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "What's this?", Images: []string{"data:image/jpeg;base64,/9j/AA=="}},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Messages) != 1 {
+		t.Fatalf("len(Messages) = %d, want 1", len(params.Messages))
+	}
+
+	blocks := params.Messages[0].Content
+	if len(blocks) != 2 {
+		t.Fatalf("len(Content) = %d, want 2", len(blocks))
+	}
+	image := blocks[0].OfImage
+	if image == nil || image.Source.OfBase64 == nil {
+		t.Fatalf("Content[0] is not a base64 image block")
+	}
+	if image.Source.OfBase64.MediaType != "image/jpeg" || image.Source.OfBase64.Data != "/9j/AA==" {
+		t.Errorf("image source = %q %q", image.Source.OfBase64.MediaType, image.Source.OfBase64.Data)
+	}
+	if blocks[1].OfText == nil || blocks[1].OfText.Text != "What's this?" {
+		t.Errorf("Content[1] is not the message text")
+	}
+}
+
 func TestBuildParams_SystemMessage(t *testing.T) {
 	messages := []Message{
 		{Role: "system", Content: "You are helpful"},
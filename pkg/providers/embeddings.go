@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// EmbeddingProvider turns texts into vectors for similarity search.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, error)
+}
+
+// CreateEmbedderFromConfig creates an embedding provider for a model_list
+// entry. Only OpenAI-compatible HTTP protocols serve embeddings.
+// Returns the provider and the model ID (without protocol prefix).
+func CreateEmbedderFromConfig(cfg *config.ModelConfig) (EmbeddingProvider, string, error) {
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	embedder, ok := provider.(EmbeddingProvider)
+	if !ok {
+		return nil, "", fmt.Errorf("model %q does not support embeddings", cfg.Model)
+	}
+	return embedder, modelID, nil
+}
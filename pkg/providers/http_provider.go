@@ -41,3 +41,7 @@ func (p *HTTPProvider) Chat(
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
+
+func (p *HTTPProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return p.delegate.Embed(ctx, texts, model)
+}
@@ -77,7 +77,7 @@ func (p *Provider) Chat(
 
 	requestBody := map[string]any{
 		"model":    model,
-		"messages": messages,
+		"messages": serializeMessages(messages),
 	}
 
 	if len(tools) > 0 {
@@ -144,6 +144,67 @@ func (p *Provider) Chat(
 	return parseResponse(body)
 }
 
+// Embed returns an embedding for each text from the /embeddings endpoint,
+// in the order of texts.
+func (p *Provider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+
+	jsonData, err := json.Marshal(map[string]any{
+		"model": normalizeModel(model, p.apiBase),
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/embeddings", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	var apiResponse struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(apiResponse.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(apiResponse.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range apiResponse.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
 func parseResponse(body []byte) (*LLMResponse, error) {
 	var apiResponse struct {
 		Choices []struct {
@@ -228,6 +289,42 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 	}, nil
 }
 
+// contentPart is one entry of a multimodal message's content array.
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// serializeMessages sends messages with images as content arrays of text
+// and image_url parts; all others keep plain string content.
+func serializeMessages(messages []Message) []any {
+	out := make([]any, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Images) == 0 {
+			out = append(out, msg)
+			continue
+		}
+
+		parts := make([]contentPart, 0, len(msg.Images)+1)
+		if msg.Content != "" {
+			parts = append(parts, contentPart{Type: "text", Text: msg.Content})
+		}
+		for _, img := range msg.Images {
+			parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: img}})
+		}
+		out = append(out, map[string]any{
+			"role":    msg.Role,
+			"content": parts,
+		})
+	}
+	return out
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
@@ -273,6 +273,66 @@ func TestProviderChat_AcceptsNumericOptionTypes(t *testing.T) {
 	}
 }
 
+func TestProviderChat_SendsImagesAsContentParts(t *testing.T) {
+	var requestBody struct {
+		Messages []map[string]any `json:"messages"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{
+					"message":       map[string]any{"content": "a cat"},
+					"finish_reason": "stop",
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(
+		t.Context(),
+		[]Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "what's in this photo?", Images: []string{"data:image/png;base64,AAAA"}},
+		},
+		nil,
+		"gpt-4o",
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if len(requestBody.Messages) != 2 {
+		t.Fatalf("len(messages) = %d, want 2", len(requestBody.Messages))
+	}
+	if requestBody.Messages[0]["content"] != "be brief" {
+		t.Fatalf("system content = %v, want plain string", requestBody.Messages[0]["content"])
+	}
+
+	parts, ok := requestBody.Messages[1]["content"].([]any)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("user content = %v, want text and image parts", requestBody.Messages[1]["content"])
+	}
+	text := parts[0].(map[string]any)
+	if text["type"] != "text" || text["text"] != "what's in this photo?" {
+		t.Fatalf("parts[0] = %v", text)
+	}
+	image := parts[1].(map[string]any)
+	imageURL, _ := image["image_url"].(map[string]any)
+	if image["type"] != "image_url" || imageURL["url"] != "data:image/png;base64,AAAA" {
+		t.Fatalf("parts[1] = %v", image)
+	}
+}
+
 func TestNormalizeModel_UsesAPIBase(t *testing.T) {
 	if got := normalizeModel("deepseek/deepseek-chat", "https://api.deepseek.com/v1"); got != "deepseek-chat" {
 		t.Fatalf("normalizeModel(deepseek) = %q, want %q", got, "deepseek-chat")
@@ -281,3 +341,38 @@ func TestNormalizeModel_UsesAPIBase(t *testing.T) {
 		t.Fatalf("normalizeModel(openrouter) = %q, want %q", got, "openrouter/auto")
 	}
 }
+
+func TestProviderEmbed(t *testing.T) {
+	var requestBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		// Out of order, as the API allows
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{
+				{"index": 1, "embedding": []float32{0, 1}},
+				{"index": 0, "embedding": []float32{1, 0}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	vectors, err := p.Embed(t.Context(), []string{"a", "b"}, "ollama/nomic-embed-text")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if requestBody["model"] != "nomic-embed-text" {
+		t.Errorf("model = %v, want prefix stripped", requestBody["model"])
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("vectors = %v", vectors)
+	}
+
+	if _, err := p.Embed(t.Context(), []string{"a"}, "m"); err == nil {
+		t.Error("expected an error when the count of embeddings doesn't match")
+	}
+}
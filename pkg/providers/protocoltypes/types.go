@@ -42,6 +42,7 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Images     []string   `json:"-"` // data URLs sent alongside Content by multimodal providers
 }
 
 type ToolDefinition struct {
@@ -14,6 +14,8 @@ type RouteInput struct {
 	ParentPeer *RoutePeer
 	GuildID    string
 	TeamID     string
+	ThreadID   string
+	SenderID   string
 }
 
 // ResolvedRoute is the result of agent routing.
@@ -49,6 +51,11 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 		dmScope = DMScopeMain
 	}
 	identityLinks := r.cfg.Session.IdentityLinks
+	groupScope := GroupScope(r.cfg.Session.GroupScope)
+	threadID := input.ThreadID
+	if r.cfg.Session.SharedThreads {
+		threadID = ""
+	}
 
 	bindings := r.filterBindings(channel, accountID)
 
@@ -61,6 +68,9 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 			Peer:          peer,
 			DMScope:       dmScope,
 			IdentityLinks: identityLinks,
+			GroupScope:    groupScope,
+			SenderID:      input.SenderID,
+			ThreadID:      threadID,
 		}))
 		mainSessionKey := strings.ToLower(BuildAgentMainSessionKey(resolvedAgentID))
 		return ResolvedRoute{
@@ -295,3 +295,52 @@ func TestResolveRoute_NoDefaultUsesFirst(t *testing.T) {
 		t.Errorf("AgentID = %q, want 'alpha' (first in list)", route.AgentID)
 	}
 }
+
+func TestResolveRoute_ThreadSessions(t *testing.T) {
+	cfg := testConfig(nil, nil)
+	r := NewRouteResolver(cfg)
+	input := RouteInput{
+		Channel:  "telegram",
+		Peer:     &RoutePeer{Kind: "group", ID: "-100123"},
+		ThreadID: "42",
+	}
+
+	if got, want := r.ResolveRoute(input).SessionKey, "agent:main:telegram:group:-100123:thread:42"; got != want {
+		t.Errorf("SessionKey = %q, want %q", got, want)
+	}
+
+	cfg.Session.SharedThreads = true
+	if got, want := r.ResolveRoute(input).SessionKey, "agent:main:telegram:group:-100123"; got != want {
+		t.Errorf("SessionKey with shared_threads = %q, want %q", got, want)
+	}
+}
+
+func TestResolveRoute_SessionScopes(t *testing.T) {
+	cfg := testConfig(nil, nil)
+	cfg.Session.DMScope = "per-peer"
+	cfg.Session.IdentityLinks = map[string][]string{"alice": {"telegram:42", "slack:U42"}}
+	r := NewRouteResolver(cfg)
+
+	telegram := r.ResolveRoute(RouteInput{Channel: "telegram", Peer: &RoutePeer{Kind: "direct", ID: "42"}})
+	slack := r.ResolveRoute(RouteInput{Channel: "slack", Peer: &RoutePeer{Kind: "direct", ID: "U42"}})
+	if telegram.SessionKey != "agent:main:direct:alice" || slack.SessionKey != telegram.SessionKey {
+		t.Errorf("linked DMs = %q and %q, want one shared session", telegram.SessionKey, slack.SessionKey)
+	}
+
+	cfg.Session.DMScope = "per-channel-peer"
+	telegram = r.ResolveRoute(RouteInput{Channel: "telegram", Peer: &RoutePeer{Kind: "direct", ID: "42"}})
+	slack = r.ResolveRoute(RouteInput{Channel: "slack", Peer: &RoutePeer{Kind: "direct", ID: "U42"}})
+	if telegram.SessionKey == slack.SessionKey {
+		t.Errorf("per-channel-peer DMs share session %q", telegram.SessionKey)
+	}
+
+	cfg.Session.GroupScope = "per-member"
+	group := r.ResolveRoute(RouteInput{
+		Channel:  "slack",
+		Peer:     &RoutePeer{Kind: "channel", ID: "C1"},
+		SenderID: "U42",
+	})
+	if got, want := group.SessionKey, "agent:main:slack:channel:c1:member:alice"; got != want {
+		t.Errorf("per-member group SessionKey = %q, want %q", got, want)
+	}
+}
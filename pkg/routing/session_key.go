@@ -15,6 +15,14 @@ const (
 	DMScopePerAccountChannelPeer DMScope = "per-account-channel-peer"
 )
 
+// GroupScope controls session isolation inside group chats.
+type GroupScope string
+
+const (
+	GroupScopePerChat   GroupScope = "per-chat"
+	GroupScopePerMember GroupScope = "per-member"
+)
+
 // RoutePeer represents a chat peer with kind and ID.
 type RoutePeer struct {
 	Kind string // "direct", "group", "channel"
@@ -29,6 +37,13 @@ type SessionKeyParams struct {
 	Peer          *RoutePeer
 	DMScope       DMScope
 	IdentityLinks map[string][]string
+	// GroupScope per-member gives each SenderID its own session within a
+	// group chat; the default shares one session per group.
+	GroupScope GroupScope
+	SenderID   string
+	// ThreadID scopes the session to a platform thread (Slack thread,
+	// Telegram topic or reply chain) within the peer's chat.
+	ThreadID string
 }
 
 // ParsedSessionKey is the result of parsing an agent-scoped session key.
@@ -43,7 +58,16 @@ func BuildAgentMainSessionKey(agentID string) string {
 }
 
 // BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, and DM scope.
+// A non-empty ThreadID appends ":thread:<id>" so each thread gets its own history.
 func BuildAgentPeerSessionKey(params SessionKeyParams) string {
+	key := buildPeerSessionKey(params)
+	if threadID := strings.ToLower(strings.TrimSpace(params.ThreadID)); threadID != "" {
+		key += ":thread:" + threadID
+	}
+	return key
+}
+
+func buildPeerSessionKey(params SessionKeyParams) string {
 	agentID := NormalizeAgentID(params.AgentID)
 
 	peer := params.Peer
@@ -96,7 +120,41 @@ func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	if peerID == "" {
 		peerID = "unknown"
 	}
-	return fmt.Sprintf("agent:%s:%s:%s:%s", agentID, channel, peerKind, peerID)
+	key := fmt.Sprintf("agent:%s:%s:%s:%s", agentID, channel, peerKind, peerID)
+
+	if params.GroupScope == GroupScopePerMember {
+		if memberID := resolveMemberID(params.IdentityLinks, channel, params.SenderID); memberID != "" {
+			key += ":member:" + memberID
+		}
+	}
+	return key
+}
+
+// resolveMemberID returns the identity-linked name of a group member, or
+// the platform ID without any "|username" suffix.
+func resolveMemberID(identityLinks map[string][]string, channel, senderID string) string {
+	senderID, _, _ = strings.Cut(strings.TrimSpace(senderID), "|")
+	if senderID == "" {
+		return ""
+	}
+	if linked := resolveLinkedPeerID(identityLinks, channel, senderID); linked != "" {
+		senderID = linked
+	}
+	return strings.ToLower(senderID)
+}
+
+// ResolveUserID names the person who sent a message the same way across
+// chats: their identity-linked name, or "channel:id". It returns "" when
+// there is no sender.
+func ResolveUserID(identityLinks map[string][]string, channel, senderID string) string {
+	id, _, _ := strings.Cut(strings.TrimSpace(senderID), "|")
+	if id == "" {
+		return ""
+	}
+	if linked := resolveLinkedPeerID(identityLinks, channel, id); linked != "" {
+		return strings.ToLower(linked)
+	}
+	return strings.ToLower(normalizeChannel(channel) + ":" + id)
 }
 
 // ParseAgentSessionKey extracts agentId and rest from "agent:<agentId>:<rest>".
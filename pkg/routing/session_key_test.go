@@ -57,6 +57,19 @@ func TestBuildAgentPeerSessionKey_DMScopePerChannelPeer(t *testing.T) {
 	}
 }
 
+func TestBuildAgentPeerSessionKey_Thread(t *testing.T) {
+	got := BuildAgentPeerSessionKey(SessionKeyParams{
+		AgentID:  "main",
+		Channel:  "slack",
+		Peer:     &RoutePeer{Kind: "channel", ID: "C001"},
+		ThreadID: "1700000000.000100",
+	})
+	want := "agent:main:slack:channel:c001:thread:1700000000.000100"
+	if got != want {
+		t.Errorf("Thread = %q, want %q", got, want)
+	}
+}
+
 func TestBuildAgentPeerSessionKey_DMScopePerAccountChannelPeer(t *testing.T) {
 	got := BuildAgentPeerSessionKey(SessionKeyParams{
 		AgentID:   "main",
@@ -115,6 +128,29 @@ func TestBuildAgentPeerSessionKey_IdentityLink(t *testing.T) {
 	}
 }
 
+func TestBuildAgentPeerSessionKey_GroupScopePerMember(t *testing.T) {
+	params := SessionKeyParams{
+		AgentID:    "main",
+		Channel:    "telegram",
+		Peer:       &RoutePeer{Kind: "group", ID: "-100123"},
+		GroupScope: GroupScopePerMember,
+		SenderID:   "42|Alice",
+	}
+	if got, want := BuildAgentPeerSessionKey(params), "agent:main:telegram:group:-100123:member:42"; got != want {
+		t.Errorf("GroupScopePerMember = %q, want %q", got, want)
+	}
+
+	params.IdentityLinks = map[string][]string{"alice": {"telegram:42"}}
+	if got, want := BuildAgentPeerSessionKey(params), "agent:main:telegram:group:-100123:member:alice"; got != want {
+		t.Errorf("GroupScopePerMember with identity link = %q, want %q", got, want)
+	}
+
+	params.GroupScope = GroupScopePerChat
+	if got, want := BuildAgentPeerSessionKey(params), "agent:main:telegram:group:-100123"; got != want {
+		t.Errorf("GroupScopePerChat = %q, want %q", got, want)
+	}
+}
+
 func TestParseAgentSessionKey_Valid(t *testing.T) {
 	parsed := ParseAgentSessionKey("agent:sales:telegram:direct:user123")
 	if parsed == nil {
@@ -160,3 +196,21 @@ func TestIsSubagentSessionKey(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveUserID(t *testing.T) {
+	links := map[string][]string{"alice": {"telegram:123", "discord:alice#1"}}
+	tests := []struct {
+		channel, senderID, want string
+	}{
+		{"telegram", "123|alice_tg", "alice"},
+		{"discord", "Alice#1", "alice"},
+		{"telegram", "456", "telegram:456"},
+		{"Slack", "U0ABC|bob", "slack:u0abc"},
+		{"telegram", "", ""},
+	}
+	for _, tt := range tests {
+		if got := ResolveUserID(links, tt.channel, tt.senderID); got != tt.want {
+			t.Errorf("ResolveUserID(%q, %q) = %q, want %q", tt.channel, tt.senderID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package session
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Archive keeps a copy of the session with the archived sessions and starts
+// it over with no history, summary or usage. Sessions that are empty are
+// just cleared.
+func (sm *SessionManager) Archive(key string) error {
+	snapshot, ok := sm.snapshot(key)
+	if !ok {
+		return nil
+	}
+	if sm.db != nil && (len(snapshot.Messages) > 0 || snapshot.Summary != "") {
+		if err := sm.writeArchive(snapshot, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	sm.mu.Lock()
+	if session, ok := sm.sessions[key]; ok {
+		session.Messages = []providers.Message{}
+		session.Summary = ""
+		session.Feedback = nil
+		session.Usage = Usage{}
+		session.Created = time.Now()
+		session.Updated = session.Created
+	}
+	sm.mu.Unlock()
+	return sm.Save(key)
+}
+
+// Prune archives sessions not updated within idle and deletes archived
+// sessions older than retention. A zero duration disables that step. It
+// returns how many sessions were archived and how many archives deleted.
+func (sm *SessionManager) Prune(idle, retention time.Duration) (archived, deleted int, err error) {
+	if idle > 0 {
+		cutoff := time.Now().Add(-idle)
+		var stale []string
+		sm.mu.RLock()
+		for key, session := range sm.sessions {
+			if session.Updated.Before(cutoff) {
+				stale = append(stale, key)
+			}
+		}
+		sm.mu.RUnlock()
+
+		for _, key := range stale {
+			if err := sm.Archive(key); err != nil {
+				return archived, deleted, err
+			}
+			sm.remove(key)
+			archived++
+		}
+	}
+
+	if retention > 0 && sm.db != nil {
+		cutoff := time.Now().Add(-retention)
+		result, err := sm.db.Exec(`DELETE FROM archived_sessions WHERE archived < ?`, cutoff.UnixMilli())
+		if err != nil {
+			return archived, deleted, err
+		}
+		n, _ := result.RowsAffected()
+		deleted = int(n)
+	}
+	return archived, deleted, nil
+}
+
+// remove drops an archived session from memory and from the database,
+// unless a message came in since it was archived.
+func (sm *SessionManager) remove(key string) {
+	sm.mu.Lock()
+	if session, ok := sm.sessions[key]; !ok || len(session.Messages) > 0 {
+		sm.mu.Unlock()
+		return
+	}
+	delete(sm.sessions, key)
+	sm.mu.Unlock()
+
+	if sm.db != nil {
+		_, _ = sm.db.Exec(`DELETE FROM sessions WHERE key = ?`, key)
+	}
+}
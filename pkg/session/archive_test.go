@@ -0,0 +1,72 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+	key := "telegram:42"
+	sm.AddMessage(key, "user", "hello")
+	sm.AddUsage(key, &providers.UsageInfo{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})
+	sm.AddUsage(key, nil)
+	if got := sm.GetUsage(key); got.Calls != 2 || got.TotalTokens != 15 {
+		t.Errorf("GetUsage() = %+v", got)
+	}
+
+	if err := sm.Archive(key); err != nil {
+		t.Fatalf("Archive() error: %v", err)
+	}
+	if len(sm.GetHistory(key)) != 0 || sm.GetUsage(key).Calls != 0 {
+		t.Error("session not cleared by Archive()")
+	}
+
+	archives, err := sm.Archived(key)
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("archived() = %v, %v; want one", archives, err)
+	}
+	if archives[0].Messages[0].Content != "hello" || archives[0].Usage.TotalTokens != 15 {
+		t.Errorf("archive = %+v", archives[0])
+	}
+	// Archives are not loaded as sessions
+	reloaded := NewSessionManager(tmpDir)
+	if len(reloaded.GetHistory(key)) != 0 {
+		t.Error("reloaded session has the archived history")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+	sm.AddMessage("old", "user", "from last month")
+	sm.AddMessage("new", "user", "from today")
+	sm.sessions["old"].Updated = time.Now().Add(-30 * 24 * time.Hour)
+	sm.Save("old")
+	sm.Save("new")
+
+	gone := Session{Key: "gone", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	if err := sm.writeArchive(gone, time.Now().Add(-400*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, deleted, err := sm.Prune(7*24*time.Hour, 90*24*time.Hour)
+	if err != nil || archived != 1 || deleted != 1 {
+		t.Fatalf("Prune() = %d, %d, %v; want 1 archived, 1 deleted", archived, deleted, err)
+	}
+	if _, ok := NewSessionManager(tmpDir).sessions["old"]; ok {
+		t.Error("idle session still stored")
+	}
+	if archives, _ := sm.Archived("gone"); len(archives) != 0 {
+		t.Error("expired archive not deleted")
+	}
+	if len(sm.GetHistory("new")) != 1 {
+		t.Error("active session pruned")
+	}
+	if archives, _ := sm.Archived("old"); len(archives) != 1 {
+		t.Errorf("idle session archives = %v", archives)
+	}
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// MainBranch is the name of a conversation's original thread.
+	MainBranch = "main"
+	// branchSep joins a conversation's session key and a branch name into
+	// the key of the branch's session.
+	branchSep = ":branch:"
+)
+
+var reBranchName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ErrNoBranch is returned when switching to a branch that doesn't exist.
+var ErrNoBranch = errors.New("no such branch")
+
+// BranchKey returns the session key of branch name of the conversation
+// with session key base.
+func BranchKey(base, name string) string {
+	if name == "" || name == MainBranch {
+		return base
+	}
+	return base + branchSep + name
+}
+
+// BaseKey returns the conversation session key of a branch's key, and the
+// branch name.
+func BaseKey(key string) (base, branch string) {
+	if base, branch, ok := strings.Cut(key, branchSep); ok {
+		return base, branch
+	}
+	return key, MainBranch
+}
+
+// ActiveKey returns the session key of the branch the conversation with
+// session key base is on.
+func (sm *SessionManager) ActiveKey(base string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if session, ok := sm.sessions[base]; ok {
+		return BranchKey(base, session.ActiveBranch)
+	}
+	return base
+}
+
+// Branches lists the branches of the conversation with session key base,
+// main first.
+func (sm *SessionManager) Branches(base string) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var names []string
+	for key := range sm.sessions {
+		if b, name := BaseKey(key); b == base && name != MainBranch {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{MainBranch}, names...)
+}
+
+// Fork copies the session with key into a new branch of its conversation
+// and makes the branch active. An empty name picks the next free "fork-N".
+// It returns the new branch's name.
+func (sm *SessionManager) Fork(key, name string) (string, error) {
+	base, _ := BaseKey(key)
+	snapshot, ok := sm.snapshot(key)
+	if !ok || len(snapshot.Messages) == 0 {
+		return "", errors.New("there is no conversation to fork yet")
+	}
+
+	sm.mu.Lock()
+	if name == "" {
+		name = sm.freeBranchName(base, "fork")
+	}
+	name = strings.ToLower(name)
+	if !reBranchName.MatchString(name) || name == MainBranch {
+		sm.mu.Unlock()
+		return "", fmt.Errorf("invalid branch name %q; use up to 32 letters, digits, - and _", name)
+	}
+	branchKey := BranchKey(base, name)
+	if _, exists := sm.sessions[branchKey]; exists {
+		sm.mu.Unlock()
+		return "", fmt.Errorf("branch %s already exists", name)
+	}
+	now := time.Now()
+	sm.sessions[branchKey] = &Session{
+		Key:      branchKey,
+		Messages: append([]providers.Message(nil), snapshot.Messages...),
+		Summary:  snapshot.Summary,
+		Created:  now,
+		Updated:  now,
+	}
+	sm.activate(base, name)
+	sm.mu.Unlock()
+
+	if err := sm.Save(branchKey); err != nil {
+		return "", err
+	}
+	return name, sm.Save(base)
+}
+
+// Switch makes branch name active in the conversation with session key
+// base.
+func (sm *SessionManager) Switch(base, name string) error {
+	name = strings.ToLower(name)
+	sm.mu.Lock()
+	if _, exists := sm.sessions[BranchKey(base, name)]; !exists && name != MainBranch {
+		sm.mu.Unlock()
+		return ErrNoBranch
+	}
+	sm.activate(base, name)
+	sm.mu.Unlock()
+	return sm.Save(base)
+}
+
+// freeBranchName returns the first "prefix-N" that no branch of the
+// conversation uses. The caller holds sm.mu.
+func (sm *SessionManager) freeBranchName(base, prefix string) string {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s-%d", prefix, i)
+		if _, taken := sm.sessions[BranchKey(base, name)]; !taken {
+			return name
+		}
+	}
+}
+
+// activate records the active branch on the conversation's main session.
+// The caller holds sm.mu.
+func (sm *SessionManager) activate(base, name string) {
+	if name == MainBranch {
+		name = ""
+	}
+	session, ok := sm.sessions[base]
+	if !ok {
+		session = &Session{Key: base, Messages: []providers.Message{}, Created: time.Now()}
+		sm.sessions[base] = session
+	}
+	session.ActiveBranch = name
+	session.Updated = time.Now()
+}
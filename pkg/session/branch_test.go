@@ -0,0 +1,41 @@
+package session
+
+import "testing"
+
+func TestFork(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	base := "agent:main:telegram:direct:42"
+	sm.AddMessage(base, "user", "hello")
+	sm.SetSummary(base, "earlier talk")
+
+	name, err := sm.Fork(base, "")
+	if err != nil || name != "fork-1" {
+		t.Fatalf("Fork() = %q, %v", name, err)
+	}
+	key := BranchKey(base, name)
+	if sm.ActiveKey(base) != key || sm.GetSummary(key) != "earlier talk" {
+		t.Errorf("after Fork: active %q, summary %q", sm.ActiveKey(base), sm.GetSummary(key))
+	}
+	// Forking a branch makes a sibling, not a nested branch
+	if name, _ := sm.Fork(key, ""); name != "fork-2" {
+		t.Errorf("second Fork() = %q", name)
+	}
+	if b, branch := BaseKey(sm.ActiveKey(base)); b != base || branch != "fork-2" {
+		t.Errorf("BaseKey(active) = %q, %q", b, branch)
+	}
+
+	for _, bad := range []string{"main", "fork-1", "has space", "../up"} {
+		if _, err := sm.Fork(base, bad); err == nil {
+			t.Errorf("Fork(%q) succeeded", bad)
+		}
+	}
+	if got := sm.Branches(base); len(got) != 3 || got[0] != MainBranch || got[2] != "fork-2" {
+		t.Errorf("Branches() = %v", got)
+	}
+	if err := sm.Switch(base, "nope"); err != ErrNoBranch {
+		t.Errorf("Switch to a missing branch = %v", err)
+	}
+	if err := sm.Switch(base, "MAIN"); err != nil || sm.ActiveKey(base) != base {
+		t.Errorf("Switch(main) = %v, active %q", err, sm.ActiveKey(base))
+	}
+}
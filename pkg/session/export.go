@@ -0,0 +1,200 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// TranscriptFormat marks an exported session.
+const TranscriptFormat = "picoclaw-session"
+
+// ErrSessionExists is returned when importing into a session that already
+// has messages.
+var ErrSessionExists = errors.New("session already has messages")
+
+// Transcript is a session exported for archival or for importing
+// elsewhere: its messages with their tool calls and results, the summary of
+// older messages and the token usage.
+type Transcript struct {
+	Format   string              `json:"format"`
+	Version  int                 `json:"version"`
+	Key      string              `json:"key"`
+	Summary  string              `json:"summary,omitempty"`
+	Messages []providers.Message `json:"messages"`
+	Usage    Usage               `json:"usage"`
+	Created  time.Time           `json:"created"`
+	Updated  time.Time           `json:"updated"`
+	Exported time.Time           `json:"exported"`
+}
+
+// SessionInfo describes a stored session.
+type SessionInfo struct {
+	Key      string
+	Messages int
+	Updated  time.Time
+}
+
+// List describes every session, most recently updated first.
+func (sm *SessionManager) List() []SessionInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	infos := make([]SessionInfo, 0, len(sm.sessions))
+	for key, session := range sm.sessions {
+		infos = append(infos, SessionInfo{Key: key, Messages: len(session.Messages), Updated: session.Updated})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Updated.After(infos[j].Updated) })
+	return infos
+}
+
+// Export returns the transcript of the session with key.
+func (sm *SessionManager) Export(key string) (*Transcript, error) {
+	snapshot, ok := sm.snapshot(key)
+	if !ok || (len(snapshot.Messages) == 0 && snapshot.Summary == "") {
+		return nil, fmt.Errorf("session %s has no messages", key)
+	}
+	return &Transcript{
+		Format:   TranscriptFormat,
+		Version:  1,
+		Key:      snapshot.Key,
+		Summary:  snapshot.Summary,
+		Messages: snapshot.Messages,
+		Usage:    snapshot.Usage,
+		Created:  snapshot.Created,
+		Updated:  snapshot.Updated,
+		Exported: time.Now(),
+	}, nil
+}
+
+// Import fills the session with key from t. The session must be new or
+// empty, so nothing is overwritten.
+func (sm *SessionManager) Import(key string, t *Transcript) error {
+	sm.mu.Lock()
+	if session, ok := sm.sessions[key]; ok && (len(session.Messages) > 0 || session.Summary != "") {
+		sm.mu.Unlock()
+		return ErrSessionExists
+	}
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{Key: key}
+		sm.sessions[key] = session
+	}
+	session.Messages = append([]providers.Message{}, t.Messages...)
+	session.Summary = t.Summary
+	session.Usage = t.Usage
+	session.Created = time.Now()
+	session.Updated = session.Created
+	sm.mu.Unlock()
+	return sm.Save(key)
+}
+
+// ImportBranch imports t into a new branch of the conversation with
+// session key base, named "import-N", and makes it active.
+func (sm *SessionManager) ImportBranch(base string, t *Transcript) (string, error) {
+	sm.mu.Lock()
+	name := sm.freeBranchName(base, "import")
+	sm.mu.Unlock()
+	if err := sm.Import(BranchKey(base, name), t); err != nil {
+		return "", err
+	}
+	return name, sm.Switch(base, name)
+}
+
+// ParseTranscript reads an exported transcript. A session file from the
+// sessions directory is accepted as well.
+func ParseTranscript(data []byte) (*Transcript, error) {
+	var t Transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("not a session transcript: %w", err)
+	}
+	if t.Format != "" && t.Format != TranscriptFormat {
+		return nil, fmt.Errorf("unknown transcript format %q", t.Format)
+	}
+	if len(t.Messages) == 0 && t.Summary == "" {
+		return nil, errors.New("the transcript has no messages")
+	}
+	for i, msg := range t.Messages {
+		switch msg.Role {
+		case "user", "assistant", "tool", "system":
+		default:
+			return nil, fmt.Errorf("message %d has unknown role %q", i+1, msg.Role)
+		}
+	}
+	return &t, nil
+}
+
+// Markdown renders the transcript for reading. Tool results are shown with
+// the name of the tool that produced them.
+func (t *Transcript) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Conversation %s\n\n", t.Key)
+	fmt.Fprintf(&sb, "- Started: %s\n", t.Created.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&sb, "- Last message: %s\n", t.Updated.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&sb, "- Messages: %d\n", len(t.Messages))
+	fmt.Fprintf(&sb, "- Usage: %s\n", t.Usage)
+	if t.Summary != "" {
+		sb.WriteString("\n## Summary of earlier messages\n\n" + strings.TrimSpace(t.Summary) + "\n")
+	}
+
+	toolNames := make(map[string]string)
+	for _, msg := range t.Messages {
+		switch msg.Role {
+		case "user":
+			sb.WriteString("\n## User\n\n")
+		case "assistant":
+			sb.WriteString("\n## Assistant\n\n")
+		case "tool":
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				name = "tool"
+			}
+			fmt.Fprintf(&sb, "\n**Result of %s:**\n\n%s", name, fence(msg.Content, ""))
+			continue
+		default:
+			fmt.Fprintf(&sb, "\n## %s\n\n", msg.Role)
+		}
+		if content := strings.TrimSpace(msg.Content); content != "" {
+			sb.WriteString(content + "\n")
+		}
+		if len(msg.Images) > 0 {
+			fmt.Fprintf(&sb, "\n_[%d image(s)]_\n", len(msg.Images))
+		}
+		for _, tc := range msg.ToolCalls {
+			name, args := tc.Name, ""
+			if tc.Function != nil {
+				if name == "" {
+					name = tc.Function.Name
+				}
+				args = tc.Function.Arguments
+			}
+			if args == "" && tc.Arguments != nil {
+				data, _ := json.MarshalIndent(tc.Arguments, "", "  ")
+				args = string(data)
+			}
+			toolNames[tc.ID] = name
+			fmt.Fprintf(&sb, "\n**Calls %s:**\n\n%s", name, fence(args, "json"))
+		}
+	}
+	return sb.String()
+}
+
+// fence wraps text in a code block whose fence is longer than any run of
+// backticks in it.
+func fence(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	f := strings.Repeat("`", max(3, longest+1))
+	return f + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + f + "\n"
+}
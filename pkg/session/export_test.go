@@ -0,0 +1,82 @@
+package session
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestExportImport(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	key := "agent:main:telegram:direct:42"
+	sm.AddMessage(key, "user", "what's in notes.txt?")
+	sm.AddFullMessage(key, providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Function: &providers.FunctionCall{Name: "read_file", Arguments: `{"path":"notes.txt"}`},
+		}},
+	})
+	sm.AddFullMessage(key, providers.Message{Role: "tool", ToolCallID: "call_1", Content: "```buy milk```"})
+	sm.AddMessage(key, "assistant", "It says to buy milk.")
+	sm.AddUsage(key, &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120})
+
+	transcript, err := sm.Export(key)
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	md := transcript.Markdown()
+	for _, want := range []string{"## User\n\nwhat's in notes.txt?", "**Calls read_file:**", `{"path":"notes.txt"}`,
+		"**Result of read_file:**\n\n````\n```buy milk```\n````", "## Assistant\n\nIt says to buy milk."} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() lacks %q:\n%s", want, md)
+		}
+	}
+
+	data, _ := json.Marshal(transcript)
+	parsed, err := ParseTranscript(data)
+	if err != nil {
+		t.Fatalf("ParseTranscript() error: %v", err)
+	}
+	other := NewSessionManager(t.TempDir())
+	if err := other.Import(key, parsed); err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	history := other.GetHistory(key)
+	if len(history) != 4 || history[1].ToolCalls[0].Function.Name != "read_file" || history[2].ToolCallID != "call_1" {
+		t.Errorf("imported history = %+v", history)
+	}
+	if got := other.GetUsage(key); got.TotalTokens != 120 {
+		t.Errorf("imported usage = %+v", got)
+	}
+	if err := other.Import(key, parsed); err != ErrSessionExists {
+		t.Errorf("Import() into a session with messages = %v", err)
+	}
+
+	name, err := other.ImportBranch(key, parsed)
+	if err != nil || name != "import-1" || other.ActiveKey(key) != BranchKey(key, "import-1") {
+		t.Errorf("ImportBranch() = %q, %v; active %q", name, err, other.ActiveKey(key))
+	}
+	if infos := other.List(); len(infos) != 2 {
+		t.Errorf("List() = %+v", infos)
+	}
+}
+
+func TestParseTranscript_Errors(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":     "hello",
+		"other format": `{"format":"chat-log","messages":[{"role":"user","content":"hi"}]}`,
+		"empty":        `{"format":"picoclaw-session","messages":[]}`,
+		"bad role":     `{"messages":[{"role":"narrator","content":"hi"}]}`,
+	} {
+		if _, err := ParseTranscript([]byte(data)); err == nil {
+			t.Errorf("%s: ParseTranscript() succeeded", name)
+		}
+	}
+	// A session file from the sessions directory carries no format
+	if _, err := ParseTranscript([]byte(`{"key":"k","messages":[{"role":"user","content":"hi"}]}`)); err != nil {
+		t.Errorf("session file: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Feedback is a rating a user gave one of the agent's replies, such as a
+// 👍 or 👎 reaction or a /bad command. It is kept with the session so
+// replies can be reviewed against the conversation that produced them.
+type Feedback struct {
+	Rating    string    `json:"rating"` // bus.FeedbackUp or bus.FeedbackDown
+	SenderID  string    `json:"sender_id"`
+	MessageID string    `json:"message_id,omitempty"` // platform ID of the rated reply
+	Turn      int       `json:"turn,omitempty"`       // position of the reply in the history, from 1
+	Reply     string    `json:"reply,omitempty"`
+	Prompt    string    `json:"prompt,omitempty"` // user message the reply answered
+	Tools     []string  `json:"tools,omitempty"`  // tools called while writing the reply
+	Comment   string    `json:"comment,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// sameReply reports whether fb rates the reply prev rated.
+func (fb Feedback) sameReply(prev Feedback) bool {
+	if fb.MessageID != "" && fb.MessageID == prev.MessageID {
+		return true
+	}
+	return fb.Turn > 0 && fb.Turn == prev.Turn
+}
+
+// SessionFeedback is feedback together with the session it was given in.
+type SessionFeedback struct {
+	SessionKey string `json:"session_key"`
+	Feedback
+}
+
+// AddFeedback records feedback in a session. A sender rating the same reply
+// again replaces their earlier rating, keeping its comment unless the new
+// rating has one.
+func (sm *SessionManager) AddFeedback(key string, fb Feedback) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+
+	for i, prev := range session.Feedback {
+		if prev.SenderID == fb.SenderID && fb.sameReply(prev) {
+			if fb.Comment == "" {
+				fb.Comment = prev.Comment
+			}
+			if fb.MessageID == "" {
+				fb.MessageID = prev.MessageID
+			}
+			session.Feedback = append(session.Feedback[:i], session.Feedback[i+1:]...)
+			break
+		}
+	}
+	session.Feedback = append(session.Feedback, fb)
+	session.Updated = time.Now()
+}
+
+// GetFeedback returns the feedback recorded in a session, oldest first.
+func (sm *SessionManager) GetFeedback(key string) []Feedback {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return nil
+	}
+	return append([]Feedback(nil), session.Feedback...)
+}
+
+// ListFeedback returns the feedback of every session, oldest first.
+func (sm *SessionManager) ListFeedback() []SessionFeedback {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var all []SessionFeedback
+	for key, session := range sm.sessions {
+		for _, fb := range session.Feedback {
+			all = append(all, SessionFeedback{SessionKey: key, Feedback: fb})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+	return all
+}
+
+// RatedReply is one of the agent's replies with all the ratings it got.
+type RatedReply struct {
+	SessionKey string    `json:"session_key"`
+	Turn       int       `json:"turn,omitempty"`
+	Prompt     string    `json:"prompt,omitempty"`
+	Reply      string    `json:"reply,omitempty"`
+	Tools      []string  `json:"tools,omitempty"`
+	Up         int       `json:"up"`
+	Down       int       `json:"down"`
+	Comments   []string  `json:"comments,omitempty"`
+	Last       time.Time `json:"last"` // when it was last rated
+}
+
+// Score is the number of 👍 minus the number of 👎.
+func (r *RatedReply) Score() int {
+	return r.Up - r.Down
+}
+
+// WorstRated groups feedback by the reply it rates and returns up to n of
+// the replies with at least one 👎, lowest score first. Ties go to the reply
+// with more comments, then to the one rated last. n <= 0 returns all.
+func WorstRated(entries []SessionFeedback, n int) []RatedReply {
+	type replyKey struct {
+		session, id string
+		turn        int
+	}
+	var order []replyKey
+	byKey := make(map[replyKey]*RatedReply)
+	for _, fb := range entries {
+		key := replyKey{session: fb.SessionKey, turn: fb.Turn}
+		if fb.Turn == 0 {
+			key.id = fb.MessageID
+			if key.id == "" {
+				key.id = fb.Reply
+			}
+		}
+		r, ok := byKey[key]
+		if !ok {
+			r = &RatedReply{SessionKey: fb.SessionKey, Turn: fb.Turn}
+			byKey[key] = r
+			order = append(order, key)
+		}
+		if fb.Rating == bus.FeedbackDown {
+			r.Down++
+		} else {
+			r.Up++
+		}
+		if fb.Prompt != "" {
+			r.Prompt = fb.Prompt
+		}
+		if fb.Reply != "" {
+			r.Reply = fb.Reply
+		}
+		if len(fb.Tools) > 0 {
+			r.Tools = fb.Tools
+		}
+		if fb.Comment != "" {
+			r.Comments = append(r.Comments, fb.Comment)
+		}
+		if fb.Time.After(r.Last) {
+			r.Last = fb.Time
+		}
+	}
+
+	var worst []RatedReply
+	for _, key := range order {
+		if r := byKey[key]; r.Down > 0 {
+			worst = append(worst, *r)
+		}
+	}
+	sort.SliceStable(worst, func(i, j int) bool {
+		a, b := &worst[i], &worst[j]
+		if a.Score() != b.Score() {
+			return a.Score() < b.Score()
+		}
+		if len(a.Comments) != len(b.Comments) {
+			return len(a.Comments) > len(b.Comments)
+		}
+		return a.Last.After(b.Last)
+	})
+	if n > 0 && len(worst) > n {
+		worst = worst[:n]
+	}
+	return worst
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedback_ReplacesAndPersists(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	key := "telegram:7"
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	sm.AddMessage(key, "user", "hi")
+	sm.AddFeedback(key, Feedback{Rating: "down", SenderID: "7", MessageID: "100", Time: start})
+	sm.AddFeedback(key, Feedback{Rating: "up", SenderID: "8", MessageID: "100", Time: start.Add(time.Minute)})
+	sm.AddFeedback(key, Feedback{Rating: "up", SenderID: "7", MessageID: "100", Time: start.Add(2 * time.Minute)})
+	sm.AddFeedback("slack:C1", Feedback{Rating: "down", SenderID: "U1", Time: start.Add(-time.Hour)})
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := sm.Save("slack:C1"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded := NewSessionManager(dir)
+	got := reloaded.GetFeedback(key)
+	if len(got) != 2 || got[0].SenderID != "8" || got[1].SenderID != "7" || got[1].Rating != "up" {
+		t.Fatalf("feedback = %+v", got)
+	}
+	if history := reloaded.GetHistory(key); len(history) != 1 {
+		t.Errorf("history = %+v", history)
+	}
+
+	all := reloaded.ListFeedback()
+	if len(all) != 3 || all[0].SessionKey != "slack:C1" || all[2].SessionKey != key {
+		t.Errorf("ListFeedback() = %+v", all)
+	}
+}
+
+func TestWorstRated(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return start.Add(time.Duration(min) * time.Minute) }
+	entries := []SessionFeedback{
+		{"telegram:7", Feedback{Rating: "down", SenderID: "7", Turn: 2, Reply: "25 degrees", Time: at(0)}},
+		{"telegram:7", Feedback{Rating: "down", SenderID: "8", Turn: 2, Comment: "wrong unit", Time: at(1)}},
+		{"telegram:7", Feedback{Rating: "up", SenderID: "7", Turn: 4, Time: at(2)}},
+		{"slack:C1", Feedback{Rating: "down", SenderID: "U1", MessageID: "1.5", Time: at(3)}},
+		{"slack:C1", Feedback{Rating: "up", SenderID: "U2", MessageID: "1.5", Time: at(4)}},
+		{"slack:C1", Feedback{Rating: "down", SenderID: "U1", MessageID: "2.5", Comment: "too long", Time: at(5)}},
+		{"discord:9", Feedback{Rating: "down", SenderID: "9", Turn: 6, Time: at(6)}},
+	}
+
+	got := WorstRated(entries, 0)
+	if len(got) != 4 {
+		t.Fatalf("WorstRated() = %+v, want 4 replies", got)
+	}
+	first := got[0]
+	if first.SessionKey != "telegram:7" || first.Score() != -2 || first.Reply != "25 degrees" ||
+		len(first.Comments) != 1 || !first.Last.Equal(at(1)) {
+		t.Errorf("worst = %+v", first)
+	}
+	// Ties at -1: the commented reply, then the latest
+	if got[1].Comments[0] != "too long" || got[2].SessionKey != "discord:9" {
+		t.Errorf("order = %+v", got)
+	}
+	if got[3].Score() != 0 {
+		t.Errorf("last = %+v", got[3])
+	}
+	if top := WorstRated(entries, 2); len(top) != 2 {
+		t.Errorf("WorstRated(2) returned %d replies", len(top))
+	}
+}
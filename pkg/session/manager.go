@@ -1,13 +1,12 @@
 package session
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"strings"
+	"database/sql"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -15,16 +14,56 @@ type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Feedback []Feedback          `json:"feedback,omitempty"`
+	Usage    Usage               `json:"usage"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
+	// ActiveBranch is the branch the conversation is on, "" for main. Only
+	// the main session of a conversation keeps it.
+	ActiveBranch string `json:"active_branch,omitempty"`
+	// DryRun overrides tools.dry_run for the session when set.
+	DryRun *bool `json:"dry_run,omitempty"`
+	// Persona is the persona the conversation switched to, "" for none.
+	Persona string `json:"persona,omitempty"`
+}
+
+// Usage sums the token usage of the model calls made for a session.
+type Usage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add counts one model call; usage may be nil when the provider doesn't
+// report it.
+func (u *Usage) Add(usage *providers.UsageInfo) {
+	u.Calls++
+	if usage != nil {
+		u.PromptTokens += usage.PromptTokens
+		u.CompletionTokens += usage.CompletionTokens
+		u.TotalTokens += usage.TotalTokens
+	}
+}
+
+func (u Usage) String() string {
+	if u.Calls == 0 {
+		return "no model calls yet"
+	}
+	return fmt.Sprintf("%d tokens (%d prompt, %d completion) over %d model calls",
+		u.TotalTokens, u.PromptTokens, u.CompletionTokens, u.Calls)
 }
 
 type SessionManager struct {
 	sessions map[string]*Session
 	mu       sync.RWMutex
 	storage  string
+	db       *sql.DB // nil keeps sessions in memory only
 }
 
+// NewSessionManager loads the sessions kept in the SQLite database in the
+// storage directory. Without storage, or when the database can't be opened,
+// sessions only live in memory.
 func NewSessionManager(storage string) *SessionManager {
 	sm := &SessionManager{
 		sessions: make(map[string]*Session),
@@ -32,8 +71,20 @@ func NewSessionManager(storage string) *SessionManager {
 	}
 
 	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+		db, err := openDB(storage)
+		if err != nil {
+			logger.ErrorCF("session", "Failed to open the session database, sessions won't be saved",
+				map[string]any{"dir": storage, "error": err.Error()})
+			return sm
+		}
+		sm.db = db
+		if err := sm.loadSessions(); err != nil {
+			logger.ErrorCF("session", "Failed to load sessions", map[string]any{"error": err.Error()})
+		}
+		if err := sm.migrateFiles(); err != nil {
+			logger.ErrorCF("session", "Failed to move session files into the database",
+				map[string]any{"error": err.Error()})
+		}
 	}
 
 	return sm
@@ -122,6 +173,79 @@ func (sm *SessionManager) SetSummary(key string, summary string) {
 	}
 }
 
+// AddUsage counts a model call made for the session.
+func (sm *SessionManager) AddUsage(key string, usage *providers.UsageInfo) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, ok := sm.sessions[key]; ok {
+		session.Usage.Add(usage)
+	}
+}
+
+// GetUsage returns the session's token usage.
+func (sm *SessionManager) GetUsage(key string) Usage {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[key]; ok {
+		return session.Usage
+	}
+	return Usage{}
+}
+
+// SetDryRun turns dry-run mode on or off for the session.
+func (sm *SessionManager) SetDryRun(key string, on bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{Key: key, Messages: []providers.Message{}, Created: time.Now()}
+		sm.sessions[key] = session
+	}
+	session.DryRun = &on
+	session.Updated = time.Now()
+}
+
+// DryRun returns the session's dry-run setting; set is false when the
+// session has none.
+func (sm *SessionManager) DryRun(key string) (on, set bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[key]; ok && session.DryRun != nil {
+		return *session.DryRun, true
+	}
+	return false, false
+}
+
+// SetPersona switches the session to a persona; "" switches back to the
+// agent's own.
+func (sm *SessionManager) SetPersona(key, name string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{Key: key, Messages: []providers.Message{}, Created: time.Now()}
+		sm.sessions[key] = session
+	}
+	session.Persona = name
+	session.Updated = time.Now()
+}
+
+// Persona returns the persona the session is switched to, or "".
+func (sm *SessionManager) Persona(key string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[key]; ok {
+		return session.Persona
+	}
+	return ""
+}
+
 func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -145,43 +269,25 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	session.Updated = time.Now()
 }
 
-// sanitizeFilename converts a session key into a cross-platform safe filename.
-// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
-// volume separator on Windows, so filepath.Base would misinterpret the key.
-// We replace it with '_'. The original key is preserved inside the JSON file,
-// so loadSessions still maps back to the right in-memory key.
-func sanitizeFilename(key string) string {
-	return strings.ReplaceAll(key, ":", "_")
-}
-
-func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
-		return nil
-	}
-
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
-	}
-
-	// Snapshot under read lock, then perform slow file I/O after unlock.
+// snapshot copies a session so it can be written without holding the lock.
+func (sm *SessionManager) snapshot(key string) (Session, bool) {
 	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
 	stored, ok := sm.sessions[key]
 	if !ok {
-		sm.mu.RUnlock()
-		return nil
+		return Session{}, false
 	}
 
 	snapshot := Session{
-		Key:     stored.Key,
-		Summary: stored.Summary,
-		Created: stored.Created,
-		Updated: stored.Updated,
+		Key:          stored.Key,
+		Summary:      stored.Summary,
+		Usage:        stored.Usage,
+		Created:      stored.Created,
+		Updated:      stored.Updated,
+		ActiveBranch: stored.ActiveBranch,
+		DryRun:       stored.DryRun,
+		Persona:      stored.Persona,
 	}
 	if len(stored.Messages) > 0 {
 		snapshot.Messages = make([]providers.Message, len(stored.Messages))
@@ -189,80 +295,24 @@ func (sm *SessionManager) Save(key string) error {
 	} else {
 		snapshot.Messages = []providers.Message{}
 	}
-	sm.mu.RUnlock()
-
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
-	if err != nil {
-		return err
-	}
-
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
-		}
-	}()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
+	if len(stored.Feedback) > 0 {
+		snapshot.Feedback = append([]Feedback(nil), stored.Feedback...)
 	}
-	if err := tmpFile.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
-	}
-	cleanup = false
-	return nil
+	return snapshot, true
 }
 
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
-		return err
+// Save stores the session in the database.
+func (sm *SessionManager) Save(key string) error {
+	if sm.db == nil {
+		return nil
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
-
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
-		if err != nil {
-			continue
-		}
-
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
-
-		sm.sessions[session.Key] = &session
+	// Snapshot under read lock, then write after unlock.
+	snapshot, ok := sm.snapshot(key)
+	if !ok {
+		return nil
 	}
-
-	return nil
+	return sm.writeSession(snapshot)
 }
 
 // SetHistory updates the messages of a session.
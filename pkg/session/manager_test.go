@@ -1,33 +1,13 @@
 package session
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
-)
-
-func TestSanitizeFilename(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"simple", "simple"},
-		{"telegram:123456", "telegram_123456"},
-		{"discord:987654321", "discord_987654321"},
-		{"slack:C01234", "slack_C01234"},
-		{"no-colons-here", "no-colons-here"},
-		{"multiple:colons:here", "multiple_colons_here"},
-	}
 
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := sanitizeFilename(tt.input)
-			if got != tt.expected {
-				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.input, got, tt.expected)
-			}
-		})
-	}
-}
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
 
 func TestSave_WithColonInKey(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -38,17 +18,10 @@ func TestSave_WithColonInKey(t *testing.T) {
 	sm.GetOrCreate(key)
 	sm.AddMessage(key, "user", "hello")
 
-	// Save should succeed even though the key contains ':'
 	if err := sm.Save(key); err != nil {
 		t.Fatalf("Save(%q) failed: %v", key, err)
 	}
 
-	// The file on disk should use sanitized name.
-	expectedFile := filepath.Join(tmpDir, "telegram_123456.json")
-	if _, err := os.Stat(expectedFile); os.IsNotExist(err) {
-		t.Fatalf("expected session file %s to exist", expectedFile)
-	}
-
 	// Load into a fresh manager and verify the session round-trips.
 	sm2 := NewSessionManager(tmpDir)
 	history := sm2.GetHistory(key)
@@ -60,15 +33,82 @@ func TestSave_WithColonInKey(t *testing.T) {
 	}
 }
 
-func TestSave_RejectsPathTraversal(t *testing.T) {
+func TestSave_KeysThatArentFileNames(t *testing.T) {
 	tmpDir := t.TempDir()
 	sm := NewSessionManager(tmpDir)
 
-	badKeys := []string{"", ".", "..", "foo/bar", "foo\\bar"}
-	for _, key := range badKeys {
-		sm.GetOrCreate(key)
-		if err := sm.Save(key); err == nil {
-			t.Errorf("Save(%q) should have failed but didn't", key)
+	keys := []string{".", "..", "foo/bar", "foo\\bar", "../../etc/passwd"}
+	for _, key := range keys {
+		sm.AddMessage(key, "user", key)
+		if err := sm.Save(key); err != nil {
+			t.Errorf("Save(%q) error: %v", key, err)
 		}
 	}
+
+	reloaded := NewSessionManager(tmpDir)
+	for _, key := range keys {
+		if history := reloaded.GetHistory(key); len(history) != 1 || history[0].Content != key {
+			t.Errorf("reloaded %q = %v", key, history)
+		}
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".json" || entry.IsDir() {
+			t.Errorf("unexpected %s in the storage directory", entry.Name())
+		}
+	}
+}
+
+func TestSave_ToolCallsAndUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+	key := "agent:main:cli:default"
+	sm.AddMessage(key, "user", "what's in notes.md?")
+	sm.AddFullMessage(key, providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{
+		{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "notes.md"}},
+	}})
+	sm.AddFullMessage(key, providers.Message{Role: "tool", Content: "buy milk", ToolCallID: "call_1"})
+	sm.AddUsage(key, &providers.UsageInfo{PromptTokens: 30, CompletionTokens: 10, TotalTokens: 40})
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	sm.Close()
+
+	reloaded := NewSessionManager(tmpDir)
+	history := reloaded.GetHistory(key)
+	if len(history) != 3 || history[1].ToolCalls[0].Name != "read_file" || history[2].ToolCallID != "call_1" {
+		t.Errorf("reloaded history = %+v", history)
+	}
+	if usage := reloaded.GetUsage(key); usage.Calls != 1 || usage.TotalTokens != 40 {
+		t.Errorf("reloaded usage = %+v", usage)
+	}
+}
+
+func TestNewSessionManager_MovesSessionFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(path string, session Session) {
+		data, _ := json.Marshal(session)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message := []providers.Message{{Role: "user", Content: "from before"}}
+	write(filepath.Join(tmpDir, "telegram_42.json"), Session{Key: "telegram:42", Messages: message})
+	write(filepath.Join(tmpDir, "archive", "telegram_42.20250101-000000.json"),
+		Session{Key: "telegram:42", Messages: message})
+
+	sm := NewSessionManager(tmpDir)
+	if history := sm.GetHistory("telegram:42"); len(history) != 1 || history[0].Content != "from before" {
+		t.Errorf("history = %v", history)
+	}
+	if archives, _ := sm.Archived("telegram:42"); len(archives) != 1 {
+		t.Errorf("archives = %v", archives)
+	}
+	if files, _ := filepath.Glob(filepath.Join(tmpDir, "*.json")); len(files) != 0 {
+		t.Errorf("session files left behind: %v", files)
+	}
+	if history := NewSessionManager(tmpDir).GetHistory("telegram:42"); len(history) != 1 {
+		t.Errorf("moved session not stored: %v", history)
+	}
 }
@@ -0,0 +1,190 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// dbFile is the SQLite database the sessions are kept in, in the storage
+// directory.
+const dbFile = "sessions.db"
+
+// schema keeps each session as one JSON document, its messages with their
+// tool calls, summary, usage and settings, and the sessions archived by
+// /reset or for going idle next to them.
+const schema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	key     TEXT PRIMARY KEY,
+	data    TEXT NOT NULL,
+	updated INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS archived_sessions (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	key      TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	archived INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS archived_sessions_archived ON archived_sessions (archived);
+`
+
+// openDB opens the session database in dir, creating it when needed. The
+// CLI commands open it while the gateway runs, so writers wait for each
+// other instead of failing.
+func openDB(dir string) (*sql.DB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	dsn := filepath.Join(dir, dbFile) + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (sm *SessionManager) loadSessions() error {
+	rows, err := sm.db.Query(`SELECT data FROM sessions`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			continue
+		}
+		sm.sessions[session.Key] = &session
+	}
+	return rows.Err()
+}
+
+// writeSession stores a session, replacing what was stored for its key.
+func (sm *SessionManager) writeSession(session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = sm.db.Exec(`INSERT INTO sessions (key, data, updated) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET data = excluded.data, updated = excluded.updated`,
+		session.Key, string(data), session.Updated.UnixMilli())
+	return err
+}
+
+// writeArchive stores a copy of a session as archived at the given time.
+func (sm *SessionManager) writeArchive(session Session, archived time.Time) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = sm.db.Exec(`INSERT INTO archived_sessions (key, data, archived) VALUES (?, ?, ?)`,
+		session.Key, string(data), archived.UnixMilli())
+	return err
+}
+
+// Archived returns the archived copies of the session with key, oldest
+// first.
+func (sm *SessionManager) Archived(key string) ([]Session, error) {
+	if sm.db == nil {
+		return nil, nil
+	}
+	rows, err := sm.db.Query(`SELECT data FROM archived_sessions WHERE key = ? ORDER BY id`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archived []Session
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, err
+		}
+		archived = append(archived, session)
+	}
+	return archived, rows.Err()
+}
+
+// migrateFiles moves sessions that earlier versions saved as JSON files in
+// the storage directory, and their archives, into the database. Each file
+// is removed once its session is stored; sessions already in the database
+// win over files.
+func (sm *SessionManager) migrateFiles() error {
+	files, _ := filepath.Glob(filepath.Join(sm.storage, "*.json"))
+	for _, path := range files {
+		session, err := readSessionFile(path)
+		if err != nil {
+			logger.WarnCF("session", "Skipping unreadable session file",
+				map[string]any{"path": path, "error": err.Error()})
+			continue
+		}
+		if _, ok := sm.sessions[session.Key]; !ok {
+			if err := sm.writeSession(*session); err != nil {
+				return err
+			}
+			sm.sessions[session.Key] = session
+		}
+		_ = os.Remove(path)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(sm.storage, "archive", "*.json"))
+	for _, path := range archives {
+		session, err := readSessionFile(path)
+		if err != nil {
+			continue
+		}
+		archived := session.Updated
+		if info, err := os.Stat(path); err == nil {
+			archived = info.ModTime()
+		}
+		if err := sm.writeArchive(*session, archived); err != nil {
+			return err
+		}
+		_ = os.Remove(path)
+	}
+	_ = os.Remove(filepath.Join(sm.storage, "archive"))
+	return nil
+}
+
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	if session.Key == "" {
+		return nil, errors.New("no session key")
+	}
+	return &session, nil
+}
+
+// Close closes the session database. Sessions can't be saved afterwards.
+func (sm *SessionManager) Close() error {
+	if sm.db == nil {
+		return nil
+	}
+	return sm.db.Close()
+}
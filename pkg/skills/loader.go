@@ -58,6 +58,7 @@ type SkillsLoader struct {
 	workspaceSkills string // workspace skills (项目级别)
 	globalSkills    string // 全局 skills (~/.picoclaw/skills)
 	builtinSkills   string // 内置 skills
+	allowed         map[string]bool
 }
 
 func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string) *SkillsLoader {
@@ -69,7 +70,78 @@ func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string
 	}
 }
 
+// Allow limits the skills the loader lists and loads to names, as set in an
+// agent's skills config. An empty list allows all of them.
+func (sl *SkillsLoader) Allow(names []string) {
+	if len(names) == 0 {
+		sl.allowed = nil
+		return
+	}
+	sl.allowed = make(map[string]bool, len(names))
+	for _, name := range names {
+		sl.allowed[name] = true
+	}
+}
+
+func (sl *SkillsLoader) isAllowed(name string) bool {
+	return sl.allowed == nil || sl.allowed[name]
+}
+
 func (sl *SkillsLoader) ListSkills() []SkillInfo {
+	skills := sl.listAllSkills()
+	if sl.allowed == nil {
+		return skills
+	}
+	allowed := skills[:0]
+	for _, s := range skills {
+		if sl.isAllowed(s.Name) {
+			allowed = append(allowed, s)
+		}
+	}
+	return allowed
+}
+
+// SearchSkills returns the skills whose name or description contains every
+// word of query, or all of them for an empty query.
+func (sl *SkillsLoader) SearchSkills(query string) []SkillInfo {
+	words := strings.Fields(strings.ToLower(query))
+	var found []SkillInfo
+	for _, s := range sl.ListSkills() {
+		text := strings.ToLower(s.Name + " " + s.Description)
+		match := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// FindSkill returns the listed skill called name.
+func (sl *SkillsLoader) FindSkill(name string) (SkillInfo, bool) {
+	for _, s := range sl.ListSkills() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SkillInfo{}, false
+}
+
+// ReadSkill returns the instructions of a listed skill, without frontmatter.
+func (sl *SkillsLoader) ReadSkill(info SkillInfo) (string, error) {
+	content, err := os.ReadFile(info.Path)
+	if err != nil {
+		return "", err
+	}
+	return sl.stripFrontmatter(string(content)), nil
+}
+
+func (sl *SkillsLoader) listAllSkills() []SkillInfo {
 	skills := make([]SkillInfo, 0)
 
 	if sl.workspaceSkills != "" {
@@ -182,6 +254,10 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 }
 
 func (sl *SkillsLoader) LoadSkill(name string) (string, bool) {
+	if !sl.isAllowed(name) {
+		return "", false
+	}
+
 	// 1. 优先从 workspace skills 加载（项目级别）
 	if sl.workspaceSkills != "" {
 		skillFile := filepath.Join(sl.workspaceSkills, name, "SKILL.md")
@@ -225,6 +301,18 @@ func (sl *SkillsLoader) LoadSkillsForContext(skillNames []string) string {
 	return strings.Join(parts, "\n\n---\n\n")
 }
 
+// BuildSkillsIndex lists just the skill names, for a system prompt that
+// leaves descriptions and instructions to the list_skills and load_skill
+// tools.
+func (sl *SkillsLoader) BuildSkillsIndex() string {
+	allSkills := sl.ListSkills()
+	names := make([]string, len(allSkills))
+	for i, s := range allSkills {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 func (sl *SkillsLoader) BuildSkillsSummary() string {
 	allSkills := sl.ListSkills()
 	if len(allSkills) == 0 {
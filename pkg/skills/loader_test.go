@@ -1,6 +1,8 @@
 package skills
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -195,3 +197,45 @@ func TestStripFrontmatter(t *testing.T) {
 		})
 	}
 }
+
+func writeSkill(t *testing.T, dir, name, description string) {
+	t.Helper()
+	skillDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\nname: " + name + "\ndescription: " + description + "\n---\n\n# " + name + "\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchAndAllowSkills(t *testing.T) {
+	workspace := t.TempDir()
+	writeSkill(t, filepath.Join(workspace, "skills"), "weather", "Get the current weather and forecasts")
+	writeSkill(t, filepath.Join(workspace, "skills"), "github", "Work with GitHub issues and pull requests")
+	sl := NewSkillsLoader(workspace, "", "")
+
+	assert.Len(t, sl.SearchSkills(""), 2)
+	found := sl.SearchSkills("Pull Requests")
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "github", found[0].Name)
+	}
+	assert.Empty(t, sl.SearchSkills("weather github"))
+
+	info, ok := sl.FindSkill("weather")
+	assert.True(t, ok)
+	content, err := sl.ReadSkill(info)
+	assert.NoError(t, err)
+	assert.Equal(t, "# weather\n", content)
+
+	sl.Allow([]string{"weather"})
+	assert.Equal(t, "weather", sl.BuildSkillsIndex())
+	_, ok = sl.FindSkill("github")
+	assert.False(t, ok)
+	_, ok = sl.LoadSkill("github")
+	assert.False(t, ok)
+
+	sl.Allow(nil)
+	assert.Equal(t, "github, weather", sl.BuildSkillsIndex())
+}
@@ -0,0 +1,216 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Task priorities, most urgent first.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// doneRetention is how long completed tasks are kept.
+const doneRetention = 30 * 24 * time.Hour
+
+var priorityRank = map[string]int{PriorityHigh: 0, PriorityNormal: 1, PriorityLow: 2}
+
+// ValidPriority reports whether p is a known priority.
+func ValidPriority(p string) bool {
+	_, ok := priorityRank[p]
+	return ok
+}
+
+// Task is an item on the todo list. Due is a date in YYYY-MM-DD form.
+type Task struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Priority  string     `json:"priority"`
+	Due       string     `json:"due,omitempty"`
+	Created   time.Time  `json:"created"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// Overdue reports whether the task is open and was due before today.
+func (t Task) Overdue(now time.Time) bool {
+	return t.Completed == nil && t.Due != "" && t.Due < now.Format("2006-01-02")
+}
+
+type taskFile struct {
+	NextID int    `json:"next_id"`
+	Tasks  []Task `json:"tasks"`
+}
+
+// TaskList is a workspace's todo list, saved in state/tasks.json with the
+// same atomic writes as the rest of the state. Completed tasks are dropped
+// after 30 days.
+type TaskList struct {
+	path string
+	now  func() time.Time
+	mu   sync.Mutex
+	data taskFile
+}
+
+// NewTaskList loads the todo list of workspace.
+func NewTaskList(workspace string) (*TaskList, error) {
+	tl := &TaskList{
+		path: filepath.Join(workspace, "state", "tasks.json"),
+		now:  time.Now,
+		data: taskFile{NextID: 1},
+	}
+	data, err := os.ReadFile(tl.path)
+	if os.IsNotExist(err) {
+		return tl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+	if err := json.Unmarshal(data, &tl.data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tasks: %w", err)
+	}
+	return tl, nil
+}
+
+// Add puts a new open task on the list. An empty priority is normal.
+func (tl *TaskList) Add(title, priority, due string) (Task, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return Task{}, fmt.Errorf("task title is empty")
+	}
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	if !ValidPriority(priority) {
+		return Task{}, fmt.Errorf("unknown priority %q", priority)
+	}
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	task := Task{
+		ID:       tl.data.NextID,
+		Title:    title,
+		Priority: priority,
+		Due:      due,
+		Created:  tl.now(),
+	}
+	tl.data.NextID++
+	tl.data.Tasks = append(tl.data.Tasks, task)
+	return task, tl.save()
+}
+
+// Complete marks an open task done.
+func (tl *TaskList) Complete(id int) (Task, error) {
+	return tl.update(id, func(t *Task) error {
+		if t.Completed != nil {
+			return fmt.Errorf("task %d is already done", id)
+		}
+		now := tl.now()
+		t.Completed = &now
+		return nil
+	})
+}
+
+// SetPriority changes the priority of a task.
+func (tl *TaskList) SetPriority(id int, priority string) (Task, error) {
+	if !ValidPriority(priority) {
+		return Task{}, fmt.Errorf("unknown priority %q", priority)
+	}
+	return tl.update(id, func(t *Task) error {
+		t.Priority = priority
+		return nil
+	})
+}
+
+// Open returns the open tasks, most urgent first: by priority, then due
+// date, then age.
+func (tl *TaskList) Open() []Task {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	var open []Task
+	for _, t := range tl.data.Tasks {
+		if t.Completed == nil {
+			open = append(open, t)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		a, b := open[i], open[j]
+		if priorityRank[a.Priority] != priorityRank[b.Priority] {
+			return priorityRank[a.Priority] < priorityRank[b.Priority]
+		}
+		if a.Due != b.Due {
+			// Tasks without a due date go last
+			return b.Due == "" || (a.Due != "" && a.Due < b.Due)
+		}
+		return a.ID < b.ID
+	})
+	return open
+}
+
+// CompletedSince returns the tasks completed after since, in the order they
+// were done.
+func (tl *TaskList) CompletedSince(since time.Time) []Task {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	var done []Task
+	for _, t := range tl.data.Tasks {
+		if t.Completed != nil && t.Completed.After(since) {
+			done = append(done, t)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].Completed.Before(*done[j].Completed) })
+	return done
+}
+
+func (tl *TaskList) update(id int, fn func(*Task) error) (Task, error) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	for i := range tl.data.Tasks {
+		if tl.data.Tasks[i].ID != id {
+			continue
+		}
+		if err := fn(&tl.data.Tasks[i]); err != nil {
+			return Task{}, err
+		}
+		task := tl.data.Tasks[i]
+		return task, tl.save()
+	}
+	return Task{}, fmt.Errorf("no task with id %d", id)
+}
+
+// save drops old completed tasks and writes the list atomically; callers
+// hold mu.
+func (tl *TaskList) save() error {
+	cutoff := tl.now().Add(-doneRetention)
+	kept := tl.data.Tasks[:0]
+	for _, t := range tl.data.Tasks {
+		if t.Completed == nil || t.Completed.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	tl.data.Tasks = kept
+
+	data, err := json.MarshalIndent(tl.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tasks: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(tl.path), 0o755); err != nil {
+		return err
+	}
+	tempFile := tl.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tempFile, tl.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
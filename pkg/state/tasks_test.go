@@ -0,0 +1,78 @@
+package state
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskList(t *testing.T) {
+	workspace := t.TempDir()
+	tl, err := NewTaskList(workspace)
+	if err != nil {
+		t.Fatalf("NewTaskList: %v", err)
+	}
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tl.now = func() time.Time { return now }
+
+	tl.Add("Water plants", "", "")
+	tl.Add("Pay rent", PriorityHigh, "2026-03-15")
+	tl.Add("Call plumber", PriorityHigh, "2026-03-09")
+	tl.Add("Sort photos", PriorityLow, "")
+	for _, args := range [][2]string{{"", PriorityHigh}, {"x", "urgent"}} {
+		if _, err := tl.Add(args[0], args[1], ""); err == nil {
+			t.Errorf("Add(%q, %q) succeeded", args[0], args[1])
+		}
+	}
+
+	if got := titles(tl.Open()); got != "Call plumber,Pay rent,Water plants,Sort photos" {
+		t.Errorf("Open() = %s", got)
+	}
+	if open := tl.Open(); !open[0].Overdue(now) || open[1].Overdue(now) {
+		t.Error("only the plumber should be overdue")
+	}
+
+	if _, err := tl.SetPriority(4, PriorityHigh); err != nil {
+		t.Fatalf("SetPriority: %v", err)
+	}
+	if _, err := tl.Complete(3); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if _, err := tl.Complete(3); err == nil {
+		t.Error("completed a task twice")
+	}
+	if _, err := tl.Complete(42); err == nil {
+		t.Error("completed a missing task")
+	}
+
+	// The list survives a reload
+	tl, err = NewTaskList(workspace)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	tl.now = func() time.Time { return now }
+	if got := titles(tl.Open()); got != "Pay rent,Sort photos,Water plants" {
+		t.Errorf("Open() after reload = %s", got)
+	}
+	if got := titles(tl.CompletedSince(now.Add(-time.Hour))); got != "Call plumber" {
+		t.Errorf("CompletedSince() = %s", got)
+	}
+	if task, _ := tl.Add("New", "", ""); task.ID != 5 {
+		t.Errorf("new task id = %d, want 5", task.ID)
+	}
+
+	// Completed tasks are dropped after 30 days
+	now = now.AddDate(0, 0, 31)
+	tl.Complete(1)
+	if got := titles(tl.CompletedSince(time.Time{})); got != "Water plants" {
+		t.Errorf("completed tasks after a month = %s", got)
+	}
+}
+
+func titles(tasks []Task) string {
+	var out []string
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return strings.Join(out, ",")
+}
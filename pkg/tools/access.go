@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// RiskLevel rates what a tool can do, so that config can keep risky tools
+// out of conversations it doesn't trust.
+type RiskLevel int
+
+const (
+	// RiskLow tools only read public information, like web search.
+	RiskLow RiskLevel = iota + 1
+	// RiskMedium tools read the workspace or act on the user's behalf, like
+	// reading files or sending messages.
+	RiskMedium
+	// RiskHigh tools change the host: they write files, run code or drive
+	// hardware.
+	RiskHigh
+)
+
+func (r RiskLevel) String() string {
+	switch r {
+	case RiskLow:
+		return "low"
+	case RiskMedium:
+		return "medium"
+	case RiskHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// ParseRiskLevel reads a level from config.
+func ParseRiskLevel(s string) (RiskLevel, bool) {
+	switch s {
+	case "low":
+		return RiskLow, true
+	case "medium":
+		return RiskMedium, true
+	case "high":
+		return RiskHigh, true
+	}
+	return 0, false
+}
+
+// RiskyTool is implemented by tools that declare their risk level.
+type RiskyTool interface {
+	Tool
+	Risk() RiskLevel
+}
+
+// ToolRisk returns the tool's declared risk level. Tools that don't declare
+// one count as high risk.
+func ToolRisk(tool Tool) RiskLevel {
+	if rt, ok := tool.(RiskyTool); ok {
+		return rt.Risk()
+	}
+	return RiskHigh
+}
+
+// AccessPolicy decides which tools a conversation may use, following
+// tools.access in the config.
+type AccessPolicy struct {
+	defaultRule accessRule
+	rules       map[string]accessRule
+}
+
+type accessRule struct {
+	maxRisk     RiskLevel
+	allow, deny []string
+}
+
+func newAccessRule(cfg config.ToolAccessRule) accessRule {
+	maxRisk, ok := ParseRiskLevel(cfg.MaxRisk)
+	if !ok {
+		maxRisk = RiskHigh // no limit
+	}
+	return accessRule{maxRisk: maxRisk, allow: cfg.Allow, deny: cfg.Deny}
+}
+
+func (r accessRule) allows(tool Tool) bool {
+	name := tool.Name()
+	if slices.Contains(r.deny, name) {
+		return false
+	}
+	if len(r.allow) > 0 && !slices.Contains(r.allow, name) {
+		return false
+	}
+	return ToolRisk(tool) <= r.maxRisk
+}
+
+func NewAccessPolicy(cfg config.ToolAccessConfig) *AccessPolicy {
+	p := &AccessPolicy{
+		defaultRule: newAccessRule(cfg.Default),
+		rules:       make(map[string]accessRule, len(cfg.Rules)),
+	}
+	for key, rule := range cfg.Rules {
+		p.rules[key] = newAccessRule(rule)
+	}
+	return p
+}
+
+// Allows reports whether the conversation may use the tool. A rule for the
+// chat wins over one for its channel. A nil policy allows every tool.
+func (p *AccessPolicy) Allows(tool Tool, channel, chatID string) bool {
+	if p == nil {
+		return true
+	}
+	if rule, ok := p.rules[channel+":"+chatID]; ok && chatID != "" {
+		return rule.allows(tool)
+	}
+	if rule, ok := p.rules[channel]; ok && channel != "" {
+		return rule.allows(tool)
+	}
+	return p.defaultRule.allows(tool)
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// unratedTool doesn't declare a risk level.
+type unratedTool struct{}
+
+func (t *unratedTool) Name() string               { return "unrated" }
+func (t *unratedTool) Description() string        { return "test tool" }
+func (t *unratedTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *unratedTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	return NewToolResult("ok")
+}
+
+func newAccessTestRegistry() *ToolRegistry {
+	r := NewToolRegistry()
+	r.Register(NewWebFetchTool(1000))                 // low
+	r.Register(NewReadFileTool("", false))            // medium
+	r.Register(NewExecToolWithConfig("", false, nil)) // high
+	r.Register(&unratedTool{})                        // undeclared
+	r.SetAccessPolicy(NewAccessPolicy(config.ToolAccessConfig{
+		Default: config.ToolAccessRule{MaxRisk: "medium"},
+		Rules: map[string]config.ToolAccessRule{
+			"wecom":              {MaxRisk: "low"},
+			"telegram":           {Deny: config.FlexibleStringSlice{"read_file"}},
+			"telegram:42":        {Allow: config.FlexibleStringSlice{"exec", "read_file"}},
+			"discord:allow_more": {Allow: config.FlexibleStringSlice{"exec"}, MaxRisk: "medium"},
+		},
+	}))
+	return r
+}
+
+func TestAccessPolicy_Rules(t *testing.T) {
+	r := newAccessTestRegistry()
+
+	tests := []struct {
+		channel, chatID string
+		want            []string
+	}{
+		{"wecom", "room", []string{"web_fetch"}},
+		{"slack", "C1", []string{"read_file", "web_fetch"}},
+		{"", "", []string{"read_file", "web_fetch"}},
+		{"telegram", "7", []string{"exec", "unrated", "web_fetch"}},
+		{"telegram", "42", []string{"exec", "read_file"}},
+		{"discord", "allow_more", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, def := range r.ToProviderDefsFor(tt.channel, tt.chatID) {
+			got = append(got, def.Function.Name)
+		}
+		sort.Strings(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("tools for %s:%s = %v, want %v", tt.channel, tt.chatID, got, tt.want)
+		}
+		if summaries := r.GetSummariesFor(tt.channel, tt.chatID); len(summaries) != len(tt.want) {
+			t.Errorf("summaries for %s:%s = %v, want %d", tt.channel, tt.chatID, summaries, len(tt.want))
+		}
+	}
+
+	if n := len(r.ToProviderDefs()); n != 4 {
+		t.Errorf("ToProviderDefs() lists %d tools, want all 4", n)
+	}
+}
+
+func TestToolRegistry_RefusesDisallowedTool(t *testing.T) {
+	r := newAccessTestRegistry()
+
+	result := r.ExecuteWithContext(context.Background(), "exec", map[string]any{"command": "echo hi"},
+		"wecom", "room", nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "not available in this conversation") {
+		t.Errorf("exec from wecom: %q", result.ForLLM)
+	}
+
+	result = r.ExecuteWithContext(context.Background(), "exec", map[string]any{"command": "echo hi"},
+		"telegram", "42", nil)
+	if result.IsError || !strings.Contains(result.ForLLM, "hi") {
+		t.Errorf("exec from the owner's chat: %q", result.ForLLM)
+	}
+}
+
+func TestToolRisk(t *testing.T) {
+	if got := ToolRisk(NewExecToolWithConfig("", false, nil)); got != RiskHigh {
+		t.Errorf("exec risk = %v", got)
+	}
+	if got := ToolRisk(&unratedTool{}); got != RiskHigh {
+		t.Errorf("undeclared risk = %v, want high", got)
+	}
+	if (*AccessPolicy)(nil).Allows(&unratedTool{}, "wecom", "1") != true {
+		t.Error("nil policy should allow every tool")
+	}
+}
+
+func TestToolRegistry_AuditLog(t *testing.T) {
+	path := audit.LogPath(t.TempDir())
+	r := NewToolRegistry()
+	r.Register(&unratedTool{})
+	r.Register(NewExecToolWithConfig("", false, nil))
+	r.SetAccessPolicy(NewAccessPolicy(config.ToolAccessConfig{
+		Default: config.ToolAccessRule{Deny: config.FlexibleStringSlice{"exec"}},
+	}))
+	r.SetAuditLog(audit.New(path, 0))
+
+	ctx := audit.WithActor(context.Background(), audit.Actor{Agent: "main", Session: "wecom:room", Sender: "7"})
+	r.ExecuteWithContext(ctx, "unrated", map[string]any{"x": "y"}, "telegram", "42", nil)
+	r.ExecuteWithContext(ctx, "exec", map[string]any{"command": "ls"}, "wecom", "room", nil)
+	r.ExecuteWithContext(ctx, "missing", nil, "slack", "C1", nil)
+
+	entries, err := audit.Read(path, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("recorded %d calls, want 3", len(entries))
+	}
+	got := entries[0]
+	if got.Tool != "unrated" || got.Status != audit.StatusOK || got.Args["x"] != "y" ||
+		got.Agent != "main" || got.Session != "wecom:room" || got.Sender != "7" ||
+		got.Channel != "telegram" || got.ChatID != "42" || got.Preview != "ok" || got.ResultSHA == "" {
+		t.Errorf("entry = %+v", got)
+	}
+	if entries[1].Status != audit.StatusDenied || entries[2].Status != audit.StatusNotFound {
+		t.Errorf("statuses = %q, %q; want denied, not_found", entries[1].Status, entries[2].Status)
+	}
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TaskQueue runs the jobs the background_task tool hands off, outside the
+// conversation that started them.
+type TaskQueue interface {
+	// Submit queues task for the chat and returns what to tell the model.
+	Submit(ctx context.Context, task, label, channel, chatID string) (string, error)
+	// List describes the chat's tasks.
+	List(channel, chatID string) string
+	// Cancel stops one of the chat's tasks, queued or running.
+	Cancel(channel, chatID, id string) (string, error)
+}
+
+// BackgroundTaskTool lets the agent queue long jobs, such as research that
+// ends in a report, to run in the background while the conversation goes
+// on. The chat hears how a task is doing and gets its result when it ends.
+type BackgroundTaskTool struct {
+	queue TaskQueue
+
+	mu      sync.RWMutex
+	channel string
+	chatID  string
+}
+
+func NewBackgroundTaskTool(queue TaskQueue) *BackgroundTaskTool {
+	return &BackgroundTaskTool{queue: queue}
+}
+
+func (t *BackgroundTaskTool) Name() string {
+	return "background_task"
+}
+
+func (t *BackgroundTaskTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *BackgroundTaskTool) Description() string {
+	return "Run a long job in the background, e.g. \"research X and write a report\", so the conversation can go " +
+		"on meanwhile. The task runs on its own with your tools and can't ask the user questions, so describe " +
+		"it fully. The user gets progress updates and the result in this chat when it ends; you don't need " +
+		"to wait or check. Also lists this chat's tasks or cancels one."
+}
+
+func (t *BackgroundTaskTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"start", "list", "cancel"},
+				"description": "start a task (default), list this chat's tasks, or cancel one",
+			},
+			"task": map[string]any{
+				"type":        "string",
+				"description": "For start: everything needed to do the job and what the result should be",
+			},
+			"label": map[string]any{
+				"type":        "string",
+				"description": "For start: a short name for the task, shown in updates",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "For cancel: the task's ID",
+			},
+		},
+	}
+}
+
+func (t *BackgroundTaskTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *BackgroundTaskTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.mu.RLock()
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
+	t.mu.RUnlock()
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "start":
+		task, _ := args["task"].(string)
+		if strings.TrimSpace(task) == "" {
+			return ErrorResult("task is required")
+		}
+		label, _ := args["label"].(string)
+		msg, err := t.queue.Submit(ctx, strings.TrimSpace(task), strings.TrimSpace(label), channel, chatID)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to start the task: %v", err))
+		}
+		return NewToolResult(msg)
+	case "list":
+		return NewToolResult(t.queue.List(channel, chatID))
+	case "cancel":
+		id, _ := args["id"].(string)
+		if id == "" {
+			return ErrorResult("id is required")
+		}
+		msg, err := t.queue.Cancel(channel, chatID, strings.TrimPrefix(strings.TrimSpace(id), "#"))
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		return NewToolResult(msg)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeTaskQueue struct {
+	submitted []string
+	cancelled []string
+}
+
+func (q *fakeTaskQueue) Submit(ctx context.Context, task, label, channel, chatID string) (string, error) {
+	q.submitted = append(q.submitted, channel+"/"+chatID+"/"+label+"/"+task)
+	return "Started task 1", nil
+}
+
+func (q *fakeTaskQueue) List(channel, chatID string) string {
+	return "tasks of " + channel + "/" + chatID
+}
+
+func (q *fakeTaskQueue) Cancel(channel, chatID, id string) (string, error) {
+	if id != "1" {
+		return "", errors.New("no task " + id)
+	}
+	q.cancelled = append(q.cancelled, id)
+	return "Cancelling task 1", nil
+}
+
+func TestBackgroundTaskTool(t *testing.T) {
+	queue := &fakeTaskQueue{}
+	tool := NewBackgroundTaskTool(queue)
+	tool.SetContext("telegram", "42")
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"task": "  research solar panels ", "label": "solar"})
+	if result.IsError {
+		t.Fatalf("start: %s", result.ForLLM)
+	}
+	if len(queue.submitted) != 1 || queue.submitted[0] != "telegram/42/solar/research solar panels" {
+		t.Errorf("submitted = %q", queue.submitted)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "start"}); !result.IsError {
+		t.Error("start without a task was accepted")
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "list"}); result.ForLLM != "tasks of telegram/42" {
+		t.Errorf("list = %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "cancel", "id": "#1"}); result.IsError {
+		t.Errorf("cancel: %s", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "cancel", "id": "7"}); !strings.Contains(result.ForLLM,
+		"no task 7") {
+		t.Errorf("cancel of an unknown task = %q", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "pause"}); !result.IsError {
+		t.Error("unknown action was accepted")
+	}
+}
@@ -11,12 +11,36 @@ type Tool interface {
 }
 
 // ContextualTool is an optional interface that tools can implement
-// to receive the current message context (channel, chatID)
+// to receive the current message context (channel, chatID). The chat a
+// call's context names (see WithChat) comes first; SetContext sets the one
+// used when it names none.
 type ContextualTool interface {
 	Tool
 	SetContext(channel, chatID string)
 }
 
+type chatKey struct{}
+
+type chatRef struct {
+	channel string
+	chatID  string
+}
+
+// WithChat returns a context whose tool calls are made for the chat with
+// chatID on channel. Turns running at the same time each carry their own.
+func WithChat(ctx context.Context, channel, chatID string) context.Context {
+	return context.WithValue(ctx, chatKey{}, chatRef{channel: channel, chatID: chatID})
+}
+
+// ChatFrom returns the chat ctx's tool calls are made for, or channel and
+// chatID when it names none.
+func ChatFrom(ctx context.Context, channel, chatID string) (string, string) {
+	if chat, ok := ctx.Value(chatKey{}).(chatRef); ok {
+		return chat.channel, chat.chatID
+	}
+	return channel, chatID
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// BroadcastCallback delivers content to every target.
+type BroadcastCallback func(ctx context.Context, targets []string, content string) error
+
+// BroadcastTool sends one message to the configured broadcast targets, e.g.
+// a digest produced by a scheduled task.
+type BroadcastTool struct {
+	targets  []string
+	callback BroadcastCallback
+}
+
+func NewBroadcastTool(targets []string, callback BroadcastCallback) *BroadcastTool {
+	return &BroadcastTool{targets: targets, callback: callback}
+}
+
+func (t *BroadcastTool) Name() string {
+	return "broadcast"
+}
+
+func (t *BroadcastTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *BroadcastTool) Description() string {
+	return fmt.Sprintf("Send the same message to all %d configured broadcast recipients across channels. "+
+		"Use it for announcements and digests meant for everyone, not for replying to the current user.",
+		len(t.targets))
+}
+
+func (t *BroadcastTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The message to broadcast",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *BroadcastTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, _ := args["content"].(string)
+	if content == "" {
+		return ErrorResult("content is required")
+	}
+	if len(t.targets) == 0 {
+		return ErrorResult("No broadcast targets configured")
+	}
+
+	if err := t.callback(ctx, t.targets, content); err != nil {
+		return &ToolResult{
+			ForLLM:  fmt.Sprintf("broadcast partly failed: %v", err),
+			IsError: true,
+			Err:     err,
+		}
+	}
+	return NewToolResult(fmt.Sprintf("Broadcast sent to %d recipients", len(t.targets)))
+}
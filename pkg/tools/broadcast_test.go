@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBroadcastTool_Execute(t *testing.T) {
+	var gotTargets []string
+	var gotContent string
+	send := func(_ context.Context, targets []string, content string) error {
+		gotTargets, gotContent = targets, content
+		return nil
+	}
+	tool := NewBroadcastTool([]string{"telegram:1", "slack:C1"}, send)
+
+	result := tool.Execute(context.Background(), map[string]any{"content": "Daily digest"})
+	if result.IsError || result.ForLLM != "Broadcast sent to 2 recipients" {
+		t.Errorf("Execute() = %+v", result)
+	}
+	if len(gotTargets) != 2 || gotContent != "Daily digest" {
+		t.Errorf("callback got targets=%v content=%q", gotTargets, gotContent)
+	}
+
+	if result := tool.Execute(context.Background(), map[string]any{}); !result.IsError {
+		t.Error("expected error without content")
+	}
+}
+
+func TestBroadcastTool_ReportsFailures(t *testing.T) {
+	sendErr := errors.New("channel slack is not running")
+	tool := NewBroadcastTool([]string{"slack:C1"}, func(context.Context, []string, string) error {
+		return sendErr
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"content": "hi"})
+	if !result.IsError || !errors.Is(result.Err, sendErr) {
+		t.Errorf("Execute() = %+v, want error result", result)
+	}
+}
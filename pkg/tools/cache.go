@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ResultCache keeps successful results of the tools tools.cache lists,
+// keyed on the tool and its arguments. One cache can serve the registries
+// of several agents, so a search one agent ran answers the same search
+// from another, or from a later scheduled job.
+type ResultCache struct {
+	ttl        map[string]time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cachedResult
+}
+
+type cachedResult struct {
+	result   ToolResult
+	storedAt time.Time
+	ttl      time.Duration
+}
+
+// NewResultCache returns the cache tools.cache describes, or nil when it is
+// off or lists no tools.
+func NewResultCache(cfg config.ToolCacheConfig) *ResultCache {
+	if !cfg.Enabled {
+		return nil
+	}
+	c := &ResultCache{
+		ttl:        make(map[string]time.Duration),
+		maxEntries: cfg.MaxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*cachedResult),
+	}
+	for name, minutes := range cfg.TTLMinutes {
+		if minutes > 0 {
+			c.ttl[name] = time.Duration(minutes) * time.Minute
+		}
+	}
+	if len(c.ttl) == 0 {
+		return nil
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = 500
+	}
+	return c
+}
+
+// key identifies a call. Map keys are sorted when marshalled, so the same
+// arguments always give the same key. ok is false for tools that aren't
+// cached.
+func (c *ResultCache) key(name string, args map[string]any) (key string, ok bool) {
+	if c == nil {
+		return "", false
+	}
+	if _, cached := c.ttl[name]; !cached {
+		return "", false
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return name + "\x00" + string(data), true
+}
+
+// get returns a copy of the cached result of the call, if there is a
+// fresh one.
+func (c *ResultCache) get(name string, args map[string]any) (*ToolResult, bool) {
+	key, ok := c.key(name, args)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().Sub(entry.storedAt) > entry.ttl {
+		delete(c.entries, key)
+		return nil, false
+	}
+	result := entry.result
+	return &result, true
+}
+
+// put caches result if the tool is cached and the call succeeded, making
+// room by dropping expired results or, failing that, the oldest one.
+func (c *ResultCache) put(name string, args map[string]any, result *ToolResult) {
+	if result == nil || result.IsError || result.Async {
+		return
+	}
+	key, ok := c.key(name, args)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		oldest := ""
+		for k, entry := range c.entries {
+			if now.Sub(entry.storedAt) > entry.ttl {
+				delete(c.entries, k)
+			} else if oldest == "" || entry.storedAt.Before(c.entries[oldest].storedAt) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = &cachedResult{result: *result, storedAt: now, ttl: c.ttl[name]}
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// countingTool answers with how often it ran, failing when asked to.
+type countingTool struct {
+	name string
+	runs int
+}
+
+func (t *countingTool) Name() string        { return t.name }
+func (t *countingTool) Description() string { return "Counts its runs" }
+func (t *countingTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{"type": "string"},
+			"fail":  map[string]any{"type": "boolean"},
+		},
+	}
+}
+
+func (t *countingTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.runs++
+	if fail, _ := args["fail"].(bool); fail {
+		return ErrorResult("failed")
+	}
+	return NewToolResult(fmt.Sprintf("run %d", t.runs))
+}
+
+func TestRegistry_ServesCachedResults(t *testing.T) {
+	cache := NewResultCache(config.ToolCacheConfig{
+		Enabled:    true,
+		TTLMinutes: map[string]int{"search": 10},
+	})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	search := &countingTool{name: "search"}
+	clock := &countingTool{name: "clock"}
+	registry := NewToolRegistry()
+	registry.Register(search)
+	registry.Register(clock)
+	registry.SetResultCache(cache)
+	// Another agent's registry shares the cache
+	other := NewToolRegistry()
+	other.Register(search)
+	other.SetResultCache(cache)
+
+	ctx := context.Background()
+	first := registry.Execute(ctx, "search", map[string]any{"query": "go"})
+	again := other.Execute(ctx, "search", map[string]any{"query": "go"})
+	if first.ForLLM != "run 1" || again.ForLLM != "run 1" || search.runs != 1 {
+		t.Errorf("repeated call = %q, %q after %d runs; want one run", first.ForLLM, again.ForLLM, search.runs)
+	}
+	if got := registry.Execute(ctx, "search", map[string]any{"query": "rust"}); got.ForLLM != "run 2" {
+		t.Errorf("new arguments = %q, want a fresh run", got.ForLLM)
+	}
+
+	registry.Execute(ctx, "clock", map[string]any{})
+	registry.Execute(ctx, "clock", map[string]any{})
+	if clock.runs != 2 {
+		t.Errorf("uncached tool ran %d times, want 2", clock.runs)
+	}
+
+	registry.Execute(ctx, "search", map[string]any{"query": "x", "fail": true})
+	registry.Execute(ctx, "search", map[string]any{"query": "x", "fail": true})
+	if search.runs != 4 {
+		t.Errorf("failures were cached: %d runs, want 4", search.runs)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if got := registry.Execute(ctx, "search", map[string]any{"query": "go"}); got.ForLLM != "run 5" {
+		t.Errorf("expired result = %q, want a fresh run", got.ForLLM)
+	}
+}
+
+func TestResultCache_Evicts(t *testing.T) {
+	cache := NewResultCache(config.ToolCacheConfig{
+		Enabled:    true,
+		TTLMinutes: map[string]int{"search": 10},
+		MaxEntries: 2,
+	})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	for i := range 3 {
+		cache.put("search", map[string]any{"query": i}, NewToolResult("r"))
+		now = now.Add(time.Second)
+	}
+	if _, ok := cache.get("search", map[string]any{"query": 0}); ok {
+		t.Error("oldest result wasn't evicted")
+	}
+	if _, ok := cache.get("search", map[string]any{"query": 2}); !ok {
+		t.Error("newest result was evicted")
+	}
+}
+
+func TestNewResultCache_Off(t *testing.T) {
+	if c := NewResultCache(config.ToolCacheConfig{TTLMinutes: map[string]int{"search": 10}}); c != nil {
+		t.Error("disabled cache was created")
+	}
+	if c := NewResultCache(config.ToolCacheConfig{Enabled: true}); c != nil {
+		t.Error("cache without tools was created")
+	}
+}
@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const clipboardTimeout = 5 * time.Second
+
+// clipboardCommands are the programs that read and write the clipboard on
+// one platform; the text goes through their stdout and stdin.
+type clipboardCommands struct {
+	read, write []string
+}
+
+// errNoClipboard is returned where no clipboard program can be found, such
+// as on a server without a display.
+var errNoClipboard = errors.New(
+	"no clipboard is available; on Linux this needs a desktop session and wl-clipboard, xclip or xsel")
+
+// ClipboardTool reads and replaces the text on the system clipboard, so
+// that "fix the text on my clipboard" works when picoclaw runs on a desktop.
+type ClipboardTool struct {
+	maxChars int
+	commands func() (clipboardCommands, error)
+}
+
+func NewClipboardTool(cfg config.ClipboardToolsConfig) *ClipboardTool {
+	maxChars := cfg.MaxChars
+	if maxChars <= 0 {
+		maxChars = 20000
+	}
+	return &ClipboardTool{maxChars: maxChars, commands: systemClipboard}
+}
+
+func (t *ClipboardTool) Name() string {
+	return "clipboard"
+}
+
+// Risk is high: what the agent copies may be pasted into a terminal.
+func (t *ClipboardTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
+func (t *ClipboardTool) Description() string {
+	return "Read the text on the user's clipboard, or replace it with new text. " +
+		"Use when the user refers to what they copied or asks for something they can paste"
+}
+
+func (t *ClipboardTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"read", "write"},
+				"description": "read returns the clipboard text; write replaces it with text",
+			},
+			"text": map[string]any{
+				"type":        "string",
+				"description": "Text to put on the clipboard, for write",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ClipboardTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	commands, err := t.commands()
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, clipboardTimeout)
+	defer cancel()
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "read":
+		return t.read(ctx, commands.read)
+	case "write":
+		text, ok := args["text"].(string)
+		if !ok {
+			return ErrorResult("text is required for write")
+		}
+		return t.write(ctx, commands.write, text)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q; use read or write", action))
+	}
+}
+
+func (t *ClipboardTool) read(ctx context.Context, argv []string) *ToolResult {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// wl-paste fails rather than print nothing when the clipboard is empty
+		if strings.Contains(stderr.String(), "Nothing is copied") {
+			return NewToolResult("The clipboard is empty")
+		}
+		err = fmt.Errorf("%s: %v %s", argv[0], err, strings.TrimSpace(stderr.String()))
+		return ErrorResult(fmt.Sprintf("failed to read the clipboard: %v", err)).WithError(err)
+	}
+
+	text := strings.ToValidUTF8(string(out), "�")
+	if strings.TrimSpace(text) == "" {
+		return NewToolResult("The clipboard is empty")
+	}
+	if n := utf8.RuneCountInString(text); n > t.maxChars {
+		text = string([]rune(text)[:t.maxChars]) +
+			fmt.Sprintf("\n... (clipboard truncated, %d of %d characters shown)", t.maxChars, n)
+	}
+	return NewToolResult(text)
+}
+
+func (t *ClipboardTool) write(ctx context.Context, argv []string, text string) *ToolResult {
+	// xclip and wl-copy stay in the background to serve the clipboard, so
+	// their output isn't captured: waiting on it would block until they exit.
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("%s: %w", argv[0], err)
+		return ErrorResult(fmt.Sprintf("failed to write the clipboard: %v", err)).WithError(err)
+	}
+	return NewToolResult(fmt.Sprintf("Copied %d characters to the clipboard", utf8.RuneCountInString(text)))
+}
+
+// systemClipboard picks the clipboard programs for this platform. On Linux
+// and the BSDs it prefers wl-clipboard under Wayland, then xclip or xsel
+// under X11.
+func systemClipboard() (clipboardCommands, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return clipboardCommands{read: []string{"pbpaste"}, write: []string{"pbcopy"}}, nil
+	case "windows":
+		// PowerShell's pipes default to the console code page
+		const utf8Setup = "[Console]::InputEncoding = [Console]::OutputEncoding = [Text.Encoding]::UTF8; "
+		return clipboardCommands{
+			read: []string{"powershell", "-NoProfile", "-Command", utf8Setup + "Get-Clipboard -Raw"},
+			write: []string{"powershell", "-NoProfile", "-Command",
+				utf8Setup + "Set-Clipboard -Value ([Console]::In.ReadToEnd())"},
+		}, nil
+	}
+
+	candidates := []struct {
+		display string
+		cmds    clipboardCommands
+	}{
+		{"WAYLAND_DISPLAY", clipboardCommands{
+			read: []string{"wl-paste", "--no-newline"}, write: []string{"wl-copy"},
+		}},
+		{"DISPLAY", clipboardCommands{
+			read:  []string{"xclip", "-selection", "clipboard", "-out"},
+			write: []string{"xclip", "-selection", "clipboard", "-in"},
+		}},
+		{"DISPLAY", clipboardCommands{
+			read: []string{"xsel", "--clipboard", "--output"}, write: []string{"xsel", "--clipboard", "--input"},
+		}},
+	}
+	for _, c := range candidates {
+		if os.Getenv(c.display) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(c.cmds.read[0]); err == nil {
+			return c.cmds, nil
+		}
+	}
+	return clipboardCommands{}, errNoClipboard
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestClipboardTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh and cat as the clipboard")
+	}
+	board := filepath.Join(t.TempDir(), "clipboard")
+	tool := NewClipboardTool(config.ClipboardToolsConfig{MaxChars: 12})
+	tool.commands = func() (clipboardCommands, error) {
+		return clipboardCommands{
+			read:  []string{"sh", "-c", `cat "$0" 2>/dev/null || true`, board},
+			write: []string{"sh", "-c", `cat > "$0"`, board},
+		}, nil
+	}
+	ctx := context.Background()
+
+	if result := tool.Execute(ctx, map[string]any{"action": "read"}); result.ForLLM != "The clipboard is empty" {
+		t.Errorf("empty read = %q", result.ForLLM)
+	}
+	result := tool.Execute(ctx, map[string]any{"action": "write", "text": "Grüße, world"})
+	if result.IsError || result.ForLLM != "Copied 12 characters to the clipboard" {
+		t.Fatalf("write = %q", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "read"}); result.ForLLM != "Grüße, world" {
+		t.Errorf("read = %q", result.ForLLM)
+	}
+
+	tool.Execute(ctx, map[string]any{"action": "write", "text": "a much longer sentence"})
+	result = tool.Execute(ctx, map[string]any{"action": "read"})
+	if !strings.HasPrefix(result.ForLLM, "a much longe\n") || !strings.Contains(result.ForLLM, "12 of 22 characters") {
+		t.Errorf("long read = %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "write"}); !result.IsError {
+		t.Errorf("write without text = %q", result.ForLLM)
+	}
+	tool.commands = func() (clipboardCommands, error) { return clipboardCommands{}, errNoClipboard }
+	if result := tool.Execute(ctx, map[string]any{"action": "read"}); !result.IsError {
+		t.Errorf("read without a clipboard = %q", result.ForLLM)
+	}
+}
@@ -9,12 +9,15 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/usertime"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // JobExecutor is the interface for executing cron jobs through the agent
 type JobExecutor interface {
 	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
+	// ExecuteTool calls one of the agent's tools as if from the given chat.
+	ExecuteTool(ctx context.Context, name string, args map[string]any, channel, chatID string) *ToolResult
 }
 
 // CronTool provides scheduling capabilities for the agent
@@ -49,9 +52,19 @@ func (t *CronTool) Name() string {
 	return "cron"
 }
 
+func (t *CronTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 // Description returns the tool description
 func (t *CronTool) Description() string {
-	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly."
+	return "Schedule reminders, tasks, or system commands. " +
+		"IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. " +
+		"Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). " +
+		"Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). " +
+		"Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly, " +
+		"or 'tool' with 'tool_args' to call another tool on schedule. " +
+		"Results go to this chat unless 'channel' and 'chat_id' name another."
 }
 
 // Parameters returns the tool parameters schema
@@ -84,6 +97,26 @@ func (t *CronTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Cron expression for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Use this for complex recurring schedules.",
 			},
+			"tz": map[string]any{
+				"type":        "string",
+				"description": "Optional: IANA time zone for cron_expr (e.g., 'Europe/Berlin'). Default: the user's time zone.",
+			},
+			"tool": map[string]any{
+				"type":        "string",
+				"description": "Optional: tool to call on schedule instead of prompting the agent; its result is sent.",
+			},
+			"tool_args": map[string]any{
+				"type":        "object",
+				"description": "Arguments for 'tool'.",
+			},
+			"channel": map[string]any{
+				"type":        "string",
+				"description": "Optional: channel to deliver results to (e.g., 'telegram'). Default: the current one.",
+			},
+			"chat_id": map[string]any{
+				"type":        "string",
+				"description": "Chat on 'channel' to deliver results to. Required when 'channel' is set.",
+			},
 			"job_id": map[string]any{
 				"type":        "string",
 				"description": "Job ID (for remove/enable/disable)",
@@ -114,7 +147,7 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 
 	switch action {
 	case "add":
-		return t.addJob(args)
+		return t.addJob(ctx, args)
 	case "list":
 		return t.listJobs()
 	case "remove":
@@ -128,12 +161,18 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
-func (t *CronTool) addJob(args map[string]any) *ToolResult {
+func (t *CronTool) addJob(ctx context.Context, args map[string]any) *ToolResult {
 	t.mu.RLock()
-	channel := t.channel
-	chatID := t.chatID
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
 	t.mu.RUnlock()
 
+	if target, _ := args["channel"].(string); target != "" {
+		to, _ := args["chat_id"].(string)
+		if to == "" {
+			return ErrorResult("chat_id is required when channel is set")
+		}
+		channel, chatID = target, to
+	}
 	if channel == "" || chatID == "" {
 		return ErrorResult("no session context (channel/chat_id not set). Use this tool in an active conversation.")
 	}
@@ -164,9 +203,19 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 			EveryMS: &everyMS,
 		}
 	} else if hasCron {
+		tz, _ := args["tz"].(string)
+		if tz != "" {
+			if _, err := usertime.Load(tz); err != nil {
+				return ErrorResult(fmt.Sprintf("unknown time zone %q", tz))
+			}
+		} else if loc := usertime.Location(ctx); loc != time.Local {
+			// The user's, so "daily at 9" stays at 9 for them
+			tz = loc.String()
+		}
 		schedule = cron.CronSchedule{
 			Kind: "cron",
 			Expr: cronExpr,
+			TZ:   tz,
 		}
 	} else {
 		return ErrorResult("one of at_seconds, every_seconds, or cron_expr is required")
@@ -179,7 +228,12 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 	}
 
 	command, _ := args["command"].(string)
-	if command != "" {
+	toolName, _ := args["tool"].(string)
+	toolArgs, _ := args["tool_args"].(map[string]any)
+	if toolName == t.Name() {
+		return ErrorResult("cron jobs cannot call the cron tool")
+	}
+	if command != "" || toolName != "" {
 		// Commands must be processed by agent/exec tool, so deliver must be false (or handled specifically)
 		// Actually, let's keep deliver=false to let the system know it's not a simple chat message
 		// But for our new logic in ExecuteJob, we can handle it regardless of deliver flag if Payload.Command is set.
@@ -202,8 +256,13 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
-	if command != "" {
+	if command != "" || toolName != "" {
 		job.Payload.Command = command
+		if toolName != "" {
+			job.Payload.Kind = "tool"
+			job.Payload.Tool = toolName
+			job.Payload.Args = toolArgs
+		}
 		// Need to save the updated payload
 		t.cronService.UpdateJob(job)
 	}
@@ -225,11 +284,17 @@ func (t *CronTool) listJobs() *ToolResult {
 			scheduleInfo = fmt.Sprintf("every %ds", *j.Schedule.EveryMS/1000)
 		} else if j.Schedule.Kind == "cron" {
 			scheduleInfo = j.Schedule.Expr
+			if j.Schedule.TZ != "" {
+				scheduleInfo += " " + j.Schedule.TZ
+			}
 		} else if j.Schedule.Kind == "at" {
 			scheduleInfo = "one-time"
 		} else {
 			scheduleInfo = "unknown"
 		}
+		if j.Source == cron.SourceConfig {
+			scheduleInfo += ", from config"
+		}
 		result += fmt.Sprintf("- %s (id: %s, %s)\n", j.Name, j.ID, scheduleInfo)
 	}
 
@@ -241,6 +306,9 @@ func (t *CronTool) removeJob(args map[string]any) *ToolResult {
 	if !ok || jobID == "" {
 		return ErrorResult("job_id is required for remove")
 	}
+	if t.isConfigJob(jobID) {
+		return ErrorResult(fmt.Sprintf("Job %s is defined in the config; disable it instead", jobID))
+	}
 
 	if t.cronService.RemoveJob(jobID) {
 		return SilentResult(fmt.Sprintf("Cron job removed: %s", jobID))
@@ -266,6 +334,15 @@ func (t *CronTool) enableJob(args map[string]any, enable bool) *ToolResult {
 	return SilentResult(fmt.Sprintf("Cron job '%s' %s", job.Name, status))
 }
 
+func (t *CronTool) isConfigJob(jobID string) bool {
+	for _, job := range t.cronService.ListJobs(true) {
+		if job.ID == jobID {
+			return job.Source == cron.SourceConfig
+		}
+	}
+	return false
+}
+
 // ExecuteJob executes a cron job through the agent
 func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 	// Get channel/chatID from job payload
@@ -294,21 +371,37 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			output = fmt.Sprintf("Scheduled command '%s' executed:\n%s", job.Payload.Command, result.ForLLM)
 		}
 
-		t.msgBus.PublishOutbound(bus.OutboundMessage{
-			Channel: channel,
-			ChatID:  chatID,
-			Content: output,
-		})
+		if err := t.msgBus.Notify(ctx, channel, chatID, output); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		return "ok"
+	}
+
+	// Call a tool and send its result
+	if job.Payload.Tool != "" {
+		result := t.executor.ExecuteTool(ctx, job.Payload.Tool, job.Payload.Args, channel, chatID)
+		output := result.ForUser
+		if output == "" {
+			output = result.ForLLM
+		}
+		if result.IsError {
+			output = fmt.Sprintf("Scheduled %s failed: %s", job.Payload.Tool, output)
+		}
+		if err := t.msgBus.Notify(ctx, channel, chatID, output); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
 		return "ok"
 	}
 
 	// If deliver=true, send message directly without agent processing
 	if job.Payload.Deliver {
-		t.msgBus.PublishOutbound(bus.OutboundMessage{
-			Channel: channel,
-			ChatID:  chatID,
-			Content: job.Payload.Message,
-		})
+		message := job.Payload.Message
+		if job.Payload.Kind == reminderKind {
+			message = "⏰ Reminder: " + message
+		}
+		if err := t.msgBus.Notify(ctx, channel, chatID, message); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
 		return "ok"
 	}
 
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+// toolExecutor runs scheduled tool calls against a registry.
+type toolExecutor struct {
+	registry *ToolRegistry
+}
+
+func (e *toolExecutor) ProcessDirectWithChannel(
+	ctx context.Context,
+	content, sessionKey, channel, chatID string,
+) (string, error) {
+	return "", nil
+}
+
+func (e *toolExecutor) ExecuteTool(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID string,
+) *ToolResult {
+	return e.registry.ExecuteWithContext(ctx, name, args, channel, chatID, nil)
+}
+
+func TestCronToolSchedulesToolCalls(t *testing.T) {
+	workspace := t.TempDir()
+	cs := cron.NewCronService(filepath.Join(workspace, "jobs.json"), nil)
+	registry := NewToolRegistry()
+	registry.Register(NewListDirTool(workspace, true))
+	msgBus := bus.NewMessageBus()
+	tool := NewCronTool(cs, &toolExecutor{registry}, msgBus, workspace, true, time.Minute, config.DefaultConfig())
+	tool.SetContext("telegram", "42")
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"action":    "add",
+		"message":   "Nightly listing",
+		"cron_expr": "0 2 * * *",
+		"tz":        "Europe/Berlin",
+		"tool":      "list_dir",
+		"tool_args": map[string]any{"path": "."},
+		"channel":   "slack",
+		"chat_id":   "C1",
+	})
+	if result.IsError {
+		t.Fatalf("add failed: %s", result.ForLLM)
+	}
+
+	jobs := cs.ListJobs(true)
+	if len(jobs) != 1 {
+		t.Fatalf("jobs = %d, want 1", len(jobs))
+	}
+	job := jobs[0]
+	if job.Payload.Tool != "list_dir" || job.Payload.Channel != "slack" || job.Payload.To != "C1" ||
+		job.Schedule.TZ != "Europe/Berlin" || job.Payload.Deliver {
+		t.Errorf("job = %+v", job)
+	}
+
+	if got := tool.ExecuteJob(context.Background(), &job); got != "ok" {
+		t.Fatalf("ExecuteJob() = %q", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "slack" || out.ChatID != "C1" || out.Content == "" {
+		t.Errorf("delivered %+v", out)
+	}
+
+	for _, args := range []map[string]any{
+		{"action": "add", "message": "x", "cron_expr": "* * * * *", "tz": "Mars/Olympus"},
+		{"action": "add", "message": "x", "cron_expr": "* * * * *", "channel": "slack"},
+		{"action": "add", "message": "x", "cron_expr": "* * * * *", "tool": "cron"},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("Execute(%v) succeeded", args)
+		}
+	}
+}
+
+func TestCronToolKeepsConfigJobs(t *testing.T) {
+	cs := cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	err := cs.SetConfigJobs([]config.CronJobConfig{
+		{Name: "digest", Schedule: "0 7 * * *", Prompt: "News digest", Channel: "telegram", To: "42"},
+	})
+	if err != nil {
+		t.Fatalf("SetConfigJobs() error: %v", err)
+	}
+	tool := NewCronTool(cs, &toolExecutor{NewToolRegistry()}, bus.NewMessageBus(), t.TempDir(), true, 0,
+		config.DefaultConfig())
+
+	ctx := context.Background()
+	if result := tool.Execute(ctx, map[string]any{"action": "remove", "job_id": "config-digest"}); !result.IsError {
+		t.Error("removed a job defined in the config")
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "disable", "job_id": "config-digest"}); result.IsError {
+		t.Errorf("disable failed: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,728 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	maxDataChars       = 10000
+	maxCachedDataFiles = 4
+	dataTopValues      = 5
+)
+
+// dataAggregates are the functions analyze_data can aggregate with.
+var dataAggregates = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true, "median": true, "distinct": true,
+}
+
+// cachedDataFile is a loaded table, kept until its file changes.
+type cachedDataFile struct {
+	modTime time.Time
+	size    int64
+	table   *dataTable
+}
+
+// DataTool answers questions about CSV and XLSX files without running
+// code: it describes their columns, summarizes them, and filters, groups
+// and aggregates their rows.
+type DataTool struct {
+	workspace string
+	restrict  bool
+	allowed   []config.AllowedPath
+	maxBytes  int64
+	maxRows   int
+
+	mu    sync.Mutex
+	cache map[string]cachedDataFile
+}
+
+func NewDataTool(
+	workspace string,
+	restrict bool,
+	cfg config.DataToolsConfig,
+	allowed ...config.AllowedPath,
+) *DataTool {
+	t := &DataTool{
+		workspace: workspace,
+		restrict:  restrict,
+		allowed:   allowed,
+		maxBytes:  20 << 20,
+		maxRows:   50,
+		cache:     make(map[string]cachedDataFile),
+	}
+	if cfg.MaxFileMB > 0 {
+		t.maxBytes = int64(cfg.MaxFileMB) << 20
+	}
+	if cfg.MaxRows > 0 {
+		t.maxRows = cfg.MaxRows
+	}
+	return t
+}
+
+func (t *DataTool) Name() string {
+	return "analyze_data"
+}
+
+func (t *DataTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *DataTool) Description() string {
+	return "Analyze a CSV, TSV or XLSX file. Use action=schema first to see its columns and types, " +
+		"action=stats for summary statistics, and action=query to filter, group, aggregate and sort rows. " +
+		"Prefer this to writing code for questions about tabular data."
+}
+
+func (t *DataTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"schema", "stats", "query"},
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path to the .csv, .tsv or .xlsx file",
+			},
+			"sheet": map[string]any{
+				"type":        "string",
+				"description": "Workbook sheet to use (default: the first)",
+			},
+			"columns": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "stats: columns to summarize (default: all). query: columns to show (default: all)",
+			},
+			"filters": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"column": map[string]any{"type": "string"},
+						"op": map[string]any{
+							"type": "string",
+							"enum": []string{
+								"=", "!=", ">", ">=", "<", "<=", "contains", "not_contains", "starts_with", "empty", "not_empty",
+							},
+						},
+						"value": map[string]any{"type": "string"},
+					},
+					"required": []string{"column", "op"},
+				},
+				"description": "query: rows must match all of these. Numbers compare as numbers, text ignoring case",
+			},
+			"group_by": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "query: columns to group rows by",
+			},
+			"aggregates": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+				"description": "query: values to compute per group (or over all matching rows), e.g. " +
+					"\"count\", \"sum(amount)\", \"avg(price)\", \"min(date)\", \"max(date)\", \"median(x)\", \"distinct(x)\"",
+			},
+			"sort": map[string]any{
+				"type":        "string",
+				"description": "query: column or aggregate to sort by; prefix with - for descending, e.g. \"-sum(amount)\"",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("query: rows to return (default and maximum %d)", t.maxRows),
+			},
+		},
+		"required": []string{"action", "path"},
+	}
+}
+
+func (t *DataTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	path, _ := args["path"].(string)
+	sheet, _ := args["sheet"].(string)
+	if path == "" {
+		return ErrorResult("path is required")
+	}
+	resolved, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	table, err := t.load(resolved, sheet)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to load %s: %v", path, err))
+	}
+
+	var out string
+	switch action {
+	case "schema":
+		out = t.schema(path, table)
+	case "stats":
+		out, err = t.stats(table, stringArgs(args["columns"]))
+	case "query":
+		out, err = t.query(table, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q; use schema, stats or query", action))
+	}
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	return NewToolResult(out)
+}
+
+// load reads a file, reusing the table from an earlier call when the file
+// hasn't changed since.
+func (t *DataTool) load(path, sheet string) (*dataTable, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > t.maxBytes {
+		return nil, fmt.Errorf("file is %d MB, over the %d MB limit", info.Size()>>20, t.maxBytes>>20)
+	}
+
+	key := path + "\x00" + strings.ToLower(sheet)
+	t.mu.Lock()
+	cached, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.table, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := loadDataFile(path, sheet, content)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.cache) >= maxCachedDataFiles {
+		for k := range t.cache {
+			delete(t.cache, k)
+			break
+		}
+	}
+	t.cache[key] = cachedDataFile{modTime: info.ModTime(), size: info.Size(), table: table}
+	return table, nil
+}
+
+func (t *DataTool) schema(path string, d *dataTable) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d rows, %d columns", path, len(d.rows), len(d.columns))
+	if len(d.sheets) > 0 {
+		fmt.Fprintf(&sb, " (sheet %q of %s)", d.sheet, strings.Join(d.sheets, ", "))
+	}
+	sb.WriteString("\n\n")
+
+	records := make([][]string, len(d.columns))
+	for i, col := range d.columns {
+		empty, example := 0, ""
+		for _, row := range d.rows {
+			switch {
+			case row[i] == "":
+				empty++
+			case example == "":
+				example = row[i]
+			}
+		}
+		records[i] = []string{tableCell(col), d.types[i], strconv.Itoa(empty), tableCell(example)}
+	}
+	table, _ := markdownTable([]string{"column", "type", "empty", "example"}, records, maxDataChars)
+	sb.WriteString(table)
+
+	if len(d.rows) > 0 {
+		sample := make([][]string, 0, 5)
+		for _, row := range d.rows[:min(5, len(d.rows))] {
+			sample = append(sample, tableCells(row))
+		}
+		table, _ = markdownTable(d.columns, sample, maxDataChars-sb.Len())
+		sb.WriteString("\nFirst rows:\n" + table)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (t *DataTool) stats(d *dataTable, columns []string) (string, error) {
+	cols, err := t.selectColumns(d, columns)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d rows\n", len(d.rows))
+	for _, i := range cols {
+		var values []string
+		for _, row := range d.rows {
+			if row[i] != "" {
+				values = append(values, row[i])
+			}
+		}
+		fmt.Fprintf(&sb, "\n%s (%s): %d values, %d empty", d.columns[i], d.types[i], len(values), len(d.rows)-len(values))
+		if len(values) == 0 {
+			continue
+		}
+		switch d.types[i] {
+		case dataNumber:
+			nums := dataNumbers(values)
+			sum := 0.0
+			for _, n := range nums {
+				sum += n
+			}
+			mean := sum / float64(len(nums))
+			variance := 0.0
+			for _, n := range nums {
+				variance += (n - mean) * (n - mean)
+			}
+			fmt.Fprintf(&sb, "; min %s, max %s, mean %s, median %s, sum %s, std dev %s",
+				formatDataNumber(slices.Min(nums)), formatDataNumber(slices.Max(nums)), formatDataNumber(mean),
+				formatDataNumber(median(nums)), formatDataNumber(sum),
+				formatDataNumber(math.Sqrt(variance/float64(len(nums)))))
+		case dataDate:
+			sort.Strings(values)
+			fmt.Fprintf(&sb, "; earliest %s, latest %s", values[0], values[len(values)-1])
+		default:
+			counts := make(map[string]int)
+			for _, v := range values {
+				counts[v]++
+			}
+			top := make([]string, 0, len(counts))
+			for v := range counts {
+				top = append(top, v)
+			}
+			sort.Slice(top, func(a, b int) bool {
+				if counts[top[a]] != counts[top[b]] {
+					return counts[top[a]] > counts[top[b]]
+				}
+				return top[a] < top[b]
+			})
+			parts := make([]string, 0, dataTopValues)
+			for _, v := range top[:min(dataTopValues, len(top))] {
+				parts = append(parts, fmt.Sprintf("%s (%d)", truncateDataValue(v), counts[v]))
+			}
+			fmt.Fprintf(&sb, "; %d distinct; most common: %s", len(counts), strings.Join(parts, ", "))
+		}
+		if sb.Len() > maxDataChars {
+			sb.WriteString("\n... (more columns left out; ask for specific columns)")
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+// dataFilter is one condition from a query's filters.
+type dataFilter struct {
+	col   int
+	op    string
+	value string
+}
+
+// dataAggregate is a parsed aggregate such as "sum(amount)". col is -1 for
+// a plain count.
+type dataAggregate struct {
+	label string
+	fn    string
+	col   int
+}
+
+func (t *DataTool) query(d *dataTable, args map[string]any) (string, error) {
+	filters, err := parseDataFilters(d, args["filters"])
+	if err != nil {
+		return "", err
+	}
+	var groupBy []int
+	for _, name := range stringArgs(args["group_by"]) {
+		i, err := d.column(name)
+		if err != nil {
+			return "", err
+		}
+		groupBy = append(groupBy, i)
+	}
+	var aggregates []dataAggregate
+	for _, spec := range stringArgs(args["aggregates"]) {
+		agg, err := parseDataAggregate(d, spec)
+		if err != nil {
+			return "", err
+		}
+		aggregates = append(aggregates, agg)
+	}
+	limit := t.maxRows
+	if l, ok := args["limit"].(float64); ok && l >= 1 {
+		limit = min(int(l), t.maxRows)
+	}
+
+	var matched [][]string
+	for _, row := range d.rows {
+		if matchDataFilters(row, filters) {
+			matched = append(matched, row)
+		}
+	}
+
+	var columns []string
+	var records [][]string
+	if len(groupBy) > 0 || len(aggregates) > 0 {
+		if len(aggregates) == 0 {
+			aggregates = []dataAggregate{{label: "count", fn: "count", col: -1}}
+		}
+		columns, records = groupDataRows(d, matched, groupBy, aggregates)
+	} else {
+		cols, err := t.selectColumns(d, stringArgs(args["columns"]))
+		if err != nil {
+			return "", err
+		}
+		for _, i := range cols {
+			columns = append(columns, d.columns[i])
+		}
+		for _, row := range matched {
+			record := make([]string, len(cols))
+			for j, i := range cols {
+				record[j] = row[i]
+			}
+			records = append(records, record)
+		}
+	}
+
+	if sortBy, _ := args["sort"].(string); sortBy != "" {
+		if err := sortDataRecords(columns, records, sortBy); err != nil {
+			return "", err
+		}
+	}
+
+	total := len(records)
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	for i, record := range records {
+		records[i] = tableCells(record)
+	}
+	table, shown := markdownTable(columns, records, maxDataChars)
+
+	noun := "rows"
+	if len(groupBy) > 0 {
+		noun = "groups"
+	}
+	switch {
+	case total == 0:
+		return table + "\n(no matching rows)", nil
+	case shown < total:
+		return table + fmt.Sprintf("\n(showing %d of %d %s)", shown, total, noun), nil
+	case len(groupBy) == 0 && len(aggregates) > 0:
+		return table + fmt.Sprintf("\n(over %d of %d rows)", len(matched), len(d.rows)), nil
+	default:
+		return table + fmt.Sprintf("\n(%d %s)", total, noun), nil
+	}
+}
+
+func (t *DataTool) selectColumns(d *dataTable, names []string) ([]int, error) {
+	if len(names) == 0 {
+		cols := make([]int, len(d.columns))
+		for i := range cols {
+			cols[i] = i
+		}
+		return cols, nil
+	}
+	cols := make([]int, 0, len(names))
+	for _, name := range names {
+		i, err := d.column(name)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, i)
+	}
+	return cols, nil
+}
+
+func parseDataFilters(d *dataTable, raw any) ([]dataFilter, error) {
+	list, _ := raw.([]any)
+	filters := make([]dataFilter, 0, len(list))
+	for _, item := range list {
+		spec, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("each filter must be an object with column, op and value")
+		}
+		name, _ := spec["column"].(string)
+		op, _ := spec["op"].(string)
+		col, err := d.column(name)
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		switch v := spec["value"].(type) {
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		}
+		switch op {
+		case "=", "==", "!=", ">", ">=", "<", "<=", "contains", "not_contains", "starts_with", "empty", "not_empty":
+		default:
+			return nil, fmt.Errorf("unknown filter op %q", op)
+		}
+		filters = append(filters, dataFilter{col: col, op: op, value: value})
+	}
+	return filters, nil
+}
+
+func matchDataFilters(row []string, filters []dataFilter) bool {
+	for _, f := range filters {
+		v := row[f.col]
+		lower, want := strings.ToLower(v), strings.ToLower(f.value)
+		var ok bool
+		switch f.op {
+		case "empty":
+			ok = v == ""
+		case "not_empty":
+			ok = v != ""
+		case "contains":
+			ok = strings.Contains(lower, want)
+		case "not_contains":
+			ok = !strings.Contains(lower, want)
+		case "starts_with":
+			ok = strings.HasPrefix(lower, want)
+		default:
+			if v == "" {
+				return false
+			}
+			cmp := compareDataValues(v, f.value)
+			switch f.op {
+			case "=", "==":
+				ok = cmp == 0
+			case "!=":
+				ok = cmp != 0
+			case ">":
+				ok = cmp > 0
+			case ">=":
+				ok = cmp >= 0
+			case "<":
+				ok = cmp < 0
+			case "<=":
+				ok = cmp <= 0
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareDataValues compares two values as numbers when both are, and as
+// text ignoring case otherwise. ISO dates sort correctly as text.
+func compareDataValues(a, b string) int {
+	x, okA := parseDataNumber(a)
+	y, okB := parseDataNumber(b)
+	if okA && okB {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// parseDataAggregate reads "fn(column)", or "count" on its own.
+func parseDataAggregate(d *dataTable, spec string) (dataAggregate, error) {
+	spec = strings.TrimSpace(spec)
+	fn, arg, hasArg := strings.Cut(spec, "(")
+	fn = strings.ToLower(strings.TrimSpace(fn))
+	if fn == "mean" || fn == "average" {
+		fn = "avg"
+	}
+	if !dataAggregates[fn] {
+		return dataAggregate{}, fmt.Errorf("unknown aggregate %q; use count, sum, avg, min, max, median or distinct", spec)
+	}
+	arg = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(arg), ")"))
+	if !hasArg || arg == "" || arg == "*" {
+		if fn != "count" {
+			return dataAggregate{}, fmt.Errorf("%s needs a column, e.g. %s(amount)", fn, fn)
+		}
+		return dataAggregate{label: "count", fn: fn, col: -1}, nil
+	}
+	col, err := d.column(arg)
+	if err != nil {
+		return dataAggregate{}, err
+	}
+	return dataAggregate{label: fmt.Sprintf("%s(%s)", fn, d.columns[col]), fn: fn, col: col}, nil
+}
+
+// groupDataRows computes the aggregates for each distinct combination of
+// the groupBy columns, in order of first appearance.
+func groupDataRows(d *dataTable, rows [][]string, groupBy []int, aggregates []dataAggregate) ([]string, [][]string) {
+	var columns []string
+	for _, i := range groupBy {
+		columns = append(columns, d.columns[i])
+	}
+	for _, agg := range aggregates {
+		columns = append(columns, agg.label)
+	}
+
+	groups := make(map[string][][]string)
+	var order []string
+	for _, row := range rows {
+		keyParts := make([]string, len(groupBy))
+		for j, i := range groupBy {
+			keyParts[j] = row[i]
+		}
+		key := strings.Join(keyParts, "\x00")
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(groupBy) == 0 && len(order) == 0 {
+		order = []string{""}
+	}
+
+	records := make([][]string, 0, len(order))
+	for _, key := range order {
+		groupRows := groups[key]
+		var record []string
+		if len(groupBy) > 0 {
+			record = strings.Split(key, "\x00")
+		}
+		for _, agg := range aggregates {
+			record = append(record, aggregateData(groupRows, agg))
+		}
+		records = append(records, record)
+	}
+	return columns, records
+}
+
+func aggregateData(rows [][]string, agg dataAggregate) string {
+	if agg.col < 0 {
+		return strconv.Itoa(len(rows))
+	}
+	var values []string
+	for _, row := range rows {
+		if row[agg.col] != "" {
+			values = append(values, row[agg.col])
+		}
+	}
+	switch agg.fn {
+	case "count":
+		return strconv.Itoa(len(values))
+	case "distinct":
+		seen := make(map[string]bool)
+		for _, v := range values {
+			seen[strings.ToLower(v)] = true
+		}
+		return strconv.Itoa(len(seen))
+	case "min", "max":
+		if len(values) == 0 {
+			return ""
+		}
+		best := values[0]
+		for _, v := range values[1:] {
+			cmp := compareDataValues(v, best)
+			if (agg.fn == "min" && cmp < 0) || (agg.fn == "max" && cmp > 0) {
+				best = v
+			}
+		}
+		return best
+	}
+
+	nums := dataNumbers(values)
+	if len(nums) == 0 {
+		return ""
+	}
+	switch agg.fn {
+	case "sum", "avg":
+		sum := 0.0
+		for _, n := range nums {
+			sum += n
+		}
+		if agg.fn == "avg" {
+			sum /= float64(len(nums))
+		}
+		return formatDataNumber(sum)
+	default: // median
+		return formatDataNumber(median(nums))
+	}
+}
+
+// sortDataRecords sorts result rows by a column or aggregate label, with a
+// leading "-" for descending order. Empty values go last.
+func sortDataRecords(columns []string, records [][]string, sortBy string) error {
+	desc := strings.HasPrefix(sortBy, "-")
+	name := strings.TrimSpace(strings.TrimPrefix(sortBy, "-"))
+	// "sum( amount )" finds the sum(amount) column
+	col := slices.IndexFunc(columns, func(c string) bool {
+		return strings.EqualFold(strings.ReplaceAll(c, " ", ""), strings.ReplaceAll(name, " ", ""))
+	})
+	if col < 0 {
+		return fmt.Errorf("can't sort by %q; result columns are: %s", name, strings.Join(columns, ", "))
+	}
+	sort.SliceStable(records, func(a, b int) bool {
+		x, y := records[a][col], records[b][col]
+		if x == "" || y == "" {
+			return x != "" && y == ""
+		}
+		if desc {
+			return compareDataValues(x, y) > 0
+		}
+		return compareDataValues(x, y) < 0
+	})
+	return nil
+}
+
+// dataNumbers parses the values that are numbers, skipping the rest.
+func dataNumbers(values []string) []float64 {
+	nums := make([]float64, 0, len(values))
+	for _, v := range values {
+		if n, ok := parseDataNumber(v); ok {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
+
+func median(nums []float64) float64 {
+	sorted := append([]float64(nil), nums...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// formatDataNumber prints whole numbers without a decimal point and rounds
+// the rest to four places.
+func formatDataNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
+
+func truncateDataValue(v string) string {
+	if len([]rune(v)) > 40 {
+		return string([]rune(v)[:40]) + "…"
+	}
+	return v
+}
+
+func tableCells(values []string) []string {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = tableCell(v)
+	}
+	return cells
+}
@@ -0,0 +1,463 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types the analyze_data tool infers from a column's values.
+const (
+	dataNumber = "number"
+	dataDate   = "date"
+	dataBool   = "bool"
+	dataText   = "text"
+)
+
+// dataTable is a loaded CSV file or workbook sheet. Every row has a value
+// for every column; missing cells are "".
+type dataTable struct {
+	columns []string
+	types   []string
+	rows    [][]string
+	sheet   string   // the sheet loaded, for workbooks
+	sheets  []string // all sheets in the workbook
+}
+
+// column finds a column by name, ignoring case.
+func (d *dataTable) column(name string) (int, error) {
+	for i, col := range d.columns {
+		if strings.EqualFold(col, strings.TrimSpace(name)) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no column %q; columns are: %s", name, strings.Join(d.columns, ", "))
+}
+
+// loadDataFile reads a CSV, TSV or XLSX file. For workbooks, sheet picks
+// the sheet by name; the first one is used when it is empty.
+func loadDataFile(filePath, sheet string, content []byte) (*dataTable, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".txt":
+		return loadCSV(content, 0)
+	case ".tsv", ".tab":
+		return loadCSV(content, '\t')
+	case ".xlsx", ".xlsm":
+		return loadXLSX(content, sheet)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q; use .csv, .tsv or .xlsx", filepath.Ext(filePath))
+	}
+}
+
+// loadCSV parses delimited text. With no delimiter given, it picks whichever
+// of comma, semicolon and tab the header line has most of.
+func loadCSV(content []byte, delimiter rune) (*dataTable, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if delimiter == 0 {
+		header, _, _ := bytes.Cut(content, []byte("\n"))
+		delimiter = ','
+		for _, candidate := range []rune{';', '\t'} {
+			if bytes.Count(header, []byte(string(candidate))) > bytes.Count(header, []byte(string(delimiter))) {
+				delimiter = candidate
+			}
+		}
+	}
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSV: %w", err)
+	}
+	return newDataTable(records)
+}
+
+// newDataTable takes the first non-empty record as the header and infers
+// column types from the rest. Blank or repeated column names are made
+// unique.
+func newDataTable(records [][]string) (*dataTable, error) {
+	for len(records) > 0 && blankRecord(records[0]) {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file has no data")
+	}
+
+	d := &dataTable{}
+	seen := make(map[string]bool)
+	for i, name := range records[0] {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		unique := name
+		for n := 2; seen[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		seen[strings.ToLower(unique)] = true
+		d.columns = append(d.columns, unique)
+	}
+
+	for _, record := range records[1:] {
+		if blankRecord(record) {
+			continue
+		}
+		row := make([]string, len(d.columns))
+		for i := range row {
+			if i < len(record) {
+				row[i] = strings.TrimSpace(record[i])
+			}
+		}
+		d.rows = append(d.rows, row)
+	}
+
+	d.types = make([]string, len(d.columns))
+	for i := range d.columns {
+		d.types[i] = d.inferType(i)
+	}
+	return d, nil
+}
+
+func blankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// inferType returns the narrowest type all of a column's non-empty values
+// fit.
+func (d *dataTable) inferType(col int) string {
+	number, date, boolean := true, true, true
+	seen := false
+	for _, row := range d.rows {
+		v := row[col]
+		if v == "" {
+			continue
+		}
+		seen = true
+		if number {
+			_, number = parseDataNumber(v)
+		}
+		if date {
+			_, date = parseDataDate(v)
+		}
+		if boolean {
+			switch strings.ToLower(v) {
+			case "true", "false", "yes", "no":
+			default:
+				boolean = false
+			}
+		}
+		if !number && !date && !boolean {
+			return dataText
+		}
+	}
+	switch {
+	case !seen:
+		return dataText
+	case number:
+		return dataNumber
+	case date:
+		return dataDate
+	case boolean:
+		return dataBool
+	default:
+		return dataText
+	}
+}
+
+// parseDataNumber reads a number, allowing thousands separators, a leading
+// currency sign and a trailing percent sign ("$1,250.50", "12%").
+func parseDataNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	for _, sign := range []string{"$", "€", "£", "¥"} {
+		s = strings.TrimPrefix(s, sign)
+	}
+	s = strings.TrimSuffix(s, "%")
+	if strings.Contains(s, ",") {
+		whole, _, _ := strings.Cut(s, ".")
+		groups := strings.Split(whole, ",")
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return 0, false
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return 0, false
+			}
+		}
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	if negative {
+		f = -f
+	}
+	return f, true
+}
+
+// dataDateLayouts are the ISO 8601 forms a date column can take.
+var dataDateLayouts = []string{
+	"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05", time.RFC3339,
+}
+
+func parseDataDate(s string) (time.Time, bool) {
+	for _, layout := range dataDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// XLSX parts, as far as reading cell values goes.
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxText struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	xlsxStyles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	xlsxSheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string   `xml:"r,attr"`
+				Style  int      `xml:"s,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	for _, run := range t.Runs {
+		sb.WriteString(run.T)
+	}
+	return sb.String()
+}
+
+// loadXLSX reads one sheet of a workbook. Formulas give their cached
+// values, and numbers in date formats become dates.
+func loadXLSX(content []byte, sheet string) (*dataTable, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("reading workbook: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	readXML := func(name string, out any) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("workbook has no %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(io.LimitReader(rc, 256<<20)).Decode(out); err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		return nil
+	}
+
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if err := readXML("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := readXML("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	var sheets []string
+	chosen := -1
+	for i, s := range workbook.Sheets {
+		sheets = append(sheets, s.Name)
+		if chosen < 0 && (sheet == "" || strings.EqualFold(s.Name, sheet)) {
+			chosen = i
+		}
+	}
+	if chosen < 0 {
+		return nil, fmt.Errorf("no sheet %q; sheets are: %s", sheet, strings.Join(sheets, ", "))
+	}
+	target := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[chosen].RID {
+			target = rel.Target
+		}
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	// Shared strings and styles are optional parts
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXML("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := files["xl/styles.xml"]; ok {
+		if err := readXML("xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+	dateStyles := xlsxDateStyles(styles)
+
+	var ws xlsxSheet
+	if err := readXML(target, &ws); err != nil {
+		return nil, err
+	}
+	var records [][]string
+	for _, row := range ws.Rows {
+		var record []string
+		for _, c := range row.Cells {
+			col := xlsxColumn(c.Ref)
+			if col < 0 {
+				col = len(record)
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			switch c.Type {
+			case "s":
+				if idx, err := strconv.Atoi(c.Value); err == nil && idx >= 0 && idx < len(shared.Items) {
+					record[col] = shared.Items[idx].String()
+				}
+			case "inlineStr":
+				record[col] = c.Inline.String()
+			case "b":
+				record[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[c.Value]
+			case "", "n":
+				record[col] = c.Value
+				if dateStyles[c.Style] {
+					if serial, err := strconv.ParseFloat(c.Value, 64); err == nil {
+						record[col] = xlsxDate(serial)
+					}
+				}
+			default: // str (formula text), e (error), d (ISO date)
+				record[col] = c.Value
+			}
+		}
+		records = append(records, record)
+	}
+
+	table, err := newDataTable(records)
+	if err != nil {
+		return nil, err
+	}
+	table.sheet, table.sheets = workbook.Sheets[chosen].Name, sheets
+	return table, nil
+}
+
+// xlsxDateStyles returns which cell styles format numbers as dates: the
+// built-in date formats, and custom ones with day or year codes.
+func xlsxDateStyles(styles xlsxStyles) map[int]bool {
+	dateFormats := map[int]bool{14: true, 15: true, 16: true, 17: true, 22: true, 45: true, 46: true, 47: true}
+	for _, f := range styles.NumFmts {
+		code := strings.ToLower(f.Code)
+		// Drop quoted literals and [color] or [$-locale] sections first
+		for _, pair := range [][2]string{{`"`, `"`}, {"[", "]"}} {
+			for {
+				start := strings.Index(code, pair[0])
+				if start < 0 {
+					break
+				}
+				end := strings.Index(code[start+1:], pair[1])
+				if end < 0 {
+					break
+				}
+				code = code[:start] + code[start+end+2:]
+			}
+		}
+		if strings.ContainsAny(code, "dy") {
+			dateFormats[f.ID] = true
+		}
+	}
+	dates := make(map[int]bool)
+	for i, xf := range styles.CellXfs {
+		if dateFormats[xf.NumFmtID] {
+			dates[i] = true
+		}
+	}
+	return dates
+}
+
+// xlsxColumn turns a cell reference like "AB12" into a 0-based column, or
+// -1 when there is no reference.
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, c := range strings.ToUpper(ref) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+	}
+	return col - 1
+}
+
+// xlsxDate converts a serial day number (1900 date system) to a date, with
+// the time when there is one.
+func xlsxDate(serial float64) string {
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	t := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	if seconds == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
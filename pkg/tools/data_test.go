@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const salesCSV = `date,region,product,amount
+2026-01-05,North,Widget,"1,200.50"
+2026-01-09,South,Gadget,300
+2026-02-11,North,Gadget,450
+2026-02-14,East,Widget,
+2026-03-02,South,Widget,80
+`
+
+func TestDataTool(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "sales.csv"), []byte(salesCSV), 0o644)
+	tool := NewDataTool(workspace, true, config.DataToolsConfig{MaxRows: 3})
+	ctx := context.Background()
+	run := func(args map[string]any) string {
+		t.Helper()
+		args["path"] = "sales.csv"
+		result := tool.Execute(ctx, args)
+		if result.IsError {
+			t.Fatalf("%v: %s", args, result.ForLLM)
+		}
+		return result.ForLLM
+	}
+
+	schema := run(map[string]any{"action": "schema"})
+	for _, want := range []string{
+		"sales.csv: 5 rows, 4 columns",
+		"| date | date | 0 | 2026-01-05 |",
+		"| amount | number | 1 | 1,200.50 |",
+		"First rows:\n| date | region | product | amount |",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema missing %q:\n%s", want, schema)
+		}
+	}
+
+	stats := run(map[string]any{"action": "stats", "columns": []any{"amount", "Region", "date"}})
+	for _, want := range []string{
+		"amount (number): 4 values, 1 empty; min 80, max 1200.5, mean 507.625, median 375, sum 2030.5",
+		"region (text): 5 values, 0 empty; 3 distinct; most common: North (2), South (2), East (1)",
+		"date (date): 5 values, 0 empty; earliest 2026-01-05, latest 2026-03-02",
+	} {
+		if !strings.Contains(stats, want) {
+			t.Errorf("stats missing %q:\n%s", want, stats)
+		}
+	}
+
+	grouped := run(map[string]any{
+		"action":     "query",
+		"filters":    []any{map[string]any{"column": "date", "op": ">=", "value": "2026-01-06"}},
+		"group_by":   []any{"region"},
+		"aggregates": []any{"count", "sum(amount)"},
+		"sort":       "-sum(amount)",
+	})
+	want := "| region | count | sum(amount) |\n" +
+		"| --- | --- | --- |\n" +
+		"| North | 1 | 450 |\n" +
+		"| South | 2 | 380 |\n" +
+		"| East | 1 |  |\n" +
+		"\n(3 groups)"
+	if grouped != want {
+		t.Errorf("grouped = %q, want %q", grouped, want)
+	}
+
+	rows := run(map[string]any{
+		"action":  "query",
+		"columns": []any{"product", "amount"},
+		"filters": []any{
+			map[string]any{"column": "amount", "op": ">", "value": float64(100)},
+			map[string]any{"column": "product", "op": "contains", "value": "GAD"},
+		},
+	})
+	if !strings.Contains(rows, "| Gadget | 300 |\n| Gadget | 450 |\n\n(2 rows)") {
+		t.Errorf("filtered rows = %q", rows)
+	}
+	limited := run(map[string]any{"action": "query", "sort": "date"})
+	if !strings.HasSuffix(limited, "(showing 3 of 5 rows)") {
+		t.Errorf("limited rows = %q", limited)
+	}
+	total := run(map[string]any{"action": "query", "aggregates": "avg(amount),max(date)"})
+	if !strings.Contains(total, "| 507.625 | 2026-03-02 |") || !strings.HasSuffix(total, "(over 5 of 5 rows)") {
+		t.Errorf("overall aggregates = %q", total)
+	}
+
+	os.WriteFile(filepath.Join(workspace, "notes.pdf"), []byte("%PDF"), 0o644)
+	failures := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"action": "stats", "path": "sales.csv", "columns": []any{"price"}}, `no column "price"`},
+		{map[string]any{"action": "query", "path": "sales.csv", "aggregates": []any{"sum"}}, "needs a column"},
+		{map[string]any{"action": "query", "path": "sales.csv", "aggregates": []any{"mode(amount)"}}, "unknown aggregate"},
+		{map[string]any{"action": "query", "path": "sales.csv", "sort": "price"}, "can't sort"},
+		{map[string]any{"action": "schema", "path": "notes.pdf"}, "unsupported file type"},
+		{map[string]any{"action": "schema", "path": "../outside.csv"}, "outside"},
+	}
+	for _, tt := range failures {
+		result := tool.Execute(ctx, tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v = %q, want an error with %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
+
+// writeTestXLSX writes a workbook with a Summary sheet and an Orders sheet
+// using shared strings, inline strings, a skipped cell and a date format.
+func writeTestXLSX(t *testing.T, path string) {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Orders" sheetId="2" r:id="rId2"/></sheets>
+			</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+			<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+			</Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>customer</t></si><si><t>ordered</t></si>` +
+			`<si><r><t>Ada </t></r><r><t>Lovelace</t></r></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="[$-409]d\-mmm\-yyyy"/></numFmts>
+			<cellXfs><xf numFmtId="0"/><xf numFmtId="164"/><xf numFmtId="4"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>total</t></is></c></row>
+			<row r="2"><c r="A2"><v>42</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c>
+				<c r="C1" t="inlineStr"><is><t>total</t></is></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" s="1"><v>46023</v></c><c r="C2" s="2"><v>19.5</v></c></row>
+			<row r="3"><c r="A3" t="inlineStr"><is><t>Grace</t></is></c><c r="C3"><v>7</v></c></row>
+			</sheetData></worksheet>`,
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range parts {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+}
+
+func TestDataTool_XLSX(t *testing.T) {
+	workspace := t.TempDir()
+	writeTestXLSX(t, filepath.Join(workspace, "orders.xlsx"))
+	tool := NewDataTool(workspace, true, config.DataToolsConfig{})
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "schema", "path": "orders.xlsx"})
+	if !strings.Contains(result.ForLLM, `1 rows, 1 columns (sheet "Summary" of Summary, Orders)`) {
+		t.Errorf("first sheet = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "query", "path": "orders.xlsx", "sheet": "orders"})
+	want := "| customer | ordered | total |\n" +
+		"| --- | --- | --- |\n" +
+		"| Ada Lovelace | 2026-01-01 | 19.5 |\n" +
+		"| Grace |  | 7 |\n" +
+		"\n(2 rows)"
+	if result.IsError || result.ForLLM != want {
+		t.Errorf("orders = %q, want %q", result.ForLLM, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "schema", "path": "orders.xlsx", "sheet": "Refunds"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "sheets are: Summary, Orders") {
+		t.Errorf("missing sheet = %q", result.ForLLM)
+	}
+}
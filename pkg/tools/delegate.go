@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxDelegateSteps bounds the length of a pipeline.
+const maxDelegateSteps = 5
+
+// DelegateProfile is a specialist a DelegateTool can hand work to.
+type DelegateProfile struct {
+	Name          string
+	Description   string
+	Prompt        string
+	Provider      providers.LLMProvider
+	Model         string
+	Tools         *ToolRegistry // nil for none
+	MaxIterations int
+	LLMOptions    map[string]any
+}
+
+// DelegateTool routes a task to one of the configured agent profiles, or
+// pipelines it through several, and returns their results within the same
+// turn. Unlike spawn it waits for the work to finish.
+type DelegateTool struct {
+	profiles      map[string]*DelegateProfile
+	names         []string
+	originChannel string
+	originChatID  string
+}
+
+func NewDelegateTool(profiles ...*DelegateProfile) *DelegateTool {
+	t := &DelegateTool{
+		profiles:      make(map[string]*DelegateProfile, len(profiles)),
+		originChannel: "cli",
+		originChatID:  "direct",
+	}
+	for _, p := range profiles {
+		t.profiles[p.Name] = p
+		t.names = append(t.names, p.Name)
+	}
+	slices.Sort(t.names)
+	return t
+}
+
+func (t *DelegateTool) Name() string {
+	return "delegate"
+}
+
+func (t *DelegateTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *DelegateTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Hand a task to a specialist agent and get its result back. Give profile and task for one " +
+		"specialist, or steps to pipeline the work: each step gets the previous step's result. Specialists:")
+	for _, name := range t.names {
+		fmt.Fprintf(&sb, "\n- %s: %s", name, t.profiles[name].Description)
+	}
+	return sb.String()
+}
+
+func (t *DelegateTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"profile": map[string]any{
+				"type":        "string",
+				"enum":        t.names,
+				"description": "Specialist to hand the task to",
+			},
+			"task": map[string]any{
+				"type":        "string",
+				"description": "What the specialist should do, with everything it needs to know",
+			},
+			"steps": map[string]any{
+				"type":        "array",
+				"description": fmt.Sprintf("Instead of profile and task, up to %d steps run in order", maxDelegateSteps),
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"profile": map[string]any{"type": "string", "enum": t.names},
+						"task":    map[string]any{"type": "string"},
+					},
+					"required": []string{"profile", "task"},
+				},
+			},
+		},
+	}
+}
+
+func (t *DelegateTool) SetContext(channel, chatID string) {
+	t.originChannel = channel
+	t.originChatID = chatID
+}
+
+// delegateStep is one specialist's share of the work.
+type delegateStep struct {
+	profile *DelegateProfile
+	task    string
+}
+
+func (t *DelegateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	steps, err := t.parseSteps(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	var sb strings.Builder
+	var previous string
+	for i, step := range steps {
+		task := step.task
+		if i > 0 {
+			task += fmt.Sprintf("\n\nResult of the previous step (%s):\n%s", steps[i-1].profile.Name, previous)
+		}
+		if len(steps) > 1 {
+			ReportProgress(ctx, fmt.Sprintf("Step %d of %d: %s", i+1, len(steps), step.profile.Name))
+		}
+		result, err := t.run(ctx, step.profile, task)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("%s failed at step %d: %v", step.profile.Name, i+1, err)).WithError(err)
+		}
+		previous = result
+		if len(steps) > 1 {
+			fmt.Fprintf(&sb, "## Step %d: %s\n", i+1, step.profile.Name)
+		}
+		sb.WriteString(result)
+		sb.WriteString("\n\n")
+	}
+	return NewToolResult(strings.TrimSpace(sb.String()))
+}
+
+func (t *DelegateTool) parseSteps(args map[string]any) ([]delegateStep, error) {
+	type rawStep struct{ profile, task string }
+	var raw []rawStep
+	if list, ok := args["steps"].([]any); ok && len(list) > 0 {
+		for _, item := range list {
+			m, _ := item.(map[string]any)
+			profile, _ := m["profile"].(string)
+			task, _ := m["task"].(string)
+			raw = append(raw, rawStep{profile, task})
+		}
+	} else {
+		profile, _ := args["profile"].(string)
+		task, _ := args["task"].(string)
+		raw = append(raw, rawStep{profile, task})
+	}
+	if len(raw) > maxDelegateSteps {
+		return nil, fmt.Errorf("at most %d steps are allowed, got %d", maxDelegateSteps, len(raw))
+	}
+
+	steps := make([]delegateStep, 0, len(raw))
+	for _, r := range raw {
+		profile, ok := t.profiles[r.profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q; choose from %s", r.profile, strings.Join(t.names, ", "))
+		}
+		if strings.TrimSpace(r.task) == "" {
+			return nil, fmt.Errorf("task is required for %s", r.profile)
+		}
+		steps = append(steps, delegateStep{profile, r.task})
+	}
+	return steps, nil
+}
+
+// run has profile carry out task with its own prompt, model and tools.
+func (t *DelegateTool) run(ctx context.Context, profile *DelegateProfile, task string) (string, error) {
+	messages := []providers.Message{
+		{
+			Role: "system",
+			Content: profile.Prompt + "\n\nAnother agent handed you the task below on behalf of its user. " +
+				"The user won't see your reply, so reply with the result itself, complete and without small talk.",
+		},
+		{Role: "user", Content: task},
+	}
+	channel, chatID := ChatFrom(ctx, t.originChannel, t.originChatID)
+	result, err := RunToolLoop(ctx, ToolLoopConfig{
+		Provider:      profile.Provider,
+		Model:         profile.Model,
+		Tools:         profile.Tools,
+		MaxIterations: profile.MaxIterations,
+		LLMOptions:    profile.LLMOptions,
+	}, messages, channel, chatID)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return "", fmt.Errorf("no result after %d iterations", result.Iterations)
+	}
+	return result.Content, nil
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// profileProvider answers as the profile named in the system prompt, after
+// reading notes.txt once when the profile has tools
+type profileProvider struct {
+	tasks []string
+}
+
+func (p *profileProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "user" {
+		p.tasks = append(p.tasks, last.Content)
+		if len(tools) > 0 {
+			return &providers.LLMResponse{ToolCalls: []providers.ToolCall{
+				{ID: "call_1", Name: "notes", Arguments: map[string]any{}},
+			}}, nil
+		}
+	}
+	role, _, _ := strings.Cut(messages[0].Content, ".")
+	return &providers.LLMResponse{Content: role + " done: " + last.Content}, nil
+}
+
+func (p *profileProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+type notesStub struct{}
+
+func (notesStub) Name() string               { return "notes" }
+func (notesStub) Description() string        { return "Reads the notes" }
+func (notesStub) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (notesStub) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	return NewToolResult("the launch moved to May")
+}
+
+func TestDelegateTool(t *testing.T) {
+	provider := &profileProvider{}
+	researchTools := NewToolRegistry()
+	researchTools.Register(notesStub{})
+	tool := NewDelegateTool(
+		&DelegateProfile{Name: "summarizer", Description: "summarizes", Prompt: "Summarizer.",
+			Provider: provider, MaxIterations: 3},
+		&DelegateProfile{Name: "researcher", Description: "researches", Prompt: "Researcher.",
+			Provider: provider, Tools: researchTools, MaxIterations: 3},
+	)
+	ctx := context.Background()
+
+	if desc := tool.Description(); !strings.Contains(desc, "- researcher: researches\n- summarizer: summarizes") {
+		t.Errorf("Description = %q", desc)
+	}
+
+	result := tool.Execute(ctx, map[string]any{"profile": "summarizer", "task": "shorten this"})
+	if result.IsError || result.ForLLM != "Summarizer done: shorten this" {
+		t.Errorf("single step = %q", result.ForLLM)
+	}
+
+	provider.tasks = nil
+	result = tool.Execute(ctx, map[string]any{"steps": []any{
+		map[string]any{"profile": "researcher", "task": "check the launch date"},
+		map[string]any{"profile": "summarizer", "task": "one line for the user"},
+	}})
+	if result.IsError {
+		t.Fatalf("pipeline: %s", result.ForLLM)
+	}
+	want := "## Step 1: researcher\nResearcher done: the launch moved to May\n\n" +
+		"## Step 2: summarizer\nSummarizer done: one line for the user\n\n" +
+		"Result of the previous step (researcher):\nResearcher done: the launch moved to May"
+	if result.ForLLM != want {
+		t.Errorf("pipeline = %q, want %q", result.ForLLM, want)
+	}
+	if len(provider.tasks) != 2 || !strings.Contains(provider.tasks[1], "Researcher done: the launch moved to May") {
+		t.Errorf("the summarizer didn't get the research: %q", provider.tasks)
+	}
+
+	failures := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"profile": "poet", "task": "a haiku"}, "unknown profile"},
+		{map[string]any{"profile": "researcher"}, "task is required"},
+		{map[string]any{"steps": []any{1, 2, 3, 4, 5, 6}}, "at most 5 steps"},
+	}
+	for _, tt := range failures {
+		result := tool.Execute(ctx, tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v = %q, want an error with %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DryRunner is implemented by tools that can tell what a call would do
+// without doing it. The call is checked as it would be for real, so a dry
+// run also shows calls that would fail or be blocked.
+type DryRunner interface {
+	DryRun(ctx context.Context, args map[string]any) *ToolResult
+}
+
+// DryRun returns what calling tool with args would do, without calling it.
+// Tools that aren't DryRunners report the arguments they would get.
+func DryRun(ctx context.Context, tool Tool, args map[string]any) *ToolResult {
+	if err := ValidateArgs(tool, args); err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+	if dr, ok := tool.(DryRunner); ok {
+		return dr.DryRun(ctx, args)
+	}
+	data, _ := json.Marshal(args)
+	return dryRunResult("%s would be called with %s", tool.Name(), data)
+}
+
+func dryRunResult(format string, a ...any) *ToolResult {
+	return SilentResult("[dry run, nothing was done] " + fmt.Sprintf(format, a...))
+}
+
+func (t *WriteFileTool) DryRun(ctx context.Context, args map[string]any) *ToolResult {
+	path, ok := args["path"].(string)
+	if !ok {
+		return ErrorResult("path is required")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return ErrorResult("content is required")
+	}
+	resolvedPath, err := validatePath(path, t.workspace, t.restrict, t.allowed, true)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if info, err := os.Stat(resolvedPath); err == nil {
+		return dryRunResult("%d bytes would be written to %s, replacing its %d bytes",
+			len(content), path, info.Size())
+	}
+	return dryRunResult("%s would be created with %d bytes", path, len(content))
+}
+
+func (t *EditFileTool) DryRun(ctx context.Context, args map[string]any) *ToolResult {
+	path, _, newContent, errResult := t.edit(args)
+	if errResult != nil {
+		return errResult
+	}
+	return dryRunResult("%s would be edited; it would then have %d lines",
+		path, strings.Count(newContent, "\n")+1)
+}
+
+func (t *AppendFileTool) DryRun(ctx context.Context, args map[string]any) *ToolResult {
+	path, ok := args["path"].(string)
+	if !ok {
+		return ErrorResult("path is required")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return ErrorResult("content is required")
+	}
+	if _, err := validatePath(path, t.workspace, t.restrict, t.allowed, true); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return dryRunResult("%d bytes would be appended to %s", len(content), path)
+}
+
+func (t *ExecTool) DryRun(ctx context.Context, args map[string]any) *ToolResult {
+	command, cwd, errResult := t.prepare(args)
+	if errResult != nil {
+		return errResult
+	}
+	return dryRunResult("this command would run in %s:\n%s", cwd, command)
+}
+
+func (t *SSHTool) DryRun(ctx context.Context, args map[string]any) *ToolResult {
+	name, _, command, errResult := t.prepare(args)
+	if errResult != nil {
+		return errResult
+	}
+	return dryRunResult("this command would run on %s:\n%s", name, command)
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("one\ntwo\n"), 0o644)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name    string
+		tool    Tool
+		args    map[string]any
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "write new", tool: NewWriteFileTool(dir, true),
+			args: map[string]any{"path": "new.md", "content": "hello"},
+			want: "new.md would be created with 5 bytes",
+		},
+		{
+			name: "write existing", tool: NewWriteFileTool(dir, true),
+			args: map[string]any{"path": "notes.md", "content": "x"},
+			want: "1 bytes would be written to notes.md, replacing its 8 bytes",
+		},
+		{
+			name: "write outside the workspace", tool: NewWriteFileTool(dir, true),
+			args: map[string]any{"path": "/etc/passwd", "content": "x"}, wantErr: true,
+		},
+		{
+			name: "edit", tool: NewEditFileTool(dir, true),
+			args: map[string]any{"path": "notes.md", "old_text": "two", "new_text": "2\n3"},
+			want: "notes.md would be edited; it would then have 4 lines",
+		},
+		{
+			name: "edit with missing text", tool: NewEditFileTool(dir, true),
+			args: map[string]any{"path": "notes.md", "old_text": "three", "new_text": "3"}, wantErr: true,
+		},
+		{
+			name: "append", tool: NewAppendFileTool(dir, true),
+			args: map[string]any{"path": "notes.md", "content": "three\n"},
+			want: "6 bytes would be appended to notes.md",
+		},
+		{
+			name: "exec", tool: NewExecTool(dir, true),
+			args: map[string]any{"command": "make build"},
+			want: "this command would run in " + dir + ":\nmake build",
+		},
+		{
+			name: "blocked exec", tool: NewExecTool(dir, true),
+			args: map[string]any{"command": "rm -rf /"}, wantErr: true,
+		},
+		{
+			name: "other tool", tool: &unratedTool{},
+			args: map[string]any{"q": 1},
+			want: `unrated would be called with {"q":1}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DryRun(ctx, tt.tool, tt.args)
+			if result.IsError != tt.wantErr {
+				t.Fatalf("DryRun() = %+v, want error %v", result, tt.wantErr)
+			}
+			if !tt.wantErr && result.ForLLM != "[dry run, nothing was done] "+tt.want {
+				t.Errorf("DryRun() = %q", result.ForLLM)
+			}
+		})
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "one\ntwo\n" {
+		t.Errorf("notes.md changed: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.md")); !os.IsNotExist(err) {
+		t.Errorf("new.md was created")
+	}
+}
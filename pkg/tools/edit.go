@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // EditFileTool edits a file by replacing old_text with new_text.
@@ -12,13 +14,16 @@ import (
 type EditFileTool struct {
 	allowedDir string
 	restrict   bool
+	allowed    []config.AllowedPath
+	history    *FileHistory
 }
 
 // NewEditFileTool creates a new EditFileTool with optional directory restriction.
-func NewEditFileTool(allowedDir string, restrict bool) *EditFileTool {
+func NewEditFileTool(allowedDir string, restrict bool, allowed ...config.AllowedPath) *EditFileTool {
 	return &EditFileTool{
 		allowedDir: allowedDir,
 		restrict:   restrict,
+		allowed:    allowed,
 	}
 }
 
@@ -26,6 +31,10 @@ func (t *EditFileTool) Name() string {
 	return "edit_file"
 }
 
+func (t *EditFileTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 func (t *EditFileTool) Description() string {
 	return "Edit a file by replacing old_text with new_text. The old_text must exist exactly in the file."
 }
@@ -52,70 +61,85 @@ func (t *EditFileTool) Parameters() map[string]any {
 }
 
 func (t *EditFileTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	path, resolvedPath, newContent, errResult := t.edit(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.history.Record(ctx, path, resolvedPath)
+	if err := os.WriteFile(resolvedPath, []byte(newContent), 0o644); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write file: %v", err))
+	}
+
+	return SilentResult(fmt.Sprintf("File edited: %s", path))
+}
+
+// edit checks the call and returns the file's edited contents.
+func (t *EditFileTool) edit(args map[string]any) (path, resolvedPath, newContent string, errResult *ToolResult) {
 	path, ok := args["path"].(string)
 	if !ok {
-		return ErrorResult("path is required")
+		return "", "", "", ErrorResult("path is required")
 	}
 
 	oldText, ok := args["old_text"].(string)
 	if !ok {
-		return ErrorResult("old_text is required")
+		return "", "", "", ErrorResult("old_text is required")
 	}
 
 	newText, ok := args["new_text"].(string)
 	if !ok {
-		return ErrorResult("new_text is required")
+		return "", "", "", ErrorResult("new_text is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.allowedDir, t.restrict)
+	resolvedPath, err := validatePath(path, t.allowedDir, t.restrict, t.allowed, true)
 	if err != nil {
-		return ErrorResult(err.Error())
+		return "", "", "", ErrorResult(err.Error())
 	}
 
 	if _, err = os.Stat(resolvedPath); os.IsNotExist(err) {
-		return ErrorResult(fmt.Sprintf("file not found: %s", path))
+		return "", "", "", ErrorResult(fmt.Sprintf("file not found: %s", path))
 	}
 
 	content, err := os.ReadFile(resolvedPath)
 	if err != nil {
-		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
+		return "", "", "", ErrorResult(fmt.Sprintf("failed to read file: %v", err))
 	}
 
 	contentStr := string(content)
 
 	if !strings.Contains(contentStr, oldText) {
-		return ErrorResult("old_text not found in file. Make sure it matches exactly")
+		return "", "", "", ErrorResult("old_text not found in file. Make sure it matches exactly")
 	}
 
 	count := strings.Count(contentStr, oldText)
 	if count > 1 {
-		return ErrorResult(
+		return "", "", "", ErrorResult(
 			fmt.Sprintf("old_text appears %d times. Please provide more context to make it unique", count),
 		)
 	}
 
-	newContent := strings.Replace(contentStr, oldText, newText, 1)
-
-	if err := os.WriteFile(resolvedPath, []byte(newContent), 0o644); err != nil {
-		return ErrorResult(fmt.Sprintf("failed to write file: %v", err))
-	}
-
-	return SilentResult(fmt.Sprintf("File edited: %s", path))
+	return path, resolvedPath, strings.Replace(contentStr, oldText, newText, 1), nil
 }
 
 type AppendFileTool struct {
 	workspace string
 	restrict  bool
+	allowed   []config.AllowedPath
+	history   *FileHistory
 }
 
-func NewAppendFileTool(workspace string, restrict bool) *AppendFileTool {
-	return &AppendFileTool{workspace: workspace, restrict: restrict}
+func NewAppendFileTool(workspace string, restrict bool, allowed ...config.AllowedPath) *AppendFileTool {
+	return &AppendFileTool{workspace: workspace, restrict: restrict, allowed: allowed}
 }
 
 func (t *AppendFileTool) Name() string {
 	return "append_file"
 }
 
+func (t *AppendFileTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 func (t *AppendFileTool) Description() string {
 	return "Append content to the end of a file"
 }
@@ -148,11 +172,12 @@ func (t *AppendFileTool) Execute(ctx context.Context, args map[string]any) *Tool
 		return ErrorResult("content is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validatePath(path, t.workspace, t.restrict, t.allowed, true)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
 
+	t.history.Record(ctx, path, resolvedPath)
 	f, err := os.OpenFile(resolvedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to open file: %v", err))
@@ -0,0 +1,349 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// fetchUserAgent names the tool honestly, so robots.txt rules for
+	// picoclaw apply to it.
+	fetchUserAgent   = "Mozilla/5.0 (compatible; picoclaw/1.0; +https://github.com/sipeed/picoclaw)"
+	fetchRobotsAgent = "picoclaw"
+
+	fetchTimeout      = 30 * time.Second
+	fetchMaxRedirects = 5
+	fetchCacheLimit   = 100 // pages cached across all sessions
+)
+
+// FetchURLTool downloads a page and returns its main content as markdown or
+// plain text, with navigation, ads and other boilerplate stripped. Pages are
+// cached per chat, so reading a page again in the same conversation is free.
+type FetchURLTool struct {
+	client        *http.Client
+	maxBytes      int64
+	maxChars      int
+	respectRobots bool
+	cacheTTL      time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	channel string
+	chatID  string
+	pages   map[string]*fetchedPage // session + format + URL -> page
+	robots  map[string]*robotsEntry // scheme://host -> rules
+}
+
+type fetchedPage struct {
+	content   string // header and body, before truncation
+	fetchedAt time.Time
+}
+
+type robotsEntry struct {
+	rules     *robotsRules
+	fetchedAt time.Time
+}
+
+func NewFetchURLTool(cfg config.FetchToolsConfig) *FetchURLTool {
+	t := &FetchURLTool{
+		maxBytes:      int64(cfg.MaxBytes),
+		maxChars:      cfg.MaxChars,
+		respectRobots: cfg.RespectRobots,
+		cacheTTL:      time.Duration(cfg.CacheMinutes) * time.Minute,
+		now:           time.Now,
+		pages:         make(map[string]*fetchedPage),
+		robots:        make(map[string]*robotsEntry),
+	}
+	if t.maxBytes <= 0 {
+		t.maxBytes = 2 << 20
+	}
+	if t.maxChars <= 0 {
+		t.maxChars = 50000
+	}
+	t.client = &http.Client{
+		Timeout: fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", fetchMaxRedirects)
+			}
+			return nil
+		},
+	}
+	return t
+}
+
+func (t *FetchURLTool) Name() string {
+	return "fetch_url"
+}
+
+func (t *FetchURLTool) Risk() RiskLevel {
+	return RiskLow
+}
+
+func (t *FetchURLTool) Description() string {
+	return "Fetch a web page and return its main content as markdown, without menus, ads and other " +
+		"boilerplate. Use it to read articles, documentation or any page found with web_search."
+}
+
+func (t *FetchURLTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "http or https URL to fetch",
+			},
+			"format": map[string]any{
+				"type":        "string",
+				"enum":        []string{"markdown", "text"},
+				"description": "markdown (default) keeps headings, lists and links; text is plain",
+			},
+			"max_chars": map[string]any{
+				"type":        "integer",
+				"description": "Maximum characters to return",
+				"minimum":     100.0,
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *FetchURLTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *FetchURLTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	rawURL, ok := args["url"].(string)
+	if !ok || rawURL == "" {
+		return ErrorResult("url is required")
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid URL: %v", err))
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return ErrorResult("only http/https URLs are allowed")
+	}
+	if target.Host == "" {
+		return ErrorResult("missing domain in URL")
+	}
+	target.Fragment = ""
+
+	markdown := true
+	if format, _ := args["format"].(string); format == "text" {
+		markdown = false
+	}
+	maxChars := t.maxChars
+	if mc, ok := args["max_chars"].(float64); ok && int(mc) >= 100 {
+		maxChars = min(int(mc), t.maxChars)
+	}
+
+	key := t.cacheKey(ctx, target.String(), markdown)
+	content, cached := t.cached(key)
+	if !cached {
+		if t.respectRobots && !t.robotsAllow(ctx, target) {
+			return ErrorResult(fmt.Sprintf("robots.txt of %s does not allow fetching %s", target.Host, target.Path))
+		}
+		content, err = t.fetch(ctx, target, markdown)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		t.store(key, content)
+	}
+
+	if len(content) > maxChars {
+		cut := maxChars
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		content = content[:cut] + fmt.Sprintf("\n\n... (truncated, %d more chars; ask for a larger max_chars)",
+			len(content)-cut)
+	}
+	return NewToolResult(content)
+}
+
+// fetch downloads target and renders it.
+func (t *FetchURLTool) fetch(ctx context.Context, target *url.URL, markdown bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,application/json;q=0.8,*/*;q=0.5")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("fetching %s failed: HTTP %d", target, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	oversized := int64(len(body)) > t.maxBytes
+	if oversized {
+		body = body[:t.maxBytes]
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	final := resp.Request.URL
+	var title, text string
+	switch {
+	case strings.Contains(contentType, "html") || contentType == "" && looksLikeHTML(body):
+		reader, err := charset.NewReader(strings.NewReader(string(body)), contentType)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode page: %v", err)
+		}
+		title, text, err = extractReadable(reader, final, markdown)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse page: %v", err)
+		}
+	case strings.Contains(contentType, "json"):
+		var data any
+		if json.Unmarshal(body, &data) == nil {
+			formatted, _ := json.MarshalIndent(data, "", "  ")
+			text = string(formatted)
+		} else {
+			text = string(body)
+		}
+	case strings.HasPrefix(contentType, "text/"), contentType == "":
+		text = string(body)
+	default:
+		return "", fmt.Errorf("cannot read %s content from %s", contentType, final)
+	}
+
+	var sb strings.Builder
+	if title != "" {
+		if markdown {
+			sb.WriteString("# ")
+		}
+		sb.WriteString(title + "\n")
+	}
+	sb.WriteString("URL: " + final.String() + "\n")
+	if oversized {
+		fmt.Fprintf(&sb, "(page larger than %d bytes; only the start was read)\n", t.maxBytes)
+	}
+	sb.WriteString("\n" + strings.TrimSpace(text))
+	return sb.String(), nil
+}
+
+func (t *FetchURLTool) cacheKey(ctx context.Context, rawURL string, markdown bool) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
+	return fmt.Sprintf("%s:%s\x00%v\x00%s", channel, chatID, markdown, rawURL)
+}
+
+func (t *FetchURLTool) cached(key string) (string, bool) {
+	if t.cacheTTL <= 0 {
+		return "", false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	page, ok := t.pages[key]
+	if !ok || t.now().Sub(page.fetchedAt) > t.cacheTTL {
+		return "", false
+	}
+	return page.content, true
+}
+
+// store caches a page, making room by dropping expired pages or, failing
+// that, the oldest one.
+func (t *FetchURLTool) store(key, content string) {
+	if t.cacheTTL <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if len(t.pages) >= fetchCacheLimit {
+		oldest := ""
+		for k, page := range t.pages {
+			if now.Sub(page.fetchedAt) > t.cacheTTL {
+				delete(t.pages, k)
+			} else if oldest == "" || page.fetchedAt.Before(t.pages[oldest].fetchedAt) {
+				oldest = k
+			}
+		}
+		if len(t.pages) >= fetchCacheLimit {
+			delete(t.pages, oldest)
+		}
+	}
+	t.pages[key] = &fetchedPage{content: content, fetchedAt: now}
+}
+
+// robotsAllow reports whether the site's robots.txt lets picoclaw fetch
+// target. Sites whose robots.txt can't be read are treated as allowing
+// everything.
+func (t *FetchURLTool) robotsAllow(ctx context.Context, target *url.URL) bool {
+	site := target.Scheme + "://" + target.Host
+
+	t.mu.Lock()
+	entry, ok := t.robots[site]
+	t.mu.Unlock()
+	if !ok || t.now().Sub(entry.fetchedAt) > time.Hour {
+		entry = &robotsEntry{rules: t.fetchRobots(ctx, site), fetchedAt: t.now()}
+		t.mu.Lock()
+		t.robots[site] = entry
+		t.mu.Unlock()
+	}
+
+	path := target.EscapedPath()
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return entry.rules.allows(path)
+}
+
+func (t *FetchURLTool) fetchRobots(ctx context.Context, site string) *robotsRules {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512<<10))
+	if err != nil {
+		return nil
+	}
+	return parseRobots(string(body), fetchRobotsAgent)
+}
+
+func looksLikeHTML(body []byte) bool {
+	start := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
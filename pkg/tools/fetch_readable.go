@@ -0,0 +1,412 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// reBoilerplate matches class and id values of page chrome.
+	reBoilerplate = regexp.MustCompile(`(?i)\b(nav|navbar|menu|footer|sidebar|cookies?|banner|breadcrumbs?|` +
+		`share|social|advert|ads|promo|related|subscribe|newsletter|popup|modal|comments)\b`)
+	reSpaces     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	reBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// boilerplateTags never hold a page's main content.
+var boilerplateTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Canvas: true, atom.Iframe: true, atom.Object: true,
+	atom.Nav: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+	atom.Dialog: true, atom.Menu: true,
+}
+
+// boilerplateRoles are ARIA landmark roles of page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"dialog": true, "alertdialog": true, "search": true, "menu": true, "menubar": true,
+}
+
+// extractReadable parses an HTML page and renders its main content. Links
+// are resolved against base.
+func extractReadable(r io.Reader, base *url.URL, markdown bool) (title, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+	if t := findElement(doc, atom.Title); t != nil {
+		title = strings.TrimSpace(reSpaces.ReplaceAllString(nodeText(t), " "))
+	}
+
+	pruneBoilerplate(doc, false)
+
+	w := &readableWriter{base: base, markdown: markdown}
+	w.render(mainContent(doc))
+	return title, w.String(), nil
+}
+
+// pruneBoilerplate removes scripts, navigation, footers and the like. Site
+// headers are removed too, but not the header of an article.
+func pruneBoilerplate(n *html.Node, inContent bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			if isBoilerplate(c, inContent) {
+				n.RemoveChild(c)
+			} else {
+				pruneBoilerplate(c, inContent || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+			}
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node, inContent bool) bool {
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Article, atom.Main:
+		return false
+	case atom.Header:
+		return !inContent
+	}
+	if boilerplateTags[n.DataAtom] {
+		return true
+	}
+	if _, hidden := attr(n, "hidden"); hidden {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if v, _ := attr(n, "role"); boilerplateRoles[v] {
+		return true
+	}
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	if reBoilerplate.MatchString(class + " " + id) {
+		// Don't lose the content to a wrapper with a misleading name
+		return findElement(n, atom.Article) == nil && findElement(n, atom.Main) == nil
+	}
+	return false
+}
+
+// mainContent picks the element holding the page's content: the largest
+// article or main element, else the element with the most paragraph text,
+// else the body.
+func mainContent(doc *html.Node) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	walk(doc, func(n *html.Node) {
+		role, _ := attr(n, "role")
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main || role == "main" {
+			if l := len(strings.TrimSpace(nodeText(n))); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+	})
+	if best != nil && bestLen >= 200 {
+		return best
+	}
+
+	scores := make(map[*html.Node]int)
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre {
+			return
+		}
+		l := len(strings.TrimSpace(nodeText(n)))
+		if l < 25 || n.Parent == nil {
+			return
+		}
+		scores[n.Parent] += l
+		if n.Parent.Parent != nil {
+			scores[n.Parent.Parent] += l / 2
+		}
+	})
+	best, bestScore := nil, 0
+	for n, score := range scores {
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best != nil && bestScore >= 100 {
+		return best
+	}
+
+	if body := findElement(doc, atom.Body); body != nil {
+		return body
+	}
+	return doc
+}
+
+// readableWriter renders HTML as markdown, or as plain text with the same
+// line structure.
+type readableWriter struct {
+	buf      bytes.Buffer
+	base     *url.URL
+	markdown bool
+	pre      int
+	lists    []readableList
+}
+
+type readableList struct {
+	ordered bool
+	n       int
+}
+
+func (w *readableWriter) String() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// block starts a new paragraph.
+func (w *readableWriter) block() {
+	b := w.buf.Bytes()
+	switch {
+	case len(b) == 0, bytes.HasSuffix(b, []byte("\n\n")):
+	case b[len(b)-1] == '\n':
+		w.buf.WriteByte('\n')
+	default:
+		w.buf.WriteString("\n\n")
+	}
+}
+
+// line starts a new line.
+func (w *readableWriter) line() {
+	if b := w.buf.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		w.buf.WriteByte('\n')
+	}
+}
+
+func (w *readableWriter) text(s string) {
+	if w.pre > 0 {
+		w.buf.WriteString(s)
+		return
+	}
+	s = reSpaces.ReplaceAllString(s, " ")
+	if b := w.buf.Bytes(); len(b) == 0 || b[len(b)-1] == ' ' || b[len(b)-1] == '\n' {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.buf.WriteString(s)
+}
+
+// wrap renders n's children between before and after, or nothing when they
+// render as blank.
+func (w *readableWriter) wrap(n *html.Node, before, after string) {
+	start := w.buf.Len()
+	w.buf.WriteString(before)
+	w.children(n)
+	if strings.TrimSpace(w.buf.String()[start+len(before):]) == "" {
+		w.buf.Truncate(start)
+		return
+	}
+	w.buf.WriteString(after)
+}
+
+func (w *readableWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.render(c)
+	}
+}
+
+func (w *readableWriter) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.DocumentNode:
+		w.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.block()
+		prefix := ""
+		if w.markdown {
+			prefix = strings.Repeat("#", int(n.Data[1]-'0')) + " "
+		}
+		w.wrap(n, prefix, "")
+		w.block()
+	case atom.P, atom.Table, atom.Figure, atom.Dl:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.buf.WriteByte('\n')
+	case atom.Hr:
+		w.block()
+		if w.markdown {
+			w.buf.WriteString("---")
+		}
+		w.block()
+	case atom.Ul, atom.Ol:
+		if len(w.lists) == 0 {
+			w.block()
+		}
+		w.lists = append(w.lists, readableList{ordered: n.DataAtom == atom.Ol})
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		if len(w.lists) == 0 {
+			w.block()
+		}
+	case atom.Li:
+		w.line()
+		marker := "- "
+		if depth := len(w.lists); depth > 0 {
+			list := &w.lists[depth-1]
+			list.n++
+			if list.ordered {
+				marker = strconv.Itoa(list.n) + ". "
+			}
+			marker = strings.Repeat("  ", depth-1) + marker
+		}
+		w.wrap(n, marker, "")
+		w.line()
+	case atom.Pre:
+		w.block()
+		w.pre++
+		if w.markdown {
+			w.wrap(n, "```\n", "\n```")
+		} else {
+			w.children(n)
+		}
+		w.pre--
+		w.block()
+	case atom.Code:
+		if w.pre > 0 || !w.markdown {
+			w.children(n)
+		} else {
+			w.wrap(n, "`", "`")
+		}
+	case atom.Strong, atom.B:
+		w.inline(n, "**")
+	case atom.Em, atom.I:
+		w.inline(n, "*")
+	case atom.A:
+		href, _ := attr(n, "href")
+		link := w.resolve(href)
+		if !w.markdown || link == "" {
+			w.children(n)
+		} else {
+			w.wrap(n, "[", "]("+link+")")
+		}
+	case atom.Blockquote:
+		w.block()
+		inner := &readableWriter{base: w.base, markdown: w.markdown}
+		inner.children(n)
+		prefix := ""
+		if w.markdown {
+			prefix = "> "
+		}
+		for _, line := range strings.Split(inner.String(), "\n") {
+			w.buf.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+		}
+		w.block()
+	case atom.Tr:
+		w.line()
+		w.children(n)
+		w.line()
+	case atom.Td, atom.Th:
+		if b := w.buf.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+			w.buf.WriteString(" | ")
+		}
+		w.children(n)
+	case atom.Dt:
+		w.line()
+		w.inline(n, "**")
+		w.line()
+	case atom.Img, atom.Picture, atom.Video, atom.Audio, atom.Source:
+		// Media can't be read as text
+	case atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Figcaption, atom.Dd,
+		atom.Address, atom.Details, atom.Summary:
+		w.line()
+		w.children(n)
+		w.line()
+	default:
+		w.children(n)
+	}
+}
+
+// inline renders emphasis, which plain text drops.
+func (w *readableWriter) inline(n *html.Node, mark string) {
+	if !w.markdown {
+		w.children(n)
+		return
+	}
+	w.wrap(n, mark, mark)
+}
+
+// resolve makes href absolute; links that don't lead to a page resolve to "".
+func (w *readableWriter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if w.base != nil {
+		u = w.base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto" {
+		return ""
+	}
+	return u.String()
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+)
+
+// robotsRules are the Allow and Disallow lines of the robots.txt group that
+// applies to one user agent. A nil *robotsRules allows everything.
+type robotsRules struct {
+	rules []robotsRule
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// parseRobots picks the group naming agent, or the "*" group when there is
+// none, as RFC 9309 describes.
+func parseRobots(content, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+
+	var named, wildcard []robotsRule
+	var groupAgents []string
+	inRules := false // a rule line ends the list of agents of a group
+	foundNamed := false
+
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // "Disallow:" with no path allows everything
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)}
+			for _, a := range groupAgents {
+				switch {
+				case a == agent:
+					named = append(named, rule)
+					foundNamed = true
+				case a == "*":
+					wildcard = append(wildcard, rule)
+				}
+			}
+		}
+	}
+
+	if foundNamed {
+		return &robotsRules{rules: named}
+	}
+	return &robotsRules{rules: wildcard}
+}
+
+// robotsPattern compiles a path pattern, where * matches anything and a
+// trailing $ anchors the end.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allows applies the longest matching rule; Allow wins a tie.
+func (r *robotsRules) allows(path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+	allowed, best := true, -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || n == best && rule.allow {
+			allowed, best = rule.allow, n
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const fetchTestPage = `<!DOCTYPE html>
+<html><head><title>Release notes</title><script>track()</script></head>
+<body>
+<header class="site-header"><a href="/">Home</a> <a href="/blog">Blog</a></header>
+<nav><ul><li>Docs</li><li>Pricing</li></ul></nav>
+<div class="cookie-banner">We use cookies</div>
+<main>
+  <article>
+    <h1>Version 2.0</h1>
+    <p>This release adds <strong>streaming</strong> and fixes the
+       <a href="/issues/42">memory leak</a> reported last month.</p>
+    <h2>Upgrading</h2>
+    <ol><li>Stop the service</li><li>Install the <code>v2</code> package</li></ol>
+    <pre>picoclaw upgrade
+picoclaw gateway</pre>
+    <div class="share-buttons">Share on social media</div>
+  </article>
+</main>
+<footer>Copyright 2026</footer>
+</body></html>`
+
+func newFetchTestServer(t *testing.T, robots string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var pageHits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			if robots == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(robots))
+		case "/notes":
+			pageHits.Add(1)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(fetchTestPage))
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		case "/big":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("a", 5000)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &pageHits
+}
+
+func testFetchConfig() config.FetchToolsConfig {
+	return config.FetchToolsConfig{MaxBytes: 1 << 20, MaxChars: 50000, RespectRobots: true, CacheMinutes: 15}
+}
+
+func TestFetchURLTool_Markdown(t *testing.T) {
+	server, _ := newFetchTestServer(t, "")
+	tool := NewFetchURLTool(testFetchConfig())
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/notes#upgrading"})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+
+	want := "# Release notes\nURL: " + server.URL + "/notes\n\n" +
+		"# Version 2.0\n\n" +
+		"This release adds **streaming** and fixes the [memory leak](" + server.URL + "/issues/42) " +
+		"reported last month.\n\n" +
+		"## Upgrading\n\n" +
+		"1. Stop the service\n2. Install the `v2` package\n\n" +
+		"```\npicoclaw upgrade\npicoclaw gateway\n```"
+	if result.ForLLM != want {
+		t.Errorf("ForLLM =\n%s\n\nwant\n%s", result.ForLLM, want)
+	}
+	if result.ForUser != "" {
+		t.Errorf("ForUser = %q, want page kept from the user", result.ForUser)
+	}
+}
+
+func TestFetchURLTool_Text(t *testing.T) {
+	server, _ := newFetchTestServer(t, "")
+	tool := NewFetchURLTool(testFetchConfig())
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/notes", "format": "text"})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	for _, unwanted := range []string{"**", "](", "```", "Pricing", "cookies", "Copyright", "Share on", "track()"} {
+		if strings.Contains(result.ForLLM, unwanted) {
+			t.Errorf("text contains %q:\n%s", unwanted, result.ForLLM)
+		}
+	}
+	if !strings.Contains(result.ForLLM, "fixes the memory leak reported") {
+		t.Errorf("text is missing the article:\n%s", result.ForLLM)
+	}
+}
+
+func TestFetchURLTool_OtherContent(t *testing.T) {
+	server, _ := newFetchTestServer(t, "")
+	tool := NewFetchURLTool(config.FetchToolsConfig{MaxBytes: 1000, MaxChars: 50000})
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/data.json"})
+	if result.IsError || !strings.Contains(result.ForLLM, "\"ok\": true") {
+		t.Errorf("json: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL + "/image.png"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "image/png") {
+		t.Errorf("image: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL + "/big", "max_chars": 200.0})
+	if result.IsError {
+		t.Fatalf("big: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "larger than 1000 bytes") || !strings.Contains(result.ForLLM, "truncated") {
+		t.Errorf("big: expected size notes, got %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL + "/missing"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "404") {
+		t.Errorf("missing: %s", result.ForLLM)
+	}
+
+	for _, bad := range []string{"file:///etc/passwd", "https://", "not a url"} {
+		if result := tool.Execute(context.Background(), map[string]any{"url": bad}); !result.IsError {
+			t.Errorf("url %q accepted", bad)
+		}
+	}
+}
+
+func TestFetchURLTool_Robots(t *testing.T) {
+	robots := "User-agent: *\nDisallow: /\n\nUser-agent: picoclaw\nDisallow: /notes\nAllow: /data.json\n"
+	server, hits := newFetchTestServer(t, robots)
+
+	tool := NewFetchURLTool(testFetchConfig())
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/notes"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "robots.txt") {
+		t.Errorf("disallowed page fetched: %s", result.ForLLM)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("page requested %d times despite robots.txt", hits.Load())
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/data.json"}); result.IsError {
+		t.Errorf("allowed page refused: %s", result.ForLLM)
+	}
+
+	cfg := testFetchConfig()
+	cfg.RespectRobots = false
+	tool = NewFetchURLTool(cfg)
+	if result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/notes"}); result.IsError {
+		t.Errorf("robots.txt applied with respect_robots off: %s", result.ForLLM)
+	}
+}
+
+func TestFetchURLTool_CachePerSession(t *testing.T) {
+	server, hits := newFetchTestServer(t, "")
+	tool := NewFetchURLTool(testFetchConfig())
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tool.now = func() time.Time { return now }
+	args := map[string]any{"url": server.URL + "/notes"}
+
+	tool.SetContext("telegram", "1")
+	tool.Execute(context.Background(), args)
+	tool.Execute(context.Background(), args)
+	if hits.Load() != 1 {
+		t.Errorf("page fetched %d times in one session, want 1", hits.Load())
+	}
+
+	tool.SetContext("telegram", "2")
+	tool.Execute(context.Background(), args)
+	if hits.Load() != 2 {
+		t.Errorf("page fetched %d times after switching chats, want 2", hits.Load())
+	}
+
+	now = now.Add(16 * time.Minute)
+	tool.Execute(context.Background(), args)
+	if hits.Load() != 3 {
+		t.Errorf("page fetched %d times after the cache expired, want 3", hits.Load())
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots(`# comment
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public-*
+Disallow: /*.pdf$
+Disallow:
+`, "picoclaw")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/blog/post", true},
+		{"/private/notes", false},
+		{"/private/public-page", true},
+		{"/files/report.pdf", false},
+		{"/files/report.pdf?download=1", true},
+	}
+	for _, tt := range tests {
+		if got := rules.allows(tt.path); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if !(*robotsRules)(nil).allows("/anything") {
+		t.Error("nil rules should allow everything")
+	}
+}
@@ -6,10 +6,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
-// validatePath ensures the given path is within the workspace if restrict is true.
-func validatePath(path, workspace string, restrict bool) (string, error) {
+// validatePath ensures the given path is within the workspace if restrict is
+// true, or within one of the allowed exceptions, which must permit writing
+// when write is set. Symlinks are followed, so a link inside the workspace
+// can't lead out of it.
+func validatePath(
+	path, workspace string,
+	restrict bool,
+	allowed []config.AllowedPath,
+	write bool,
+) (string, error) {
 	if workspace == "" {
 		return path, nil
 	}
@@ -30,35 +40,105 @@ func validatePath(path, workspace string, restrict bool) (string, error) {
 	}
 
 	if restrict {
-		if !isWithinWorkspace(absPath, absWorkspace) {
-			return "", fmt.Errorf("access denied: path is outside the workspace")
+		if err := checkWithinWorkspace(absPath, absWorkspace); err != nil {
+			entry, ok := allowedPath(absPath, allowed)
+			if !ok {
+				return "", err
+			}
+			if write && !entry.Write {
+				return "", fmt.Errorf("access denied: %s is read-only", entry.Path)
+			}
 		}
+	}
 
-		var resolved string
-		workspaceReal := absWorkspace
-		if resolved, err = filepath.EvalSymlinks(absWorkspace); err == nil {
-			workspaceReal = resolved
-		}
+	return absPath, nil
+}
 
-		if resolved, err = filepath.EvalSymlinks(absPath); err == nil {
-			if !isWithinWorkspace(resolved, workspaceReal) {
-				return "", fmt.Errorf("access denied: symlink resolves outside workspace")
-			}
-		} else if os.IsNotExist(err) {
-			var parentResolved string
-			if parentResolved, err = resolveExistingAncestor(filepath.Dir(absPath)); err == nil {
-				if !isWithinWorkspace(parentResolved, workspaceReal) {
-					return "", fmt.Errorf("access denied: symlink resolves outside workspace")
-				}
-			} else if !os.IsNotExist(err) {
-				return "", fmt.Errorf("failed to resolve path: %w", err)
+// WorkspacePath resolves path, relative to workspace unless absolute, and
+// returns an error when it or a symlink along it leads outside workspace.
+func WorkspacePath(path, workspace string) (string, error) {
+	return validatePath(path, workspace, true, nil, false)
+}
+
+// checkWithinWorkspace returns an error when absPath, or what it resolves
+// to, lies outside the workspace.
+func checkWithinWorkspace(absPath, absWorkspace string) error {
+	if !isWithinWorkspace(absPath, absWorkspace) {
+		return fmt.Errorf("access denied: path is outside the workspace")
+	}
+
+	workspaceReal := absWorkspace
+	if resolved, err := filepath.EvalSymlinks(absWorkspace); err == nil {
+		workspaceReal = resolved
+	}
+
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		if !isWithinWorkspace(resolved, workspaceReal) {
+			return fmt.Errorf("access denied: symlink resolves outside workspace")
+		}
+	} else if os.IsNotExist(err) {
+		if parentResolved, err := resolveExistingAncestor(filepath.Dir(absPath)); err == nil {
+			if !isWithinWorkspace(parentResolved, workspaceReal) {
+				return fmt.Errorf("access denied: symlink resolves outside workspace")
 			}
-		} else {
-			return "", fmt.Errorf("failed to resolve path: %w", err)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to resolve path: %w", err)
 		}
+	} else {
+		return fmt.Errorf("failed to resolve path: %w", err)
 	}
+	return nil
+}
 
-	return absPath, nil
+// allowedPath returns the allowed entry that absPath really lies in, after
+// following symlinks, so a link can't borrow an exception for another path.
+func allowedPath(absPath string, allowed []config.AllowedPath) (config.AllowedPath, bool) {
+	if len(allowed) == 0 {
+		return config.AllowedPath{}, false
+	}
+	real, err := realPath(absPath)
+	if err != nil {
+		return config.AllowedPath{}, false
+	}
+	for _, entry := range allowed {
+		if entry.Path == "" || !filepath.IsAbs(entry.Path) {
+			continue
+		}
+		entryReal, err := realPath(filepath.Clean(entry.Path))
+		if err != nil {
+			continue
+		}
+		if isWithinWorkspace(real, entryReal) {
+			return entry, true
+		}
+	}
+	return config.AllowedPath{}, false
+}
+
+// realPath resolves the symlinks in path. For a path that doesn't exist
+// yet, the missing part is kept as is under its resolved ancestor.
+func realPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			rest, err := filepath.Rel(dir, path)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(resolved, rest), nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		if filepath.Dir(dir) == dir {
+			return path, nil
+		}
+	}
 }
 
 func resolveExistingAncestor(path string) (string, error) {
@@ -82,18 +162,23 @@ func isWithinWorkspace(candidate, workspace string) bool {
 type ReadFileTool struct {
 	workspace string
 	restrict  bool
+	allowed   []config.AllowedPath
 }
 
-func NewReadFileTool(workspace string, restrict bool) *ReadFileTool {
-	return &ReadFileTool{workspace: workspace, restrict: restrict}
+func NewReadFileTool(workspace string, restrict bool, allowed ...config.AllowedPath) *ReadFileTool {
+	return &ReadFileTool{workspace: workspace, restrict: restrict, allowed: allowed}
 }
 
 func (t *ReadFileTool) Name() string {
 	return "read_file"
 }
 
+func (t *ReadFileTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return "Read the contents of a file. Use start_line and end_line to read part of a large file"
 }
 
 func (t *ReadFileTool) Parameters() map[string]any {
@@ -104,6 +189,14 @@ func (t *ReadFileTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"start_line": map[string]any{
+				"type":        "integer",
+				"description": "Optional first line to read, counting from 1",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "Optional last line to read (inclusive); defaults to the end of the file",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -115,7 +208,7 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("path is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -125,22 +218,50 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
 	}
 
-	return NewToolResult(string(content))
+	start, hasStart := args["start_line"].(float64)
+	end, hasEnd := args["end_line"].(float64)
+	if !hasStart && !hasEnd {
+		return NewToolResult(string(content))
+	}
+	if !hasStart {
+		start = 1
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+	if !hasEnd || int(end) > total {
+		end = float64(total)
+	}
+	if start < 1 || int(start) > total || end < start {
+		return ErrorResult(fmt.Sprintf("line range %d-%d is outside the file (%d lines)", int(start), int(end), total))
+	}
+
+	header := fmt.Sprintf("[lines %d-%d of %d]\n", int(start), int(end), total)
+	return NewToolResult(header + strings.Join(lines[int(start)-1:int(end)], ""))
 }
 
 type WriteFileTool struct {
 	workspace string
 	restrict  bool
+	allowed   []config.AllowedPath
+	history   *FileHistory
 }
 
-func NewWriteFileTool(workspace string, restrict bool) *WriteFileTool {
-	return &WriteFileTool{workspace: workspace, restrict: restrict}
+func NewWriteFileTool(workspace string, restrict bool, allowed ...config.AllowedPath) *WriteFileTool {
+	return &WriteFileTool{workspace: workspace, restrict: restrict, allowed: allowed}
 }
 
 func (t *WriteFileTool) Name() string {
 	return "write_file"
 }
 
+func (t *WriteFileTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 func (t *WriteFileTool) Description() string {
 	return "Write content to a file"
 }
@@ -173,7 +294,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		return ErrorResult("content is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validatePath(path, t.workspace, t.restrict, t.allowed, true)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -183,6 +304,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		return ErrorResult(fmt.Sprintf("failed to create directory: %v", err))
 	}
 
+	t.history.Record(ctx, path, resolvedPath)
 	if err := os.WriteFile(resolvedPath, []byte(content), 0o644); err != nil {
 		return ErrorResult(fmt.Sprintf("failed to write file: %v", err))
 	}
@@ -193,16 +315,21 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 type ListDirTool struct {
 	workspace string
 	restrict  bool
+	allowed   []config.AllowedPath
 }
 
-func NewListDirTool(workspace string, restrict bool) *ListDirTool {
-	return &ListDirTool{workspace: workspace, restrict: restrict}
+func NewListDirTool(workspace string, restrict bool, allowed ...config.AllowedPath) *ListDirTool {
+	return &ListDirTool{workspace: workspace, restrict: restrict, allowed: allowed}
 }
 
 func (t *ListDirTool) Name() string {
 	return "list_dir"
 }
 
+func (t *ListDirTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
 func (t *ListDirTool) Description() string {
 	return "List files and directories in a path"
 }
@@ -226,7 +353,7 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		path = "."
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -247,3 +374,135 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 
 	return NewToolResult(result)
 }
+
+// globMaxResults caps how many paths GlobTool returns.
+const globMaxResults = 200
+
+type GlobTool struct {
+	workspace string
+	restrict  bool
+	allowed   []config.AllowedPath
+}
+
+func NewGlobTool(workspace string, restrict bool, allowed ...config.AllowedPath) *GlobTool {
+	return &GlobTool{workspace: workspace, restrict: restrict, allowed: allowed}
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files by name pattern, e.g. \"**/*.md\" or \"notes/2026-*.txt\". " +
+		"** matches any number of directories"
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Pattern relative to path; * and ? match within a name, ** across directories",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search from; defaults to the workspace",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if _, err := filepath.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+	if strings.HasPrefix(pattern, "../") || pattern == ".." {
+		return ErrorResult("pattern must not leave the search directory")
+	}
+
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		path = "."
+	}
+	root, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	var matches []string
+	truncated := false
+	err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip what can't be read
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if matchGlob(pattern, rel) {
+			if len(matches) == globMaxResults {
+				truncated = true
+				return filepath.SkipAll
+			}
+			if d.IsDir() {
+				rel += "/"
+			}
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search: %v", err))
+	}
+
+	if len(matches) == 0 {
+		return NewToolResult("No files match " + pattern)
+	}
+	result := strings.Join(matches, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n... (stopped after %d matches; narrow the pattern)", globMaxResults)
+	}
+	return NewToolResult(result)
+}
+
+// matchGlob reports whether the slash-separated path matches pattern, where
+// a "**" segment matches zero or more directories.
+func matchGlob(pattern, path string) bool {
+	return matchGlobParts(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchGlobParts(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchGlobParts(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
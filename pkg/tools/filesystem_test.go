@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // TestFilesystemTool_ReadFile_Success verifies successful file reading
@@ -279,3 +281,120 @@ func TestFilesystemTool_ReadFile_RejectsSymlinkEscape(t *testing.T) {
 		t.Fatalf("expected symlink escape error, got: %s", result.ForLLM)
 	}
 }
+
+// TestFilesystemTool_AllowedPaths verifies the exceptions to the workspace
+// restriction, and that they stay read-only unless marked writable
+func TestFilesystemTool_AllowedPaths(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "workspace")
+	shared := filepath.Join(root, "shared")
+	os.MkdirAll(workspace, 0o755)
+	os.MkdirAll(shared, 0o755)
+	hosts := filepath.Join(root, "hosts")
+	os.WriteFile(hosts, []byte("127.0.0.1 localhost"), 0o644)
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte("top secret"), 0o644)
+
+	allowed := []config.AllowedPath{{Path: hosts}, {Path: shared, Write: true}}
+	ctx := context.Background()
+	read := NewReadFileTool(workspace, true, allowed...)
+	write := NewWriteFileTool(workspace, true, allowed...)
+
+	if result := read.Execute(ctx, map[string]any{"path": hosts}); result.IsError {
+		t.Errorf("reading an allowed file failed: %s", result.ForLLM)
+	}
+	result := write.Execute(ctx, map[string]any{"path": hosts, "content": "x"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "read-only") {
+		t.Errorf("writing a read-only allowed file = %q", result.ForLLM)
+	}
+	newFile := filepath.Join(shared, "new", "a.txt")
+	if result := write.Execute(ctx, map[string]any{"path": newFile, "content": "x"}); result.IsError {
+		t.Errorf("writing under a writable allowed dir failed: %s", result.ForLLM)
+	}
+	if result := read.Execute(ctx, map[string]any{"path": filepath.Join(root, "secret.txt")}); !result.IsError {
+		t.Error("reading outside the workspace and allowed paths should fail")
+	}
+	// Relative escapes are no way around the allowlist either
+	if result := read.Execute(ctx, map[string]any{"path": "../shared/../secret.txt"}); !result.IsError {
+		t.Error("relative escape should fail")
+	}
+
+	// A symlink in an allowed dir can't lead to a path that isn't allowed
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(shared, "leak.txt")); err != nil {
+		t.Skipf("symlink not supported in this environment: %v", err)
+	}
+	if result := read.Execute(ctx, map[string]any{"path": filepath.Join(shared, "leak.txt")}); !result.IsError {
+		t.Error("symlink out of an allowed dir should fail")
+	}
+}
+
+// TestFilesystemTool_ReadFile_LineRange verifies reading part of a file
+func TestFilesystemTool_ReadFile_LineRange(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "notes.txt")
+	os.WriteFile(testFile, []byte("one\ntwo\nthree\nfour\n"), 0o644)
+
+	tool := NewReadFileTool(tmpDir, true)
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"start_line": 2.0, "end_line": 3.0}, "[lines 2-3 of 4]\ntwo\nthree\n"},
+		{map[string]any{"start_line": 3.0}, "[lines 3-4 of 4]\nthree\nfour\n"},
+		{map[string]any{"end_line": 1.0}, "[lines 1-1 of 4]\none\n"},
+		{map[string]any{"start_line": 4.0, "end_line": 99.0}, "[lines 4-4 of 4]\nfour\n"},
+	}
+	for _, tt := range tests {
+		tt.args["path"] = "notes.txt"
+		result := tool.Execute(context.Background(), tt.args)
+		if result.IsError || result.ForLLM != tt.want {
+			t.Errorf("Execute(%v) = %q, want %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+
+	for _, args := range []map[string]any{
+		{"path": "notes.txt", "start_line": 5.0},
+		{"path": "notes.txt", "start_line": 0.0},
+		{"path": "notes.txt", "start_line": 3.0, "end_line": 2.0},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("Execute(%v) succeeded, want a range error: %s", args, result.ForLLM)
+		}
+	}
+}
+
+// TestFilesystemTool_Glob verifies pattern matching across directories
+func TestFilesystemTool_Glob(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"README.md", "notes/a.md", "notes/deep/b.md", "notes/c.txt"} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("x"), 0o644)
+	}
+
+	tool := NewGlobTool(tmpDir, true)
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"pattern": "**/*.md"}, "README.md\nnotes/a.md\nnotes/deep/b.md"},
+		{map[string]any{"pattern": "notes/*"}, "notes/a.md\nnotes/c.txt\nnotes/deep/"},
+		{map[string]any{"pattern": "*.txt", "path": "notes"}, "c.txt"},
+		{map[string]any{"pattern": "*.go"}, "No files match *.go"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), tt.args)
+		if result.IsError || result.ForLLM != tt.want {
+			t.Errorf("Execute(%v) = %q, want %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+
+	for _, args := range []map[string]any{
+		{"pattern": "../*"},
+		{"pattern": "*", "path": filepath.Dir(tmpDir)},
+		{"pattern": "[", "path": "."},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("Execute(%v) succeeded, want an error: %s", args, result.ForLLM)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/imagegen"
+)
+
+var reImageSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// GenerateImageTool creates an image from a prompt, saves it in the
+// workspace and sends it to the current chat.
+type GenerateImageTool struct {
+	generator imagegen.Generator
+	workspace string
+	dir       string
+	size      string
+	send      MediaSendCallback
+	now       func() time.Time
+
+	mu      sync.RWMutex
+	channel string
+	chatID  string
+}
+
+func NewGenerateImageTool(
+	generator imagegen.Generator,
+	workspace string,
+	cfg config.ImageToolsConfig,
+) *GenerateImageTool {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "images"
+	}
+	return &GenerateImageTool{
+		generator: generator,
+		workspace: workspace,
+		dir:       dir,
+		size:      cfg.Size,
+		now:       time.Now,
+	}
+}
+
+func (t *GenerateImageTool) Name() string {
+	return "generate_image"
+}
+
+func (t *GenerateImageTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *GenerateImageTool) Description() string {
+	return "Create an image from a text description. The image is saved in the workspace and sent to the user " +
+		"in this chat, with an optional caption, on channels that can send images. Describe the subject, " +
+		"style, composition and colors in the prompt."
+}
+
+func (t *GenerateImageTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "Description of the image to create",
+			},
+			"size": map[string]any{
+				"type":        "string",
+				"description": "Optional: WIDTHxHEIGHT, e.g. 1024x1024 or 1792x1024",
+			},
+			"caption": map[string]any{
+				"type":        "string",
+				"description": "Optional: text sent along with the image",
+			},
+		},
+		"required": []string{"prompt"},
+	}
+}
+
+func (t *GenerateImageTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// SetMediaSendCallback sets how images are delivered to the chat.
+func (t *GenerateImageTool) SetMediaSendCallback(callback MediaSendCallback) {
+	t.send = callback
+}
+
+func (t *GenerateImageTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	prompt, _ := args["prompt"].(string)
+	if strings.TrimSpace(prompt) == "" {
+		return ErrorResult("prompt is required")
+	}
+	size, _ := args["size"].(string)
+	if size == "" {
+		size = t.size
+	}
+	caption, _ := args["caption"].(string)
+
+	data, err := t.generator.Generate(ctx, prompt, size)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("generating image: %v", err)).WithError(err)
+	}
+
+	ext := ".png"
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/webp":
+		ext = ".webp"
+	}
+	rel := filepath.Join(t.dir, t.now().Format("20060102-150405")+"-"+imageSlug(prompt)+ext)
+	path := filepath.Join(t.workspace, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return ErrorResult(fmt.Sprintf("saving image: %v", err)).WithError(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return ErrorResult(fmt.Sprintf("saving image: %v", err)).WithError(err)
+	}
+
+	t.mu.RLock()
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
+	t.mu.RUnlock()
+	if t.send == nil || channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		return NewToolResult(fmt.Sprintf("Image saved to %s", path))
+	}
+	if err := t.send(channel, chatID, caption, []string{path}); err != nil {
+		return ErrorResult(fmt.Sprintf("Image saved to %s but sending it failed: %v", path, err)).WithError(err)
+	}
+	return NewToolResult(fmt.Sprintf("Image saved to %s and sent to the chat", path))
+}
+
+// imageSlug makes a short file name part from the start of the prompt.
+func imageSlug(prompt string) string {
+	slug := strings.Trim(reImageSlug.ReplaceAllString(strings.ToLower(prompt), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		return "image"
+	}
+	return slug
+}
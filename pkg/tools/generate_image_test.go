@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type fakeImageGenerator struct {
+	prompt, size string
+	err          error
+}
+
+func (g *fakeImageGenerator) Generate(ctx context.Context, prompt, size string) ([]byte, error) {
+	g.prompt, g.size = prompt, size
+	return []byte("\x89PNG\r\n\x1a\n"), g.err
+}
+
+func TestGenerateImageTool(t *testing.T) {
+	workspace := t.TempDir()
+	gen := &fakeImageGenerator{}
+	tool := NewGenerateImageTool(gen, workspace, config.ImageToolsConfig{Size: "1024x1024", Dir: "images"})
+	tool.now = func() time.Time { return time.Date(2026, 3, 14, 9, 30, 0, 0, time.Local) }
+
+	var sentTo, caption string
+	var media []string
+	tool.SetMediaSendCallback(func(channel, chatID, content string, files []string) error {
+		sentTo, caption, media = channel+":"+chatID, content, files
+		return nil
+	})
+	tool.SetContext("telegram", "42")
+
+	result := tool.Execute(context.Background(), map[string]any{"prompt": "A Red Fox, in snow!", "caption": "Here"})
+	if result.IsError {
+		t.Fatalf("Execute() error = %s", result.ForLLM)
+	}
+	want := filepath.Join(workspace, "images", "20260314-093000-a-red-fox-in-snow.png")
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("image not saved at %s: %v", want, err)
+	}
+	if gen.size != "1024x1024" {
+		t.Errorf("size = %q, want the configured default", gen.size)
+	}
+	if sentTo != "telegram:42" || caption != "Here" || len(media) != 1 || media[0] != want {
+		t.Errorf("sent %v to %s with caption %q", media, sentTo, caption)
+	}
+	if !strings.Contains(result.ForLLM, "sent to the chat") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+
+	// Nothing is sent from the CLI
+	sentTo = ""
+	tool.SetContext("cli", "direct")
+	result = tool.Execute(context.Background(), map[string]any{"prompt": "a fox", "size": "512x512"})
+	if result.IsError || sentTo != "" || gen.size != "512x512" {
+		t.Errorf("cli result = %+v, sent to %q, size %q", result, sentTo, gen.size)
+	}
+
+	gen.err = errors.New("quota exceeded")
+	if result := tool.Execute(context.Background(), map[string]any{"prompt": "a fox"}); !result.IsError {
+		t.Errorf("generator error not reported: %+v", result)
+	}
+	if result := tool.Execute(context.Background(), map[string]any{}); !result.IsError {
+		t.Errorf("missing prompt accepted: %+v", result)
+	}
+}
@@ -3,27 +3,63 @@ package tools
 import (
 	"context"
 	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 type SendCallback func(channel, chatID, content string) error
 
+// MediaSendCallback delivers a message with local file attachments.
+type MediaSendCallback func(channel, chatID, content string, media []string) error
+
 type MessageTool struct {
 	sendCallback   SendCallback
+	mediaCallback  MediaSendCallback
 	defaultChannel string
 	defaultChatID  string
-	sentInRound    bool // Tracks whether a message was sent in the current processing round
+	workspace      string
+	restrict       bool
+	allowed        []config.AllowedPath
+}
+
+// MessageRound tracks whether the message tool sent anything while one
+// message was processed, so the reply isn't sent a second time.
+type MessageRound struct {
+	sent atomic.Bool
+}
+
+type messageRoundKey struct{}
+
+// WithMessageRound returns a context whose message tool calls are tracked
+// by round.
+func WithMessageRound(ctx context.Context, round *MessageRound) context.Context {
+	return context.WithValue(ctx, messageRoundKey{}, round)
+}
+
+// Sent returns true if the message tool sent a message during the round.
+func (r *MessageRound) Sent() bool {
+	return r.sent.Load()
 }
 
-func NewMessageTool() *MessageTool {
-	return &MessageTool{}
+// NewMessageTool returns the message tool. Attachments are held to the
+// same workspace rules as the file tools.
+func NewMessageTool(workspace string, restrict bool, allowed ...config.AllowedPath) *MessageTool {
+	return &MessageTool{workspace: workspace, restrict: restrict, allowed: allowed}
 }
 
 func (t *MessageTool) Name() string {
 	return "message"
 }
 
+func (t *MessageTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
 func (t *MessageTool) Description() string {
-	return "Send a message to user on a chat channel. Use this when you want to communicate something."
+	return "Send a message to user on a chat channel. Use this when you want to communicate something. " +
+		"Local files (images, documents, audio) can be attached with media on channels that support it."
 }
 
 func (t *MessageTool) Parameters() map[string]any {
@@ -42,6 +78,11 @@ func (t *MessageTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional: target chat/user ID",
 			},
+			"media": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Optional: local file paths to send as attachments",
+			},
 		},
 		"required": []string{"content"},
 	}
@@ -50,18 +91,16 @@ func (t *MessageTool) Parameters() map[string]any {
 func (t *MessageTool) SetContext(channel, chatID string) {
 	t.defaultChannel = channel
 	t.defaultChatID = chatID
-	t.sentInRound = false // Reset send tracking for new processing round
-}
-
-// HasSentInRound returns true if the message tool sent a message during the current round.
-func (t *MessageTool) HasSentInRound() bool {
-	return t.sentInRound
 }
 
 func (t *MessageTool) SetSendCallback(callback SendCallback) {
 	t.sendCallback = callback
 }
 
+func (t *MessageTool) SetMediaSendCallback(callback MediaSendCallback) {
+	t.mediaCallback = callback
+}
+
 func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	content, ok := args["content"].(string)
 	if !ok {
@@ -71,33 +110,84 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 	channel, _ := args["channel"].(string)
 	chatID, _ := args["chat_id"].(string)
 
+	defaultChannel, defaultChatID := ChatFrom(ctx, t.defaultChannel, t.defaultChatID)
 	if channel == "" {
-		channel = t.defaultChannel
+		channel = defaultChannel
 	}
 	if chatID == "" {
-		chatID = t.defaultChatID
+		chatID = defaultChatID
 	}
 
 	if channel == "" || chatID == "" {
 		return &ToolResult{ForLLM: "No target channel/chat specified", IsError: true}
 	}
 
-	if t.sendCallback == nil {
-		return &ToolResult{ForLLM: "Message sending not configured", IsError: true}
+	media, err := t.parseMediaArg(args["media"])
+	if err != nil {
+		return ErrorResult(err.Error())
 	}
 
-	if err := t.sendCallback(channel, chatID, content); err != nil {
+	var sendErr error
+	if len(media) > 0 {
+		if t.mediaCallback == nil {
+			return &ToolResult{ForLLM: "Sending attachments is not configured", IsError: true}
+		}
+		sendErr = t.mediaCallback(channel, chatID, content, media)
+	} else {
+		if t.sendCallback == nil {
+			return &ToolResult{ForLLM: "Message sending not configured", IsError: true}
+		}
+		sendErr = t.sendCallback(channel, chatID, content)
+	}
+
+	if sendErr != nil {
 		return &ToolResult{
-			ForLLM:  fmt.Sprintf("sending message: %v", err),
+			ForLLM:  fmt.Sprintf("sending message: %v", sendErr),
 			IsError: true,
-			Err:     err,
+			Err:     sendErr,
 		}
 	}
 
-	t.sentInRound = true
+	if round, ok := ctx.Value(messageRoundKey{}).(*MessageRound); ok {
+		round.sent.Store(true)
+	}
 	// Silent: user already received the message directly
 	return &ToolResult{
 		ForLLM: fmt.Sprintf("Message sent to %s:%s", channel, chatID),
 		Silent: true,
 	}
 }
+
+// parseMediaArg validates the optional media argument: a list of existing
+// regular files the file tools could read.
+func (t *MessageTool) parseMediaArg(raw any) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("media must be an array of file paths")
+	}
+
+	media := make([]string, 0, len(items))
+	for _, item := range items {
+		path, ok := item.(string)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("media entries must be non-empty file paths")
+		}
+		path, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
+		if err != nil {
+			return nil, fmt.Errorf("media file %s: %w", item, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("media file %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("media file %s is not a regular file", path)
+		}
+		media = append(media, path)
+	}
+	return media, nil
+}
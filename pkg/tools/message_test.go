@@ -3,11 +3,13 @@ package tools
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestMessageTool_Execute_Success(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	tool.SetContext("test-channel", "test-chat-id")
 
 	var sentChannel, sentChatID, sentContent string
@@ -59,7 +61,7 @@ func TestMessageTool_Execute_Success(t *testing.T) {
 }
 
 func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	tool.SetContext("default-channel", "default-chat-id")
 
 	var sentChannel, sentChatID string
@@ -95,7 +97,7 @@ func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
 }
 
 func TestMessageTool_Execute_SendFailure(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	tool.SetContext("test-channel", "test-chat-id")
 
 	sendErr := errors.New("network error")
@@ -132,7 +134,7 @@ func TestMessageTool_Execute_SendFailure(t *testing.T) {
 }
 
 func TestMessageTool_Execute_MissingContent(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	tool.SetContext("test-channel", "test-chat-id")
 
 	ctx := context.Background()
@@ -150,7 +152,7 @@ func TestMessageTool_Execute_MissingContent(t *testing.T) {
 }
 
 func TestMessageTool_Execute_NoTargetChannel(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	// No SetContext called, so defaultChannel and defaultChatID are empty
 
 	tool.SetSendCallback(func(channel, chatID, content string) error {
@@ -174,7 +176,7 @@ func TestMessageTool_Execute_NoTargetChannel(t *testing.T) {
 }
 
 func TestMessageTool_Execute_NotConfigured(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	tool.SetContext("test-channel", "test-chat-id")
 	// No SetSendCallback called
 
@@ -195,14 +197,14 @@ func TestMessageTool_Execute_NotConfigured(t *testing.T) {
 }
 
 func TestMessageTool_Name(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	if tool.Name() != "message" {
 		t.Errorf("Expected name 'message', got '%s'", tool.Name())
 	}
 }
 
 func TestMessageTool_Description(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	desc := tool.Description()
 	if desc == "" {
 		t.Error("Description should not be empty")
@@ -210,7 +212,7 @@ func TestMessageTool_Description(t *testing.T) {
 }
 
 func TestMessageTool_Parameters(t *testing.T) {
-	tool := NewMessageTool()
+	tool := NewMessageTool("", false)
 	params := tool.Parameters()
 
 	// Verify parameters structure
@@ -257,3 +259,91 @@ func TestMessageTool_Parameters(t *testing.T) {
 		t.Error("Expected chat_id type to be 'string'")
 	}
 }
+
+func TestMessageTool_Execute_WithMedia(t *testing.T) {
+	tool := NewMessageTool("", false)
+	tool.SetContext("wecom_app", "user1")
+
+	file := filepath.Join(t.TempDir(), "chart.png")
+	if err := os.WriteFile(file, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var sentMedia []string
+	tool.SetMediaSendCallback(func(channel, chatID, content string, media []string) error {
+		sentMedia = media
+		return nil
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"content": "chart attached",
+		"media":   []any{file},
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if len(sentMedia) != 1 || sentMedia[0] != file {
+		t.Errorf("sent media = %v, want [%s]", sentMedia, file)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"content": "missing",
+		"media":   []any{filepath.Join(t.TempDir(), "nope.png")},
+	})
+	if !result.IsError {
+		t.Error("expected error for missing media file")
+	}
+}
+
+func TestMessageTool_Execute_MediaNotConfigured(t *testing.T) {
+	tool := NewMessageTool("", false)
+	tool.SetContext("telegram", "1")
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+
+	file := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(file, []byte("x"), 0o600)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"content": "hi",
+		"media":   []any{file},
+	})
+	if !result.IsError {
+		t.Error("expected error when media sending is not configured")
+	}
+}
+
+func TestMessageTool_Execute_MediaOutsideWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewMessageTool(workspace, true)
+	tool.SetContext("telegram", "1")
+
+	var sent bool
+	tool.SetMediaSendCallback(func(channel, chatID, content string, media []string) error {
+		sent = true
+		return nil
+	})
+
+	outside := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(outside, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result := tool.Execute(context.Background(), map[string]any{
+		"content": "here",
+		"media":   []any{outside},
+	})
+	if !result.IsError || sent {
+		t.Fatalf("file outside the workspace was sent: %+v", result)
+	}
+
+	inside := filepath.Join(workspace, "chart.png")
+	if err := os.WriteFile(inside, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result = tool.Execute(context.Background(), map[string]any{
+		"content": "here",
+		"media":   []any{"chart.png"},
+	})
+	if result.IsError || !sent {
+		t.Fatalf("workspace file not sent: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,256 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/notes"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const notesSearchLimit = 10
+
+// NoteSavedCallback is called after a note is created or updated.
+type NoteSavedCallback func(ctx context.Context, note *notes.Note)
+
+// NotesTool lets the agent keep notes: markdown files with a title and
+// tags that it can add to, look up and search later.
+type NotesTool struct {
+	store   *notes.Store
+	onSaved NoteSavedCallback
+}
+
+func NewNotesTool(store *notes.Store) *NotesTool {
+	return &NotesTool{store: store}
+}
+
+func (t *NotesTool) Name() string {
+	return "notes"
+}
+
+func (t *NotesTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *NotesTool) Description() string {
+	return "Your notebook: keep notes that last beyond this conversation, such as research, plans, lists, " +
+		"instructions and anything the user asks you to note down. Actions: 'create' (title, content, tags), " +
+		"'update' (id; content replaces the text, append adds to it, title and tags replace theirs), " +
+		"'read' (id) and 'search' (query and/or tags). Search before creating, and update an existing note " +
+		"on the same topic instead of starting another."
+}
+
+func (t *NotesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"create", "update", "read", "search"},
+				"description": "What to do",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Note ID, as returned by create and search (for update and read)",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Note title (for create, optional for update)",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "Markdown text of the note (for create; replaces the text on update)",
+			},
+			"append": map[string]any{
+				"type":        "string",
+				"description": "Markdown to add at the end of the note (for update)",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Tags, e.g. [\"travel\", \"family\"] (for create, update and search)",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Words to look for (for search)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// SetNoteSavedCallback sets what happens after a note is saved, such as
+// indexing it for recall.
+func (t *NotesTool) SetNoteSavedCallback(callback NoteSavedCallback) {
+	t.onSaved = callback
+}
+
+func (t *NotesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "create":
+		return t.create(ctx, args)
+	case "update":
+		return t.update(ctx, args)
+	case "read":
+		return t.read(args)
+	case "search":
+		return t.search(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %q", action))
+	}
+}
+
+func (t *NotesTool) create(ctx context.Context, args map[string]any) *ToolResult {
+	title, _ := args["title"].(string)
+	content, _ := args["content"].(string)
+	note, err := t.store.Create(title, content, stringArgs(args["tags"]))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("creating note: %v", err)).WithError(err)
+	}
+	t.saved(ctx, note)
+	return NewToolResult(fmt.Sprintf("Note created: %s (id: %s)", note.Title, note.ID))
+}
+
+func (t *NotesTool) update(ctx context.Context, args map[string]any) *ToolResult {
+	note, result := t.get(args)
+	if result != nil {
+		return result
+	}
+
+	changed := false
+	if title, _ := args["title"].(string); strings.TrimSpace(title) != "" {
+		note.Title = strings.TrimSpace(title)
+		changed = true
+	}
+	if content, ok := args["content"].(string); ok {
+		note.Body = content
+		changed = true
+	}
+	if extra, _ := args["append"].(string); strings.TrimSpace(extra) != "" {
+		note.Body = strings.TrimRight(note.Body, "\n") + "\n\n" + strings.TrimSpace(extra)
+		changed = true
+	}
+	if _, ok := args["tags"]; ok {
+		note.Tags = stringArgs(args["tags"])
+		changed = true
+	}
+	if !changed {
+		return ErrorResult("nothing to update; give title, content, append or tags")
+	}
+
+	if err := t.store.Update(note); err != nil {
+		return ErrorResult(fmt.Sprintf("updating note: %v", err)).WithError(err)
+	}
+	t.saved(ctx, note)
+	return NewToolResult(fmt.Sprintf("Note updated: %s (id: %s)", note.Title, note.ID))
+}
+
+func (t *NotesTool) read(args map[string]any) *ToolResult {
+	note, result := t.get(args)
+	if result != nil {
+		return result
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", note.Title)
+	fmt.Fprintf(&sb, "id: %s", note.ID)
+	if len(note.Tags) > 0 {
+		fmt.Fprintf(&sb, " | tags: %s", strings.Join(note.Tags, ", "))
+	}
+	if !note.Updated.IsZero() {
+		fmt.Fprintf(&sb, " | updated: %s", note.Updated.Format("2006-01-02 15:04"))
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(note.Body)
+	return NewToolResult(sb.String())
+}
+
+func (t *NotesTool) search(args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	found, err := t.store.Search(query, stringArgs(args["tags"]), notesSearchLimit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("searching notes: %v", err)).WithError(err)
+	}
+	if len(found) == 0 {
+		return NewToolResult("No matching notes")
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	var sb strings.Builder
+	for _, note := range found {
+		fmt.Fprintf(&sb, "- %s (id: %s)", note.Title, note.ID)
+		if len(note.Tags) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(note.Tags, ", "))
+		}
+		if snippet := noteSnippet(note.Body, words); snippet != "" {
+			fmt.Fprintf(&sb, "\n  %s", snippet)
+		}
+		sb.WriteString("\n")
+	}
+	return NewToolResult(sb.String())
+}
+
+// get reads the note named by the id argument, or returns the error result.
+func (t *NotesTool) get(args map[string]any) (*notes.Note, *ToolResult) {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return nil, ErrorResult("id is required")
+	}
+	note, err := t.store.Get(id)
+	if errors.Is(err, notes.ErrNotFound) {
+		return nil, ErrorResult(fmt.Sprintf("no note with id %q; search to find it", id))
+	}
+	if err != nil {
+		return nil, ErrorResult(fmt.Sprintf("reading note: %v", err)).WithError(err)
+	}
+	return note, nil
+}
+
+func (t *NotesTool) saved(ctx context.Context, note *notes.Note) {
+	if t.onSaved != nil {
+		t.onSaved(ctx, note)
+	}
+}
+
+// noteSnippet returns the first line of body with one of words, or its
+// first line when words is empty or nothing matches.
+func noteSnippet(body string, words []string) string {
+	var first string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if first == "" {
+			first = line
+		}
+		lower := strings.ToLower(line)
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				return utils.Truncate(line, 160)
+			}
+		}
+	}
+	return utils.Truncate(first, 160)
+}
+
+// stringArgs reads a JSON array of strings, or a comma-separated string.
+func stringArgs(v any) []string {
+	switch v := v.(type) {
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return v
+	case string:
+		return strings.Split(v, ",")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/notes"
+)
+
+func TestNotesTool(t *testing.T) {
+	tool := NewNotesTool(notes.NewStore(t.TempDir()))
+	var saved []string
+	tool.SetNoteSavedCallback(func(ctx context.Context, note *notes.Note) {
+		saved = append(saved, note.ID)
+	})
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":  "create",
+		"title":   "Gift ideas",
+		"content": "- Mum: a cookbook",
+		"tags":    []any{"family", "shopping"},
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "id: gift-ideas") {
+		t.Fatalf("create = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "update", "id": "gift-ideas", "append": "- Dad: gloves"})
+	if result.IsError {
+		t.Fatalf("update: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "read", "id": "gift-ideas"})
+	if !strings.Contains(result.ForLLM, "# Gift ideas") || !strings.Contains(result.ForLLM, "tags: family, shopping") ||
+		!strings.Contains(result.ForLLM, "- Mum: a cookbook\n\n- Dad: gloves") {
+		t.Errorf("read = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "search", "query": "gloves", "tags": "family"})
+	if !strings.Contains(result.ForLLM, "Gift ideas (id: gift-ideas)") || !strings.Contains(result.ForLLM, "Dad: gloves") {
+		t.Errorf("search = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "search", "query": "bicycle"})
+	if result.ForLLM != "No matching notes" {
+		t.Errorf("search without hits = %q", result.ForLLM)
+	}
+
+	if strings.Join(saved, ",") != "gift-ideas,gift-ideas" {
+		t.Errorf("saved callbacks = %v", saved)
+	}
+
+	for _, args := range []map[string]any{
+		{"action": "create", "content": "no title"},
+		{"action": "update", "id": "gift-ideas"},
+		{"action": "update", "id": "missing", "content": "x"},
+		{"action": "read", "id": "../../etc/passwd"},
+		{"action": "delete", "id": "gift-ideas"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v succeeded: %s", args, result.ForLLM)
+		}
+	}
+}
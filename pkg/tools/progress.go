@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+)
+
+// ProgressFunc receives progress from a running tool, one line at a time:
+// a line of command output, a page fetched. It may be called from several
+// goroutines at once.
+type ProgressFunc func(line string)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls report progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress passes a line of progress to whoever ctx reports to. It does
+// nothing when no one listens.
+func ReportProgress(ctx context.Context, line string) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(line)
+	}
+}
+
+// progressOutput returns w, also reporting each complete line written to it
+// as progress when ctx has a listener.
+func progressOutput(ctx context.Context, w io.Writer) io.Writer {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || fn == nil {
+		return w
+	}
+	return io.MultiWriter(w, &lineReporter{report: fn})
+}
+
+// lineReporter splits what is written to it into lines for a ProgressFunc.
+// Blank lines are skipped and carriage returns end a line, so progress bars
+// that redraw themselves come through as they update.
+type lineReporter struct {
+	report  ProgressFunc
+	partial []byte
+}
+
+func (r *lineReporter) Write(p []byte) (int, error) {
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(data[:i])); line != "" {
+			r.report(line)
+		}
+		data = data[i+1:]
+	}
+	// A line without an end is kept, within reason, for the next write
+	if len(data) > 4096 {
+		data = data[len(data)-4096:]
+	}
+	r.partial = append(r.partial[:0], data...)
+	return len(p), nil
+}
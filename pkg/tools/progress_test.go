@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgressOutput(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	ctx := WithProgress(context.Background(), func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, line)
+	})
+
+	var buf strings.Builder
+	w := progressOutput(ctx, &buf)
+	for _, chunk := range []string{"downloading\n\n10%", "\r50%\r100%\n", "do", "ne\n", "no end"} {
+		w.Write([]byte(chunk))
+	}
+	if got := strings.Join(lines, "|"); got != "downloading|10%|50%|100%|done" {
+		t.Errorf("reported %q", got)
+	}
+	if !strings.HasSuffix(buf.String(), "no end") {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	if w := progressOutput(context.Background(), &buf); w != &buf {
+		t.Error("progressOutput without a listener wraps the writer")
+	}
+}
@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// pythonBootstrap runs the program read from stdin. It first checks its
+// imports against the allowlist and, with an allowlist, refuses the
+// builtins and dunder attributes that reach modules without an import
+// (print.__self__.__import__, ().__class__.__base__.__subclasses__(),
+// getattr), then lowers its own resource limits.
+// Arguments: allowed modules (comma-separated, "*" for any), memory, CPU
+// seconds and file size limits (0 for none).
+const pythonBootstrap = `import ast, sys
+allowed = None if sys.argv[1] == "*" else set(filter(None, sys.argv[1].split(",")))
+memory, cpu, fsize = (int(a) for a in sys.argv[2:5])
+source = sys.stdin.read()
+try:
+    tree = ast.parse(source, "<code>")
+except SyntaxError as e:
+    sys.exit("SyntaxError: %s" % e)
+blocked = ("exec", "eval", "compile", "getattr", "setattr", "delattr", "vars", "globals", "locals", "open",
+    "breakpoint", "input", "help")
+if allowed is not None:
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Name) and (node.id in blocked or node.id.startswith("__") and node.id != "__name__"):
+            sys.exit("NotAllowed: %s cannot be used" % node.id)
+        if isinstance(node, ast.Attribute) and node.attr.startswith("__"):
+            sys.exit("NotAllowed: attribute %s cannot be used" % node.attr)
+        names = []
+        if isinstance(node, ast.Import):
+            names = [a.name for a in node.names]
+        elif isinstance(node, ast.ImportFrom):
+            names = ["." if node.level else node.module]
+        for name in names:
+            if name.split(".")[0] not in allowed:
+                sys.exit("NotAllowed: module %s is not allowed; allowed: %s" % (name, ", ".join(sorted(allowed))))
+try:
+    import resource
+    for limit, value in ((resource.RLIMIT_AS, memory), (resource.RLIMIT_CPU, cpu), (resource.RLIMIT_FSIZE, fsize)):
+        if value:
+            resource.setrlimit(limit, (value, value))
+except (ImportError, ValueError, OSError):
+    pass
+sys.argv = ["<code>"]
+code = compile(tree, "<code>", "exec")
+del ast, allowed, blocked, tree, source
+exec(code, {"__name__": "__main__"})
+`
+
+// PythonTool runs Python on the host with a venv in the workspace, for
+// hosts without Docker. The venv is created on first use.
+type PythonTool struct {
+	cfg       config.PythonToolConfig
+	workspace string
+
+	mu    sync.Mutex
+	ready bool
+}
+
+func NewPythonTool(workspace string, cfg config.PythonToolConfig) *PythonTool {
+	if cfg.Interpreter == "" {
+		cfg.Interpreter = "python3"
+	}
+	if cfg.Venv == "" {
+		cfg.Venv = ".venv"
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 30
+	}
+	if cfg.MaxOutputChars <= 0 {
+		cfg.MaxOutputChars = 10000
+	}
+	return &PythonTool{cfg: cfg, workspace: workspace}
+}
+
+func (t *PythonTool) Name() string {
+	return "python"
+}
+
+func (t *PythonTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
+func (t *PythonTool) Description() string {
+	modules := "any installed module"
+	if !t.allowsAll() {
+		modules = "only these modules: " + strings.Join(t.cfg.AllowedModules, ", ") +
+			" (open, getattr, eval, exec and dunder names aren't available)"
+	}
+	return fmt.Sprintf("Run a Python program and return its output. Use it for calculations and data "+
+		"processing. The program runs in the workspace directory, may import %s, and is stopped after %d seconds.",
+		modules, t.cfg.TimeoutSeconds)
+}
+
+func (t *PythonTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code": map[string]any{
+				"type":        "string",
+				"description": "The complete program; print results to stdout",
+			},
+		},
+		"required": []string{"code"},
+	}
+}
+
+func (t *PythonTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	code, ok := args["code"].(string)
+	if !ok || strings.TrimSpace(code) == "" {
+		return ErrorResult("code is required")
+	}
+
+	python, err := t.ensureVenv(ctx)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	allowed := "*"
+	if !t.allowsAll() {
+		allowed = strings.Join(t.cfg.AllowedModules, ",")
+	}
+	limit := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, python, "-s", "-c", pythonBootstrap, allowed,
+		limit(int64(t.cfg.MemoryMB)<<20), limit(int64(t.cfg.TimeoutSeconds)), limit(100<<20))
+	cmd.Dir = t.workspace
+	cmd.Env = t.env()
+	cmd.Stdin = strings.NewReader(code)
+	stdout := &cappedBuffer{limit: t.cfg.MaxOutputChars}
+	stderr := &cappedBuffer{limit: t.cfg.MaxOutputChars}
+	cmd.Stdout = progressOutput(ctx, stdout)
+	cmd.Stderr = progressOutput(ctx, stderr)
+	prepareCommandForTermination(cmd)
+	cmd.Cancel = func() error { return terminateProcessTree(cmd) }
+	cmd.WaitDelay = 2 * time.Second
+	runErr := cmd.Run()
+
+	var sb strings.Builder
+	sb.WriteString(stdout.String())
+	if stderr.Len() > 0 {
+		sb.WriteString("\nSTDERR:\n" + stderr.String())
+	}
+	if dropped := stdout.dropped + stderr.dropped; dropped > 0 {
+		fmt.Fprintf(&sb, "\n... (truncated, %d more chars)", dropped)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+		fmt.Fprintf(&sb, "\nStopped after %d seconds", t.cfg.TimeoutSeconds)
+	case ctx.Err() != nil:
+		sb.WriteString("\nCancelled")
+	case errors.As(runErr, &exitErr):
+		fmt.Fprintf(&sb, "\nExit code: %d", exitErr.ExitCode())
+	case runErr != nil:
+		return ErrorResult(fmt.Sprintf("failed to run python: %v", runErr))
+	}
+
+	output := strings.TrimSpace(sb.String())
+	if output == "" {
+		output = "(no output)"
+	}
+	return &ToolResult{ForLLM: output, IsError: runErr != nil}
+}
+
+func (t *PythonTool) allowsAll() bool {
+	return len(t.cfg.AllowedModules) == 0 || slices.Contains(t.cfg.AllowedModules, "*")
+}
+
+// venvPython returns the venv's interpreter.
+func (t *PythonTool) venvPython() string {
+	venv := t.cfg.Venv
+	if !filepath.IsAbs(venv) {
+		venv = filepath.Join(t.workspace, venv)
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(venv, "Scripts", "python.exe")
+	}
+	return filepath.Join(venv, "bin", "python")
+}
+
+// ensureVenv creates the venv unless it exists, and returns its
+// interpreter. Hosts whose Python lacks ensurepip get a venv without pip.
+func (t *PythonTool) ensureVenv(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	python := t.venvPython()
+	if t.ready {
+		return python, nil
+	}
+	if _, err := os.Stat(python); err == nil {
+		t.ready = true
+		return python, nil
+	}
+
+	venv := filepath.Dir(filepath.Dir(python))
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, t.cfg.Interpreter, "-m", "venv", venv).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(venv)
+		out, err = exec.CommandContext(ctx, t.cfg.Interpreter, "-m", "venv", "--without-pip", venv).CombinedOutput()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create python venv at %s: %v: %s", venv, err, strings.TrimSpace(string(out)))
+	}
+	t.ready = true
+	return python, nil
+}
+
+// env keeps the gateway's secrets out of the program's environment.
+func (t *PythonTool) env() []string {
+	env := []string{
+		"HOME=" + t.workspace,
+		"PYTHONDONTWRITEBYTECODE=1",
+		"PYTHONIOENCODING=utf-8",
+		"VIRTUAL_ENV=" + filepath.Dir(filepath.Dir(t.venvPython())),
+	}
+	for _, key := range []string{"PATH", "LANG", "TZ", "SYSTEMROOT", "TEMP", "TMP", "TMPDIR"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
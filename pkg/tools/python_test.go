@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// newTestPythonTool returns a tool whose venv is already in place; creating
+// one with pip takes seconds.
+func newTestPythonTool(t *testing.T, cfg config.PythonToolConfig) *PythonTool {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	workspace := t.TempDir()
+	if out, err := exec.Command(python, "-m", "venv", "--without-pip", filepath.Join(workspace, ".venv")).
+		CombinedOutput(); err != nil {
+		t.Skipf("cannot create a venv: %v: %s", err, out)
+	}
+	cfg.Interpreter = python
+	return NewPythonTool(workspace, cfg)
+}
+
+func TestPythonTool_Run(t *testing.T) {
+	t.Setenv("PICOCLAW_TEST_SECRET", "hunter2")
+	tool := newTestPythonTool(t, config.PythonToolConfig{
+		AllowedModules: config.FlexibleStringSlice{"math", "os", "sys"},
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"code": "import math, os, sys\n" +
+			"print(math.factorial(10))\n" +
+			"print(os.environ.get('PICOCLAW_TEST_SECRET'))\n" +
+			"print(sys.prefix.endswith('.venv'))\n" +
+			"os.close(os.open('out.txt', os.O_CREAT | os.O_WRONLY))\n",
+	})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if result.ForLLM != "3628800\nNone\nTrue" {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	if _, err := os.Stat(filepath.Join(tool.workspace, "out.txt")); err != nil {
+		t.Errorf("program did not run in the workspace: %v", err)
+	}
+}
+
+func TestPythonTool_ModuleAllowlist(t *testing.T) {
+	tool := newTestPythonTool(t, config.PythonToolConfig{AllowedModules: config.FlexibleStringSlice{"json"}})
+
+	for code, want := range map[string]string{
+		"import subprocess":                               "module subprocess is not allowed",
+		"from os import path":                             "module os is not allowed",
+		"import json.decoder, socket":                     "module socket is not allowed",
+		"from . import x":                                 "module . is not allowed",
+		"__import__('os').system('id')":                   "__import__ cannot be used",
+		"eval(\"__import__('os')\")":                      "eval cannot be used",
+		"print(print.__self__.__import__('os').getcwd())": "attribute __import__ cannot be used",
+		"print(().__class__.__base__.__subclasses__())":   "attribute __subclasses__ cannot be used",
+		"print(getattr(print, '__self__'))":               "getattr cannot be used",
+		"print(open('/etc/passwd').read())":               "open cannot be used",
+		"print(vars(print)['__self__'])":                  "vars cannot be used",
+		"print(__builtins__)":                             "__builtins__ cannot be used",
+		"import json\nprint(json.dumps(":                  "SyntaxError",
+	} {
+		result := tool.Execute(context.Background(), map[string]any{"code": code})
+		if !result.IsError || !strings.Contains(result.ForLLM, want) {
+			t.Errorf("Execute(%q) = %q, want error with %q", code, result.ForLLM, want)
+		}
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"code": "import json\nprint(json.dumps([1]))"})
+	if result.IsError || result.ForLLM != "[1]" {
+		t.Errorf("allowed module: %q", result.ForLLM)
+	}
+
+	tool.cfg.AllowedModules = config.FlexibleStringSlice{"*"}
+	result = tool.Execute(context.Background(), map[string]any{"code": "import subprocess\nprint('ok')"})
+	if result.IsError || result.ForLLM != "ok" {
+		t.Errorf("wildcard allowlist: %q", result.ForLLM)
+	}
+}
+
+func TestPythonTool_Limits(t *testing.T) {
+	tool := newTestPythonTool(t, config.PythonToolConfig{TimeoutSeconds: 1, MaxOutputChars: 50})
+
+	result := tool.Execute(context.Background(), map[string]any{"code": "while True: pass"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "Stopped after 1 seconds") {
+		t.Errorf("endless loop: %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"code": "print('y' * 500)"})
+	if !strings.Contains(result.ForLLM, "truncated, 451 more chars") {
+		t.Errorf("long output: %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"code": "raise SystemExit(4)"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "Exit code: 4") {
+		t.Errorf("exit code: %q", result.ForLLM)
+	}
+}
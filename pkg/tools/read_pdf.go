@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/pdftext"
+)
+
+const (
+	maxPDFChars       = 40000
+	maxCachedPDFFiles = 4
+)
+
+// cachedPDF is the extracted text of a PDF, kept until the file changes.
+type cachedPDF struct {
+	modTime time.Time
+	pages   []string
+}
+
+// ReadPDFTool extracts the text of PDFs so models that can't take documents
+// as input can still read them. Text comes back page by page, and pages can
+// be asked for by number.
+type ReadPDFTool struct {
+	workspace string
+	restrict  bool
+	allowed   []config.AllowedPath
+
+	mu    sync.Mutex
+	cache map[string]cachedPDF
+}
+
+func NewReadPDFTool(workspace string, restrict bool, allowed ...config.AllowedPath) *ReadPDFTool {
+	return &ReadPDFTool{
+		workspace: workspace,
+		restrict:  restrict,
+		allowed:   allowed,
+		cache:     make(map[string]cachedPDF),
+	}
+}
+
+func (t *ReadPDFTool) Name() string {
+	return "read_pdf"
+}
+
+func (t *ReadPDFTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *ReadPDFTool) Description() string {
+	return "Extract the text of a PDF file, page by page. Use pages to read part of a long document"
+}
+
+func (t *ReadPDFTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path to the PDF",
+			},
+			"pages": map[string]any{
+				"type":        "string",
+				"description": "Optional pages to read, e.g. \"3\", \"1-5\", \"2,7-9\" or \"10-\"; default all",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *ReadPDFTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return ErrorResult("path is required")
+	}
+	resolved, err := validatePath(path, t.workspace, t.restrict, t.allowed, false)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	pages, err := t.extract(ctx, resolved)
+	if err != nil {
+		if errors.Is(err, pdftext.ErrEncrypted) {
+			return ErrorResult(fmt.Sprintf("can't read %s: %v", path, err))
+		}
+		return ErrorResult(fmt.Sprintf("failed to read PDF: %v", err)).WithError(err)
+	}
+	if len(pages) == 0 {
+		return ErrorResult(fmt.Sprintf("%s has no pages", path))
+	}
+
+	spec, _ := args["pages"].(string)
+	selected, err := parsePageRanges(spec, len(pages))
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d pages\n", path, len(pages))
+	empty := 0
+	for i, n := range selected {
+		text := strings.TrimSpace(pages[n-1])
+		if text == "" {
+			empty++
+			text = "(no text on this page)"
+		}
+		block := fmt.Sprintf("\n--- Page %d ---\n%s\n", n, text)
+		if sb.Len()+len(block) > maxPDFChars && i > 0 {
+			fmt.Fprintf(&sb, "\n(stopped before page %d to keep this short; ask for pages \"%d-\" to read on)", n, n)
+			break
+		}
+		sb.WriteString(block)
+	}
+	if empty == len(selected) {
+		sb.WriteString("\nNo text was found. The PDF may be scanned images, which need OCR.")
+	}
+	return NewToolResult(strings.TrimRight(sb.String(), "\n"))
+}
+
+// extract returns the pages of a PDF, reusing the text from an earlier
+// call when the file hasn't changed since.
+func (t *ReadPDFTool) extract(ctx context.Context, path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	cached, ok := t.cache[path]
+	t.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.pages, nil
+	}
+
+	pages, err := pdftext.Extract(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.cache) >= maxCachedPDFFiles {
+		for k := range t.cache {
+			delete(t.cache, k)
+			break
+		}
+	}
+	t.cache[path] = cachedPDF{modTime: info.ModTime(), pages: pages}
+	return pages, nil
+}
+
+// parsePageRanges turns "2,5-7,10-" into page numbers in order, or every
+// page when spec is empty.
+func parsePageRanges(spec string, total int) ([]int, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = "1-"
+	}
+	var pages []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pages %q; use e.g. \"3\", \"1-5\" or \"2,7-9\"", spec)
+		}
+		end := start
+		if isRange {
+			end = total
+			if last = strings.TrimSpace(last); last != "" {
+				if end, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("invalid pages %q; use e.g. \"3\", \"1-5\" or \"2,7-9\"", spec)
+				}
+			}
+		}
+		if start < 1 || start > total || end < start {
+			return nil, fmt.Errorf("pages %q are outside the document (%d pages)", part, total)
+		}
+		for n := start; n <= min(end, total); n++ {
+			if !seen[n] {
+				seen[n] = true
+				pages = append(pages, n)
+			}
+		}
+	}
+	return pages, nil
+}
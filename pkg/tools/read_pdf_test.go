@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeOnePagePerLinePDF writes a PDF with one line of text on each page;
+// an empty line makes a page without text.
+func writeOnePagePerLinePDF(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"}
+	var kids []string
+	for _, line := range lines {
+		content := "BT /F1 12 Tf 72 720 Td ET"
+		if line != "" {
+			content = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", line)
+		}
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objects)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func TestReadPDFTool(t *testing.T) {
+	workspace := t.TempDir()
+	writeOnePagePerLinePDF(t, filepath.Join(workspace, "lease.pdf"),
+		"Residential lease", "Rent is due on the first", "", "Signatures")
+	tool := NewReadPDFTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"path": "lease.pdf"})
+	if result.IsError {
+		t.Fatalf("Execute: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"lease.pdf: 4 pages",
+		"--- Page 1 ---\nResidential lease",
+		"--- Page 3 ---\n(no text on this page)",
+		"--- Page 4 ---\nSignatures",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("missing %q in:\n%s", want, result.ForLLM)
+		}
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "lease.pdf", "pages": "2"})
+	if strings.Contains(result.ForLLM, "Page 1") || !strings.Contains(result.ForLLM, "Rent is due") {
+		t.Errorf("page 2 = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"path": "lease.pdf", "pages": "3"})
+	if !strings.Contains(result.ForLLM, "may be scanned images") {
+		t.Errorf("textless page = %q", result.ForLLM)
+	}
+
+	os.WriteFile(filepath.Join(workspace, "fake.pdf"), []byte("not a pdf"), 0o644)
+	failures := []struct{ path, pages, want string }{
+		{"lease.pdf", "7", "outside the document"},
+		{"lease.pdf", "two", "invalid pages"},
+		{"fake.pdf", "", "failed to read PDF"},
+		{"../elsewhere.pdf", "", "outside"},
+	}
+	for _, tt := range failures {
+		result := tool.Execute(ctx, map[string]any{"path": tt.path, "pages": tt.pages})
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%s pages %q = %q, want an error with %q", tt.path, tt.pages, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestParsePageRanges(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{"3", []int{3}},
+		{"4-", []int{4, 5}},
+		{"2, 4-9, 2", []int{2, 4, 5}},
+	}
+	for _, tt := range tests {
+		got, err := parsePageRanges(tt.spec, 5)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePageRanges(%q) = %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"0", "6", "3-1", "1-x", "-2"} {
+		if _, err := parsePageRanges(spec, 5); err == nil {
+			t.Errorf("parsePageRanges(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+// MemorySearchFunc returns up to limit stored memories closest to query,
+// best first, only of the given kind when kind isn't empty.
+type MemorySearchFunc func(ctx context.Context, query, kind string, limit int) ([]vectorstore.Match, error)
+
+// RecallMemoryTool lets the agent search long-term memory on purpose, for
+// things the memories recalled with the user's message didn't cover.
+type RecallMemoryTool struct {
+	search MemorySearchFunc
+	limit  int
+}
+
+// NewRecallMemoryTool creates a recall_memory tool over an agent's memory;
+// limit is how many memories a search returns unless asked for more.
+func NewRecallMemoryTool(search MemorySearchFunc, limit int) *RecallMemoryTool {
+	if limit <= 0 {
+		limit = 5
+	}
+	return &RecallMemoryTool{search: search, limit: limit}
+}
+
+func (t *RecallMemoryTool) Name() string {
+	return "recall_memory"
+}
+
+func (t *RecallMemoryTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *RecallMemoryTool) Description() string {
+	return "Search long-term memory of earlier conversations by meaning: facts about the user, summaries of " +
+		"past sessions and saved notes. Use it when the user refers to something from before " +
+		"(\"what did I say about my travel plans?\") that the memories already shown don't cover."
+}
+
+func (t *RecallMemoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What to remember, as a question or description",
+			},
+			"kind": map[string]any{
+				"type":        "string",
+				"enum":        []string{"fact", "summary", "note"},
+				"description": "Only memories of this kind: facts, session summaries or notes (optional)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of memories (1-20)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *RecallMemoryTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ErrorResult("query is required")
+	}
+	kind, _ := args["kind"].(string)
+	limit := t.limit
+	if l, ok := args["limit"].(float64); ok && l >= 1 && l <= 20 {
+		limit = int(l)
+	}
+
+	matches, err := t.search(ctx, query, kind, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("memory search failed: %v", err))
+	}
+	if len(matches) == 0 {
+		return SilentResult(fmt.Sprintf("Nothing in memory matches %q.", query))
+	}
+
+	var sb strings.Builder
+	for i, match := range matches {
+		fmt.Fprintf(&sb, "\n%d. (%s, %s, score %.2f) %s", i+1, match.Kind, match.Created.Format("2006-01-02"),
+			match.Score, match.Text)
+	}
+	return SilentResult(fmt.Sprintf("Found %d memories for %q:%s", len(matches), query, sb.String()))
+}
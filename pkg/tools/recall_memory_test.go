@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+func TestRecallMemoryTool(t *testing.T) {
+	var gotKind string
+	var gotLimit int
+	search := func(ctx context.Context, query, kind string, limit int) ([]vectorstore.Match, error) {
+		gotKind, gotLimit = kind, limit
+		if query == "broken" {
+			return nil, errors.New("embedding model unavailable")
+		}
+		if !strings.Contains(query, "travel") {
+			return nil, nil
+		}
+		return []vectorstore.Match{{
+			Record: vectorstore.Record{
+				Kind:    "fact",
+				Text:    "The user is going to Lisbon in June",
+				Created: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Score: 0.81,
+		}}, nil
+	}
+	tool := NewRecallMemoryTool(search, 0)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"query": "travel plans", "kind": "fact", "limit": 3.0})
+	if result.IsError || !strings.Contains(result.ForLLM, "(fact, 2026-03-01, score 0.81) The user is going to Lisbon") {
+		t.Errorf("recall_memory = %q", result.ForLLM)
+	}
+	if gotKind != "fact" || gotLimit != 3 {
+		t.Errorf("searched kind %q limit %d, want fact and 3", gotKind, gotLimit)
+	}
+
+	tool.Execute(ctx, map[string]any{"query": "travel"})
+	if gotLimit != 5 {
+		t.Errorf("default limit = %d, want 5", gotLimit)
+	}
+	if result := tool.Execute(ctx, map[string]any{"query": "pets"}); !strings.Contains(result.ForLLM, "Nothing") {
+		t.Errorf("recall_memory without a match = %q", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"query": "broken"}); !result.IsError {
+		t.Error("expected an error when the search fails")
+	}
+	if result := tool.Execute(ctx, map[string]any{}); !result.IsError {
+		t.Error("expected an error without a query")
+	}
+}
@@ -6,13 +6,18 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools  map[string]Tool
+	access *AccessPolicy
+	retry  *RetryPolicy
+	cache  *ResultCache
+	audit  *audit.Log
+	mu     sync.RWMutex
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -27,6 +32,42 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[tool.Name()] = tool
 }
 
+// SetAccessPolicy limits the tools each conversation may see and run.
+func (r *ToolRegistry) SetAccessPolicy(policy *AccessPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.access = policy
+}
+
+// SetRetryPolicy has failed tool calls tried again as policy says.
+func (r *ToolRegistry) SetRetryPolicy(policy *RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retry = policy
+}
+
+// SetResultCache answers repeated calls of the tools cache lists from it.
+func (r *ToolRegistry) SetResultCache(cache *ResultCache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = cache
+}
+
+// SetAuditLog records every tool call made through the registry in log.
+func (r *ToolRegistry) SetAuditLog(log *audit.Log) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = log
+}
+
+// Allowed reports whether the conversation may use the tool.
+func (r *ToolRegistry) Allowed(name, channel, chatID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return ok && r.access.Allows(tool, channel, chatID)
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -60,12 +101,52 @@ func (r *ToolRegistry) ExecuteWithContext(
 			map[string]any{
 				"tool": name,
 			})
-		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
+		result := ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
+		r.record(ctx, name, args, channel, chatID, audit.StatusNotFound, result, 0)
+		return result
 	}
 
-	// If tool implements ContextualTool, set context
-	if contextualTool, ok := tool.(ContextualTool); ok && channel != "" && chatID != "" {
-		contextualTool.SetContext(channel, chatID)
+	if !r.Allowed(name, channel, chatID) {
+		logger.WarnCF("tool", "Tool not allowed in this conversation",
+			map[string]any{
+				"tool":    name,
+				"channel": channel,
+				"chat_id": chatID,
+			})
+		result := ErrorResult(fmt.Sprintf("tool %q is not available in this conversation", name)).
+			WithError(fmt.Errorf("tool not allowed"))
+		r.record(ctx, name, args, channel, chatID, audit.StatusDenied, result, 0)
+		return result
+	}
+
+	if err := ValidateArgs(tool, args); err != nil {
+		logger.WarnCF("tool", "Invalid tool arguments",
+			map[string]any{
+				"tool":  name,
+				"error": err.Error(),
+			})
+		result := ErrorResult(err.Error()).WithError(err)
+		r.record(ctx, name, args, channel, chatID, audit.StatusInvalid, result, 0)
+		return result
+	}
+
+	r.mu.RLock()
+	cache := r.cache
+	r.mu.RUnlock()
+	if cached, ok := cache.get(name, args); ok {
+		logger.InfoCF("tool", "Tool result served from cache",
+			map[string]any{
+				"tool":          name,
+				"result_length": len(cached.ForLLM),
+			})
+		r.record(ctx, name, args, channel, chatID, audit.StatusCached, cached, 0)
+		return cached
+	}
+
+	// Contextual tools read the chat from ctx, so calls for different chats
+	// can run at the same time
+	if channel != "" && chatID != "" {
+		ctx = WithChat(ctx, channel, chatID)
 	}
 
 	// If tool implements AsyncTool and callback is provided, set callback
@@ -78,11 +159,13 @@ func (r *ToolRegistry) ExecuteWithContext(
 	}
 
 	start := time.Now()
-	result := tool.Execute(ctx, args)
+	result := r.executeWithRetry(ctx, tool, args)
 	duration := time.Since(start)
 
 	// Log based on result type
+	status := audit.StatusOK
 	if result.IsError {
+		status = audit.StatusError
 		logger.ErrorCF("tool", "Tool execution failed",
 			map[string]any{
 				"tool":     name,
@@ -90,6 +173,7 @@ func (r *ToolRegistry) ExecuteWithContext(
 				"error":    result.ForLLM,
 			})
 	} else if result.Async {
+		status = audit.StatusAsync
 		logger.InfoCF("tool", "Tool started (async)",
 			map[string]any{
 				"tool":     name,
@@ -103,10 +187,50 @@ func (r *ToolRegistry) ExecuteWithContext(
 				"result_length": len(result.ForLLM),
 			})
 	}
+	r.record(ctx, name, args, channel, chatID, status, result, duration)
+	cache.put(name, args, result)
 
 	return result
 }
 
+// record appends a tool call to the audit log, if there is one.
+func (r *ToolRegistry) record(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID, status string,
+	result *ToolResult,
+	duration time.Duration,
+) {
+	r.mu.RLock()
+	log := r.audit
+	r.mu.RUnlock()
+	if log == nil {
+		return
+	}
+
+	actor := audit.ActorFrom(ctx)
+	entry := audit.Entry{
+		Agent:      actor.Agent,
+		Session:    actor.Session,
+		Sender:     actor.Sender,
+		Channel:    channel,
+		ChatID:     chatID,
+		Tool:       name,
+		Args:       args,
+		Status:     status,
+		DurationMS: duration.Milliseconds(),
+	}
+	entry.SetResult(result.ForLLM)
+	if err := log.Record(entry); err != nil {
+		logger.WarnCF("tool", "Could not write audit log",
+			map[string]any{
+				"tool":  name,
+				"error": err.Error(),
+			})
+	}
+}
+
 func (r *ToolRegistry) GetDefinitions() []map[string]any {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -121,11 +245,24 @@ func (r *ToolRegistry) GetDefinitions() []map[string]any {
 // ToProviderDefs converts tool definitions to provider-compatible format.
 // This is the format expected by LLM provider APIs.
 func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
+	return r.toProviderDefs(func(Tool) bool { return true })
+}
+
+// ToProviderDefsFor is ToProviderDefs limited to the tools the conversation
+// may use.
+func (r *ToolRegistry) ToProviderDefsFor(channel, chatID string) []providers.ToolDefinition {
+	return r.toProviderDefs(func(tool Tool) bool { return r.access.Allows(tool, channel, chatID) })
+}
+
+func (r *ToolRegistry) toProviderDefs(keep func(Tool) bool) []providers.ToolDefinition {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	definitions := make([]providers.ToolDefinition, 0, len(r.tools))
 	for _, tool := range r.tools {
+		if !keep(tool) {
+			continue
+		}
 		schema := ToolToSchema(tool)
 
 		// Safely extract nested values with type checks
@@ -172,11 +309,24 @@ func (r *ToolRegistry) Count() int {
 // GetSummaries returns human-readable summaries of all registered tools.
 // Returns a slice of "name - description" strings.
 func (r *ToolRegistry) GetSummaries() []string {
+	return r.getSummaries(func(Tool) bool { return true })
+}
+
+// GetSummariesFor is GetSummaries limited to the tools the conversation may
+// use.
+func (r *ToolRegistry) GetSummariesFor(channel, chatID string) []string {
+	return r.getSummaries(func(tool Tool) bool { return r.access.Allows(tool, channel, chatID) })
+}
+
+func (r *ToolRegistry) getSummaries(keep func(Tool) bool) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	summaries := make([]string, 0, len(r.tools))
 	for _, tool := range r.tools {
+		if !keep(tool) {
+			continue
+		}
 		summaries = append(summaries, fmt.Sprintf("- `%s` - %s", tool.Name(), tool.Description()))
 	}
 	return summaries
@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/usertime"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// reminderKind marks the cron jobs that hold reminders.
+const reminderKind = "reminder"
+
+// RemindersTool sets, lists and cancels reminders for the current chat.
+// Reminders are one-time cron jobs, so they survive restarts and one that
+// came due while the gateway was down is sent when it starts again.
+type RemindersTool struct {
+	cronService *cron.CronService
+	now         func() time.Time
+
+	mu      sync.RWMutex
+	channel string
+	chatID  string
+}
+
+func NewRemindersTool(cronService *cron.CronService) *RemindersTool {
+	return &RemindersTool{cronService: cronService, now: time.Now}
+}
+
+func (t *RemindersTool) Name() string {
+	return "reminders"
+}
+
+func (t *RemindersTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *RemindersTool) Description() string {
+	return "Remind the user of something at a later time in this chat. Use action 'create' with the reminder " +
+		"text and when, written as the user said it: \"in 45 minutes\", \"tomorrow\", \"next tuesday 9am\", " +
+		"\"friday at 18:30\" or \"2026-03-14 18:30\". Use 'list' to see this chat's pending reminders and " +
+		"'cancel' with an id to drop one. For recurring schedules use the cron tool."
+}
+
+func (t *RemindersTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"create", "list", "cancel"},
+				"description": "What to do",
+			},
+			"text": map[string]any{
+				"type":        "string",
+				"description": "What to remind the user of (for create)",
+			},
+			"when": map[string]any{
+				"type":        "string",
+				"description": "When to remind them, e.g. \"in 2 hours\" or \"next monday at 9am\" (for create)",
+			},
+			"tz": map[string]any{
+				"type":        "string",
+				"description": "Optional: IANA time zone when is in, e.g. 'Europe/Berlin'. Default: the user's time zone",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Reminder ID (for cancel)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *RemindersTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *RemindersTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.mu.RLock()
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
+	t.mu.RUnlock()
+	if channel == "" || chatID == "" {
+		return ErrorResult("no chat for reminders; use this tool in an active conversation")
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "create":
+		return t.create(ctx, args, channel, chatID)
+	case "list":
+		return t.list(ctx, channel, chatID)
+	case "cancel":
+		return t.cancel(args, channel, chatID)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %q", action))
+	}
+}
+
+func (t *RemindersTool) create(ctx context.Context, args map[string]any, channel, chatID string) *ToolResult {
+	text, _ := args["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return ErrorResult("text is required for create")
+	}
+	when, _ := args["when"].(string)
+	if strings.TrimSpace(when) == "" {
+		return ErrorResult("when is required for create")
+	}
+
+	now := t.now().In(usertime.Location(ctx))
+	if tz, _ := args["tz"].(string); tz != "" {
+		loc, err := usertime.Load(tz)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("unknown time zone %q", tz))
+		}
+		now = now.In(loc)
+	}
+	at, err := parseWhen(when, now)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if !at.After(now) {
+		return ErrorResult(fmt.Sprintf("%s is in the past", at.Format("2006-01-02 15:04")))
+	}
+
+	atMS := at.UnixMilli()
+	job, err := t.cronService.AddJob(
+		"Reminder: "+utils.Truncate(text, 30),
+		cron.CronSchedule{Kind: "at", AtMS: &atMS},
+		text,
+		true,
+		channel,
+		chatID,
+	)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to set reminder: %v", err))
+	}
+	job.Payload.Kind = reminderKind
+	if err := t.cronService.UpdateJob(job); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to set reminder: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Reminder set for %s (id: %s)", at.Format("Mon 2006-01-02 15:04 MST"), job.ID))
+}
+
+func (t *RemindersTool) list(ctx context.Context, channel, chatID string) *ToolResult {
+	pending := t.Pending(channel, chatID, usertime.Location(ctx))
+	if len(pending) == 0 {
+		return SilentResult("No pending reminders in this chat")
+	}
+	return SilentResult("Pending reminders:\n- " + strings.Join(pending, "\n- ") + "\n")
+}
+
+// Pending describes the chat's pending reminders, soonest first, with their
+// times in loc.
+func (t *RemindersTool) Pending(channel, chatID string, loc *time.Location) []string {
+	var pending []string
+	for _, job := range t.reminders(channel, chatID) {
+		pending = append(pending, fmt.Sprintf("%s: %s (id: %s)",
+			time.UnixMilli(*job.Schedule.AtMS).In(loc).Format("Mon 2006-01-02 15:04"), job.Payload.Message, job.ID))
+	}
+	return pending
+}
+
+func (t *RemindersTool) cancel(args map[string]any, channel, chatID string) *ToolResult {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required for cancel")
+	}
+	for _, job := range t.reminders(channel, chatID) {
+		if job.ID == id {
+			t.cronService.RemoveJob(id)
+			return SilentResult(fmt.Sprintf("Reminder cancelled: %s", job.Payload.Message))
+		}
+	}
+	return ErrorResult(fmt.Sprintf("no pending reminder %s in this chat", id))
+}
+
+// reminders returns the chat's pending reminders, soonest first.
+func (t *RemindersTool) reminders(channel, chatID string) []cron.CronJob {
+	var reminders []cron.CronJob
+	for _, job := range t.cronService.ListJobs(false) {
+		if job.Payload.Kind == reminderKind && job.Payload.Channel == channel && job.Payload.To == chatID &&
+			job.Schedule.AtMS != nil {
+			reminders = append(reminders, job)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return *reminders[i].Schedule.AtMS < *reminders[j].Schedule.AtMS
+	})
+	return reminders
+}
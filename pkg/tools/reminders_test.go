@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/usertime"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Tuesday afternoon
+	now := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		when string
+		want time.Time
+	}{
+		{"in 45 minutes", now.Add(45 * time.Minute)},
+		{"in 2 hours and 30 minutes", now.Add(150 * time.Minute)},
+		{"in an hour and a half", now.Add(90 * time.Minute)},
+		{"in half an hour", now.Add(30 * time.Minute)},
+		{"in 3 days", now.AddDate(0, 0, 3)},
+		{"in 1h30m", now.Add(90 * time.Minute)},
+		{"tomorrow", at(11, 9, 0)},
+		{"tomorrow at 7:30pm", at(11, 19, 30)},
+		{"Tomorrow morning", at(11, 9, 0)},
+		{"tonight at 9", at(10, 21, 0)},
+		{"this evening", at(10, 18, 0)},
+		{"at 5 pm", at(10, 17, 0)},
+		{"9am", at(11, 9, 0)},
+		{"18:30", at(10, 18, 30)},
+		{"noon", at(11, 12, 0)},
+		{"midnight", at(11, 0, 0)},
+		{"friday", at(13, 9, 0)},
+		{"on Friday at 18:30", at(13, 18, 30)},
+		{"next tuesday 9am", at(17, 9, 0)},
+		{"tuesday 9am", at(17, 9, 0)},
+		{"tuesday 4pm", at(10, 16, 0)},
+		{"next week", at(17, 9, 0)},
+		{"2026-03-14 18:30", at(14, 18, 30)},
+		{"2026-03-14", at(14, 9, 0)},
+		{"2026-03-14T18:30:00Z", at(14, 18, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			got, err := parseWhen(tt.when, now)
+			if err != nil {
+				t.Fatalf("parseWhen: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseWhen(%q) = %s, want %s", tt.when, got, tt.want)
+			}
+		})
+	}
+
+	for _, when := range []string{"", "soon", "in a while", "next", "13pm", "tomorrow at 9 and 10", "week"} {
+		if got, err := parseWhen(when, now); err == nil {
+			t.Errorf("parseWhen(%q) = %s, want an error", when, got)
+		}
+	}
+}
+
+func TestRemindersTool(t *testing.T) {
+	workspace := t.TempDir()
+	cs := cron.NewCronService(filepath.Join(workspace, "jobs.json"), nil)
+	tool := NewRemindersTool(cs)
+	now := time.Now().Truncate(time.Minute)
+	tool.now = func() time.Time { return now }
+	tool.SetContext("telegram", "42")
+	ctx := context.Background()
+
+	for _, args := range []map[string]any{
+		{"action": "create", "text": "stretch", "when": "in 2 hours"},
+		{"action": "create", "text": "call the bank", "when": "in 20 minutes"},
+	} {
+		if result := tool.Execute(ctx, args); result.IsError || !result.Silent {
+			t.Fatalf("create %v: %+v", args, result)
+		}
+	}
+	for _, args := range []map[string]any{
+		{"action": "create", "text": "x", "when": "someday"},
+		{"action": "create", "text": "x", "when": "2000-01-01 09:00"},
+		{"action": "create", "text": "x", "when": "tomorrow", "tz": "Mars/Olympus"},
+		{"action": "create", "when": "tomorrow"},
+		{"action": "cancel", "id": "missing"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v succeeded: %s", args, result.ForLLM)
+		}
+	}
+
+	// Other chats neither see nor cancel them
+	other := NewRemindersTool(cs)
+	other.SetContext("telegram", "7")
+	if result := other.Execute(ctx, map[string]any{"action": "list"}); !strings.Contains(result.ForLLM, "No pending") {
+		t.Errorf("other chat list = %q", result.ForLLM)
+	}
+
+	list := tool.Execute(ctx, map[string]any{"action": "list"}).ForLLM
+	if i, j := strings.Index(list, "call the bank"), strings.Index(list, "stretch"); i < 0 || j < i {
+		t.Errorf("list = %q, want both reminders, soonest first", list)
+	}
+
+	jobs := cs.ListJobs(false)
+	if len(jobs) != 2 {
+		t.Fatalf("jobs = %d, want 2", len(jobs))
+	}
+	var bank cron.CronJob
+	for _, job := range jobs {
+		if job.Payload.Message == "call the bank" {
+			bank = job
+		}
+	}
+	if bank.Payload.Kind != reminderKind || !bank.DeleteAfterRun ||
+		*bank.Schedule.AtMS != now.Add(20*time.Minute).UnixMilli() {
+		t.Errorf("job = %+v", bank)
+	}
+
+	if result := other.Execute(ctx, map[string]any{"action": "cancel", "id": bank.ID}); !result.IsError {
+		t.Error("another chat cancelled the reminder")
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "cancel", "id": bank.ID}); result.IsError {
+		t.Fatalf("cancel: %s", result.ForLLM)
+	}
+	if jobs := cs.ListJobs(false); len(jobs) != 1 || jobs[0].Payload.Message != "stretch" {
+		t.Errorf("jobs after cancel = %+v", jobs)
+	}
+
+	// Due reminders are sent to the chat
+	msgBus := bus.NewMessageBus()
+	cronTool := NewCronTool(cs, &toolExecutor{NewToolRegistry()}, msgBus, workspace, true, time.Minute,
+		config.DefaultConfig())
+	job := cs.ListJobs(false)[0]
+	if got := cronTool.ExecuteJob(ctx, &job); got != "ok" {
+		t.Fatalf("ExecuteJob() = %q", got)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(waitCtx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || out.Content != "⏰ Reminder: stretch" {
+		t.Errorf("delivered %+v", out)
+	}
+}
+
+func TestSchedulingUsesUserTimeZone(t *testing.T) {
+	workspace := t.TempDir()
+	cs := cron.NewCronService(filepath.Join(workspace, "jobs.json"), nil)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	ctx := usertime.WithLocation(context.Background(), tokyo)
+	// 23:00 UTC is already 08:00 the next day in Tokyo
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	reminders := NewRemindersTool(cs)
+	reminders.now = func() time.Time { return now }
+	reminders.SetContext("telegram", "42")
+	result := reminders.Execute(ctx, map[string]any{"action": "create", "text": "standup", "when": "tomorrow at 9"})
+	if result.IsError {
+		t.Fatalf("create: %s", result.ForLLM)
+	}
+	want := time.Date(2026, 3, 12, 9, 0, 0, 0, tokyo)
+	if !strings.Contains(result.ForLLM, "Thu 2026-03-12 09:00 JST") {
+		t.Errorf("create = %q, want %s", result.ForLLM, want)
+	}
+	if list := reminders.Execute(ctx, map[string]any{"action": "list"}).ForLLM; !strings.Contains(list, "09:00") {
+		t.Errorf("list = %q, want the time in Tokyo", list)
+	}
+
+	sendLater := NewSendLaterTool(cs)
+	sendLater.now = func() time.Time { return now }
+	sendLater.SetContext("telegram", "42")
+	if result := sendLater.Execute(ctx, map[string]any{"content": "hi", "at": "9am"}); result.IsError {
+		t.Fatalf("send_later: %s", result.ForLLM)
+	}
+
+	cronTool := NewCronTool(cs, &toolExecutor{NewToolRegistry()}, bus.NewMessageBus(), workspace, true,
+		time.Minute, config.DefaultConfig())
+	cronTool.SetContext("telegram", "42")
+	if result := cronTool.Execute(ctx, map[string]any{
+		"action": "add", "message": "daily", "cron_expr": "0 9 * * *",
+	}); result.IsError {
+		t.Fatalf("cron add: %s", result.ForLLM)
+	}
+
+	atMS := map[string]int64{}
+	for _, job := range cs.ListJobs(true) {
+		switch {
+		case job.Schedule.Kind == "cron" && job.Schedule.TZ != "Asia/Tokyo":
+			t.Errorf("cron job time zone = %q, want Asia/Tokyo", job.Schedule.TZ)
+		case job.Schedule.AtMS != nil:
+			atMS[job.Payload.Message] = *job.Schedule.AtMS
+		}
+	}
+	if atMS["standup"] != want.UnixMilli() {
+		t.Errorf("reminder at %s, want %s", time.UnixMilli(atMS["standup"]).In(tokyo), want)
+	}
+	if atMS["hi"] != time.Date(2026, 3, 11, 9, 0, 0, 0, tokyo).UnixMilli() {
+		t.Errorf("message at %s, want 09:00 the same morning in Tokyo", time.UnixMilli(atMS["hi"]).In(tokyo))
+	}
+}
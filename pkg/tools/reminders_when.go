@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReminderHour is when a reminder for a day with no time given goes
+// off.
+const defaultReminderHour = 9
+
+var (
+	reDuration = regexp.MustCompile(
+		`^(\d+(?:\.\d+)?|an?|half an?)\s*` +
+			`(seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|wks?|w)$`)
+	reClock = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// namedTimes are parts of the day a reminder can be set for.
+var namedTimes = map[string]int{ // minutes after midnight
+	"morning":   9 * 60,
+	"noon":      12 * 60,
+	"midday":    12 * 60,
+	"afternoon": 15 * 60,
+	"evening":   18 * 60,
+	"tonight":   20 * 60,
+	"night":     20 * 60,
+}
+
+var durationUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseWhen reads a time the way people say it, in now's location:
+// "in 45 minutes", "in an hour and a half", "tomorrow", "tonight at 9",
+// "next tuesday 9am", "friday at 18:30", "at noon", "9:15pm", or a date
+// such as "2026-03-14 18:30" or "2026-03-14". A day with no time means 9am;
+// a time with no day means the next time the clock shows it.
+func parseWhen(value string, now time.Time) (time.Time, error) {
+	for _, layout := range sendLaterDates {
+		if parsed, err := time.ParseInLocation(layout, strings.TrimSpace(value), now.Location()); err == nil {
+			return parsed, nil
+		}
+	}
+	if day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(value), now.Location()); err == nil {
+		return time.Date(day.Year(), day.Month(), day.Day(), defaultReminderHour, 0, 0, 0, day.Location()), nil
+	}
+
+	s := strings.ToLower(strings.TrimSpace(value))
+	s = strings.TrimRight(s, ".!")
+	s = strings.Join(strings.Fields(strings.ReplaceAll(s, ",", " ")), " ")
+	invalid := fmt.Errorf("cannot read time %q; use e.g. \"in 45 minutes\", \"tomorrow 9am\", "+
+		"\"next tuesday at 18:30\" or \"2026-03-14 18:30\"", value)
+
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		d, err := parseSpokenDuration(rest)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return now.Add(d), nil
+	}
+
+	// Split into the day, the time of day and the part of the day
+	var day time.Time
+	hasDay, weekday, next := false, false, false
+	clock, period := -1, -1
+	words := strings.Fields(s)
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if wd, ok := weekdays[w]; ok {
+			ahead := (int(wd) - int(now.Weekday()) + 7) % 7
+			if next && ahead == 0 {
+				ahead = 7
+			}
+			day, hasDay, weekday = now.AddDate(0, 0, ahead), true, !next
+			next = false
+			continue
+		}
+		if minutes, ok := namedTimes[w]; ok {
+			period = minutes
+			if w == "tonight" {
+				day, hasDay = now, true
+			}
+			continue
+		}
+		switch w {
+		case "at", "on", "this", "in", "the":
+		case "next":
+			next = true
+		case "week":
+			if !next {
+				return time.Time{}, invalid
+			}
+			day, hasDay, next = now.AddDate(0, 0, 7), true, false
+		case "today":
+			day, hasDay = now, true
+		case "tomorrow", "tmrw":
+			day, hasDay = now.AddDate(0, 0, 1), true
+		case "midnight":
+			// The one coming up, at the end of the day
+			day, hasDay, clock = now.AddDate(0, 0, 1), true, 0
+		default:
+			// A clock time, possibly written "9 am"
+			if i+1 < len(words) && (words[i+1] == "am" || words[i+1] == "pm") {
+				w += words[i+1]
+				i++
+			}
+			minutes, ok := parseClockWord(w)
+			if !ok || clock >= 0 {
+				return time.Time{}, invalid
+			}
+			clock = minutes
+		}
+	}
+	if next || (!hasDay && clock < 0 && period < 0) {
+		return time.Time{}, invalid
+	}
+
+	switch {
+	case clock < 0 && period >= 0:
+		clock = period
+	case clock < 0:
+		clock = defaultReminderHour * 60
+	case period >= 15*60 && clock >= 60 && clock < 12*60:
+		// "at 7 in the evening", "tonight at 9"
+		clock += 12 * 60
+	}
+	base := now
+	if hasDay {
+		base = day
+	}
+	when := time.Date(base.Year(), base.Month(), base.Day(), clock/60, clock%60, 0, 0, now.Location())
+	if !when.After(now) {
+		switch {
+		case !hasDay:
+			when = when.AddDate(0, 0, 1)
+		case weekday:
+			when = when.AddDate(0, 0, 7)
+		}
+	}
+	return when, nil
+}
+
+// parseSpokenDuration reads "45 minutes", "half an hour", "2 hours and 30
+// minutes", "an hour and a half", "1.5 days" or a Go duration such as
+// "1h30m".
+func parseSpokenDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.ReplaceAll(s, " ", "")); err == nil && d > 0 {
+		return d, nil
+	}
+
+	rest, half := strings.CutSuffix(s, " and a half")
+	var total, unit time.Duration
+	for _, part := range strings.Split(rest, " and ") {
+		m := reDuration.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		var n float64
+		switch m[1] {
+		case "a", "an":
+			n = 1
+		case "half a", "half an":
+			n = 0.5
+		default:
+			n, _ = strconv.ParseFloat(m[1], 64)
+		}
+		unit = durationUnits[m[2][0]]
+		total += time.Duration(n * float64(unit))
+	}
+	if half {
+		total += unit / 2
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return total, nil
+}
+
+// parseClockWord reads "9", "9am", "9:30", "9:30pm" or "18:30" as minutes
+// after midnight.
+func parseClockWord(w string) (int, bool) {
+	m := reClock.FindStringSubmatch(w)
+	if m == nil {
+		return 0, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		hour %= 12
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		hour = hour%12 + 12
+	}
+	if hour > 23 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
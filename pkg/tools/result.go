@@ -27,6 +27,10 @@ type ToolResult struct {
 	// When true, the tool will complete later and notify via callback.
 	Async bool `json:"async"`
 
+	// Images are data URLs of pictures for a multimodal model to look at,
+	// such as screenshots. They are sent with the next request only.
+	Images []string `json:"-"`
+
 	// Err is the underlying error (not JSON serialized).
 	// Used for internal error handling and logging.
 	Err error `json:"-"`
@@ -141,3 +145,14 @@ func (tr *ToolResult) WithError(err error) *ToolResult {
 	tr.Err = err
 	return tr
 }
+
+// WithImages attaches images for the model and returns the result for
+// chaining.
+//
+// Example:
+//
+//	result := NewToolResult("Screenshot of the screen").WithImages(dataURL)
+func (tr *ToolResult) WithImages(images ...string) *ToolResult {
+	tr.Images = append(tr.Images, images...)
+	return tr
+}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// RetryPolicy decides which failed tool calls are tried again, following
+// tools.retry in the config.
+type RetryPolicy struct {
+	defaultRule retryRule
+	perTool     map[string]retryRule
+}
+
+type retryRule struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	classes    []string
+}
+
+func newRetryRule(cfg config.ToolRetryPolicy) retryRule {
+	rule := retryRule{
+		attempts:   max(cfg.Attempts, 1),
+		backoff:    time.Duration(cfg.BackoffMS) * time.Millisecond,
+		maxBackoff: time.Duration(cfg.MaxBackoffMS) * time.Millisecond,
+		classes:    cfg.RetryOn,
+	}
+	if len(rule.classes) == 0 {
+		rule.classes = config.ToolRetryClasses
+	}
+	return rule
+}
+
+// NewRetryPolicy builds the policy from config.
+func NewRetryPolicy(cfg config.ToolRetryConfig) *RetryPolicy {
+	p := &RetryPolicy{
+		defaultRule: newRetryRule(cfg.Default),
+		perTool:     make(map[string]retryRule, len(cfg.PerTool)),
+	}
+	for name, rule := range cfg.PerTool {
+		p.perTool[name] = newRetryRule(rule)
+	}
+	return p
+}
+
+// rule returns the rule for the named tool. A nil policy never retries.
+func (p *RetryPolicy) rule(name string) retryRule {
+	if p == nil {
+		return retryRule{attempts: 1}
+	}
+	if rule, ok := p.perTool[name]; ok {
+		return rule
+	}
+	return p.defaultRule
+}
+
+// delay is the wait before the given retry, counted from 1.
+func (r retryRule) delay(retry int) time.Duration {
+	d := r.backoff << min(retry-1, 16)
+	if r.maxBackoff > 0 && d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+var httpStatusPattern = regexp.MustCompile(`(?i)\b(?:http|status)[ :]*([1-5]\d\d)\b`)
+
+// ErrorClass sorts a failed result into one of config.ToolRetryClasses, or
+// "" when the failure isn't a passing one, like a missing file or a bad
+// argument.
+func ErrorClass(result *ToolResult) string {
+	if result == nil || !result.IsError {
+		return ""
+	}
+	if err := result.Err; err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "timeout"
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			if netErr.Timeout() {
+				return "timeout"
+			}
+			return "network"
+		}
+	}
+
+	text := strings.ToLower(result.ForLLM)
+	if result.Err != nil {
+		text += "\n" + strings.ToLower(result.Err.Error())
+	}
+	if m := httpStatusPattern.FindStringSubmatch(text); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		switch {
+		case code == 429:
+			return "rate_limit"
+		case code >= 500:
+			return "server"
+		}
+	}
+	switch {
+	case strings.Contains(text, "too many requests"), strings.Contains(text, "rate limit"):
+		return "rate_limit"
+	case strings.Contains(text, "timeout"), strings.Contains(text, "timed out"),
+		strings.Contains(text, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(text, "connection refused"), strings.Contains(text, "connection reset"),
+		strings.Contains(text, "no such host"), strings.Contains(text, "network is unreachable"),
+		strings.Contains(text, "tls handshake"), strings.Contains(text, "unexpected eof"),
+		strings.Contains(text, "broken pipe"):
+		return "network"
+	}
+	return ""
+}
+
+// executeWithRetry runs tool, trying again after failures the policy
+// retries. It gives up early when ctx is done.
+func (r *ToolRegistry) executeWithRetry(ctx context.Context, tool Tool, args map[string]any) *ToolResult {
+	r.mu.RLock()
+	rule := r.retry.rule(tool.Name())
+	r.mu.RUnlock()
+
+	result := tool.Execute(ctx, args)
+	for attempt := 1; attempt < rule.attempts && !result.Async; attempt++ {
+		class := ErrorClass(result)
+		if class == "" || !slices.Contains(rule.classes, class) || ctx.Err() != nil {
+			break
+		}
+		wait := rule.delay(attempt)
+		logger.WarnCF("tool", "Retrying tool after a transient failure",
+			map[string]any{
+				"tool":    tool.Name(),
+				"class":   class,
+				"attempt": attempt + 1,
+				"wait_ms": wait.Milliseconds(),
+				"error":   result.ForLLM,
+			})
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(wait):
+		}
+		result = tool.Execute(ctx, args)
+	}
+	return result
+}
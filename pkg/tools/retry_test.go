@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// flakyTool fails with failure for the first fails calls, then succeeds.
+type flakyTool struct {
+	fails   int
+	failure string
+	calls   int
+}
+
+func (t *flakyTool) Name() string               { return "flaky" }
+func (t *flakyTool) Description() string        { return "Fails a few times" }
+func (t *flakyTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (t *flakyTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.calls++
+	if t.calls <= t.fails {
+		return ErrorResult(t.failure)
+	}
+	return NewToolResult("ok")
+}
+
+func TestRegistry_RetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    config.ToolRetryPolicy
+		failure   string
+		wantCalls int
+		wantError bool
+	}{
+		{
+			name:      "network error retried",
+			policy:    config.ToolRetryPolicy{Attempts: 3, BackoffMS: 1},
+			failure:   "request failed: dial tcp: connection refused",
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after attempts",
+			policy:    config.ToolRetryPolicy{Attempts: 2, BackoffMS: 1},
+			failure:   "fetching https://example.com failed: HTTP 503",
+			wantCalls: 2,
+			wantError: true,
+		},
+		{
+			name:      "permanent error not retried",
+			policy:    config.ToolRetryPolicy{Attempts: 3, BackoffMS: 1},
+			failure:   "fetching https://example.com failed: HTTP 404",
+			wantCalls: 1,
+			wantError: true,
+		},
+		{
+			name:      "class not listed",
+			policy:    config.ToolRetryPolicy{Attempts: 3, BackoffMS: 1, RetryOn: []string{"server"}},
+			failure:   "Tavily API error: status 429: slow down",
+			wantCalls: 1,
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &flakyTool{fails: 2, failure: tt.failure}
+			registry := NewToolRegistry()
+			registry.Register(tool)
+			registry.SetRetryPolicy(NewRetryPolicy(config.ToolRetryConfig{
+				Default: config.ToolRetryPolicy{Attempts: 1},
+				PerTool: map[string]config.ToolRetryPolicy{"flaky": tt.policy},
+			}))
+
+			result := registry.Execute(context.Background(), "flaky", map[string]any{})
+			if tool.calls != tt.wantCalls {
+				t.Errorf("tool ran %d times, want %d", tool.calls, tt.wantCalls)
+			}
+			if result.IsError != tt.wantError {
+				t.Errorf("IsError = %v, want %v (%s)", result.IsError, tt.wantError, result.ForLLM)
+			}
+		})
+	}
+}
+
+func TestRegistry_NoRetryWithoutPolicy(t *testing.T) {
+	tool := &flakyTool{fails: 1, failure: "request failed: connection reset by peer"}
+	registry := NewToolRegistry()
+	registry.Register(tool)
+
+	if result := registry.Execute(context.Background(), "flaky", map[string]any{}); !result.IsError {
+		t.Errorf("result = %q, want the failure", result.ForLLM)
+	}
+	if tool.calls != 1 {
+		t.Errorf("tool ran %d times, want 1", tool.calls)
+	}
+}
+
+func TestRegistry_RetryStopsWhenCancelled(t *testing.T) {
+	tool := &flakyTool{fails: 5, failure: "request failed: i/o timeout"}
+	registry := NewToolRegistry()
+	registry.Register(tool)
+	registry.SetRetryPolicy(NewRetryPolicy(config.ToolRetryConfig{
+		Default: config.ToolRetryPolicy{Attempts: 5, BackoffMS: 60000},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	registry.Execute(ctx, "flaky", map[string]any{})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry waited %v after the context was done", elapsed)
+	}
+	if tool.calls != 1 {
+		t.Errorf("tool ran %d times, want 1", tool.calls)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		result *ToolResult
+		want   string
+	}{
+		{ErrorResult("request failed: Get \"https://x\": dial tcp: lookup x: no such host"), "network"},
+		{ErrorResult("SearxNG error: status 502"), "server"},
+		{ErrorResult("fetching https://x failed: HTTP 429"), "rate_limit"},
+		{ErrorResult("command timed out after 60s"), "timeout"},
+		{ErrorResult("search failed").WithError(context.DeadlineExceeded), "timeout"},
+		{ErrorResult("file not found: notes.txt"), ""},
+		{ErrorResult("fetching https://x failed: HTTP 403"), ""},
+		{NewToolResult("connection refused is a common error"), ""},
+		{ErrorResult("wrapped").WithError(errors.New("plain")), ""},
+	}
+	for _, tt := range tests {
+		if got := ErrorClass(tt.result); got != tt.want {
+			t.Errorf("ErrorClass(%q) = %q, want %q", tt.result.ForLLM, got, tt.want)
+		}
+	}
+}
+
+func TestRetryRule_Delay(t *testing.T) {
+	rule := newRetryRule(config.ToolRetryPolicy{Attempts: 5, BackoffMS: 100, MaxBackoffMS: 300})
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := rule.delay(i + 1); got != w {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// runCodeDir is where runs that produce files are kept, relative to the
+	// workspace.
+	runCodeDir      = "runs"
+	runCodeMaxFiles = 20
+)
+
+// runCodeLanguages maps a language to its script name and interpreter.
+var runCodeLanguages = map[string]struct{ script, interpreter string }{
+	"python": {"main.py", "python"},
+	"node":   {"main.js", "node"},
+}
+
+// RunCodeTool runs Python and Node snippets in a throwaway Docker container
+// with CPU, memory and time limits. Each run gets a fresh directory in the
+// workspace as its working directory, so files it writes are kept.
+type RunCodeTool struct {
+	cfg       config.RunCodeConfig
+	workspace string
+	now       func() time.Time
+}
+
+func NewRunCodeTool(workspace string, cfg config.RunCodeConfig) *RunCodeTool {
+	if cfg.Docker == "" {
+		cfg.Docker = "docker"
+	}
+	if cfg.PythonImage == "" {
+		cfg.PythonImage = "python:3.12-slim"
+	}
+	if cfg.NodeImage == "" {
+		cfg.NodeImage = "node:22-slim"
+	}
+	if cfg.CPUs <= 0 {
+		cfg.CPUs = 1
+	}
+	if cfg.MemoryMB <= 0 {
+		cfg.MemoryMB = 512
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 60
+	}
+	if cfg.MaxOutputChars <= 0 {
+		cfg.MaxOutputChars = 10000
+	}
+	return &RunCodeTool{cfg: cfg, workspace: workspace, now: time.Now}
+}
+
+func (t *RunCodeTool) Name() string {
+	return "run_code"
+}
+
+func (t *RunCodeTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
+func (t *RunCodeTool) Description() string {
+	network := "has no network access, so only the standard library is available"
+	if t.cfg.Network {
+		network = "has network access"
+	}
+	return fmt.Sprintf("Run a Python or Node.js program in an isolated container and return its output. "+
+		"Use it for calculations, data processing and generating files such as charts or CSVs. "+
+		"The container %s, and runs are stopped after %d seconds. "+
+		"Files the program writes to its current directory are saved to the workspace and listed.",
+		network, t.cfg.TimeoutSeconds)
+}
+
+func (t *RunCodeTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"language": map[string]any{
+				"type":        "string",
+				"enum":        []string{"python", "node"},
+				"description": "Language of the code",
+			},
+			"code": map[string]any{
+				"type":        "string",
+				"description": "The complete program; print results to stdout",
+			},
+		},
+		"required": []string{"language", "code"},
+	}
+}
+
+func (t *RunCodeTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	language, _ := args["language"].(string)
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "javascript" || language == "js" {
+		language = "node"
+	}
+	lang, ok := runCodeLanguages[language]
+	if !ok {
+		return ErrorResult("language must be python or node")
+	}
+	code, ok := args["code"].(string)
+	if !ok || strings.TrimSpace(code) == "" {
+		return ErrorResult("code is required")
+	}
+
+	runID := t.now().Format("20060102-150405") + "-" + randomSuffix()
+	dir, err := filepath.Abs(filepath.Join(t.workspace, runCodeDir, runID))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to resolve run directory: %v", err))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create run directory: %v", err))
+	}
+	if err := os.WriteFile(filepath.Join(dir, lang.script), []byte(code), 0o644); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write program: %v", err))
+	}
+
+	container := "picoclaw-run-" + runID
+	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, t.cfg.Docker, t.dockerArgs(container, dir, language)...)
+	stdout := &cappedBuffer{limit: t.cfg.MaxOutputChars}
+	stderr := &cappedBuffer{limit: t.cfg.MaxOutputChars}
+	cmd.Stdout = progressOutput(ctx, stdout)
+	cmd.Stderr = progressOutput(ctx, stderr)
+	cmd.WaitDelay = 2 * time.Second
+	runErr := cmd.Run()
+
+	if cmdCtx.Err() != nil {
+		// Killing the client leaves the container running
+		removeCtx, cancelRemove := context.WithTimeout(context.Background(), 10*time.Second)
+		_ = exec.CommandContext(removeCtx, t.cfg.Docker, "rm", "-f", container).Run()
+		cancelRemove()
+	}
+
+	files := t.collectFiles(dir, lang.script)
+	if len(files) == 0 {
+		os.RemoveAll(dir)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(stdout.String())
+	if stderr.Len() > 0 {
+		sb.WriteString("\nSTDERR:\n" + stderr.String())
+	}
+	if dropped := stdout.dropped + stderr.dropped; dropped > 0 {
+		fmt.Fprintf(&sb, "\n... (truncated, %d more chars)", dropped)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+		fmt.Fprintf(&sb, "\nStopped after %d seconds", t.cfg.TimeoutSeconds)
+	case ctx.Err() != nil:
+		sb.WriteString("\nCancelled")
+	case errors.As(runErr, &exitErr):
+		fmt.Fprintf(&sb, "\nExit code: %d", exitErr.ExitCode())
+	case runErr != nil:
+		return ErrorResult(fmt.Sprintf("failed to run %s: %v", t.cfg.Docker, runErr))
+	}
+
+	if len(files) > 0 {
+		sb.WriteString("\nFiles written (in the workspace):")
+		for _, f := range files {
+			sb.WriteString("\n- " + f)
+		}
+	}
+
+	output := strings.TrimSpace(sb.String())
+	if output == "" {
+		output = "(no output)"
+	}
+	return &ToolResult{ForLLM: output, IsError: runErr != nil}
+}
+
+// dockerArgs builds the docker run command line for a run in dir.
+func (t *RunCodeTool) dockerArgs(container, dir, language string) []string {
+	image := t.cfg.PythonImage
+	if language == "node" {
+		image = t.cfg.NodeImage
+	}
+	memory := strconv.Itoa(t.cfg.MemoryMB) + "m"
+
+	args := []string{
+		"run", "--rm", "--name", container,
+		"--cpus", strconv.FormatFloat(t.cfg.CPUs, 'f', -1, 64),
+		"--memory", memory, "--memory-swap", memory,
+		"--pids-limit", "128",
+		"--read-only", "--tmpfs", "/tmp:rw,size=64m",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"-e", "HOME=/tmp",
+		"-v", dir + ":/work", "-w", "/work",
+	}
+	if !t.cfg.Network {
+		args = append(args, "--network", "none")
+	}
+	if runtime.GOOS != "windows" {
+		// Let the files written belong to us rather than root
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	lang := runCodeLanguages[language]
+	return append(args, image, lang.interpreter, lang.script)
+}
+
+// collectFiles lists the files a run left in dir, relative to the
+// workspace, with their sizes.
+func (t *RunCodeTool) collectFiles(dir, script string) []string {
+	workspace, _ := filepath.Abs(t.workspace)
+	var files []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == filepath.Join(dir, script) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(workspace, path)
+		if err != nil {
+			rel = path
+		}
+		files = append(files, fmt.Sprintf("%s (%s)", filepath.ToSlash(rel), formatSize(info.Size())))
+		return nil
+	})
+	sort.Strings(files)
+	if len(files) > runCodeMaxFiles {
+		more := len(files) - runCodeMaxFiles
+		files = append(files[:runCodeMaxFiles], fmt.Sprintf("... and %d more", more))
+	}
+	return files
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+func randomSuffix() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,113 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeDocker stands in for the docker CLI: it logs its arguments, and for
+// "run" executes the script's instructions against the mounted directory.
+const fakeDocker = `#!/bin/sh
+echo "$*" >> "$FAKE_DOCKER_LOG"
+[ "$1" = "run" ] || exit 0
+for arg in "$@"; do
+	case "$arg" in *:/work) dir="${arg%:/work}" ;; esac
+done
+for arg in "$@"; do last="$arg"; done
+code="$dir/$last"
+grep -q SLEEP "$code" && sleep 5
+grep -q WRITE "$code" && echo "a,b" > "$dir/out.csv"
+grep -q FAIL "$code" && { echo "Traceback: boom" >&2; exit 3; }
+echo "ran $last"
+`
+
+func newFakeDockerTool(t *testing.T, cfg config.RunCodeConfig) (*RunCodeTool, string, string) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(bin, []byte(fakeDocker), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(t.TempDir(), "docker.log")
+	t.Setenv("FAKE_DOCKER_LOG", logFile)
+
+	workspace := t.TempDir()
+	cfg.Docker = bin
+	return NewRunCodeTool(workspace, cfg), workspace, logFile
+}
+
+func TestRunCodeTool_Python(t *testing.T) {
+	tool, workspace, logFile := newFakeDockerTool(t, config.RunCodeConfig{MemoryMB: 256, CPUs: 0.5})
+
+	result := tool.Execute(context.Background(), map[string]any{"language": "python", "code": "print(1)"})
+	if result.IsError || result.ForLLM != "ran main.py" {
+		t.Fatalf("Execute() = %q (error %v)", result.ForLLM, result.IsError)
+	}
+
+	log, _ := os.ReadFile(logFile)
+	for _, want := range []string{
+		"--network none", "--memory 256m", "--memory-swap 256m", "--cpus 0.5", "--read-only",
+		"--cap-drop ALL", "python:3.12-slim python main.py",
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("docker args %q missing %q", log, want)
+		}
+	}
+
+	// A run without files leaves nothing behind
+	if entries, _ := os.ReadDir(filepath.Join(workspace, runCodeDir)); len(entries) != 0 {
+		t.Errorf("run directory kept: %v", entries)
+	}
+}
+
+func TestRunCodeTool_FilesAndErrors(t *testing.T) {
+	tool, workspace, logFile := newFakeDockerTool(t, config.RunCodeConfig{Network: true})
+
+	result := tool.Execute(context.Background(), map[string]any{"language": "javascript", "code": "// WRITE"})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "ran main.js") || !strings.Contains(result.ForLLM, "/out.csv (4 bytes)") {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	matches, _ := filepath.Glob(filepath.Join(workspace, runCodeDir, "*", "out.csv"))
+	if len(matches) != 1 {
+		t.Errorf("generated file not kept in the workspace: %v", matches)
+	}
+	if log, _ := os.ReadFile(logFile); strings.Contains(string(log), "--network none") {
+		t.Errorf("network disabled although allowed: %s", log)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"language": "python", "code": "FAIL"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "Traceback: boom") ||
+		!strings.Contains(result.ForLLM, "Exit code: 3") {
+		t.Errorf("failed run = %q (error %v)", result.ForLLM, result.IsError)
+	}
+
+	for _, args := range []map[string]any{
+		{"language": "ruby", "code": "puts 1"},
+		{"language": "python", "code": "  "},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("Execute(%v) succeeded", args)
+		}
+	}
+}
+
+func TestRunCodeTool_Timeout(t *testing.T) {
+	tool, _, logFile := newFakeDockerTool(t, config.RunCodeConfig{TimeoutSeconds: 1})
+
+	result := tool.Execute(context.Background(), map[string]any{"language": "python", "code": "SLEEP"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "Stopped after 1 seconds") {
+		t.Errorf("Execute() = %q (error %v)", result.ForLLM, result.IsError)
+	}
+	if log, _ := os.ReadFile(logFile); !strings.Contains(string(log), "rm -f picoclaw-run-") {
+		t.Errorf("container not removed after the timeout: %s", log)
+	}
+}
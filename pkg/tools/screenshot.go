@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	screenshotTimeout = 20 * time.Second
+	maxScreenshotWait = 10
+)
+
+// screenshotProgram is a command line screenshot tool; the file to save to
+// is appended to its arguments. window is nil when it can't capture just
+// the active window.
+type screenshotProgram struct {
+	display string // environment variable that must be set
+	screen  []string
+	window  []string
+}
+
+// screenshotPrograms are tried in order on Linux and the BSDs.
+var screenshotPrograms = []screenshotProgram{
+	{"WAYLAND_DISPLAY", []string{"grim"}, nil},
+	{"WAYLAND_DISPLAY", []string{"spectacle", "-b", "-n", "-f", "-o"}, []string{"spectacle", "-b", "-n", "-a", "-o"}},
+	{"DISPLAY", []string{"gnome-screenshot", "-f"}, []string{"gnome-screenshot", "-w", "-f"}},
+	{"DISPLAY", []string{"spectacle", "-b", "-n", "-f", "-o"}, []string{"spectacle", "-b", "-n", "-a", "-o"}},
+	{"DISPLAY", []string{"scrot"}, []string{"scrot", "-u"}},
+	{"DISPLAY", []string{"maim"}, nil},
+	{"DISPLAY", []string{"import", "-window", "root"}, nil},
+}
+
+// ScreenshotTool captures the screen, or the active window, of the desktop
+// picoclaw runs on and passes the picture to the model, for questions like
+// "what does this error dialog mean?".
+type ScreenshotTool struct {
+	maxWidth int
+	capture  func(ctx context.Context, window bool, path string) error
+}
+
+func NewScreenshotTool(cfg config.ScreenshotToolsConfig) *ScreenshotTool {
+	maxWidth := cfg.MaxWidth
+	if maxWidth <= 0 {
+		maxWidth = 1568
+	}
+	return &ScreenshotTool{maxWidth: maxWidth, capture: captureScreen}
+}
+
+func (t *ScreenshotTool) Name() string {
+	return "screenshot"
+}
+
+func (t *ScreenshotTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *ScreenshotTool) Description() string {
+	return "Take a screenshot of the user's screen or active window and look at it. " +
+		"Use when the user asks about something on their screen, such as an error dialog"
+}
+
+func (t *ScreenshotTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"target": map[string]any{
+				"type":        "string",
+				"enum":        []string{"screen", "window"},
+				"description": "screen (default) for everything, window for the active window only",
+			},
+			"delay_seconds": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Wait before capturing, up to %d, e.g. so the user can open a menu", maxScreenshotWait),
+			},
+		},
+	}
+}
+
+func (t *ScreenshotTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	target, _ := args["target"].(string)
+	if target == "" {
+		target = "screen"
+	}
+	if target != "screen" && target != "window" {
+		return ErrorResult(fmt.Sprintf("unknown target %q; use screen or window", target))
+	}
+	if delay, ok := args["delay_seconds"].(float64); ok && delay > 0 {
+		select {
+		case <-time.After(time.Duration(min(delay, maxScreenshotWait)) * time.Second):
+		case <-ctx.Done():
+			return ErrorResult("screenshot cancelled")
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "picoclaw-screenshot-")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to take screenshot: %v", err)).WithError(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screenshot.png")
+
+	captureCtx, cancel := context.WithTimeout(ctx, screenshotTimeout)
+	defer cancel()
+	if err := t.capture(captureCtx, target == "window", path); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to take screenshot: %v", err)).WithError(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to take screenshot: %v", err)).WithError(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read screenshot: %v", err)).WithError(err)
+	}
+
+	bounds := img.Bounds()
+	img = scaleToWidth(img, t.maxWidth)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to encode screenshot: %v", err)).WithError(err)
+	}
+	dataURL := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	return NewToolResult(fmt.Sprintf("Screenshot of the %s (%dx%d); the image follows.",
+		target, bounds.Dx(), bounds.Dy())).WithImages(dataURL)
+}
+
+// scaleToWidth shrinks img to width pixels across, keeping its aspect
+// ratio, by averaging the pixels each new pixel covers. Narrower images are
+// returned as they are.
+func scaleToWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width {
+		return img
+	}
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := range width {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			var r, g, bl, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, _ := img.At(sx, sy).RGBA()
+					r, g, bl, n = r+cr>>8, g+cg>>8, bl+cb>>8, n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255})
+		}
+	}
+	return dst
+}
+
+// captureScreen saves a PNG screenshot to path with the platform's tools.
+func captureScreen(ctx context.Context, window bool, path string) error {
+	var argv []string
+	switch runtime.GOOS {
+	case "darwin":
+		if window {
+			return errors.New("capturing only the active window isn't supported on macOS; capture the screen")
+		}
+		argv = []string{"screencapture", "-x", "-t", "png", path}
+	case "windows":
+		argv = []string{"powershell", "-NoProfile", "-Command", windowsScreenshotScript(window, path)}
+	default:
+		program, err := findScreenshotProgram(window)
+		if err != nil {
+			return err
+		}
+		argv = append(append([]string{}, program...), path)
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", argv[0], err, strings.TrimSpace(string(out)))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s saved no screenshot", argv[0])
+	}
+	return nil
+}
+
+// findScreenshotProgram returns the arguments of the first installed
+// program that can capture the screen, or the active window, in this
+// desktop session.
+func findScreenshotProgram(window bool) ([]string, error) {
+	foundScreenOnly := false
+	for _, p := range screenshotPrograms {
+		if os.Getenv(p.display) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(p.screen[0]); err != nil {
+			continue
+		}
+		if !window {
+			return p.screen, nil
+		}
+		if p.window != nil {
+			return p.window, nil
+		}
+		foundScreenOnly = true
+	}
+	if foundScreenOnly {
+		return nil, errors.New(
+			"capturing only the active window needs gnome-screenshot, spectacle or scrot; capture the screen")
+	}
+	return nil, errors.New("no screenshot program found; this needs a desktop session and grim, " +
+		"gnome-screenshot, spectacle, scrot, maim or ImageMagick's import")
+}
+
+// windowsScreenshotScript returns PowerShell that copies the virtual
+// screen, or the foreground window's rectangle, to a PNG at path.
+func windowsScreenshotScript(window bool, path string) string {
+	bounds := "$b = [System.Windows.Forms.SystemInformation]::VirtualScreen"
+	if window {
+		bounds = `Add-Type -TypeDefinition @'
+using System;
+using System.Runtime.InteropServices;
+public struct PicoRect { public int Left, Top, Right, Bottom; }
+public static class PicoWin {
+	[DllImport("user32.dll")] public static extern IntPtr GetForegroundWindow();
+	[DllImport("user32.dll")] public static extern bool GetWindowRect(IntPtr hwnd, out PicoRect rect);
+}
+'@
+$r = New-Object PicoRect
+[PicoWin]::GetWindowRect([PicoWin]::GetForegroundWindow(), [ref]$r) | Out-Null
+$b = [System.Drawing.Rectangle]::FromLTRB($r.Left, $r.Top, $r.Right, $r.Bottom)`
+	}
+	return "Add-Type -AssemblyName System.Windows.Forms, System.Drawing\n" + bounds + `
+$img = New-Object System.Drawing.Bitmap $b.Width, $b.Height
+$g = [System.Drawing.Graphics]::FromImage($img)
+$g.CopyFromScreen($b.Location, [System.Drawing.Point]::Empty, $b.Size)
+$img.Save('` + strings.ReplaceAll(path, "'", "''") + `', [System.Drawing.Imaging.ImageFormat]::Png)`
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestScreenshotTool(t *testing.T) {
+	tool := NewScreenshotTool(config.ScreenshotToolsConfig{MaxWidth: 300})
+	var gotWindow bool
+	tool.capture = func(ctx context.Context, window bool, path string) error {
+		gotWindow = window
+		// A 900x600 screen, red on the left half and white on the right
+		img := image.NewRGBA(image.Rect(0, 0, 900, 600))
+		for y := range 600 {
+			for x := range 900 {
+				c := color.RGBA{255, 255, 255, 255}
+				if x < 450 {
+					c = color.RGBA{255, 0, 0, 255}
+				}
+				img.SetRGBA(x, y, c)
+			}
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return png.Encode(f, img)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"target": "window"})
+	if result.IsError || !gotWindow {
+		t.Fatalf("Execute = %q, window = %v", result.ForLLM, gotWindow)
+	}
+	if result.ForLLM != "Screenshot of the window (900x600); the image follows." {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	if len(result.Images) != 1 || !strings.HasPrefix(result.Images[0], "data:image/jpeg;base64,") {
+		t.Fatalf("Images = %.60q", result.Images)
+	}
+	data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(result.Images[0], "data:image/jpeg;base64,"))
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(300, 200) {
+		t.Errorf("size = %v, want 300x200", size)
+	}
+	if r, g, _, _ := img.At(20, 100).RGBA(); r>>8 < 200 || g>>8 > 60 {
+		t.Errorf("left of the screenshot isn't red: %v", img.At(20, 100))
+	}
+
+	tool.capture = func(ctx context.Context, window bool, path string) error {
+		return errors.New("no screenshot program found")
+	}
+	if result := tool.Execute(context.Background(), map[string]any{}); !result.IsError ||
+		!strings.Contains(result.ForLLM, "no screenshot program found") {
+		t.Errorf("failed capture = %q", result.ForLLM)
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"target": "desk"}); !result.IsError {
+		t.Errorf("unknown target = %q", result.ForLLM)
+	}
+}
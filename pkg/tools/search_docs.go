@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/docindex"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// docsRefreshAge is how old the index may be before a search first picks up
+// changed files.
+const docsRefreshAge = 30 * time.Second
+
+// SearchDocsTool finds passages in the workspace documents by meaning,
+// rather than by the exact words grep needs.
+type SearchDocsTool struct {
+	index    *docindex.Indexer
+	topK     int
+	minScore float64
+}
+
+// NewSearchDocsTool creates a search_docs tool over an agent's document index.
+func NewSearchDocsTool(index *docindex.Indexer, topK int, minScore float64) *SearchDocsTool {
+	if topK <= 0 {
+		topK = 5
+	}
+	return &SearchDocsTool{index: index, topK: topK, minScore: minScore}
+}
+
+func (t *SearchDocsTool) Name() string {
+	return "search_docs"
+}
+
+func (t *SearchDocsTool) Risk() RiskLevel {
+	return RiskLow
+}
+
+func (t *SearchDocsTool) Description() string {
+	return "Search the documents, notes and code in the workspace by meaning. Returns the most relevant passages " +
+		"with their file and line. Use it to answer questions from the user's files; read_file shows more around a hit."
+}
+
+func (t *SearchDocsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What to look for, as a question or description",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of passages (1-20)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Only search files under this workspace path (optional)",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *SearchDocsTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ErrorResult("query is required")
+	}
+	limit := t.topK
+	if l, ok := args["limit"].(float64); ok && l >= 1 && l <= 20 {
+		limit = int(l)
+	}
+	prefix, _ := args["path"].(string)
+	prefix = strings.Trim(strings.TrimPrefix(prefix, "./"), "/")
+
+	if stats, err := t.index.Refresh(ctx, docsRefreshAge); err != nil {
+		// Search what is indexed; the failed files are tried again later
+		logger.WarnCF("tool", "Could not index all documents", map[string]any{"error": err.Error()})
+	} else if stats.Indexed > 0 || stats.Removed > 0 {
+		logger.InfoCF("tool", "Updated document index", map[string]any{
+			"indexed": stats.Indexed,
+			"removed": stats.Removed,
+			"chunks":  stats.Chunks,
+		})
+	}
+
+	// Filter after the search, so ask for more when limited to a path
+	k := limit
+	if prefix != "" {
+		k = 0
+	}
+	hits, err := t.index.Search(ctx, query, k, t.minScore)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("document search failed: %v", err))
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, hit := range hits {
+		if prefix != "" && hit.Path != prefix && !strings.HasPrefix(hit.Path, prefix+"/") {
+			continue
+		}
+		n++
+		where := fmt.Sprintf("%s:%d", hit.Path, hit.Line)
+		if hit.Page > 0 {
+			where = fmt.Sprintf("%s, page %d", hit.Path, hit.Page)
+		}
+		fmt.Fprintf(&sb, "\n%d. %s (score %.2f)\n%s\n", n, where, hit.Score, hit.Text)
+		if n == limit {
+			break
+		}
+	}
+	if n == 0 {
+		return SilentResult(fmt.Sprintf("No documents match %q.", query))
+	}
+	return SilentResult(fmt.Sprintf("Found %d passages for %q:\n%s", n, query, sb.String()))
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/docindex"
+	"github.com/sipeed/picoclaw/pkg/vectorstore"
+)
+
+func TestSearchDocsTool(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"notes/garden.md":   "Water the garden every morning.",
+		"archive/garden.md": "The old garden plan.",
+		"notes/bills.md":    "Pay the invoice.",
+	} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0o755)
+		os.WriteFile(filepath.Join(root, path), []byte(content), 0o644)
+	}
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			switch {
+			case strings.Contains(text, "garden"):
+				vectors[i] = []float32{1, 0, 0}
+			case strings.Contains(text, "invoice"):
+				vectors[i] = []float32{0, 1, 0}
+			default:
+				vectors[i] = []float32{0, 0, 1}
+			}
+		}
+		return vectors, nil
+	}
+	store, _ := vectorstore.Open(filepath.Join(root, "state", "docs.jsonl"))
+	tool := NewSearchDocsTool(docindex.New(root, store, embed, docindex.Options{Extensions: []string{".md"}}), 5, 0.9)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"query": "garden"})
+	if result.IsError || !strings.Contains(result.ForLLM, "Found 2 passages") {
+		t.Fatalf("search_docs = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"query": "garden", "path": "./notes/"})
+	if !strings.Contains(result.ForLLM, "notes/garden.md:1") || strings.Contains(result.ForLLM, "archive/") {
+		t.Errorf("search_docs under notes = %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"query": "taxes"}); !strings.Contains(result.ForLLM, "No documents") {
+		t.Errorf("search_docs without a match = %q", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{}); !result.IsError {
+		t.Error("expected an error without a query")
+	}
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/usertime"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// sendLaterDates and sendLaterClocks are the forms "at" accepts, read in
+// local time. A clock time alone means the next time the clock shows it.
+var (
+	sendLaterDates  = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+	sendLaterClocks = []string{"15:04", "3:04pm", "3pm"}
+)
+
+// SendLaterTool schedules a message to the current chat. It is stored as a
+// one-time cron job, so it is sent even if the gateway restarts meanwhile.
+type SendLaterTool struct {
+	cronService *cron.CronService
+	now         func() time.Time
+
+	mu      sync.RWMutex
+	channel string
+	chatID  string
+}
+
+func NewSendLaterTool(cronService *cron.CronService) *SendLaterTool {
+	return &SendLaterTool{cronService: cronService, now: time.Now}
+}
+
+func (t *SendLaterTool) Name() string {
+	return "send_later"
+}
+
+func (t *SendLaterTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *SendLaterTool) Description() string {
+	return "Schedule a message to be sent to the user in this chat at a later time, e.g. " +
+		"\"send me this at 9am\" or \"remind me of this in 2 hours\". Give either at (in the user's time zone) " +
+		"or delay_seconds. The message is sent as written, without further processing. " +
+		"Scheduled messages appear in the cron tool's list and can be cancelled there."
+}
+
+func (t *SendLaterTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The message to send",
+			},
+			"at": map[string]any{
+				"type": "string",
+				"description": "Time to send at, in the user's time zone: \"09:00\" or \"9am\" for the next " +
+					"time the clock shows it, or a date and time such as \"2026-03-14 18:30\" or RFC 3339",
+			},
+			"delay_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Seconds from now to send at, instead of at",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *SendLaterTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *SendLaterTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.mu.RLock()
+	channel, chatID := ChatFrom(ctx, t.channel, t.chatID)
+	t.mu.RUnlock()
+	if channel == "" || chatID == "" {
+		return ErrorResult("no chat to send to; use this tool in an active conversation")
+	}
+
+	content, _ := args["content"].(string)
+	if strings.TrimSpace(content) == "" {
+		return ErrorResult("content is required")
+	}
+
+	now := t.now().In(usertime.Location(ctx))
+	var sendAt time.Time
+	at, _ := args["at"].(string)
+	delay, hasDelay := args["delay_seconds"].(float64)
+	switch {
+	case at != "":
+		var err error
+		if sendAt, err = parseSendTime(at, now); err != nil {
+			return ErrorResult(err.Error())
+		}
+	case hasDelay && delay > 0:
+		sendAt = now.Add(time.Duration(delay) * time.Second)
+	default:
+		return ErrorResult("one of at or a positive delay_seconds is required")
+	}
+	if !sendAt.After(now) {
+		return ErrorResult(fmt.Sprintf("%s is in the past", sendAt.Format("2006-01-02 15:04")))
+	}
+
+	atMS := sendAt.UnixMilli()
+	job, err := t.cronService.AddJob(
+		"Send later: "+utils.Truncate(content, 30),
+		cron.CronSchedule{Kind: "at", AtMS: &atMS},
+		content,
+		true,
+		channel,
+		chatID,
+	)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to schedule message: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Message scheduled for %s (id: %s)",
+		sendAt.Format("Mon 2006-01-02 15:04 MST"), job.ID))
+}
+
+// parseSendTime reads value as one of sendLaterDates or sendLaterClocks,
+// in now's location.
+func parseSendTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range sendLaterDates {
+		if parsed, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return parsed, nil
+		}
+	}
+
+	clock := strings.ToLower(strings.ReplaceAll(value, " ", ""))
+	for _, layout := range sendLaterClocks {
+		parsed, err := time.Parse(layout, clock)
+		if err != nil {
+			continue
+		}
+		next := time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot read time %q; use e.g. \"09:00\", \"9am\" or \"2026-03-14 18:30\"", value)
+}
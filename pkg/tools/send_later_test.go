@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+func TestSendLaterToolSchedulesMessage(t *testing.T) {
+	cs := cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	tool := NewSendLaterTool(cs)
+	now := time.Date(2026, 3, 14, 22, 15, 0, 0, time.Local)
+	tool.now = func() time.Time { return now }
+
+	if result := tool.Execute(context.Background(), map[string]any{"content": "hi", "at": "9am"}); !result.IsError {
+		t.Fatalf("scheduled without a chat: %+v", result)
+	}
+
+	tool.SetContext("telegram", "42")
+	result := tool.Execute(context.Background(), map[string]any{"content": "Call the dentist", "at": "9am"})
+	if result.IsError {
+		t.Fatalf("Execute() error = %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "2026-03-15 09:00") {
+		t.Errorf("result = %q, want tomorrow 09:00", result.ForLLM)
+	}
+
+	jobs := cs.ListJobs(true)
+	if len(jobs) != 1 {
+		t.Fatalf("jobs = %d, want 1", len(jobs))
+	}
+	job := jobs[0]
+	want := time.Date(2026, 3, 15, 9, 0, 0, 0, time.Local).UnixMilli()
+	if job.Schedule.Kind != "at" || *job.Schedule.AtMS != want || !job.DeleteAfterRun {
+		t.Errorf("schedule = %+v", job.Schedule)
+	}
+	if !job.Payload.Deliver || job.Payload.Channel != "telegram" || job.Payload.To != "42" ||
+		job.Payload.Message != "Call the dentist" {
+		t.Errorf("payload = %+v", job.Payload)
+	}
+}
+
+func TestSendLaterToolRejectsBadTimes(t *testing.T) {
+	tool := NewSendLaterTool(cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil))
+	tool.SetContext("slack", "C1")
+	now := time.Date(2026, 3, 14, 22, 15, 0, 0, time.Local)
+	tool.now = func() time.Time { return now }
+
+	for _, args := range []map[string]any{
+		{"content": "x"},
+		{"content": "x", "at": "tomorrow-ish"},
+		{"content": "x", "at": "2026-03-14 08:00"},
+		{"content": "x", "delay_seconds": float64(0)},
+		{"at": "9am"},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("Execute(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestParseSendTime(t *testing.T) {
+	now := time.Date(2026, 3, 14, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"09:00", time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)},
+		{"8:00", time.Date(2026, 3, 15, 8, 0, 0, 0, time.Local)},
+		{"6:45 PM", time.Date(2026, 3, 14, 18, 45, 0, 0, time.Local)},
+		{"2026-04-01 07:00", time.Date(2026, 4, 1, 7, 0, 0, 0, time.Local)},
+		{"2026-04-01T07:00:00Z", time.Date(2026, 4, 1, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSendTime(tt.value, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSendTime(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,12 +17,21 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
+const (
+	defaultExecTimeout   = 60 * time.Second
+	defaultExecMaxOutput = 10000
+)
+
 type ExecTool struct {
 	workingDir          string
 	timeout             time.Duration
+	maxOutput           int
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	allowedPaths        []config.AllowedPath // exceptions to restrictToWorkspace
+	sandbox             *execSandbox
+	sandboxErr          error // set when the configured sandbox can't be used
 }
 
 var defaultDenyPatterns = []*regexp.Regexp{
@@ -75,6 +85,12 @@ func NewExecTool(workingDir string, restrict bool) *ExecTool {
 
 func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Config) *ExecTool {
 	denyPatterns := make([]*regexp.Regexp, 0)
+	tool := &ExecTool{
+		workingDir:          workingDir,
+		timeout:             defaultExecTimeout,
+		maxOutput:           defaultExecMaxOutput,
+		restrictToWorkspace: restrict,
+	}
 
 	enableDenyPatterns := true
 	if config != nil {
@@ -98,23 +114,46 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 			// If deny patterns are disabled, we won't add any patterns, allowing all commands.
 			fmt.Println("Warning: deny patterns are disabled. All commands will be allowed.")
 		}
+
+		if execConfig.TimeoutSeconds > 0 {
+			tool.timeout = time.Duration(execConfig.TimeoutSeconds) * time.Second
+		}
+		if execConfig.MaxOutputChars > 0 {
+			tool.maxOutput = execConfig.MaxOutputChars
+		}
+		for _, pattern := range execConfig.AllowPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fmt.Printf("Invalid allow pattern %q: %v\n", pattern, err)
+				continue
+			}
+			tool.allowPatterns = append(tool.allowPatterns, re)
+		}
+		if len(execConfig.AllowPatterns) > 0 && len(tool.allowPatterns) == 0 {
+			// Every allow pattern was invalid; allow nothing rather than everything
+			tool.allowPatterns = []*regexp.Regexp{regexp.MustCompile(`[^\s\S]`)}
+		}
+		tool.allowedPaths = config.Agents.Defaults.AllowedPaths
+		tool.sandbox, tool.sandboxErr = newExecSandbox(execConfig.Sandbox)
+		if tool.sandboxErr != nil {
+			fmt.Printf("Exec sandbox unavailable, commands will be refused: %v\n", tool.sandboxErr)
+		}
 	} else {
 		denyPatterns = append(denyPatterns, defaultDenyPatterns...)
 	}
 
-	return &ExecTool{
-		workingDir:          workingDir,
-		timeout:             60 * time.Second,
-		denyPatterns:        denyPatterns,
-		allowPatterns:       nil,
-		restrictToWorkspace: restrict,
-	}
+	tool.denyPatterns = denyPatterns
+	return tool
 }
 
 func (t *ExecTool) Name() string {
 	return "exec"
 }
 
+func (t *ExecTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 func (t *ExecTool) Description() string {
 	return "Execute a shell command and return its output. Use with caution."
 }
@@ -137,33 +176,9 @@ func (t *ExecTool) Parameters() map[string]any {
 }
 
 func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
-	command, ok := args["command"].(string)
-	if !ok {
-		return ErrorResult("command is required")
-	}
-
-	cwd := t.workingDir
-	if wd, ok := args["working_dir"].(string); ok && wd != "" {
-		if t.restrictToWorkspace && t.workingDir != "" {
-			resolvedWD, err := validatePath(wd, t.workingDir, true)
-			if err != nil {
-				return ErrorResult("Command blocked by safety guard (" + err.Error() + ")")
-			}
-			cwd = resolvedWD
-		} else {
-			cwd = wd
-		}
-	}
-
-	if cwd == "" {
-		wd, err := os.Getwd()
-		if err == nil {
-			cwd = wd
-		}
-	}
-
-	if guardError := t.guardCommand(command, cwd); guardError != "" {
-		return ErrorResult(guardError)
+	command, cwd, errResult := t.prepare(args)
+	if errResult != nil {
+		return errResult
 	}
 
 	// timeout == 0 means no timeout
@@ -177,7 +192,13 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	defer cancel()
 
 	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
+	if t.sandbox != nil {
+		if abs, err := filepath.Abs(cwd); err == nil {
+			cwd = abs
+		}
+		name, sandboxArgs := t.sandbox.wrap(command, cwd, t.writableDirs(cwd))
+		cmd = exec.CommandContext(cmdCtx, name, sandboxArgs...)
+	} else if runtime.GOOS == "windows" {
 		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
 	} else {
 		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
@@ -188,9 +209,10 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 
 	prepareCommandForTermination(cmd)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout := &cappedBuffer{limit: t.maxOutput}
+	stderr := &cappedBuffer{limit: t.maxOutput}
+	cmd.Stdout = progressOutput(ctx, stdout)
+	cmd.Stderr = progressOutput(ctx, stderr)
 
 	if err := cmd.Start(); err != nil {
 		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
@@ -220,6 +242,7 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	if stderr.Len() > 0 {
 		output += "\nSTDERR:\n" + stderr.String()
 	}
+	dropped := stdout.dropped + stderr.dropped
 
 	if err != nil {
 		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
@@ -237,9 +260,12 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		output = "(no output)"
 	}
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	if maxLen := t.maxOutput; len(output) > maxLen || dropped > 0 {
+		if len(output) > maxLen {
+			dropped += len(output) - maxLen
+			output = output[:maxLen]
+		}
+		output += fmt.Sprintf("\n... (truncated, %d more chars)", dropped)
 	}
 
 	if err != nil {
@@ -257,6 +283,43 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
+// prepare checks the call and returns the command and the directory to run
+// it in.
+func (t *ExecTool) prepare(args map[string]any) (command, cwd string, errResult *ToolResult) {
+	command, ok := args["command"].(string)
+	if !ok {
+		return "", "", ErrorResult("command is required")
+	}
+
+	cwd = t.workingDir
+	if wd, ok := args["working_dir"].(string); ok && wd != "" {
+		if t.restrictToWorkspace && t.workingDir != "" {
+			resolvedWD, err := validatePath(wd, t.workingDir, true, t.allowedPaths, true)
+			if err != nil {
+				return "", "", ErrorResult("Command blocked by safety guard (" + err.Error() + ")")
+			}
+			cwd = resolvedWD
+		} else {
+			cwd = wd
+		}
+	}
+
+	if cwd == "" {
+		wd, err := os.Getwd()
+		if err == nil {
+			cwd = wd
+		}
+	}
+
+	if guardError := t.guardCommand(command, cwd); guardError != "" {
+		return "", "", ErrorResult(guardError)
+	}
+	if t.sandboxErr != nil {
+		return "", "", ErrorResult("Command blocked: " + t.sandboxErr.Error())
+	}
+	return command, cwd, nil
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
@@ -268,6 +331,9 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	}
 
 	if len(t.allowPatterns) > 0 {
+		if hasShellMetachars(cmd) {
+			return "Command blocked by safety guard (shell operators aren't allowed with an allowlist)"
+		}
 		allowed := false
 		for _, pattern := range t.allowPatterns {
 			if pattern.MatchString(lower) {
@@ -304,7 +370,7 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 				continue
 			}
 
-			if strings.HasPrefix(rel, "..") {
+			if strings.HasPrefix(rel, "..") && !t.execAllowed(p) {
 				return "Command blocked by safety guard (path outside working dir)"
 			}
 		}
@@ -313,6 +379,77 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// shellMetachars chain, substitute or redirect commands. An allow pattern
+// only vouches for the start of a line, so a line with any of them could run
+// something it never matched.
+const shellMetachars = ";&|$`<>()\n\r"
+
+// hasShellMetachars reports whether cmd has a shell operator.
+func hasShellMetachars(cmd string) bool {
+	return strings.ContainsAny(cmd, shellMetachars)
+}
+
+// execAllowed reports whether a command may use a path outside the working
+// dir because an allowed_paths entry covers it. A read-only entry counts only
+// with the sandbox on, as nothing else stops a command from writing to it.
+func (t *ExecTool) execAllowed(path string) bool {
+	entry, ok := allowedPath(path, t.allowedPaths)
+	return ok && (entry.Write || t.sandbox != nil)
+}
+
+// writableDirs returns the directories a sandboxed command may write to:
+// the workspace, cwd when it lies outside it, and the writable allowed paths.
+func (t *ExecTool) writableDirs(cwd string) []string {
+	var dirs []string
+	if t.workingDir != "" {
+		if abs, err := filepath.Abs(t.workingDir); err == nil {
+			dirs = append(dirs, abs)
+		}
+	}
+	if cwd != "" {
+		inside := false
+		for _, dir := range dirs {
+			if rel, err := filepath.Rel(dir, cwd); err == nil && !strings.HasPrefix(rel, "..") {
+				inside = true
+			}
+		}
+		if !inside {
+			dirs = append(dirs, cwd)
+		}
+	}
+	for _, entry := range t.allowedPaths {
+		// The sandbox can only bind paths that exist
+		if _, err := os.Stat(entry.Path); entry.Write && err == nil {
+			dirs = append(dirs, entry.Path)
+		}
+	}
+	return dirs
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the
+// rest, so a chatty command can't exhaust memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit   int
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		room = max(room, 0)
+		b.Buffer.Write(p[:room])
+		b.dropped += len(p) - room
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// ReadFrom hides bytes.Buffer's, which os/exec would use to copy the
+// command's output past the limit.
+func (b *cappedBuffer) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{b}, r)
+}
+
 func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
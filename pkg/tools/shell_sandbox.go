@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// execSandbox wraps shell commands in bubblewrap or nsjail. Everything but
+// the writable directories and /tmp is mounted read-only.
+type execSandbox struct {
+	kind    string
+	path    string
+	network bool
+	args    []string
+}
+
+// newExecSandbox resolves the configured sandbox. It returns nil when none
+// is configured, and an error when one is configured but can't be used, so
+// commands are refused rather than run unconfined.
+func newExecSandbox(cfg config.ExecSandboxConfig) (*execSandbox, error) {
+	if cfg.Type == "" || cfg.Type == config.ExecSandboxNone {
+		return nil, nil
+	}
+	if cfg.Type != config.ExecSandboxBwrap && cfg.Type != config.ExecSandboxNsjail {
+		return nil, fmt.Errorf("exec sandbox %q is not valid; use %q, %q or %q",
+			cfg.Type, config.ExecSandboxNone, config.ExecSandboxBwrap, config.ExecSandboxNsjail)
+	}
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("exec sandbox %q is only supported on linux", cfg.Type)
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = cfg.Type
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("exec sandbox %q not found: %w", path, err)
+	}
+
+	return &execSandbox{
+		kind:    cfg.Type,
+		path:    resolved,
+		network: cfg.Network,
+		args:    cfg.Args,
+	}, nil
+}
+
+// wrap returns the program and arguments that run command under the
+// sandbox in cwd, with the writable directories bound read-write.
+func (s *execSandbox) wrap(command, cwd string, writable []string) (string, []string) {
+	var args []string
+	switch s.kind {
+	case config.ExecSandboxBwrap:
+		args = []string{
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--tmpfs", "/tmp",
+		}
+		for _, dir := range writable {
+			args = append(args, "--bind", dir, dir)
+		}
+		args = append(args, "--unshare-all", "--die-with-parent", "--new-session")
+		if s.network {
+			args = append(args, "--share-net")
+		}
+		if cwd != "" {
+			args = append(args, "--chdir", cwd)
+		}
+		args = append(args, s.args...)
+		args = append(args, "--", "sh", "-c", command)
+
+	case config.ExecSandboxNsjail:
+		args = []string{
+			"--mode", "o",
+			"--quiet",
+			"--chroot", "/",
+			"--keep_env",
+			"--disable_rlimits",
+			"--time_limit", "0",
+			"--tmpfsmount", "/tmp",
+		}
+		for _, dir := range writable {
+			args = append(args, "--bindmount", dir)
+		}
+		if s.network {
+			args = append(args, "--disable_clone_newnet")
+		}
+		if cwd != "" {
+			args = append(args, "--cwd", cwd)
+		}
+		args = append(args, s.args...)
+		args = append(args, "--", "/bin/sh", "-c", command)
+	}
+	return s.path, args
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestExecSandboxWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox execSandbox
+		want    []string
+		absent  []string
+	}{
+		{
+			name:    "bwrap",
+			sandbox: execSandbox{kind: config.ExecSandboxBwrap, path: "/usr/bin/bwrap", args: []string{"--clearenv"}},
+			want: []string{
+				"--ro-bind / /", "--bind /ws /ws", "--unshare-all", "--chdir /ws/src", "--clearenv",
+				"-- sh -c make test",
+			},
+			absent: []string{"--share-net"},
+		},
+		{
+			name:    "bwrap with network",
+			sandbox: execSandbox{kind: config.ExecSandboxBwrap, path: "/usr/bin/bwrap", network: true},
+			want:    []string{"--unshare-all", "--share-net"},
+		},
+		{
+			name:    "nsjail",
+			sandbox: execSandbox{kind: config.ExecSandboxNsjail, path: "/usr/bin/nsjail"},
+			want: []string{
+				"--mode o", "--chroot /", "--bindmount /ws", "--cwd /ws/src",
+				"-- /bin/sh -c make test",
+			},
+			absent: []string{"--disable_clone_newnet"},
+		},
+		{
+			name:    "nsjail with network",
+			sandbox: execSandbox{kind: config.ExecSandboxNsjail, path: "/usr/bin/nsjail", network: true},
+			want:    []string{"--disable_clone_newnet"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := tt.sandbox.wrap("make test", "/ws/src", []string{"/ws"})
+			if name != tt.sandbox.path {
+				t.Errorf("program = %q, want %q", name, tt.sandbox.path)
+			}
+			// The command itself must be the last argument, after any extra flags
+			if args[len(args)-1] != "make test" {
+				t.Errorf("last arg = %q", args[len(args)-1])
+			}
+			joined := strings.Join(args, " ")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("args %q missing %q", joined, want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if slices.Contains(args, absent) {
+					t.Errorf("args %q contain %q", joined, absent)
+				}
+			}
+		})
+	}
+}
+
+func TestNewExecSandbox(t *testing.T) {
+	if s, err := newExecSandbox(config.ExecSandboxConfig{Type: config.ExecSandboxNone}); s != nil || err != nil {
+		t.Errorf("none: got %v, %v", s, err)
+	}
+	if _, err := newExecSandbox(config.ExecSandboxConfig{Type: "docker"}); err == nil {
+		t.Error("unknown sandbox type accepted")
+	}
+	_, err := newExecSandbox(config.ExecSandboxConfig{Type: config.ExecSandboxNsjail, Path: "/nonexistent/nsjail"})
+	if err == nil {
+		t.Error("missing sandbox binary accepted")
+	}
+}
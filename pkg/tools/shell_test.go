@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // TestShellTool_Success verifies successful command execution
@@ -272,3 +274,115 @@ func TestShellTool_RestrictToWorkspace(t *testing.T) {
 		)
 	}
 }
+
+// TestShellTool_AllowedPaths verifies that commands may use writable
+// allowed paths outside the workspace, and read-only ones only when the
+// sandbox can keep them read-only
+func TestShellTool_AllowedPaths(t *testing.T) {
+	workspace := t.TempDir()
+	shared := t.TempDir()
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	os.WriteFile(hosts, []byte("127.0.0.1 localhost\n"), 0o644)
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.AllowedPaths = []config.AllowedPath{{Path: hosts}, {Path: shared, Write: true}}
+	tool := NewExecToolWithConfig(workspace, true, cfg)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"command": "echo hi > " + filepath.Join(shared, "out.txt")})
+	if result.IsError {
+		t.Errorf("writable allowed path blocked: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"command": "cat " + hosts})
+	if !result.IsError || !strings.Contains(result.ForLLM, "path outside working dir") {
+		t.Errorf("read-only allowed path used without a sandbox: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"command": "ls", "working_dir": shared})
+	if result.IsError {
+		t.Errorf("working_dir in a writable allowed path blocked: %s", result.ForLLM)
+	}
+	if dirs := tool.writableDirs(workspace); len(dirs) != 2 || dirs[1] != shared {
+		t.Errorf("writableDirs() = %v, want the workspace and %s", dirs, shared)
+	}
+}
+
+// TestShellTool_ConfigLimits verifies the configured timeout and output cap
+func TestShellTool_ConfigLimits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.TimeoutSeconds = 7
+	cfg.Tools.Exec.MaxOutputChars = 100
+	tool := NewExecToolWithConfig(t.TempDir(), false, cfg)
+
+	if tool.timeout != 7*time.Second {
+		t.Errorf("timeout = %v, want 7s", tool.timeout)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "printf '%0500d' 0",
+	})
+	if result.IsError {
+		t.Fatalf("Expected success, got: %s", result.ForLLM)
+	}
+	if !strings.HasPrefix(result.ForLLM, strings.Repeat("0", 100)+"\n") {
+		t.Errorf("Expected the first 100 chars, got: %q", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "truncated, 400 more chars") {
+		t.Errorf("Expected truncation note, got: %q", result.ForLLM)
+	}
+}
+
+// TestShellTool_ConfigAllowPatterns verifies only allowlisted commands run
+func TestShellTool_ConfigAllowPatterns(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.AllowPatterns = config.FlexibleStringSlice{`^echo\b`, `^git\s+status\b`}
+	tool := NewExecToolWithConfig(t.TempDir(), false, cfg)
+
+	result := tool.Execute(context.Background(), map[string]any{"command": "echo allowed"})
+	if result.IsError || !strings.Contains(result.ForLLM, "allowed") {
+		t.Errorf("Expected echo to run, got: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"command": "ls"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not in allowlist") {
+		t.Errorf("Expected ls to be blocked, got: %s", result.ForLLM)
+	}
+
+	// Chained commands don't ride on an allowed first one
+	for _, command := range []string{
+		"echo hi; cat /etc/passwd",
+		"echo hi && id",
+		"echo hi | wc -c",
+		"echo $HOME",
+		"echo hi > out.txt",
+		"echo hi\nid",
+	} {
+		result = tool.Execute(context.Background(), map[string]any{"command": command})
+		if !result.IsError || !strings.Contains(result.ForLLM, "shell operators") {
+			t.Errorf("Expected %q to be blocked, got: %s", command, result.ForLLM)
+		}
+	}
+
+	// An allowlist of only invalid patterns allows nothing
+	cfg.Tools.Exec.AllowPatterns = config.FlexibleStringSlice{`(`}
+	tool = NewExecToolWithConfig(t.TempDir(), false, cfg)
+	result = tool.Execute(context.Background(), map[string]any{"command": "echo hi"})
+	if !result.IsError {
+		t.Errorf("Expected command to be blocked, got: %s", result.ForLLM)
+	}
+}
+
+// TestShellTool_SandboxUnavailable verifies commands are refused, not run
+// unconfined, when the configured sandbox is missing
+func TestShellTool_SandboxUnavailable(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox = config.ExecSandboxConfig{
+		Type: config.ExecSandboxBwrap,
+		Path: filepath.Join(t.TempDir(), "no-bwrap"),
+	}
+	tool := NewExecToolWithConfig(t.TempDir(), false, cfg)
+
+	result := tool.Execute(context.Background(), map[string]any{"command": "echo hi"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "sandbox") {
+		t.Errorf("Expected sandbox error, got: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// ListSkillsTool lets the LLM agent see what its installed skills do, so it
+// can load only the relevant ones.
+type ListSkillsTool struct {
+	loader *skills.SkillsLoader
+}
+
+// NewListSkillsTool creates a new ListSkillsTool over the agent's skills.
+func NewListSkillsTool(loader *skills.SkillsLoader) *ListSkillsTool {
+	return &ListSkillsTool{loader: loader}
+}
+
+func (t *ListSkillsTool) Name() string {
+	return "list_skills"
+}
+
+func (t *ListSkillsTool) Risk() RiskLevel {
+	return RiskLow
+}
+
+func (t *ListSkillsTool) Description() string {
+	return "List installed skills with their descriptions, optionally only those matching a query. " +
+		"Use load_skill to read the instructions of a skill before using it."
+}
+
+func (t *ListSkillsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Words to look for in skill names and descriptions (optional, lists all skills if empty)",
+			},
+		},
+	}
+}
+
+func (t *ListSkillsTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	found := t.loader.SearchSkills(query)
+	if len(found) == 0 {
+		if strings.TrimSpace(query) == "" {
+			return SilentResult("No skills are installed. Use find_skills to search registries for one.")
+		}
+		return SilentResult(fmt.Sprintf("No installed skills match %q. Call list_skills without a query to see all.",
+			query))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d skills:\n\n", len(found))
+	for _, s := range found {
+		fmt.Fprintf(&sb, "- **%s** (%s): %s\n", s.Name, s.Source, s.Description)
+	}
+	sb.WriteString("\nUse load_skill with a name to read its instructions.")
+	return SilentResult(sb.String())
+}
+
+// LoadSkillTool pulls the instructions of an installed skill into the
+// conversation when the agent needs them.
+type LoadSkillTool struct {
+	loader *skills.SkillsLoader
+}
+
+// NewLoadSkillTool creates a new LoadSkillTool over the agent's skills.
+func NewLoadSkillTool(loader *skills.SkillsLoader) *LoadSkillTool {
+	return &LoadSkillTool{loader: loader}
+}
+
+func (t *LoadSkillTool) Name() string {
+	return "load_skill"
+}
+
+func (t *LoadSkillTool) Risk() RiskLevel {
+	return RiskLow
+}
+
+func (t *LoadSkillTool) Description() string {
+	return "Load the full instructions of an installed skill. Follow them to carry out the task the skill is for."
+}
+
+func (t *LoadSkillTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Skill name, as shown by list_skills",
+			},
+		},
+		"required": []string{"name"},
+	}
+}
+
+func (t *LoadSkillTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrorResult("name is required")
+	}
+
+	info, ok := t.loader.FindSkill(name)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("skill %q not found, use list_skills to see installed skills", name))
+	}
+	content, err := t.loader.ReadSkill(info)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read skill %q: %v", name, err))
+	}
+
+	// Skills may refer to scripts and references next to their SKILL.md
+	return SilentResult(fmt.Sprintf("# Skill: %s\n\nSkill directory: %s\n\n%s",
+		info.Name, filepath.Dir(info.Path), strings.TrimSpace(content)))
+}
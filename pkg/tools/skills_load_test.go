@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+func TestListAndLoadSkillTools(t *testing.T) {
+	workspace := t.TempDir()
+	skillDir := filepath.Join(workspace, "skills", "weather")
+	os.MkdirAll(skillDir, 0o755)
+	os.WriteFile(filepath.Join(skillDir, "SKILL.md"),
+		[]byte("---\nname: weather\ndescription: Get weather forecasts\n---\n\nRun scripts/forecast.sh.\n"), 0o644)
+	loader := skills.NewSkillsLoader(workspace, "", "")
+	ctx := context.Background()
+
+	list := NewListSkillsTool(loader)
+	result := list.Execute(ctx, map[string]any{"query": "forecast"})
+	if result.IsError || !strings.Contains(result.ForLLM, "**weather** (workspace): Get weather forecasts") {
+		t.Errorf("list_skills = %q", result.ForLLM)
+	}
+	if result := list.Execute(ctx, map[string]any{"query": "calendar"}); !strings.Contains(result.ForLLM, "No installed") {
+		t.Errorf("list_skills without a match = %q", result.ForLLM)
+	}
+
+	load := NewLoadSkillTool(loader)
+	result = load.Execute(ctx, map[string]any{"name": "weather"})
+	if result.IsError {
+		t.Fatalf("load_skill error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "Run scripts/forecast.sh.") || strings.Contains(result.ForLLM, "description:") {
+		t.Errorf("load_skill = %q", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "Skill directory: "+skillDir) {
+		t.Errorf("skill directory missing from %q", result.ForLLM)
+	}
+	if result := load.Execute(ctx, map[string]any{"name": "calendar"}); !result.IsError {
+		t.Error("expected an error for an unknown skill")
+	}
+}
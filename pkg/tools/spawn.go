@@ -30,6 +30,10 @@ func (t *SpawnTool) Name() string {
 	return "spawn"
 }
 
+func (t *SpawnTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
 func (t *SpawnTool) Description() string {
 	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done."
 }
@@ -85,7 +89,8 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]any) *ToolResul
 	}
 
 	// Pass callback to manager for async completion notification
-	result, err := t.manager.Spawn(ctx, task, label, agentID, t.originChannel, t.originChatID, t.callback)
+	channel, chatID := ChatFrom(ctx, t.originChannel, t.originChatID)
+	result, err := t.manager.Spawn(ctx, task, label, agentID, channel, chatID, t.callback)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to spawn subagent: %v", err))
 	}
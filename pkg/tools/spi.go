@@ -20,6 +20,10 @@ func (t *SPITool) Name() string {
 	return "spi"
 }
 
+func (t *SPITool) Risk() RiskLevel {
+	return RiskHigh
+}
+
 func (t *SPITool) Description() string {
 	return "Interact with SPI bus devices for high-speed peripheral communication. Actions: list (find SPI devices), transfer (full-duplex send/receive), read (receive bytes). Linux only."
 }
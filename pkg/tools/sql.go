@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// sqlDrivers maps the driver names in tools.sql to the database/sql ones.
+var sqlDrivers = map[string]string{
+	"postgres": "pgx",
+	"mysql":    "mysql",
+	"sqlite":   "sqlite",
+}
+
+// SQLTool runs read-only queries against the databases in tools.sql and
+// renders the results as markdown tables. Queries must parse as a single
+// SELECT-like statement, and run in a read-only transaction as well.
+type SQLTool struct {
+	databases map[string]config.SQLDatabaseConfig
+	maxRows   int
+	maxChars  int
+	timeout   time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*sql.DB
+}
+
+func NewSQLTool(cfg config.SQLToolsConfig) *SQLTool {
+	t := &SQLTool{
+		databases: cfg.Databases,
+		maxRows:   100,
+		maxChars:  10000,
+		timeout:   30 * time.Second,
+		conns:     make(map[string]*sql.DB),
+	}
+	if cfg.MaxRows > 0 {
+		t.maxRows = cfg.MaxRows
+	}
+	if cfg.MaxChars > 0 {
+		t.maxChars = cfg.MaxChars
+	}
+	if cfg.TimeoutSeconds > 0 {
+		t.timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return t
+}
+
+func (t *SQLTool) Name() string {
+	return "sql"
+}
+
+func (t *SQLTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *SQLTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Run a read-only SQL query (SELECT, WITH, EXPLAIN, SHOW or DESCRIBE) against one of the user's " +
+		"databases and get the rows back as a table. Writes are refused. To find tables, query " +
+		"information_schema.tables (postgres, mysql) or sqlite_master (sqlite). Databases:")
+	for _, name := range t.databaseNames() {
+		db := t.databases[name]
+		fmt.Fprintf(&sb, "\n- %s (%s)", name, db.Driver)
+		if db.Description != "" {
+			sb.WriteString(": " + db.Description)
+		}
+	}
+	return sb.String()
+}
+
+func (t *SQLTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"database": map[string]any{
+				"type":        "string",
+				"enum":        t.databaseNames(),
+				"description": "Name of the database to query",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("A single read-only SQL statement. At most %d rows are returned", t.maxRows),
+			},
+		},
+		"required": []string{"database", "query"},
+	}
+}
+
+func (t *SQLTool) databaseNames() []string {
+	names := make([]string, 0, len(t.databases))
+	for name := range t.databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *SQLTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	name, _ := args["database"].(string)
+	query, _ := args["query"].(string)
+	cfg, ok := t.databases[name]
+	if !ok {
+		return ErrorResult(fmt.Sprintf("unknown database %q; configured databases: %s", name,
+			strings.Join(t.databaseNames(), ", ")))
+	}
+	if err := checkReadOnlySQL(cfg.Driver, query); err != nil {
+		return ErrorResult(fmt.Sprintf("Query refused: %v", err))
+	}
+
+	db, err := t.open(name, cfg)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("opening %s: %v", name, err)).WithError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	table, err := t.query(ctx, db, query)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrorResult(fmt.Sprintf("Query on %s timed out after %v", name, t.timeout)).WithError(err)
+		}
+		return ErrorResult(fmt.Sprintf("Query on %s failed: %v", name, err)).WithError(err)
+	}
+	return NewToolResult(table)
+}
+
+// open returns the connection pool for a database, opening it on first use.
+func (t *SQLTool) open(name string, cfg config.SQLDatabaseConfig) (*sql.DB, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if db, ok := t.conns[name]; ok {
+		return db, nil
+	}
+
+	dsn := cfg.DataSource()
+	if cfg.Driver == "sqlite" {
+		// SQLite ignores read-only transactions, so make the connection refuse writes
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=query_only(1)"
+	}
+	db, err := sql.Open(sqlDrivers[cfg.Driver], dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(2)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	t.conns[name] = db
+	return db, nil
+}
+
+func (t *SQLTool) query(ctx context.Context, db *sql.DB, query string) (string, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	var records [][]string
+	more := false
+	for rows.Next() {
+		if len(records) == t.maxRows {
+			more = true
+			break
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = sqlCell(v)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return t.renderTable(columns, records, more), nil
+}
+
+func (t *SQLTool) renderTable(columns []string, records [][]string, more bool) string {
+	if len(columns) == 0 {
+		return "Query returned no columns."
+	}
+	table, shown := markdownTable(columns, records, t.maxChars)
+	switch {
+	case len(records) == 0:
+		return table + "\n(no rows)"
+	case more || shown < len(records):
+		return table + fmt.Sprintf("\n(showing the first %d rows; there are more. Narrow the query or add a LIMIT)", shown)
+	case shown == 1:
+		return table + "\n(1 row)"
+	default:
+		return table + fmt.Sprintf("\n(%d rows)", shown)
+	}
+}
+
+// sqlCell formats a value for a table cell on a single line.
+func sqlCell(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		s = v
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("(%d bytes)", len(v))
+		}
+		s = string(v)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			s = v.Format("2006-01-02")
+		} else {
+			s = v.Format("2006-01-02 15:04:05 MST")
+		}
+	default:
+		s = fmt.Sprint(v)
+	}
+	return tableCell(s)
+}
@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// readOnlyStatements are the statements the sql tool runs.
+var readOnlyStatements = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"EXPLAIN":  true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+}
+
+// writeKeywords can't appear anywhere in a query. Besides the statements
+// themselves this catches data-modifying CTEs, SELECT INTO, locking reads
+// and anything hidden behind EXPLAIN. When one is followed by "(" it is a
+// function call (REPLACE, MySQL's INSERT) and is let through.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "REPLACE": true, "UPSERT": true,
+	"INTO": true, "OUTFILE": true, "DUMPFILE": true, "CREATE": true, "ALTER": true, "DROP": true,
+	"TRUNCATE": true, "RENAME": true, "GRANT": true, "REVOKE": true, "ATTACH": true, "DETACH": true,
+	"PRAGMA": true, "VACUUM": true, "REINDEX": true, "REFRESH": true, "COPY": true, "CALL": true,
+	"EXEC": true, "EXECUTE": true, "DO": true, "LOAD": true, "LOCK": true,
+}
+
+// sideEffectFunctions read or write files, reach other servers or act on
+// other sessions, so they're refused even in a SELECT.
+var sideEffectFunctions = map[string]bool{
+	"PG_READ_FILE": true, "PG_READ_BINARY_FILE": true, "PG_LS_DIR": true, "PG_STAT_FILE": true,
+	"LO_IMPORT": true, "LO_EXPORT": true, "LO_UNLINK": true, "DBLINK": true, "DBLINK_EXEC": true,
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true, "SET_CONFIG": true,
+	"PG_ADVISORY_LOCK": true, "PG_SLEEP": true, "LOAD_FILE": true, "SLEEP": true, "BENCHMARK": true,
+	"GET_LOCK": true, "LOAD_EXTENSION": true, "WRITEFILE": true, "READFILE": true,
+}
+
+// sqlToken is a word or a symbol from a query. Literals, quoted names and
+// comments are skipped.
+type sqlToken struct {
+	text string // upper-cased words
+	word bool
+}
+
+// checkReadOnlySQL makes sure query is a single read-only statement for the
+// given driver (postgres, mysql or sqlite), and says why it isn't otherwise.
+func checkReadOnlySQL(driver, query string) error {
+	tokens, err := scanSQL(driver, query)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+	if !tokens[0].word || !readOnlyStatements[tokens[0].text] {
+		return fmt.Errorf("only SELECT, WITH, VALUES, EXPLAIN, SHOW and DESCRIBE queries can be run")
+	}
+	for i, tok := range tokens {
+		if tok.text == ";" {
+			for _, rest := range tokens[i+1:] {
+				if rest.text != ";" {
+					return fmt.Errorf("only one statement can be run at a time")
+				}
+			}
+			break
+		}
+		if !tok.word || (i > 0 && tokens[i-1].text == ".") {
+			continue
+		}
+		call := i+1 < len(tokens) && tokens[i+1].text == "("
+		if writeKeywords[tok.text] && !call {
+			return fmt.Errorf("%s is not allowed in a read-only query", tok.text)
+		}
+		if sideEffectFunctions[tok.text] && call {
+			return fmt.Errorf("%s() is not allowed in a read-only query", strings.ToLower(tok.text))
+		}
+	}
+	return nil
+}
+
+// scanSQL splits a query into words and symbols the way the driver's
+// database would, so nothing can hide in what looks like a string or a
+// comment.
+func scanSQL(driver, query string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case lineComment(driver, query[i:]):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			if driver == "mysql" && (strings.HasPrefix(query[i:], "/*!") || strings.HasPrefix(query[i:], "/*+")) {
+				return nil, fmt.Errorf("MySQL executable comments are not allowed")
+			}
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			// Postgres nests comments; refuse them rather than guess
+			if strings.Contains(query[i+2:i+2+end], "/*") {
+				return nil, fmt.Errorf("nested comments are not allowed")
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			backslash := driver == "mysql" && c != '`'
+			if driver == "postgres" && c == '\'' && escapePrefixed(query, i) {
+				backslash = true
+			}
+			end, ok := quotedEnd(query, i, backslash)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			i = end
+		case c == '[' && driver == "sqlite":
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted name")
+			}
+			i += end + 1
+		case c == '$' && driver == "postgres" && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string")
+			}
+			i += len(tag) + end + len(tag)
+		case isSQLWordStart(c):
+			start := i
+			for i < len(query) && (isSQLWordStart(query[i]) || isSQLDigit(query[i]) || query[i] == '$') {
+				i++
+			}
+			word := strings.ToUpper(query[start:i])
+			// An E'...' prefix belongs to the string that follows
+			if driver == "postgres" && escapePrefixed(query, i) {
+				continue
+			}
+			tokens = append(tokens, sqlToken{text: word, word: true})
+		case isSQLDigit(c):
+			for i < len(query) && (isSQLDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+		default:
+			tokens = append(tokens, sqlToken{text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// lineComment reports whether s starts with a comment that runs to the end
+// of the line. MySQL needs a space after "--" and also takes "#".
+func lineComment(driver, s string) bool {
+	if driver != "mysql" {
+		return strings.HasPrefix(s, "--")
+	}
+	if strings.HasPrefix(s, "#") {
+		return true
+	}
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] <= ' ')
+}
+
+// escapePrefixed reports whether the quote at query[i] opens a Postgres
+// E'...' string, in which backslashes escape.
+func escapePrefixed(query string, i int) bool {
+	if i >= len(query) || query[i] != '\'' {
+		return false
+	}
+	if i < 1 || (query[i-1] != 'E' && query[i-1] != 'e') {
+		return false
+	}
+	return i < 2 || !(isSQLWordStart(query[i-2]) || isSQLDigit(query[i-2]) || query[i-2] == '$')
+}
+
+// quotedEnd returns the index just past the quoted text starting at
+// query[start]. A doubled quote is an escaped one, and so is a backslashed
+// one when backslash is set.
+func quotedEnd(query string, start int, backslash bool) (int, bool) {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case backslash && query[i] == '\\':
+			i++
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// dollarTag returns the $tag$ opening a Postgres dollar-quoted string at
+// the start of s, or "".
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case isSQLWordStart(s[i]), i > 1 && isSQLDigit(s[i]):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isSQLWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isSQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
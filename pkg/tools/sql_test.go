@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	allowed := []struct{ driver, query string }{
+		{"postgres", "SELECT * FROM orders WHERE status = 'shipped'"},
+		{"postgres", "with t as (select 1) select * from t;"},
+		{"postgres", "SELECT replace(name, 'a', 'b'), o.update FROM orders o"},
+		{"postgres", "SELECT 'DELETE FROM orders; DROP TABLE x' AS note -- INSERT"},
+		{"postgres", "SELECT $$ INSERT INTO t $$, $tag$ ; $tag$, $1"},
+		{"postgres", `SELECT "into", E'it\'s; update' FROM t`},
+		{"postgres", "EXPLAIN ANALYZE SELECT count(*) FROM orders"},
+		{"mysql", "SHOW TABLES"},
+		{"mysql", "SELECT 'it\\'s; DROP TABLE t' # comment"},
+		{"mysql", "SELECT `select`, INSERT(name, 1, 2, 'x') FROM t"},
+		{"sqlite", "SELECT [order] FROM t /* UPDATE */"},
+	}
+	for _, tt := range allowed {
+		if err := checkReadOnlySQL(tt.driver, tt.query); err != nil {
+			t.Errorf("%s %q refused: %v", tt.driver, tt.query, err)
+		}
+	}
+
+	refused := []struct{ driver, query, want string }{
+		{"postgres", "", "empty"},
+		{"postgres", "DELETE FROM orders", "only SELECT"},
+		{"postgres", "SELECT 1; DROP TABLE orders", "one statement"},
+		{"postgres", "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", "DELETE"},
+		{"postgres", "SELECT * INTO backup FROM orders", "INTO"},
+		{"postgres", "SELECT * FROM orders FOR UPDATE", "UPDATE"},
+		{"postgres", "EXPLAIN ANALYZE CREATE TABLE x AS SELECT 1", "CREATE"},
+		{"postgres", "SELECT pg_read_file('/etc/passwd')", "pg_read_file()"},
+		{"postgres", "SELECT 'it\\'; DELETE FROM t; --'", "one statement"},
+		{"postgres", "SELECT $a$ unterminated", "unterminated"},
+		{"postgres", "SELECT /* /* */ DELETE FROM t */ 1", "nested"},
+		{"mysql", "SELECT 1--1; DELETE FROM t", "one statement"},
+		{"mysql", "SELECT 1 /*!; DROP TABLE t */", "executable comments"},
+		{"mysql", "SELECT * FROM t INTO OUTFILE '/tmp/x'", "INTO"},
+		{"sqlite", "SELECT [a'] ; DELETE FROM t; --']", "one statement"},
+		{"sqlite", "ATTACH DATABASE 'x.db' AS x", "only SELECT"},
+	}
+	for _, tt := range refused {
+		err := checkReadOnlySQL(tt.driver, tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q = %v, want an error with %q", tt.driver, tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestSQLTool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shop.db")
+	setup, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setup.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL, note TEXT);
+		INSERT INTO orders VALUES (1, 'Ada', 12.5, NULL), (2, 'Grace', 40, 'gift | wrap
+please'), (3, 'Linus', 7.25, NULL)`)
+	setup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewSQLTool(config.SQLToolsConfig{
+		MaxRows: 2,
+		Databases: map[string]config.SQLDatabaseConfig{
+			"shop": {Driver: "sqlite", DSN: path, Description: "orders"},
+		},
+	})
+	if !strings.Contains(tool.Description(), "- shop (sqlite): orders") {
+		t.Errorf("description = %q", tool.Description())
+	}
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"database": "shop",
+		"query":    "SELECT id, customer, total, note FROM orders WHERE id > 1 ORDER BY id",
+	})
+	want := "| id | customer | total | note |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| 2 | Grace | 40 | gift \\| wrap please |\n" +
+		"| 3 | Linus | 7.25 | NULL |\n" +
+		"\n(2 rows)"
+	if result.IsError || result.ForLLM != want {
+		t.Errorf("result = %q, want %q", result.ForLLM, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"database": "shop", "query": "SELECT id FROM orders"})
+	if !strings.Contains(result.ForLLM, "showing the first 2 rows") {
+		t.Errorf("row limit = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"database": "shop", "query": "SELECT * FROM orders WHERE id = 99"})
+	if !strings.HasSuffix(result.ForLLM, "(no rows)") {
+		t.Errorf("no rows = %q", result.ForLLM)
+	}
+
+	tool.maxChars = 60
+	result = tool.Execute(ctx, map[string]any{"database": "shop", "query": "SELECT customer, note FROM orders"})
+	if !strings.Contains(result.ForLLM, "showing the first 1 rows") || len(result.ForLLM) > 160 {
+		t.Errorf("size limit = %q", result.ForLLM)
+	}
+
+	failures := []struct{ database, query, want string }{
+		{"shop", "DELETE FROM orders", "Query refused"},
+		{"shop", "SELECT * FROM missing", "no such table"},
+		{"crm", "SELECT 1", "unknown database"},
+	}
+	for _, tt := range failures {
+		result := tool.Execute(ctx, map[string]any{"database": tt.database, "query": tt.query})
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%s on %s = %q, want an error with %q", tt.query, tt.database, result.ForLLM, tt.want)
+		}
+	}
+
+	// The connection itself refuses writes, whatever the parser lets through
+	db, _ := tool.open("shop", tool.databases["shop"])
+	if _, err := db.Exec("DELETE FROM orders"); err == nil {
+		t.Error("write through the tool's connection succeeded")
+	}
+}
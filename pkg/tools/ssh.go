@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// sshHost is a configured host with its compiled command policy.
+type sshHost struct {
+	cfg   config.SSHHostConfig
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// SSHTool runs commands on the remote hosts in tools.ssh, authenticating
+// with each host's private key. Hosts that aren't configured can't be
+// reached, and each host's allow and deny patterns limit what runs there.
+type SSHTool struct {
+	hosts      map[string]*sshHost
+	knownHosts string
+	timeout    time.Duration
+	maxOutput  int
+}
+
+func NewSSHTool(cfg config.SSHToolsConfig) *SSHTool {
+	t := &SSHTool{
+		hosts:      make(map[string]*sshHost, len(cfg.Hosts)),
+		knownHosts: cfg.KnownHostsPath(),
+		timeout:    defaultExecTimeout,
+		maxOutput:  defaultExecMaxOutput,
+	}
+	if cfg.TimeoutSeconds > 0 {
+		t.timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if cfg.MaxOutputChars > 0 {
+		t.maxOutput = cfg.MaxOutputChars
+	}
+	for name, hostCfg := range cfg.Hosts {
+		host := &sshHost{cfg: hostCfg}
+		// Patterns were checked when the config was loaded
+		for _, pattern := range hostCfg.AllowPatterns {
+			host.allow = append(host.allow, regexp.MustCompile(pattern))
+		}
+		for _, pattern := range hostCfg.DenyPatterns {
+			host.deny = append(host.deny, regexp.MustCompile(pattern))
+		}
+		host.deny = append(host.deny, defaultDenyPatterns...)
+		t.hosts[name] = host
+	}
+	return t
+}
+
+func (t *SSHTool) Name() string {
+	return "ssh"
+}
+
+func (t *SSHTool) Risk() RiskLevel {
+	return RiskHigh
+}
+
+func (t *SSHTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Run a shell command on one of the user's remote servers over SSH, e.g. to check disk space, " +
+		"look at logs or restart a service. Only these hosts can be used:")
+	for _, name := range t.hostNames() {
+		sb.WriteString("\n- " + name)
+		if desc := t.hosts[name].cfg.Description; desc != "" {
+			sb.WriteString(": " + desc)
+		}
+	}
+	return sb.String()
+}
+
+func (t *SSHTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"host": map[string]any{
+				"type":        "string",
+				"enum":        t.hostNames(),
+				"description": "Name of the host to run the command on",
+			},
+			"command": map[string]any{
+				"type":        "string",
+				"description": "Shell command to run on the host",
+			},
+		},
+		"required": []string{"host", "command"},
+	}
+}
+
+func (t *SSHTool) hostNames() []string {
+	names := make([]string, 0, len(t.hosts))
+	for name := range t.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *SSHTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	name, host, command, errResult := t.prepare(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	client, err := t.dial(ctx, host.cfg)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("connecting to %s: %v", name, err)).WithError(err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("opening session on %s: %v", name, err)).WithError(err)
+	}
+	defer session.Close()
+
+	stdout := &cappedBuffer{limit: t.maxOutput}
+	stderr := &cappedBuffer{limit: t.maxOutput}
+	session.Stdout = progressOutput(ctx, stdout)
+	session.Stderr = progressOutput(ctx, stderr)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Closing the connection ends the remote command's session
+		client.Close()
+		msg := fmt.Sprintf("Command on %s timed out after %v", name, t.timeout)
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			msg = fmt.Sprintf("Command on %s was cancelled", name)
+		}
+		return &ToolResult{ForLLM: msg, ForUser: msg, IsError: true}
+	}
+
+	output := stdout.String()
+	if stderr.Len() > 0 {
+		output += "\nSTDERR:\n" + stderr.String()
+	}
+	dropped := stdout.dropped + stderr.dropped
+
+	var exitErr *ssh.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		output += fmt.Sprintf("\nExit code: %d", exitErr.ExitStatus())
+	case err != nil:
+		output += fmt.Sprintf("\nError: %v", err)
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	if len(output) > t.maxOutput || dropped > 0 {
+		if len(output) > t.maxOutput {
+			dropped += len(output) - t.maxOutput
+			output = output[:t.maxOutput]
+		}
+		output += fmt.Sprintf("\n... (truncated, %d more chars)", dropped)
+	}
+	return &ToolResult{ForLLM: output, ForUser: output, IsError: err != nil}
+}
+
+// prepare checks the call and returns the host to run the command on.
+func (t *SSHTool) prepare(args map[string]any) (name string, host *sshHost, command string, errResult *ToolResult) {
+	name, _ = args["host"].(string)
+	command, _ = args["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return "", nil, "", ErrorResult("command is required")
+	}
+	host, ok := t.hosts[name]
+	if !ok {
+		return "", nil, "", ErrorResult(fmt.Sprintf("unknown host %q; configured hosts: %s", name,
+			strings.Join(t.hostNames(), ", ")))
+	}
+	if guardError := host.guard(command); guardError != "" {
+		return "", nil, "", ErrorResult(guardError)
+	}
+	return name, host, command, nil
+}
+
+// guard checks a command against the host's policy, returning why it is
+// refused or "".
+func (h *sshHost) guard(command string) string {
+	lower := strings.ToLower(strings.TrimSpace(command))
+	for _, pattern := range h.deny {
+		if pattern.MatchString(lower) {
+			return "Command blocked by safety guard (dangerous pattern detected)"
+		}
+	}
+	if len(h.allow) == 0 {
+		return ""
+	}
+	if hasShellMetachars(lower) {
+		return "Command blocked by safety guard (shell operators aren't allowed with an allowlist)"
+	}
+	for _, pattern := range h.allow {
+		if pattern.MatchString(lower) {
+			return ""
+		}
+	}
+	return "Command blocked by safety guard (not in this host's allowlist)"
+}
+
+func (t *SSHTool) dial(ctx context.Context, cfg config.SSHHostConfig) (*ssh.Client, error) {
+	key, err := os.ReadFile(cfg.KeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+	var signer ssh.Signer
+	if cfg.KeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.KeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing key: %w", err)
+	}
+
+	hostKeyCallback, err := t.hostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := cfg.Address
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	clientCfg := &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Bound the handshake; the command itself is bounded by ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientCfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// hostKeyCallback verifies the server against the host's pinned key, or
+// else the known_hosts file. Unknown servers are refused.
+func (t *SSHTool) hostKeyCallback(cfg config.SSHHostConfig) (ssh.HostKeyCallback, error) {
+	if cfg.HostKey != "" {
+		pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("parsing host_key: %w", err)
+		}
+		return ssh.FixedHostKey(pinned), nil
+	}
+	callback, err := knownhosts.New(t.knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts (add the host with ssh-keyscan, or set host_key): %w", err)
+	}
+	return callback, nil
+}
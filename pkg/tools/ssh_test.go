@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// startSSHServer runs an SSH server that accepts the client key and answers
+// a few fixed commands. It returns its address and host key line.
+func startSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, string) {
+	t.Helper()
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "deploy" && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	serverCfg.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, serverCfg)
+		}
+	}()
+	return listener.Addr().String(), string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey()))
+}
+
+func serveSSH(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				command := string(req.Payload[4:])
+				status := uint32(0)
+				switch command {
+				case "df -h":
+					channel.Write([]byte("/dev/sda1  40G  12G  28G  30% /\n"))
+				case "systemctl restart app":
+					channel.Stderr().Write([]byte("Failed to restart app.service: Access denied\n"))
+					status = 4
+				}
+				payload := make([]byte, 4)
+				binary.BigEndian.PutUint32(payload, status)
+				channel.SendRequest("exit-status", false, payload)
+				return
+			}
+		}()
+	}
+}
+
+func TestSSHTool(t *testing.T) {
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600)
+	sshPub, _ := ssh.NewPublicKey(clientPub)
+	addr, hostKey := startSSHServer(t, sshPub)
+
+	_, otherHostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(otherHostPriv)
+	tool := NewSSHTool(config.SSHToolsConfig{
+		KnownHosts: filepath.Join(t.TempDir(), "known_hosts"),
+		Hosts: map[string]config.SSHHostConfig{
+			"web1": {
+				Address:     addr,
+				User:        "deploy",
+				KeyFile:     keyFile,
+				HostKey:     hostKey,
+				Description: "app server",
+				AllowPatterns: config.FlexibleStringSlice{
+					`^df( -h)?$`, `^systemctl restart app$`, `^systemctl status \S+$`, `^uptime$`, `^sudo df$`,
+				},
+				DenyPatterns: config.FlexibleStringSlice{`^uptime$`},
+			},
+			"default-policy": {Address: addr, User: "deploy", KeyFile: keyFile, HostKey: hostKey},
+			"unknown-key":    {Address: addr, User: "deploy", KeyFile: keyFile},
+			"wrong-key": {
+				Address: addr, User: "deploy", KeyFile: keyFile,
+				HostKey: string(ssh.MarshalAuthorizedKey(otherSigner.PublicKey())),
+			},
+		},
+	})
+	if !strings.Contains(tool.Description(), "- web1: app server") {
+		t.Errorf("description = %q", tool.Description())
+	}
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"host": "web1", "command": "df -h"})
+	if result.IsError || !strings.Contains(result.ForLLM, "28G  30% /") {
+		t.Errorf("df = %+v", result)
+	}
+	result = tool.Execute(ctx, map[string]any{"host": "web1", "command": "systemctl restart app"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "STDERR:\nFailed to restart") ||
+		!strings.Contains(result.ForLLM, "Exit code: 4") {
+		t.Errorf("failed restart = %+v", result)
+	}
+
+	refused := []struct {
+		host, command, want string
+	}{
+		{"web1", "cat /etc/shadow", "not in this host's allowlist"},
+		{"web1", "uptime", "dangerous pattern"},
+		{"web1", "sudo df", "dangerous pattern"},
+		{"web1", "systemctl status x;id", "shell operators"},
+		{"web1", "df -h && id", "shell operators"},
+		{"default-policy", "sudo reboot", "dangerous pattern"},
+		{"db9", "df -h", "unknown host"},
+		{"unknown-key", "df -h", "known hosts"},
+		{"wrong-key", "df -h", "connecting to wrong-key"},
+	}
+	for _, tt := range refused {
+		result := tool.Execute(ctx, map[string]any{"host": tt.host, "command": tt.command})
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%s on %s = %q, want an error with %q", tt.command, tt.host, result.ForLLM, tt.want)
+		}
+	}
+}
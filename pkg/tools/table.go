@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTableCellChars = 200
+
+// markdownTable renders records as a markdown table under a header row,
+// stopping at a row boundary once the table would pass maxChars. It returns
+// the table and how many records fit. Cells must already be escaped with
+// tableCell.
+func markdownTable(columns []string, records [][]string, maxChars int) (string, int) {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = tableCell(col)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	sb.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+	shown := 0
+	for _, record := range records {
+		line := "| " + strings.Join(record, " | ") + " |\n"
+		if sb.Len()+len(line) > maxChars {
+			break
+		}
+		sb.WriteString(line)
+		shown++
+	}
+	return sb.String(), shown
+}
+
+// tableCell puts a value on a single line for a markdown table cell,
+// escaping pipes and cutting it short if it's long.
+func tableCell(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+	if utf8.RuneCountInString(s) > maxTableCellChars {
+		s = string([]rune(s)[:maxTableCellChars]) + "…"
+	}
+	return s
+}
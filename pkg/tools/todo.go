@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// TodoTool manages the agent's todo list. Its summary action is meant to be
+// scheduled, e.g. every morning with the cron tool, to send the user their
+// open tasks.
+type TodoTool struct {
+	tasks *state.TaskList
+	now   func() time.Time
+}
+
+func NewTodoTool(tasks *state.TaskList) *TodoTool {
+	return &TodoTool{tasks: tasks, now: time.Now}
+}
+
+func (t *TodoTool) Name() string {
+	return "todo"
+}
+
+func (t *TodoTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *TodoTool) Description() string {
+	return "Keep the user's todo list. Actions: 'add' (title, optional priority and due date), " +
+		"'complete' (id), 'list' (open tasks, most urgent first; include_done adds recently finished ones), " +
+		"'prioritize' (id, priority) and 'summary' (a short digest of open, overdue and recently done tasks). " +
+		"For a daily summary, schedule this tool with the cron tool: tool 'todo', tool_args {\"action\": \"summary\"}."
+}
+
+func (t *TodoTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"add", "complete", "list", "prioritize", "summary"},
+				"description": "What to do",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "The task (for add)",
+			},
+			"priority": map[string]any{
+				"type":        "string",
+				"enum":        []string{state.PriorityHigh, state.PriorityNormal, state.PriorityLow},
+				"description": "Task priority (for add, default normal; required for prioritize)",
+			},
+			"due": map[string]any{
+				"type":        "string",
+				"description": "Optional due date for add, e.g. 2026-03-14, \"friday\" or \"tomorrow\"",
+			},
+			"id": map[string]any{
+				"type":        "integer",
+				"description": "Task ID (for complete and prioritize)",
+			},
+			"include_done": map[string]any{
+				"type":        "boolean",
+				"description": "Also list tasks completed in the last 7 days (for list)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *TodoTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "add":
+		return t.add(args)
+	case "complete":
+		id, ok := taskID(args)
+		if !ok {
+			return ErrorResult("id is required for complete")
+		}
+		task, err := t.tasks.Complete(id)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		return NewToolResult(fmt.Sprintf("Completed #%d: %s", task.ID, task.Title))
+	case "prioritize":
+		id, ok := taskID(args)
+		if !ok {
+			return ErrorResult("id is required for prioritize")
+		}
+		priority, _ := args["priority"].(string)
+		task, err := t.tasks.SetPriority(id, priority)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		return NewToolResult(fmt.Sprintf("#%d is now %s priority: %s", task.ID, task.Priority, task.Title))
+	case "list":
+		return t.list(args)
+	case "summary":
+		return NewToolResult(t.summary())
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %q", action))
+	}
+}
+
+func (t *TodoTool) add(args map[string]any) *ToolResult {
+	title, _ := args["title"].(string)
+	priority, _ := args["priority"].(string)
+	due, _ := args["due"].(string)
+	if due = strings.TrimSpace(due); due != "" {
+		when, err := parseWhen(due, t.now())
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("cannot read due date %q; use e.g. 2026-03-14 or \"friday\"", due))
+		}
+		due = when.Format("2006-01-02")
+	}
+	task, err := t.tasks.Add(title, priority, due)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	return NewToolResult(fmt.Sprintf("Added #%d: %s", task.ID, t.describe(task)))
+}
+
+func (t *TodoTool) list(args map[string]any) *ToolResult {
+	open := t.tasks.Open()
+	var done []state.Task
+	if includeDone, _ := args["include_done"].(bool); includeDone {
+		done = t.tasks.CompletedSince(t.now().AddDate(0, 0, -7))
+	}
+	if len(open) == 0 && len(done) == 0 {
+		return NewToolResult("The todo list is empty")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Open tasks (%d):\n", len(open))
+	for _, task := range open {
+		fmt.Fprintf(&sb, "#%d %s\n", task.ID, t.describe(task))
+	}
+	if len(done) > 0 {
+		sb.WriteString("\nDone in the last 7 days:\n")
+		for _, task := range done {
+			fmt.Fprintf(&sb, "#%d %s (done %s)\n", task.ID, task.Title, task.Completed.Format("2006-01-02"))
+		}
+	}
+	return NewToolResult(sb.String())
+}
+
+// summary is the digest sent to the user: open tasks, most urgent first,
+// and what was finished in the last day.
+func (t *TodoTool) summary() string {
+	now := t.now()
+	open := t.tasks.Open()
+	done := t.tasks.CompletedSince(now.Add(-24 * time.Hour))
+
+	var sb strings.Builder
+	if len(open) == 0 {
+		sb.WriteString("📋 No open tasks.")
+	} else {
+		overdue := 0
+		for _, task := range open {
+			if task.Overdue(now) {
+				overdue++
+			}
+		}
+		fmt.Fprintf(&sb, "📋 %d open task", len(open))
+		if len(open) != 1 {
+			sb.WriteString("s")
+		}
+		if overdue > 0 {
+			fmt.Fprintf(&sb, ", %d overdue", overdue)
+		}
+		sb.WriteString(":\n")
+		for _, task := range open {
+			fmt.Fprintf(&sb, "• %s\n", t.describe(task))
+		}
+	}
+	if len(done) > 0 {
+		titles := make([]string, len(done))
+		for i, task := range done {
+			titles[i] = task.Title
+		}
+		fmt.Fprintf(&sb, "\n✅ Done since yesterday: %s", strings.Join(titles, ", "))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// describe shows a task's title with its priority and due date.
+func (t *TodoTool) describe(task state.Task) string {
+	s := task.Title
+	if task.Priority != state.PriorityNormal {
+		s = "[" + task.Priority + "] " + s
+	}
+	today := t.now().Format("2006-01-02")
+	switch {
+	case task.Due == "":
+	case task.Overdue(t.now()):
+		s += " (overdue, due " + task.Due + ")"
+	case task.Due == today:
+		s += " (due today)"
+	default:
+		s += " (due " + task.Due + ")"
+	}
+	return s
+}
+
+func taskID(args map[string]any) (int, bool) {
+	id, ok := args["id"].(float64)
+	return int(id), ok && id > 0
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+func TestTodoTool(t *testing.T) {
+	tasks, err := state.NewTaskList(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := NewTodoTool(tasks)
+	// A Tuesday morning
+	tool.now = func() time.Time { return time.Date(2026, 3, 10, 8, 0, 0, 0, time.Local) }
+	ctx := context.Background()
+
+	for _, args := range []map[string]any{
+		{"action": "add", "title": "Water plants"},
+		{"action": "add", "title": "Pay rent", "priority": "high", "due": "friday"},
+		{"action": "add", "title": "Renew passport", "due": "2026-03-01"},
+		{"action": "add", "title": "Buy milk", "due": "today"},
+	} {
+		if result := tool.Execute(ctx, args); result.IsError {
+			t.Fatalf("add %v: %s", args, result.ForLLM)
+		}
+	}
+	for _, args := range []map[string]any{
+		{"action": "add", "title": "x", "due": "someday"},
+		{"action": "add", "title": "x", "priority": "asap"},
+		{"action": "add"},
+		{"action": "complete"},
+		{"action": "complete", "id": float64(99)},
+		{"action": "prioritize", "id": float64(1)},
+		{"action": "remove", "id": float64(1)},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v succeeded: %s", args, result.ForLLM)
+		}
+	}
+
+	list := tool.Execute(ctx, map[string]any{"action": "list"}).ForLLM
+	want := "Open tasks (4):\n" +
+		"#2 [high] Pay rent (due 2026-03-13)\n" +
+		"#3 Renew passport (overdue, due 2026-03-01)\n" +
+		"#4 Buy milk (due today)\n" +
+		"#1 Water plants\n"
+	if list != want {
+		t.Errorf("list = %q, want %q", list, want)
+	}
+
+	tool.Execute(ctx, map[string]any{"action": "complete", "id": float64(4)})
+	tool.Execute(ctx, map[string]any{"action": "prioritize", "id": float64(1), "priority": "low"})
+
+	list = tool.Execute(ctx, map[string]any{"action": "list", "include_done": true}).ForLLM
+	if !strings.Contains(list, "#1 [low] Water plants") || !strings.Contains(list, "#4 Buy milk (done ") {
+		t.Errorf("list with done = %q", list)
+	}
+
+	summary := tool.Execute(ctx, map[string]any{"action": "summary"}).ForLLM
+	want = "📋 3 open tasks, 1 overdue:\n" +
+		"• [high] Pay rent (due 2026-03-13)\n" +
+		"• Renew passport (overdue, due 2026-03-01)\n" +
+		"• [low] Water plants\n\n" +
+		"✅ Done since yesterday: Buy milk"
+	if summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+}
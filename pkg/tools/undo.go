@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+)
+
+const (
+	// maxUndoBatches is how many turns' worth of file changes are kept per
+	// conversation.
+	maxUndoBatches = 10
+	// maxSnapshotSize is the largest file whose contents are kept for undo.
+	maxSnapshotSize = 4 << 20
+)
+
+// ErrNothingToUndo is returned by Undo when no file changes are recorded.
+var ErrNothingToUndo = errors.New("no file changes to undo")
+
+// FileHistory keeps what files looked like before the file tools changed
+// them, so a turn's changes can be reverted. Changes are grouped in batches,
+// one per turn, and kept per conversation in memory. A nil history records
+// nothing.
+type FileHistory struct {
+	mu      sync.Mutex
+	batches map[string][]*fileBatch
+}
+
+type fileBatch struct {
+	files []fileSnapshot
+}
+
+type fileSnapshot struct {
+	path    string // as the agent named it
+	abs     string
+	existed bool
+	mode    os.FileMode
+	content []byte
+	tooBig  bool
+}
+
+// NewFileHistory creates an empty history.
+func NewFileHistory() *FileHistory {
+	return &FileHistory{batches: make(map[string][]*fileBatch)}
+}
+
+// Begin starts a new batch for the conversation with key. Changes recorded
+// in it until its next Begin belong to it.
+func (h *FileHistory) Begin(key string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	batches := h.batches[key]
+	if n := len(batches); n > 0 && len(batches[n-1].files) == 0 {
+		return
+	}
+	batches = append(batches, &fileBatch{})
+	if len(batches) > maxUndoBatches {
+		batches = batches[len(batches)-maxUndoBatches:]
+	}
+	h.batches[key] = batches
+}
+
+// Record saves the contents of the file at abs before it is first changed
+// in the current batch of the conversation ctx's call belongs to. It does
+// nothing outside a turn.
+func (h *FileHistory) Record(ctx context.Context, path, abs string) {
+	if h == nil {
+		return
+	}
+	key := audit.ActorFrom(ctx).Session
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	batches := h.batches[key]
+	if len(batches) == 0 {
+		return
+	}
+	batch := batches[len(batches)-1]
+	for _, f := range batch.files {
+		if f.abs == abs {
+			return
+		}
+	}
+
+	snap := fileSnapshot{path: path, abs: abs}
+	if info, err := os.Stat(abs); err == nil {
+		snap.existed, snap.mode = true, info.Mode().Perm()
+		if info.Size() > maxSnapshotSize {
+			snap.tooBig = true
+		} else if snap.content, err = os.ReadFile(abs); err != nil {
+			snap.tooBig = true
+		}
+	}
+	batch.files = append(batch.files, snap)
+}
+
+// Undo reverts the latest batch of changes in the conversation with key:
+// files get their old contents back and files the batch created are
+// removed. It returns the reverted paths and the ones it couldn't restore.
+func (h *FileHistory) Undo(key string) (reverted, failed []string, err error) {
+	if h == nil {
+		return nil, nil, ErrNothingToUndo
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	batches := h.batches[key]
+	for len(batches) > 0 && len(batches[len(batches)-1].files) == 0 {
+		batches = batches[:len(batches)-1]
+	}
+	if len(batches) == 0 {
+		h.batches[key] = nil
+		return nil, nil, ErrNothingToUndo
+	}
+	batch := batches[len(batches)-1]
+	h.batches[key] = batches[:len(batches)-1]
+
+	for i := len(batch.files) - 1; i >= 0; i-- {
+		f := batch.files[i]
+		var err error
+		switch {
+		case f.tooBig:
+			err = errors.New("too large to keep a copy")
+		case !f.existed:
+			if err = os.Remove(f.abs); os.IsNotExist(err) {
+				err = nil
+			}
+		default:
+			err = os.WriteFile(f.abs, f.content, f.mode)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", f.path, err))
+		} else {
+			reverted = append(reverted, f.path)
+		}
+	}
+	return reverted, failed, nil
+}
+
+// SetHistory makes the tool record files in h before changing them.
+func (t *WriteFileTool) SetHistory(h *FileHistory) { t.history = h }
+
+// SetHistory records edited files in h so /undo can revert them.
+func (t *EditFileTool) SetHistory(h *FileHistory) { t.history = h }
+
+// SetHistory records files in h before appending to them.
+func (t *AppendFileTool) SetHistory(h *FileHistory) { t.history = h }
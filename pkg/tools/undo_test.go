@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+)
+
+func TestFileHistory_Undo(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	os.WriteFile(notes, []byte("one\n"), 0o600)
+
+	history := NewFileHistory()
+	write := NewWriteFileTool(dir, true)
+	edit := NewEditFileTool(dir, true)
+	appendTool := NewAppendFileTool(dir, true)
+	write.SetHistory(history)
+	edit.SetHistory(history)
+	appendTool.SetHistory(history)
+	ctx := audit.WithActor(context.Background(), audit.Actor{Session: "chat-a"})
+
+	history.Begin("chat-a")
+	appendTool.Execute(ctx, map[string]any{"path": "notes.md", "content": "two\n"})
+	history.Begin("chat-a")
+	edit.Execute(ctx, map[string]any{"path": "notes.md", "old_text": "one", "new_text": "ONE"})
+	write.Execute(ctx, map[string]any{"path": "new/todo.md", "content": "x"})
+	history.Begin("chat-a") // a turn that changes nothing isn't a batch of its own
+
+	if _, _, err := history.Undo("chat-b"); err != ErrNothingToUndo {
+		t.Errorf("Undo() in another chat = %v", err)
+	}
+
+	reverted, failed, err := history.Undo("chat-a")
+	if err != nil || len(reverted) != 2 || len(failed) != 0 {
+		t.Fatalf("Undo() = %v, %v, %v", reverted, failed, err)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "one\ntwo\n" {
+		t.Errorf("notes.md after the first undo = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new", "todo.md")); !os.IsNotExist(err) {
+		t.Errorf("created file still exists: %v", err)
+	}
+	info, _ := os.Stat(notes)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode after undo = %v", info.Mode().Perm())
+	}
+
+	if _, _, err := history.Undo("chat-a"); err != nil {
+		t.Fatalf("second Undo() error: %v", err)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "one\n" {
+		t.Errorf("notes.md after the second undo = %q", data)
+	}
+	if _, _, err := history.Undo("chat-a"); err != ErrNothingToUndo {
+		t.Errorf("third Undo() = %v", err)
+	}
+}
+
+func TestFileHistory_KeepsLastBatches(t *testing.T) {
+	dir := t.TempDir()
+	history := NewFileHistory()
+	write := NewWriteFileTool(dir, true)
+	write.SetHistory(history)
+	ctx := audit.WithActor(context.Background(), audit.Actor{Session: "chat"})
+
+	for i := 0; i < maxUndoBatches+5; i++ {
+		history.Begin("chat")
+		write.Execute(ctx, map[string]any{"path": "f.txt", "content": "v"})
+	}
+	undone := 0
+	for {
+		if _, _, err := history.Undo("chat"); err != nil {
+			break
+		}
+		undone++
+	}
+	if undone != maxUndoBatches {
+		t.Errorf("undid %d batches, want %d", undone, maxUndoBatches)
+	}
+}
+
+func TestFileHistory_InterleavedSessions(t *testing.T) {
+	dir := t.TempDir()
+	history := NewFileHistory()
+	write := NewWriteFileTool(dir, true)
+	write.SetHistory(history)
+	ctxA := audit.WithActor(context.Background(), audit.Actor{Session: "chat-a"})
+	ctxB := audit.WithActor(context.Background(), audit.Actor{Session: "chat-b"})
+
+	// Both turns are under way when either writes
+	history.Begin("chat-a")
+	history.Begin("chat-b")
+	write.Execute(ctxA, map[string]any{"path": "a.txt", "content": "a"})
+	write.Execute(ctxB, map[string]any{"path": "b.txt", "content": "b"})
+
+	reverted, _, err := history.Undo("chat-a")
+	if err != nil || len(reverted) != 1 || reverted[0] != "a.txt" {
+		t.Fatalf("Undo(chat-a) = %v, %v", reverted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.txt")); err != nil {
+		t.Errorf("chat-a's undo touched chat-b's file: %v", err)
+	}
+	reverted, _, err = history.Undo("chat-b")
+	if err != nil || len(reverted) != 1 || reverted[0] != "b.txt" {
+		t.Fatalf("Undo(chat-b) = %v, %v", reverted, err)
+	}
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/userprofile"
+	"github.com/sipeed/picoclaw/pkg/usertime"
+)
+
+// maxProfileItems bounds the preferences and the standing instructions of
+// a profile, which are part of every prompt.
+const maxProfileItems = 30
+
+// UserProfileTool keeps the profile of the person the agent is talking to.
+// It only ever sees the current user's profile, so in a shared chat one
+// member can't read or change another's.
+type UserProfileTool struct {
+	store *userprofile.Store
+}
+
+type userKey struct{}
+
+// WithUser returns a context whose tool calls are on behalf of userID, whose
+// profile user_profile works on; "" for none.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFrom returns the user ctx's tool calls are on behalf of, or "".
+func UserFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+func NewUserProfileTool(store *userprofile.Store) *UserProfileTool {
+	return &UserProfileTool{store: store}
+}
+
+func (t *UserProfileTool) Name() string {
+	return "user_profile"
+}
+
+func (t *UserProfileTool) Risk() RiskLevel {
+	return RiskMedium
+}
+
+func (t *UserProfileTool) Description() string {
+	return "Keep the profile of the user you are talking to, which is part of your instructions in their " +
+		"conversations. When they tell you their name, time zone, a lasting preference or a standing " +
+		"instruction (\"always answer in German\", \"don't use emoji\"), save it without being asked; " +
+		"their time zone is what reminders and schedules are set in. " +
+		"Actions: 'read'; 'set' field name or timezone to value (empty clears it); 'add' or 'remove' a value " +
+		"in field preferences or instructions."
+}
+
+func (t *UserProfileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"read", "set", "add", "remove"},
+				"description": "What to do",
+			},
+			"field": map[string]any{
+				"type":        "string",
+				"enum":        []string{"name", "timezone", "preferences", "instructions"},
+				"description": "name and timezone for set, preferences and instructions for add and remove",
+			},
+			"value": map[string]any{
+				"type":        "string",
+				"description": "The value, e.g. 'Sam', 'Europe/Berlin' (IANA) or 'prefers metric units'",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *UserProfileTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	userID := UserFrom(ctx)
+	if userID == "" {
+		return ErrorResult("no user to keep a profile for in this conversation")
+	}
+
+	action, _ := args["action"].(string)
+	field, _ := args["field"].(string)
+	value, _ := args["value"].(string)
+	// Each value is one line of the profile file
+	value = strings.Join(strings.Fields(value), " ")
+
+	if action == "read" {
+		p, err := t.store.Get(userID)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read the profile: %v", err)).WithError(err)
+		}
+		if p.Empty() {
+			return SilentResult("The profile is empty")
+		}
+		return SilentResult(p.Markdown())
+	}
+
+	var update func(p *userprofile.Profile) error
+	switch {
+	case action == "set" && field == "name":
+		update = func(p *userprofile.Profile) error { p.Name = value; return nil }
+	case action == "set" && field == "timezone":
+		if value != "" {
+			if _, err := usertime.Load(value); err != nil {
+				return ErrorResult(fmt.Sprintf(
+					"unknown time zone %q; use an IANA name like Europe/Berlin or an offset like UTC+2", value))
+			}
+		}
+		update = func(p *userprofile.Profile) error { p.Timezone = value; return nil }
+	case (action == "add" || action == "remove") && (field == "preferences" || field == "instructions"):
+		if value == "" {
+			return ErrorResult(fmt.Sprintf("value is required to %s", action))
+		}
+		update = func(p *userprofile.Profile) error {
+			list := &p.Preferences
+			if field == "instructions" {
+				list = &p.Instructions
+			}
+			if action == "remove" {
+				if !userprofile.Remove(list, value) {
+					return fmt.Errorf("%q is not in %s", value, field)
+				}
+				return nil
+			}
+			if slices.ContainsFunc(*list, func(s string) bool { return strings.EqualFold(s, value) }) {
+				return nil
+			}
+			if len(*list) >= maxProfileItems {
+				return fmt.Errorf("%s is full (%d entries); remove one first", field, maxProfileItems)
+			}
+			*list = append(*list, value)
+			return nil
+		}
+	case action == "set" || action == "add" || action == "remove":
+		return ErrorResult(fmt.Sprintf("can't %s field %q", action, field))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %q", action))
+	}
+
+	if err := t.store.Update(userID, update); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to update the profile: %v", err)).WithError(err)
+	}
+	if action == "set" && value == "" {
+		return SilentResult(fmt.Sprintf("Cleared %s", field))
+	}
+	return SilentResult(fmt.Sprintf("Profile updated: %s %s", action, field))
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/userprofile"
+)
+
+func TestUserProfileTool(t *testing.T) {
+	store := userprofile.NewStore(t.TempDir())
+	tool := NewUserProfileTool(store)
+	ctx := context.Background()
+
+	if result := tool.Execute(ctx, map[string]any{"action": "read"}); !result.IsError {
+		t.Errorf("read without a user = %q", result.ForLLM)
+	}
+
+	ctx = WithUser(ctx, "telegram:123")
+	for _, args := range []map[string]any{
+		{"action": "set", "field": "name", "value": " Sam "},
+		{"action": "set", "field": "timezone", "value": "Europe/Berlin"},
+		{"action": "add", "field": "preferences", "value": "metric\nunits"},
+		{"action": "add", "field": "preferences", "value": "Metric units"},
+		{"action": "add", "field": "instructions", "value": "answer in German"},
+	} {
+		if result := tool.Execute(ctx, args); result.IsError {
+			t.Fatalf("%v: %s", args, result.ForLLM)
+		}
+	}
+	result := tool.Execute(ctx, map[string]any{"action": "read"})
+	want := "Name: Sam\nTimezone: Europe/Berlin\n\n## Preferences\n- metric units\n\n" +
+		"## Standing instructions\n- answer in German\n"
+	if result.ForLLM != want {
+		t.Errorf("read = %q, want %q", result.ForLLM, want)
+	}
+
+	for _, args := range []map[string]any{
+		{"action": "set", "field": "timezone", "value": "Mars/Olympus"},
+		{"action": "set", "field": "preferences", "value": "x"},
+		{"action": "remove", "field": "instructions", "value": "answer in French"},
+		{"action": "add", "field": "preferences"},
+		{"action": "forget"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v = %q, want an error", args, result.ForLLM)
+		}
+	}
+
+	// Another member of the same chat has a profile of their own
+	other := WithUser(ctx, "telegram:456")
+	if result := tool.Execute(other, map[string]any{"action": "read"}); result.ForLLM != "The profile is empty" {
+		t.Errorf("other user's read = %q", result.ForLLM)
+	}
+
+	tool.Execute(ctx, map[string]any{"action": "remove", "field": "instructions", "value": "Answer in German"})
+	tool.Execute(ctx, map[string]any{"action": "set", "field": "timezone", "value": ""})
+	if result := tool.Execute(ctx, map[string]any{"action": "read"}); strings.Contains(result.ForLLM, "German") ||
+		strings.Contains(result.ForLLM, "Timezone") {
+		t.Errorf("read after remove and clear = %q", result.ForLLM)
+	}
+}
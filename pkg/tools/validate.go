@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ArgumentError lists what is wrong with the arguments of a tool call. Its
+// message is written for the model, so it can fix the call and try again.
+type ArgumentError struct {
+	Tool     string
+	Problems []string // "path: required", "limit: must be at most 20, got 50"
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s:\n- %s\nCall %s again with arguments that match its parameters.",
+		e.Tool, strings.Join(e.Problems, "\n- "), e.Tool)
+}
+
+// ValidateArgs checks args against the tool's parameter schema before it
+// runs. It covers the parts of JSON Schema that tools use: type, required,
+// properties, additionalProperties, enum, items, minimum and maximum.
+// Optional arguments that are null are treated as left out.
+func ValidateArgs(tool Tool, args map[string]any) error {
+	var problems []string
+	validateObject(tool.Parameters(), args, "", &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ArgumentError{Tool: tool.Name(), Problems: problems}
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string, problems *[]string) {
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range stringList(schema["required"]) {
+		if obj[name] == nil {
+			*problems = append(*problems, argPath(path, name)+": required")
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := obj[name]
+		prop, known := properties[name].(map[string]any)
+		switch {
+		case value == nil:
+		case known:
+			validateValue(prop, value, argPath(path, name), problems)
+		case schema["additionalProperties"] == false:
+			*problems = append(*problems, fmt.Sprintf("%s: unknown argument; expected one of %s",
+				argPath(path, name), strings.Join(sortedKeys(properties), ", ")))
+		}
+	}
+}
+
+func validateValue(schema map[string]any, value any, path string, problems *[]string) {
+	if typ, _ := schema["type"].(string); typ != "" && !hasType(value, typ) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be %s, got %s", path, article(typ), describe(value)))
+		return
+	}
+
+	if enum := reflect.ValueOf(schema["enum"]); enum.Kind() == reflect.Slice && enum.Len() > 0 {
+		allowed := make([]string, enum.Len())
+		for i := range allowed {
+			allowed[i] = fmt.Sprint(enum.Index(i).Interface())
+		}
+		if !slices.Contains(allowed, fmt.Sprint(value)) {
+			*problems = append(*problems, fmt.Sprintf("%s: must be one of %s, got %v",
+				path, strings.Join(allowed, ", "), value))
+			return
+		}
+	}
+
+	if n, ok := toFloat(value); ok {
+		if lo, ok := toFloat(schema["minimum"]); ok && n < lo {
+			*problems = append(*problems, fmt.Sprintf("%s: must be at least %v, got %v", path, lo, n))
+		}
+		if hi, ok := toFloat(schema["maximum"]); ok && n > hi {
+			*problems = append(*problems, fmt.Sprintf("%s: must be at most %v, got %v", path, hi, n))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		validateObject(schema, v, path, problems)
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// hasType reports whether value, as decoded from JSON, is of the JSON
+// Schema type typ. Unknown types match anything.
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		return reflect.ValueOf(value).Kind() == reflect.Slice
+	}
+	return true
+}
+
+func toFloat(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+func describe(value any) string {
+	switch value.(type) {
+	case string:
+		return fmt.Sprintf("the string %q", value)
+	case bool:
+		return fmt.Sprintf("%v", value)
+	case map[string]any:
+		return "an object"
+	}
+	if reflect.ValueOf(value).Kind() == reflect.Slice {
+		return "an array"
+	}
+	if _, ok := toFloat(value); ok {
+		return fmt.Sprintf("the number %v", value)
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func article(typ string) string {
+	switch typ {
+	case "array", "object", "integer":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+// stringList reads a list of strings from a schema, which may be written
+// in Go ([]string) or decoded from JSON ([]any).
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func argPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
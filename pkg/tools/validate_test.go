@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+)
+
+// schemaTool declares a schema with the features tools use.
+type schemaTool struct{ runs int }
+
+func (t *schemaTool) Name() string        { return "schema_tool" }
+func (t *schemaTool) Description() string { return "test tool" }
+func (t *schemaTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{"type": "string", "enum": []string{"add", "list"}},
+			"count":  map[string]any{"type": "integer", "minimum": 1.0, "maximum": 20.0},
+			"force":  map[string]any{"type": "boolean"},
+			"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"where": map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"column": map[string]any{"type": "string"}},
+				"required":             []any{"column"},
+				"additionalProperties": false,
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *schemaTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	t.runs++
+	return NewToolResult("ok")
+}
+
+func TestValidateArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want []string
+	}{
+		{name: "valid", args: `{"action":"add","count":3,"tags":["a"],"where":{"column":"x"}}`},
+		{name: "optional null", args: `{"action":"list","count":null}`},
+		{name: "missing required", args: `{"count":2}`, want: []string{"action: required"}},
+		{name: "not in enum", args: `{"action":"delete"}`, want: []string{"action: must be one of add, list, got delete"}},
+		{
+			name: "wrong types", args: `{"action":"add","count":"5","force":"yes"}`,
+			want: []string{`count: must be an integer, got the string "5"`, `force: must be a boolean, got the string "yes"`},
+		},
+		{
+			name: "fraction", args: `{"action":"add","count":2.5}`,
+			want: []string{"count: must be an integer, got the number 2.5"},
+		},
+		{name: "out of range", args: `{"action":"add","count":50}`, want: []string{"count: must be at most 20, got 50"}},
+		{
+			name: "array item", args: `{"action":"add","tags":["a",7]}`,
+			want: []string{"tags[1]: must be a string, got the number 7"},
+		},
+		{
+			name: "nested object", args: `{"action":"add","where":{"col":"x"}}`,
+			want: []string{"where.column: required", "where.col: unknown argument; expected one of column"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]any
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateArgs(&schemaTool{}, args)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("ValidateArgs() = %v", err)
+				}
+				return
+			}
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) || strings.Join(argErr.Problems, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ValidateArgs() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_RejectsInvalidArgs(t *testing.T) {
+	tool := &schemaTool{}
+	r := NewToolRegistry()
+	r.Register(tool)
+	path := audit.LogPath(t.TempDir())
+	r.SetAuditLog(audit.New(path, 0))
+
+	result := r.Execute(context.Background(), "schema_tool", map[string]any{"action": "remove"})
+	if !result.IsError || tool.runs != 0 {
+		t.Fatalf("Execute() = %+v after %d runs", result, tool.runs)
+	}
+	want := "invalid arguments for schema_tool:\n- action: must be one of add, list, got remove\n" +
+		"Call schema_tool again with arguments that match its parameters."
+	if result.ForLLM != want {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	entries, _ := audit.Read(path, audit.Filter{})
+	if len(entries) != 1 || entries[0].Status != audit.StatusInvalid {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
@@ -0,0 +1,347 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	openMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+	openMeteoGeocodeURL  = "https://geocoding-api.open-meteo.com/v1/search"
+	maxWeatherDays       = 16
+
+	weatherCurrentFields = "temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,wind_speed_10m"
+	weatherDailyFields   = "weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum," +
+		"precipitation_probability_max"
+)
+
+// weatherCodes describes the WMO weather codes Open-Meteo reports.
+var weatherCodes = map[int]string{
+	0:  "clear sky",
+	1:  "mainly clear",
+	2:  "partly cloudy",
+	3:  "overcast",
+	45: "fog",
+	48: "freezing fog",
+	51: "light drizzle",
+	53: "drizzle",
+	55: "heavy drizzle",
+	56: "light freezing drizzle",
+	57: "freezing drizzle",
+	61: "light rain",
+	63: "rain",
+	65: "heavy rain",
+	66: "light freezing rain",
+	67: "freezing rain",
+	71: "light snow",
+	73: "snow",
+	75: "heavy snow",
+	77: "snow grains",
+	80: "light showers",
+	81: "showers",
+	82: "violent showers",
+	85: "light snow showers",
+	86: "snow showers",
+	95: "thunderstorm",
+	96: "thunderstorm with hail",
+	99: "thunderstorm with heavy hail",
+}
+
+// weatherPlace is a resolved location.
+type weatherPlace struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// WeatherTool reports current conditions and the daily forecast from
+// Open-Meteo, which needs no API key. Without a location it uses the one
+// in tools.weather.
+type WeatherTool struct {
+	cfg         config.WeatherToolsConfig
+	forecastURL string
+	geocodeURL  string
+	client      *http.Client
+
+	mu     sync.Mutex
+	places map[string]weatherPlace // geocoding cache, by lowercased query
+}
+
+func NewWeatherTool(cfg config.WeatherToolsConfig) *WeatherTool {
+	if cfg.Days <= 0 {
+		cfg.Days = 3
+	}
+	return &WeatherTool{
+		cfg:         cfg,
+		forecastURL: openMeteoForecastURL,
+		geocodeURL:  openMeteoGeocodeURL,
+		client:      &http.Client{Timeout: 15 * time.Second},
+		places:      make(map[string]weatherPlace),
+	}
+}
+
+func (t *WeatherTool) Name() string {
+	return "weather"
+}
+
+func (t *WeatherTool) Risk() RiskLevel {
+	return RiskLow
+}
+
+func (t *WeatherTool) Description() string {
+	desc := "Get the current weather and the daily forecast (conditions, temperatures, chance of rain) for a place. " +
+		"Use it instead of a web search for weather questions."
+	if home := t.homeName(); home != "" {
+		desc += fmt.Sprintf(" Without a location, it reports the weather for %s.", home)
+	}
+	return desc
+}
+
+func (t *WeatherTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"location": map[string]any{
+				"type": "string",
+				"description": "City, optionally with country (\"Paris, France\"), or \"latitude,longitude\". " +
+					"Default: the configured location",
+			},
+			"days": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Days of forecast, 1-%d (default %d)", maxWeatherDays, t.cfg.Days),
+			},
+		},
+	}
+}
+
+func (t *WeatherTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	days := t.cfg.Days
+	if d, ok := args["days"].(float64); ok && d >= 1 {
+		days = min(int(d), maxWeatherDays)
+	}
+
+	location, _ := args["location"].(string)
+	place, err := t.resolve(ctx, strings.TrimSpace(location))
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	report, err := t.forecast(ctx, place, days)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("weather lookup failed: %v", err)).WithError(err)
+	}
+	return NewToolResult(report)
+}
+
+func (t *WeatherTool) homeName() string {
+	if t.cfg.Location != "" {
+		return t.cfg.Location
+	}
+	if t.cfg.Latitude != 0 || t.cfg.Longitude != 0 {
+		return fmt.Sprintf("%.4g,%.4g", t.cfg.Latitude, t.cfg.Longitude)
+	}
+	return ""
+}
+
+// resolve finds the coordinates of location, or of the configured one when
+// location is empty.
+func (t *WeatherTool) resolve(ctx context.Context, location string) (weatherPlace, error) {
+	if location == "" {
+		if t.cfg.Latitude != 0 || t.cfg.Longitude != 0 {
+			return weatherPlace{Name: t.homeName(), Latitude: t.cfg.Latitude, Longitude: t.cfg.Longitude}, nil
+		}
+		if t.cfg.Location == "" {
+			return weatherPlace{}, fmt.Errorf("no location given and tools.weather.location is not set")
+		}
+		location = t.cfg.Location
+	}
+
+	if lat, lon, ok := strings.Cut(location, ","); ok {
+		latitude, errLat := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		longitude, errLon := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if errLat == nil && errLon == nil {
+			return weatherPlace{Name: location, Latitude: latitude, Longitude: longitude}, nil
+		}
+	}
+
+	key := strings.ToLower(location)
+	t.mu.Lock()
+	place, ok := t.places[key]
+	t.mu.Unlock()
+	if ok {
+		return place, nil
+	}
+	place, err := t.geocode(ctx, location)
+	if err != nil {
+		return weatherPlace{}, err
+	}
+	t.mu.Lock()
+	t.places[key] = place
+	t.mu.Unlock()
+	return place, nil
+}
+
+// geocode looks up a place name. "Paris, France" searches for Paris and
+// prefers the result whose country or region matches the rest.
+func (t *WeatherTool) geocode(ctx context.Context, location string) (weatherPlace, error) {
+	name, qualifier, _ := strings.Cut(location, ",")
+	name, qualifier = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(qualifier))
+
+	query := url.Values{"name": {name}, "count": {"10"}, "format": {"json"}}
+	var resp struct {
+		Results []struct {
+			Name        string  `json:"name"`
+			Latitude    float64 `json:"latitude"`
+			Longitude   float64 `json:"longitude"`
+			Country     string  `json:"country"`
+			CountryCode string  `json:"country_code"`
+			Admin1      string  `json:"admin1"`
+		} `json:"results"`
+	}
+	if err := t.getJSON(ctx, t.geocodeURL+"?"+query.Encode(), &resp); err != nil {
+		return weatherPlace{}, fmt.Errorf("looking up %q: %w", location, err)
+	}
+	if len(resp.Results) == 0 {
+		return weatherPlace{}, fmt.Errorf("no place called %q found", location)
+	}
+
+	best := resp.Results[0]
+	if qualifier != "" {
+		for _, r := range resp.Results {
+			if strings.HasPrefix(strings.ToLower(r.Country), qualifier) ||
+				strings.HasPrefix(strings.ToLower(r.Admin1), qualifier) ||
+				strings.EqualFold(r.CountryCode, qualifier) {
+				best = r
+				break
+			}
+		}
+	}
+
+	parts := []string{best.Name}
+	if best.Admin1 != "" && best.Admin1 != best.Name {
+		parts = append(parts, best.Admin1)
+	}
+	if best.Country != "" {
+		parts = append(parts, best.Country)
+	}
+	return weatherPlace{Name: strings.Join(parts, ", "), Latitude: best.Latitude, Longitude: best.Longitude}, nil
+}
+
+func (t *WeatherTool) forecast(ctx context.Context, place weatherPlace, days int) (string, error) {
+	query := url.Values{
+		"latitude":      {strconv.FormatFloat(place.Latitude, 'f', 4, 64)},
+		"longitude":     {strconv.FormatFloat(place.Longitude, 'f', 4, 64)},
+		"current":       {weatherCurrentFields},
+		"daily":         {weatherDailyFields},
+		"timezone":      {"auto"},
+		"forecast_days": {strconv.Itoa(days)},
+	}
+	if t.cfg.Units == "imperial" {
+		query.Set("temperature_unit", "fahrenheit")
+		query.Set("wind_speed_unit", "mph")
+		query.Set("precipitation_unit", "inch")
+	}
+
+	var resp struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			FeelsLike   float64 `json:"apparent_temperature"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			Code        int     `json:"weather_code"`
+			Wind        float64 `json:"wind_speed_10m"`
+		} `json:"current"`
+		CurrentUnits struct {
+			Temperature string `json:"temperature_2m"`
+			Wind        string `json:"wind_speed_10m"`
+		} `json:"current_units"`
+		Daily struct {
+			Time       []string   `json:"time"`
+			Code       []int      `json:"weather_code"`
+			Max        []float64  `json:"temperature_2m_max"`
+			Min        []float64  `json:"temperature_2m_min"`
+			Precip     []float64  `json:"precipitation_sum"`
+			PrecipProb []*float64 `json:"precipitation_probability_max"`
+		} `json:"daily"`
+		DailyUnits struct {
+			Precip string `json:"precipitation_sum"`
+		} `json:"daily_units"`
+	}
+	if err := t.getJSON(ctx, t.forecastURL+"?"+query.Encode(), &resp); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Weather for %s\n", place.Name)
+	c, unit := resp.Current, resp.CurrentUnits.Temperature
+	fmt.Fprintf(&sb, "Now: %.0f%s (feels like %.0f%s), %s, wind %.0f %s, humidity %.0f%%\n",
+		c.Temperature, unit, c.FeelsLike, unit, weatherDescription(c.Code), c.Wind, resp.CurrentUnits.Wind, c.Humidity)
+
+	d := resp.Daily
+	for i, date := range d.Time {
+		if i >= len(d.Code) || i >= len(d.Max) || i >= len(d.Min) {
+			break
+		}
+		label := date
+		if day, err := time.Parse("2006-01-02", date); err == nil {
+			label = day.Format("Mon 2006-01-02")
+		}
+		fmt.Fprintf(&sb, "%s: %s, %.0f to %.0f%s", label, weatherDescription(d.Code[i]), d.Min[i], d.Max[i], unit)
+		if i < len(d.PrecipProb) && d.PrecipProb[i] != nil {
+			fmt.Fprintf(&sb, ", %.0f%% chance of rain", *d.PrecipProb[i])
+		}
+		if i < len(d.Precip) && d.Precip[i] > 0 {
+			fmt.Fprintf(&sb, ", %.1f %s", d.Precip[i], resp.DailyUnits.Precip)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func (t *WeatherTool) getJSON(ctx context.Context, requestURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Reason != "" {
+			return fmt.Errorf("Open-Meteo error: %s", apiErr.Reason)
+		}
+		return fmt.Errorf("Open-Meteo error: status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+func weatherDescription(code int) string {
+	if desc, ok := weatherCodes[code]; ok {
+		return desc
+	}
+	return fmt.Sprintf("weather code %d", code)
+}
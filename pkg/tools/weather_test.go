@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newWeatherTestServer(t *testing.T, geocodes *int) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		*geocodes++
+		if r.URL.Query().Get("name") == "Nowhere" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"results": [
+			{"name": "Paris", "latitude": 33.66, "longitude": -95.56, "country": "United States", "admin1": "Texas"},
+			{"name": "Paris", "latitude": 48.85, "longitude": 2.35, "country": "France", "admin1": "Île-de-France"}
+		]}`))
+	})
+	mux.HandleFunc("/forecast", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("latitude") == "99.0000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": true, "reason": "Latitude must be in range of -90 to 90°."}`))
+			return
+		}
+		unit := "°C"
+		if q.Get("temperature_unit") == "fahrenheit" {
+			unit = "°F"
+		}
+		w.Write([]byte(`{
+			"current_units": {"temperature_2m": "` + unit + `", "wind_speed_10m": "km/h"},
+			"current": {"temperature_2m": 12.4, "apparent_temperature": 10.2, "relative_humidity_2m": 71,
+				"weather_code": 2, "wind_speed_10m": 14.8},
+			"daily_units": {"precipitation_sum": "mm"},
+			"daily": {
+				"time": ["2026-03-10", "2026-03-11"],
+				"weather_code": [61, 0],
+				"temperature_2m_max": [13.1, 15.6],
+				"temperature_2m_min": [7.9, 6.2],
+				"precipitation_sum": [2.4, 0],
+				"precipitation_probability_max": [80, null]
+			},
+			"latitude": ` + q.Get("latitude") + `
+		}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWeatherTool(t *testing.T) {
+	geocodes := 0
+	server := newWeatherTestServer(t, &geocodes)
+	tool := NewWeatherTool(config.WeatherToolsConfig{Location: "Paris, France", Units: "metric"})
+	tool.forecastURL = server.URL + "/forecast"
+	tool.geocodeURL = server.URL + "/search"
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{})
+	if result.IsError {
+		t.Fatalf("Execute: %s", result.ForLLM)
+	}
+	want := "Weather for Paris, Île-de-France, France\n" +
+		"Now: 12°C (feels like 10°C), partly cloudy, wind 15 km/h, humidity 71%\n" +
+		"Tue 2026-03-10: light rain, 8 to 13°C, 80% chance of rain, 2.4 mm\n" +
+		"Wed 2026-03-11: clear sky, 6 to 16°C"
+	if result.ForLLM != want {
+		t.Errorf("report = %q, want %q", result.ForLLM, want)
+	}
+
+	// Places are looked up once
+	tool.Execute(ctx, map[string]any{"location": "paris, france"})
+	if geocodes != 1 {
+		t.Errorf("geocoded %d times, want 1", geocodes)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"location": "Paris"})
+	if !strings.HasPrefix(result.ForLLM, "Weather for Paris, Texas, United States") {
+		t.Errorf("unqualified place = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"location": "52.52, 13.41"})
+	if !strings.HasPrefix(result.ForLLM, "Weather for 52.52, 13.41") || geocodes != 2 {
+		t.Errorf("coordinates = %q", result.ForLLM)
+	}
+
+	for _, location := range []string{"Nowhere", "99,0"} {
+		if result := tool.Execute(ctx, map[string]any{"location": location}); !result.IsError {
+			t.Errorf("%s succeeded: %s", location, result.ForLLM)
+		}
+	}
+	if result := tool.Execute(ctx, map[string]any{"location": "99,0"}); !strings.Contains(result.ForLLM, "Latitude must") {
+		t.Errorf("API error = %q", result.ForLLM)
+	}
+
+	imperial := NewWeatherTool(config.WeatherToolsConfig{Latitude: 40.71, Longitude: -74.01, Units: "imperial"})
+	imperial.forecastURL = server.URL + "/forecast"
+	result = imperial.Execute(ctx, map[string]any{"days": float64(2)})
+	if !strings.Contains(result.ForLLM, "°F") || !strings.Contains(imperial.Description(), "40.71,-74.01") {
+		t.Errorf("imperial report = %q", result.ForLLM)
+	}
+
+	if result := NewWeatherTool(config.WeatherToolsConfig{}).Execute(ctx, map[string]any{}); !result.IsError {
+		t.Errorf("no location succeeded: %s", result.ForLLM)
+	}
+}
@@ -20,13 +20,21 @@ type SearchProvider interface {
 	Search(ctx context.Context, query string, count int) (string, error)
 }
 
+// Safe search levels; backends without a filter ignore them.
+const (
+	SafeSearchOff      = "off"
+	SafeSearchModerate = "moderate"
+	SafeSearchStrict   = "strict"
+)
+
 type BraveSearchProvider struct {
-	apiKey string
+	apiKey     string
+	safeSearch string
 }
 
 func (p *BraveSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
-	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d",
-		url.QueryEscape(query), count)
+	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d&safesearch=%s",
+		url.QueryEscape(query), count, p.safeSearch)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
@@ -84,10 +92,13 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 	return strings.Join(lines, "\n"), nil
 }
 
-type DuckDuckGoSearchProvider struct{}
+type DuckDuckGoSearchProvider struct {
+	safeSearch string
+}
 
 func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
-	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
+	kp := map[string]string{SafeSearchOff: "-2", SafeSearchModerate: "-1", SafeSearchStrict: "1"}[p.safeSearch]
+	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s&kp=%s", url.QueryEscape(query), kp)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
@@ -247,6 +258,147 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 	return fmt.Sprintf("Results for: %s (via Perplexity)\n%s", query, searchResp.Choices[0].Message.Content), nil
 }
 
+// searchResult is one hit from a backend with a structured API.
+type searchResult struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// formatWebResults renders results in the numbered layout all backends
+// share.
+func formatWebResults(query, via string, results []searchResult, count int) string {
+	if len(results) == 0 {
+		return fmt.Sprintf("No results for: %s", query)
+	}
+
+	lines := []string{fmt.Sprintf("Results for: %s (via %s)", query, via)}
+	for i, item := range results {
+		if i >= count {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
+		if snippet := strings.Join(strings.Fields(item.Snippet), " "); snippet != "" {
+			lines = append(lines, fmt.Sprintf("   %s", snippet))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SearxNGSearchProvider queries a SearxNG instance's JSON API. The instance
+// must have the json format enabled in its settings.
+type SearxNGSearchProvider struct {
+	baseURL    string
+	safeSearch string
+}
+
+func (p *SearxNGSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	level := map[string]string{SafeSearchOff: "0", SafeSearchModerate: "1", SafeSearchStrict: "2"}[p.safeSearch]
+	searchURL := fmt.Sprintf("%s/search?q=%s&format=json&safesearch=%s",
+		strings.TrimRight(p.baseURL, "/"), url.QueryEscape(query), level)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("SearxNG error: status %d", resp.StatusCode)
+	}
+
+	var searchResp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return "", fmt.Errorf("failed to parse response (is the json format enabled?): %w", err)
+	}
+
+	results := make([]searchResult, 0, len(searchResp.Results))
+	for _, item := range searchResp.Results {
+		results = append(results, searchResult{Title: item.Title, URL: item.URL, Snippet: item.Content})
+	}
+	return formatWebResults(query, "SearxNG", results, count), nil
+}
+
+// TavilySearchProvider uses the Tavily search API, which has no safe search
+// setting.
+type TavilySearchProvider struct {
+	apiKey  string
+	baseURL string
+}
+
+func (p *TavilySearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = "https://api.tavily.com"
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"query":        query,
+		"max_results":  count,
+		"search_depth": "basic",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/search", strings.NewReader(string(payload)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Tavily API error: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var searchResp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	results := make([]searchResult, 0, len(searchResp.Results))
+	for _, item := range searchResp.Results {
+		results = append(results, searchResult{Title: item.Title, URL: item.URL, Snippet: item.Content})
+	}
+	return formatWebResults(query, "Tavily", results, count), nil
+}
+
 type WebSearchTool struct {
 	provider   SearchProvider
 	maxResults int
@@ -261,25 +413,47 @@ type WebSearchToolOptions struct {
 	PerplexityAPIKey     string
 	PerplexityMaxResults int
 	PerplexityEnabled    bool
+	SearxNGBaseURL       string
+	SearxNGMaxResults    int
+	SearxNGEnabled       bool
+	TavilyAPIKey         string
+	TavilyMaxResults     int
+	TavilyEnabled        bool
+	SafeSearch           string // off, moderate (default) or strict
 }
 
 func NewWebSearchTool(opts WebSearchToolOptions) *WebSearchTool {
 	var provider SearchProvider
 	maxResults := 5
 
-	// Priority: Perplexity > Brave > DuckDuckGo
+	safeSearch := strings.ToLower(opts.SafeSearch)
+	if safeSearch != SafeSearchOff && safeSearch != SafeSearchStrict {
+		safeSearch = SafeSearchModerate
+	}
+
+	// Priority: Perplexity > Tavily > Brave > SearxNG > DuckDuckGo
 	if opts.PerplexityEnabled && opts.PerplexityAPIKey != "" {
 		provider = &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey}
 		if opts.PerplexityMaxResults > 0 {
 			maxResults = opts.PerplexityMaxResults
 		}
+	} else if opts.TavilyEnabled && opts.TavilyAPIKey != "" {
+		provider = &TavilySearchProvider{apiKey: opts.TavilyAPIKey}
+		if opts.TavilyMaxResults > 0 {
+			maxResults = opts.TavilyMaxResults
+		}
 	} else if opts.BraveEnabled && opts.BraveAPIKey != "" {
-		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey}
+		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey, safeSearch: safeSearch}
 		if opts.BraveMaxResults > 0 {
 			maxResults = opts.BraveMaxResults
 		}
+	} else if opts.SearxNGEnabled && opts.SearxNGBaseURL != "" {
+		provider = &SearxNGSearchProvider{baseURL: opts.SearxNGBaseURL, safeSearch: safeSearch}
+		if opts.SearxNGMaxResults > 0 {
+			maxResults = opts.SearxNGMaxResults
+		}
 	} else if opts.DuckDuckGoEnabled {
-		provider = &DuckDuckGoSearchProvider{}
+		provider = &DuckDuckGoSearchProvider{safeSearch: safeSearch}
 		if opts.DuckDuckGoMaxResults > 0 {
 			maxResults = opts.DuckDuckGoMaxResults
 		}
@@ -297,6 +471,10 @@ func (t *WebSearchTool) Name() string {
 	return "web_search"
 }
 
+func (t *WebSearchTool) Risk() RiskLevel {
+	return RiskLow
+}
+
 func (t *WebSearchTool) Description() string {
 	return "Search the web for current information. Returns titles, URLs, and snippets from search results."
 }
@@ -344,6 +522,10 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 	}
 }
 
+// WebFetchTool fetches a URL and strips its tags.
+//
+// Deprecated: agents use FetchURLTool, which extracts the main content,
+// respects robots.txt and caches pages.
 type WebFetchTool struct {
 	maxChars int
 }
@@ -361,6 +543,10 @@ func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
+func (t *WebFetchTool) Risk() RiskLevel {
+	return RiskLow
+}
+
 func (t *WebFetchTool) Description() string {
 	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
 }
@@ -3,8 +3,10 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -333,3 +335,100 @@ func TestWebTool_WebFetch_MissingDomain(t *testing.T) {
 		t.Errorf("Expected domain error message, got ForLLM: %s", result.ForLLM)
 	}
 }
+
+// TestWebTool_WebSearch_SearxNG verifies the SearxNG backend and safe search level
+func TestWebTool_WebSearch_SearxNG(t *testing.T) {
+	var gotQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[
+			{"title":"Go 1.26","url":"https://go.dev/doc/go1.26","content":"Release\n notes"},
+			{"title":"Second","url":"https://example.com/2","content":""},
+			{"title":"Third","url":"https://example.com/3","content":"more"}]}`))
+	}))
+	defer server.Close()
+
+	tool := NewWebSearchTool(WebSearchToolOptions{
+		SearxNGEnabled:    true,
+		SearxNGBaseURL:    server.URL + "/",
+		SearxNGMaxResults: 2,
+		DuckDuckGoEnabled: true,
+		SafeSearch:        "Strict",
+	})
+	result := tool.Execute(context.Background(), map[string]any{"query": "go release"})
+	if result.IsError {
+		t.Fatalf("Expected success, got: %s", result.ForLLM)
+	}
+
+	if gotQuery.Get("q") != "go release" || gotQuery.Get("format") != "json" || gotQuery.Get("safesearch") != "2" {
+		t.Errorf("query = %v", gotQuery)
+	}
+	want := "Results for: go release (via SearxNG)\n" +
+		"1. Go 1.26\n   https://go.dev/doc/go1.26\n   Release notes\n" +
+		"2. Second\n   https://example.com/2"
+	if result.ForLLM != want {
+		t.Errorf("ForLLM = %q, want %q", result.ForLLM, want)
+	}
+}
+
+// TestWebTool_WebSearch_Tavily verifies the Tavily request and result parsing
+func TestWebTool_WebSearch_Tavily(t *testing.T) {
+	var gotAuth string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"results":[{"title":"Weather","url":"https://example.com/w","content":"Sunny"}]}`))
+	}))
+	defer server.Close()
+
+	provider := &TavilySearchProvider{apiKey: "tvly-key", baseURL: server.URL}
+	got, err := provider.Search(context.Background(), "weather", 3)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if gotAuth != "Bearer tvly-key" || gotBody["query"] != "weather" || gotBody["max_results"] != 3.0 {
+		t.Errorf("auth = %q, body = %v", gotAuth, gotBody)
+	}
+	if got != "Results for: weather (via Tavily)\n1. Weather\n   https://example.com/w\n   Sunny" {
+		t.Errorf("Search() = %q", got)
+	}
+}
+
+// TestWebTool_WebSearch_BackendPriority verifies which backend wins when several are enabled
+func TestWebTool_WebSearch_BackendPriority(t *testing.T) {
+	tests := []struct {
+		opts WebSearchToolOptions
+		want SearchProvider
+	}{
+		{
+			WebSearchToolOptions{TavilyEnabled: true, TavilyAPIKey: "k", BraveEnabled: true, BraveAPIKey: "k"},
+			&TavilySearchProvider{},
+		},
+		{
+			WebSearchToolOptions{
+				BraveEnabled: true, BraveAPIKey: "k",
+				SearxNGEnabled: true, SearxNGBaseURL: "http://s",
+			},
+			&BraveSearchProvider{},
+		},
+		{
+			WebSearchToolOptions{SearxNGEnabled: true, SearxNGBaseURL: "http://s", DuckDuckGoEnabled: true},
+			&SearxNGSearchProvider{},
+		},
+		{
+			WebSearchToolOptions{SearxNGEnabled: true, DuckDuckGoEnabled: true},
+			&DuckDuckGoSearchProvider{},
+		},
+	}
+	for _, tt := range tests {
+		tool := NewWebSearchTool(tt.opts)
+		if tool == nil {
+			t.Fatalf("NewWebSearchTool(%+v) = nil", tt.opts)
+		}
+		if fmt.Sprintf("%T", tool.provider) != fmt.Sprintf("%T", tt.want) {
+			t.Errorf("NewWebSearchTool(%+v) provider = %T, want %T", tt.opts, tool.provider, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	webhookTimeout  = 30 * time.Second
+	webhookMaxChars = 10000
+	webhookMaxBytes = 1 << 20
+)
+
+// webhookFuncs are the functions webhook templates can use besides the
+// built-in ones such as urlquery: json encodes a value, env reads an
+// environment variable, so secrets can stay out of the config.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"env": os.Getenv,
+}
+
+// WebhookTool is a tool declared in config (tools.webhooks) that calls an
+// HTTP endpoint with the model's arguments and returns the response.
+type WebhookTool struct {
+	name        string
+	description string
+	parameters  map[string]any
+	method      string
+	url         *template.Template
+	headers     map[string]*template.Template
+	body        *template.Template // nil sends the arguments as JSON, or nothing for GET and DELETE
+	risk        RiskLevel
+	maxChars    int
+	client      *http.Client
+}
+
+// NewWebhookTool creates the webhook tool called name, failing when one of
+// its templates doesn't parse.
+func NewWebhookTool(name string, cfg config.WebhookToolConfig) (*WebhookTool, error) {
+	t := &WebhookTool{
+		name:        name,
+		description: cfg.Description,
+		parameters:  cfg.Parameters,
+		method:      strings.ToUpper(cfg.Method),
+		headers:     make(map[string]*template.Template, len(cfg.Headers)),
+		maxChars:    cfg.MaxChars,
+		client:      &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second},
+	}
+	if t.parameters == nil {
+		t.parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if t.method == "" {
+		t.method = http.MethodGet
+		if cfg.Body != "" {
+			t.method = http.MethodPost
+		}
+	}
+	// Only reads are medium risk by default: anything else may change
+	// things, so it needs approval and doesn't run in dry runs
+	t.risk = RiskHigh
+	if t.method == http.MethodGet || t.method == http.MethodHead {
+		t.risk = RiskMedium
+	}
+	if risk, ok := ParseRiskLevel(cfg.Risk); ok {
+		t.risk = risk
+	}
+	if t.maxChars <= 0 {
+		t.maxChars = webhookMaxChars
+	}
+	if t.client.Timeout <= 0 {
+		t.client.Timeout = webhookTimeout
+	}
+
+	var err error
+	if t.url, err = parseWebhookTemplate("url", cfg.URL); err != nil {
+		return nil, err
+	}
+	for key, value := range cfg.Headers {
+		if t.headers[key], err = parseWebhookTemplate("header "+key, value); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Body != "" {
+		if t.body, err = parseWebhookTemplate("body", cfg.Body); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func parseWebhookTemplate(what, text string) (*template.Template, error) {
+	tmpl, err := template.New(what).Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", what, err)
+	}
+	return tmpl, nil
+}
+
+func (t *WebhookTool) Name() string {
+	return t.name
+}
+
+func (t *WebhookTool) Risk() RiskLevel {
+	return t.risk
+}
+
+func (t *WebhookTool) Description() string {
+	return t.description
+}
+
+func (t *WebhookTool) Parameters() map[string]any {
+	return t.parameters
+}
+
+func (t *WebhookTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	// Arguments left out render as empty rather than "<no value>"
+	data := make(map[string]any, len(args))
+	if properties, ok := t.parameters["properties"].(map[string]any); ok {
+		for name := range properties {
+			data[name] = ""
+		}
+	}
+	for name, value := range args {
+		data[name] = value
+	}
+
+	target, err := renderWebhookTemplate(t.url, data)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrorResult(fmt.Sprintf("%s: %q is not an http(s) URL", t.name, target))
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch {
+	case t.body != nil:
+		rendered, err := renderWebhookTemplate(t.body, data)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		body = strings.NewReader(rendered)
+		if trimmed := strings.TrimSpace(rendered); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			contentType = "application/json"
+		}
+	case t.method != http.MethodGet && t.method != http.MethodDelete:
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to encode arguments: %v", err))
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, t.method, target, body)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create request: %v", err))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, tmpl := range t.headers {
+		value, err := renderWebhookTemplate(tmpl, data)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s request failed: %v", t.name, err)).WithError(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBytes))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read the response: %v", err)).WithError(err)
+	}
+
+	content := strings.TrimSpace(string(respBody))
+	if truncated := utils.Truncate(content, t.maxChars); truncated != content {
+		content = truncated + "\n[response truncated]"
+	}
+	if resp.StatusCode >= 400 {
+		return ErrorResult(fmt.Sprintf("%s returned %s: %s", t.name, resp.Status, content))
+	}
+	if content == "" {
+		content = fmt.Sprintf("%s returned %s with no content", t.name, resp.Status)
+	}
+	return NewToolResult(content)
+}
+
+func renderWebhookTemplate(tmpl *template.Template, data map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render the %s: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestWebhookTool(t *testing.T) {
+	var method, path, auth, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.RequestURI(), string(data)
+		auth, contentType = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		if strings.Contains(path, "missing") {
+			http.Error(w, "no such city", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	t.Setenv("WEBHOOK_TEST_TOKEN", "secret")
+	ctx := context.Background()
+
+	lookup, err := NewWebhookTool("city_info", config.WebhookToolConfig{
+		Description: "Looks up a city",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}, "lang": map[string]any{}},
+			"required":   []any{"city"},
+		},
+		URL:     server.URL + "/cities/{{urlquery .city}}?lang={{.lang}}",
+		Headers: map[string]string{"Authorization": `Bearer {{env "WEBHOOK_TEST_TOKEN"}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := lookup.Execute(ctx, map[string]any{"city": "São Paulo"})
+	if result.IsError || result.ForLLM != `{"ok":true}` {
+		t.Fatalf("Execute() = %+v", result)
+	}
+	if method != http.MethodGet || path != "/cities/S%C3%A3o+Paulo?lang=" || auth != "Bearer secret" || body != "" {
+		t.Errorf("request = %s %s, auth %q, body %q", method, path, auth, body)
+	}
+	if lookup.Risk() != RiskMedium {
+		t.Errorf("GET webhook risk = %v, want medium", lookup.Risk())
+	}
+	if result := lookup.Execute(ctx, map[string]any{"city": "missing"}); !result.IsError ||
+		!strings.Contains(result.ForLLM, "404") || !strings.Contains(result.ForLLM, "no such city") {
+		t.Errorf("Execute() on a 404 = %+v", result)
+	}
+
+	// Without a body template, the arguments are sent as JSON
+	notify, err := NewWebhookTool("notify", config.WebhookToolConfig{
+		Description: "Sends a notification",
+		Method:      "put",
+		URL:         server.URL + "/notify",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notify.Risk() != RiskHigh {
+		t.Errorf("PUT webhook risk = %v, want high", notify.Risk())
+	}
+	notify.Execute(ctx, map[string]any{"text": "hi", "priority": 2.0})
+	var sent map[string]any
+	if err := json.Unmarshal([]byte(body), &sent); err != nil || sent["text"] != "hi" || method != http.MethodPut ||
+		contentType != "application/json" {
+		t.Errorf("request = %s with %q (%s)", method, body, contentType)
+	}
+
+	templated, err := NewWebhookTool("log", config.WebhookToolConfig{
+		Description: "Logs an entry",
+		URL:         server.URL + "/log",
+		Body:        `{"entry": {{json .text}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	templated.Execute(ctx, map[string]any{"text": `say "hi"`})
+	if method != http.MethodPost || body != `{"entry": "say \"hi\""}` {
+		t.Errorf("request = %s with %q", method, body)
+	}
+
+	if _, err := NewWebhookTool("broken", config.WebhookToolConfig{URL: "http://x/{{.city"}); err == nil {
+		t.Error("NewWebhookTool() accepted a broken template")
+	}
+}
@@ -0,0 +1,171 @@
+// Package tunnel runs an outbound tunnel client (cloudflared, ngrok or frpc)
+// so webhook channels can receive callbacks without port forwarding.
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// provider describes how to run one tunnel client and find its public URL.
+type provider struct {
+	binary string
+	args   func(cfg config.TunnelConfig, localURL string) []string
+	// urlPattern matches the public URL in the client's output; nil when the
+	// URL is fixed by config
+	urlPattern *regexp.Regexp
+	// perPort is false for clients that expose every channel with one process
+	perPort bool
+}
+
+var providers = map[string]provider{
+	"cloudflared": {
+		binary: "cloudflared",
+		args: func(_ config.TunnelConfig, localURL string) []string {
+			args := []string{"tunnel", "--no-autoupdate", "--url", localURL}
+			if strings.HasPrefix(localURL, "https://") {
+				args = append(args, "--no-tls-verify")
+			}
+			return args
+		},
+		urlPattern: regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`),
+		perPort:    true,
+	},
+	"ngrok": {
+		binary: "ngrok",
+		args: func(_ config.TunnelConfig, localURL string) []string {
+			return []string{"http", localURL, "--log", "stdout", "--log-format", "json"}
+		},
+		urlPattern: regexp.MustCompile(`"url":"(https://[^"]+)"`),
+		perPort:    true,
+	},
+	"frp": {
+		binary: "frpc",
+		args: func(cfg config.TunnelConfig, _ string) []string {
+			return []string{"-c", cfg.FRPConfig}
+		},
+		perPort: false,
+	},
+}
+
+// Tunnel is one tunnel client process forwarding to a local URL.
+type Tunnel struct {
+	cfg      config.TunnelConfig
+	provider provider
+	localURL string
+}
+
+// New returns a tunnel to localURL for the configured provider.
+func New(cfg config.TunnelConfig, localURL string) (*Tunnel, error) {
+	p, ok := providers[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown tunnel provider %q", cfg.Provider)
+	}
+	if p.urlPattern == nil && cfg.PublicURL == "" {
+		return nil, fmt.Errorf("tunnel provider %q requires public_url", cfg.Provider)
+	}
+	if cfg.Provider == "frp" && cfg.FRPConfig == "" {
+		return nil, errors.New("tunnel provider \"frp\" requires frp_config")
+	}
+	return &Tunnel{cfg: cfg, provider: p, localURL: localURL}, nil
+}
+
+// PerPort reports whether the provider needs one tunnel per local port. When
+// false, a single tunnel serves all channels under public_url.
+func PerPort(providerName string) bool {
+	return providers[providerName].perPort
+}
+
+// Run starts the client and blocks until it exits or ctx is cancelled.
+// onURL is called with the public base URL once the tunnel is up.
+func (t *Tunnel) Run(ctx context.Context, onURL func(publicURL string)) error {
+	binary := t.cfg.Binary
+	if binary == "" {
+		binary = t.provider.binary
+	}
+
+	cmd := exec.CommandContext(ctx, binary, t.provider.args(t.cfg, t.localURL)...)
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Env = os.Environ()
+	if t.cfg.AuthToken != "" {
+		cmd.Env = append(cmd.Env, "NGROK_AUTHTOKEN="+t.cfg.AuthToken)
+	}
+
+	output, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", binary, err)
+	}
+	logger.InfoCF("tunnel", "Tunnel client started", map[string]any{
+		"provider": t.cfg.Provider,
+		"local":    t.localURL,
+		"pid":      cmd.Process.Pid,
+	})
+
+	if t.provider.urlPattern == nil {
+		onURL(strings.TrimRight(t.cfg.PublicURL, "/"))
+	}
+
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		t.scanOutput(output, onURL)
+	}()
+
+	err := cmd.Wait()
+	writer.Close()
+	<-scanned
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s exited: %w", binary, err)
+	}
+	return fmt.Errorf("%s exited", binary)
+}
+
+// scanOutput logs the client's output and reports the first public URL found.
+func (t *Tunnel) scanOutput(output io.Reader, onURL func(publicURL string)) {
+	found := t.provider.urlPattern == nil
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := scanner.Text()
+		logger.DebugCF("tunnel", line, map[string]any{"provider": t.cfg.Provider})
+
+		if found {
+			continue
+		}
+		if url := findURL(t.provider.urlPattern, line); url != "" {
+			found = true
+			onURL(url)
+		}
+	}
+	// Keep draining if the scanner stopped on an overlong line
+	io.Copy(io.Discard, output)
+}
+
+func findURL(pattern *regexp.Regexp, line string) string {
+	match := pattern.FindStringSubmatch(line)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return match[0]
+	default:
+		return match[1]
+	}
+}
@@ -0,0 +1,106 @@
+package tunnel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeClient writes a script that prints output and then waits, standing in
+// for a tunnel client binary.
+func fakeClient(t *testing.T, output string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "client")
+	script := "#!/bin/sh\nprintf '%s\\n' '" + output + "'\nexec sleep 30\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runUntilURL(t *testing.T, tun *Tunnel) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	urls := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- tun.Run(ctx, func(url string) { urls <- url })
+	}()
+
+	select {
+	case url := <-urls:
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() after cancel = %v, want nil", err)
+		}
+		return url
+	case err := <-done:
+		t.Fatalf("Run() returned before reporting a URL: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for public URL")
+	}
+	return ""
+}
+
+func TestRunReportsPublicURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.TunnelConfig
+		output string
+		want   string
+	}{
+		{
+			name:   "cloudflared",
+			cfg:    config.TunnelConfig{Provider: "cloudflared"},
+			output: "INF |  https://quiet-lake-1234.trycloudflare.com  |",
+			want:   "https://quiet-lake-1234.trycloudflare.com",
+		},
+		{
+			name:   "ngrok",
+			cfg:    config.TunnelConfig{Provider: "ngrok"},
+			output: `{"lvl":"info","msg":"started tunnel","url":"https://ab12.ngrok-free.app"}`,
+			want:   "https://ab12.ngrok-free.app",
+		},
+		{
+			name: "frp",
+			cfg: config.TunnelConfig{
+				Provider:  "frp",
+				FRPConfig: "frpc.toml",
+				PublicURL: "https://bot.example.com/",
+			},
+			output: "start proxy success",
+			want:   "https://bot.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Binary = fakeClient(t, tt.output)
+			tun, err := New(tt.cfg, "http://127.0.0.1:18791")
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := runUntilURL(t, tun); got != tt.want {
+				t.Errorf("public URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, cfg := range []config.TunnelConfig{
+		{Provider: "unknown"},
+		{Provider: "frp", PublicURL: "https://bot.example.com"},
+		{Provider: "frp", FRPConfig: "frpc.toml"},
+	} {
+		if _, err := New(cfg, ""); err == nil {
+			t.Errorf("New(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package userprofile keeps what the agent has learned about each person it
+// talks to: their name, time zone, preferences and standing instructions.
+// Every person has a markdown file of their own in a directory of the
+// workspace, so one user's profile never leaks into another's chats and the
+// files stay readable and editable by hand.
+package userprofile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	preferencesHeading  = "## Preferences"
+	instructionsHeading = "## Standing instructions"
+)
+
+var reUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Profile is one person's profile.
+type Profile struct {
+	Name         string
+	Timezone     string // IANA name, e.g. Europe/Berlin
+	Preferences  []string
+	Instructions []string // Things to always or never do for them
+}
+
+// Empty reports whether nothing is known about the person.
+func (p *Profile) Empty() bool {
+	return p.Name == "" && p.Timezone == "" && len(p.Preferences) == 0 && len(p.Instructions) == 0
+}
+
+// Markdown is the profile as stored, without the title line.
+func (p *Profile) Markdown() string {
+	var sb strings.Builder
+	if p.Name != "" {
+		fmt.Fprintf(&sb, "Name: %s\n", p.Name)
+	}
+	if p.Timezone != "" {
+		fmt.Fprintf(&sb, "Timezone: %s\n", p.Timezone)
+	}
+	for _, section := range []struct {
+		heading string
+		items   []string
+	}{{preferencesHeading, p.Preferences}, {instructionsHeading, p.Instructions}} {
+		if len(section.items) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(section.heading + "\n")
+		for _, item := range section.items {
+			sb.WriteString("- " + item + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Parse reads a profile from its markdown. Lines it doesn't recognize are
+// skipped.
+func Parse(text string) *Profile {
+	p := &Profile{}
+	var list *[]string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.EqualFold(line, preferencesHeading):
+			list = &p.Preferences
+		case strings.EqualFold(line, instructionsHeading):
+			list = &p.Instructions
+		case strings.HasPrefix(line, "#"):
+			list = nil
+		case list != nil && strings.HasPrefix(line, "- "):
+			if item := strings.TrimSpace(line[2:]); item != "" {
+				*list = append(*list, item)
+			}
+		case list == nil:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "name":
+				p.Name = strings.TrimSpace(value)
+			case "timezone":
+				p.Timezone = strings.TrimSpace(value)
+			}
+		}
+	}
+	return p
+}
+
+// Store reads and writes the profiles in a directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore returns the store for dir, which is created on the first write.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Path returns the file of the profile of userID.
+func (s *Store) Path(userID string) string {
+	name := strings.Trim(reUnsafe.ReplaceAllString(strings.ToLower(userID), "_"), "._")
+	return filepath.Join(s.dir, name+".md")
+}
+
+// Get returns the profile of userID, which is empty when there is none yet.
+func (s *Store) Get(userID string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(userID)
+}
+
+func (s *Store) read(userID string) (*Profile, error) {
+	data, err := os.ReadFile(s.Path(userID))
+	if os.IsNotExist(err) {
+		return &Profile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data)), nil
+}
+
+// Update applies fn to the profile of userID and saves the result.
+func (s *Store) Update(userID string, fn func(p *Profile) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.read(userID)
+	if err != nil {
+		return err
+	}
+	if err := fn(p); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("# Profile of %s\n\n%s", userID, p.Markdown())
+	return os.WriteFile(s.Path(userID), []byte(content), 0o644)
+}
+
+// Remove drops the item of list that matches item, ignoring case, and
+// reports whether there was one.
+func Remove(list *[]string, item string) bool {
+	i := slices.IndexFunc(*list, func(s string) bool { return strings.EqualFold(s, strings.TrimSpace(item)) })
+	if i < 0 {
+		return false
+	}
+	*list = slices.Delete(*list, i, i+1)
+	return true
+}
@@ -0,0 +1,59 @@
+package userprofile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "users"))
+
+	p, err := s.Get("telegram:123")
+	if err != nil || !p.Empty() {
+		t.Fatalf("Get before any update = %+v, %v; want an empty profile", p, err)
+	}
+
+	err = s.Update("telegram:123", func(p *Profile) error {
+		p.Name = "Sam"
+		p.Timezone = "Europe/Berlin"
+		p.Preferences = append(p.Preferences, "metric units", "short answers")
+		p.Instructions = append(p.Instructions, "Always answer in German")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(s.Path("telegram:123")) != "telegram_123.md" {
+		t.Errorf("Path = %s", s.Path("telegram:123"))
+	}
+	want := &Profile{
+		Name:         "Sam",
+		Timezone:     "Europe/Berlin",
+		Preferences:  []string{"metric units", "short answers"},
+		Instructions: []string{"Always answer in German"},
+	}
+	if p, _ := s.Get("telegram:123"); !reflect.DeepEqual(p, want) {
+		t.Errorf("Get = %+v, want %+v", p, want)
+	}
+	if p, _ := s.Get("telegram:456"); !p.Empty() {
+		t.Errorf("another user's profile = %+v, want empty", p)
+	}
+
+	// Edits by hand are read back
+	data, _ := os.ReadFile(s.Path("telegram:123"))
+	edited := string(data) + "- no emoji\n\n## Notes\n- ignored\n"
+	os.WriteFile(s.Path("telegram:123"), []byte(edited), 0o644)
+	p, _ = s.Get("telegram:123")
+	if len(p.Instructions) != 2 || p.Instructions[1] != "no emoji" {
+		t.Errorf("instructions after a hand edit = %q", p.Instructions)
+	}
+
+	if !Remove(&p.Preferences, " Metric Units") || len(p.Preferences) != 1 {
+		t.Errorf("Remove left %q", p.Preferences)
+	}
+	if Remove(&p.Preferences, "tea") {
+		t.Error("Remove of a missing item reported true")
+	}
+}
@@ -0,0 +1,65 @@
+// Package usertime keeps track of the time zone of the user a turn is for,
+// so that times the agent reads ("tomorrow at 9") and shows are in the
+// user's time rather than the server's. The agent puts the user's zone in
+// the context of a turn; tools read it from there.
+package usertime
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var reOffset = regexp.MustCompile(`^(?i:UTC|GMT)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+type locationKey struct{}
+
+// WithLocation returns a context whose times are in loc.
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	if loc == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// Location returns the time zone of the user ctx is for, or the server's
+// when it isn't known.
+func Location(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(locationKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.Local
+}
+
+// Now returns the current time in the user's time zone.
+func Now(ctx context.Context) time.Time {
+	return time.Now().In(Location(ctx))
+}
+
+// Load returns the time zone called name: an IANA name such as
+// "Europe/Berlin", "UTC", "Local", or a fixed offset such as "UTC+2",
+// "GMT-03:30" or "+0530".
+func Load(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	m := reOffset.FindStringSubmatch(name)
+	if m == nil {
+		return time.LoadLocation(name)
+	}
+	hours, _ := strconv.Atoi(m[2])
+	minutes := 0
+	if m[3] != "" {
+		minutes, _ = strconv.Atoi(m[3])
+	}
+	if hours > 14 || minutes > 59 {
+		return nil, fmt.Errorf("invalid UTC offset %q", name)
+	}
+	offset := hours*3600 + minutes*60
+	label := fmt.Sprintf("UTC%s%02d:%02d", m[1], hours, minutes)
+	if m[1] == "-" {
+		offset = -offset
+	}
+	return time.FixedZone(label, offset), nil
+}
@@ -0,0 +1,56 @@
+package usertime
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantOffset int // seconds east of UTC in January
+		wantErr    bool
+	}{
+		{name: "UTC"},
+		{name: "Asia/Tokyo", wantOffset: 9 * 3600},
+		{name: "UTC+2", wantOffset: 2 * 3600},
+		{name: "gmt-03:30", wantOffset: -(3*3600 + 30*60)},
+		{name: "+0530", wantOffset: 5*3600 + 30*60},
+		{name: "UTC+15", wantErr: true},
+		{name: "Mars/Olympus", wantErr: true},
+	}
+	january := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		loc, err := Load(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Load(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, offset := january.In(loc).Zone(); offset != tt.wantOffset {
+			t.Errorf("Load(%q) offset = %d, want %d", tt.name, offset, tt.wantOffset)
+		}
+	}
+
+	// A fixed offset's name loads back to the same zone, so it can be stored
+	loc, _ := Load("UTC+2")
+	again, err := Load(loc.String())
+	if err != nil || january.In(again).Format(time.RFC3339) != january.In(loc).Format(time.RFC3339) {
+		t.Errorf("Load(%q) = %v, %v", loc.String(), again, err)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	ctx := context.Background()
+	if Location(ctx) != time.Local {
+		t.Errorf("Location() without a zone = %v", Location(ctx))
+	}
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	ctx = WithLocation(ctx, tokyo)
+	if Location(ctx) != tokyo || Now(ctx).Location() != tokyo {
+		t.Errorf("Location() = %v, want Asia/Tokyo", Location(ctx))
+	}
+}
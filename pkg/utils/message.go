@@ -2,9 +2,12 @@ package utils
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 // SplitMessage splits long messages into chunks, preserving code block integrity.
+// Chunks end at a paragraph break when one is near the limit, then at a line
+// break or space, and never in the middle of a UTF-8 character.
 // The function reserves a buffer (10% of maxLen, min 50) to leave room for closing code blocks,
 // but may extend to maxLen when needed.
 // Call SplitMessage with the full text content and the maximum allowed length of a single message;
@@ -34,12 +37,15 @@ func SplitMessage(content string, maxLen int) []string {
 		}
 
 		// Find natural split point within the effective limit
-		msgEnd := findLastNewline(content[:effectiveLimit], 200)
+		msgEnd := findLastParagraph(content[:effectiveLimit], effectiveLimit/3)
+		if msgEnd <= 0 {
+			msgEnd = findLastNewline(content[:effectiveLimit], 200)
+		}
 		if msgEnd <= 0 {
 			msgEnd = findLastSpace(content[:effectiveLimit], 100)
 		}
 		if msgEnd <= 0 {
-			msgEnd = effectiveLimit
+			msgEnd = runeStart(content, effectiveLimit)
 		}
 
 		// Check if this would end with an incomplete code block
@@ -73,7 +79,7 @@ func SplitMessage(content string, maxLen int) []string {
 						if betterEnd > headerEnd {
 							msgEnd = betterEnd
 						} else {
-							msgEnd = innerLimit
+							msgEnd = runeStart(content, innerLimit)
 						}
 						messages = append(messages, strings.TrimRight(content[:msgEnd], " \t\n\r")+"\n```")
 						content = strings.TrimSpace(header + "\n" + content[msgEnd:])
@@ -92,7 +98,7 @@ func SplitMessage(content string, maxLen int) []string {
 						if unclosedIdx > 20 {
 							msgEnd = unclosedIdx
 						} else {
-							msgEnd = maxLen - 5
+							msgEnd = runeStart(content, maxLen-5)
 							messages = append(messages, strings.TrimRight(content[:msgEnd], " \t\n\r")+"\n```")
 							content = strings.TrimSpace(header + "\n" + content[msgEnd:])
 							continue
@@ -103,7 +109,7 @@ func SplitMessage(content string, maxLen int) []string {
 		}
 
 		if msgEnd <= 0 {
-			msgEnd = effectiveLimit
+			msgEnd = runeStart(content, effectiveLimit)
 		}
 
 		messages = append(messages, content[:msgEnd])
@@ -148,6 +154,33 @@ func findNextClosingCodeBlock(text string, startIdx int) int {
 	return -1
 }
 
+// findLastParagraph finds the last blank line within the last N characters
+// Returns the position of the paragraph break or -1 if not found
+func findLastParagraph(s string, searchWindow int) int {
+	searchStart := len(s) - searchWindow
+	if searchStart < 0 {
+		searchStart = 0
+	}
+	if idx := strings.LastIndex(s[searchStart:], "\n\n"); idx >= 0 {
+		return searchStart + idx
+	}
+	return -1
+}
+
+// runeStart moves i back to the start of the UTF-8 character it falls in, so
+// a cut at i doesn't split a multi-byte character. Invalid UTF-8 with no
+// character start before i is cut at i.
+func runeStart(s string, i int) int {
+	j := i
+	for j > 0 && j < len(s) && !utf8.RuneStart(s[j]) {
+		j--
+	}
+	if j == 0 {
+		return i
+	}
+	return j
+}
+
 // findLastNewline finds the last newline character within the last N characters
 // Returns the position of the newline or -1 if not found
 func findLastNewline(s string, searchWindow int) int {
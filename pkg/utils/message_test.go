@@ -3,6 +3,7 @@ package utils
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestSplitMessage(t *testing.T) {
@@ -149,3 +150,36 @@ func TestSplitMessage_CodeBlockIntegrity(t *testing.T) {
 		t.Errorf("First chunk exceeded maxLen: length %d", len(chunks[0]))
 	}
 }
+
+func TestSplitMessage_PrefersParagraphs(t *testing.T) {
+	para1 := strings.Repeat("word ", 300) // 1500 chars
+	para2 := strings.Repeat("next ", 60) + "\n" + strings.Repeat("line ", 100)
+	chunks := SplitMessage(para1+"\n\n"+para2, 2000)
+
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0] != para1 {
+		t.Errorf("First chunk should end at the paragraph break, got length %d", len(chunks[0]))
+	}
+	if !strings.HasPrefix(chunks[1], "next") {
+		t.Errorf("Second chunk should start the next paragraph, got %q", chunks[1][:20])
+	}
+}
+
+func TestSplitMessage_KeepsRunesWhole(t *testing.T) {
+	chunks := SplitMessage(strings.Repeat("\u4e16", 1000), 2000)
+	total := 0
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Chunk %d splits a UTF-8 character", i)
+		}
+		if len(chunk) > 2000 {
+			t.Errorf("Chunk %d too large: %d", i, len(chunk))
+		}
+		total += utf8.RuneCountInString(chunk)
+	}
+	if total != 1000 {
+		t.Errorf("Expected 1000 characters across chunks, got %d", total)
+	}
+}
@@ -0,0 +1,264 @@
+// Package vectorstore keeps texts with their embeddings in a local file and
+// finds the ones closest to a query vector.
+//
+// Records are held in memory and searched by brute force, which is fast
+// enough for the few thousand entries a personal assistant collects.
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Vector is an embedding. It is stored as base64 little-endian float32s,
+// about a third the size of a JSON number list.
+type Vector []float32
+
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(buf)%4 != 0 {
+		return errors.New("vector length is not a multiple of 4 bytes")
+	}
+	out := make(Vector, len(buf)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	*v = out
+	return nil
+}
+
+// Record is a stored text and its embedding.
+type Record struct {
+	ID      string            `json:"id"`
+	Kind    string            `json:"kind,omitempty"`
+	Source  string            `json:"source,omitempty"`
+	Text    string            `json:"text"`
+	Meta    map[string]string `json:"meta,omitempty"`
+	Created time.Time         `json:"created"`
+	Vector  Vector            `json:"vector"`
+}
+
+// Match is a search result.
+type Match struct {
+	Record
+	Score float64 // cosine similarity, 1 for identical directions
+}
+
+// Store holds records in a JSON Lines file. Adds are appended; deletes
+// rewrite the file.
+type Store struct {
+	path    string
+	mu      sync.RWMutex
+	records []Record
+}
+
+// Open loads the store at path, which is created on the first add.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		s.records = append(s.records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Len returns the number of records.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Add stores records, filling in missing IDs and creation times.
+func (s *Store) Add(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for i := range records {
+		if records[i].ID == "" {
+			records[i].ID = newID()
+		}
+		if records[i].Created.IsZero() {
+			records[i].Created = time.Now()
+		}
+		data, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Delete removes the records for which match returns true and reports how
+// many were removed.
+func (s *Store) Delete(match func(Record) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		if !match(r) {
+			kept = append(kept, r)
+		}
+	}
+	removed := len(s.records) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := s.rewrite(kept); err != nil {
+		return 0, err
+	}
+	s.records = kept
+	return removed, nil
+}
+
+// rewrite replaces the file with records atomically; callers hold mu.
+func (s *Store) rewrite(records []Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Each calls fn for every record, in the order they were added.
+func (s *Store) Each(fn func(Record)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.records {
+		fn(r)
+	}
+}
+
+// Search returns up to k records most similar to query that score at least
+// minScore, best first. A nil filter accepts every record.
+func (s *Store) Search(query []float32, k int, minScore float64, filter func(Record) bool) []Match {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []Match
+	for _, r := range s.records {
+		if filter != nil && !filter(r) {
+			continue
+		}
+		score := Cosine(query, r.Vector)
+		if score < minScore {
+			continue
+		}
+		matches = append(matches, Match{Record: r, Score: score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// Cosine returns the cosine similarity of a and b, or 0 when their lengths
+// differ or either is zero.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
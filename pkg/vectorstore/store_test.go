@@ -0,0 +1,65 @@
+package vectorstore
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_AddSearchReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "store.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	err = s.Add(
+		Record{Kind: "fact", Text: "likes tea", Vector: Vector{1, 0, 0}},
+		Record{Kind: "fact", Text: "lives in Oslo", Vector: Vector{0, 1, 0}},
+		Record{Kind: "summary", Text: "talked about tea", Vector: Vector{0.9, 0.1, 0}},
+	)
+	if err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	matches := s.Search([]float32{1, 0, 0}, 2, 0.5, nil)
+	if len(matches) != 2 || matches[0].Text != "likes tea" || matches[1].Text != "talked about tea" {
+		t.Fatalf("Search() = %+v", matches)
+	}
+	if math.Abs(matches[0].Score-1) > 1e-6 {
+		t.Errorf("exact match score = %v", matches[0].Score)
+	}
+	facts := s.Search([]float32{1, 0, 0}, 5, 0, func(r Record) bool { return r.Kind == "fact" })
+	if len(facts) != 2 || facts[0].ID == "" || facts[0].Created.IsZero() {
+		t.Errorf("filtered Search() = %+v", facts)
+	}
+
+	removed, err := s.Delete(func(r Record) bool { return r.Kind == "summary" })
+	if err != nil || removed != 1 {
+		t.Fatalf("Delete() = %d, %v", removed, err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if reopened.Len() != 2 {
+		t.Fatalf("reopened store has %d records, want 2", reopened.Len())
+	}
+	got := reopened.Search([]float32{0, 1, 0}, 1, 0.5, nil)
+	if len(got) != 1 || got[0].Text != "lives in Oslo" || got[0].Vector[1] != 1 {
+		t.Errorf("Search() after reopen = %+v", got)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := Cosine([]float32{1, 1}, []float32{-1, -1}); math.Abs(got+1) > 1e-9 {
+		t.Errorf("opposite vectors = %v, want -1", got)
+	}
+	if got := Cosine([]float32{1}, []float32{1, 0}); got != 0 {
+		t.Errorf("mismatched lengths = %v, want 0", got)
+	}
+	if got := Cosine([]float32{0, 0}, []float32{1, 0}); got != 0 {
+		t.Errorf("zero vector = %v, want 0", got)
+	}
+}
@@ -0,0 +1,60 @@
+package voice
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// NewTranscriber builds the transcriber selected in cfg.Voice. With no
+// provider set, Groq is used when a Groq API key is configured. It returns
+// nil when transcription is not configured.
+func NewTranscriber(cfg *config.Config) (Transcriber, error) {
+	v := cfg.Voice
+	language := v.Language
+	if language == "auto" {
+		language = ""
+	}
+
+	switch v.Provider {
+	case "":
+		if cfg.Providers.Groq.APIKey == "" {
+			return nil, nil
+		}
+		return NewGroqTranscriber(cfg.Providers.Groq.APIKey), nil
+	case "groq":
+		apiKey := firstNonEmpty(v.APIKey, cfg.Providers.Groq.APIKey)
+		apiBase := firstNonEmpty(v.APIBase, "https://api.groq.com/openai/v1")
+		return NewAPITranscriber("Groq", apiKey, apiBase, firstNonEmpty(v.Model, "whisper-large-v3"), language), nil
+	case "openai":
+		apiKey := firstNonEmpty(v.APIKey, cfg.Providers.OpenAI.APIKey)
+		apiBase := firstNonEmpty(v.APIBase, "https://api.openai.com/v1")
+		return NewAPITranscriber("OpenAI", apiKey, apiBase, firstNonEmpty(v.Model, "whisper-1"), language), nil
+	case "bedrock":
+		// Bedrock has no native transcription endpoint; it is reached through
+		// an OpenAI-compatible gateway serving a Whisper model
+		if v.APIBase == "" {
+			return nil, fmt.Errorf("voice provider %q requires api_base", v.Provider)
+		}
+		model := firstNonEmpty(v.Model, "whisper-large-v3-turbo")
+		t := NewAPITranscriber("Bedrock", v.APIKey, v.APIBase, model, language)
+		t.keyless = true
+		return t, nil
+	case "whisper_cpp":
+		if v.WhisperCppModel == "" {
+			return nil, fmt.Errorf("voice provider %q requires whisper_cpp_model", v.Provider)
+		}
+		return NewWhisperCppTranscriber(v.WhisperCppBinary, v.WhisperCppModel, language), nil
+	default:
+		return nil, fmt.Errorf("unknown voice provider %q", v.Provider)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -10,15 +10,29 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-type GroqTranscriber struct {
+// Transcriber converts a voice recording to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error)
+	IsAvailable() bool
+}
+
+// APITranscriber transcribes through an OpenAI-compatible
+// /audio/transcriptions endpoint (OpenAI, Groq, or a gateway in front of
+// another provider).
+type APITranscriber struct {
+	name       string
 	apiKey     string
 	apiBase    string
+	model      string
+	language   string // empty lets the model detect it
+	keyless    bool   // endpoint needs no API key, e.g. a Bedrock gateway
 	httpClient *http.Client
 }
 
@@ -28,20 +42,31 @@ type TranscriptionResponse struct {
 	Duration float64 `json:"duration,omitempty"`
 }
 
-func NewGroqTranscriber(apiKey string) *GroqTranscriber {
-	logger.DebugCF("voice", "Creating Groq transcriber", map[string]any{"has_api_key": apiKey != ""})
+func NewGroqTranscriber(apiKey string) *APITranscriber {
+	return NewAPITranscriber("Groq", apiKey, "https://api.groq.com/openai/v1", "whisper-large-v3", "")
+}
+
+// NewAPITranscriber creates a transcriber for an OpenAI-compatible API.
+func NewAPITranscriber(name, apiKey, apiBase, model, language string) *APITranscriber {
+	logger.DebugCF("voice", "Creating API transcriber", map[string]any{
+		"provider":    name,
+		"model":       model,
+		"has_api_key": apiKey != "",
+	})
 
-	apiBase := "https://api.groq.com/openai/v1"
-	return &GroqTranscriber{
-		apiKey:  apiKey,
-		apiBase: apiBase,
+	return &APITranscriber{
+		name:     name,
+		apiKey:   apiKey,
+		apiBase:  strings.TrimRight(apiBase, "/"),
+		model:    model,
+		language: language,
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 	}
 }
 
-func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+func (t *APITranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
 	logger.InfoCF("voice", "Starting transcription", map[string]any{"audio_file": audioFilePath})
 
 	audioFile, err := os.Open(audioFilePath)
@@ -79,12 +104,24 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 
 	logger.DebugCF("voice", "File copied to request", map[string]any{"bytes_copied": copied})
 
-	if err = writer.WriteField("model", "whisper-large-v3"); err != nil {
+	if err = writer.WriteField("model", t.model); err != nil {
 		logger.ErrorCF("voice", "Failed to write model field", map[string]any{"error": err})
 		return nil, fmt.Errorf("failed to write model field: %w", err)
 	}
 
-	if err = writer.WriteField("response_format", "json"); err != nil {
+	if t.language != "" {
+		if err = writer.WriteField("language", t.language); err != nil {
+			logger.ErrorCF("voice", "Failed to write language field", map[string]any{"error": err})
+			return nil, fmt.Errorf("failed to write language field: %w", err)
+		}
+	}
+
+	// Whisper models report the detected language only in verbose_json
+	responseFormat := "json"
+	if strings.HasPrefix(t.model, "whisper") {
+		responseFormat = "verbose_json"
+	}
+	if err = writer.WriteField("response_format", responseFormat); err != nil {
 		logger.ErrorCF("voice", "Failed to write response_format field", map[string]any{"error": err})
 		return nil, fmt.Errorf("failed to write response_format field: %w", err)
 	}
@@ -102,9 +139,12 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
 
-	logger.DebugCF("voice", "Sending transcription request to Groq API", map[string]any{
+	logger.DebugCF("voice", "Sending transcription request", map[string]any{
+		"provider":           t.name,
 		"url":                url,
 		"request_size_bytes": requestBody.Len(),
 		"file_size_bytes":    fileInfo.Size(),
@@ -131,7 +171,7 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
-	logger.DebugCF("voice", "Received response from Groq API", map[string]any{
+	logger.DebugCF("voice", "Received transcription response", map[string]any{
 		"status_code":         resp.StatusCode,
 		"response_size_bytes": len(body),
 	})
@@ -152,8 +192,8 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	return &result, nil
 }
 
-func (t *GroqTranscriber) IsAvailable() bool {
-	available := t.apiKey != ""
+func (t *APITranscriber) IsAvailable() bool {
+	available := t.apiKey != "" || (t.keyless && t.apiBase != "")
 	logger.DebugCF("voice", "Checking transcriber availability", map[string]any{"available": available})
 	return available
 }
@@ -0,0 +1,112 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestAPITranscriberSendsModelAndLanguage(t *testing.T) {
+	var got map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		got = map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			got[k] = v[0]
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(TranscriptionResponse{Text: "hola", Language: "spanish"})
+	}))
+	defer server.Close()
+
+	audio := filepath.Join(t.TempDir(), "note.ogg")
+	os.WriteFile(audio, []byte("OggS"), 0o600)
+
+	tr := NewAPITranscriber("OpenAI", "key", server.URL+"/v1/", "whisper-1", "es")
+	result, err := tr.Transcribe(context.Background(), audio)
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if result.Text != "hola" || result.Language != "spanish" {
+		t.Errorf("result = %+v", result)
+	}
+	if got["model"] != "whisper-1" || got["language"] != "es" || got["response_format"] != "verbose_json" {
+		t.Errorf("form fields = %v", got)
+	}
+	if auth != "Bearer key" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	// Without a language the field is omitted so the model detects it
+	tr = NewAPITranscriber("OpenAI", "key", server.URL+"/v1", "gpt-4o-transcribe", "")
+	tr.Transcribe(context.Background(), audio)
+	if _, ok := got["language"]; ok || got["response_format"] != "json" {
+		t.Errorf("form fields = %v", got)
+	}
+}
+
+func TestNewTranscriber(t *testing.T) {
+	tests := []struct {
+		name      string
+		voice     config.VoiceConfig
+		groqKey   string
+		wantNil   bool
+		wantErr   bool
+		available bool
+	}{
+		{name: "not configured", wantNil: true},
+		{name: "groq fallback", groqKey: "gsk", available: true},
+		{name: "openai without key", voice: config.VoiceConfig{Provider: "openai"}},
+		{name: "openai", voice: config.VoiceConfig{Provider: "openai", APIKey: "sk"}, available: true},
+		{
+			name:      "bedrock gateway",
+			voice:     config.VoiceConfig{Provider: "bedrock", APIBase: "http://gateway/v1"},
+			available: true,
+		},
+		{name: "bedrock without base", voice: config.VoiceConfig{Provider: "bedrock"}, wantErr: true},
+		{name: "whisper.cpp without model", voice: config.VoiceConfig{Provider: "whisper_cpp"}, wantErr: true},
+		{
+			name: "whisper.cpp missing binary",
+			voice: config.VoiceConfig{
+				Provider:         "whisper_cpp",
+				WhisperCppBinary: "/nonexistent/whisper-cli",
+				WhisperCppModel:  "m.bin",
+			},
+		},
+		{name: "unknown", voice: config.VoiceConfig{Provider: "nope"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Voice = tt.voice
+			cfg.Providers.Groq.APIKey = tt.groqKey
+
+			tr, err := NewTranscriber(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTranscriber() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (tr == nil) != tt.wantNil {
+				t.Fatalf("NewTranscriber() = %v, wantNil %v", tr, tt.wantNil)
+			}
+			if tr != nil && tr.IsAvailable() != tt.available {
+				t.Errorf("IsAvailable() = %v, want %v", tr.IsAvailable(), tt.available)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Synthesizer converts reply text to speech.
+type Synthesizer interface {
+	// Formats lists the audio formats (file extensions) the provider can
+	// produce directly, preferred first
+	Formats() []string
+	// Synthesize writes speech for text in format to path
+	Synthesize(ctx context.Context, text, format, path string) error
+}
+
+// NewSynthesizer builds the speech synthesizer selected in cfg.Voice.TTS, or
+// returns nil when text-to-speech is disabled.
+func NewSynthesizer(cfg *config.Config) (Synthesizer, error) {
+	tts := cfg.Voice.TTS
+	switch tts.Provider {
+	case "":
+		return nil, nil
+	case "openai":
+		apiKey := firstNonEmpty(tts.APIKey, cfg.Providers.OpenAI.APIKey)
+		if apiKey == "" {
+			return nil, fmt.Errorf("tts provider %q requires an API key", tts.Provider)
+		}
+		return &OpenAISynthesizer{
+			apiKey:  apiKey,
+			apiBase: strings.TrimRight(firstNonEmpty(tts.APIBase, "https://api.openai.com/v1"), "/"),
+			model:   firstNonEmpty(tts.Model, "gpt-4o-mini-tts"),
+			voice:   firstNonEmpty(tts.Voice, "alloy"),
+		}, nil
+	case "polly":
+		if tts.AccessKeyID == "" || tts.SecretAccessKey == "" {
+			return nil, fmt.Errorf("tts provider %q requires access_key_id and secret_access_key", tts.Provider)
+		}
+		return &PollySynthesizer{
+			region:    firstNonEmpty(tts.Region, "us-east-1"),
+			accessKey: tts.AccessKeyID,
+			secretKey: tts.SecretAccessKey,
+			voice:     firstNonEmpty(tts.Voice, "Joanna"),
+			engine:    firstNonEmpty(tts.Model, "neural"),
+		}, nil
+	case "edge_tts":
+		return &EdgeTTSSynthesizer{
+			binary: firstNonEmpty(tts.Binary, "edge-tts"),
+			voice:  firstNonEmpty(tts.Voice, "en-US-AriaNeural"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown tts provider %q", tts.Provider)
+	}
+}
+
+// SynthesizeFile speaks text into a temporary file in the first of formats
+// the channel accepts. When the provider can't produce any of them, its own
+// output is converted with ffmpeg. The caller removes the file.
+func SynthesizeFile(ctx context.Context, s Synthesizer, text string, formats []string) (string, error) {
+	dir := filepath.Join(os.TempDir(), "picoclaw-tts")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create tts dir: %w", err)
+	}
+	base := filepath.Join(dir, uuid.NewString())
+
+	for _, format := range formats {
+		if slices.Contains(s.Formats(), format) {
+			path := base + "." + format
+			return path, s.Synthesize(ctx, text, format, path)
+		}
+	}
+	if len(formats) == 0 {
+		return "", fmt.Errorf("no audio format requested")
+	}
+
+	native := s.Formats()[0]
+	source := base + "." + native
+	if err := s.Synthesize(ctx, text, native, source); err != nil {
+		return "", err
+	}
+	defer os.Remove(source)
+
+	path := base + "." + formats[0]
+	if err := convertAudio(ctx, source, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// convertAudio transcodes with ffmpeg, picking settings the target format
+// needs for voice notes.
+func convertAudio(ctx context.Context, src, dst string) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg is required to produce %s audio", filepath.Ext(dst))
+	}
+
+	args := []string{"-nostdin", "-y", "-i", src}
+	switch filepath.Ext(dst) {
+	case ".amr":
+		args = append(args, "-ar", "8000", "-ac", "1", "-c:a", "libopencore_amrnb", "-b:a", "12.2k")
+	case ".ogg":
+		args = append(args, "-c:a", "libopus", "-b:a", "32k")
+	}
+	args = append(args, dst)
+
+	if out, err := exec.CommandContext(ctx, ffmpeg, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg conversion failed: %w: %s", err, lastLine(out))
+	}
+	logger.DebugCF("voice", "Converted synthesized audio", map[string]any{"path": dst})
+	return nil
+}
+
+var (
+	reSpeechCodeBlock = regexp.MustCompile("(?s)```.*?```")
+	reSpeechLink      = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	reSpeechMarkup    = regexp.MustCompile("[*_`#>~|]+")
+	reSpeechSpaces    = regexp.MustCompile(`[ \t]+`)
+)
+
+// SpeechText strips markdown so it isn't read aloud. Code blocks are dropped.
+func SpeechText(markdown string) string {
+	text := reSpeechCodeBlock.ReplaceAllString(markdown, "")
+	text = reSpeechLink.ReplaceAllString(text, "$1")
+	text = reSpeechMarkup.ReplaceAllString(text, "")
+	text = reSpeechSpaces.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,138 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+var ttsHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// OpenAISynthesizer uses the OpenAI /audio/speech endpoint.
+type OpenAISynthesizer struct {
+	apiKey  string
+	apiBase string
+	model   string
+	voice   string
+}
+
+func (s *OpenAISynthesizer) Formats() []string { return []string{"ogg", "mp3"} }
+
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text, format, path string) error {
+	responseFormat := format
+	if format == "ogg" {
+		responseFormat = "opus" // Ogg/Opus, what Telegram expects for voice notes
+	}
+
+	payload := map[string]string{
+		"model":           s.model,
+		"input":           text,
+		"voice":           s.voice,
+		"response_format": responseFormat,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiBase+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	return saveAudioResponse(req, path)
+}
+
+// PollySynthesizer uses Amazon Polly's SynthesizeSpeech API.
+type PollySynthesizer struct {
+	region    string
+	accessKey string
+	secretKey string
+	voice     string
+	engine    string
+	endpoint  string // overrides the regional endpoint, for tests
+	now       func() time.Time
+}
+
+func (s *PollySynthesizer) Formats() []string { return []string{"mp3"} }
+
+func (s *PollySynthesizer) Synthesize(ctx context.Context, text, format, path string) error {
+	body, err := json.Marshal(map[string]string{
+		"Engine":       s.engine,
+		"OutputFormat": format,
+		"Text":         text,
+		"VoiceId":      s.voice,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := s.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://polly.%s.amazonaws.com", s.region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/speech", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	utils.SignAWSRequest(req, body, s.region, "polly", s.accessKey, s.secretKey, now().UTC())
+
+	return saveAudioResponse(req, path)
+}
+
+// EdgeTTSSynthesizer runs the edge-tts CLI (Microsoft Edge's free online voices).
+type EdgeTTSSynthesizer struct {
+	binary string
+	voice  string
+}
+
+func (s *EdgeTTSSynthesizer) Formats() []string { return []string{"mp3"} }
+
+func (s *EdgeTTSSynthesizer) Synthesize(ctx context.Context, text, _, path string) error {
+	cmd := exec.CommandContext(ctx, s.binary, "--voice", s.voice, "--text", text, "--write-media", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("edge-tts failed: %w: %s", err, lastLine(out))
+	}
+	return nil
+}
+
+// saveAudioResponse performs req and writes the audio body to path.
+func saveAudioResponse(req *http.Request, path string) error {
+	resp, err := ttsHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write audio file: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,112 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSpeechText(t *testing.T) {
+	in := "# Result\n**Done!** See [the docs](https://x.y).\n```go\nfmt.Println()\n```\nBye"
+	want := "Result\nDone! See the docs.\n\nBye"
+	if got := SpeechText(in); got != want {
+		t.Errorf("SpeechText() = %q, want %q", got, want)
+	}
+}
+
+func TestOpenAISynthesizer(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" || r.Header.Get("Authorization") != "Bearer sk" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte("OggS-audio"))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Voice.TTS = config.TTSConfig{Provider: "openai", APIKey: "sk", APIBase: server.URL + "/v1"}
+	s, err := NewSynthesizer(cfg)
+	if err != nil {
+		t.Fatalf("NewSynthesizer() error = %v", err)
+	}
+
+	path, err := SynthesizeFile(context.Background(), s, "hello", []string{"ogg", "mp3"})
+	if err != nil {
+		t.Fatalf("SynthesizeFile() error = %v", err)
+	}
+	defer os.Remove(path)
+
+	if filepath.Ext(path) != ".ogg" || payload["response_format"] != "opus" || payload["input"] != "hello" {
+		t.Errorf("path = %s, payload = %v", path, payload)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "OggS-audio" {
+		t.Errorf("audio = %q", data)
+	}
+}
+
+func TestPollySynthesizerSignsRequest(t *testing.T) {
+	var auth, amzDate string
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		amzDate = r.Header.Get("X-Amz-Date")
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte("ID3"))
+	}))
+	defer server.Close()
+
+	s := &PollySynthesizer{
+		region:    "eu-west-1",
+		accessKey: "AKID",
+		secretKey: "secret",
+		voice:     "Amy",
+		engine:    "neural",
+		endpoint:  server.URL,
+		now:       func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	path := filepath.Join(t.TempDir(), "out.mp3")
+	if err := s.Synthesize(context.Background(), "hi", "mp3", path); err != nil {
+		t.Fatalf("Synthesize() error = %v", err)
+	}
+
+	prefix := "AWS4-HMAC-SHA256 Credential=AKID/20260102/eu-west-1/polly/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(auth, prefix) || len(auth) != len(prefix)+64 {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if amzDate != "20260102T030405Z" {
+		t.Errorf("X-Amz-Date = %q", amzDate)
+	}
+	if payload["VoiceId"] != "Amy" || payload["OutputFormat"] != "mp3" || payload["Engine"] != "neural" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestNewSynthesizerValidatesConfig(t *testing.T) {
+	for _, tts := range []config.TTSConfig{
+		{Provider: "openai"},
+		{Provider: "polly", AccessKeyID: "AKID"},
+		{Provider: "nope"},
+	} {
+		cfg := config.DefaultConfig()
+		cfg.Voice.TTS = tts
+		if _, err := NewSynthesizer(cfg); err == nil {
+			t.Errorf("NewSynthesizer(%+v) should fail", tts)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	if s, err := NewSynthesizer(cfg); s != nil || err != nil {
+		t.Errorf("disabled tts = %v, %v", s, err)
+	}
+}
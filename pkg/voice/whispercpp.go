@@ -0,0 +1,107 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// WhisperCppTranscriber transcribes locally with the whisper.cpp CLI. Audio
+// is converted to 16 kHz mono WAV with ffmpeg first when ffmpeg is installed.
+type WhisperCppTranscriber struct {
+	binary   string
+	model    string
+	language string // empty or "auto" detects the language
+}
+
+// whisperCppOutput is the part of whisper.cpp's -oj output we use.
+type whisperCppOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+func NewWhisperCppTranscriber(binary, model, language string) *WhisperCppTranscriber {
+	if binary == "" {
+		binary = "whisper-cli"
+	}
+	return &WhisperCppTranscriber{binary: binary, model: model, language: language}
+}
+
+func (t *WhisperCppTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	logger.InfoCF("voice", "Starting local transcription", map[string]any{"audio_file": audioFilePath})
+
+	tmpDir, err := os.MkdirTemp("", "picoclaw-whisper-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	input := audioFilePath
+	if ffmpeg, err := exec.LookPath("ffmpeg"); err == nil {
+		input = filepath.Join(tmpDir, "input.wav")
+		cmd := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-y", "-i", audioFilePath,
+			"-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", input)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("ffmpeg conversion failed: %w: %s", err, lastLine(out))
+		}
+	}
+
+	language := t.language
+	if language == "" {
+		language = "auto"
+	}
+	outBase := filepath.Join(tmpDir, "out")
+	cmd := exec.CommandContext(ctx, t.binary,
+		"-m", t.model, "-f", input, "-l", language, "-oj", "-of", outBase, "-np")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("whisper.cpp failed: %w: %s", err, lastLine(out))
+	}
+
+	data, err := os.ReadFile(outBase + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read whisper.cpp output: %w", err)
+	}
+	var output whisperCppOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse whisper.cpp output: %w", err)
+	}
+
+	var text strings.Builder
+	for _, segment := range output.Transcription {
+		text.WriteString(segment.Text)
+	}
+	result := &TranscriptionResponse{
+		Text:     strings.TrimSpace(text.String()),
+		Language: output.Result.Language,
+	}
+
+	logger.InfoCF("voice", "Local transcription completed", map[string]any{
+		"text_length": len(result.Text),
+		"language":    result.Language,
+	})
+	return result, nil
+}
+
+func (t *WhisperCppTranscriber) IsAvailable() bool {
+	if t.model == "" {
+		return false
+	}
+	_, err := exec.LookPath(t.binary)
+	return err == nil
+}
+
+// lastLine returns the last non-empty line of command output for errors.
+func lastLine(out []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return lines[len(lines)-1]
+}